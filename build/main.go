@@ -151,6 +151,7 @@ func build() {
 	buildGengen()
 	buildFaucet()
 	buildGenesisFileServer()
+	buildRemoteSigner()
 	generateGenesis()
 	buildMigrations()
 	buildPrereleaseTool()
@@ -161,6 +162,7 @@ func forcebuild() {
 	buildGengen()
 	buildFaucet()
 	buildGenesisFileServer()
+	buildRemoteSigner()
 	generateGenesis()
 	buildMigrations()
 	buildPrereleaseTool()
@@ -277,6 +279,12 @@ func buildGenesisFileServer() {
 	runCmd(cmd([]string{"go", "build", "-o", "./tools/genesis-file-server/genesis-file-server", "./tools/genesis-file-server/"}...))
 }
 
+func buildRemoteSigner() {
+	log.Println("Building remote signer...")
+
+	runCmd(cmd([]string{"go", "build", "-o", "./tools/remote-signer/remote-signer", "./tools/remote-signer/"}...))
+}
+
 func buildMigrations() {
 	log.Println("Building migrations...")
 	runCmd(cmd([]string{
@@ -5,11 +5,13 @@ import (
 	"encoding/json"
 	"fmt"
 	"io"
+	"time"
 
 	"github.com/filecoin-project/go-address"
 	cmdkit "github.com/ipfs/go-ipfs-cmdkit"
 	cmds "github.com/ipfs/go-ipfs-cmds"
 	files "github.com/ipfs/go-ipfs-files"
+	"github.com/pkg/errors"
 
 	"github.com/filecoin-project/go-filecoin/internal/pkg/crypto"
 	"github.com/filecoin-project/go-filecoin/internal/pkg/types"
@@ -20,9 +22,13 @@ var walletCmd = &cmds.Command{
 		Tagline: "Manage your filecoin wallets",
 	},
 	Subcommands: map[string]*cmds.Command{
-		"balance": balanceCmd,
-		"import":  walletImportCmd,
-		"export":  walletExportCmd,
+		"balance":           balanceCmd,
+		"import":            walletImportCmd,
+		"export":            walletExportCmd,
+		"encrypt":           walletEncryptCmd,
+		"unlock":            walletUnlockCmd,
+		"lock":              walletLockCmd,
+		"change-passphrase": walletChangePassphraseCmd,
 	},
 }
 
@@ -241,3 +247,89 @@ var walletExportCmd = &cmds.Command{
 		}),
 	},
 }
+
+var walletUnlockCmd = &cmds.Command{
+	Helptext: cmdkit.HelpText{
+		Tagline: "Unlock an encrypted wallet",
+		ShortDescription: `
+Makes the keys of an encrypted wallet available for signing. The wallet locks
+again after --timeout, or when 'wallet lock' is run if no timeout is given.
+The passphrase is read from stdin, or prompted for.
+`,
+	},
+	Arguments: []cmdkit.Argument{
+		cmdkit.FileArg("passphrase-file", false, false, "File containing the wallet passphrase").EnableStdin(),
+	},
+	Options: []cmdkit.Option{
+		cmdkit.StringOption("timeout", "duration after which the wallet locks again, e.g. 30m"),
+	},
+	PreRun: promptPassphrases(false, "Passphrase"),
+	Run: func(req *cmds.Request, re cmds.ResponseEmitter, env cmds.Environment) error {
+		var timeout time.Duration
+		if o, ok := req.Options["timeout"].(string); ok && o != "" {
+			var err error
+			timeout, err = time.ParseDuration(o)
+			if err != nil {
+				return errors.Wrap(err, "invalid timeout")
+			}
+		}
+		passphrases, err := requestPassphrases(req, 1)
+		if err != nil {
+			return err
+		}
+		return GetPorcelainAPI(env).WalletUnlock(passphrases[0], timeout)
+	},
+}
+
+var walletLockCmd = &cmds.Command{
+	Helptext: cmdkit.HelpText{
+		Tagline: "Lock an encrypted wallet",
+	},
+	Run: func(req *cmds.Request, re cmds.ResponseEmitter, env cmds.Environment) error {
+		return GetPorcelainAPI(env).WalletLock()
+	},
+}
+
+var walletChangePassphraseCmd = &cmds.Command{
+	Helptext: cmdkit.HelpText{
+		Tagline: "Change the passphrase of an encrypted wallet",
+		ShortDescription: `
+Reseals all keys of the wallet with the new passphrase. The wallet is locked
+afterwards. The current and new passphrases are read from stdin, one per line,
+or prompted for.
+`,
+	},
+	Arguments: []cmdkit.Argument{
+		cmdkit.FileArg("passphrase-file", false, false, "File containing the current and new wallet passphrases, one per line").EnableStdin(),
+	},
+	PreRun: promptPassphrases(true, "Current passphrase", "New passphrase"),
+	Run: func(req *cmds.Request, re cmds.ResponseEmitter, env cmds.Environment) error {
+		passphrases, err := requestPassphrases(req, 2)
+		if err != nil {
+			return err
+		}
+		return GetPorcelainAPI(env).WalletChangePassphrase(passphrases[0], passphrases[1])
+	},
+}
+
+var walletEncryptCmd = &cmds.Command{
+	Helptext: cmdkit.HelpText{
+		Tagline: "Encrypt a plaintext wallet",
+		ShortDescription: `
+Seals all keys of the wallet with a passphrase. The wallet is locked afterwards,
+and must be unlocked with 'wallet unlock' before it can sign, including for
+mining. The passphrase is read from stdin, or prompted for.
+`,
+	},
+	Arguments: []cmdkit.Argument{
+		cmdkit.FileArg("passphrase-file", false, false, "File containing the new wallet passphrase").EnableStdin(),
+	},
+	PreRun: promptPassphrases(true, "New passphrase"),
+	Run: func(req *cmds.Request, re cmds.ResponseEmitter, env cmds.Environment) error {
+		passphrases, err := requestPassphrases(req, 1)
+		if err != nil {
+			return err
+		}
+		return GetPorcelainAPI(env).WalletEncrypt(passphrases[0])
+	},
+}
@@ -8,7 +8,10 @@ import (
 	"strconv"
 	"strings"
 
+	"github.com/filecoin-project/go-filecoin/internal/app/go-filecoin/plumbing/events"
 	"github.com/filecoin-project/go-filecoin/internal/pkg/block"
+	"github.com/filecoin-project/go-filecoin/internal/pkg/chain"
+	"github.com/filecoin-project/go-filecoin/internal/pkg/chainsync"
 	"github.com/ipfs/go-cid"
 	cmdkit "github.com/ipfs/go-ipfs-cmdkit"
 	cmds "github.com/ipfs/go-ipfs-cmds"
@@ -21,10 +24,13 @@ var chainCmd = &cmds.Command{
 		Tagline: "Inspect the filecoin blockchain",
 	},
 	Subcommands: map[string]*cmds.Command{
+		"bad":      storeBadCmd,
 		"export":   storeExportCmd,
 		"head":     storeHeadCmd,
 		"import":   storeImportCmd,
 		"ls":       storeLsCmd,
+		"notify":   storeNotifyCmd,
+		"replay":   storeReplayCmd,
 		"status":   storeStatusCmd,
 		"set-head": storeSetHeadCmd,
 		"sync":     storeSyncCmd,
@@ -56,6 +62,46 @@ var storeHeadCmd = &cmds.Command{
 	},
 }
 
+var storeNotifyCmd = &cmds.Command{
+	Helptext: cmdkit.HelpText{
+		Tagline: "Stream changes of the chain head",
+		ShortDescription: `
+Outputs the current head, then the tipsets reverted from and applied to the
+head chain each time the head changes, until interrupted. On a reorg the
+reverted tipsets are listed highest first, followed by the applied tipsets
+lowest first.
+`,
+	},
+	Run: func(req *cmds.Request, re cmds.ResponseEmitter, env cmds.Environment) error {
+		changes, err := GetPorcelainAPI(env).ChainHeadChanges(req.Context)
+		if err != nil {
+			return err
+		}
+		for batch := range changes {
+			if err := re.Emit(batch); err != nil {
+				return err
+			}
+		}
+		return nil
+	},
+	Type: []events.HeadChange{},
+	Encoders: cmds.EncoderMap{
+		cmds.Text: cmds.MakeTypedEncoder(func(req *cmds.Request, w io.Writer, res *[]events.HeadChange) error {
+			for _, change := range *res {
+				height, err := change.TipSet.Height()
+				if err != nil {
+					return err
+				}
+				_, err = fmt.Fprintf(w, "%s\t%d\t%s\n", change.Type, height, change.TipSet.Key())
+				if err != nil {
+					return err
+				}
+			}
+			return nil
+		}),
+	},
+}
+
 var storeLsCmd = &cmds.Command{
 	Helptext: cmdkit.HelpText{
 		Tagline:          "List blocks in the blockchain",
@@ -185,6 +231,9 @@ var storeExportCmd = &cmds.Command{
 		cmdkit.StringArg("file", true, false, "File to export chain data to."),
 		cmdkit.StringArg("cids", true, true, "CID's of the blocks of the tipset to export from."),
 	},
+	Options: []cmdkit.Option{
+		cmdkit.IntOption("recent-stateroots", "Export only the state trees and messages of this many recent tipsets, with every header back to genesis"),
+	},
 	Run: func(req *cmds.Request, re cmds.ResponseEmitter, env cmds.Environment) error {
 		f, err := os.Create(req.Arguments[0])
 		if err != nil {
@@ -198,7 +247,12 @@ var storeExportCmd = &cmds.Command{
 		}
 		expKey := block.NewTipSetKey(expCids...)
 
-		if err := GetPorcelainAPI(env).ChainExport(req.Context, expKey, f); err != nil {
+		recent, _ := req.Options["recent-stateroots"].(int)
+		if recent < 0 {
+			return fmt.Errorf("recent-stateroots must not be negative")
+		}
+
+		if err := GetPorcelainAPI(env).ChainExport(req.Context, expKey, f, recent); err != nil {
 			return err
 		}
 		return nil
@@ -212,6 +266,9 @@ var storeImportCmd = &cmds.Command{
 	Arguments: []cmdkit.Argument{
 		cmdkit.FileArg("file", true, false, "File to import chain data from.").EnableStdin(),
 	},
+	Options: []cmdkit.Option{
+		cmdkit.IntOption("recompute", "Recompute the state transitions of this many recent tipsets to verify the import, at least the head").WithDefault(1),
+	},
 	Run: func(req *cmds.Request, re cmds.ResponseEmitter, env cmds.Environment) error {
 		iter := req.Files.Entries()
 		if !iter.Next() {
@@ -223,10 +280,108 @@ var storeImportCmd = &cmds.Command{
 			return fmt.Errorf("given file was not a files.File")
 		}
 		defer func() { _ = fi.Close() }()
-		headKey, err := GetPorcelainAPI(env).ChainImport(req.Context, fi)
+		recompute, _ := req.Options["recompute"].(int)
+		if recompute < 1 {
+			return fmt.Errorf("recompute must be at least 1")
+		}
+
+		headKey, err := GetPorcelainAPI(env).ChainImport(req.Context, fi, recompute)
 		if err != nil {
 			return err
 		}
 		return re.Emit(headKey)
 	},
 }
+
+var storeReplayCmd = &cmds.Command{
+	Helptext: cmdkit.HelpText{
+		Tagline:          "Run the state transition of a tipset again.",
+		ShortDescription: `Runs the messages of a tipset again on its parent state and checks the resulting state root and receipts against the ones stored for it.`,
+	},
+	Arguments: []cmdkit.Argument{
+		cmdkit.StringArg("cids", true, true, "CID's of the blocks of the tipset to replay."),
+	},
+	Run: func(req *cmds.Request, re cmds.ResponseEmitter, env cmds.Environment) error {
+		replayCids, err := cidsFromSlice(req.Arguments)
+		if err != nil {
+			return err
+		}
+		result, err := GetPorcelainAPI(env).ChainReplay(req.Context, block.NewTipSetKey(replayCids...))
+		if err != nil {
+			return err
+		}
+		return re.Emit(result)
+	},
+	Type: &chain.ReplayResult{},
+	Encoders: cmds.EncoderMap{
+		cmds.Text: cmds.MakeTypedEncoder(func(req *cmds.Request, w io.Writer, res *chain.ReplayResult) error {
+			status := "matches"
+			if !res.Matches() {
+				status = "MISMATCH"
+			}
+			_, err := fmt.Fprintf(w, "tipset %s: %s\nstate root:    %s (stored %s)\nreceipts root: %s (stored %s)\n",
+				res.TipSet, status, res.StateRoot, res.StoredStateRoot, res.ReceiptsRoot, res.StoredReceiptsRoot)
+			return err
+		}),
+	},
+}
+
+var storeBadCmd = &cmds.Command{
+	Helptext: cmdkit.HelpText{
+		Tagline: "Inspect the tipsets that failed validation",
+	},
+	Subcommands: map[string]*cmds.Command{
+		"ls":     storeBadLsCmd,
+		"unmark": storeBadUnmarkCmd,
+	},
+}
+
+var storeBadLsCmd = &cmds.Command{
+	Helptext: cmdkit.HelpText{
+		Tagline:          "List the tipsets that failed validation.",
+		ShortDescription: `Lists the tipsets the node will not sync again, most recently used first, with the validation error that caused their rejection and the peer that sent them.`,
+	},
+	Run: func(req *cmds.Request, re cmds.ResponseEmitter, env cmds.Environment) error {
+		return re.Emit(GetPorcelainAPI(env).ChainBadTipSets())
+	},
+	Type: []*chainsync.BadTipSet{},
+	Encoders: cmds.EncoderMap{
+		cmds.Text: cmds.MakeTypedEncoder(func(req *cmds.Request, w io.Writer, res []*chainsync.BadTipSet) error {
+			for _, bad := range res {
+				sender := bad.Sender
+				if sender == "" {
+					sender = "unknown"
+				}
+				if _, err := fmt.Fprintf(w, "%s\tsender: %s\treason: %s\n", bad.Key, sender, bad.Reason); err != nil {
+					return err
+				}
+			}
+			return nil
+		}),
+	},
+}
+
+var storeBadUnmarkCmd = &cmds.Command{
+	Helptext: cmdkit.HelpText{
+		Tagline:          "Forget that a tipset failed validation.",
+		ShortDescription: `Removes a tipset from the tipsets that failed validation, so that it is validated again when next received. Use this after upgrading a node whose validation rejected a valid tipset.`,
+	},
+	Arguments: []cmdkit.Argument{
+		cmdkit.StringArg("cids", true, true, "CID's of the blocks of the tipset to unmark."),
+	},
+	Run: func(req *cmds.Request, re cmds.ResponseEmitter, env cmds.Environment) error {
+		badCids, err := cidsFromSlice(req.Arguments)
+		if err != nil {
+			return err
+		}
+		key := block.NewTipSetKey(badCids...)
+		removed, err := GetPorcelainAPI(env).ChainUnmarkBadTipSet(key)
+		if err != nil {
+			return err
+		}
+		if !removed {
+			return fmt.Errorf("tipset %s is not marked bad", key)
+		}
+		return nil
+	},
+}
@@ -4,6 +4,7 @@ import (
 	"fmt"
 	"io"
 
+	"github.com/filecoin-project/go-fil-markets/retrievalmarket"
 	"github.com/filecoin-project/go-fil-markets/storagemarket/network"
 
 	"github.com/ipfs/go-cid"
@@ -25,6 +26,7 @@ var clientCmd = &cmds.Command{
 		"query-storage-deal":   clientQueryStorageDealCmd,
 		"verify-storage-deal":  clientVerifyStorageDealCmd,
 		"list-asks":            clientListAsksCmd,
+		"find-providers":       clientFindProvidersCmd,
 	},
 }
 
@@ -270,3 +272,38 @@ respectively.
 		}),
 	},
 }
+
+var clientFindProvidersCmd = &cmds.Command{
+	Helptext: cmdkit.HelpText{
+		Tagline: "Find retrieval providers of a piece",
+		ShortDescription: `
+Lists the miners that have made storage deals for the piece, and the miners
+whose peers advertise in the DHT the payloads stored in the piece by this
+node's storage deals. Results are returned one per line as the miner address
+and peer id respectively.
+`,
+	},
+	Arguments: []cmdkit.Argument{
+		cmdkit.StringArg("cid", true, false, "CID of piece to find"),
+	},
+	Run: func(req *cmds.Request, re cmds.ResponseEmitter, env cmds.Environment) error {
+		pieceCID, err := cid.Decode(req.Arguments[0])
+		if err != nil {
+			return err
+		}
+
+		for _, p := range GetRetrievalAPI(env).FindProviders(pieceCID.Bytes()) {
+			if err := re.Emit(p); err != nil {
+				return err
+			}
+		}
+		return nil
+	},
+	Type: retrievalmarket.RetrievalPeer{},
+	Encoders: cmds.EncoderMap{
+		cmds.Text: cmds.MakeTypedEncoder(func(req *cmds.Request, w io.Writer, p *retrievalmarket.RetrievalPeer) error {
+			fmt.Fprintf(w, "%s %s\n", p.Address, p.ID.Pretty()) // nolint: errcheck
+			return nil
+		}),
+	},
+}
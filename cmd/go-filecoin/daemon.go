@@ -7,6 +7,7 @@ import (
 	_ "net/http/pprof" // nolint: golint
 	"os"
 	"os/signal"
+	"strings"
 	"syscall"
 	"time"
 
@@ -36,6 +37,7 @@ var daemonCmd = &cmds.Command{
 		cmdkit.BoolOption(OfflineMode, "start the node without networking"),
 		cmdkit.BoolOption(ELStdout),
 		cmdkit.BoolOption(IsRelay, "advertise and allow filecoin network traffic to be relayed through this node"),
+		cmdkit.StringOption(Checkpoint, "comma separated cids of the blocks of a trusted tipset, chains not including it are refused"),
 		cmdkit.StringOption(BlockTime, "time a node waits before trying to mine the next block").WithDefault(clock.DefaultEpochDuration.String()),
 	},
 	Run: func(req *cmds.Request, re cmds.ResponseEmitter, env cmds.Environment) error {
@@ -71,6 +73,14 @@ func daemonRun(req *cmds.Request, re cmds.ResponseEmitter) error {
 		rep.Config().Swarm.PublicRelayAddress = publicRelayAddress
 	}
 
+	if checkpoint, ok := req.Options[Checkpoint].(string); ok && checkpoint != "" {
+		cids, err := cidsFromSlice(strings.Split(checkpoint, ","))
+		if err != nil {
+			return errors.Wrap(err, "Bad checkpoint passed")
+		}
+		rep.Config().Chain.Checkpoint = cids
+	}
+
 	opts, err := node.OptionsFromRepo(rep)
 	if err != nil {
 		return err
@@ -162,7 +172,7 @@ func RunAPIAndWait(ctx context.Context, nd *node.Node, config *config.APIConfig,
 		ctx:            ctx,
 		inspectorAPI:   NewInspectorAPI(nd.Repo),
 		porcelainAPI:   nd.PorcelainAPI,
-		//retrievalAPI:   nd.RetrievalProtocol.RetrievalClient,
+		retrievalAPI:   nd.RetrievalClient.Client,
 		//storageAPI:     nd.StorageProtocol.StorageClient,
 	}
 
@@ -187,6 +197,7 @@ func RunAPIAndWait(ctx context.Context, nd *node.Node, config *config.APIConfig,
 	handler := http.NewServeMux()
 	handler.Handle("/debug/pprof/", http.DefaultServeMux)
 	handler.Handle(APIPrefix+"/", cmdhttp.NewHandler(servenv, rootCmdDaemon, cfg))
+	handler.Handle(RPCPath, newRPCServer(servenv, config))
 
 	apiserv := http.Server{
 		Handler: handler,
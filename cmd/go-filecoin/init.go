@@ -48,6 +48,7 @@ var initCmd = &cmds.Command{
 		cmdkit.StringOption(GenesisFile, "path of file or HTTP(S) URL containing archive of genesis block DAG data"),
 		cmdkit.StringOption(PeerKeyFile, "path of file containing key to use for new node's libp2p identity"),
 		cmdkit.StringOption(WalletKeyFile, "path of file containing keys to import into the wallet on initialization"),
+		cmdkit.BoolOption(EncryptWallet, "when set, encrypts the wallet with a passphrase read from stdin, or prompted for"),
 		cmdkit.StringOption(WithMiner, "when set, creates a custom genesis block  a pre generated miner account, requires running the daemon using dev mode (--dev)"),
 		cmdkit.StringOption(OptionSectorDir, "path of directory into which staged and sealed sectors will be written"),
 		cmdkit.StringOption(DefaultAddress, "when set, sets the daemons's default address to the provided address"),
@@ -93,6 +94,13 @@ var initCmd = &cmds.Command{
 		if err != nil {
 			return err
 		}
+		if encrypt, _ := req.Options[EncryptWallet].(bool); encrypt {
+			passphrases, err := readPassphrases(os.Stdin, true, "Wallet passphrase")
+			if err != nil {
+				return err
+			}
+			initopts = append(initopts, node.WalletPassphraseOpt(passphrases[0]))
+		}
 
 		if err := node.Init(req.Context, rep, genesisFile, initopts...); err != nil {
 			return err
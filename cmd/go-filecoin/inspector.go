@@ -79,6 +79,8 @@ Prints out information about filecoin process and its environment.
 			sw.Printf("Free:  \t%d\n", info.Disk.Free)
 			sw.Printf("Total: \t%d\n", info.Disk.Total)
 			sw.Printf("FSType:\t%s\n", info.Disk.FSType)
+			sw.Printf("ChainStore:\t%d\n", info.Disk.ChainStoreSize)
+			sw.Printf("PieceStore:\t%d\n", info.Disk.PieceStoreSize)
 
 			// Print Memory Info
 			sw.Printf("\nMemory\n")
@@ -136,7 +138,8 @@ var diskInspectCmd = &cmds.Command{
 	Helptext: cmdkit.HelpText{
 		Tagline: "Print filesystem usage information.",
 		ShortDescription: `
-Prints out information about the filesystem.
+Prints out information about the filesystem, and the size on disk of the chain
+store and of the store of client and piece data.
 `,
 	},
 	Run: func(req *cmds.Request, res cmds.ResponseEmitter, env cmds.Environment) error {
@@ -153,6 +156,8 @@ Prints out information about the filesystem.
 			sw.Printf("Free:  \t%d\n", info.Free)
 			sw.Printf("Total: \t%d\n", info.Total)
 			sw.Printf("FSType:\t%s\n", info.FSType)
+			sw.Printf("ChainStore:\t%d\n", info.ChainStoreSize)
+			sw.Printf("PieceStore:\t%d\n", info.PieceStoreSize)
 			return sw.Error()
 		}),
 	},
@@ -274,9 +279,11 @@ type EnvironmentInfo struct {
 
 // DiskInfo contains information about disk usage and type.
 type DiskInfo struct {
-	Free   uint64
-	Total  uint64
-	FSType string
+	Free           uint64
+	Total          uint64
+	FSType         string
+	ChainStoreSize uint64
+	PieceStoreSize uint64
 }
 
 // MemoryInfo contains information about memory usage.
@@ -330,10 +337,21 @@ func (g *Inspector) Disk() (*DiskInfo, error) {
 		return nil, err
 	}
 
+	chainSize, err := fsr.ChainStoreSize()
+	if err != nil {
+		return nil, err
+	}
+	pieceSize, err := fsr.PieceStoreSize()
+	if err != nil {
+		return nil, err
+	}
+
 	return &DiskInfo{
-		Free:   dinfo.Free,
-		Total:  dinfo.Total,
-		FSType: dinfo.FsType,
+		Free:           dinfo.Free,
+		Total:          dinfo.Total,
+		FSType:         dinfo.FsType,
+		ChainStoreSize: chainSize,
+		PieceStoreSize: pieceSize,
 	}, nil
 }
 
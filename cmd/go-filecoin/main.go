@@ -40,6 +40,9 @@ const (
 	// APIPrefix is the prefix for the http version of the api.
 	APIPrefix = "/api"
 
+	// RPCPath is the path of the JSON-RPC endpoint, over HTTP and websocket.
+	RPCPath = "/rpc/v0"
+
 	// OfflineMode tells us if we should try to connect this Filecoin node to the network
 	OfflineMode = "offline"
 
@@ -58,6 +61,10 @@ const (
 	// NAT mapping.
 	SwarmPublicRelayAddress = "swarmrelaypublic"
 
+	// Checkpoint is the comma separated cids of the blocks of a tipset
+	// trusted without validating its state
+	Checkpoint = "checkpoint"
+
 	// BlockTime is the duration string of the block time the daemon will
 	// run with.  TODO: this should eventually be more explicitly grouped
 	// with testing as we won't be able to set blocktime in production.
@@ -69,6 +76,9 @@ const (
 	// WalletKeyFile is the path of file containing wallet keys that may be imported on initialization
 	WalletKeyFile = "wallet-keyfile"
 
+	// EncryptWallet when set, encrypts the wallet with a passphrase read from stdin or prompted for
+	EncryptWallet = "encrypt-wallet"
+
 	// WithMiner when set, creates a custom genesis block with a pre generated miner account, requires to run the daemon using dev mode (--dev)
 	WithMiner = "with-miner"
 
@@ -143,6 +153,7 @@ TOOL COMMANDS
   go-filecoin leb128                 - Leb128 cli encode/decode
   go-filecoin log                    - Interact with the daemon event log output
   go-filecoin protocol               - Show protocol parameter details
+  go-filecoin repo                   - Manage the filecoin repo
   go-filecoin version                - Show go-filecoin version information
 `,
 	},
@@ -167,6 +178,7 @@ var rootSubcmdsLocal = map[string]*cmds.Command{
 	"init":    initCmd,
 	"version": versionCmd,
 	"leb128":  leb128Cmd,
+	"repo":    repoCmd,
 }
 
 // all top level commands, available on daemon. set during init() to avoid configuration loops.
@@ -193,6 +205,7 @@ var rootSubcmdsDaemon = map[string]*cmds.Command{
 	"protocol":         protocolCmd,
 	"retrieval-client": retrievalClientCmd,
 	"show":             showCmd,
+	"state":            stateCmd,
 	"stats":            statsCmd,
 	"swarm":            swarmCmd,
 	"wallet":           walletCmd,
@@ -350,30 +363,55 @@ func isConnectionRefused(err error) bool {
 var priceOption = cmdkit.StringOption("gas-price", "Price (FIL e.g. 0.00013) to pay for each GasUnit consumed mining this message")
 var limitOption = cmdkit.Uint64Option("gas-limit", "Maximum GasUnits this message is allowed to consume")
 var previewOption = cmdkit.BoolOption("preview", "Preview the Gas cost of this command without actually executing it")
+var autoGasOption = cmdkit.BoolOption("auto-gas", "Estimate the gas price and limit not given, from recent messages and a local run of this message")
+
+// parseGasOptions returns the gas price and limit given by the options of
+// `req`, and whether the command is only previewed. With --auto-gas, a price
+// or limit not given is estimated, the limit from the gas used by the message
+// as reported by `previewGas`. Commands whose messages cannot be previewed do
+// not take --auto-gas.
+func parseGasOptions(req *cmds.Request, env cmds.Environment, previewGas func() (types.GasUnits, error)) (types.AttoFIL, types.GasUnits, bool, error) {
+	preview, _ := req.Options["preview"].(bool)
+	autoGas, _ := req.Options["auto-gas"].(bool)
+	// A preview does not use the gas price and limit.
+	estimate := autoGas && !preview
 
-func parseGasOptions(req *cmds.Request) (types.AttoFIL, types.GasUnits, bool, error) {
+	price := types.ZeroAttoFIL
 	priceOption := req.Options["gas-price"]
-	if priceOption == nil {
+	if priceOption != nil {
+		var ok bool
+		price, ok = types.NewAttoFILFromFILString(priceOption.(string))
+		if !ok {
+			return types.ZeroAttoFIL, types.GasUnits(0), false, errors.New("invalid gas price (specify FIL as a decimal number)")
+		}
+	} else if estimate {
+		var err error
+		price, err = GetPorcelainAPI(env).MessageEstimateGasPrice(req.Context)
+		if err != nil {
+			return types.ZeroAttoFIL, types.GasUnits(0), false, errors.Wrap(err, "failed to estimate gas price")
+		}
+	} else if !autoGas {
 		return types.ZeroAttoFIL, types.GasUnits(0), false, errors.New("gas-price option is required")
 	}
 
-	price, ok := types.NewAttoFILFromFILString(priceOption.(string))
-	if !ok {
-		return types.ZeroAttoFIL, types.GasUnits(0), false, errors.New("invalid gas price (specify FIL as a decimal number)")
-	}
-
+	gasLimit := types.GasUnits(0)
 	limitOption := req.Options["gas-limit"]
-	if limitOption == nil {
+	if limitOption != nil {
+		gasLimitInt, ok := limitOption.(uint64)
+		if !ok {
+			msg := fmt.Sprintf("invalid gas limit: %s", limitOption)
+			return types.ZeroAttoFIL, types.GasUnits(0), false, errors.New(msg)
+		}
+		gasLimit = types.GasUnits(gasLimitInt)
+	} else if estimate {
+		usedGas, err := previewGas()
+		if err != nil {
+			return types.ZeroAttoFIL, types.GasUnits(0), false, errors.Wrap(err, "failed to estimate gas limit")
+		}
+		gasLimit = GetPorcelainAPI(env).MessageEstimateGasLimit(usedGas)
+	} else if !autoGas {
 		return types.ZeroAttoFIL, types.GasUnits(0), false, errors.New("gas-limit option is required")
 	}
 
-	gasLimitInt, ok := limitOption.(uint64)
-	if !ok {
-		msg := fmt.Sprintf("invalid gas limit: %s", limitOption)
-		return types.ZeroAttoFIL, types.GasUnits(0), false, errors.New(msg)
-	}
-
-	preview, _ := req.Options["preview"].(bool)
-
-	return price, types.GasUnits(gasLimitInt), preview, nil
+	return price, gasLimit, preview, nil
 }
@@ -5,7 +5,9 @@ import (
 	"encoding/json"
 	"fmt"
 	"io"
+	"math/big"
 	"strconv"
+	"strings"
 	"time"
 
 	"github.com/filecoin-project/go-address"
@@ -21,6 +23,7 @@ import (
 	"github.com/filecoin-project/go-filecoin/internal/pkg/vm"
 
 	"github.com/filecoin-project/go-filecoin/internal/app/go-filecoin/plumbing/cst"
+	"github.com/filecoin-project/go-filecoin/internal/app/go-filecoin/plumbing/events"
 	"github.com/filecoin-project/go-filecoin/internal/app/go-filecoin/plumbing/msg"
 	"github.com/filecoin-project/go-filecoin/internal/pkg/message"
 	"github.com/filecoin-project/go-filecoin/internal/pkg/types"
@@ -31,10 +34,13 @@ var msgCmd = &cmds.Command{
 		Tagline: "Send and monitor messages",
 	},
 	Subcommands: map[string]*cmds.Command{
+		"estimate":   msgEstimateCmd,
 		"send":       msgSendCmd,
 		"sendsigned": signedMsgSendCmd,
 		"status":     msgStatusCmd,
+		"trace":      msgTraceCmd,
 		"wait":       msgWaitCmd,
+		"watch":      msgWatchCmd,
 	},
 }
 
@@ -58,6 +64,7 @@ var msgSendCmd = &cmds.Command{
 		cmdkit.StringOption("from", "Address to send message from"),
 		priceOption,
 		limitOption,
+		autoGasOption,
 		previewOption,
 		// TODO: (per dignifiedquire) add an option to set the nonce and method explicitly
 	},
@@ -81,22 +88,25 @@ var msgSendCmd = &cmds.Command{
 			return err
 		}
 
-		gasPrice, gasLimit, preview, err := parseGasOptions(req)
-		if err != nil {
-			return err
-		}
-
 		methodID := builtin.MethodSend
 		methodInput, ok := req.Options["method"].(uint64)
 		if ok {
 			methodID = abi.MethodNum(methodInput)
 		}
 
+		gasPrice, gasLimit, preview, err := parseGasOptions(req, env, func() (types.GasUnits, error) {
+			return GetPorcelainAPI(env).MessagePreview(req.Context, fromAddr, target, val, methodID)
+		})
+		if err != nil {
+			return err
+		}
+
 		if preview {
 			usedGas, err := GetPorcelainAPI(env).MessagePreview(
 				req.Context,
 				fromAddr,
 				target,
+				val,
 				methodID,
 			)
 			if err != nil {
@@ -142,6 +152,84 @@ var msgSendCmd = &cmds.Command{
 	},
 }
 
+// MessageEstimateResult is the return type for message estimate command
+type MessageEstimateResult struct {
+	GasUsed  types.GasUnits
+	GasLimit types.GasUnits
+	GasPrice types.AttoFIL
+}
+
+var msgEstimateCmd = &cmds.Command{
+	Helptext: cmdkit.HelpText{
+		Tagline: "Estimate the gas price and limit of a message",
+		ShortDescription: `Runs the message against the head state to find the gas it uses, and suggests
+a gas limit adding the message.gasLimitMarginPercent config margin to it. The
+gas price suggested is the message.gasPricePercentile percentile of the gas
+prices of the messages included in the last message.gasPriceLookback tipsets.
+The gas price is shown in FIL, as taken by --gas-price.`,
+	},
+	Arguments: []cmdkit.Argument{
+		cmdkit.StringArg("target", true, false, "Address of the actor to send the message to"),
+	},
+	Options: []cmdkit.Option{
+		cmdkit.StringOption("value", "Value to send with message in FIL"),
+		cmdkit.StringOption("from", "Address to send message from"),
+		cmdkit.Uint64Option("method", "The method to invoke on the target actor"),
+	},
+	Run: func(req *cmds.Request, re cmds.ResponseEmitter, env cmds.Environment) error {
+		target, err := address.NewFromString(req.Arguments[0])
+		if err != nil {
+			return err
+		}
+
+		rawVal := req.Options["value"]
+		if rawVal == nil {
+			rawVal = "0"
+		}
+		val, ok := types.NewAttoFILFromFILString(rawVal.(string))
+		if !ok {
+			return errors.New("mal-formed value")
+		}
+
+		fromAddr, err := fromAddrOrDefault(req, env)
+		if err != nil {
+			return err
+		}
+
+		methodID := builtin.MethodSend
+		methodInput, ok := req.Options["method"].(uint64)
+		if ok {
+			methodID = abi.MethodNum(methodInput)
+		}
+
+		estimate, err := GetPorcelainAPI(env).MessageEstimate(req.Context, fromAddr, target, val, methodID)
+		if err != nil {
+			return err
+		}
+		return re.Emit(&MessageEstimateResult{
+			GasUsed:  estimate.GasUsed,
+			GasLimit: estimate.GasLimit,
+			GasPrice: estimate.GasPrice,
+		})
+	},
+	Type: &MessageEstimateResult{},
+	Encoders: cmds.EncoderMap{
+		cmds.Text: cmds.MakeTypedEncoder(func(req *cmds.Request, w io.Writer, res *MessageEstimateResult) error {
+			_, err := fmt.Fprintf(w, "gas used: %d\ngas limit: %d\ngas price: %s\n", res.GasUsed, res.GasLimit, attoFILToFILString(res.GasPrice))
+			return err
+		}),
+	},
+}
+
+// attoFILToFILString formats an amount of attoFIL in FIL, as parsed by
+// types.NewAttoFILFromFILString.
+func attoFILToFILString(amount types.AttoFIL) string {
+	fil := new(big.Rat).SetFrac(amount.Int, big.NewInt(1e18))
+	s := fil.FloatString(18)
+	s = strings.TrimRight(s, "0")
+	return strings.TrimSuffix(s, ".")
+}
+
 var signedMsgSendCmd = &cmds.Command{
 	Helptext: cmdkit.HelpText{
 		Tagline: "Send a signed message",
@@ -365,6 +453,65 @@ var msgStatusCmd = &cmds.Command{
 	},
 }
 
+var msgTraceCmd = &cmds.Command{
+	Helptext: cmdkit.HelpText{
+		Tagline: "Show the execution trace of a message",
+		ShortDescription: `
+Replays the tipset that included a message and shows the trace of the
+message's execution: the internal sends it made, their exit codes and return
+values, the gas charged by each operation and the reads and writes of actor
+state.
+`,
+	},
+	Arguments: []cmdkit.Argument{
+		cmdkit.StringArg("cid", true, false, "CID of the message to trace"),
+	},
+	Run: func(req *cmds.Request, re cmds.ResponseEmitter, env cmds.Environment) error {
+		msgCid, err := cid.Parse(req.Arguments[0])
+		if err != nil {
+			return errors.Wrap(err, "invalid cid "+req.Arguments[0])
+		}
+
+		trace, err := GetPorcelainAPI(env).MessageTrace(req.Context, msgCid)
+		if err != nil {
+			return err
+		}
+		return re.Emit(trace)
+	},
+	Type: vm.ExecutionTrace{},
+	Encoders: cmds.EncoderMap{
+		cmds.Text: cmds.MakeTypedEncoder(func(req *cmds.Request, w io.Writer, trace *vm.ExecutionTrace) error {
+			sw := NewSilentWriter(w)
+			printTrace(sw, trace, "")
+			return sw.Error()
+		}),
+	},
+}
+
+func printTrace(sw *SilentWriter, trace *vm.ExecutionTrace, indent string) {
+	sw.Printf("%s%s -> %s method %d value %s: exit code %d, gas used %d\n",
+		indent, trace.From, trace.To, trace.Method, trace.Value, trace.ExitCode, trace.GasUsed())
+	if len(trace.Params) > 0 {
+		sw.Printf("%s  params %x\n", indent, trace.Params)
+	}
+	if len(trace.Return) > 0 {
+		sw.Printf("%s  return %x\n", indent, trace.Return)
+	}
+	for _, charge := range trace.GasCharges {
+		sw.Printf("%s  gas %s %d\n", indent, charge.Name, charge.Amount)
+	}
+	for _, access := range trace.StateAccesses {
+		op := "read"
+		if access.Write {
+			op = "write"
+		}
+		sw.Printf("%s  state %s %s\n", indent, op, access.Head)
+	}
+	for _, sub := range trace.Subcalls {
+		printTrace(sw, sub, indent+"  ")
+	}
+}
+
 func appendJSON(val interface{}, out []byte) ([]byte, error) {
 	m, err := json.MarshalIndent(val, "", "\t")
 	if err != nil {
@@ -374,3 +521,49 @@ func appendJSON(val interface{}, out []byte) ([]byte, error) {
 	out = append(out, byte('\n'))
 	return out, nil
 }
+
+var msgWatchCmd = &cmds.Command{
+	Helptext: cmdkit.HelpText{
+		Tagline: "Stream messages from or to addresses as they are mined",
+		ShortDescription: `
+Outputs each message sent from or to one of the watched addresses, with its
+receipt, when the tipset including it is applied to the head chain, and again
+when that tipset is reverted by a reorg, until interrupted.
+`,
+	},
+	Options: []cmdkit.Option{
+		cmdkit.StringOption("addr", "Comma separated addresses to watch"),
+	},
+	Run: func(req *cmds.Request, re cmds.ResponseEmitter, env cmds.Environment) error {
+		rawAddrs, _ := req.Options["addr"].(string)
+		if rawAddrs == "" {
+			return errors.New("at least one address to watch is required")
+		}
+		var addrs []address.Address
+		for _, raw := range strings.Split(rawAddrs, ",") {
+			addr, err := address.NewFromString(raw)
+			if err != nil {
+				return errors.Wrapf(err, "invalid address %s", raw)
+			}
+			addrs = append(addrs, addr)
+		}
+
+		msgEvents, err := GetPorcelainAPI(env).MessageWatch(req.Context, addrs)
+		if err != nil {
+			return err
+		}
+		for event := range msgEvents {
+			if err := re.Emit(event); err != nil {
+				return err
+			}
+		}
+		return nil
+	},
+	Type: events.MessageEvent{},
+	Encoders: cmds.EncoderMap{
+		cmds.Text: cmds.MakeTypedEncoder(func(req *cmds.Request, w io.Writer, res *events.MessageEvent) error {
+			_, err := fmt.Fprintf(w, "%s\t%d\t%s\t%s\t%s\t%d\n", res.Type, res.Height, res.Cid, res.Message.Message.From, res.Message.Message.To, res.Receipt.ExitCode)
+			return err
+		}),
+	},
+}
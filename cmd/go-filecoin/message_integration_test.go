@@ -81,6 +81,26 @@ func TestMessageSend(t *testing.T) {
 		"--value", "5.5",
 		fixtures.TestAddresses[3].String(),
 	)
+
+	t.Log("[success] with estimated gas")
+	cmdClient.RunSuccess(
+		ctx,
+		"message", "send",
+		"--from", from.String(),
+		"--auto-gas",
+		"--value", "10",
+		fixtures.TestAddresses[3].String(),
+	)
+
+	t.Log("[success] estimate")
+	estimate := cmdClient.RunSuccess(
+		ctx,
+		"message", "estimate",
+		"--from", from.String(),
+		fixtures.TestAddresses[3].String(),
+	).ReadStdoutTrimNewlines()
+	assert.Contains(t, estimate, "gas limit: ")
+	assert.Contains(t, estimate, "gas price: ")
 }
 
 func TestMessageWait(t *testing.T) {
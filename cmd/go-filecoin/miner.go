@@ -22,7 +22,7 @@ import (
 
 var minerCmd = &cmds.Command{
 	Helptext: cmdkit.HelpText{
-		Tagline: "Manage a single miner actor",
+		Tagline: "Manage the miner actors of a node",
 	},
 	Subcommands: map[string]*cmds.Command{
 		"create":        minerCreateCmd,
@@ -58,6 +58,7 @@ additional sectors.`,
 		cmdkit.StringOption("peerid", "Base58-encoded libp2p peer ID that the miner will operate"),
 		priceOption,
 		limitOption,
+		autoGasOption,
 		previewOption,
 	},
 	Run: func(req *cmds.Request, re cmds.ResponseEmitter, env cmds.Environment) error {
@@ -90,7 +91,9 @@ additional sectors.`,
 			return ErrInvalidCollateral
 		}
 
-		gasPrice, gasLimit, preview, err := parseGasOptions(req)
+		gasPrice, gasLimit, preview, err := parseGasOptions(req, env, func() (types.GasUnits, error) {
+			return GetPorcelainAPI(env).MinerPreviewCreate(req.Context, fromAddr, sectorSize, pid, collateral)
+		})
 		if err != nil {
 			return err
 		}
@@ -101,6 +104,7 @@ additional sectors.`,
 				fromAddr,
 				sectorSize,
 				pid,
+				collateral,
 			)
 			if err != nil {
 				return err
@@ -162,7 +166,7 @@ This command waits for the ask to be mined.`,
 	},
 	Options: []cmdkit.Option{
 		cmdkit.StringOption("from", "Address to send from"),
-		cmdkit.StringOption("miner", "The address of the miner owning the ask"),
+		cmdkit.StringOption("miner", "The address of the miner owning the ask, defaults to the node's miner"),
 		priceOption,
 		limitOption,
 	},
@@ -177,12 +181,9 @@ This command waits for the ask to be mined.`,
 			return err
 		}
 
-		var minerAddr address.Address
-		if req.Options["miner"] != nil {
-			minerAddr, err = address.NewFromString(req.Options["miner"].(string))
-			if err != nil {
-				return errors.Wrap(err, "miner must be an address")
-			}
+		minerAddr, err := minerAddrOrDefault(req, env)
+		if err != nil {
+			return err
 		}
 
 		expiry, ok := big.NewInt(0).SetString(req.Arguments[1], 10)
@@ -190,7 +191,7 @@ This command waits for the ask to be mined.`,
 			return fmt.Errorf("expiry must be a valid integer")
 		}
 
-		gasPrice, gasLimit, _, err := parseGasOptions(req)
+		gasPrice, gasLimit, _, err := parseGasOptions(req, env, nil)
 		if err != nil {
 			return err
 		}
@@ -238,6 +239,7 @@ var minerUpdatePeerIDCmd = &cmds.Command{
 		cmdkit.StringOption("from", "Address to send from"),
 		priceOption,
 		limitOption,
+		autoGasOption,
 		previewOption,
 	},
 	Run: func(req *cmds.Request, re cmds.ResponseEmitter, env cmds.Environment) error {
@@ -256,7 +258,9 @@ var minerUpdatePeerIDCmd = &cmds.Command{
 			return err
 		}
 
-		gasPrice, gasLimit, preview, err := parseGasOptions(req)
+		gasPrice, gasLimit, preview, err := parseGasOptions(req, env, func() (types.GasUnits, error) {
+			return GetPorcelainAPI(env).MessagePreview(req.Context, fromAddr, minerAddr, types.ZeroAttoFIL, builtin.MethodsMiner.ChangePeerID, newPid)
+		})
 		if err != nil {
 			return err
 		}
@@ -266,6 +270,7 @@ var minerUpdatePeerIDCmd = &cmds.Command{
 				req.Context,
 				fromAddr,
 				minerAddr,
+				types.ZeroAttoFIL,
 				builtin.MethodsMiner.ChangePeerID,
 				newPid,
 			)
@@ -320,8 +325,14 @@ var minerStatusCommand = &cmds.Command{
 		Tagline: "Get the status of a miner",
 	},
 	Run: func(req *cmds.Request, re cmds.ResponseEmitter, env cmds.Environment) error {
-		minerAddr, err := optionalAddr(req.Arguments[0])
-		if err != nil {
+		var minerAddr address.Address
+		var err error
+		if len(req.Arguments) > 0 {
+			minerAddr, err = address.NewFromString(req.Arguments[0])
+			if err != nil {
+				return errors.Wrap(err, "miner must be an address")
+			}
+		} else if minerAddr, err = minerAddrOrDefault(req, env); err != nil {
 			return err
 		}
 
@@ -334,7 +345,10 @@ var minerStatusCommand = &cmds.Command{
 	},
 	Type: porcelain.MinerStatus{},
 	Arguments: []cmdkit.Argument{
-		cmdkit.StringArg("miner", true, false, "A miner actor address"),
+		cmdkit.StringArg("miner", false, false, "A miner actor address, defaults to the --miner option"),
+	},
+	Options: []cmdkit.Option{
+		cmdkit.StringOption("miner", "The address of the miner, defaults to the node's miner"),
 	},
 }
 
@@ -347,8 +361,10 @@ var minerSetWorkerAddressCmd = &cmds.Command{
 		cmdkit.StringArg("new-address", true, false, "The address of the new miner worker."),
 	},
 	Options: []cmdkit.Option{
+		cmdkit.StringOption("miner", "The address of the miner, defaults to the node's miner"),
 		priceOption,
 		limitOption,
+		autoGasOption,
 	},
 	Run: func(req *cmds.Request, re cmds.ResponseEmitter, env cmds.Environment) error {
 		newWorker, err := address.NewFromString(req.Arguments[0])
@@ -356,12 +372,19 @@ var minerSetWorkerAddressCmd = &cmds.Command{
 			return err
 		}
 
-		gasPrice, gasLimit, _, err := parseGasOptions(req)
+		minerAddr, err := minerAddrOrDefault(req, env)
+		if err != nil {
+			return err
+		}
+
+		gasPrice, gasLimit, _, err := parseGasOptions(req, env, func() (types.GasUnits, error) {
+			return GetPorcelainAPI(env).MinerPreviewSetWorkerAddress(req.Context, minerAddr, newWorker)
+		})
 		if err != nil {
 			return err
 		}
 
-		msgCid, err := GetPorcelainAPI(env).MinerSetWorkerAddress(req.Context, newWorker, gasPrice, gasLimit)
+		msgCid, err := GetPorcelainAPI(env).MinerSetWorkerAddress(req.Context, minerAddr, newWorker, gasPrice, gasLimit)
 		if err != nil {
 			return err
 		}
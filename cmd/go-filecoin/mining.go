@@ -9,6 +9,8 @@ import (
 	"github.com/ipfs/go-cid"
 	cmdkit "github.com/ipfs/go-ipfs-cmdkit"
 	cmds "github.com/ipfs/go-ipfs-cmds"
+
+	"github.com/filecoin-project/go-filecoin/internal/pkg/mining"
 )
 
 var miningCmd = &cmds.Command{
@@ -96,8 +98,9 @@ var miningStartCmd = &cmds.Command{
 
 // MiningStatusResult is the type returned when get mining status.
 type MiningStatusResult struct {
-	Miner  address.Address `json:"minerAddress"`
-	Active bool            `json:"active"`
+	Miner  address.Address      `json:"minerAddress"`
+	Active bool                 `json:"active"`
+	Miners []mining.MinerResult `json:"miners"`
 }
 
 var miningStatusCmd = &cmds.Command{
@@ -116,6 +119,7 @@ var miningStatusCmd = &cmds.Command{
 		return re.Emit(&MiningStatusResult{
 			Miner:  minerAddress,
 			Active: isMining,
+			Miners: GetBlockAPI(env).MinerResults(),
 		})
 	},
 	Type: &MiningStatusResult{},
@@ -125,8 +129,31 @@ var miningStatusCmd = &cmds.Command{
 Active:     %s
 Address:    %s
 `, strconv.FormatBool(res.Active), res.Miner)
-
-			return err
+			if err != nil {
+				return err
+			}
+
+			for _, m := range res.Miners {
+				lastBlock := "none"
+				if m.LastBlock.Defined() {
+					lastBlock = m.LastBlock.String()
+				}
+				if _, err := fmt.Fprintf(w, `
+Miner:      %s
+Elections:  %d
+Wins:       %d
+Last Epoch: %d
+Last Block: %s
+`, m.Miner, m.Elections, m.Wins, m.LastEpoch, lastBlock); err != nil {
+					return err
+				}
+				if m.LastError != "" {
+					if _, err := fmt.Fprintf(w, "Last Error: %s\n", m.LastError); err != nil {
+						return err
+					}
+				}
+			}
+			return nil
 		}),
 	},
 }
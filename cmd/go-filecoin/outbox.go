@@ -4,6 +4,7 @@ import (
 	"io"
 
 	"github.com/filecoin-project/go-address"
+	"github.com/filecoin-project/specs-actors/actors/abi"
 	cmdkit "github.com/ipfs/go-ipfs-cmdkit"
 	cmds "github.com/ipfs/go-ipfs-cmds"
 
@@ -15,17 +16,26 @@ var outboxCmd = &cmds.Command{
 		Tagline: "View and manipulate the outbound message queue",
 	},
 	Subcommands: map[string]*cmds.Command{
-		"clear": outboxClearCmd,
-		"ls":    outboxLsCmd,
+		"clear":  outboxClearCmd,
+		"ls":     outboxLsCmd,
+		"resend": outboxResendCmd,
 	},
 }
 
 // OutboxLsResult is a listing of the outbox for a single address.
 type OutboxLsResult struct {
-	Address  address.Address
+	Address address.Address
+	// Height of the head when listed, from which the age of messages is counted.
+	Height   abi.ChainEpoch
 	Messages []*message.Queued
 }
 
+// OutboxResendResult is the number of messages published again for an address.
+type OutboxResendResult struct {
+	Address address.Address
+	Count   int
+}
+
 var outboxLsCmd = &cmds.Command{
 	Helptext: cmdkit.HelpText{
 		Tagline: "List the queue(s) of sent but un-mined messages",
@@ -38,10 +48,18 @@ var outboxLsCmd = &cmds.Command{
 		if err != nil {
 			return err
 		}
+		head, err := GetPorcelainAPI(env).ChainHead()
+		if err != nil {
+			return err
+		}
+		height, err := head.Height()
+		if err != nil {
+			return err
+		}
 
 		for _, addr := range addresses {
 			msgs := GetPorcelainAPI(env).OutboxQueueLs(addr)
-			err := re.Emit(OutboxLsResult{addr, msgs})
+			err := re.Emit(OutboxLsResult{addr, height, msgs})
 			if err != nil {
 				return err
 			}
@@ -55,7 +73,11 @@ var outboxLsCmd = &cmds.Command{
 			sw.Println("From:", queue.Address.String())
 			for _, qm := range queue.Messages {
 				msg := qm.Msg
-				sw.Printf("%s, height: %d\n", msg.String(), qm.Stamp)
+				age := uint64(0)
+				if uint64(queue.Height) > qm.Stamp {
+					age = uint64(queue.Height) - qm.Stamp
+				}
+				sw.Printf("%s, height: %d, age: %d rounds, attempts: %d\n", msg.String(), qm.Stamp, age, qm.Attempts)
 			}
 			return sw.Error()
 		}),
@@ -83,6 +105,45 @@ var outboxClearCmd = &cmds.Command{
 	Encoders: cmds.EncoderMap{},
 }
 
+var outboxResendCmd = &cmds.Command{
+	Helptext: cmdkit.HelpText{
+		Tagline: "Broadcast the queue(s) of sent but un-mined messages again",
+		ShortDescription: `
+Publishes the queued messages of an address, or of all addresses, to the message
+pool and the network again. Use it when messages seem stuck, e.g. because peers
+dropped them.
+`,
+	},
+	Arguments: []cmdkit.Argument{
+		cmdkit.StringArg("address", false, false, "Address of the queue to resend (otherwise resends all)"),
+	},
+	Run: func(req *cmds.Request, re cmds.ResponseEmitter, env cmds.Environment) error {
+		addresses, err := queueAddressesFromArg(req, env, 0)
+		if err != nil {
+			return err
+		}
+
+		for _, addr := range addresses {
+			count, err := GetPorcelainAPI(env).OutboxQueueResend(req.Context, addr)
+			if err != nil {
+				return err
+			}
+			if err := re.Emit(OutboxResendResult{addr, count}); err != nil {
+				return err
+			}
+		}
+		return nil
+	},
+	Type: OutboxResendResult{},
+	Encoders: cmds.EncoderMap{
+		cmds.Text: cmds.MakeTypedEncoder(func(req *cmds.Request, w io.Writer, res *OutboxResendResult) error {
+			sw := NewSilentWriter(w)
+			sw.Printf("%s: resent %d messages\n", res.Address, res.Count)
+			return sw.Error()
+		}),
+	},
+}
+
 // Reads an address from an argument, or lists addresses of all outbox queues if no arg is given.
 func queueAddressesFromArg(req *cmds.Request, env cmds.Environment, argIndex int) ([]address.Address, error) {
 	var addresses []address.Address
@@ -0,0 +1,111 @@
+package commands
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+
+	cmds "github.com/ipfs/go-ipfs-cmds"
+	files "github.com/ipfs/go-ipfs-files"
+	"github.com/pkg/errors"
+	"golang.org/x/crypto/ssh/terminal"
+)
+
+// Passphrases are never taken as command line arguments, where they would end
+// up in shell history and the process list. They are read one per line from
+// stdin, or from a file given in place of stdin, or prompted for without echo
+// when stdin is a terminal.
+
+// promptPassphrases is a PreRun that prompts for passphrases on the client
+// when none were piped to the command. The passphrases are sent to the daemon
+// in the request body, as if they had been read from stdin. If confirm is set
+// the last passphrase is prompted for twice.
+func promptPassphrases(confirm bool, prompts ...string) func(*cmds.Request, cmds.Environment) error {
+	return func(req *cmds.Request, env cmds.Environment) error {
+		if req.Files != nil {
+			return nil
+		}
+		if !terminal.IsTerminal(int(os.Stdin.Fd())) {
+			return errors.New("no passphrase given on stdin")
+		}
+		passphrases, err := readPassphrases(os.Stdin, confirm, prompts...)
+		if err != nil {
+			return err
+		}
+		req.Files = files.NewMapDirectory(map[string]files.Node{
+			"passphrase": files.NewBytesFile([]byte(strings.Join(passphrases, "\n") + "\n")),
+		})
+		return nil
+	}
+}
+
+// requestPassphrases reads n passphrases, one per line, from the request body.
+func requestPassphrases(req *cmds.Request, n int) ([]string, error) {
+	if req.Files == nil {
+		return nil, errors.New("no passphrase given")
+	}
+	iter := req.Files.Entries()
+	if !iter.Next() {
+		return nil, fmt.Errorf("no passphrase given: %s", iter.Err())
+	}
+	fi, ok := iter.Node().(files.File)
+	if !ok {
+		return nil, fmt.Errorf("given file was not a files.File")
+	}
+	return scanPassphrases(fi, n)
+}
+
+// readPassphrases prompts for passphrases without echo if in is a terminal,
+// and otherwise reads them one per line from in.
+func readPassphrases(in *os.File, confirm bool, prompts ...string) ([]string, error) {
+	if !terminal.IsTerminal(int(in.Fd())) {
+		return scanPassphrases(in, len(prompts))
+	}
+
+	var passphrases []string
+	for _, prompt := range prompts {
+		passphrase, err := promptPassphrase(in, prompt)
+		if err != nil {
+			return nil, err
+		}
+		passphrases = append(passphrases, passphrase)
+	}
+	if confirm && len(prompts) > 0 {
+		again, err := promptPassphrase(in, "Repeat "+strings.ToLower(prompts[len(prompts)-1]))
+		if err != nil {
+			return nil, err
+		}
+		if again != passphrases[len(passphrases)-1] {
+			return nil, errors.New("passphrases do not match")
+		}
+	}
+	return passphrases, nil
+}
+
+func promptPassphrase(in *os.File, prompt string) (string, error) {
+	fmt.Fprintf(os.Stderr, "%s: ", prompt) // nolint: errcheck
+	b, err := terminal.ReadPassword(int(in.Fd()))
+	fmt.Fprintln(os.Stderr) // nolint: errcheck
+	if err != nil {
+		return "", errors.Wrap(err, "failed to read passphrase")
+	}
+	return string(b), nil
+}
+
+// scanPassphrases reads n passphrases, one per line, from r.
+func scanPassphrases(r io.Reader, n int) ([]string, error) {
+	var passphrases []string
+	scanner := bufio.NewScanner(r)
+	for len(passphrases) < n && scanner.Scan() {
+		passphrases = append(passphrases, strings.TrimSuffix(scanner.Text(), "\r"))
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, errors.Wrap(err, "failed to read passphrase")
+	}
+	if len(passphrases) < n {
+		return nil, fmt.Errorf("expected %d passphrase(s), one per line", n)
+	}
+	return passphrases, nil
+}
@@ -1,19 +1,25 @@
 package commands
 
 import (
+	"encoding/json"
 	"fmt"
 	"io"
 
+	"github.com/filecoin-project/specs-actors/actors/abi"
 	"github.com/ipfs/go-ipfs-cmdkit"
 	"github.com/ipfs/go-ipfs-cmds"
 
 	"github.com/filecoin-project/go-filecoin/internal/app/go-filecoin/porcelain"
+	"github.com/filecoin-project/go-filecoin/internal/pkg/vm"
 )
 
 var protocolCmd = &cmds.Command{
 	Helptext: cmdkit.HelpText{
 		Tagline: "Show protocol parameter details",
 	},
+	Subcommands: map[string]*cmds.Command{
+		"gas-prices": protocolGasPricesCmd,
+	},
 	Run: func(req *cmds.Request, re cmds.ResponseEmitter, env cmds.Environment) error {
 		params, err := GetPorcelainAPI(env).ProtocolParameters(env.Context())
 		if err != nil {
@@ -57,3 +63,58 @@ func readableBytesAmount(amt float64) string {
 
 	return fmt.Sprintf("%.2f %s", amt, units[unit])
 }
+
+// GasPricesResult is the gas pricelist active at an epoch.
+type GasPricesResult struct {
+	Epoch           abi.ChainEpoch
+	ProtocolVersion uint64
+	Prices          vm.GasPrices
+}
+
+var protocolGasPricesCmd = &cmds.Command{
+	Helptext: cmdkit.HelpText{
+		Tagline:          "Show the gas prices charged by the vm",
+		ShortDescription: `Shows the protocol version and gas price table in effect at an epoch, by default the height of the chain head.`,
+	},
+	Options: []cmdkit.Option{
+		cmdkit.Int64Option("at", "Epoch to show the gas prices at"),
+	},
+	Run: func(req *cmds.Request, re cmds.ResponseEmitter, env cmds.Environment) error {
+		api := GetPorcelainAPI(env)
+
+		var epoch abi.ChainEpoch
+		if at, ok := req.Options["at"].(int64); ok {
+			epoch = abi.ChainEpoch(at)
+		} else {
+			head, err := api.ChainHead()
+			if err != nil {
+				return err
+			}
+			epoch, err = head.Height()
+			if err != nil {
+				return err
+			}
+		}
+
+		protocolVersion, prices, err := api.ProtocolGasPrices(epoch)
+		if err != nil {
+			return err
+		}
+		return re.Emit(&GasPricesResult{Epoch: epoch, ProtocolVersion: protocolVersion, Prices: prices})
+	},
+	Type: &GasPricesResult{},
+	Encoders: cmds.EncoderMap{
+		cmds.Text: cmds.MakeTypedEncoder(func(req *cmds.Request, w io.Writer, res *GasPricesResult) error {
+			_, err := fmt.Fprintf(w, "Epoch: %d\nProtocol Version: %d\n", res.Epoch, res.ProtocolVersion)
+			if err != nil {
+				return err
+			}
+			table, err := json.MarshalIndent(res.Prices, "", "  ")
+			if err != nil {
+				return err
+			}
+			_, err = fmt.Fprintf(w, "Prices: %s\n", table)
+			return err
+		}),
+	},
+}
@@ -0,0 +1,63 @@
+package commands
+
+import (
+	"fmt"
+	"io"
+
+	cmdkit "github.com/ipfs/go-ipfs-cmdkit"
+	cmds "github.com/ipfs/go-ipfs-cmds"
+
+	"github.com/filecoin-project/go-filecoin/internal/app/go-filecoin/node"
+	"github.com/filecoin-project/go-filecoin/internal/app/go-filecoin/paths"
+	"github.com/filecoin-project/go-filecoin/internal/pkg/gc"
+	"github.com/filecoin-project/go-filecoin/internal/pkg/repo"
+)
+
+var repoCmd = &cmds.Command{
+	Helptext: cmdkit.HelpText{
+		Tagline: "Manage the filecoin repo",
+	},
+	Subcommands: map[string]*cmds.Command{
+		"gc": repoGCCmd,
+	},
+}
+
+var repoGCCmd = &cmds.Command{
+	Helptext: cmdkit.HelpText{
+		Tagline: "Delete unreachable blocks from the repo chain blockstore",
+		ShortDescription: `
+Garbage collects the chain blockstore of a repo that is not in use by a
+daemon. Chain headers back to genesis, the state and messages of recent
+tipsets (see the gc.retainedEpochs config option) and messages sent from or
+to wallet addresses are kept. Everything else is deleted. Client and piece
+data is kept in a separate blockstore and never collected.
+Set gc.enabled to collect in the background while the daemon runs instead.
+`,
+	},
+	Run: func(req *cmds.Request, re cmds.ResponseEmitter, env cmds.Environment) error {
+		repoDir, _ := req.Options[OptionRepoDir].(string)
+		repoDir, err := paths.GetRepoPath(repoDir)
+		if err != nil {
+			return err
+		}
+		rep, err := repo.OpenFSRepo(repoDir, repo.Version)
+		if err != nil {
+			return err
+		}
+		// The only error Close can return is that the repo has already been closed.
+		defer func() { _ = rep.Close() }()
+
+		result, err := node.CollectGarbage(req.Context, rep)
+		if err != nil {
+			return err
+		}
+		return re.Emit(result)
+	},
+	Type: gc.Result{},
+	Encoders: cmds.EncoderMap{
+		cmds.Text: cmds.MakeTypedEncoder(func(req *cmds.Request, w io.Writer, result *gc.Result) error {
+			_, err := fmt.Fprintf(w, "removed %d blocks, freed %d bytes\n", result.BlocksRemoved, result.BytesFreed)
+			return err
+		}),
+	},
+}
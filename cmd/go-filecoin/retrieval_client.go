@@ -1,8 +1,16 @@
 package commands
 
 import (
+	"bytes"
+	"fmt"
+
+	"github.com/filecoin-project/go-address"
+	"github.com/filecoin-project/go-fil-markets/retrievalmarket"
+	"github.com/filecoin-project/specs-actors/actors/abi/big"
+	"github.com/ipfs/go-cid"
 	cmdkit "github.com/ipfs/go-ipfs-cmdkit"
 	cmds "github.com/ipfs/go-ipfs-cmds"
+	"github.com/pkg/errors"
 )
 
 var retrievalClientCmd = &cmds.Command{
@@ -17,34 +25,89 @@ var retrievalClientCmd = &cmds.Command{
 var clientRetrievePieceCmd = &cmds.Command{
 	Helptext: cmdkit.HelpText{
 		Tagline: "Read out piece data stored by a miner on the network",
+		ShortDescription: `
+Queries the miner for the piece and retrieves it at the miner's asking price,
+paying through a payment channel from the sending address to the miner's worker.
+The channel is created on chain if none exists yet.
+`,
 	},
 	Arguments: []cmdkit.Argument{
 		cmdkit.StringArg("miner", true, false, "Retrieval miner actor address"),
 		cmdkit.StringArg("cid", true, false, "Content identifier of piece to read"),
 	},
+	Options: []cmdkit.Option{
+		cmdkit.StringOption("from", "Address to pay for the retrieval from"),
+	},
 	Run: func(req *cmds.Request, re cmds.ResponseEmitter, env cmds.Environment) error {
-		panic("TODO: go-fil-markets integration")
-
-		//minerAddr, err := address.NewFromString(req.Arguments[0])
-		//if err != nil {
-		//	return err
-		//}
-		//
-		//pieceCID, err := cid.Decode(req.Arguments[1])
-		//if err != nil {
-		//	return err
-		//}
-		//
-		//mpid, err := GetPorcelainAPI(env).MinerGetPeerID(req.Context, minerAddr)
-		//if err != nil {
-		//	return err
-		//}
-		//
-		//readCloser, err := GetRetrievalAPI(env).RetrievePiece(req.Context, pieceCID, mpid, minerAddr)
-		//if err != nil {
-		//	return err
-		//}
-		//
-		//return re.Emit(readCloser)
+		minerAddr, err := address.NewFromString(req.Arguments[0])
+		if err != nil {
+			return err
+		}
+
+		pieceCID, err := cid.Decode(req.Arguments[1])
+		if err != nil {
+			return err
+		}
+
+		fromAddr, err := fromAddrOrDefault(req, env)
+		if err != nil {
+			return err
+		}
+
+		porcelainAPI := GetPorcelainAPI(env)
+		status, err := porcelainAPI.MinerGetStatus(req.Context, minerAddr, porcelainAPI.ChainHeadKey())
+		if err != nil {
+			return err
+		}
+
+		client := GetRetrievalAPI(env)
+		rpeer := retrievalmarket.RetrievalPeer{Address: minerAddr, ID: status.PeerID}
+		pieceBytes := pieceCID.Bytes()
+		resp, err := client.Query(req.Context, rpeer, pieceBytes, retrievalmarket.QueryParams{})
+		if err != nil {
+			return err
+		}
+		if resp.Status != retrievalmarket.QueryResponseAvailable {
+			return fmt.Errorf("piece %s is not available from miner %s", pieceCID, minerAddr)
+		}
+
+		done := make(chan error, 1)
+		unsubscribe := client.SubscribeToEvents(func(event retrievalmarket.ClientEvent, state retrievalmarket.ClientDealState) {
+			if !bytes.Equal(state.PieceCID, pieceBytes) {
+				return
+			}
+			var result error
+			switch event {
+			case retrievalmarket.ClientEventComplete:
+			case retrievalmarket.ClientEventError:
+				result = errors.Errorf("retrieval failed: %s", state.Message)
+			default:
+				return
+			}
+			select {
+			case done <- result:
+			default:
+			}
+		})
+		defer unsubscribe()
+
+		params := retrievalmarket.NewParamsV0(resp.MinPricePerByte, resp.MaxPaymentInterval, resp.MaxPaymentIntervalIncrease)
+		totalFunds := big.Mul(resp.MinPricePerByte, big.NewIntUnsigned(resp.Size))
+		client.Retrieve(req.Context, pieceBytes, params, totalFunds, status.PeerID, fromAddr, status.WorkerAddress)
+
+		select {
+		case err := <-done:
+			if err != nil {
+				return err
+			}
+		case <-req.Context.Done():
+			return req.Context.Err()
+		}
+
+		reader, err := porcelainAPI.DAGCat(req.Context, pieceCID)
+		if err != nil {
+			return err
+		}
+		return re.Emit(reader)
 	},
 }
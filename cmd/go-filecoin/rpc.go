@@ -0,0 +1,235 @@
+package commands
+
+import (
+	"context"
+	"net/http"
+
+	"github.com/filecoin-project/go-address"
+	"github.com/filecoin-project/specs-actors/actors/abi"
+	"github.com/filecoin-project/specs-actors/actors/util/adt"
+	"github.com/ipfs/go-cid"
+	"github.com/pkg/errors"
+
+	"github.com/filecoin-project/go-filecoin/internal/app/go-filecoin/plumbing/events"
+	"github.com/filecoin-project/go-filecoin/internal/app/go-filecoin/plumbing/msg"
+	"github.com/filecoin-project/go-filecoin/internal/pkg/block"
+	"github.com/filecoin-project/go-filecoin/internal/pkg/chainsync/status"
+	"github.com/filecoin-project/go-filecoin/internal/pkg/config"
+	"github.com/filecoin-project/go-filecoin/internal/pkg/jsonrpc"
+	"github.com/filecoin-project/go-filecoin/internal/pkg/types"
+	"github.com/filecoin-project/go-filecoin/internal/pkg/vm"
+	"github.com/filecoin-project/go-filecoin/internal/pkg/vm/actor"
+)
+
+// RPCTipSet is the representation of a tipset in JSON-RPC results.
+type RPCTipSet struct {
+	Key    block.TipSetKey `json:"key"`
+	Height abi.ChainEpoch  `json:"height"`
+	Blocks []*block.Block  `json:"blocks"`
+}
+
+func newRPCTipSet(ts block.TipSet) (*RPCTipSet, error) {
+	height, err := ts.Height()
+	if err != nil {
+		return nil, err
+	}
+	return &RPCTipSet{Key: ts.Key(), Height: height, Blocks: ts.ToSlice()}, nil
+}
+
+type rpcTipSetParams struct {
+	Key block.TipSetKey `json:"key"`
+}
+
+type rpcCidParams struct {
+	Cid cid.Cid `json:"cid"`
+}
+
+type rpcStateParams struct {
+	Address address.Address `json:"address"`
+	// The tipset whose state to read, the head if empty.
+	TipSet block.TipSetKey `json:"tipset"`
+}
+
+type rpcAddressParams struct {
+	Address address.Address `json:"address"`
+}
+
+type rpcAddressesParams struct {
+	Addresses []address.Address `json:"addresses"`
+}
+
+type rpcNewAddressParams struct {
+	// bls or secp256k1, the default.
+	Protocol string `json:"protocol"`
+}
+
+type rpcSendParams struct {
+	// The sender, the default wallet address if undefined.
+	From     address.Address `json:"from"`
+	To       address.Address `json:"to"`
+	Value    types.AttoFIL   `json:"value"`
+	GasPrice types.AttoFIL   `json:"gasPrice"`
+	GasLimit types.GasUnits  `json:"gasLimit"`
+	Method   abi.MethodNum   `json:"method"`
+}
+
+// RPCMessageResult is a message found in a block, with its receipt.
+type RPCMessageResult struct {
+	Found   bool                 `json:"found"`
+	Block   *block.Block         `json:"block,omitempty"`
+	Message *types.SignedMessage `json:"message,omitempty"`
+	Receipt *vm.MessageReceipt   `json:"receipt,omitempty"`
+}
+
+func newRPCMessageResult(chainMsg *msg.ChainMessage, found bool) *RPCMessageResult {
+	if !found {
+		return &RPCMessageResult{}
+	}
+	return &RPCMessageResult{
+		Found:   true,
+		Block:   chainMsg.Block,
+		Message: chainMsg.Message,
+		Receipt: chainMsg.Receipt,
+	}
+}
+
+// newRPCServer returns a JSON-RPC server exposing the node APIs of `env`
+// under stable method names. Requests are accepted from the origins allowed
+// by `cfg`.
+func newRPCServer(env *Env, cfg *config.APIConfig) *jsonrpc.Server {
+	s := jsonrpc.NewServer(func(r *http.Request) bool {
+		origin := r.Header.Get("Origin")
+		if origin == "" {
+			return true
+		}
+		for _, allowed := range cfg.AccessControlAllowOrigin {
+			if allowed == "*" || allowed == origin {
+				return true
+			}
+		}
+		return false
+	})
+	api := env.porcelainAPI
+
+	// Chain
+	s.Register("Filecoin.ChainHead", "Returns the head tipset", func(context.Context) (*RPCTipSet, error) {
+		head, err := api.ChainHead()
+		if err != nil {
+			return nil, err
+		}
+		return newRPCTipSet(head)
+	})
+	s.Register("Filecoin.ChainGetTipSet", "Returns the tipset with a key", func(_ context.Context, p *rpcTipSetParams) (*RPCTipSet, error) {
+		ts, err := api.ChainTipSet(p.Key)
+		if err != nil {
+			return nil, err
+		}
+		return newRPCTipSet(ts)
+	})
+	s.Register("Filecoin.ChainGetBlock", "Returns the block with a CID", func(ctx context.Context, p *rpcCidParams) (*block.Block, error) {
+		return api.ChainGetBlock(ctx, p.Cid)
+	})
+	s.Register("Filecoin.ChainGetMessages", "Returns the messages of a block, by the CID of its message collection", func(ctx context.Context, p *rpcCidParams) ([]*types.SignedMessage, error) {
+		return api.ChainGetMessages(ctx, p.Cid)
+	})
+	s.Register("Filecoin.ChainGetReceipts", "Returns the receipts of a tipset, by the CID of their collection", func(ctx context.Context, p *rpcCidParams) ([]vm.MessageReceipt, error) {
+		return api.ChainGetReceipts(ctx, p.Cid)
+	})
+	s.Register("Filecoin.ChainSyncStatus", "Returns the status of the chain syncer", func(context.Context) (status.Status, error) {
+		return api.SyncerStatus(), nil
+	})
+	s.Register("Filecoin.ChainNotify", "Subscribes to batches of tipsets applied to and reverted from the head chain, starting with the current head", func(ctx context.Context) (<-chan []events.HeadChange, error) {
+		return api.ChainHeadChanges(ctx)
+	})
+
+	// State
+	s.Register("Filecoin.StateGetActor", "Returns an actor in the state of a tipset", func(ctx context.Context, p *rpcStateParams) (*actor.Actor, error) {
+		if p.TipSet.Empty() {
+			return api.ActorGet(ctx, p.Address)
+		}
+		return api.ActorGetAt(ctx, p.TipSet, p.Address)
+	})
+
+	// Wallet
+	s.Register("Filecoin.WalletAddresses", "Returns the addresses in the wallet", func(context.Context) ([]address.Address, error) {
+		return api.WalletAddresses(), nil
+	})
+	s.Register("Filecoin.WalletDefaultAddress", "Returns the default wallet address", func(context.Context) (address.Address, error) {
+		return api.WalletDefaultAddress()
+	})
+	s.Register("Filecoin.WalletNewAddress", "Creates a new wallet address", func(_ context.Context, p *rpcNewAddressParams) (address.Address, error) {
+		switch p.Protocol {
+		case "", "secp256k1":
+			return api.WalletNewAddress(address.SECP256K1)
+		case "bls":
+			return api.WalletNewAddress(address.BLS)
+		default:
+			return address.Undef, errors.Errorf("unrecognized address protocol %s", p.Protocol)
+		}
+	})
+	s.Register("Filecoin.WalletBalance", "Returns the balance of an address at the head", func(ctx context.Context, p *rpcAddressParams) (abi.TokenAmount, error) {
+		return api.WalletBalance(ctx, p.Address)
+	})
+
+	// Message pool
+	s.Register("Filecoin.MpoolPending", "Returns the messages in the message pool", func(context.Context) ([]*types.SignedMessage, error) {
+		return api.MessagePoolPending(), nil
+	})
+
+	// Messages
+	s.Register("Filecoin.MessageSend", "Signs and sends a message without parameters, returning its CID", func(ctx context.Context, p *rpcSendParams) (cid.Cid, error) {
+		from := p.From
+		if from.Empty() {
+			var err error
+			if from, err = api.WalletDefaultAddress(); err != nil {
+				return cid.Undef, err
+			}
+		}
+		value := p.Value
+		if value.Nil() {
+			value = types.ZeroAttoFIL
+		}
+		gasPrice := p.GasPrice
+		if gasPrice.Nil() {
+			gasPrice = types.ZeroAttoFIL
+		}
+		c, _, err := api.MessageSend(ctx, from, p.To, value, gasPrice, p.GasLimit, p.Method, &adt.EmptyValue{})
+		return c, err
+	})
+	s.Register("Filecoin.MessageWait", "Waits for a message to appear in the head chain and returns it with its block and receipt", func(ctx context.Context, p *rpcCidParams) (*RPCMessageResult, error) {
+		var result *RPCMessageResult
+		err := api.MessageWait(ctx, p.Cid, func(blk *block.Block, smsg *types.SignedMessage, receipt *vm.MessageReceipt) error {
+			result = newRPCMessageResult(&msg.ChainMessage{Message: smsg, Block: blk, Receipt: receipt}, true)
+			return nil
+		})
+		if err != nil {
+			return nil, err
+		}
+		return result, nil
+	})
+	s.Register("Filecoin.MessageFind", "Looks a message up in the head chain", func(ctx context.Context, p *rpcCidParams) (*RPCMessageResult, error) {
+		chainMsg, found, err := api.MessageFind(ctx, p.Cid)
+		if err != nil {
+			return nil, err
+		}
+		return newRPCMessageResult(chainMsg, found), nil
+	})
+	s.Register("Filecoin.MessageWatch", "Subscribes to the messages from or to addresses in tipsets applied to and reverted from the head chain", func(ctx context.Context, p *rpcAddressesParams) (<-chan events.MessageEvent, error) {
+		return api.MessageWatch(ctx, p.Addresses)
+	})
+
+	// Mining
+	s.Register("Filecoin.MiningStatus", "Returns whether the node is mining, the miner address it mines with and the election results of each of its miners", func(context.Context) (*MiningStatusResult, error) {
+		minerAddress, err := env.blockMiningAPI.MinerAddress()
+		if err != nil {
+			return nil, err
+		}
+		return &MiningStatusResult{
+			Miner:  minerAddress,
+			Active: env.blockMiningAPI.MiningIsActive(),
+			Miners: env.blockMiningAPI.MinerResults(),
+		}, nil
+	})
+
+	return s
+}
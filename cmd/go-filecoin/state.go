@@ -0,0 +1,99 @@
+package commands
+
+import (
+	"fmt"
+	"io"
+
+	"github.com/ipfs/go-cid"
+	cmdkit "github.com/ipfs/go-ipfs-cmdkit"
+	cmds "github.com/ipfs/go-ipfs-cmds"
+	"github.com/pkg/errors"
+
+	"github.com/filecoin-project/go-filecoin/internal/pkg/state"
+)
+
+var stateCmd = &cmds.Command{
+	Helptext: cmdkit.HelpText{
+		Tagline: "Inspect the state of the filecoin vm",
+	},
+	Subcommands: map[string]*cmds.Command{
+		"diff": stateDiffCmd,
+	},
+}
+
+var stateDiffCmd = &cmds.Command{
+	Helptext: cmdkit.HelpText{
+		Tagline: "Show the actors that differ between two state roots.",
+		ShortDescription: `Lists the actors added (+), removed (-) and changed (~) from the first state root to the second.
+For builtin actors the changed fields of the actor state are listed too.`,
+	},
+	Arguments: []cmdkit.Argument{
+		cmdkit.StringArg("rootA", true, false, "CID of the state root to diff from."),
+		cmdkit.StringArg("rootB", true, false, "CID of the state root to diff to."),
+	},
+	Run: func(req *cmds.Request, re cmds.ResponseEmitter, env cmds.Environment) error {
+		rootA, err := cid.Decode(req.Arguments[0])
+		if err != nil {
+			return errors.Wrap(err, "invalid state root")
+		}
+		rootB, err := cid.Decode(req.Arguments[1])
+		if err != nil {
+			return errors.Wrap(err, "invalid state root")
+		}
+		diffs, err := GetPorcelainAPI(env).StateDiff(req.Context, rootA, rootB)
+		if err != nil {
+			return err
+		}
+		return re.Emit(diffs)
+	},
+	Type: []*state.ActorStateDiff{},
+	Encoders: cmds.EncoderMap{
+		cmds.Text: cmds.MakeTypedEncoder(func(req *cmds.Request, w io.Writer, diffs []*state.ActorStateDiff) error {
+			for _, d := range diffs {
+				var err error
+				switch {
+				case d.Before == nil:
+					_, err = fmt.Fprintf(w, "+ %s code=%s balance=%s\n", d.Address, d.After.Code, d.After.Balance)
+				case d.After == nil:
+					_, err = fmt.Fprintf(w, "- %s code=%s balance=%s\n", d.Address, d.Before.Code, d.Before.Balance)
+				default:
+					_, err = fmt.Fprintf(w, "~ %s\n", d.Address)
+					if err == nil {
+						err = printActorChanges(w, d)
+					}
+				}
+				if err != nil {
+					return err
+				}
+			}
+			return nil
+		}),
+	},
+}
+
+func printActorChanges(w io.Writer, d *state.ActorStateDiff) error {
+	changes := []struct {
+		name          string
+		before, after interface{}
+		changed       bool
+	}{
+		{"code", d.Before.Code, d.After.Code, !d.Before.Code.Equals(d.After.Code.Cid)},
+		{"nonce", d.Before.CallSeqNum, d.After.CallSeqNum, d.Before.CallSeqNum != d.After.CallSeqNum},
+		{"balance", d.Before.Balance, d.After.Balance, !d.Before.Balance.Equals(d.After.Balance)},
+		{"head", d.Before.Head, d.After.Head, !d.Before.Head.Equals(d.After.Head.Cid)},
+	}
+	for _, c := range changes {
+		if !c.changed {
+			continue
+		}
+		if _, err := fmt.Fprintf(w, "    %s: %v -> %v\n", c.name, c.before, c.after); err != nil {
+			return err
+		}
+	}
+	for _, f := range d.Fields {
+		if _, err := fmt.Fprintf(w, "    state.%s: %v -> %v\n", f.Field, f.Before, f.After); err != nil {
+			return err
+		}
+	}
+	return nil
+}
@@ -40,17 +40,30 @@ var swarmPeersCmd = &cmds.Command{
 		cmdkit.BoolOption("verbose", "v", "Display all extra information"),
 		cmdkit.BoolOption("streams", "Also list information about open streams for each peer"),
 		cmdkit.BoolOption("latency", "Also list information about latency to each peer"),
+		cmdkit.BoolOption("scores", "Also list the sync behaviour score of each peer"),
 	},
 	Run: func(req *cmds.Request, re cmds.ResponseEmitter, env cmds.Environment) error {
 		verbose, _ := req.Options["verbose"].(bool)
 		latency, _ := req.Options["latency"].(bool)
 		streams, _ := req.Options["streams"].(bool)
+		scores, _ := req.Options["scores"].(bool)
 
 		out, err := GetPorcelainAPI(env).NetworkPeers(req.Context, verbose, latency, streams)
 		if err != nil {
 			return err
 		}
 
+		if verbose || scores {
+			peerScores := make(map[string]int)
+			for _, ps := range GetPorcelainAPI(env).NetworkPeerScores() {
+				peerScores[ps.Peer.Pretty()] = ps.Score
+			}
+			for i := range out.Peers {
+				score := peerScores[out.Peers[i].Peer]
+				out.Peers[i].Score = &score
+			}
+		}
+
 		return re.Emit(&out)
 	},
 	Encoders: cmds.EncoderMap{
@@ -66,6 +79,9 @@ var swarmPeersCmd = &cmds.Command{
 				if info.Latency != "" {
 					fmt.Fprintf(w, " %s", info.Latency) // nolint: errcheck
 				}
+				if info.Score != nil {
+					fmt.Fprintf(w, " score: %d", *info.Score) // nolint: errcheck
+				}
 				fmt.Fprintln(w) // nolint: errcheck
 
 				for _, s := range info.Streams {
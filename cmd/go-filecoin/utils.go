@@ -124,6 +124,19 @@ func fromAddrOrDefault(req *cmds.Request, env cmds.Environment) (address.Address
 	return addr, nil
 }
 
+// minerAddrOrDefault returns the miner of the --miner option, or the one the
+// node mines with if the option is not set.
+func minerAddrOrDefault(req *cmds.Request, env cmds.Environment) (address.Address, error) {
+	if req.Options["miner"] != nil {
+		addr, err := address.NewFromString(req.Options["miner"].(string))
+		if err != nil {
+			return address.Undef, errors.Wrap(err, "miner must be an address")
+		}
+		return addr, nil
+	}
+	return GetBlockAPI(env).MinerAddress()
+}
+
 func cidsFromSlice(args []string) ([]cid.Cid, error) {
 	out := make([]cid.Cid, len(args))
 	for i, arg := range args {
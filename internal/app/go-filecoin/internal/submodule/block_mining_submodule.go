@@ -17,8 +17,11 @@ type BlockMiningSubmodule struct {
 	// Mining stuff.
 	AddNewlyMinedBlock newBlockFunc
 	// cancelMining cancels the context for block production and sector commitments.
-	CancelMining    context.CancelFunc
-	MiningWorker    *mining.DefaultWorker
+	CancelMining context.CancelFunc
+	// MiningWorker runs the elections of all the node's miners, each with
+	// its worker in MiningWorkers.
+	MiningWorker    *mining.MultiWorker
+	MiningWorkers   []*mining.DefaultWorker
 	MiningScheduler mining.Scheduler
 	Mining          struct {
 		sync.Mutex
@@ -8,9 +8,9 @@ import (
 
 // BlockServiceSubmodule enhances the `Node` with networked key/value fetching capabilities.
 //
-// TODO: split chain data from piece data (issue: https://github.com/filecoin-project/go-filecoin/issues/3481)
-// Note: at present:
-// - `BlockService` is shared by chain/graphsync and piece/bitswap data
+// The block service stores and exchanges client and piece data. It reads
+// chain data from the local chain blockstore, but never fetches or writes it:
+// chain data is exchanged by the chain graphsync exchange.
 type BlockServiceSubmodule struct {
 	// Blockservice is a higher level interface for fetching data
 	Blockservice bserv.BlockService
@@ -18,7 +18,8 @@ type BlockServiceSubmodule struct {
 
 // NewBlockserviceSubmodule creates a new block service submodule.
 func NewBlockserviceSubmodule(ctx context.Context, blockstore *BlockstoreSubmodule, network *NetworkSubmodule) (BlockServiceSubmodule, error) {
-	bservice := bserv.New(blockstore.Blockstore, network.Bitswap)
+	bs := &readFallbackBlockstore{Blockstore: blockstore.PieceBlockstore, fallback: blockstore.Blockstore}
+	bservice := bserv.New(bs, network.PieceBitswap)
 
 	return BlockServiceSubmodule{
 		Blockservice: bservice,
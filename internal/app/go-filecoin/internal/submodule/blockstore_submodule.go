@@ -3,39 +3,86 @@ package submodule
 import (
 	"context"
 
+	blocks "github.com/ipfs/go-block-format"
+	"github.com/ipfs/go-cid"
 	ds "github.com/ipfs/go-datastore"
 	bstore "github.com/ipfs/go-ipfs-blockstore"
 
 	"github.com/filecoin-project/go-filecoin/internal/pkg/cborutil"
+	"github.com/filecoin-project/go-filecoin/internal/pkg/gc"
+	"github.com/filecoin-project/go-filecoin/internal/pkg/repo"
 )
 
 // BlockstoreSubmodule enhances the `Node` with local key/value storing capabilities.
 //
-// TODO: split chain data from piece data (issue: https://github.com/filecoin-project/go-filecoin/issues/3481)
-// Note: at present:
-// - `Blockstore` is shared by chain/graphsync and piece/bitswap data
-// - `cborStore` is used for chain state and shared with piece data exchange for deals at the moment.
+// Chain data and piece data are kept in separate blockstores, backed by
+// separate repo datastores.
 type BlockstoreSubmodule struct {
-	// Blockstore is the un-networked blocks interface
+	// Blockstore is the un-networked blocks interface for chain headers, messages and state.
 	Blockstore bstore.Blockstore
 
+	// PieceBlockstore is the un-networked blocks interface for client and piece DAGs.
+	PieceBlockstore bstore.Blockstore
+
+	// Collectable is Blockstore, for garbage collection.
+	Collectable *gc.Blockstore
+
+	// Pins are the roots kept by garbage collection.
+	Pins *gc.Pins
+
 	// cborStore is a wrapper for a `cbor.IpldStore` that works on the local IPLD-Cbor objects stored in `Blockstore`.
+	// It is only used for chain data.
 	CborStore *cborutil.IpldStore
 }
 
 type blockstoreRepo interface {
 	Datastore() ds.Batching
+	PieceDatastore() repo.Datastore
 }
 
 // NewBlockstoreSubmodule creates a new block store submodule.
 func NewBlockstoreSubmodule(ctx context.Context, repo blockstoreRepo) (BlockstoreSubmodule, error) {
 	// set up block store
-	bs := bstore.NewBlockstore(repo.Datastore())
+	bs := gc.NewBlockstore(bstore.NewBlockstore(repo.Datastore()))
 	// setup a ipldCbor on top of the local store
 	ipldCborStore := cborutil.NewIpldStore(bs)
 
 	return BlockstoreSubmodule{
-		Blockstore: bs,
-		CborStore:  ipldCborStore,
+		Blockstore:      bs,
+		PieceBlockstore: bstore.NewBlockstore(repo.PieceDatastore()),
+		Collectable:     bs,
+		Pins:            gc.NewPins(repo.Datastore()),
+		CborStore:       ipldCborStore,
 	}, nil
 }
+
+// readFallbackBlockstore is a blockstore falling back to another blockstore
+// for reads. Writes only go to the first one.
+type readFallbackBlockstore struct {
+	bstore.Blockstore
+	fallback bstore.Blockstore
+}
+
+func (bs *readFallbackBlockstore) Has(c cid.Cid) (bool, error) {
+	has, err := bs.Blockstore.Has(c)
+	if err != nil || has {
+		return has, err
+	}
+	return bs.fallback.Has(c)
+}
+
+func (bs *readFallbackBlockstore) Get(c cid.Cid) (blocks.Block, error) {
+	blk, err := bs.Blockstore.Get(c)
+	if err == bstore.ErrNotFound {
+		return bs.fallback.Get(c)
+	}
+	return blk, err
+}
+
+func (bs *readFallbackBlockstore) GetSize(c cid.Cid) (int, error) {
+	size, err := bs.Blockstore.GetSize(c)
+	if err == bstore.ErrNotFound {
+		return bs.fallback.GetSize(c)
+	}
+	return size, err
+}
@@ -6,10 +6,13 @@ import (
 	"github.com/ipfs/go-cid"
 
 	"github.com/filecoin-project/go-filecoin/internal/app/go-filecoin/plumbing/cst"
+	"github.com/filecoin-project/go-filecoin/internal/pkg/block"
 	"github.com/filecoin-project/go-filecoin/internal/pkg/chain"
+	"github.com/filecoin-project/go-filecoin/internal/pkg/config"
 	"github.com/filecoin-project/go-filecoin/internal/pkg/consensus"
 	"github.com/filecoin-project/go-filecoin/internal/pkg/repo"
 	appstate "github.com/filecoin-project/go-filecoin/internal/pkg/state"
+	"github.com/filecoin-project/go-filecoin/internal/pkg/vm"
 	"github.com/filecoin-project/go-filecoin/internal/pkg/vm/actor/builtin"
 	"github.com/filecoin-project/go-filecoin/internal/pkg/vmsupport"
 )
@@ -26,6 +29,8 @@ type ChainSubmodule struct {
 	Sampler    *chain.Sampler
 	ActorState *appstate.TipSetStateViewer
 	Processor  *consensus.DefaultProcessor
+	// Pricelists selects the gas prices charged at each epoch.
+	Pricelists *vm.PricelistSchedule
 
 	StatusReporter *chain.StatusReporter
 }
@@ -45,23 +50,27 @@ type ChainSubmodule struct {
 */
 type chainRepo interface {
 	ChainDatastore() repo.Datastore
+	Config() *config.Config
 }
 
 type chainConfig interface {
 	GenesisCid() cid.Cid
 }
 
-// NewChainSubmodule creates a new chain submodule.
-func NewChainSubmodule(config chainConfig, repo chainRepo, blockstore *BlockstoreSubmodule, verifier *ProofVerificationSubmodule) (ChainSubmodule, error) {
+// NewChainSubmodule creates a new chain submodule, running the vm with the gas
+// pricelists `pricelists`.
+func NewChainSubmodule(config chainConfig, repo chainRepo, blockstore *BlockstoreSubmodule, verifier *ProofVerificationSubmodule, pricelists *vm.PricelistSchedule) (ChainSubmodule, error) {
 	// initialize chain store
 	chainStatusReporter := chain.NewStatusReporter()
 	chainStore := chain.NewStore(repo.ChainDatastore(), blockstore.CborStore, chainStatusReporter, config.GenesisCid())
+	chainStore.SetCheckpoint(block.NewTipSetKey(repo.Config().Chain.Checkpoint...))
 
 	actorState := appstate.NewTipSetStateViewer(chainStore, blockstore.CborStore)
 	messageStore := chain.NewMessageStore(blockstore.Blockstore)
+	chainStore.SetMessageIndex(chain.NewMessageIndex(repo.ChainDatastore(), messageStore))
 	chainState := cst.NewChainStateReadWriter(chainStore, messageStore, blockstore.Blockstore, builtin.DefaultActors)
 	syscalls := vmsupport.NewSyscalls(verifier.ProofVerifier)
-	processor := consensus.NewDefaultProcessor(syscalls, chainState)
+	processor := consensus.NewDefaultProcessor(syscalls, chainState, pricelists)
 
 	return ChainSubmodule{
 		ChainReader:  chainStore,
@@ -70,6 +79,7 @@ func NewChainSubmodule(config chainConfig, repo chainRepo, blockstore *Blockstor
 		ActorState:     actorState,
 		State:          chainState,
 		Processor:      processor,
+		Pricelists:     pricelists,
 		StatusReporter: chainStatusReporter,
 	}, nil
 }
@@ -78,7 +88,17 @@ type chainNode interface {
 	Chain() ChainSubmodule
 }
 
-// Start loads the chain from disk.
+// Start loads the chain from disk and brings the message index up to date in
+// the background, building it on the first start after an upgrade.
 func (c *ChainSubmodule) Start(ctx context.Context, node chainNode) error {
-	return node.Chain().ChainReader.Load(ctx)
+	store := node.Chain().ChainReader
+	if err := store.Load(ctx); err != nil {
+		return err
+	}
+	go func() {
+		if err := store.BuildMessageIndex(context.Background()); err != nil {
+			log.Errorf("failed to build message index: %s", err)
+		}
+	}()
+	return nil
 }
@@ -5,12 +5,14 @@ import (
 	"time"
 
 	"github.com/filecoin-project/go-filecoin/internal/pkg/block"
+	"github.com/filecoin-project/go-filecoin/internal/pkg/clock"
 	"github.com/filecoin-project/go-filecoin/internal/pkg/config"
 	"github.com/filecoin-project/go-filecoin/internal/pkg/discovery"
 	"github.com/filecoin-project/go-filecoin/internal/pkg/net"
 	"github.com/filecoin-project/go-filecoin/internal/pkg/util/moresync"
 	"github.com/ipfs/go-cid"
 	logging "github.com/ipfs/go-log"
+	"github.com/libp2p/go-libp2p-core/peer"
 	"github.com/pkg/errors"
 )
 
@@ -24,6 +26,9 @@ type DiscoverySubmodule struct {
 	// PeerTracker maintains a list of peers.
 	PeerTracker *discovery.PeerTracker
 
+	// PeerScorer scores peers by their sync behaviour and bans misbehaving ones.
+	PeerScorer *discovery.PeerScorer
+
 	// HelloHandler handle peer connections for the "hello" protocol.
 	HelloHandler *discovery.HelloProtocolHandler
 }
@@ -33,7 +38,7 @@ type discoveryConfig interface {
 }
 
 // NewDiscoverySubmodule creates a new discovery submodule.
-func NewDiscoverySubmodule(ctx context.Context, config discoveryConfig, bsConfig *config.BootstrapConfig, network *NetworkSubmodule) (DiscoverySubmodule, error) {
+func NewDiscoverySubmodule(ctx context.Context, config discoveryConfig, bsConfig *config.BootstrapConfig, swarmConfig *config.SwarmConfig, network *NetworkSubmodule) (DiscoverySubmodule, error) {
 	periodStr := bsConfig.Period
 	period, err := time.ParseDuration(periodStr)
 	if err != nil {
@@ -52,14 +57,26 @@ func NewDiscoverySubmodule(ctx context.Context, config discoveryConfig, bsConfig
 	// create a bootstrapper
 	bootstrapper := discovery.NewBootstrapper(bpi, network.Host, network.Host.Network(), network.Router, minPeerThreshold, period)
 
-	// set up peer tracking
-	peerTracker := discovery.NewPeerTracker(network.Host.ID())
+	banDuration, err := time.ParseDuration(swarmConfig.BanDuration)
+	if err != nil {
+		return DiscoverySubmodule{}, errors.Wrapf(err, "couldn't parse ban duration %s", swarmConfig.BanDuration)
+	}
+
+	// set up peer scoring and tracking
+	disconnect := func(p peer.ID) {
+		if err := network.Host.Network().ClosePeer(p); err != nil {
+			log.Errorf("failed to disconnect from banned peer %s: %s", p, err)
+		}
+	}
+	peerScorer := discovery.NewPeerScorer(network.Host.ID(), clock.NewSystemClock(), swarmConfig.BanThreshold, banDuration, disconnect)
+	peerTracker := discovery.NewPeerTracker(network.Host.ID(), peerScorer)
 
 	return DiscoverySubmodule{
 		Bootstrapper:   bootstrapper,
 		BootstrapReady: moresync.NewLatch(uint(minPeerThreshold)),
 		PeerTracker:    peerTracker,
-		HelloHandler:   discovery.NewHelloProtocolHandler(network.Host, config.GenesisCid(), network.NetworkName),
+		PeerScorer:     peerScorer,
+		HelloHandler:   discovery.NewHelloProtocolHandler(network.Host, config.GenesisCid(), network.NetworkName, peerScorer),
 	}, nil
 }
 
@@ -78,6 +95,9 @@ func (m *DiscoverySubmodule) Start(node discoveryNode) error {
 	// Register peer tracker disconnect function with network.
 	m.PeerTracker.RegisterDisconnect(node.Network().Host.Network())
 
+	// Refuse connections from banned peers on every protocol.
+	m.PeerScorer.RegisterConnect(node.Network().Host.Network())
+
 	// Start up 'hello' handshake service
 	peerDiscoveredCallback := func(ci *block.ChainInfo) {
 		m.PeerTracker.Track(ci)
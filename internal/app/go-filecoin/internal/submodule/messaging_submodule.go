@@ -3,6 +3,9 @@ package submodule
 import (
 	"context"
 
+	ds "github.com/ipfs/go-datastore"
+	"github.com/pkg/errors"
+
 	"github.com/filecoin-project/go-filecoin/internal/pkg/config"
 	"github.com/filecoin-project/go-filecoin/internal/pkg/consensus"
 	"github.com/filecoin-project/go-filecoin/internal/pkg/journal"
@@ -32,6 +35,7 @@ type messagingConfig interface {
 
 type messagingRepo interface {
 	Config() *config.Config
+	Datastore() ds.Batching
 }
 
 // NewMessagingSubmodule creates a new discovery submodule.
@@ -45,8 +49,14 @@ func NewMessagingSubmodule(ctx context.Context, config messagingConfig, repo mes
 		return MessagingSubmodule{}, err
 	}
 
-	msgQueue := message.NewQueue()
-	outboxPolicy := message.NewMessageQueuePolicy(chain.MessageStore, message.OutboxMaxAgeRounds)
+	msgQueue, err := message.NewPersistentQueue(repo.Datastore())
+	if err != nil {
+		return MessagingSubmodule{}, errors.Wrap(err, "failed to load outbound message queue")
+	}
+	outboxPolicy, err := message.NewPersistentMessageQueuePolicy(chain.MessageStore, message.OutboxMaxAgeRounds, repo.Datastore())
+	if err != nil {
+		return MessagingSubmodule{}, errors.Wrap(err, "failed to load outbound message signatures")
+	}
 	msgPublisher := message.NewDefaultPublisher(pubsub.NewTopic(topic), msgPool)
 	signer := wallet.Wallet
 	outbox := message.NewOutbox(signer, consensus.NewOutboundMessageValidator(), msgQueue, msgPublisher, outboxPolicy, chain.ChainReader, chain.State, config.Journal().Topic("outbox"))
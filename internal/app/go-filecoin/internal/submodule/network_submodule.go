@@ -48,12 +48,23 @@ type NetworkSubmodule struct {
 
 	pubsub *libp2pps.PubSub
 
-	// TODO: split chain bitswap from storage bitswap (issue: ???)
+	// Bitswap exchanges chain data. It also serves client and piece data,
+	// read-only, to peers still using the unprefixed protocols.
 	Bitswap exchange.Interface
 
+	// PieceBitswap exchanges client and piece data, on protocols prefixed
+	// with net.PieceProtocolPrefix.
+	PieceBitswap exchange.Interface
+
 	Network *net.Network
 
+	// GraphExchange exchanges chain data. It also serves client and piece
+	// data, read-only, to peers still using the unprefixed protocols.
 	GraphExchange graphsync.GraphExchange
+
+	// PieceGraphExchange exchanges client and piece data, on protocols
+	// prefixed with net.PieceProtocolPrefix.
+	PieceGraphExchange graphsync.GraphExchange
 }
 
 type blankValidator struct{}
@@ -127,33 +138,45 @@ func NewNetworkSubmodule(ctx context.Context, config networkConfig, repo network
 		return NetworkSubmodule{}, errors.Wrap(err, "failed to set up network")
 	}
 
-	// set up bitswap
+	// The chain exchanges keep the original protocol IDs. Peers that predate
+	// the split request client and piece data on these too, so the chain
+	// exchanges serve it from the piece blockstore without ever storing it.
+	legacyBs := &readFallbackBlockstore{Blockstore: blockstore.Blockstore, fallback: blockstore.PieceBlockstore}
+
+	// set up bitswap, separately for chain and piece data
 	nwork := bsnet.NewFromIpfsHost(peerHost, router)
 	//nwork := bsnet.NewFromIpfsHost(innerHost, router)
-	bswap := bitswap.New(ctx, nwork, blockstore.Blockstore)
+	bswap := bitswap.New(ctx, nwork, legacyBs)
+	pieceHost := net.NewPrefixHost(peerHost, net.PieceProtocolPrefix)
+	pieceBswap := bitswap.New(ctx, bsnet.NewFromIpfsHost(pieceHost, router), blockstore.PieceBlockstore)
 
 	// set up pinger
 	pingService := ping.NewPingService(peerHost)
 
-	// set up graphsync
+	// set up graphsync, separately for chain and piece data
 	graphsyncNetwork := gsnet.NewFromLibp2pHost(peerHost)
 	bridge := ipldbridge.NewIPLDBridge()
-	loader := gsstoreutil.LoaderForBlockstore(blockstore.Blockstore)
+	loader := gsstoreutil.LoaderForBlockstore(legacyBs)
 	storer := gsstoreutil.StorerForBlockstore(blockstore.Blockstore)
 	gsync := graphsyncimpl.New(ctx, graphsyncNetwork, bridge, loader, storer)
+	pieceLoader := gsstoreutil.LoaderForBlockstore(blockstore.PieceBlockstore)
+	pieceStorer := gsstoreutil.StorerForBlockstore(blockstore.PieceBlockstore)
+	pieceGsync := graphsyncimpl.New(ctx, gsnet.NewFromLibp2pHost(pieceHost), bridge, pieceLoader, pieceStorer)
 
 	// build network
 	network := net.New(peerHost, net.NewRouter(router), bandwidthTracker, net.NewPinger(peerHost, pingService))
 
 	// build the network submdule
 	return NetworkSubmodule{
-		NetworkName:   networkName,
-		Host:          peerHost,
-		Router:        router,
-		pubsub:        gsub,
-		Bitswap:       bswap,
-		GraphExchange: gsync,
-		Network:       network,
+		NetworkName:        networkName,
+		Host:               peerHost,
+		Router:             router,
+		pubsub:             gsub,
+		Bitswap:            bswap,
+		PieceBitswap:       pieceBswap,
+		GraphExchange:      gsync,
+		PieceGraphExchange: pieceGsync,
+		Network:            network,
 	}, nil
 }
 
@@ -0,0 +1,22 @@
+package submodule
+
+import (
+	"context"
+
+	"github.com/ipfs/go-datastore"
+
+	"github.com/filecoin-project/go-filecoin/internal/app/go-filecoin/paymentchannel"
+	"github.com/filecoin-project/go-filecoin/internal/app/go-filecoin/plumbing/msg"
+)
+
+// PaymentChannelSubmodule adds payment channel management to the node.
+type PaymentChannelSubmodule struct {
+	Manager *paymentchannel.Manager
+}
+
+// NewPaymentChannelSubmodule creates a new payment channel submodule.
+func NewPaymentChannelSubmodule(ctx context.Context, ds datastore.Batching, chain *ChainSubmodule, messaging *MessagingSubmodule, waiter *msg.Waiter) PaymentChannelSubmodule {
+	return PaymentChannelSubmodule{
+		Manager: paymentchannel.NewManager(ctx, paymentchannel.NewStore(ds), chain.State, messaging.Outbox, waiter),
+	}
+}
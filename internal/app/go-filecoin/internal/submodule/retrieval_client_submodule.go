@@ -0,0 +1,42 @@
+package submodule
+
+import (
+	"context"
+
+	iface "github.com/filecoin-project/go-fil-markets/retrievalmarket"
+	impl "github.com/filecoin-project/go-fil-markets/retrievalmarket/impl"
+	"github.com/filecoin-project/go-fil-markets/retrievalmarket/network"
+	blockstore "github.com/ipfs/go-ipfs-blockstore"
+	"github.com/libp2p/go-libp2p-core/host"
+
+	retmkt "github.com/filecoin-project/go-filecoin/internal/app/go-filecoin/retrieval_market_connector"
+	"github.com/filecoin-project/go-filecoin/internal/pkg/clock"
+)
+
+// RetrievalClientSubmodule enhances the node with the ability to retrieve
+// pieces from retrieval miners.
+type RetrievalClientSubmodule struct {
+	Client iface.RetrievalClient
+}
+
+// NewRetrievalClientSubmodule creates a new retrieval client submodule. Peers
+// are resolved from on-chain deals, and from the DHT if a provider finder and a
+// payload finder are given.
+func NewRetrievalClientSubmodule(
+	ctx context.Context,
+	bs blockstore.Blockstore,
+	host host.Host,
+	chain *ChainSubmodule,
+	finder retmkt.ProviderFinder,
+	payloads retmkt.PayloadFinder,
+	signer retmkt.RetrievalSigner,
+	pchMgrAPI retmkt.PaychMgrAPI,
+) *RetrievalClientSubmodule {
+	netwk := network.NewFromLibp2pHost(host)
+	cnode := retmkt.NewRetrievalClientNodeConnector(pchMgrAPI, signer)
+	resolver := retmkt.NewRetrievalPeerResolverConnector(ctx, chain.State, finder, payloads, clock.NewSystemClock())
+
+	return &RetrievalClientSubmodule{
+		Client: impl.NewClient(netwk, bs, cnode, resolver),
+	}
+}
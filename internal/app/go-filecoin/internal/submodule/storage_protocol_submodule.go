@@ -15,14 +15,12 @@ import (
 	smnetwork "github.com/filecoin-project/go-fil-markets/storagemarket/network"
 	"github.com/filecoin-project/specs-actors/actors/abi"
 	"github.com/ipfs/go-datastore"
-	blockstore "github.com/ipfs/go-ipfs-blockstore"
 	"github.com/libp2p/go-libp2p-core/host"
 	"github.com/pkg/errors"
 
 	"github.com/filecoin-project/go-filecoin/internal/app/go-filecoin/paths"
 	"github.com/filecoin-project/go-filecoin/internal/app/go-filecoin/plumbing/msg"
 	storagemarketconnector "github.com/filecoin-project/go-filecoin/internal/app/go-filecoin/storage_market_connector"
-	"github.com/filecoin-project/go-filecoin/internal/pkg/cborutil"
 	"github.com/filecoin-project/go-filecoin/internal/pkg/piecemanager"
 	"github.com/filecoin-project/go-filecoin/internal/pkg/wallet"
 )
@@ -46,7 +44,7 @@ func NewStorageProtocolSubmodule(
 	wlt *wallet.Wallet,
 	h host.Host,
 	ds datastore.Batching,
-	bs blockstore.Blockstore,
+	bs *BlockstoreSubmodule,
 	gsync graphsync.GraphExchange,
 	repoPath string,
 	sealProofType abi.RegisteredProof,
@@ -54,7 +52,7 @@ func NewStorageProtocolSubmodule(
 ) (*StorageProtocolSubmodule, error) {
 
 	pnode := storagemarketconnector.NewStorageProviderNodeConnector(minerAddr, c.State, m.Outbox, mw, pm, wlt)
-	cnode := storagemarketconnector.NewStorageClientNodeConnector(cborutil.NewIpldStore(bs), c.State, mw, wlt, m.Outbox, clientAddr)
+	cnode := storagemarketconnector.NewStorageClientNodeConnector(bs.CborStore, c.State, mw, wlt, m.Outbox, clientAddr)
 
 	pieceStagingPath, err := paths.PieceStagingDir(repoPath)
 	if err != nil {
@@ -74,13 +72,13 @@ func NewStorageProtocolSubmodule(
 
 	dt := graphsyncimpl.NewGraphSyncDataTransfer(h, gsync)
 
-	provider, err := impl.NewProvider(smnetwork.NewFromLibp2pHost(h), ds, bs, fs, piecestore.NewPieceStore(ds), dt, pnode, minerAddr, sealProofType)
+	provider, err := impl.NewProvider(smnetwork.NewFromLibp2pHost(h), ds, bs.PieceBlockstore, fs, piecestore.NewPieceStore(ds), dt, pnode, minerAddr, sealProofType)
 	if err != nil {
 		return nil, errors.Wrap(err, "error creating graphsync provider")
 	}
 
 	return &StorageProtocolSubmodule{
-		StorageClient:   impl.NewClient(smnetwork.NewFromLibp2pHost(h), bs, dt, nil, nil, cnode),
+		StorageClient:   impl.NewClient(smnetwork.NewFromLibp2pHost(h), bs.PieceBlockstore, dt, nil, nil, cnode),
 		StorageProvider: provider,
 	}, nil
 }
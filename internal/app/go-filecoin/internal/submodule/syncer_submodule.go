@@ -10,11 +10,14 @@ import (
 
 	"github.com/filecoin-project/go-filecoin/internal/pkg/block"
 	"github.com/filecoin-project/go-filecoin/internal/pkg/chainsync"
+	"github.com/filecoin-project/go-filecoin/internal/pkg/chainsync/exchange"
 	"github.com/filecoin-project/go-filecoin/internal/pkg/chainsync/fetcher"
 	"github.com/filecoin-project/go-filecoin/internal/pkg/clock"
+	"github.com/filecoin-project/go-filecoin/internal/pkg/config"
 	"github.com/filecoin-project/go-filecoin/internal/pkg/consensus"
 	"github.com/filecoin-project/go-filecoin/internal/pkg/net"
 	"github.com/filecoin-project/go-filecoin/internal/pkg/net/pubsub"
+	"github.com/filecoin-project/go-filecoin/internal/pkg/repo"
 	"github.com/filecoin-project/go-filecoin/internal/pkg/slashing"
 	"github.com/filecoin-project/go-filecoin/internal/pkg/state"
 )
@@ -27,9 +30,14 @@ type SyncerSubmodule struct {
 	Consensus        consensus.Protocol
 	FaultDetector    slashing.ConsensusFaultDetector
 	ChainSyncManager *chainsync.Manager
+	// HeaderRangeHandler serves ranges of chain headers to syncing peers.
+	HeaderRangeHandler *exchange.HeaderRangeHandler
 
 	// cancelChainSync cancels the context for chain sync subscriptions and handlers.
 	CancelChainSync context.CancelFunc
+	// Slasher reports detected consensus faults. Faults are only logged
+	// when it is nil.
+	Slasher *slashing.Slasher
 	// faultCh receives detected consensus faults
 	faultCh chan slashing.ConsensusFault
 }
@@ -40,20 +48,25 @@ type syncerConfig interface {
 	ChainClock() clock.ChainEpochClock
 }
 
+type syncerRepo interface {
+	ChainDatastore() repo.Datastore
+	Config() *config.Config
+}
+
 type nodeChainSelector interface {
 	Weight(context.Context, block.TipSet, cid.Cid) (fbig.Int, error)
 	IsHeavier(ctx context.Context, a, b block.TipSet, aStateID, bStateID cid.Cid) (bool, error)
 }
 
 // NewSyncerSubmodule creates a new chain submodule.
-func NewSyncerSubmodule(ctx context.Context, config syncerConfig, blockstore *BlockstoreSubmodule, network *NetworkSubmodule,
+func NewSyncerSubmodule(ctx context.Context, config syncerConfig, repo syncerRepo, blockstore *BlockstoreSubmodule, network *NetworkSubmodule,
 	discovery *DiscoverySubmodule, chn *ChainSubmodule, postVerifier consensus.EPoStVerifier) (SyncerSubmodule, error) {
 	// setup block validation
 	// TODO when #2961 is resolved do the needful here.
 	blkValid := consensus.NewDefaultBlockValidator(config.ChainClock())
 
 	// register block validation on floodsub
-	btv := net.NewBlockTopicValidator(blkValid)
+	btv := net.NewBlockTopicValidator(blkValid, discovery.PeerScorer)
 	if err := network.pubsub.RegisterTopicValidator(btv.Topic(network.NetworkName), btv.Validator(), btv.Opts()...); err != nil {
 		return SyncerSubmodule{}, errors.Wrap(err, "failed to register block validator")
 	}
@@ -73,11 +86,12 @@ func NewSyncerSubmodule(ctx context.Context, config syncerConfig, blockstore *Bl
 	nodeChainSelector := consensus.NewChainSelector(blockstore.CborStore, &stateViewer, config.GenesisCid())
 
 	// setup fecher
-	fetcher := fetcher.NewGraphSyncFetcher(ctx, network.GraphExchange, blockstore.Blockstore, blkValid, config.ChainClock(), discovery.PeerTracker)
+	fetcher := fetcher.NewGraphSyncFetcher(ctx, network.GraphExchange, blockstore.Blockstore, blkValid, config.ChainClock(), discovery.PeerTracker, discovery.PeerScorer, exchange.NewHeaderRangeClient(network.Host))
 	faultCh := make(chan slashing.ConsensusFault)
 	faultDetector := slashing.NewConsensusFaultDetector(faultCh)
 
-	chainSyncManager, err := chainsync.NewManager(nodeConsensus, blkValid, nodeChainSelector, chn.ChainReader, chn.MessageStore, fetcher, config.ChainClock(), faultDetector)
+	chainSyncManager, err := chainsync.NewManager(nodeConsensus, blkValid, nodeChainSelector, chn.ChainReader, chn.MessageStore, fetcher, config.ChainClock(), faultDetector,
+		repo.ChainDatastore(), repo.Config().Chain.BadTipSetCacheSize, discovery.PeerScorer)
 	if err != nil {
 		return SyncerSubmodule{}, err
 	}
@@ -85,9 +99,10 @@ func NewSyncerSubmodule(ctx context.Context, config syncerConfig, blockstore *Bl
 	return SyncerSubmodule{
 		BlockTopic: pubsub.NewTopic(topic),
 		// BlockSub: nil,
-		Consensus:        nodeConsensus,
-		ChainSelector:    nodeChainSelector,
-		ChainSyncManager: &chainSyncManager,
+		Consensus:          nodeConsensus,
+		ChainSelector:      nodeChainSelector,
+		ChainSyncManager:   &chainSyncManager,
+		HeaderRangeHandler: exchange.NewHeaderRangeHandler(network.Host, chn.ChainReader),
 		// cancelChainSync: nil,
 		faultCh: faultCh,
 	}, nil
@@ -98,16 +113,20 @@ type syncerNode interface {
 
 // Start starts the syncer submodule for a node.
 func (s *SyncerSubmodule) Start(ctx context.Context, _node syncerNode) error {
-	go func() {
-		for {
-			select {
-			case <-ctx.Done():
-				return
-			case <-s.faultCh:
-				// TODO #3690 connect this up to a slasher that sends messages
-				// to outbound queue to carry out penalization
+	s.HeaderRangeHandler.Register()
+	if s.Slasher != nil {
+		go s.Slasher.Run(ctx, s.faultCh)
+	} else {
+		go func() {
+			for {
+				select {
+				case <-ctx.Done():
+					return
+				case fault := <-s.faultCh:
+					log.Warnf("detected %s consensus fault of %s, not reported: no slashing.reporterAddress is configured", fault.Type, fault.Block1.Miner)
+				}
 			}
-		}
-	}()
+		}()
+	}
 	return s.ChainSyncManager.Start(ctx)
 }
@@ -3,6 +3,8 @@ package submodule
 import (
 	"context"
 
+	"github.com/filecoin-project/go-filecoin/internal/pkg/clock"
+	"github.com/filecoin-project/go-filecoin/internal/pkg/config"
 	"github.com/filecoin-project/go-filecoin/internal/pkg/repo"
 	"github.com/filecoin-project/go-filecoin/internal/pkg/wallet"
 	"github.com/pkg/errors"
@@ -14,16 +16,38 @@ type WalletSubmodule struct {
 }
 
 type walletRepo interface {
+	Config() *config.Config
 	WalletDatastore() repo.Datastore
 }
 
 // NewWalletSubmodule creates a new storage protocol submodule.
-func NewWalletSubmodule(ctx context.Context, repo walletRepo) (WalletSubmodule, error) {
-	backend, err := wallet.NewDSBackend(repo.WalletDatastore())
+// An encrypted wallet datastore is opened locked. If a remote signer is
+// configured its addresses are added to the wallet.
+func NewWalletSubmodule(ctx context.Context, repo walletRepo, clk clock.Clock) (WalletSubmodule, error) {
+	encrypted, err := wallet.IsEncrypted(repo.WalletDatastore())
+	if err != nil {
+		return WalletSubmodule{}, errors.Wrap(err, "failed to read wallet datastore")
+	}
+
+	var backend wallet.Backend
+	if encrypted {
+		backend, err = wallet.NewEncryptedBackend(repo.WalletDatastore(), clk)
+	} else {
+		backend, err = wallet.NewDSBackend(repo.WalletDatastore())
+	}
 	if err != nil {
 		return WalletSubmodule{}, errors.Wrap(err, "failed to set up wallet backend")
 	}
-	fcWallet := wallet.New(backend)
+	backends := []wallet.Backend{backend}
+
+	if target := repo.Config().Wallet.RemoteSigner; target != "" {
+		remote, err := wallet.NewRemoteBackend(target)
+		if err != nil {
+			return WalletSubmodule{}, errors.Wrap(err, "failed to set up remote signer")
+		}
+		backends = append(backends, remote)
+	}
+	fcWallet := wallet.New(backends...)
 
 	return WalletSubmodule{
 		Wallet: fcWallet,
@@ -15,13 +15,17 @@ import (
 	"github.com/filecoin-project/go-filecoin/internal/app/go-filecoin/plumbing/cfg"
 	"github.com/filecoin-project/go-filecoin/internal/app/go-filecoin/plumbing/cst"
 	"github.com/filecoin-project/go-filecoin/internal/app/go-filecoin/plumbing/dag"
+	"github.com/filecoin-project/go-filecoin/internal/app/go-filecoin/plumbing/events"
 	"github.com/filecoin-project/go-filecoin/internal/app/go-filecoin/plumbing/msg"
 	"github.com/filecoin-project/go-filecoin/internal/app/go-filecoin/porcelain"
+	retmkt "github.com/filecoin-project/go-filecoin/internal/app/go-filecoin/retrieval_market_connector"
 	"github.com/filecoin-project/go-filecoin/internal/pkg/clock"
 	"github.com/filecoin-project/go-filecoin/internal/pkg/journal"
 	"github.com/filecoin-project/go-filecoin/internal/pkg/postgenerator"
 	"github.com/filecoin-project/go-filecoin/internal/pkg/repo"
+	"github.com/filecoin-project/go-filecoin/internal/pkg/slashing"
 	"github.com/filecoin-project/go-filecoin/internal/pkg/version"
+	"github.com/filecoin-project/go-filecoin/internal/pkg/vm"
 )
 
 // Builder is a helper to aid in the construction of a filecoin node.
@@ -166,7 +170,7 @@ func (b *Builder) build(ctx context.Context) (*Node, error) {
 		return nil, errors.Wrap(err, "failed to build node.Network")
 	}
 
-	nd.Discovery, err = submodule.NewDiscoverySubmodule(ctx, (*builder)(b), b.repo.Config().Bootstrap, &nd.network)
+	nd.Discovery, err = submodule.NewDiscoverySubmodule(ctx, (*builder)(b), b.repo.Config().Bootstrap, b.repo.Config().Swarm, &nd.network)
 	if err != nil {
 		return nil, errors.Wrap(err, "failed to build node.Discovery")
 	}
@@ -183,7 +187,12 @@ func (b *Builder) build(ctx context.Context) (*Node, error) {
 
 	nd.ProofVerification = submodule.NewProofVerificationSubmodule(b.verifier)
 
-	nd.chain, err = submodule.NewChainSubmodule((*builder)(b), b.repo, &nd.Blockstore, &nd.ProofVerification)
+	pricelists, err := vm.NewPricelistSchedule(nd.VersionTable, nd.network.NetworkName)
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to load gas pricelists")
+	}
+
+	nd.chain, err = submodule.NewChainSubmodule((*builder)(b), b.repo, &nd.Blockstore, &nd.ProofVerification, pricelists)
 	if err != nil {
 		return nil, errors.Wrap(err, "failed to build node.Chain")
 	}
@@ -198,12 +207,12 @@ func (b *Builder) build(ctx context.Context) (*Node, error) {
 	}
 	nd.ChainClock = b.chainClock
 
-	nd.syncer, err = submodule.NewSyncerSubmodule(ctx, (*builder)(b), &nd.Blockstore, &nd.network, &nd.Discovery, &nd.chain, nd.ProofVerification.ProofVerifier)
+	nd.syncer, err = submodule.NewSyncerSubmodule(ctx, (*builder)(b), b.repo, &nd.Blockstore, &nd.network, &nd.Discovery, &nd.chain, nd.ProofVerification.ProofVerifier)
 	if err != nil {
 		return nil, errors.Wrap(err, "failed to build node.Syncer")
 	}
 
-	nd.Wallet, err = submodule.NewWalletSubmodule(ctx, b.repo)
+	nd.Wallet, err = submodule.NewWalletSubmodule(ctx, b.repo, clock.NewSystemClock())
 	if err != nil {
 		return nil, errors.Wrap(err, "failed to build node.Wallet")
 	}
@@ -213,6 +222,14 @@ func (b *Builder) build(ctx context.Context) (*Node, error) {
 		return nil, errors.Wrap(err, "failed to build node.Messaging")
 	}
 
+	previewer := msg.NewPreviewer(nd.chain.ChainReader, nd.Blockstore.CborStore, nd.Blockstore.Blockstore, nd.chain.Processor)
+	estimator := msg.NewEstimator(nd.chain.ChainReader, nd.chain.MessageStore, previewer, b.repo.Config().Message)
+	waiter := msg.NewWaiter(nd.chain.ChainReader, nd.chain.MessageStore, nd.Blockstore.Blockstore, nd.Blockstore.CborStore)
+
+	if reporter := b.repo.Config().Slashing.ReporterAddress; !reporter.Empty() {
+		nd.syncer.Slasher = slashing.NewSlasher(reporter, nd.Messaging.Outbox, estimator, nd.chain.ChainReader, waiter, b.repo.Datastore())
+	}
+
 	nd.StorageNetworking, err = submodule.NewStorgeNetworkingSubmodule(ctx, &nd.network)
 	if err != nil {
 		return nil, errors.Wrap(err, "failed to build node.StorageNetworking")
@@ -223,18 +240,41 @@ func (b *Builder) build(ctx context.Context) (*Node, error) {
 		return nil, errors.Wrap(err, "failed to build node.BlockMining")
 	}
 
+	nd.PaymentChannels = submodule.NewPaymentChannelSubmodule(ctx, b.repo.Datastore(), &nd.chain, &nd.Messaging, waiter)
+
+	// Offline nodes resolve retrieval peers from chain state alone. Online
+	// nodes also look up the providers of the payloads of the node's own
+	// storage deals in the DHT.
+	var providerFinder retmkt.ProviderFinder
+	if !b.offlineMode {
+		providerFinder = nd.network.Network.Router
+	}
+	payloads := retmkt.NewClientDealPayloads(func() retmkt.LocalDealLister {
+		if nd.StorageProtocol == nil {
+			return nil
+		}
+		return nd.StorageProtocol.StorageClient
+	})
+	nd.RetrievalClient = submodule.NewRetrievalClientSubmodule(ctx, nd.Blockstore.PieceBlockstore, nd.Host(), &nd.chain, providerFinder, payloads, nd.Wallet.Wallet, nd.PaymentChannels.Manager)
+
 	nd.PorcelainAPI = porcelain.New(plumbing.New(&plumbing.APIDeps{
 		Chain:        nd.chain.State,
 		Sync:         cst.NewChainSyncProvider(nd.syncer.ChainSyncManager),
 		Config:       cfg.NewConfig(b.repo),
 		DAG:          dag.NewDAG(merkledag.NewDAGService(nd.Blockservice.Blockservice)),
 		Expected:     nd.syncer.Consensus,
+		MsgEstimator: estimator,
 		MsgPool:      nd.Messaging.MsgPool,
-		MsgPreviewer: msg.NewPreviewer(nd.chain.ChainReader, nd.Blockstore.CborStore, nd.Blockstore.Blockstore, nd.chain.Processor),
-		MsgWaiter:    msg.NewWaiter(nd.chain.ChainReader, nd.chain.MessageStore, nd.Blockstore.Blockstore, nd.Blockstore.CborStore),
+		MsgPreviewer: previewer,
+		MsgTracer:    msg.NewTracer(nd.chain.ChainReader, nd.chain.MessageStore, nd.Blockstore.Blockstore, nd.chain.Processor),
+		MsgWaiter:    waiter,
 		Network:      nd.network.Network,
+		Notifier:     events.NewNotifier(nd.chain.ChainReader, nd.chain.MessageStore, nd.Blockstore.CborStore),
 		Outbox:       nd.Messaging.Outbox,
+		PeerScorer:   nd.Discovery.PeerScorer,
 		PieceManager: nd.PieceManager,
+		Pins:         nd.Blockstore.Pins,
+		Pricelists:   nd.chain.Pricelists,
 		Wallet:       nd.Wallet.Wallet,
 	}))
 
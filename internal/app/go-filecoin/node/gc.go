@@ -0,0 +1,63 @@
+package node
+
+import (
+	"context"
+	"time"
+
+	"github.com/filecoin-project/specs-actors/actors/abi"
+	bstore "github.com/ipfs/go-ipfs-blockstore"
+	"github.com/pkg/errors"
+
+	"github.com/filecoin-project/go-filecoin/internal/app/go-filecoin/internal/submodule"
+	"github.com/filecoin-project/go-filecoin/internal/pkg/cborutil"
+	"github.com/filecoin-project/go-filecoin/internal/pkg/chain"
+	"github.com/filecoin-project/go-filecoin/internal/pkg/clock"
+	"github.com/filecoin-project/go-filecoin/internal/pkg/gc"
+	"github.com/filecoin-project/go-filecoin/internal/pkg/repo"
+)
+
+// CollectGarbage garbage collects the blockstore of a repo that is not in use
+// by a running node.
+func CollectGarbage(ctx context.Context, r repo.Repo) (*gc.Result, error) {
+	genCid, err := readGenesisCid(r.Datastore())
+	if err != nil {
+		return nil, err
+	}
+
+	bs := gc.NewBlockstore(bstore.NewBlockstore(r.Datastore()))
+	chainStore := chain.NewStore(r.ChainDatastore(), cborutil.NewIpldStore(bs), chain.NewStatusReporter(), genCid)
+	if err := chainStore.Load(ctx); err != nil {
+		return nil, errors.Wrap(err, "failed to load chain")
+	}
+	defer chainStore.Stop()
+
+	wlt, err := submodule.NewWalletSubmodule(ctx, r, clock.NewSystemClock())
+	if err != nil {
+		return nil, err
+	}
+
+	retained := abi.ChainEpoch(r.Config().GC.RetainedEpochs)
+	collector := gc.NewCollector(bs, chainStore, chain.NewMessageStore(bs), wlt.Wallet, gc.NewPins(r.Datastore()), retained)
+	return collector.Collect(ctx)
+}
+
+// collectGarbagePeriodically garbage collects the node's blockstore every
+// period until the context is done.
+func (node *Node) collectGarbagePeriodically(ctx context.Context, period time.Duration) {
+	retained := abi.ChainEpoch(node.Repo.Config().GC.RetainedEpochs)
+	collector := gc.NewCollector(node.Blockstore.Collectable, node.chain.ChainReader, node.chain.MessageStore,
+		node.Wallet.Wallet, node.Blockstore.Pins, retained)
+
+	ticker := time.NewTicker(period)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			if _, err := collector.Collect(ctx); err != nil {
+				log.Errorf("garbage collection failed: %s", err)
+			}
+		}
+	}
+}
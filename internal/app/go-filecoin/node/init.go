@@ -23,6 +23,7 @@ type initCfg struct {
 	peerKey     acrypto.PrivKey
 	defaultKey  *crypto.KeyInfo
 	initImports []*crypto.KeyInfo
+	passphrase  string
 }
 
 // InitOpt is an option for initialization of a node's repo.
@@ -44,6 +45,14 @@ func DefaultKeyOpt(ki *crypto.KeyInfo) InitOpt {
 	}
 }
 
+// WalletPassphraseOpt encrypts the wallet with a key derived from passphrase.
+// If unspecified, the wallet is stored in plaintext.
+func WalletPassphraseOpt(passphrase string) InitOpt {
+	return func(opts *initCfg) {
+		opts.passphrase = passphrase
+	}
+}
+
 // ImportKeyOpt imports the provided key during initialization.
 func ImportKeyOpt(ki *crypto.KeyInfo) InitOpt {
 	return func(opts *initCfg) {
@@ -86,6 +95,12 @@ func Init(ctx context.Context, r repo.Repo, gen consensus.GenesisInitFunc, opts
 		return err
 	}
 
+	if cfg.passphrase != "" {
+		if err := wallet.EncryptDatastore(r.WalletDatastore(), cfg.passphrase); err != nil {
+			return errors.Wrap(err, "failed to encrypt wallet")
+		}
+	}
+
 	defaultAddress, err := defaultKey.Address()
 	if err != nil {
 		return errors.Wrap(err, "failed to extract address from default key")
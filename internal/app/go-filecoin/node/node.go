@@ -6,6 +6,7 @@ import (
 	"os"
 	"reflect"
 	"runtime"
+	"time"
 
 	"github.com/filecoin-project/go-filecoin/internal/pkg/constants"
 
@@ -37,6 +38,7 @@ import (
 	"github.com/filecoin-project/go-filecoin/internal/pkg/mining"
 	"github.com/filecoin-project/go-filecoin/internal/pkg/net/pubsub"
 	"github.com/filecoin-project/go-filecoin/internal/pkg/piecemanager"
+	"github.com/filecoin-project/go-filecoin/internal/pkg/postgenerator"
 	mining_protocol "github.com/filecoin-project/go-filecoin/internal/pkg/protocol/mining"
 	"github.com/filecoin-project/go-filecoin/internal/pkg/repo"
 	"github.com/filecoin-project/go-filecoin/internal/pkg/state"
@@ -91,6 +93,7 @@ type Node struct {
 	Messaging         submodule.MessagingSubmodule
 	StorageNetworking submodule.StorageNetworkingSubmodule
 	ProofVerification submodule.ProofVerificationSubmodule
+	PaymentChannels   submodule.PaymentChannelSubmodule
 
 	//
 	// Protocols
@@ -99,6 +102,7 @@ type Node struct {
 	VersionTable      *version.ProtocolVersionTable
 	StorageProtocol   *submodule.StorageProtocolSubmodule
 	RetrievalProtocol *submodule.RetrievalProtocolSubmodule
+	RetrievalClient   *submodule.RetrievalClientSubmodule
 }
 
 // Start boots up the node.
@@ -142,6 +146,10 @@ func (node *Node) Start(ctx context.Context) error {
 	if err != nil {
 		return errors.Wrap(err, "failed to get chain head")
 	}
+	// Messages sent before the last shutdown and not yet mined are published again.
+	if err := node.Messaging.Outbox.Restore(ctx); err != nil {
+		log.Errorf("failed to restore outbound message queue: %s", err)
+	}
 	go node.handleNewChainHeads(syncCtx, head)
 
 	if !node.OfflineMode {
@@ -179,6 +187,14 @@ func (node *Node) Start(ctx context.Context) error {
 		go node.doMiningPause(syncCtx)
 	}
 
+	if gcConfig := node.Repo.Config().GC; gcConfig.Enabled {
+		period, err := time.ParseDuration(gcConfig.Period)
+		if err != nil {
+			return errors.Wrap(err, "invalid gc period")
+		}
+		go node.collectGarbagePeriodically(syncCtx, period)
+	}
+
 	return nil
 }
 
@@ -356,6 +372,26 @@ func (node *Node) MiningAddress() (address.Address, error) {
 	return addr, nil
 }
 
+// MiningAddresses returns the addresses of all the mining actors the node
+// mines blocks for, starting with the one returned by MiningAddress.
+func (node *Node) MiningAddresses() ([]address.Address, error) {
+	primary, err := node.MiningAddress()
+	if err != nil {
+		return nil, err
+	}
+
+	addrs := []address.Address{primary}
+	seen := map[address.Address]struct{}{primary: {}}
+	for _, addr := range node.Repo.Config().Mining.MinerAddresses {
+		if _, ok := seen[addr]; ok || addr.Empty() {
+			continue
+		}
+		seen[addr] = struct{}{}
+		addrs = append(addrs, addr)
+	}
+	return addrs, nil
+}
+
 // SetupMining initializes all the functionality the node needs to start mining.
 // This method is idempotent.
 func (node *Node) SetupMining(ctx context.Context) error {
@@ -382,11 +418,18 @@ func (node *Node) SetupMining(ctx context.Context) error {
 			return err
 		}
 	}
-	// ensure we have a mining worker
+	// ensure we have a mining worker for each miner
 	if node.BlockMining.MiningWorker == nil {
-		if node.BlockMining.MiningWorker, err = node.CreateMiningWorker(ctx); err != nil {
+		workers, err := node.CreateMiningWorkers(ctx)
+		if err != nil {
 			return err
 		}
+		minerWorkers := make([]mining.MinerWorker, len(workers))
+		for i, w := range workers {
+			minerWorkers[i] = w
+		}
+		node.BlockMining.MiningWorkers = workers
+		node.BlockMining.MiningWorker = mining.NewMultiWorker(minerWorkers...)
 	}
 
 	return nil
@@ -417,40 +460,12 @@ func (node *Node) setupStorageMining(ctx context.Context) error {
 		return err
 	}
 
-	head := node.Chain().ChainReader.GetHead()
-	status, err := node.PorcelainAPI.MinerGetStatus(ctx, minerAddr, head)
-	if err != nil {
-		return err
-	}
-
 	repoPath, err := node.Repo.Path()
 	if err != nil {
 		return err
 	}
 
-	sectorDir, err := paths.GetSectorPath(node.Repo.Config().SectorBase.RootDir, repoPath)
-	if err != nil {
-		return err
-	}
-
-	postProofType, sealProofType, err := registeredProofsFromSectorSize(status.SectorSize)
-	if err != nil {
-		return err
-	}
-
-	sectorBuilder, err := sectorbuilder.New(&sectorbuilder.Config{
-		PoStProofType: postProofType,
-		SealProofType: sealProofType,
-		Miner:         minerAddr,
-		WorkerThreads: 1,
-		Paths: []fs.PathConfig{
-			{
-				Path:   sectorDir,
-				Cache:  false,
-				Weight: 1,
-			},
-		},
-	}, namespace.Wrap(node.Repo.Datastore(), ds.NewKey("/sectorbuilder")))
+	sectorBuilder, err := node.newSectorBuilder(ctx, minerAddr, ds.NewKey("/sectorbuilder"))
 	if err != nil {
 		return err
 	}
@@ -477,8 +492,8 @@ func (node *Node) setupStorageMining(ctx context.Context) error {
 		node.Wallet.Wallet,
 		node.Host(),
 		node.Repo.Datastore(),
-		node.Blockstore.Blockstore,
-		node.network.GraphExchange,
+		&node.Blockstore,
+		node.network.PieceGraphExchange,
 		repoPath,
 		sectorBuilder.SealProofType(),
 	)
@@ -489,19 +504,58 @@ func (node *Node) setupStorageMining(ctx context.Context) error {
 	return nil
 }
 
+// newSectorBuilder creates a sector builder for the miner at `minerAddr`,
+// storing its metadata under `dsKey` in the repo datastore.
+func (node *Node) newSectorBuilder(ctx context.Context, minerAddr address.Address, dsKey ds.Key) (*sectorbuilder.SectorBuilder, error) {
+	head := node.Chain().ChainReader.GetHead()
+	status, err := node.PorcelainAPI.MinerGetStatus(ctx, minerAddr, head)
+	if err != nil {
+		return nil, err
+	}
+
+	repoPath, err := node.Repo.Path()
+	if err != nil {
+		return nil, err
+	}
+
+	sectorDir, err := paths.GetSectorPath(node.Repo.Config().SectorBase.RootDir, repoPath)
+	if err != nil {
+		return nil, err
+	}
+
+	postProofType, sealProofType, err := registeredProofsFromSectorSize(status.SectorSize)
+	if err != nil {
+		return nil, err
+	}
+
+	return sectorbuilder.New(&sectorbuilder.Config{
+		PoStProofType: postProofType,
+		SealProofType: sealProofType,
+		Miner:         minerAddr,
+		WorkerThreads: 1,
+		Paths: []fs.PathConfig{
+			{
+				Path:   sectorDir,
+				Cache:  false,
+				Weight: 1,
+			},
+		},
+	}, namespace.Wrap(node.Repo.Datastore(), dsKey))
+}
+
 func (node *Node) setupRetrievalMining(ctx context.Context) error {
 	providerAddr, err := node.MiningAddress()
 	if err != nil {
 		return errors.Wrap(err, "failed to get mining address")
 	}
 	rp, err := submodule.NewRetrievalProtocolSubmodule(
-		node.Blockstore.Blockstore,
+		node.Blockstore.PieceBlockstore,
 		node.Repo.Datastore(),
 		node.chain.State,
 		node.Host(),
 		providerAddr,
 		node.Wallet.Wallet,
-		nil, // TODO: payment channel manager API, in follow-up
+		node.PaymentChannels.Manager,
 		node.PieceManager(),
 	)
 	if err != nil {
@@ -629,13 +683,15 @@ func (node *Node) handleSubscription(ctx context.Context, sub pubsub.Subscriptio
 func (node *Node) setupProtocols() error {
 	blockMiningAPI := mining_protocol.New(
 		node.MiningAddress,
+		node.MiningAddresses,
 		node.addMinedBlockSynchronous,
 		node.chain.ChainReader,
 		node.IsMining,
 		node.SetupMining,
 		node.StartMining,
 		node.StopMining,
-		node.GetMiningWorker,
+		node.GetMiningWorkers,
+		node.MiningResults,
 		node.ChainClock,
 	)
 
@@ -643,22 +699,83 @@ func (node *Node) setupProtocols() error {
 	return nil
 }
 
-// GetMiningWorker ensures mining is setup and then returns the worker
-func (node *Node) GetMiningWorker(ctx context.Context) (*mining.DefaultWorker, error) {
+// GetMiningWorkers ensures mining is setup and then returns the worker of
+// each miner.
+func (node *Node) GetMiningWorkers(ctx context.Context) ([]*mining.DefaultWorker, error) {
 	if err := node.SetupMining(ctx); err != nil {
 		return nil, err
 	}
-	return node.BlockMining.MiningWorker, nil
+	return node.BlockMining.MiningWorkers, nil
 }
 
-// CreateMiningWorker creates a mining.Worker for the node using the configured
-// getStateTree, getWeight, and getAncestors functions for the node
-func (node *Node) CreateMiningWorker(ctx context.Context) (*mining.DefaultWorker, error) {
-	minerAddr, err := node.MiningAddress()
+// MiningResults returns the results of the elections run by each of the
+// node's miners. Miners that have not run any election yet are reported
+// with empty results.
+func (node *Node) MiningResults() []mining.MinerResult {
+	addrs, err := node.MiningAddresses()
 	if err != nil {
-		return nil, errors.Wrap(err, "failed to get mining address")
+		return nil
+	}
+
+	byMiner := make(map[address.Address]mining.MinerResult)
+	if node.BlockMining.MiningWorker != nil {
+		for _, result := range node.BlockMining.MiningWorker.Results() {
+			byMiner[result.Miner] = result
+		}
 	}
 
+	results := make([]mining.MinerResult, len(addrs))
+	for i, addr := range addrs {
+		result, ok := byMiner[addr]
+		if !ok {
+			result = mining.MinerResult{Miner: addr}
+		}
+		results[i] = result
+	}
+	return results
+}
+
+// CreateMiningWorkers creates a mining.Worker for each of the node's miners.
+// The node's wallet must hold the key of each miner's worker.
+func (node *Node) CreateMiningWorkers(ctx context.Context) ([]*mining.DefaultWorker, error) {
+	minerAddrs, err := node.MiningAddresses()
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to get mining addresses")
+	}
+
+	workers := make([]*mining.DefaultWorker, len(minerAddrs))
+	for i, minerAddr := range minerAddrs {
+		poster := node.BlockMining.PoStGenerator
+		if poster == nil {
+			if poster, err = node.postGenerator(ctx, minerAddr); err != nil {
+				return nil, errors.Wrapf(err, "failed to create PoSt generator for miner %s", minerAddr)
+			}
+		}
+		if workers[i], err = node.createMiningWorker(ctx, minerAddr, poster); err != nil {
+			return nil, errors.Wrapf(err, "failed to create mining worker for miner %s", minerAddr)
+		}
+	}
+	return workers, nil
+}
+
+// postGenerator returns the PoSt generator for the miner at `minerAddr`.
+// The primary miner shares the one of the storage miner, the others get a
+// sector builder of their own.
+func (node *Node) postGenerator(ctx context.Context, minerAddr address.Address) (postgenerator.PoStGenerator, error) {
+	if primary, err := node.MiningAddress(); err == nil && primary == minerAddr {
+		return node.StorageMining.PoStGenerator, nil
+	}
+	sectorBuilder, err := node.newSectorBuilder(ctx, minerAddr, ds.NewKey("/sectorbuilders/"+minerAddr.String()))
+	if err != nil {
+		return nil, err
+	}
+	return postgenerator.NewSectorBuilderBackEnd(sectorBuilder), nil
+}
+
+// createMiningWorker creates a mining.Worker for the miner at `minerAddr`
+// using the configured getStateTree, getWeight, and getAncestors functions
+// for the node
+func (node *Node) createMiningWorker(ctx context.Context, minerAddr address.Address, poster postgenerator.PoStGenerator) (*mining.DefaultWorker, error) {
 	head := node.PorcelainAPI.ChainHeadKey()
 	minerStatus, err := node.PorcelainAPI.MinerGetStatus(ctx, minerAddr, head)
 	if err != nil {
@@ -666,6 +783,24 @@ func (node *Node) CreateMiningWorker(ctx context.Context) (*mining.DefaultWorker
 		return nil, err
 	}
 
+	view, err := node.PorcelainAPI.PowerStateView(head)
+	if err != nil {
+		return nil, err
+	}
+	workerSignerAddr, err := view.AccountSignerAddress(ctx, minerStatus.WorkerAddress)
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to resolve worker address")
+	}
+	if !node.Wallet.Wallet.HasAddress(workerSignerAddr) {
+		return nil, errors.Errorf("wallet has no key for worker %s", minerStatus.WorkerAddress)
+	}
+
+	simulator := mining.NewStateSimulator(node.chain.ChainReader.GetTipSetState, node.Chain().Processor, node.Blockstore.Blockstore)
+	messageSelector, err := mining.NewMessageSelector(node.Repo.Config().Mining.MessageSelection, simulator)
+	if err != nil {
+		return nil, err
+	}
+
 	return mining.NewDefaultWorker(mining.WorkerParameters{
 		API: node.PorcelainAPI,
 
@@ -679,12 +814,13 @@ func (node *Node) CreateMiningWorker(ctx context.Context) (*mining.DefaultWorker
 		TicketGen:      consensus.NewTicketMachine(node.PorcelainAPI),
 		TipSetMetadata: node.chain.ChainReader,
 
-		MessageSource: node.Messaging.Inbox.Pool(),
-		MessageStore:  node.chain.MessageStore,
-		Processor:     node.Chain().Processor,
-		Blockstore:    node.Blockstore.Blockstore,
-		Clock:         node.ChainClock,
-		Poster:        node.StorageMining.PoStGenerator,
+		MessageSource:   node.Messaging.Inbox.Pool(),
+		MessageSelector: messageSelector,
+		MessageStore:    node.chain.MessageStore,
+		Processor:       node.Chain().Processor,
+		Blockstore:      node.Blockstore.Blockstore,
+		Clock:           node.ChainClock,
+		Poster:          poster,
 	}), nil
 }
 
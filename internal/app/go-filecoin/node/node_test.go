@@ -228,6 +228,8 @@ func TestNodeConfig(t *testing.T) {
 	assert.Equal(t, true, n.OfflineMode)
 	assert.Equal(t, defaultCfg.Mining, cfg.Mining)
 	assert.Equal(t, &config.SwarmConfig{
-		Address: "/ip4/127.0.0.1/tcp/0",
+		Address:      "/ip4/127.0.0.1/tcp/0",
+		BanThreshold: defaultCfg.Swarm.BanThreshold,
+		BanDuration:  defaultCfg.Swarm.BanDuration,
 	}, cfg.Swarm)
 }
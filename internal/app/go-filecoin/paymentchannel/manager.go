@@ -0,0 +1,303 @@
+package paymentchannel
+
+import (
+	"context"
+	"fmt"
+	"sync"
+
+	"github.com/filecoin-project/go-address"
+	"github.com/filecoin-project/specs-actors/actors/abi"
+	"github.com/filecoin-project/specs-actors/actors/abi/big"
+	"github.com/filecoin-project/specs-actors/actors/builtin"
+	init_ "github.com/filecoin-project/specs-actors/actors/builtin/init"
+	"github.com/filecoin-project/specs-actors/actors/builtin/paych"
+	"github.com/filecoin-project/specs-actors/actors/runtime/exitcode"
+	"github.com/ipfs/go-cid"
+	logging "github.com/ipfs/go-log"
+	"github.com/pkg/errors"
+
+	"github.com/filecoin-project/go-filecoin/internal/pkg/block"
+	"github.com/filecoin-project/go-filecoin/internal/pkg/encoding"
+	"github.com/filecoin-project/go-filecoin/internal/pkg/types"
+	"github.com/filecoin-project/go-filecoin/internal/pkg/vm"
+)
+
+var log = logging.Logger("paymentchannel")
+
+var (
+	paychGasPrice = types.NewGasPrice(1)
+	// Creating a channel executes the init actor and the paych constructor.
+	createChannelGasLimit = types.GasUnits(1000)
+	addFundsGasLimit      = types.GasUnits(300)
+)
+
+// ChainReader is the subset of the chain state needed to load payment channel
+// actor state.
+type ChainReader interface {
+	Head() block.TipSetKey
+	GetActorStateAt(ctx context.Context, tipKey block.TipSetKey, addr address.Address, out interface{}) error
+}
+
+// MsgSender sends messages to the network.
+type MsgSender interface {
+	Send(ctx context.Context, from, to address.Address, value types.AttoFIL, gasPrice types.AttoFIL,
+		gasLimit types.GasUnits, bcast bool, method abi.MethodNum, params interface{}) (cid.Cid, chan error, error)
+}
+
+// MsgWaiter waits for messages to be included on chain.
+type MsgWaiter interface {
+	Wait(ctx context.Context, msgCid cid.Cid, cb func(*block.Block, *types.SignedMessage, *vm.MessageReceipt) error) error
+}
+
+// Manager creates payment channels and tracks their lanes and vouchers.
+// Channels paid from this node are created on chain through the init actor and
+// recorded in the store. Channels paid to this node are loaded from the paych
+// actor state the first time they are seen.
+type Manager struct {
+	ctx    context.Context
+	store  *Store
+	chain  ChainReader
+	sender MsgSender
+	waiter MsgWaiter
+
+	// Serializes read-modify-write of channel records.
+	lk sync.Mutex
+}
+
+// NewManager creates a payment channel manager. The context bounds chain
+// state reads made on behalf of callers that don't provide one.
+func NewManager(ctx context.Context, store *Store, chain ChainReader, sender MsgSender, waiter MsgWaiter) *Manager {
+	return &Manager{
+		ctx:    ctx,
+		store:  store,
+		chain:  chain,
+		sender: sender,
+		waiter: waiter,
+	}
+}
+
+// CreatePaymentChannel creates a channel from payer to payee funded with amt,
+// waits for it to be created on chain and records it.
+func (m *Manager) CreatePaymentChannel(ctx context.Context, payer, payee address.Address, amt abi.TokenAmount) (address.Address, error) {
+	ctorParams, err := encoding.Encode(&paych.ConstructorParams{From: payer, To: payee})
+	if err != nil {
+		return address.Undef, err
+	}
+	execParams := &init_.ExecParams{
+		CodeCID:           builtin.PaymentChannelActorCodeID,
+		ConstructorParams: ctorParams,
+	}
+
+	mcid, pubErrCh, err := m.sender.Send(ctx, payer, builtin.InitActorAddr, amt, paychGasPrice, createChannelGasLimit,
+		true, builtin.MethodsInit.Exec, execParams)
+	if err != nil {
+		return address.Undef, errors.Wrap(err, "failed to send payment channel creation message")
+	}
+	receipt, err := m.wait(ctx, mcid, pubErrCh)
+	if err != nil {
+		return address.Undef, err
+	}
+
+	var ret init_.ExecReturn
+	if err := encoding.Decode(receipt.ReturnValue, &ret); err != nil {
+		return address.Undef, errors.Wrap(err, "failed to decode payment channel creation return")
+	}
+
+	m.lk.Lock()
+	defer m.lk.Unlock()
+	info := &ChannelInfo{
+		Owner: payer,
+		State: &paych.State{
+			From:   payer,
+			To:     payee,
+			ToSend: big.Zero(),
+		},
+	}
+	if err := m.store.Put(ret.RobustAddress, info); err != nil {
+		return address.Undef, err
+	}
+	return ret.RobustAddress, nil
+}
+
+// AddFundsToChannel sends amt from the channel's owner to the channel.
+func (m *Manager) AddFundsToChannel(ctx context.Context, paychAddr address.Address, amt abi.TokenAmount) error {
+	info, err := m.GetPaymentChannelInfo(paychAddr)
+	if err != nil {
+		return err
+	}
+	mcid, pubErrCh, err := m.sender.Send(ctx, info.Owner, paychAddr, amt, paychGasPrice, addFundsGasLimit,
+		true, builtin.MethodSend, nil)
+	if err != nil {
+		return errors.Wrapf(err, "failed to send funds to payment channel %s", paychAddr)
+	}
+	_, err = m.wait(ctx, mcid, pubErrCh)
+	return err
+}
+
+// GetPaymentChannelInfo returns the record for a channel. A channel without a
+// record is loaded from its actor state on chain and recorded.
+func (m *Manager) GetPaymentChannelInfo(paychAddr address.Address) (*ChannelInfo, error) {
+	m.lk.Lock()
+	defer m.lk.Unlock()
+	return m.loadChannel(paychAddr)
+}
+
+// GetPaymentChannelByAccounts returns the address and record of a channel
+// owned by payer and paying to payee, or an undefined address and nil if
+// there is none.
+func (m *Manager) GetPaymentChannelByAccounts(payer, payee address.Address) (address.Address, *ChannelInfo) {
+	m.lk.Lock()
+	defer m.lk.Unlock()
+
+	channels, err := m.store.List()
+	if err != nil {
+		log.Errorf("failed to list payment channels: %s", err)
+		return address.Undef, nil
+	}
+	for paychAddr, info := range channels {
+		if info.Owner == payer && info.State.To == payee {
+			return paychAddr, info
+		}
+	}
+	return address.Undef, nil
+}
+
+// AllocateLane adds a new lane to a channel and returns its id.
+func (m *Manager) AllocateLane(paychAddr address.Address) (uint64, error) {
+	m.lk.Lock()
+	defer m.lk.Unlock()
+
+	info, err := m.loadChannel(paychAddr)
+	if err != nil {
+		return 0, err
+	}
+	id := uint64(len(info.State.LaneStates))
+	info.State.LaneStates = append(info.State.LaneStates, &paych.LaneState{
+		ID:       id,
+		Redeemed: big.Zero(),
+	})
+	if err := m.store.Put(paychAddr, info); err != nil {
+		return 0, err
+	}
+	return id, nil
+}
+
+// CreateVoucher records a voucher issued by this node. The voucher's lane
+// must be allocated and its nonce must exceed that of the last voucher issued
+// on the lane.
+func (m *Manager) CreateVoucher(paychAddr address.Address, voucher *paych.SignedVoucher) error {
+	m.lk.Lock()
+	defer m.lk.Unlock()
+
+	info, err := m.loadChannel(paychAddr)
+	if err != nil {
+		return err
+	}
+	lane := findLane(info.State, voucher.Lane)
+	if lane == nil {
+		return fmt.Errorf("lane %d not allocated in payment channel %s", voucher.Lane, paychAddr)
+	}
+	if voucher.Nonce <= lane.Nonce {
+		return fmt.Errorf("voucher nonce %d does not exceed lane nonce %d", voucher.Nonce, lane.Nonce)
+	}
+
+	lane.Nonce = voucher.Nonce
+	lane.Redeemed = voucher.Amount
+	info.Vouchers = append(info.Vouchers, &VoucherInfo{Voucher: voucher})
+	return m.store.Put(paychAddr, info)
+}
+
+// SaveVoucher records a voucher received by this node and returns the amount
+// it adds to its lane. The amount must be at least expected.
+func (m *Manager) SaveVoucher(paychAddr address.Address, voucher *paych.SignedVoucher, proof []byte, expected abi.TokenAmount) (abi.TokenAmount, error) {
+	m.lk.Lock()
+	defer m.lk.Unlock()
+
+	if voucher.Signature == nil {
+		return abi.NewTokenAmount(0), errors.New("voucher is not signed")
+	}
+	info, err := m.loadChannel(paychAddr)
+	if err != nil {
+		return abi.NewTokenAmount(0), err
+	}
+
+	// Lanes of inbound channels are allocated by the payer, so are recorded
+	// when their first voucher arrives.
+	lane := findLane(info.State, voucher.Lane)
+	if lane == nil {
+		lane = &paych.LaneState{ID: voucher.Lane, Redeemed: big.Zero()}
+		info.State.LaneStates = append(info.State.LaneStates, lane)
+	}
+	if voucher.Nonce <= lane.Nonce {
+		return abi.NewTokenAmount(0), fmt.Errorf("voucher nonce %d does not exceed lane nonce %d", voucher.Nonce, lane.Nonce)
+	}
+	delta := big.Sub(voucher.Amount, lane.Redeemed)
+	if delta.LessThan(expected) {
+		return abi.NewTokenAmount(0), fmt.Errorf("voucher adds %s to lane, expected at least %s", delta, expected)
+	}
+
+	lane.Nonce = voucher.Nonce
+	lane.Redeemed = voucher.Amount
+	info.Vouchers = append(info.Vouchers, &VoucherInfo{Voucher: voucher, Proof: proof})
+	if err := m.store.Put(paychAddr, info); err != nil {
+		return abi.NewTokenAmount(0), err
+	}
+	return delta, nil
+}
+
+// loadChannel reads a channel record, falling back to the actor state on chain.
+// Callers must hold the lock.
+func (m *Manager) loadChannel(paychAddr address.Address) (*ChannelInfo, error) {
+	info, err := m.store.Get(paychAddr)
+	if err == nil {
+		return info, nil
+	}
+	if err != ErrChannelNotFound {
+		return nil, err
+	}
+
+	var state paych.State
+	if err := m.chain.GetActorStateAt(m.ctx, m.chain.Head(), paychAddr, &state); err != nil {
+		return nil, errors.Wrapf(err, "failed to load payment channel %s", paychAddr)
+	}
+	info = &ChannelInfo{
+		Owner: state.From,
+		State: &state,
+	}
+	if err := m.store.Put(paychAddr, info); err != nil {
+		return nil, err
+	}
+	return info, nil
+}
+
+// wait waits for the message to be published and included on chain, and
+// checks that it succeeded.
+func (m *Manager) wait(ctx context.Context, mcid cid.Cid, pubErrCh chan error) (*vm.MessageReceipt, error) {
+	if pubErrCh != nil {
+		if err := <-pubErrCh; err != nil {
+			return nil, errors.Wrapf(err, "failed to publish message %s", mcid)
+		}
+	}
+
+	var receipt *vm.MessageReceipt
+	err := m.waiter.Wait(ctx, mcid, func(_ *block.Block, _ *types.SignedMessage, r *vm.MessageReceipt) error {
+		receipt = r
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	if receipt.ExitCode != exitcode.Ok {
+		return nil, fmt.Errorf("message %s failed with exit code %d", mcid, receipt.ExitCode)
+	}
+	return receipt, nil
+}
+
+func findLane(state *paych.State, id uint64) *paych.LaneState {
+	for _, ls := range state.LaneStates {
+		if ls.ID == id {
+			return ls
+		}
+	}
+	return nil
+}
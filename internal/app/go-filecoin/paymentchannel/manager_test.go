@@ -0,0 +1,167 @@
+package paymentchannel_test
+
+import (
+	"context"
+	"testing"
+
+	"github.com/filecoin-project/go-address"
+	"github.com/filecoin-project/specs-actors/actors/abi"
+	"github.com/filecoin-project/specs-actors/actors/abi/big"
+	"github.com/filecoin-project/specs-actors/actors/builtin"
+	init_ "github.com/filecoin-project/specs-actors/actors/builtin/init"
+	"github.com/filecoin-project/specs-actors/actors/builtin/paych"
+	"github.com/filecoin-project/specs-actors/actors/crypto"
+	spec_test "github.com/filecoin-project/specs-actors/support/testing"
+	"github.com/ipfs/go-cid"
+	"github.com/ipfs/go-datastore"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	. "github.com/filecoin-project/go-filecoin/internal/app/go-filecoin/paymentchannel"
+	"github.com/filecoin-project/go-filecoin/internal/pkg/block"
+	"github.com/filecoin-project/go-filecoin/internal/pkg/encoding"
+	tf "github.com/filecoin-project/go-filecoin/internal/pkg/testhelpers/testflags"
+	"github.com/filecoin-project/go-filecoin/internal/pkg/types"
+	"github.com/filecoin-project/go-filecoin/internal/pkg/vm"
+)
+
+func TestManagerCreatePaymentChannel(t *testing.T) {
+	tf.UnitTest(t)
+	ctx := context.Background()
+	payer := spec_test.NewIDAddr(t, 101)
+	payee := spec_test.NewIDAddr(t, 102)
+	paychAddr := requireActorAddr(t)
+
+	ds := datastore.NewMapDatastore()
+	api := newFakePaychAPI(t, paychAddr)
+	m := NewManager(ctx, NewStore(ds), api, api, api)
+
+	res, err := m.CreatePaymentChannel(ctx, payer, payee, abi.NewTokenAmount(500))
+	require.NoError(t, err)
+	assert.Equal(t, paychAddr, res)
+
+	require.Len(t, api.sent, 1)
+	sent := api.sent[0]
+	assert.Equal(t, builtin.InitActorAddr, sent.to)
+	assert.Equal(t, builtin.MethodsInit.Exec, sent.method)
+	assert.True(t, abi.NewTokenAmount(500).Equals(sent.value))
+	execParams := sent.params.(*init_.ExecParams)
+	assert.Equal(t, builtin.PaymentChannelActorCodeID, execParams.CodeCID)
+
+	// The channel is found by its accounts, including by a new manager on the same repo.
+	found, info := NewManager(ctx, NewStore(ds), api, api, api).GetPaymentChannelByAccounts(payer, payee)
+	require.NotNil(t, info)
+	assert.Equal(t, paychAddr, found)
+	assert.Equal(t, payer, info.Owner)
+
+	_, info = m.GetPaymentChannelByAccounts(payee, payer)
+	assert.Nil(t, info)
+}
+
+func TestManagerLanesAndVouchers(t *testing.T) {
+	tf.UnitTest(t)
+	ctx := context.Background()
+	payer := spec_test.NewIDAddr(t, 101)
+	payee := spec_test.NewIDAddr(t, 102)
+	paychAddr := requireActorAddr(t)
+	sig := &crypto.Signature{Type: crypto.SigTypeSecp256k1, Data: []byte("sig")}
+
+	t.Run("vouchers are recorded on allocated lanes", func(t *testing.T) {
+		api := newFakePaychAPI(t, paychAddr)
+		m := NewManager(ctx, NewStore(datastore.NewMapDatastore()), api, api, api)
+		_, err := m.CreatePaymentChannel(ctx, payer, payee, abi.NewTokenAmount(500))
+		require.NoError(t, err)
+
+		lane, err := m.AllocateLane(paychAddr)
+		require.NoError(t, err)
+		assert.Equal(t, uint64(0), lane)
+		lane, err = m.AllocateLane(paychAddr)
+		require.NoError(t, err)
+		assert.Equal(t, uint64(1), lane)
+
+		v := &paych.SignedVoucher{Lane: 1, Nonce: 1, Amount: abi.NewTokenAmount(10), Signature: sig}
+		require.NoError(t, m.CreateVoucher(paychAddr, v))
+		assert.Error(t, m.CreateVoucher(paychAddr, v), "nonce must increase")
+		assert.Error(t, m.CreateVoucher(paychAddr, &paych.SignedVoucher{Lane: 2, Nonce: 1, Amount: abi.NewTokenAmount(10)}))
+
+		info, err := m.GetPaymentChannelInfo(paychAddr)
+		require.NoError(t, err)
+		require.Len(t, info.Vouchers, 1)
+		assert.True(t, abi.NewTokenAmount(10).Equals(info.State.LaneStates[1].Redeemed))
+	})
+
+	t.Run("saved vouchers of inbound channels return the lane increment", func(t *testing.T) {
+		api := newFakePaychAPI(t, paychAddr)
+		api.state = &paych.State{From: payer, To: payee, ToSend: big.Zero()}
+		m := NewManager(ctx, NewStore(datastore.NewMapDatastore()), api, api, api)
+
+		v1 := &paych.SignedVoucher{Lane: 3, Nonce: 1, Amount: abi.NewTokenAmount(10), Signature: sig}
+		amt, err := m.SaveVoucher(paychAddr, v1, []byte("proof"), abi.NewTokenAmount(10))
+		require.NoError(t, err)
+		assert.True(t, abi.NewTokenAmount(10).Equals(amt))
+
+		v2 := &paych.SignedVoucher{Lane: 3, Nonce: 2, Amount: abi.NewTokenAmount(25), Signature: sig}
+		_, err = m.SaveVoucher(paychAddr, v2, nil, abi.NewTokenAmount(20))
+		assert.Error(t, err, "increment below expected")
+
+		amt, err = m.SaveVoucher(paychAddr, v2, nil, abi.NewTokenAmount(15))
+		require.NoError(t, err)
+		assert.True(t, abi.NewTokenAmount(15).Equals(amt))
+
+		_, err = m.SaveVoucher(paychAddr, v2, nil, abi.NewTokenAmount(0))
+		assert.Error(t, err, "replayed voucher")
+
+		info, err := m.GetPaymentChannelInfo(paychAddr)
+		require.NoError(t, err)
+		assert.Equal(t, payer, info.Owner)
+		assert.Len(t, info.Vouchers, 2)
+	})
+}
+
+type sentMessage struct {
+	to     address.Address
+	value  types.AttoFIL
+	method abi.MethodNum
+	params interface{}
+}
+
+// fakePaychAPI stands in for the chain, outbox and message waiter.
+type fakePaychAPI struct {
+	t         *testing.T
+	paychAddr address.Address
+	state     *paych.State
+	sent      []sentMessage
+}
+
+func newFakePaychAPI(t *testing.T, paychAddr address.Address) *fakePaychAPI {
+	return &fakePaychAPI{t: t, paychAddr: paychAddr}
+}
+
+func (f *fakePaychAPI) Head() block.TipSetKey {
+	return block.NewTipSetKey()
+}
+
+func (f *fakePaychAPI) GetActorStateAt(_ context.Context, _ block.TipSetKey, addr address.Address, out interface{}) error {
+	require.Equal(f.t, f.paychAddr, addr)
+	require.NotNil(f.t, f.state)
+	*out.(*paych.State) = *f.state
+	return nil
+}
+
+func (f *fakePaychAPI) Send(_ context.Context, _, to address.Address, value types.AttoFIL, _ types.AttoFIL,
+	_ types.GasUnits, _ bool, method abi.MethodNum, params interface{}) (cid.Cid, chan error, error) {
+	f.sent = append(f.sent, sentMessage{to: to, value: value, method: method, params: params})
+	return types.CidFromString(f.t, "somecid"), nil, nil
+}
+
+func (f *fakePaychAPI) Wait(_ context.Context, _ cid.Cid, cb func(*block.Block, *types.SignedMessage, *vm.MessageReceipt) error) error {
+	ret, err := encoding.Encode(&init_.ExecReturn{IDAddress: spec_test.NewIDAddr(f.t, 200), RobustAddress: f.paychAddr})
+	require.NoError(f.t, err)
+	return cb(nil, nil, &vm.MessageReceipt{ReturnValue: ret})
+}
+
+func requireActorAddr(t *testing.T) address.Address {
+	addr, err := address.NewActorAddress([]byte("paych"))
+	require.NoError(t, err)
+	return addr
+}
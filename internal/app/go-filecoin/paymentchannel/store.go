@@ -0,0 +1,82 @@
+package paymentchannel
+
+import (
+	"github.com/filecoin-project/go-address"
+	"github.com/ipfs/go-datastore"
+	"github.com/ipfs/go-datastore/query"
+	"github.com/pkg/errors"
+
+	"github.com/filecoin-project/go-filecoin/internal/pkg/encoding"
+)
+
+// channelKeyPrefix is the datastore namespace under which channel records are written.
+const channelKeyPrefix = "/paych/channels/"
+
+// ErrChannelNotFound is returned when no record exists for a payment channel.
+var ErrChannelNotFound = errors.New("payment channel not found")
+
+// Store persists payment channel records, keyed by channel address.
+type Store struct {
+	ds datastore.Batching
+}
+
+// NewStore creates a store backed by the given datastore.
+func NewStore(ds datastore.Batching) *Store {
+	return &Store{ds: ds}
+}
+
+// Get returns the record for a payment channel, or ErrChannelNotFound.
+func (s *Store) Get(paychAddr address.Address) (*ChannelInfo, error) {
+	bb, err := s.ds.Get(channelKey(paychAddr))
+	if err == datastore.ErrNotFound {
+		return nil, ErrChannelNotFound
+	}
+	if err != nil {
+		return nil, errors.Wrapf(err, "failed to read payment channel %s", paychAddr)
+	}
+
+	var info ChannelInfo
+	if err := encoding.Decode(bb, &info); err != nil {
+		return nil, errors.Wrapf(err, "failed to decode payment channel %s", paychAddr)
+	}
+	return &info, nil
+}
+
+// Put writes the record for a payment channel, replacing any existing record.
+func (s *Store) Put(paychAddr address.Address, info *ChannelInfo) error {
+	bb, err := encoding.Encode(info)
+	if err != nil {
+		return errors.Wrapf(err, "failed to encode payment channel %s", paychAddr)
+	}
+	return s.ds.Put(channelKey(paychAddr), bb)
+}
+
+// List returns every payment channel record, keyed by channel address.
+func (s *Store) List() (map[address.Address]*ChannelInfo, error) {
+	res, err := s.ds.Query(query.Query{Prefix: channelKeyPrefix})
+	if err != nil {
+		return nil, err
+	}
+	entries, err := res.Rest()
+	if err != nil {
+		return nil, err
+	}
+
+	out := make(map[address.Address]*ChannelInfo, len(entries))
+	for _, entry := range entries {
+		paychAddr, err := address.NewFromString(datastore.NewKey(entry.Key).BaseNamespace())
+		if err != nil {
+			return nil, errors.Wrapf(err, "invalid payment channel key %s", entry.Key)
+		}
+		var info ChannelInfo
+		if err := encoding.Decode(entry.Value, &info); err != nil {
+			return nil, errors.Wrapf(err, "failed to decode payment channel %s", paychAddr)
+		}
+		out[paychAddr] = &info
+	}
+	return out, nil
+}
+
+func channelKey(paychAddr address.Address) datastore.Key {
+	return datastore.NewKey(channelKeyPrefix + paychAddr.String())
+}
@@ -15,16 +15,22 @@ import (
 	"github.com/libp2p/go-libp2p-core/peer"
 	"github.com/libp2p/go-libp2p/p2p/protocol/ping"
 	ma "github.com/multiformats/go-multiaddr"
+	"github.com/pkg/errors"
 
 	"github.com/filecoin-project/go-filecoin/internal/app/go-filecoin/plumbing/cfg"
 	"github.com/filecoin-project/go-filecoin/internal/app/go-filecoin/plumbing/cst"
 	"github.com/filecoin-project/go-filecoin/internal/app/go-filecoin/plumbing/dag"
+	"github.com/filecoin-project/go-filecoin/internal/app/go-filecoin/plumbing/events"
 	"github.com/filecoin-project/go-filecoin/internal/app/go-filecoin/plumbing/msg"
 	"github.com/filecoin-project/go-filecoin/internal/pkg/block"
 	"github.com/filecoin-project/go-filecoin/internal/pkg/chain"
+	"github.com/filecoin-project/go-filecoin/internal/pkg/chainsync"
 	"github.com/filecoin-project/go-filecoin/internal/pkg/chainsync/status"
+	"github.com/filecoin-project/go-filecoin/internal/pkg/clock"
 	"github.com/filecoin-project/go-filecoin/internal/pkg/consensus"
 	"github.com/filecoin-project/go-filecoin/internal/pkg/crypto"
+	"github.com/filecoin-project/go-filecoin/internal/pkg/discovery"
+	"github.com/filecoin-project/go-filecoin/internal/pkg/gc"
 	"github.com/filecoin-project/go-filecoin/internal/pkg/message"
 	"github.com/filecoin-project/go-filecoin/internal/pkg/net"
 	"github.com/filecoin-project/go-filecoin/internal/pkg/piecemanager"
@@ -49,12 +55,18 @@ type API struct {
 	config       *cfg.Config
 	dag          *dag.DAG
 	expected     consensus.Protocol
+	msgEstimator *msg.Estimator
 	msgPool      *message.Pool
 	msgPreviewer *msg.Previewer
+	msgTracer    *msg.Tracer
 	msgWaiter    *msg.Waiter
 	network      *net.Network
+	notifier     *events.Notifier
 	outbox       *message.Outbox
+	peerScorer   *discovery.PeerScorer
 	pieceManager func() piecemanager.PieceManager
+	pins         *gc.Pins
+	pricelists   *vm.PricelistSchedule
 	wallet       *wallet.Wallet
 }
 
@@ -65,12 +77,18 @@ type APIDeps struct {
 	Config       *cfg.Config
 	DAG          *dag.DAG
 	Expected     consensus.Protocol
+	MsgEstimator *msg.Estimator
 	MsgPool      *message.Pool
 	MsgPreviewer *msg.Previewer
+	MsgTracer    *msg.Tracer
 	MsgWaiter    *msg.Waiter
 	Network      *net.Network
+	Notifier     *events.Notifier
 	Outbox       *message.Outbox
+	PeerScorer   *discovery.PeerScorer
 	PieceManager func() piecemanager.PieceManager
+	Pins         *gc.Pins
+	Pricelists   *vm.PricelistSchedule
 	Wallet       *wallet.Wallet
 }
 
@@ -83,12 +101,18 @@ func New(deps *APIDeps) *API {
 		config:       deps.Config,
 		dag:          deps.DAG,
 		expected:     deps.Expected,
+		msgEstimator: deps.MsgEstimator,
 		msgPool:      deps.MsgPool,
 		msgPreviewer: deps.MsgPreviewer,
+		msgTracer:    deps.MsgTracer,
 		msgWaiter:    deps.MsgWaiter,
 		network:      deps.Network,
+		notifier:     deps.Notifier,
 		outbox:       deps.Outbox,
+		peerScorer:   deps.PeerScorer,
 		pieceManager: deps.PieceManager,
+		pins:         deps.Pins,
+		pricelists:   deps.Pricelists,
 		wallet:       deps.Wallet,
 	}
 }
@@ -98,6 +122,11 @@ func (api *API) ActorGet(ctx context.Context, addr address.Address) (*actor.Acto
 	return api.chain.GetActor(ctx, addr)
 }
 
+// ActorGetAt returns an actor from the state of the tipset with key `baseKey`.
+func (api *API) ActorGetAt(ctx context.Context, baseKey block.TipSetKey, addr address.Address) (*actor.Actor, error) {
+	return api.chain.GetActorAt(ctx, baseKey, addr)
+}
+
 // ActorGetSignature returns the signature of the given actor's given method.
 // The function signature is typically used to enable a caller to decode the
 // output of an actor method call (message).
@@ -146,6 +175,12 @@ func (api *API) ChainGetReceipts(ctx context.Context, id cid.Cid) ([]vm.MessageR
 	return api.chain.GetReceipts(ctx, id)
 }
 
+// ChainHeadChanges streams batches of tipsets applied to and reverted from
+// the head chain, starting with the current head, until ctx is done.
+func (api *API) ChainHeadChanges(ctx context.Context) (<-chan []events.HeadChange, error) {
+	return api.notifier.HeadChanges(ctx)
+}
+
 // ChainHeadKey returns the head tipset key
 func (api *API) ChainHeadKey() block.TipSetKey {
 	return api.chain.Head()
@@ -181,14 +216,46 @@ func (api *API) ChainSyncHandleNewTipSet(ci *block.ChainInfo) error {
 	return api.syncer.HandleNewTipSet(ci)
 }
 
-// ChainExport exports the chain from `head` up to and including the genesis block to `out`
-func (api *API) ChainExport(ctx context.Context, head block.TipSetKey, out io.Writer) error {
-	return api.chain.ChainExport(ctx, head, out)
+// ChainBadTipSets returns the tipsets that failed validation and are not synced again.
+func (api *API) ChainBadTipSets() []*chainsync.BadTipSet {
+	return api.syncer.BadTipSets()
+}
+
+// ChainUnmarkBadTipSet forgets that the tipset with key `key` failed
+// validation, so that it is validated again when next received.
+func (api *API) ChainUnmarkBadTipSet(key block.TipSetKey) (bool, error) {
+	return api.syncer.UnmarkBadTipSet(key)
 }
 
-// ChainImport imports a chain from `in`.
-func (api *API) ChainImport(ctx context.Context, in io.Reader) (block.TipSetKey, error) {
-	return api.chain.ChainImport(ctx, in)
+// ChainExport exports the chain from `head` up to and including the genesis block to `out`.
+// If `recentStateRoots` is positive only the state of that many recent tipsets is exported.
+func (api *API) ChainExport(ctx context.Context, head block.TipSetKey, out io.Writer, recentStateRoots int) error {
+	return api.chain.ChainExport(ctx, head, out, recentStateRoots)
+}
+
+// ChainImport imports a chain from `in`, recomputing the state of the `recompute`
+// most recent tipsets to verify it.
+func (api *API) ChainImport(ctx context.Context, in io.Reader, recompute int) (block.TipSetKey, error) {
+	return api.chain.ChainImport(ctx, in, api.expected, recompute)
+}
+
+// ChainReplay runs the state transition of the tipset with key `key` again and
+// compares the result with the state stored for it. The replay stores nothing.
+func (api *API) ChainReplay(ctx context.Context, key block.TipSetKey) (*chain.ReplayResult, error) {
+	return api.chain.ChainReplay(ctx, key, api.expected.ReadOnly())
+}
+
+// StateDiff returns the actors added, removed or changed from the state with
+// root `rootA` to the state with root `rootB`, with the changed fields of
+// builtin actor states.
+func (api *API) StateDiff(ctx context.Context, rootA, rootB cid.Cid) ([]*appstate.ActorStateDiff, error) {
+	return api.chain.StateDiff(ctx, rootA, rootB)
+}
+
+// ProtocolGasPrices returns the protocol version active at `epoch` and the gas
+// prices the vm charges under it.
+func (api *API) ProtocolGasPrices(epoch abi.ChainEpoch) (uint64, vm.GasPrices, error) {
+	return api.pricelists.PricesAt(epoch)
 }
 
 // OutboxQueues lists addresses with non-empty outbox queues (in no particular order).
@@ -206,6 +273,12 @@ func (api *API) OutboxQueueClear(ctx context.Context, sender address.Address) {
 	api.outbox.Queue().Clear(ctx, sender)
 }
 
+// OutboxQueueResend publishes and broadcasts again the messages queued for an address,
+// returning how many were published.
+func (api *API) OutboxQueueResend(ctx context.Context, sender address.Address) (int, error) {
+	return api.outbox.Resend(ctx, sender)
+}
+
 // MessagePoolPending lists messages un-mined in the pool
 func (api *API) MessagePoolPending() []*types.SignedMessage {
 	return api.msgPool.Pending()
@@ -221,10 +294,49 @@ func (api *API) MessagePoolRemove(cid cid.Cid) {
 	api.msgPool.Remove(cid)
 }
 
-// MessagePreview previews the Gas cost of a message by running it locally on the client and
-// recording the amount of Gas used.
-func (api *API) MessagePreview(ctx context.Context, from, to address.Address, method abi.MethodNum, params ...interface{}) (types.GasUnits, error) {
-	return api.msgPreviewer.Preview(ctx, from, to, method, params...)
+// MessagePreview previews the Gas cost of a message transferring `value` by running it locally
+// on the client and recording the amount of Gas used.
+func (api *API) MessagePreview(ctx context.Context, from, to address.Address, value types.AttoFIL, method abi.MethodNum, params ...interface{}) (types.GasUnits, error) {
+	usedGas, trace, err := api.msgPreviewer.Preview(ctx, from, to, value, method, params...)
+	if err != nil {
+		return types.GasUnits(0), err
+	}
+	if trace.ExitCode.IsError() {
+		return types.GasUnits(0), errors.Errorf("message failed with exit code %d", trace.ExitCode)
+	}
+	return usedGas, nil
+}
+
+// MessagePreviewTrace runs a message locally like MessagePreview, and
+// returns the trace of its execution, whether it succeeds or not.
+func (api *API) MessagePreviewTrace(ctx context.Context, from, to address.Address, value types.AttoFIL, method abi.MethodNum, params ...interface{}) (*vm.ExecutionTrace, error) {
+	_, trace, err := api.msgPreviewer.Preview(ctx, from, to, value, method, params...)
+	return trace, err
+}
+
+// MessageEstimate runs a message locally like MessagePreview, and suggests
+// its gas limit, the gas it used with a safety margin, and a gas price from
+// the messages included in recent tipsets.
+func (api *API) MessageEstimate(ctx context.Context, from, to address.Address, value types.AttoFIL, method abi.MethodNum, params ...interface{}) (*msg.GasEstimate, error) {
+	return api.msgEstimator.Estimate(ctx, from, to, value, method, params...)
+}
+
+// MessageEstimateGasPrice suggests a gas price from the messages included in
+// recent tipsets.
+func (api *API) MessageEstimateGasPrice(ctx context.Context) (types.AttoFIL, error) {
+	return api.msgEstimator.GasPrice(ctx)
+}
+
+// MessageEstimateGasLimit suggests the gas limit of a message using
+// `gasUsed` against the head state.
+func (api *API) MessageEstimateGasLimit(gasUsed types.GasUnits) types.GasUnits {
+	return api.msgEstimator.GasLimit(gasUsed)
+}
+
+// MessageTrace returns the execution trace of an on-chain message, replaying
+// the tipset that included it.
+func (api *API) MessageTrace(ctx context.Context, msgCid cid.Cid) (*vm.ExecutionTrace, error) {
+	return api.msgTracer.Trace(ctx, msgCid)
 }
 
 // StateView loads the state view for a tipset, i.e. the state *after* the application of the tipset's messages.
@@ -249,9 +361,7 @@ func (api *API) SignedMessageSend(ctx context.Context, smsg *types.SignedMessage
 
 // MessageFind returns a message and receipt from the blockchain, if it exists.
 func (api *API) MessageFind(ctx context.Context, mcid cid.Cid) (*msg.ChainMessage, bool, error) {
-	return api.msgWaiter.Find(ctx, func(msg *types.SignedMessage, msgCid cid.Cid) bool {
-		return msgCid.Equals(mcid)
-	})
+	return api.msgWaiter.Lookup(ctx, mcid)
 }
 
 // MessageWait invokes the callback when a message with the given cid appears on chain.
@@ -263,6 +373,12 @@ func (api *API) MessageWait(ctx context.Context, msgCid cid.Cid, cb func(*block.
 	return api.msgWaiter.Wait(ctx, msgCid, cb)
 }
 
+// MessageWatch streams messages from or to any of `addrs` as the tipsets
+// including them are applied to or reverted from the head chain, until ctx is done.
+func (api *API) MessageWatch(ctx context.Context, addrs []address.Address) (<-chan events.MessageEvent, error) {
+	return api.notifier.MessageEvents(ctx, addrs)
+}
+
 // NetworkGetBandwidthStats gets stats on the current bandwidth usage of the network
 func (api *API) NetworkGetBandwidthStats() metrics.Stats {
 	return api.network.GetBandwidthStats()
@@ -308,6 +424,11 @@ func (api *API) NetworkPeers(ctx context.Context, verbose, latency, streams bool
 	return api.network.Peers(ctx, verbose, latency, streams)
 }
 
+// NetworkPeerScores returns the sync behaviour scores of peers, lowest first.
+func (api *API) NetworkPeerScores() []discovery.PeerScore {
+	return api.peerScorer.Scores()
+}
+
 // SignBytes uses private key information associated with the given address to sign the given bytes.
 func (api *API) SignBytes(data []byte, addr address.Address) (crypto.Signature, error) {
 	return api.wallet.SignBytes(data, addr)
@@ -343,6 +464,28 @@ func (api *API) WalletExport(addrs []address.Address) ([]*crypto.KeyInfo, error)
 	return api.wallet.Export(addrs)
 }
 
+// WalletEncrypt seals the keys of a plaintext wallet with a passphrase. The
+// wallet is left locked.
+func (api *API) WalletEncrypt(passphrase string) error {
+	return api.wallet.Encrypt(passphrase, clock.NewSystemClock())
+}
+
+// WalletUnlock makes the keys of an encrypted wallet available for timeout,
+// or until WalletLock is called if timeout is zero.
+func (api *API) WalletUnlock(passphrase string, timeout time.Duration) error {
+	return api.wallet.Unlock(passphrase, timeout)
+}
+
+// WalletLock makes the keys of an encrypted wallet unavailable
+func (api *API) WalletLock() error {
+	return api.wallet.Lock()
+}
+
+// WalletChangePassphrase reseals the keys of an encrypted wallet with a new passphrase
+func (api *API) WalletChangePassphrase(oldPassphrase, newPassphrase string) error {
+	return api.wallet.ChangePassphrase(oldPassphrase, newPassphrase)
+}
+
 // DAGGetNode returns the associated DAG node for the passed in CID.
 func (api *API) DAGGetNode(ctx context.Context, ref string) (interface{}, error) {
 	return api.dag.GetNode(ctx, ref)
@@ -361,9 +504,19 @@ func (api *API) DAGCat(ctx context.Context, c cid.Cid) (io.Reader, error) {
 
 // DAGImportData adds data from an io reader to the merkledag and returns the
 // Cid of the given data. Once the data is in the DAG, it can fetched from the
-// node via Bitswap and a copy will be kept in the blockstore.
+// node via Bitswap and a copy will be kept in the piece blockstore. The data
+// is pinned so that garbage collection keeps it for deals.
 func (api *API) DAGImportData(ctx context.Context, data io.Reader) (ipld.Node, error) {
-	return api.dag.ImportData(ctx, data)
+	nd, err := api.dag.ImportData(ctx, data)
+	if err != nil {
+		return nil, err
+	}
+	if api.pins != nil {
+		if err := api.pins.Add(nd.Cid()); err != nil {
+			return nil, errors.Wrap(err, "failed to pin imported data")
+		}
+	}
+	return nd, nil
 }
 
 // PieceManager returns the piece manager
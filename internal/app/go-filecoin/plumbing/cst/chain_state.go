@@ -10,7 +10,6 @@ import (
 	"github.com/filecoin-project/specs-actors/actors/builtin"
 	initactor "github.com/filecoin-project/specs-actors/actors/builtin/init"
 	acrypto "github.com/filecoin-project/specs-actors/actors/crypto"
-	blocks "github.com/ipfs/go-block-format"
 	"github.com/ipfs/go-blockservice"
 	"github.com/ipfs/go-cid"
 	blockstore "github.com/ipfs/go-ipfs-blockstore"
@@ -38,10 +37,14 @@ var logStore = logging.Logger("plumbing/chain_store")
 type chainReadWriter interface {
 	GetHead() block.TipSetKey
 	GetGenesisBlock(ctx context.Context) (*block.Block, error)
+	GenesisCid() cid.Cid
 	GetTipSet(block.TipSetKey) (block.TipSet, error)
 	GetTipSetState(context.Context, block.TipSetKey) (vmstate.Tree, error)
 	GetTipSetStateRoot(block.TipSetKey) (cid.Cid, error)
+	GetTipSetReceiptsRoot(block.TipSetKey) (cid.Cid, error)
 	SetHead(context.Context, block.TipSet) error
+	PutTipSetMetadata(context.Context, *chain.TipSetMetadata) error
+	SeedMessageIndex(context.Context, abi.ChainEpoch, []block.TipSet) error
 	ReadOnlyStateStore() cborutil.ReadOnlyIpldStore
 }
 
@@ -65,18 +68,6 @@ func (as *actorStore) Context() context.Context {
 	return as.ctx
 }
 
-type carStore struct {
-	store blockstore.Blockstore
-}
-
-func newCarStore(bs blockstore.Blockstore) *carStore {
-	return &carStore{bs}
-}
-
-func (cs *carStore) Put(b blocks.Block) error {
-	return cs.store.Put(b)
-}
-
 type actorNotRegisteredError struct{}
 
 func (e actorNotRegisteredError) Error() string {
@@ -289,31 +280,52 @@ func (chn *ChainStateReadWriter) ReadOnlyStateStore() cborutil.ReadOnlyIpldStore
 	return chn.readWriter.ReadOnlyStateStore()
 }
 
-// ChainExport exports the chain from `head` up to and including the genesis block to `out`
-func (chn *ChainStateReadWriter) ChainExport(ctx context.Context, head block.TipSetKey, out io.Writer) error {
+// ChainExport exports the chain from `head` up to and including the genesis block to `out`.
+// If `recentStateRoots` is positive only the state trees and messages of that many of the
+// most recent tipsets are exported, along with every header back to genesis.
+func (chn *ChainStateReadWriter) ChainExport(ctx context.Context, head block.TipSetKey, out io.Writer, recentStateRoots int) error {
 	headTS, err := chn.GetTipSet(head)
 	if err != nil {
 		return err
 	}
 	logStore.Infof("starting CAR file export: %s", head.String())
-	if err := chain.Export(ctx, headTS, chn.readWriter, chn.messageProvider, chn, out); err != nil {
+	if recentStateRoots > 0 {
+		err = chain.ExportRecent(ctx, headTS, recentStateRoots, chn.readWriter, chn.messageProvider, chn, out)
+	} else {
+		err = chain.Export(ctx, headTS, chn.readWriter, chn.messageProvider, chn, out)
+	}
+	if err != nil {
 		return err
 	}
 	logStore.Infof("exported CAR file with head: %s", head.String())
 	return nil
 }
 
-// ChainImport imports a chain from `in`.
-func (chn *ChainStateReadWriter) ChainImport(ctx context.Context, in io.Reader) (block.TipSetKey, error) {
+// ChainImport imports a chain from `in` and verifies it links back to the node's genesis
+// block. The state transitions of the `recompute` most recent tipsets, at least one, are
+// run again with `transitioner` and checked against the imported state. A chain that
+// fails verification is discarded.
+func (chn *ChainStateReadWriter) ChainImport(ctx context.Context, in io.Reader, transitioner chain.StateTransitioner, recompute int) (block.TipSetKey, error) {
 	logStore.Info("starting CAR file import")
-	headKey, err := chain.Import(ctx, newCarStore(chn.bstore), in)
+	headKey, err := chain.ImportSnapshot(ctx, chn.bstore, in, chn.readWriter, transitioner, recompute)
 	if err != nil {
-		return block.UndefTipSet.Key(), err
+		return block.UndefTipSet.Key(), errors.Wrap(err, "imported chain failed verification")
 	}
 	logStore.Infof("imported CAR file with head: %s", headKey)
 	return headKey, nil
 }
 
+// ChainReplay runs the state transition of the tipset with key `key` again
+// with `transitioner`, from its parent state, and reports whether it results
+// in the state and receipts stored for the tipset.
+func (chn *ChainStateReadWriter) ChainReplay(ctx context.Context, key block.TipSetKey, transitioner chain.StateTransitioner) (*chain.ReplayResult, error) {
+	ts, err := chn.readWriter.GetTipSet(key)
+	if err != nil {
+		return nil, err
+	}
+	return chain.ReplayTipSet(ctx, ts, chn.readWriter, chain.NewMessageStore(chn.bstore), transitioner)
+}
+
 // ChainStateTree returns the state tree as a slice of IPLD nodes at the passed stateroot cid `c`.
 func (chn *ChainStateReadWriter) ChainStateTree(ctx context.Context, c cid.Cid) ([]format.Node, error) {
 	offl := offline.Exchange(chn.bstore)
@@ -322,6 +334,12 @@ func (chn *ChainStateReadWriter) ChainStateTree(ctx context.Context, c cid.Cid)
 	return dag.NewDAG(dserv).RecursiveGet(ctx, c)
 }
 
+// StateDiff returns the actors added, removed or changed from the state with
+// root `rootA` to the state with root `rootB`.
+func (chn *ChainStateReadWriter) StateDiff(ctx context.Context, rootA, rootB cid.Cid) ([]*state.ActorStateDiff, error) {
+	return state.DiffStates(ctx, chn, rootA, rootB)
+}
+
 func (chn *ChainStateReadWriter) StateView(key block.TipSetKey) (*state.View, error) {
 	root, err := chn.readWriter.GetTipSetStateRoot(key)
 	if err != nil {
@@ -9,6 +9,8 @@ import (
 type chainSync interface {
 	BlockProposer() chainsync.BlockProposer
 	Status() status.Status
+	BadTipSets() []*chainsync.BadTipSet
+	UnmarkBadTipSet(block.TipSetKey) (bool, error)
 }
 
 // ChainSyncProvider provides access to chain sync operations and their status.
@@ -36,3 +38,13 @@ func (chs *ChainSyncProvider) Status() status.Status {
 func (chs *ChainSyncProvider) HandleNewTipSet(ci *block.ChainInfo) error {
 	return chs.sync.BlockProposer().SendOwnBlock(ci)
 }
+
+// BadTipSets returns the tipsets that failed validation and are not synced again.
+func (chs *ChainSyncProvider) BadTipSets() []*chainsync.BadTipSet {
+	return chs.sync.BadTipSets()
+}
+
+// UnmarkBadTipSet forgets that the tipset with key `key` failed validation.
+func (chs *ChainSyncProvider) UnmarkBadTipSet(key block.TipSetKey) (bool, error) {
+	return chs.sync.UnmarkBadTipSet(key)
+}
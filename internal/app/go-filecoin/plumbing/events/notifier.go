@@ -0,0 +1,351 @@
+package events
+
+import (
+	"context"
+	"encoding/json"
+
+	"github.com/cskr/pubsub"
+	"github.com/filecoin-project/go-address"
+	"github.com/filecoin-project/specs-actors/actors/abi"
+	"github.com/ipfs/go-cid"
+	cbor "github.com/ipfs/go-ipld-cbor"
+	logging "github.com/ipfs/go-log"
+	"github.com/pkg/errors"
+
+	"github.com/filecoin-project/go-filecoin/internal/pkg/block"
+	"github.com/filecoin-project/go-filecoin/internal/pkg/chain"
+	appstate "github.com/filecoin-project/go-filecoin/internal/pkg/state"
+	"github.com/filecoin-project/go-filecoin/internal/pkg/types"
+	"github.com/filecoin-project/go-filecoin/internal/pkg/vm"
+)
+
+var log = logging.Logger("events")
+
+// HeadChangeType says whether a tipset joins or leaves the head chain.
+type HeadChangeType string
+
+const (
+	// HeadApply is the type of changes adding a tipset to the head chain.
+	HeadApply = HeadChangeType("apply")
+	// HeadRevert is the type of changes removing a tipset from the head chain.
+	HeadRevert = HeadChangeType("revert")
+)
+
+// HeadChange is a tipset applied to or reverted from the head chain.
+type HeadChange struct {
+	Type   HeadChangeType
+	TipSet block.TipSet
+}
+
+// headChangeJSON is the JSON representation of a HeadChange.
+type headChangeJSON struct {
+	Type   HeadChangeType  `json:"type"`
+	Key    block.TipSetKey `json:"key"`
+	Height abi.ChainEpoch  `json:"height"`
+	Blocks []*block.Block  `json:"blocks"`
+}
+
+// MarshalJSON serializes the change with the key, height and blocks of its tipset.
+func (hc HeadChange) MarshalJSON() ([]byte, error) {
+	height, err := hc.TipSet.Height()
+	if err != nil {
+		return nil, err
+	}
+	return json.Marshal(headChangeJSON{hc.Type, hc.TipSet.Key(), height, hc.TipSet.ToSlice()})
+}
+
+// UnmarshalJSON parses a change serialized by MarshalJSON.
+func (hc *HeadChange) UnmarshalJSON(b []byte) error {
+	var raw headChangeJSON
+	if err := json.Unmarshal(b, &raw); err != nil {
+		return err
+	}
+	ts, err := block.NewTipSet(raw.Blocks...)
+	if err != nil {
+		return err
+	}
+	hc.Type = raw.Type
+	hc.TipSet = ts
+	return nil
+}
+
+// MessageEvent is a message from or to a watched address in a tipset applied
+// to or reverted from the head chain.
+type MessageEvent struct {
+	Type    HeadChangeType       `json:"type"`
+	TipSet  block.TipSetKey      `json:"tipset"`
+	Height  abi.ChainEpoch       `json:"height"`
+	Cid     cid.Cid              `json:"cid"`
+	Message *types.SignedMessage `json:"message"`
+	Receipt *vm.MessageReceipt   `json:"receipt"`
+}
+
+// Abstracts over a store of blockchain state.
+type notifierChainReader interface {
+	GetHead() block.TipSetKey
+	GetTipSet(block.TipSetKey) (block.TipSet, error)
+	GetTipSetReceiptsRoot(block.TipSetKey) (cid.Cid, error)
+	GetTipSetStateRoot(block.TipSetKey) (cid.Cid, error)
+	HeadEvents() *pubsub.PubSub
+}
+
+// Notifier turns head events of the chain store into streams of head changes
+// and of the messages of watched addresses.
+type Notifier struct {
+	chainReader     notifierChainReader
+	messageProvider chain.MessageProvider
+	cst             cbor.IpldStore
+}
+
+// NewNotifier returns a new Notifier.
+func NewNotifier(chainReader notifierChainReader, messages chain.MessageProvider, cst cbor.IpldStore) *Notifier {
+	return &Notifier{
+		chainReader:     chainReader,
+		messageProvider: messages,
+		cst:             cst,
+	}
+}
+
+// HeadChanges streams batches of head changes until ctx is done. The first
+// batch applies the current head. Each later batch reverts the tipsets of the
+// previous head chain down to the common ancestor with the new head, highest
+// first, and then applies those of the new head chain, lowest first.
+// Head events are never held up by a slow consumer: changes it has not yet
+// received are coalesced into one batch leading from the last head it was
+// sent to the latest one.
+func (n *Notifier) HeadChanges(ctx context.Context) (<-chan []HeadChange, error) {
+	ch := n.chainReader.HeadEvents().Sub(chain.NewHeadTopic)
+	head, err := n.chainReader.GetTipSet(n.chainReader.GetHead())
+	if err != nil {
+		n.chainReader.HeadEvents().Unsub(ch, chain.NewHeadTopic)
+		return nil, err
+	}
+
+	out := make(chan []HeadChange)
+	go func() {
+		defer close(out)
+		defer n.chainReader.HeadEvents().Unsub(ch, chain.NewHeadTopic)
+
+		var err error
+		sent := block.UndefTipSet
+		pending := []HeadChange{{Type: HeadApply, TipSet: head}}
+		for {
+			// Sending on a nil channel blocks, so there is nothing to send
+			// until the head moves away from the one last sent.
+			var sendCh chan<- []HeadChange
+			if len(pending) > 0 {
+				sendCh = out
+			}
+
+			select {
+			case <-ctx.Done():
+				return
+			case sendCh <- pending:
+				sent = head
+				pending = nil
+			case raw, more := <-ch:
+				if !more {
+					return
+				}
+				newHead, ok := raw.(block.TipSet)
+				if !ok {
+					log.Warnf("unexpected type in head events: %T", raw)
+					continue
+				}
+				head = newHead
+				if !sent.Defined() {
+					pending = []HeadChange{{Type: HeadApply, TipSet: head}}
+					continue
+				}
+				pending, err = n.diff(ctx, sent, head)
+				if err != nil {
+					log.Errorf("failed computing head change to %s from %s: %s", head.Key(), sent.Key(), err)
+					return
+				}
+			}
+		}
+	}()
+	return out, nil
+}
+
+// diff returns the head changes from the chain of `oldHead` to that of `newHead`.
+func (n *Notifier) diff(ctx context.Context, oldHead, newHead block.TipSet) ([]HeadChange, error) {
+	if oldHead.Equals(newHead) {
+		return nil, nil
+	}
+	oldTips, newTips, err := chain.CollectTipsToCommonAncestor(ctx, n.chainReader, oldHead, newHead)
+	if err != nil {
+		return nil, err
+	}
+	var changes []HeadChange
+	for _, ts := range oldTips {
+		changes = append(changes, HeadChange{Type: HeadRevert, TipSet: ts})
+	}
+	for i := len(newTips) - 1; i >= 0; i-- {
+		changes = append(changes, HeadChange{Type: HeadApply, TipSet: newTips[i]})
+	}
+	return changes, nil
+}
+
+// MessageEvents streams the messages sent from or to any of `addrs` in tipsets
+// applied to or reverted from the head chain after the call, until ctx is done.
+func (n *Notifier) MessageEvents(ctx context.Context, addrs []address.Address) (<-chan MessageEvent, error) {
+	watched := make(map[address.Address]struct{}, len(addrs))
+	for _, addr := range addrs {
+		watched[addr] = struct{}{}
+	}
+
+	changesCh, err := n.HeadChanges(ctx)
+	if err != nil {
+		return nil, err
+	}
+	// The first batch is the current head, messages in it are not new.
+	select {
+	case <-changesCh:
+	case <-ctx.Done():
+		return nil, ctx.Err()
+	}
+
+	out := make(chan MessageEvent, 16)
+	go func() {
+		defer close(out)
+		for changes := range changesCh {
+			for _, change := range changes {
+				events, err := n.messageEvents(ctx, change, watched)
+				if err != nil {
+					log.Errorf("failed loading messages of %s: %s", change.TipSet.Key(), err)
+					return
+				}
+				for _, event := range events {
+					select {
+					case out <- event:
+					case <-ctx.Done():
+						return
+					}
+				}
+			}
+		}
+	}()
+	return out, nil
+}
+
+// messageEvents returns events for the messages of the changed tipset from or
+// to watched addresses, in the order in which they are executed.
+func (n *Notifier) messageEvents(ctx context.Context, change HeadChange, watched map[address.Address]struct{}) ([]MessageEvent, error) {
+	ts := change.TipSet
+	height, err := ts.Height()
+	if err != nil {
+		return nil, err
+	}
+	receiptsCid, err := n.chainReader.GetTipSetReceiptsRoot(ts.Key())
+	if err != nil {
+		return nil, err
+	}
+	receipts, err := n.messageProvider.LoadReceipts(ctx, receiptsCid)
+	if err != nil {
+		return nil, err
+	}
+
+	// A message may name an actor by its ID address or by its key address,
+	// so addresses are compared by their ID in the tipset's state.
+	stateRoot, err := n.chainReader.GetTipSetStateRoot(ts.Key())
+	if err != nil {
+		return nil, err
+	}
+	resolver := newIDResolver(ctx, appstate.NewView(n.cst, stateRoot))
+	watchedIDs := make(map[address.Address]struct{}, len(watched))
+	for addr := range watched {
+		watchedIDs[resolver.resolve(addr)] = struct{}{}
+	}
+
+	// Receipts follow the tipset's messages, BLS before secp within each
+	// block, with messages included by several blocks counted once.
+	var events []MessageEvent
+	seen := make(map[cid.Cid]struct{})
+	receiptIndex := 0
+	add := func(msgCid cid.Cid, unwrapped cid.Cid, smsg *types.SignedMessage) error {
+		if _, dup := seen[unwrapped]; dup {
+			return nil
+		}
+		seen[unwrapped] = struct{}{}
+		index := receiptIndex
+		receiptIndex++
+
+		_, fromWatched := watchedIDs[resolver.resolve(smsg.Message.From)]
+		_, toWatched := watchedIDs[resolver.resolve(smsg.Message.To)]
+		if !fromWatched && !toWatched {
+			return nil
+		}
+		if index >= len(receipts) {
+			return errors.Errorf("could not find message receipt at index %d", index)
+		}
+		events = append(events, MessageEvent{
+			Type:    change.Type,
+			TipSet:  ts.Key(),
+			Height:  height,
+			Cid:     msgCid,
+			Message: smsg,
+			Receipt: &receipts[index],
+		})
+		return nil
+	}
+
+	for i := 0; i < ts.Len(); i++ {
+		secpMsgs, blsMsgs, err := n.messageProvider.LoadMessages(ctx, ts.At(i).Messages.Cid)
+		if err != nil {
+			return nil, errors.Wrapf(err, "loading messages of block %s", ts.At(i).Cid())
+		}
+		for _, msg := range blsMsgs {
+			c, err := msg.Cid()
+			if err != nil {
+				return nil, err
+			}
+			if err := add(c, c, types.WrapBLSMessage(msg)); err != nil {
+				return nil, err
+			}
+		}
+		for _, msg := range secpMsgs {
+			c, err := msg.Cid()
+			if err != nil {
+				return nil, err
+			}
+			unwrapped, err := msg.Message.Cid()
+			if err != nil {
+				return nil, err
+			}
+			if err := add(c, unwrapped, msg); err != nil {
+				return nil, err
+			}
+		}
+	}
+	return events, nil
+}
+
+// idResolver resolves addresses to ID addresses in a state, caching the
+// results. Addresses it cannot resolve, such as those of actors not yet
+// created, are left as they are.
+type idResolver struct {
+	ctx   context.Context
+	view  *appstate.View
+	cache map[address.Address]address.Address
+}
+
+func newIDResolver(ctx context.Context, view *appstate.View) *idResolver {
+	return &idResolver{
+		ctx:   ctx,
+		view:  view,
+		cache: make(map[address.Address]address.Address),
+	}
+}
+
+func (r *idResolver) resolve(addr address.Address) address.Address {
+	if resolved, ok := r.cache[addr]; ok {
+		return resolved
+	}
+	resolved, err := r.view.InitResolveAddress(r.ctx, addr)
+	if err != nil {
+		resolved = addr
+	}
+	r.cache[addr] = resolved
+	return resolved
+}
@@ -0,0 +1,243 @@
+package events
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/filecoin-project/go-address"
+	"github.com/filecoin-project/specs-actors/actors/abi/big"
+	"github.com/filecoin-project/specs-actors/actors/builtin"
+	notinit "github.com/filecoin-project/specs-actors/actors/builtin/init"
+	"github.com/ipfs/go-cid"
+	hamt "github.com/ipfs/go-hamt-ipld"
+	cbor "github.com/ipfs/go-ipld-cbor"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/filecoin-project/go-filecoin/internal/pkg/block"
+	"github.com/filecoin-project/go-filecoin/internal/pkg/chain"
+	"github.com/filecoin-project/go-filecoin/internal/pkg/enccid"
+	"github.com/filecoin-project/go-filecoin/internal/pkg/encoding"
+	"github.com/filecoin-project/go-filecoin/internal/pkg/repo"
+	tf "github.com/filecoin-project/go-filecoin/internal/pkg/testhelpers/testflags"
+	"github.com/filecoin-project/go-filecoin/internal/pkg/types"
+	"github.com/filecoin-project/go-filecoin/internal/pkg/vm"
+	"github.com/filecoin-project/go-filecoin/internal/pkg/vm/actor"
+	"github.com/filecoin-project/go-filecoin/internal/pkg/vm/state"
+)
+
+func TestHeadChanges(t *testing.T) {
+	tf.UnitTest(t)
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	builder, store := setupStore(ctx, t)
+	genesis := builder.RequireTipSet(store.GetHead())
+	notifier := NewNotifier(store, builder, cbor.NewMemCborStore())
+
+	changes, err := notifier.HeadChanges(ctx)
+	require.NoError(t, err)
+	assertChanges(t, changes, HeadChange{HeadApply, genesis})
+
+	main1 := builder.AppendOn(genesis, 1)
+	main2 := builder.AppendOn(main1, 1)
+	putTipSet(ctx, t, store, builder, main1, nil)
+	putTipSet(ctx, t, store, builder, main2, nil)
+	require.NoError(t, store.SetHead(ctx, main2))
+	assertChanges(t, changes, HeadChange{HeadApply, main1}, HeadChange{HeadApply, main2})
+
+	// A reorg reverts the old chain from the top before applying the new one.
+	fork1 := builder.AppendOn(genesis, 2)
+	putTipSet(ctx, t, store, builder, fork1, nil)
+	require.NoError(t, store.SetHead(ctx, fork1))
+	assertChanges(t, changes, HeadChange{HeadRevert, main2}, HeadChange{HeadRevert, main1}, HeadChange{HeadApply, fork1})
+}
+
+func TestHeadChangesCoalescesForSlowConsumer(t *testing.T) {
+	tf.UnitTest(t)
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	builder, store := setupStore(ctx, t)
+	genesis := builder.RequireTipSet(store.GetHead())
+	notifier := NewNotifier(store, builder, cbor.NewMemCborStore())
+
+	changes, err := notifier.HeadChanges(ctx)
+	require.NoError(t, err)
+	assertChanges(t, changes, HeadChange{HeadApply, genesis})
+
+	// Moving the head many times does not wait for the consumer.
+	var tips []block.TipSet
+	head := genesis
+	for i := 0; i < 64; i++ {
+		head = builder.AppendOn(head, 1)
+		putTipSet(ctx, t, store, builder, head, nil)
+		tips = append(tips, head)
+	}
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		for _, ts := range tips {
+			assert.NoError(t, store.SetHead(ctx, ts))
+		}
+	}()
+	select {
+	case <-done:
+	case <-time.After(5 * time.Second):
+		t.Fatal("timed out setting heads")
+	}
+
+	// The batches received in the meantime still apply every tipset in order.
+	var applied []block.TipSet
+	for len(applied) < len(tips) {
+		select {
+		case batch := <-changes:
+			for _, change := range batch {
+				assert.Equal(t, HeadApply, change.Type)
+				applied = append(applied, change.TipSet)
+			}
+		case <-time.After(5 * time.Second):
+			t.Fatal("timed out waiting for head changes")
+		}
+	}
+	assert.Equal(t, tips, applied)
+}
+
+func TestMessageEvents(t *testing.T) {
+	tf.UnitTest(t)
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	builder, store := setupStore(ctx, t)
+	genesis := builder.RequireTipSet(store.GetHead())
+	notifier := NewNotifier(store, builder, cbor.NewMemCborStore())
+
+	mm := vm.NewMessageMaker(t, types.MustGenerateKeyInfo(2, 42))
+	alice, bob := mm.Addresses()[0], mm.Addresses()[1]
+	fromAlice := mm.NewSignedMessage(alice, 0)
+	fromBob := mm.NewUnsignedMessage(bob, 0)
+
+	msgEvents, err := notifier.MessageEvents(ctx, []address.Address{alice})
+	require.NoError(t, err)
+
+	main1 := builder.BuildOneOn(genesis, func(bb *chain.BlockBuilder) {
+		bb.AddMessages([]*types.SignedMessage{fromAlice}, []*types.UnsignedMessage{fromBob})
+	})
+	// BLS messages are executed first.
+	putTipSet(ctx, t, store, builder, main1, []vm.MessageReceipt{{ReturnValue: []byte("bob")}, {ReturnValue: []byte("alice")}})
+	require.NoError(t, store.SetHead(ctx, main1))
+
+	fromAliceCid, err := fromAlice.Cid()
+	require.NoError(t, err)
+	event := requireEvent(t, msgEvents)
+	assert.Equal(t, HeadApply, event.Type)
+	assert.Equal(t, main1.Key(), event.TipSet)
+	assert.Equal(t, fromAliceCid, event.Cid)
+	assert.Equal(t, []byte("alice"), event.Receipt.ReturnValue)
+
+	// The message is reverted by a reorg.
+	fork1 := builder.AppendOn(genesis, 2)
+	putTipSet(ctx, t, store, builder, fork1, nil)
+	require.NoError(t, store.SetHead(ctx, fork1))
+	event = requireEvent(t, msgEvents)
+	assert.Equal(t, HeadRevert, event.Type)
+	assert.Equal(t, main1.Key(), event.TipSet)
+	assert.Equal(t, fromAliceCid, event.Cid)
+}
+
+func TestMessageEventsResolvesAddresses(t *testing.T) {
+	tf.UnitTest(t)
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	builder, store := setupStore(ctx, t)
+	genesis := builder.RequireTipSet(store.GetHead())
+	cst := cbor.NewMemCborStore()
+	notifier := NewNotifier(store, builder, cst)
+
+	mm := vm.NewMessageMaker(t, types.MustGenerateKeyInfo(1, 42))
+	alice := mm.Addresses()[0]
+	aliceID, err := address.NewIDAddress(100)
+	require.NoError(t, err)
+	fromAlice := mm.NewSignedMessage(alice, 0)
+
+	// Watching the ID address matches messages from the key address it is mapped to.
+	msgEvents, err := notifier.MessageEvents(ctx, []address.Address{aliceID})
+	require.NoError(t, err)
+
+	main1 := builder.BuildOneOn(genesis, func(bb *chain.BlockBuilder) {
+		bb.AddMessages([]*types.SignedMessage{fromAlice}, []*types.UnsignedMessage{})
+	})
+	receiptsCid, err := builder.StoreReceipts(ctx, []vm.MessageReceipt{{ReturnValue: []byte("alice")}})
+	require.NoError(t, err)
+	require.NoError(t, store.PutTipSetMetadata(ctx, &chain.TipSetMetadata{
+		TipSet:          main1,
+		TipSetStateRoot: putAddressState(ctx, t, cst, map[address.Address]uint64{alice: 100}),
+		TipSetReceipts:  receiptsCid,
+	}))
+	require.NoError(t, store.SetHead(ctx, main1))
+
+	fromAliceCid, err := fromAlice.Cid()
+	require.NoError(t, err)
+	event := requireEvent(t, msgEvents)
+	assert.Equal(t, HeadApply, event.Type)
+	assert.Equal(t, fromAliceCid, event.Cid)
+}
+
+// putAddressState stores a state tree holding only an init actor mapping `ids`.
+func putAddressState(ctx context.Context, t *testing.T, cst cbor.IpldStore, ids map[address.Address]uint64) cid.Cid {
+	node := hamt.NewNode(cst)
+	for addr, id := range ids {
+		raw, err := encoding.Encode(id)
+		require.NoError(t, err)
+		require.NoError(t, node.SetRaw(ctx, string(addr.Bytes()), raw))
+	}
+	require.NoError(t, node.Flush(ctx))
+	addressMap, err := cst.Put(ctx, node)
+	require.NoError(t, err)
+	head, err := cst.Put(ctx, &notinit.State{AddressMap: addressMap, NextID: 101, NetworkName: "net"})
+	require.NoError(t, err)
+
+	tree := state.NewState(cst)
+	initActor := actor.NewActor(builtin.InitActorCodeID, big.Zero())
+	initActor.Head = enccid.NewCid(head)
+	require.NoError(t, tree.SetActor(ctx, builtin.InitActorAddr, initActor))
+	root, err := tree.Commit(ctx)
+	require.NoError(t, err)
+	return root
+}
+
+func setupStore(ctx context.Context, t *testing.T) (*chain.Builder, *chain.Store) {
+	builder := chain.NewBuilder(t, address.Undef)
+	genesis := builder.NewGenesis()
+	store := chain.NewStore(repo.NewInMemoryRepo().ChainDatastore(), cbor.NewMemCborStore(), chain.NewStatusReporter(), genesis.At(0).Cid())
+	putTipSet(ctx, t, store, builder, genesis, nil)
+	require.NoError(t, store.SetHead(ctx, genesis))
+	return builder, store
+}
+
+func putTipSet(ctx context.Context, t *testing.T, store *chain.Store, builder *chain.Builder, ts block.TipSet, receipts []vm.MessageReceipt) {
+	receiptsCid, err := builder.StoreReceipts(ctx, receipts)
+	require.NoError(t, err)
+	require.NoError(t, store.PutTipSetMetadata(ctx, &chain.TipSetMetadata{
+		TipSet:          ts,
+		TipSetStateRoot: builder.StateForKey(ts.Key()),
+		TipSetReceipts:  receiptsCid,
+	}))
+}
+
+func assertChanges(t *testing.T, ch <-chan []HeadChange, expected ...HeadChange) {
+	select {
+	case changes := <-ch:
+		assert.Equal(t, expected, changes)
+	case <-time.After(5 * time.Second):
+		t.Fatal("timed out waiting for head changes")
+	}
+}
+
+func requireEvent(t *testing.T, ch <-chan MessageEvent) MessageEvent {
+	select {
+	case event := <-ch:
+		return event
+	case <-time.After(5 * time.Second):
+		t.Fatal("timed out waiting for message event")
+	}
+	return MessageEvent{}
+}
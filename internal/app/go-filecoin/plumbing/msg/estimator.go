@@ -0,0 +1,151 @@
+package msg
+
+import (
+	"context"
+	"sort"
+
+	"github.com/filecoin-project/go-address"
+	"github.com/filecoin-project/specs-actors/actors/abi"
+	specsbig "github.com/filecoin-project/specs-actors/actors/abi/big"
+	"github.com/pkg/errors"
+
+	"github.com/filecoin-project/go-filecoin/internal/pkg/block"
+	"github.com/filecoin-project/go-filecoin/internal/pkg/chain"
+	"github.com/filecoin-project/go-filecoin/internal/pkg/config"
+	"github.com/filecoin-project/go-filecoin/internal/pkg/types"
+	"github.com/filecoin-project/go-filecoin/internal/pkg/vm"
+)
+
+// Abstracts over a store of blockchain state.
+type estimatorChainReader interface {
+	GetHead() block.TipSetKey
+	GetTipSet(block.TipSetKey) (block.TipSet, error)
+}
+
+type messageRunner interface {
+	Preview(ctx context.Context, optFrom, to address.Address, value types.AttoFIL, method abi.MethodNum, params ...interface{}) (types.GasUnits, *vm.ExecutionTrace, error)
+}
+
+// GasEstimate is the gas price and limit suggested for a message.
+type GasEstimate struct {
+	// GasUsed is the gas the message used when run against the head state.
+	GasUsed  types.GasUnits
+	GasLimit types.GasUnits
+	GasPrice types.AttoFIL
+}
+
+// Estimator suggests the gas limit and price of messages. The gas limit is
+// the gas a message uses when run against the head state, plus a safety
+// margin, as the state it is applied to may differ. The gas price is a
+// percentile of the gas prices of the messages included in recent tipsets.
+type Estimator struct {
+	chainReader     estimatorChainReader
+	messageProvider chain.MessageProvider
+	runner          messageRunner
+	cfg             *config.MessageConfig
+}
+
+// NewEstimator constructs an Estimator.
+func NewEstimator(chainReader estimatorChainReader, messages chain.MessageProvider, runner messageRunner, cfg *config.MessageConfig) *Estimator {
+	return &Estimator{
+		chainReader:     chainReader,
+		messageProvider: messages,
+		runner:          runner,
+		cfg:             cfg,
+	}
+}
+
+// Estimate runs a message transferring `value` against the head state and
+// suggests its gas limit and price. Fails if the message fails in the vm, as no gas limit makes it
+// succeed.
+func (e *Estimator) Estimate(ctx context.Context, from, to address.Address, value types.AttoFIL, method abi.MethodNum, params ...interface{}) (*GasEstimate, error) {
+	gasUsed, trace, err := e.runner.Preview(ctx, from, to, value, method, params...)
+	if err != nil {
+		return nil, err
+	}
+	if trace.ExitCode.IsError() {
+		return nil, errors.Errorf("message failed with exit code %d", trace.ExitCode)
+	}
+	return e.EstimateFromGasUsed(ctx, gasUsed)
+}
+
+// EstimateFromGasUsed suggests the gas limit and price of a message known to
+// use `gasUsed` against the head state.
+func (e *Estimator) EstimateFromGasUsed(ctx context.Context, gasUsed types.GasUnits) (*GasEstimate, error) {
+	price, err := e.GasPrice(ctx)
+	if err != nil {
+		return nil, err
+	}
+	return &GasEstimate{
+		GasUsed:  gasUsed,
+		GasLimit: e.GasLimit(gasUsed),
+		GasPrice: price,
+	}, nil
+}
+
+// GasLimit returns `gasUsed` increased by the configured margin, within the
+// block gas limit.
+func (e *Estimator) GasLimit(gasUsed types.GasUnits) types.GasUnits {
+	limit := uint64(gasUsed) * (100 + uint64(e.cfg.GasLimitMarginPercent)) / 100
+	if limit > uint64(types.BlockGasLimit) {
+		return types.BlockGasLimit
+	}
+	return types.GasUnits(limit)
+}
+
+// GasPrice returns the configured percentile of the gas prices of the
+// messages included in the tipsets of the lookback window, and at least the
+// configured minimum.
+func (e *Estimator) GasPrice(ctx context.Context) (types.AttoFIL, error) {
+	head, err := e.chainReader.GetTipSet(e.chainReader.GetHead())
+	if err != nil {
+		return types.ZeroAttoFIL, errors.Wrap(err, "failed to get head tipset")
+	}
+
+	var prices []types.AttoFIL
+	iter := chain.IterAncestors(ctx, e.chainReader, head)
+	for i := uint(0); i < e.cfg.GasPriceLookback && !iter.Complete(); i++ {
+		ts := iter.Value()
+		for j := 0; j < ts.Len(); j++ {
+			secpMsgs, blsMsgs, err := e.messageProvider.LoadMessages(ctx, ts.At(j).Messages.Cid)
+			if err != nil {
+				return types.ZeroAttoFIL, errors.Wrapf(err, "failed to load messages of block %s", ts.At(j).Cid())
+			}
+			for _, msg := range blsMsgs {
+				prices = append(prices, msg.GasPrice)
+			}
+			for _, msg := range secpMsgs {
+				prices = append(prices, msg.Message.GasPrice)
+			}
+		}
+		if err := iter.Next(); err != nil {
+			return types.ZeroAttoFIL, err
+		}
+	}
+
+	price := percentile(prices, e.cfg.GasPricePercentile)
+	if price.Nil() || price.LessThan(e.cfg.MinGasPrice) {
+		return e.cfg.MinGasPrice, nil
+	}
+	return price, nil
+}
+
+// percentile returns the `p`th percentile of `prices`, by the nearest-rank
+// method, or a nil amount if there are no prices. Percentiles above 100 are
+// taken as 100.
+func percentile(prices []types.AttoFIL, p uint) types.AttoFIL {
+	if len(prices) == 0 {
+		return specsbig.Int{}
+	}
+	if p > 100 {
+		p = 100
+	}
+	sort.Slice(prices, func(i, j int) bool {
+		return prices[i].LessThan(prices[j])
+	})
+	rank := (uint(len(prices))*p + 99) / 100
+	if rank == 0 {
+		rank = 1
+	}
+	return prices[rank-1]
+}
@@ -0,0 +1,135 @@
+package msg
+
+import (
+	"context"
+	"testing"
+
+	"github.com/filecoin-project/go-address"
+	"github.com/filecoin-project/specs-actors/actors/abi"
+	"github.com/filecoin-project/specs-actors/actors/builtin"
+	"github.com/filecoin-project/specs-actors/actors/runtime/exitcode"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/filecoin-project/go-filecoin/internal/pkg/chain"
+	"github.com/filecoin-project/go-filecoin/internal/pkg/config"
+	"github.com/filecoin-project/go-filecoin/internal/pkg/message"
+	tf "github.com/filecoin-project/go-filecoin/internal/pkg/testhelpers/testflags"
+	"github.com/filecoin-project/go-filecoin/internal/pkg/types"
+	"github.com/filecoin-project/go-filecoin/internal/pkg/vm"
+)
+
+type fakeRunner struct {
+	gasUsed  types.GasUnits
+	exitCode exitcode.ExitCode
+}
+
+func (r *fakeRunner) Preview(_ context.Context, _, _ address.Address, _ types.AttoFIL, _ abi.MethodNum, _ ...interface{}) (types.GasUnits, *vm.ExecutionTrace, error) {
+	return r.gasUsed, &vm.ExecutionTrace{ExitCode: r.exitCode}, nil
+}
+
+func TestEstimator(t *testing.T) {
+	tf.UnitTest(t)
+	ctx := context.Background()
+
+	ki := types.MustGenerateMixedKeyInfo(1, 1)
+	mm := vm.NewMessageMaker(t, ki)
+	blsAddr, secpAddr := mm.Addresses()[0], mm.Addresses()[1]
+
+	// Builds a chain whose tipsets include messages with gas prices `prices`,
+	// alternately from a BLS and a secp sender.
+	buildChain := func(prices ...[]int64) *message.FakeProvider {
+		provider := message.NewFakeProvider(t)
+		head := provider.Builder.NewGenesis()
+		nonce := uint64(0)
+		for _, tipPrices := range prices {
+			tipPrices := tipPrices
+			head = provider.Builder.BuildOneOn(head, func(b *chain.BlockBuilder) {
+				var secpMsgs []*types.SignedMessage
+				var blsMsgs []*types.UnsignedMessage
+				for i, price := range tipPrices {
+					if i%2 == 0 {
+						msg := mm.NewUnsignedMessage(blsAddr, nonce)
+						msg.GasPrice = types.NewGasPrice(price)
+						blsMsgs = append(blsMsgs, msg)
+					} else {
+						msg := mm.NewUnsignedMessage(secpAddr, nonce)
+						msg.GasPrice = types.NewGasPrice(price)
+						smsg, err := types.NewSignedMessage(*msg, mm.Signer())
+						require.NoError(t, err)
+						secpMsgs = append(secpMsgs, smsg)
+					}
+					nonce++
+				}
+				b.AddMessages(secpMsgs, blsMsgs)
+			})
+		}
+		provider.SetHead(head.Key())
+		return provider
+	}
+	newEstimator := func(provider *message.FakeProvider, runner *fakeRunner) *Estimator {
+		cfg := config.NewDefaultConfig().Message
+		cfg.MinGasPrice = types.NewGasPrice(2)
+		return NewEstimator(provider, provider, runner, cfg)
+	}
+
+	t.Run("gas limit adds the margin to the gas used", func(t *testing.T) {
+		provider := buildChain([]int64{5})
+		estimate, err := newEstimator(provider, &fakeRunner{gasUsed: 1000}).Estimate(ctx, secpAddr, blsAddr, types.ZeroAttoFIL, builtin.MethodSend)
+		require.NoError(t, err)
+		assert.Equal(t, types.GasUnits(1000), estimate.GasUsed)
+		assert.Equal(t, types.GasUnits(1250), estimate.GasLimit)
+		assert.Equal(t, types.NewGasPrice(5), estimate.GasPrice)
+	})
+
+	t.Run("gas limit is capped at the block gas limit", func(t *testing.T) {
+		provider := buildChain()
+		estimator := newEstimator(provider, &fakeRunner{})
+		assert.Equal(t, types.BlockGasLimit, estimator.GasLimit(types.BlockGasLimit-1))
+	})
+
+	t.Run("failing message is not estimated", func(t *testing.T) {
+		provider := buildChain()
+		_, err := newEstimator(provider, &fakeRunner{gasUsed: 1000, exitCode: exitcode.ErrIllegalArgument}).Estimate(ctx, secpAddr, blsAddr, types.ZeroAttoFIL, builtin.MethodSend)
+		assert.Error(t, err)
+	})
+
+	t.Run("gas price is a percentile of recent messages", func(t *testing.T) {
+		provider := buildChain([]int64{10, 3}, []int64{7}, []int64{4, 8, 6})
+		estimator := newEstimator(provider, &fakeRunner{})
+
+		price, err := estimator.GasPrice(ctx)
+		require.NoError(t, err)
+		assert.Equal(t, types.NewGasPrice(6), price)
+
+		estimator.cfg.GasPricePercentile = 100
+		price, err = estimator.GasPrice(ctx)
+		require.NoError(t, err)
+		assert.Equal(t, types.NewGasPrice(10), price)
+
+		// Percentiles above 100 are taken as 100.
+		estimator.cfg.GasPricePercentile = 250
+		price, err = estimator.GasPrice(ctx)
+		require.NoError(t, err)
+		assert.Equal(t, types.NewGasPrice(10), price)
+
+		// The oldest tipset falls out of the lookback window.
+		estimator.cfg.GasPriceLookback = 2
+		price, err = estimator.GasPrice(ctx)
+		require.NoError(t, err)
+		assert.Equal(t, types.NewGasPrice(8), price)
+	})
+
+	t.Run("gas price is at least the minimum", func(t *testing.T) {
+		estimator := newEstimator(buildChain([]int64{1, 1}), &fakeRunner{})
+		price, err := estimator.GasPrice(ctx)
+		require.NoError(t, err)
+		assert.Equal(t, types.NewGasPrice(2), price)
+
+		// Without messages to learn from.
+		estimator = newEstimator(buildChain(), &fakeRunner{})
+		price, err = estimator.GasPrice(ctx)
+		require.NoError(t, err)
+		assert.Equal(t, types.NewGasPrice(2), price)
+	})
+}
@@ -5,11 +5,16 @@ import (
 
 	"github.com/filecoin-project/go-address"
 	"github.com/filecoin-project/specs-actors/actors/abi"
+	"github.com/ipfs/go-cid"
 	bstore "github.com/ipfs/go-ipfs-blockstore"
 	cbor "github.com/ipfs/go-ipld-cbor"
+	"github.com/pkg/errors"
 
 	"github.com/filecoin-project/go-filecoin/internal/pkg/block"
+	"github.com/filecoin-project/go-filecoin/internal/pkg/encoding"
+	appstate "github.com/filecoin-project/go-filecoin/internal/pkg/state"
 	"github.com/filecoin-project/go-filecoin/internal/pkg/types"
+	"github.com/filecoin-project/go-filecoin/internal/pkg/vm"
 	"github.com/filecoin-project/go-filecoin/internal/pkg/vm/state"
 )
 
@@ -18,10 +23,11 @@ type previewerChainReader interface {
 	GetHead() block.TipSetKey
 	GetTipSetState(context.Context, block.TipSetKey) (state.Tree, error)
 	GetTipSet(block.TipSetKey) (block.TipSet, error)
+	GetTipSetStateRoot(block.TipSetKey) (cid.Cid, error)
 }
 
-// Dragons: delete
 type messagePreviewer interface {
+	PreviewMessage(ctx context.Context, st state.Tree, vms vm.Storage, head block.TipSet, msg *types.UnsignedMessage) (*vm.MessageReceipt, *vm.ExecutionTrace, error)
 }
 
 // Previewer calculates the amount of Gas needed for a command
@@ -41,34 +47,61 @@ func NewPreviewer(chainReader previewerChainReader, cst cbor.IpldStore, bs bstor
 	return &Previewer{chainReader, cst, bs, processor}
 }
 
-// Preview sends a read-only message to an actor.
-func (p *Previewer) Preview(ctx context.Context, optFrom, to address.Address, method abi.MethodNum, params ...interface{}) (types.GasUnits, error) {
-	// Dragons: delete
+// Preview runs a message transferring `value` on top of the head state,
+// without committing it, and returns the gas it used and the trace of its
+// execution. A message failing in the vm is not an error, its exit code is in
+// the trace.
+func (p *Previewer) Preview(ctx context.Context, optFrom, to address.Address, value types.AttoFIL, method abi.MethodNum, params ...interface{}) (types.GasUnits, *vm.ExecutionTrace, error) {
+	encodedParams, err := encodePreviewParams(params)
+	if err != nil {
+		return types.GasUnits(0), nil, errors.Wrap(err, "failed to encode message params")
+	}
 
-	// encodedParams, err := abi.ToEncodedValues(params...)
-	// if err != nil {
-	// 	return types.GasUnits(0), errors.Wrap(err, "failed to encode message params")
-	// }
+	head, err := p.chainReader.GetTipSet(p.chainReader.GetHead())
+	if err != nil {
+		return types.GasUnits(0), nil, errors.Wrap(err, "failed to get head tipset ")
+	}
+	st, err := p.chainReader.GetTipSetState(ctx, head.Key())
+	if err != nil {
+		return types.GasUnits(0), nil, errors.Wrap(err, "failed to load tree for latest state root")
+	}
 
-	// st, err := p.chainReader.GetTipSetState(ctx, p.chainReader.GetHead())
-	// if err != nil {
-	// 	return types.GasUnits(0), errors.Wrap(err, "failed to load tree for latest state root")
-	// }
-	// head, err := p.chainReader.GetTipSet(p.chainReader.GetHead())
-	// if err != nil {
-	// 	return types.GasUnits(0), errors.Wrap(err, "failed to get head tipset ")
-	// }
-	// h, err := head.Height()
-	// if err != nil {
-	// 	return types.GasUnits(0), errors.Wrap(err, "failed to get head tipset height")
-	// }
+	// The message carries the sender's next sequence number and pays no gas
+	// price, so that only its value and the execution of the method can fail.
+	// A sender that does not resolve is left for the vm to reject.
+	var callSeqNum uint64
+	root, err := p.chainReader.GetTipSetStateRoot(head.Key())
+	if err != nil {
+		return types.GasUnits(0), nil, errors.Wrap(err, "failed to get head state root")
+	}
+	if fromID, err := appstate.NewView(p.cst, root).InitResolveAddress(ctx, optFrom); err == nil {
+		fromActor, found, err := st.GetActor(ctx, fromID)
+		if err != nil {
+			return types.GasUnits(0), nil, errors.Wrapf(err, "failed to load sender %s", optFrom)
+		}
+		if found {
+			callSeqNum = fromActor.CallSeqNum
+		}
+	}
+	msg := types.NewMeteredMessage(optFrom, to, callSeqNum, value, method, encodedParams, types.ZeroAttoFIL, types.BlockGasLimit)
 
-	// vms := vm.NewStorageMap(p.bs)
-	// usedGas, err := p.processor.PreviewQueryMethod(ctx, st, vms, to, method, encodedParams, optFrom, abi.ChainEpoch(h))
-	// if err != nil {
-	// 	return types.GasUnits(0), errors.Wrap(err, "query method returned an error")
-	// }
-	// return usedGas, nil
+	vms := vm.NewStorage(p.bs)
+	receipt, trace, err := p.processor.PreviewMessage(ctx, st, vms, head, msg)
+	if err != nil {
+		return types.GasUnits(0), nil, errors.Wrap(err, "failed to preview message")
+	}
+	return types.GasUnits(receipt.GasUsed), trace, nil
+}
 
-	return types.GasUnits(0), nil
+// encodePreviewParams encodes a single params value as is, and several as a
+// list.
+func encodePreviewParams(params []interface{}) ([]byte, error) {
+	switch len(params) {
+	case 0:
+		return nil, nil
+	case 1:
+		return encoding.Encode(params[0])
+	default:
+		return encoding.Encode(params)
+	}
 }
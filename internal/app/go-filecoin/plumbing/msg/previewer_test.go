@@ -0,0 +1,109 @@
+package msg
+
+import (
+	"context"
+	"testing"
+
+	"github.com/filecoin-project/go-address"
+	"github.com/filecoin-project/specs-actors/actors/abi"
+	"github.com/filecoin-project/specs-actors/actors/builtin"
+	"github.com/filecoin-project/specs-actors/actors/runtime/exitcode"
+	"github.com/ipfs/go-datastore"
+	bstore "github.com/ipfs/go-ipfs-blockstore"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/filecoin-project/go-filecoin/internal/pkg/block"
+	"github.com/filecoin-project/go-filecoin/internal/pkg/cborutil"
+	"github.com/filecoin-project/go-filecoin/internal/pkg/encoding"
+	"github.com/filecoin-project/go-filecoin/internal/pkg/message"
+	tf "github.com/filecoin-project/go-filecoin/internal/pkg/testhelpers/testflags"
+	"github.com/filecoin-project/go-filecoin/internal/pkg/types"
+	"github.com/filecoin-project/go-filecoin/internal/pkg/vm"
+	"github.com/filecoin-project/go-filecoin/internal/pkg/vm/actor"
+	"github.com/filecoin-project/go-filecoin/internal/pkg/vm/gas"
+	"github.com/filecoin-project/go-filecoin/internal/pkg/vm/state"
+)
+
+type fakeStateChain struct {
+	*message.FakeProvider
+	st state.Tree
+}
+
+func (c *fakeStateChain) GetTipSetState(_ context.Context, _ block.TipSetKey) (state.Tree, error) {
+	return c.st, nil
+}
+
+type fakeMessagePreviewer struct {
+	msg     *types.UnsignedMessage
+	head    block.TipSet
+	receipt *vm.MessageReceipt
+	trace   *vm.ExecutionTrace
+}
+
+func (p *fakeMessagePreviewer) PreviewMessage(_ context.Context, _ state.Tree, _ vm.Storage, head block.TipSet, msg *types.UnsignedMessage) (*vm.MessageReceipt, *vm.ExecutionTrace, error) {
+	p.msg = msg
+	p.head = head
+	return p.receipt, p.trace, nil
+}
+
+func TestPreviewer(t *testing.T) {
+	tf.UnitTest(t)
+	ctx := context.Background()
+
+	bs := bstore.NewBlockstore(datastore.NewMapDatastore())
+	cst := cborutil.NewIpldStore(bs)
+
+	provider := message.NewFakeProvider(t)
+	head := provider.Builder.AppendOn(provider.Builder.NewGenesis(), 1)
+	provider.SetHead(head.Key())
+
+	from, err := address.NewIDAddress(100)
+	require.NoError(t, err)
+	to, err := address.NewIDAddress(101)
+	require.NoError(t, err)
+	st := state.NewState(cst)
+	fromActor := actor.NewActor(builtin.AccountActorCodeID, abi.NewTokenAmount(0))
+	fromActor.CallSeqNum = 7
+	require.NoError(t, st.SetActor(ctx, from, fromActor))
+
+	newPreviewer := func(exitCode exitcode.ExitCode) (*Previewer, *fakeMessagePreviewer) {
+		processor := &fakeMessagePreviewer{
+			receipt: &vm.MessageReceipt{ExitCode: exitCode, GasUsed: gas.NewGas(1234)},
+			trace:   &vm.ExecutionTrace{ExitCode: exitCode},
+		}
+		return NewPreviewer(&fakeStateChain{FakeProvider: provider, st: st}, cst, bs, processor), processor
+	}
+
+	t.Run("runs a message without gas price with the sender's next nonce on the head", func(t *testing.T) {
+		previewer, processor := newPreviewer(exitcode.Ok)
+		gasUsed, trace, err := previewer.Preview(ctx, from, to, types.NewAttoFILFromFIL(2), builtin.MethodsMiner.ChangePeerID, &to)
+		require.NoError(t, err)
+		assert.Equal(t, types.GasUnits(1234), gasUsed)
+		assert.Equal(t, exitcode.Ok, trace.ExitCode)
+
+		require.NotNil(t, processor.msg)
+		assert.True(t, processor.head.Equals(head))
+		assert.Equal(t, from, processor.msg.From)
+		assert.Equal(t, to, processor.msg.To)
+		assert.Equal(t, uint64(7), processor.msg.CallSeqNum)
+		assert.Equal(t, types.NewAttoFILFromFIL(2), processor.msg.Value)
+		assert.Equal(t, builtin.MethodsMiner.ChangePeerID, processor.msg.Method)
+		assert.True(t, processor.msg.GasPrice.IsZero())
+		assert.Equal(t, types.BlockGasLimit, processor.msg.GasLimit)
+		expected, err := encoding.Encode(&to)
+		require.NoError(t, err)
+		assert.Equal(t, expected, processor.msg.Params)
+	})
+
+	t.Run("an unknown sender is left to the vm", func(t *testing.T) {
+		previewer, processor := newPreviewer(exitcode.SysErrActorNotFound)
+		unknown, err := address.NewIDAddress(102)
+		require.NoError(t, err)
+		_, trace, err := previewer.Preview(ctx, unknown, to, types.ZeroAttoFIL, builtin.MethodSend)
+		require.NoError(t, err)
+		assert.Equal(t, exitcode.SysErrActorNotFound, trace.ExitCode)
+		assert.Equal(t, uint64(0), processor.msg.CallSeqNum)
+		assert.Empty(t, processor.msg.Params)
+	})
+}
@@ -0,0 +1,96 @@
+package msg
+
+import (
+	"context"
+
+	"github.com/ipfs/go-cid"
+	bstore "github.com/ipfs/go-ipfs-blockstore"
+	"github.com/pkg/errors"
+
+	"github.com/filecoin-project/go-filecoin/internal/pkg/block"
+	"github.com/filecoin-project/go-filecoin/internal/pkg/chain"
+	"github.com/filecoin-project/go-filecoin/internal/pkg/vm"
+	"github.com/filecoin-project/go-filecoin/internal/pkg/vm/state"
+)
+
+// ErrMessageNotFound is returned when tracing a message that is not on chain.
+var ErrMessageNotFound = errors.New("message not found on chain")
+
+// Abstracts over a store of blockchain state.
+type tracerChainReader interface {
+	GetTipSet(block.TipSetKey) (block.TipSet, error)
+	GetTipSetState(context.Context, block.TipSetKey) (state.Tree, error)
+	LookupMessage(cid.Cid) (*chain.MessageLocation, bool, error)
+}
+
+type tipSetTracer interface {
+	TraceTipSet(ctx context.Context, st state.Tree, vms vm.Storage, ts block.TipSet, msgs []vm.BlockMessagesInfo) ([]vm.MessageReceipt, []*vm.ExecutionTrace, error)
+}
+
+// Tracer traces the execution of on-chain messages by replaying the tipset
+// that included them on its parent state.
+type Tracer struct {
+	chainReader     tracerChainReader
+	messageProvider chain.MessageProvider
+	bs              bstore.Blockstore
+	processor       tipSetTracer
+}
+
+// NewTracer constructs a Tracer.
+func NewTracer(chainReader tracerChainReader, messages chain.MessageProvider, bs bstore.Blockstore, processor tipSetTracer) *Tracer {
+	return &Tracer{
+		chainReader:     chainReader,
+		messageProvider: messages,
+		bs:              bs,
+		processor:       processor,
+	}
+}
+
+// Trace returns the execution trace of the message with cid `msgCid`, found
+// in the message index.
+func (t *Tracer) Trace(ctx context.Context, msgCid cid.Cid) (*vm.ExecutionTrace, error) {
+	loc, found, err := t.chainReader.LookupMessage(msgCid)
+	if err != nil {
+		return nil, err
+	}
+	if !found {
+		return nil, ErrMessageNotFound
+	}
+
+	ts, err := t.chainReader.GetTipSet(loc.TipSet)
+	if err != nil {
+		return nil, err
+	}
+	parent, err := ts.Parents()
+	if err != nil {
+		return nil, err
+	}
+	st, err := t.chainReader.GetTipSetState(ctx, parent)
+	if err != nil {
+		return nil, errors.Wrapf(err, "failed to load parent state of tipset %s", ts.Key())
+	}
+
+	msgs := make([]vm.BlockMessagesInfo, ts.Len())
+	for i := 0; i < ts.Len(); i++ {
+		blk := ts.At(i)
+		secpMsgs, blsMsgs, err := t.messageProvider.LoadMessages(ctx, blk.Messages.Cid)
+		if err != nil {
+			return nil, errors.Wrapf(err, "failed to load messages of block %s", blk.Cid())
+		}
+		msgs[i] = vm.BlockMessagesInfo{
+			BLSMessages:  blsMsgs,
+			SECPMessages: secpMsgs,
+			Miner:        blk.Miner,
+			TicketCount:  int64(len(blk.EPoStInfo.Winners)),
+		}
+	}
+
+	_, traces, err := t.processor.TraceTipSet(ctx, st, vm.NewStorage(t.bs), ts, msgs)
+	if err != nil {
+		return nil, errors.Wrapf(err, "failed to replay tipset %s", ts.Key())
+	}
+	if loc.ReceiptIndex >= uint64(len(traces)) {
+		return nil, errors.Errorf("could not find message trace at index %d", loc.ReceiptIndex)
+	}
+	return traces[loc.ReceiptIndex], nil
+}
@@ -0,0 +1,120 @@
+package msg
+
+import (
+	"context"
+	"testing"
+
+	"github.com/filecoin-project/specs-actors/actors/runtime/exitcode"
+	"github.com/ipfs/go-cid"
+	"github.com/ipfs/go-datastore"
+	bstore "github.com/ipfs/go-ipfs-blockstore"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/filecoin-project/go-filecoin/internal/pkg/block"
+	"github.com/filecoin-project/go-filecoin/internal/pkg/cborutil"
+	"github.com/filecoin-project/go-filecoin/internal/pkg/chain"
+	e "github.com/filecoin-project/go-filecoin/internal/pkg/enccid"
+	"github.com/filecoin-project/go-filecoin/internal/pkg/message"
+	tf "github.com/filecoin-project/go-filecoin/internal/pkg/testhelpers/testflags"
+	"github.com/filecoin-project/go-filecoin/internal/pkg/types"
+	"github.com/filecoin-project/go-filecoin/internal/pkg/vm"
+	"github.com/filecoin-project/go-filecoin/internal/pkg/vm/state"
+)
+
+type fakeTracerChain struct {
+	fakeStateChain
+	locations map[cid.Cid]*chain.MessageLocation
+}
+
+func (c *fakeTracerChain) LookupMessage(msgCid cid.Cid) (*chain.MessageLocation, bool, error) {
+	loc, found := c.locations[msgCid]
+	return loc, found, nil
+}
+
+type fakeTipSetTracer struct {
+	ts     block.TipSet
+	msgs   []vm.BlockMessagesInfo
+	traces []*vm.ExecutionTrace
+}
+
+func (p *fakeTipSetTracer) TraceTipSet(_ context.Context, _ state.Tree, _ vm.Storage, ts block.TipSet, msgs []vm.BlockMessagesInfo) ([]vm.MessageReceipt, []*vm.ExecutionTrace, error) {
+	p.ts = ts
+	p.msgs = msgs
+	return make([]vm.MessageReceipt, len(p.traces)), p.traces, nil
+}
+
+func TestTracer(t *testing.T) {
+	tf.UnitTest(t)
+	ctx := context.Background()
+
+	bs := bstore.NewBlockstore(datastore.NewMapDatastore())
+	st := state.NewState(cborutil.NewIpldStore(bs))
+
+	ki := types.MustGenerateMixedKeyInfo(1, 1)
+	mm := vm.NewMessageMaker(t, ki)
+	blsAddr, secpAddr := mm.Addresses()[0], mm.Addresses()[1]
+
+	provider := message.NewFakeProvider(t)
+	genesis := provider.Builder.NewGenesis()
+	blsMsg := mm.NewUnsignedMessage(blsAddr, 0)
+	secpMsg, err := types.NewSignedMessage(*mm.NewUnsignedMessage(secpAddr, 0), mm.Signer())
+	require.NoError(t, err)
+	head := provider.Builder.BuildOneOn(genesis, func(b *chain.BlockBuilder) {
+		b.AddMessages([]*types.SignedMessage{secpMsg}, []*types.UnsignedMessage{blsMsg})
+	})
+	provider.SetHead(head.Key())
+
+	blsCid, err := blsMsg.Cid()
+	require.NoError(t, err)
+	secpCid, err := secpMsg.Cid()
+	require.NoError(t, err)
+	missing := types.CidFromString(t, "missing")
+	chainReader := &fakeTracerChain{
+		fakeStateChain: fakeStateChain{FakeProvider: provider, st: st},
+		locations: map[cid.Cid]*chain.MessageLocation{
+			blsCid:  {TipSet: head.Key(), Height: head.At(0).Height, Block: e.NewCid(head.At(0).Cid()), ReceiptIndex: 0},
+			secpCid: {TipSet: head.Key(), Height: head.At(0).Height, Block: e.NewCid(head.At(0).Cid()), ReceiptIndex: 1},
+			missing: {TipSet: head.Key(), Height: head.At(0).Height, Block: e.NewCid(head.At(0).Cid()), ReceiptIndex: 2},
+		},
+	}
+
+	traces := []*vm.ExecutionTrace{
+		{From: blsAddr, ExitCode: exitcode.Ok},
+		{From: secpAddr, ExitCode: exitcode.ErrIllegalArgument},
+	}
+	processor := &fakeTipSetTracer{traces: traces}
+	tracer := NewTracer(chainReader, provider, bs, processor)
+
+	t.Run("returns the trace of the message from a replay of its tipset", func(t *testing.T) {
+		trace, err := tracer.Trace(ctx, secpCid)
+		require.NoError(t, err)
+		assert.Equal(t, traces[1], trace)
+
+		assert.True(t, processor.ts.Equals(head))
+		require.Len(t, processor.msgs, 1)
+		assert.Equal(t, head.At(0).Miner, processor.msgs[0].Miner)
+		require.Len(t, processor.msgs[0].BLSMessages, 1)
+		c, err := processor.msgs[0].BLSMessages[0].Cid()
+		require.NoError(t, err)
+		assert.Equal(t, blsCid, c)
+		require.Len(t, processor.msgs[0].SECPMessages, 1)
+		c, err = processor.msgs[0].SECPMessages[0].Cid()
+		require.NoError(t, err)
+		assert.Equal(t, secpCid, c)
+
+		trace, err = tracer.Trace(ctx, blsCid)
+		require.NoError(t, err)
+		assert.Equal(t, traces[0], trace)
+	})
+
+	t.Run("a message not on chain is not found", func(t *testing.T) {
+		_, err := tracer.Trace(ctx, types.CidFromString(t, "not-on-chain"))
+		assert.Equal(t, ErrMessageNotFound, err)
+	})
+
+	t.Run("a receipt index beyond the traces fails", func(t *testing.T) {
+		_, err := tracer.Trace(ctx, missing)
+		assert.Error(t, err)
+	})
+}
@@ -27,6 +27,7 @@ type waiterChainReader interface {
 	GetTipSetState(context.Context, block.TipSetKey) (state.Tree, error)
 	GetTipSetReceiptsRoot(block.TipSetKey) (cid.Cid, error)
 	HeadEvents() *pubsub.PubSub
+	LookupMessage(cid.Cid) (*chain.MessageLocation, bool, error)
 }
 
 // Waiter waits for a message to appear on chain.
@@ -66,6 +67,17 @@ func (w *Waiter) Find(ctx context.Context, pred WaitPredicate) (*ChainMessage, b
 	return w.findMessage(ctx, headTipSet, pred)
 }
 
+// Lookup returns the message with the given cid from the chain, if it exists.
+// It answers from the chain store's message index, falling back to searching
+// the chain if the store has no index or the index is not up to date.
+func (w *Waiter) Lookup(ctx context.Context, msgCid cid.Cid) (*ChainMessage, bool, error) {
+	chainMsg, found, err := w.findIndexed(ctx, msgCid)
+	if indexUnavailable(err) {
+		return w.Find(ctx, cidPredicate(msgCid))
+	}
+	return chainMsg, found, err
+}
+
 // WaitPredicate invokes the callback when the passed predicate succeeds.
 // See api description.
 //
@@ -75,9 +87,8 @@ func (w *Waiter) Find(ctx context.Context, pred WaitPredicate) (*ChainMessage, b
 // Something like receiptFromTipset is necessary because not every message in
 // a block will have a receipt in the tipset: it might be a duplicate message.
 //
-// TODO: This implementation will become prohibitively expensive since it
-// traverses the entire chain. We should use an index instead.
-// https://github.com/filecoin-project/go-filecoin/issues/1518
+// This traverses the entire chain, so callers waiting for a known cid should
+// use Wait, which answers from the message index.
 func (w *Waiter) WaitPredicate(ctx context.Context, pred WaitPredicate, cb func(*block.Block, *types.SignedMessage, *vm.MessageReceipt) error) error {
 	ch := w.chainReader.HeadEvents().Sub(chain.NewHeadTopic)
 	defer w.chainReader.HeadEvents().Unsub(ch, chain.NewHeadTopic)
@@ -104,11 +115,123 @@ func (w *Waiter) WaitPredicate(ctx context.Context, pred WaitPredicate, cb func(
 func (w *Waiter) Wait(ctx context.Context, msgCid cid.Cid, cb func(*block.Block, *types.SignedMessage, *vm.MessageReceipt) error) error {
 	log.Infof("Calling Waiter.Wait CID: %s", msgCid.String())
 
-	pred := func(msg *types.SignedMessage, c cid.Cid) bool {
-		return c.Equals(msgCid)
+	ch := w.chainReader.HeadEvents().Sub(chain.NewHeadTopic)
+	defer w.chainReader.HeadEvents().Unsub(ch, chain.NewHeadTopic)
+
+	chainMsg, found, err := w.findIndexed(ctx, msgCid)
+	if indexUnavailable(err) {
+		return w.WaitPredicate(ctx, cidPredicate(msgCid), cb)
+	}
+	if err != nil {
+		return err
+	}
+	if found {
+		return cb(chainMsg.Block, chainMsg.Message, chainMsg.Receipt)
+	}
+
+	// The store indexes a new head before publishing it, so each head event
+	// is an opportunity for the message to have appeared. If the index falls
+	// behind, the chain is searched instead.
+	for {
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case raw, more := <-ch:
+			if !more {
+				return nil
+			}
+			if e, ok := raw.(error); ok {
+				log.Errorf("Waiter.Wait: %s", e)
+				return e
+			}
+			chainMsg, found, err := w.findIndexed(ctx, msgCid)
+			if indexUnavailable(err) {
+				return w.WaitPredicate(ctx, cidPredicate(msgCid), cb)
+			}
+			if err != nil {
+				return err
+			}
+			if found {
+				return cb(chainMsg.Block, chainMsg.Message, chainMsg.Receipt)
+			}
+		}
+	}
+}
+
+// findIndexed resolves a message from its location in the message index.
+func (w *Waiter) findIndexed(ctx context.Context, msgCid cid.Cid) (*ChainMessage, bool, error) {
+	loc, found, err := w.chainReader.LookupMessage(msgCid)
+	if err != nil || !found {
+		return nil, false, err
+	}
+
+	ts, err := w.chainReader.GetTipSet(loc.TipSet)
+	if err != nil {
+		return nil, false, err
+	}
+	var blk *block.Block
+	for _, b := range ts.ToSlice() {
+		if b.Cid().Equals(loc.Block.Cid) {
+			blk = b
+			break
+		}
+	}
+	if blk == nil {
+		return nil, false, errors.Errorf("indexed block %s not in tipset %s", loc.Block.Cid, loc.TipSet)
+	}
+
+	secpMsgs, blsMsgs, err := w.messageProvider.LoadMessages(ctx, blk.Messages.Cid)
+	if err != nil {
+		return nil, false, err
+	}
+	var smsg *types.SignedMessage
+	for _, msg := range blsMsgs {
+		c, err := msg.Cid()
+		if err != nil {
+			return nil, false, err
+		}
+		if c.Equals(msgCid) {
+			smsg = types.WrapBLSMessage(msg)
+			break
+		}
+	}
+	for i := 0; smsg == nil && i < len(secpMsgs); i++ {
+		c, err := secpMsgs[i].Cid()
+		if err != nil {
+			return nil, false, err
+		}
+		if c.Equals(msgCid) {
+			smsg = secpMsgs[i]
+		}
+	}
+	if smsg == nil {
+		return nil, false, errors.Errorf("indexed message %s not in block %s", msgCid, blk.Cid())
 	}
 
-	return w.WaitPredicate(ctx, pred, cb)
+	receiptCid, err := w.chainReader.GetTipSetReceiptsRoot(loc.TipSet)
+	if err != nil {
+		return nil, false, err
+	}
+	receipts, err := w.messageProvider.LoadReceipts(ctx, receiptCid)
+	if err != nil {
+		return nil, false, err
+	}
+	if loc.ReceiptIndex >= uint64(len(receipts)) {
+		return nil, false, errors.Errorf("could not find message receipt at index %d", loc.ReceiptIndex)
+	}
+	return &ChainMessage{Message: smsg, Block: blk, Receipt: &receipts[loc.ReceiptIndex]}, true, nil
+}
+
+// indexUnavailable returns true if err reports that the message index cannot
+// answer lookups.
+func indexUnavailable(err error) bool {
+	return err == chain.ErrNoMessageIndex || err == chain.ErrMessageIndexNotReady
+}
+
+func cidPredicate(msgCid cid.Cid) WaitPredicate {
+	return func(msg *types.SignedMessage, c cid.Cid) bool {
+		return c.Equals(msgCid)
+	}
 }
 
 // findMessage looks for a matching in the chain and returns the message,
@@ -188,7 +311,7 @@ func (w *Waiter) receiptForTipset(ctx context.Context, ts block.TipSet, pred Wai
 			}
 			originalCids[j] = c
 			unwrappedMsgs[j] = msg
-			wrappedMsgs[j] = &types.SignedMessage{Message: *msg}
+			wrappedMsgs[j] = types.WrapBLSMessage(msg)
 		}
 		for j, msg := range secpMsgs {
 			c, err := msg.Cid()
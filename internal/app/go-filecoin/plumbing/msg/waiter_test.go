@@ -1,22 +1,29 @@
 package msg
 
 import (
+	"bytes"
 	"context"
 	"sync"
 	"testing"
 	"time"
 
+	"github.com/filecoin-project/go-address"
 	"github.com/filecoin-project/go-filecoin/internal/pkg/block"
 	"github.com/filecoin-project/go-filecoin/internal/pkg/vm"
 	"github.com/filecoin-project/go-filecoin/internal/pkg/vm/gas"
 	"github.com/filecoin-project/specs-actors/actors/abi"
 	"github.com/ipfs/go-cid"
+	bstore "github.com/ipfs/go-ipfs-blockstore"
 	cbor "github.com/ipfs/go-ipld-cbor"
+	format "github.com/ipfs/go-ipld-format"
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
 
+	"github.com/filecoin-project/go-filecoin/internal/pkg/cborutil"
 	"github.com/filecoin-project/go-filecoin/internal/pkg/chain"
+	"github.com/filecoin-project/go-filecoin/internal/pkg/crypto"
 	e "github.com/filecoin-project/go-filecoin/internal/pkg/enccid"
+	"github.com/filecoin-project/go-filecoin/internal/pkg/repo"
 	th "github.com/filecoin-project/go-filecoin/internal/pkg/testhelpers"
 	tf "github.com/filecoin-project/go-filecoin/internal/pkg/testhelpers/testflags"
 	"github.com/filecoin-project/go-filecoin/internal/pkg/types"
@@ -109,6 +116,102 @@ func testWaitNew(ctx context.Context, t *testing.T, cst cbor.IpldStore, chainSto
 	wg.Wait()
 }
 
+func TestWaitWithoutBuiltIndex(t *testing.T) {
+	tf.UnitTest(t)
+
+	ctx := context.Background()
+	cst, chainStore, msgStore, waiter := setupTest(t)
+	// Lookups search the chain until the new index is built.
+	chainStore.SetMessageIndex(chain.NewMessageIndex(repo.NewInMemoryRepo().ChainDatastore(), msgStore))
+
+	testWaitExisting(ctx, t, cst, chainStore, msgStore, waiter)
+	testWaitNew(ctx, t, cst, chainStore, msgStore, waiter)
+}
+
+func TestWaitAfterRecentSnapshotImport(t *testing.T) {
+	tf.UnitTest(t)
+
+	ctx := context.Background()
+	builder := chain.NewBuilder(t, address.Undef)
+	gen := builder.NewGenesis()
+	withMessage := func(msg *types.SignedMessage) func(b *chain.BlockBuilder) {
+		return func(b *chain.BlockBuilder) {
+			b.AddMessages([]*types.SignedMessage{msg}, []*types.UnsignedMessage{})
+		}
+	}
+	oldMsg, recentMsg := newSignedMessage(), newSignedMessage()
+	old := builder.BuildOneOn(gen, withMessage(oldMsg))
+	head := builder.BuildOneOn(builder.AppendOn(old, 1), withMessage(recentMsg))
+
+	var buf bytes.Buffer
+	require.NoError(t, chain.ExportRecent(ctx, head, 1, builder, builder, &emptyStateReader{}, &buf))
+
+	r := repo.NewInMemoryRepo()
+	bs := bstore.NewBlockstore(r.Datastore())
+	cst := cborutil.NewIpldStore(bs)
+	messages := chain.NewMessageStore(bs)
+	store := chain.NewStore(r.ChainDatastore(), cst, chain.NewStatusReporter(), gen.At(0).Cid())
+	store.SetMessageIndex(chain.NewMessageIndex(r.ChainDatastore(), messages))
+
+	key, err := chain.ImportSnapshot(ctx, bs, &buf, store, &chain.FakeStateEvaluator{}, 1)
+	require.NoError(t, err)
+	require.True(t, head.Key().Equals(key))
+	require.NoError(t, store.SetHead(ctx, head))
+
+	waiter := NewWaiter(store, messages, bs, cst)
+	recentCid, err := recentMsg.Cid()
+	require.NoError(t, err)
+	waitCtx, cancel := context.WithTimeout(ctx, 5*time.Second)
+	defer cancel()
+	require.NoError(t, waiter.Wait(waitCtx, recentCid, func(_ *block.Block, msg *types.SignedMessage, _ *vm.MessageReceipt) error {
+		assert.True(t, types.SmsgCidsEqual(recentMsg, msg))
+		return nil
+	}))
+
+	// The messages of older tipsets were not imported, so they are not indexed.
+	oldCid, err := oldMsg.Cid()
+	require.NoError(t, err)
+	_, found, err := store.LookupMessage(oldCid)
+	require.NoError(t, err)
+	assert.False(t, found)
+}
+
+func TestWaitBLS(t *testing.T) {
+	tf.UnitTest(t)
+
+	ctx := context.Background()
+	cst, chainStore, msgStore, waiter := setupTest(t)
+
+	blsSigner := types.NewMockSigner(types.MustGenerateMixedKeyInfo(1, 1))
+	newBLSMessage := types.NewSignedMessageForTestGetter(blsSigner)
+	m1, m2 := newBLSMessage(), newSignedMessage()
+	require.Equal(t, crypto.SigTypeBLS, m1.Signature.Type)
+
+	head := chainStore.GetHead()
+	headTipSet, err := chainStore.GetTipSet(head)
+	require.NoError(t, err)
+	chainWithMsgs := newChainWithMessages(cst, msgStore, headTipSet, smsgsSet{smsgs{m2, m1}})
+	ts := chainWithMsgs[len(chainWithMsgs)-1]
+	require.NoError(t, chainStore.PutTipSetMetadata(ctx, &chain.TipSetMetadata{
+		TipSet:          ts,
+		TipSetStateRoot: ts.ToSlice()[0].StateRoot.Cid,
+		TipSetReceipts:  ts.ToSlice()[0].MessageReceipts.Cid,
+	}))
+	require.NoError(t, chainStore.SetHead(ctx, ts))
+
+	// The message of the BLS sender is found by the CID it was sent with, and its receipt
+	// is the one of the message, not of its neighbour.
+	expectCid, err := m1.Cid()
+	require.NoError(t, err)
+	err = waiter.Wait(ctx, expectCid, func(b *block.Block, msg *types.SignedMessage, rcp *vm.MessageReceipt) error {
+		assert.True(t, msg.Message.Equals(&m1.Message))
+		assert.Equal(t, expectCid.Bytes(), rcp.ReturnValue)
+		return nil
+	})
+	require.NoError(t, err)
+	testWaitHelp(nil, t, waiter, m2, false, nil)
+}
+
 func TestWaitError(t *testing.T) {
 	tf.UnitTest(t)
 
@@ -204,14 +307,30 @@ func newChainWithMessages(store cbor.IpldStore, msgStore *chain.MessageStore, ro
 			blocks = append(blocks, child)
 		}
 		for _, msgs := range tsMsgs {
+			// Blocks carry BLS messages unsigned, and they are applied before secp messages.
+			secpMsgs := []*types.SignedMessage{}
+			blsMsgs := []*types.UnsignedMessage{}
+			var ordered []*types.SignedMessage
+			for _, msg := range msgs {
+				if msg.Signature.Type == crypto.SigTypeBLS {
+					blsMsgs = append(blsMsgs, &msg.Message)
+					ordered = append(ordered, msg)
+				}
+			}
 			for _, msg := range msgs {
+				if msg.Signature.Type != crypto.SigTypeBLS {
+					secpMsgs = append(secpMsgs, msg)
+					ordered = append(ordered, msg)
+				}
+			}
+			for _, msg := range ordered {
 				c, err := msg.Cid()
 				if err != nil {
 					panic(err)
 				}
 				receipts = append(receipts, vm.MessageReceipt{ExitCode: 0, ReturnValue: c.Bytes(), GasUsed: gas.Zero})
 			}
-			txMeta, err := msgStore.StoreMessages(context.Background(), msgs, []*types.UnsignedMessage{})
+			txMeta, err := msgStore.StoreMessages(context.Background(), secpMsgs, blsMsgs)
 			if err != nil {
 				panic(err)
 			}
@@ -254,3 +373,10 @@ func mustPut(store cbor.IpldStore, thingy interface{}) cid.Cid {
 	}
 	return cid
 }
+
+// emptyStateReader exports no state trees.
+type emptyStateReader struct{}
+
+func (emptyStateReader) ChainStateTree(_ context.Context, _ cid.Cid) ([]format.Node, error) {
+	return nil, nil
+}
@@ -75,14 +75,15 @@ func (a *API) MinerCreate(
 	return MinerCreate(ctx, a, accountAddr, gasPrice, gasLimit, sectorSize, pid, collateral)
 }
 
-// MinerPreviewCreate previews the Gas cost of creating a miner
+// MinerPreviewCreate previews the Gas cost of creating a miner with `collateral`
 func (a *API) MinerPreviewCreate(
 	ctx context.Context,
 	fromAddr address.Address,
 	sectorSize abi.SectorSize,
 	pid peer.ID,
+	collateral types.AttoFIL,
 ) (usedGas types.GasUnits, err error) {
-	return MinerPreviewCreate(ctx, a, fromAddr, sectorSize, pid)
+	return MinerPreviewCreate(ctx, a, fromAddr, sectorSize, pid, collateral)
 }
 
 // MinerGetStatus queries for status of a miner.
@@ -132,8 +133,13 @@ func (a *API) PingMinerWithTimeout(
 }
 
 // MinerSetWorkerAddress sets the miner worker address to the provided address
-func (a *API) MinerSetWorkerAddress(ctx context.Context, toAddr address.Address, gasPrice types.AttoFIL, gasLimit types.GasUnits) (cid.Cid, error) {
-	return MinerSetWorkerAddress(ctx, a, toAddr, gasPrice, gasLimit)
+func (a *API) MinerSetWorkerAddress(ctx context.Context, minerAddr, toAddr address.Address, gasPrice types.AttoFIL, gasLimit types.GasUnits) (cid.Cid, error) {
+	return MinerSetWorkerAddress(ctx, a, minerAddr, toAddr, gasPrice, gasLimit)
+}
+
+// MinerPreviewSetWorkerAddress previews the Gas cost of setting the worker address of the miner
+func (a *API) MinerPreviewSetWorkerAddress(ctx context.Context, minerAddr, toAddr address.Address) (types.GasUnits, error) {
+	return MinerPreviewSetWorkerAddress(ctx, a, minerAddr, toAddr)
 }
 
 // MessageWaitDone blocks until the message is on chain
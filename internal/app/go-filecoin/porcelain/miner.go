@@ -110,18 +110,19 @@ func MinerCreate(
 // mpcAPI is the subset of the plumbing.API that MinerPreviewCreate uses.
 type mpcAPI interface {
 	ConfigGet(dottedPath string) (interface{}, error)
-	MessagePreview(ctx context.Context, from, to address.Address, method abi.MethodNum, params ...interface{}) (types.GasUnits, error)
+	MessagePreview(ctx context.Context, from, to address.Address, value types.AttoFIL, method abi.MethodNum, params ...interface{}) (types.GasUnits, error)
 	NetworkGetPeerID() peer.ID
 	WalletDefaultAddress() (address.Address, error)
 }
 
-// MinerPreviewCreate previews the Gas cost of creating a miner
+// MinerPreviewCreate previews the Gas cost of creating a miner with `collateral`
 func MinerPreviewCreate(
 	ctx context.Context,
 	plumbing mpcAPI,
 	fromAddr address.Address,
 	sectorSize abi.SectorSize,
 	pid peer.ID,
+	collateral types.AttoFIL,
 ) (usedGas types.GasUnits, err error) {
 	if fromAddr.Empty() {
 		fromAddr, err = plumbing.WalletDefaultAddress()
@@ -142,6 +143,7 @@ func MinerPreviewCreate(
 		ctx,
 		fromAddr,
 		builtin.StorageMarketActorAddr,
+		collateral,
 		builtin.MethodsPower.CreateMiner,
 		sectorSize,
 		pid,
@@ -252,33 +254,19 @@ type mwapi interface {
 
 // MinerSetWorkerAddress sets the worker address of the miner actor to the provided new address,
 // waits for the message to appear on chain and then sets miner.workerAddr config to the new address.
+// The miner actor is the one configured in mining.minerAddress if minerAddr is undefined.
 func MinerSetWorkerAddress(
 	ctx context.Context,
 	plumbing mwapi,
+	minerAddr address.Address,
 	workerAddr address.Address,
 	gasPrice types.AttoFIL,
 	gasLimit types.GasUnits,
 ) (cid.Cid, error) {
-
-	retVal, err := plumbing.ConfigGet("mining.minerAddress")
+	minerAddr, owner, err := minerOwner(ctx, plumbing, minerAddr)
 	if err != nil {
 		return cid.Undef, err
 	}
-	minerAddr, ok := retVal.(address.Address)
-	if !ok {
-		return cid.Undef, errors.New("problem converting miner address")
-	}
-
-	head := plumbing.ChainHeadKey()
-	state, err := plumbing.MinerStateView(head)
-	if err != nil {
-		return cid.Undef, errors.Wrap(err, "could not get miner owner address")
-	}
-
-	owner, _, err := state.MinerControlAddresses(ctx, minerAddr)
-	if err != nil {
-		return cid.Undef, errors.Wrap(err, "could not get miner owner address")
-	}
 
 	c, _, err := plumbing.MessageSend(
 		ctx,
@@ -291,3 +279,55 @@ func MinerSetWorkerAddress(
 		&workerAddr)
 	return c, err
 }
+
+// mpwapi is the subset of the plumbing.API that MinerPreviewSetWorkerAddress uses.
+type mpwapi interface {
+	ConfigGet(dottedPath string) (interface{}, error)
+	ChainHeadKey() block.TipSetKey
+	MinerStateView(baseKey block.TipSetKey) (MinerStateView, error)
+	MessagePreview(ctx context.Context, from, to address.Address, value types.AttoFIL, method abi.MethodNum, params ...interface{}) (types.GasUnits, error)
+}
+
+// MinerPreviewSetWorkerAddress previews the Gas cost of setting the worker address of the
+// miner actor, the configured one if minerAddr is undefined.
+func MinerPreviewSetWorkerAddress(ctx context.Context, plumbing mpwapi, minerAddr address.Address, workerAddr address.Address) (types.GasUnits, error) {
+	minerAddr, owner, err := minerOwner(ctx, plumbing, minerAddr)
+	if err != nil {
+		return types.GasUnits(0), err
+	}
+	return plumbing.MessagePreview(ctx, owner, minerAddr, types.ZeroAttoFIL, builtin.MethodsMiner.ChangeWorkerAddress, &workerAddr)
+}
+
+type minerOwnerAPI interface {
+	ConfigGet(dottedPath string) (interface{}, error)
+	ChainHeadKey() block.TipSetKey
+	MinerStateView(baseKey block.TipSetKey) (MinerStateView, error)
+}
+
+// minerOwner returns the miner address, the one of the config if minerAddr is undefined,
+// and the owner of that miner at the head.
+func minerOwner(ctx context.Context, plumbing minerOwnerAPI, minerAddr address.Address) (address.Address, address.Address, error) {
+	if minerAddr.Empty() {
+		retVal, err := plumbing.ConfigGet("mining.minerAddress")
+		if err != nil {
+			return address.Undef, address.Undef, err
+		}
+		var ok bool
+		minerAddr, ok = retVal.(address.Address)
+		if !ok {
+			return address.Undef, address.Undef, errors.New("problem converting miner address")
+		}
+	}
+
+	head := plumbing.ChainHeadKey()
+	state, err := plumbing.MinerStateView(head)
+	if err != nil {
+		return address.Undef, address.Undef, errors.Wrap(err, "could not get miner owner address")
+	}
+
+	owner, _, err := state.MinerControlAddresses(ctx, minerAddr)
+	if err != nil {
+		return address.Undef, address.Undef, errors.Wrap(err, "could not get miner owner address")
+	}
+	return minerAddr, owner, nil
+}
@@ -161,6 +161,7 @@ type mSetWorkerPlumbing struct {
 	head                                         block.TipSetKey
 	getStatusFail, msgFail, msgWaitFail, cfgFail bool
 	minerAddr, ownerAddr, workerAddr             address.Address
+	previewFrom, previewTo                       address.Address
 }
 
 func (p *mSetWorkerPlumbing) ChainHeadKey() block.TipSetKey {
@@ -190,6 +191,14 @@ func (p *mSetWorkerPlumbing) MessageSend(ctx context.Context, from, to address.A
 	return types.EmptyMessagesCID, nil, nil
 }
 
+func (p *mSetWorkerPlumbing) MessagePreview(ctx context.Context, from, to address.Address, value types.AttoFIL, method abi.MethodNum, params ...interface{}) (types.GasUnits, error) {
+	if p.msgFail {
+		return types.GasUnits(0), errors.New("MsgFail")
+	}
+	p.previewFrom, p.previewTo = from, to
+	return types.GasUnits(5), nil
+}
+
 func (p *mSetWorkerPlumbing) MessageWait(ctx context.Context, msgCid cid.Cid, cb func(*block.Block, *types.SignedMessage, *vm.MessageReceipt) error) error {
 	if p.msgWaitFail {
 		return errors.New("MsgWaitFail")
@@ -223,7 +232,7 @@ func TestMinerSetWorkerAddress(t *testing.T) {
 			minerAddr:  minerAddr,
 		}
 
-		_, err := MinerSetWorkerAddress(context.Background(), plumbing, workerAddr, gprice, glimit)
+		_, err := MinerSetWorkerAddress(context.Background(), plumbing, address.Undef, workerAddr, gprice, glimit)
 		assert.NoError(t, err)
 		assert.Equal(t, workerAddr, plumbing.workerAddr)
 	})
@@ -252,9 +261,50 @@ func TestMinerSetWorkerAddress(t *testing.T) {
 
 	for _, test := range testCases {
 		t.Run(test.name, func(t *testing.T) {
-			_, err := MinerSetWorkerAddress(context.Background(), test.plumbing, workerAddr, gprice, glimit)
+			_, err := MinerSetWorkerAddress(context.Background(), test.plumbing, address.Undef, workerAddr, gprice, glimit)
 			assert.Error(t, err, test.error)
 			assert.Empty(t, test.plumbing.workerAddr)
 		})
 	}
 }
+
+func TestMinerPreviewSetWorkerAddress(t *testing.T) {
+	tf.UnitTest(t)
+
+	minerOwner := vmaddr.RequireIDAddress(t, 100)
+	minerAddr := vmaddr.RequireIDAddress(t, 101)
+	workerAddr := vmaddr.RequireIDAddress(t, 102)
+
+	t.Run("previews the message from the owner to the miner", func(t *testing.T) {
+		plumbing := &mSetWorkerPlumbing{
+			ownerAddr: minerOwner,
+			minerAddr: minerAddr,
+		}
+
+		usedGas, err := MinerPreviewSetWorkerAddress(context.Background(), plumbing, address.Undef, workerAddr)
+		require.NoError(t, err)
+		assert.Equal(t, types.GasUnits(5), usedGas)
+		assert.Equal(t, minerOwner, plumbing.previewFrom)
+		assert.Equal(t, minerAddr, plumbing.previewTo)
+	})
+
+	t.Run("previews the message to the given miner", func(t *testing.T) {
+		otherMiner := vmaddr.RequireIDAddress(t, 103)
+		plumbing := &mSetWorkerPlumbing{
+			ownerAddr: minerOwner,
+			minerAddr: otherMiner,
+			cfgFail:   true,
+		}
+
+		_, err := MinerPreviewSetWorkerAddress(context.Background(), plumbing, otherMiner, workerAddr)
+		require.NoError(t, err)
+		assert.Equal(t, minerOwner, plumbing.previewFrom)
+		assert.Equal(t, otherMiner, plumbing.previewTo)
+	})
+
+	t.Run("When the miner cannot be loaded, returns the error", func(t *testing.T) {
+		plumbing := &mSetWorkerPlumbing{getStatusFail: true}
+		_, err := MinerPreviewSetWorkerAddress(context.Background(), plumbing, address.Undef, workerAddr)
+		assert.Error(t, err)
+	})
+}
@@ -2,31 +2,88 @@ package retrievalmarketconnector
 
 import (
 	"context"
+	"fmt"
 
 	"github.com/filecoin-project/go-address"
 	"github.com/filecoin-project/specs-actors/actors/abi"
 	"github.com/filecoin-project/specs-actors/actors/builtin/paych"
+	xerrors "github.com/pkg/errors"
 )
 
 // RetrievalClientNodeConnector adapts the node to provide an interface used by the retrieval client.
-type RetrievalClientNodeConnector struct{}
+type RetrievalClientNodeConnector struct {
+	paychMgr PaychMgrAPI
+	signer   RetrievalSigner
+}
 
 // NewRetrievalClientNodeConnector creates a new connector.
-func NewRetrievalClientNodeConnector() *RetrievalClientNodeConnector {
-	return &RetrievalClientNodeConnector{}
+func NewRetrievalClientNodeConnector(paychMgr PaychMgrAPI, signer RetrievalSigner) *RetrievalClientNodeConnector {
+	return &RetrievalClientNodeConnector{
+		paychMgr: paychMgr,
+		signer:   signer,
+	}
 }
 
 // GetOrCreatePaymentChannel retrieves a payment channel for the retrieval client.
+// An existing channel from the client to the miner is topped up with the funds
+// available; otherwise a new channel is created holding them.
 func (r *RetrievalClientNodeConnector) GetOrCreatePaymentChannel(ctx context.Context, clientAddress address.Address, minerAddress address.Address, clientFundsAvailable abi.TokenAmount) (address.Address, error) {
-	panic("TODO: go-fil-markets integration")
+	paychAddr, info := r.paychMgr.GetPaymentChannelByAccounts(clientAddress, minerAddress)
+	if info == nil {
+		return r.paychMgr.CreatePaymentChannel(ctx, clientAddress, minerAddress, clientFundsAvailable)
+	}
+
+	if err := r.paychMgr.AddFundsToChannel(ctx, paychAddr, clientFundsAvailable); err != nil {
+		return address.Undef, err
+	}
+	return paychAddr, nil
 }
 
 // AllocateLane creates a lane for the retrieval client.
 func (r *RetrievalClientNodeConnector) AllocateLane(paymentChannel address.Address) (int64, error) {
-	panic("TODO: go-fil-markets integration")
+	lane, err := r.paychMgr.AllocateLane(paymentChannel)
+	if err != nil {
+		return 0, err
+	}
+	return int64(lane), nil
 }
 
 // CreatePaymentVoucher creates a payment voucher for the retrieval client.
+// The amount is the total paid on the lane so far, and the voucher is signed
+// by the channel's owner.
 func (r *RetrievalClientNodeConnector) CreatePaymentVoucher(ctx context.Context, paymentChannel address.Address, amount abi.TokenAmount, lane int64) (*paych.SignedVoucher, error) {
-	panic("TODO: go-fil-markets integration")
+	info, err := r.paychMgr.GetPaymentChannelInfo(paymentChannel)
+	if err != nil {
+		return nil, err
+	}
+
+	var laneState *paych.LaneState
+	for _, ls := range info.State.LaneStates {
+		if ls.ID == uint64(lane) {
+			laneState = ls
+		}
+	}
+	if laneState == nil {
+		return nil, fmt.Errorf("lane %d not allocated in payment channel %s", lane, paymentChannel)
+	}
+
+	voucher := &paych.SignedVoucher{
+		Lane:   laneState.ID,
+		Nonce:  laneState.Nonce + 1,
+		Amount: amount,
+	}
+	vb, err := voucher.SigningBytes()
+	if err != nil {
+		return nil, xerrors.Wrap(err, "failed to encode voucher for signing")
+	}
+	sig, err := r.signer.SignBytes(vb, info.Owner)
+	if err != nil {
+		return nil, xerrors.Wrap(err, "failed to sign voucher")
+	}
+	voucher.Signature = &sig
+
+	if err := r.paychMgr.CreateVoucher(paymentChannel, voucher); err != nil {
+		return nil, err
+	}
+	return voucher, nil
 }
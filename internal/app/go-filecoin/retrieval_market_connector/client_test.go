@@ -0,0 +1,92 @@
+package retrievalmarketconnector_test
+
+import (
+	"context"
+	"testing"
+
+	"github.com/filecoin-project/specs-actors/actors/abi"
+	"github.com/filecoin-project/specs-actors/actors/builtin/paych"
+	spec_test "github.com/filecoin-project/specs-actors/support/testing"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/filecoin-project/go-filecoin/internal/app/go-filecoin/paymentchannel"
+	. "github.com/filecoin-project/go-filecoin/internal/app/go-filecoin/retrieval_market_connector"
+	tf "github.com/filecoin-project/go-filecoin/internal/pkg/testhelpers/testflags"
+)
+
+func TestRetrievalClientConnector_GetOrCreatePaymentChannel(t *testing.T) {
+	tf.UnitTest(t)
+	ctx := context.Background()
+	pchan := spec_test.NewIDAddr(t, 100)
+	clientAddr := spec_test.NewIDAddr(t, 101)
+	minerAddr := spec_test.NewIDAddr(t, 102)
+	funds := abi.NewTokenAmount(1000)
+
+	newFake := func() *RetrievalMarketClientFakeAPI {
+		rmp := NewRetrievalMarketClientFakeAPI(t, abi.NewTokenAmount(0))
+		rmp.ExpectedPmtChans[pchan] = &paymentchannel.ChannelInfo{
+			Owner: clientAddr,
+			State: &paych.State{From: clientAddr, To: minerAddr, ToSend: abi.NewTokenAmount(0)},
+		}
+		return rmp
+	}
+
+	t.Run("creates channel if none exists", func(t *testing.T) {
+		rmp := newFake()
+		rcnc := NewRetrievalClientNodeConnector(rmp, rmp)
+
+		res, err := rcnc.GetOrCreatePaymentChannel(ctx, clientAddr, minerAddr, funds)
+		require.NoError(t, err)
+		assert.Equal(t, pchan, res)
+		assert.Empty(t, rmp.AddedFunds)
+		rmp.Verify()
+	})
+
+	t.Run("adds funds to existing channel", func(t *testing.T) {
+		rmp := newFake()
+		rmp.ActualPmtChans[pchan] = true
+		rcnc := NewRetrievalClientNodeConnector(rmp, rmp)
+
+		res, err := rcnc.GetOrCreatePaymentChannel(ctx, clientAddr, minerAddr, funds)
+		require.NoError(t, err)
+		assert.Equal(t, pchan, res)
+		assert.True(t, funds.Equals(rmp.AddedFunds[pchan]))
+	})
+}
+
+func TestRetrievalClientConnector_CreatePaymentVoucher(t *testing.T) {
+	tf.UnitTest(t)
+	ctx := context.Background()
+	pchan := spec_test.NewIDAddr(t, 100)
+	clientAddr := spec_test.NewIDAddr(t, 101)
+	minerAddr := spec_test.NewIDAddr(t, 102)
+
+	rmp := NewRetrievalMarketClientFakeAPI(t, abi.NewTokenAmount(0))
+	rmp.StubSignature(nil)
+	rmp.ActualPmtChans[pchan] = true
+	rmp.ExpectedPmtChans[pchan] = &paymentchannel.ChannelInfo{
+		Owner: clientAddr,
+		State: &paych.State{From: clientAddr, To: minerAddr, ToSend: abi.NewTokenAmount(0)},
+	}
+	rcnc := NewRetrievalClientNodeConnector(rmp, rmp)
+
+	lane, err := rcnc.AllocateLane(pchan)
+	require.NoError(t, err)
+
+	t.Run("creates signed voucher on allocated lane", func(t *testing.T) {
+		amt := abi.NewTokenAmount(50)
+		voucher, err := rcnc.CreatePaymentVoucher(ctx, pchan, amt, lane)
+		require.NoError(t, err)
+		assert.Equal(t, uint64(lane), voucher.Lane)
+		assert.Equal(t, uint64(2), voucher.Nonce)
+		assert.True(t, amt.Equals(voucher.Amount))
+		require.NotNil(t, voucher.Signature)
+		assert.Equal(t, rmp.Sig, *voucher.Signature)
+	})
+
+	t.Run("errors if lane is not allocated", func(t *testing.T) {
+		_, err := rcnc.CreatePaymentVoucher(ctx, pchan, abi.NewTokenAmount(50), lane+1)
+		assert.Error(t, err)
+	})
+}
@@ -31,7 +31,8 @@ type PaychMgrAPI interface {
 	AllocateLane(paychAddr address.Address) (uint64, error)
 	GetPaymentChannelInfo(paychAddr address.Address) (*paymentchannel.ChannelInfo, error)
 	GetPaymentChannelByAccounts(payer, payee address.Address) (address.Address, *paymentchannel.ChannelInfo)
-	CreatePaymentChannel(payer, payee address.Address) error
+	CreatePaymentChannel(ctx context.Context, payer, payee address.Address, amt abi.TokenAmount) (address.Address, error)
+	AddFundsToChannel(ctx context.Context, paychAddr address.Address, amt abi.TokenAmount) error
 	CreateVoucher(paychAddr address.Address, voucher *paychActor.SignedVoucher) error
 	SaveVoucher(paychAddr address.Address, voucher *paychActor.SignedVoucher, proof []byte, expected abi.TokenAmount) (actual abi.TokenAmount, err error)
 }
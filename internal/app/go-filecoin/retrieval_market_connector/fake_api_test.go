@@ -39,6 +39,7 @@ type RetrievalMarketClientFakeAPI struct {
 	Balance                 abi.TokenAmount
 	BalanceErr              error
 	CreatePaymentChannelErr error
+	AddedFunds              map[address.Address]abi.TokenAmount
 	WorkerAddr              address.Address
 	WorkerAddrErr           error
 	Nonce                   uint64
@@ -70,6 +71,7 @@ func NewRetrievalMarketClientFakeAPI(t *testing.T, bal abi.TokenAmount) *Retriev
 		Nonce:             rand.Uint64(),
 		ExpectedPmtChans:  make(map[address.Address]*paymentchannel.ChannelInfo),
 		ActualPmtChans:    make(map[address.Address]bool),
+		AddedFunds:        make(map[address.Address]abi.TokenAmount),
 		ExpectedVouchers:  make(map[address.Address]*paymentchannel.VoucherInfo),
 		ActualVouchers:    make(map[address.Address]bool),
 		ExpectedSectorIDs: make(map[uint64]string),
@@ -96,17 +98,26 @@ func (rmFake *RetrievalMarketClientFakeAPI) AllocateLane(paychAddr address.Addre
 	return ln.ID, rmFake.AllocateLaneErr
 }
 
-func (rmFake *RetrievalMarketClientFakeAPI) CreatePaymentChannel(clientAddress, minerAddress address.Address) error {
+func (rmFake *RetrievalMarketClientFakeAPI) CreatePaymentChannel(_ context.Context, clientAddress, minerAddress address.Address, _ abi.TokenAmount) (address.Address, error) {
 	if rmFake.CreatePaymentChannelErr != nil {
-		return rmFake.CreatePaymentChannelErr
+		return address.Undef, rmFake.CreatePaymentChannelErr
 	}
 	for paychAddr, chinfo := range rmFake.ExpectedPmtChans {
 		if chinfo.State.From == clientAddress && chinfo.State.To == minerAddress {
 			rmFake.ActualPmtChans[paychAddr] = true
-			return rmFake.CreatePaymentChannelErr
+			return paychAddr, nil
 		}
 	}
 	rmFake.t.Fatalf("unexpected failure in CreatePaymentChannel")
+	return address.Undef, nil
+}
+
+// AddFundsToChannel mocks sending funds to an existing payment channel
+func (rmFake *RetrievalMarketClientFakeAPI) AddFundsToChannel(_ context.Context, paychAddr address.Address, amt abi.TokenAmount) error {
+	if _, ok := rmFake.ActualPmtChans[paychAddr]; !ok {
+		return xerrors.Errorf("payment channel does not exist: %s", paychAddr.String())
+	}
+	rmFake.AddedFunds[paychAddr] = amt
 	return nil
 }
 
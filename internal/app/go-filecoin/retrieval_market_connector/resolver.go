@@ -1,18 +1,289 @@
 package retrievalmarketconnector
 
 import (
+	"container/list"
+	"context"
+	"sync"
+	"time"
+
+	"github.com/filecoin-project/go-address"
 	iface "github.com/filecoin-project/go-fil-markets/retrievalmarket"
+	"github.com/filecoin-project/go-fil-markets/storagemarket"
+	"github.com/ipfs/go-cid"
+	logging "github.com/ipfs/go-log"
+	"github.com/libp2p/go-libp2p-core/peer"
+	xerrors "github.com/pkg/errors"
+
+	"github.com/filecoin-project/go-filecoin/internal/pkg/block"
+	"github.com/filecoin-project/go-filecoin/internal/pkg/clock"
+	"github.com/filecoin-project/go-filecoin/internal/pkg/state"
+)
+
+var log = logging.Logger("retrievalmarketconnector")
+
+const (
+	// ResolverCacheTTL is how long the providers found for a piece are reused.
+	ResolverCacheTTL = 5 * time.Minute
+	// ResolverCacheSize is the most pieces whose providers are cached.
+	ResolverCacheSize = 1024
+	// resolverDHTTimeout bounds the DHT queries made for a piece.
+	resolverDHTTimeout = 10 * time.Second
+	// resolverDHTMaxProviders is the most providers requested from the DHT
+	// for each payload.
+	resolverDHTMaxProviders = 20
 )
 
-// RetrievalPeerResolverConnector adapts the node to provide an interface for the RetrievalPeer
-type RetrievalPeerResolverConnector struct{}
+// ResolverStateView is the subset of the state view used to find the miners
+// storing a piece.
+type ResolverStateView interface {
+	MarketPieceProviders(ctx context.Context, pieceCID cid.Cid) ([]address.Address, error)
+	MinerPeerID(ctx context.Context, maddr address.Address) (peer.ID, error)
+	PowerMiners(ctx context.Context) ([]address.Address, error)
+}
 
-// NewRetrievalPeerResolverConnector creates a new connector
-func NewRetrievalPeerResolverConnector() *RetrievalPeerResolverConnector {
-	return &RetrievalPeerResolverConnector{}
+// ResolverChainAPI provides state views at the chain head.
+type ResolverChainAPI interface {
+	Head() block.TipSetKey
+	StateView(key block.TipSetKey) (*state.View, error)
+}
+
+// ProviderFinder finds content providers in the DHT.
+type ProviderFinder interface {
+	FindProvidersAsync(ctx context.Context, key cid.Cid, count int) <-chan peer.AddrInfo
+}
+
+// PayloadFinder finds the CIDs of the payloads stored in a piece, which are
+// the keys under which retrieval providers advertise it in the DHT.
+type PayloadFinder interface {
+	PayloadCIDs(ctx context.Context, pieceCID cid.Cid) ([]cid.Cid, error)
+}
+
+type resolverCacheEntry struct {
+	piece   cid.Cid
+	peers   []iface.RetrievalPeer
+	expires time.Time
+}
+
+// RetrievalPeerResolverConnector adapts the node to provide an interface for the RetrievalPeer.
+// Peers are found from the providers of storage deals for the piece, and from
+// the providers of its payloads in the DHT if a provider finder and a payload
+// finder are configured. The peers found in the DHT are returned only if they
+// operate a miner.
+type RetrievalPeerResolverConnector struct {
+	ctx      context.Context
+	views    func() (ResolverStateView, error)
+	finder   ProviderFinder
+	payloads PayloadFinder
+	clock    clock.Clock
+
+	lk    sync.Mutex
+	order *list.List // of *resolverCacheEntry, most recently used first
+	cache map[cid.Cid]*list.Element
+}
+
+// NewRetrievalPeerResolverConnector creates a new connector. The finder or
+// the payload finder may be nil, in which case the DHT is not queried.
+func NewRetrievalPeerResolverConnector(ctx context.Context, chain ResolverChainAPI, finder ProviderFinder, payloads PayloadFinder, clk clock.Clock) *RetrievalPeerResolverConnector {
+	views := func() (ResolverStateView, error) {
+		return chain.StateView(chain.Head())
+	}
+	return newRetrievalPeerResolverConnector(ctx, views, finder, payloads, clk)
+}
+
+func newRetrievalPeerResolverConnector(ctx context.Context, views func() (ResolverStateView, error), finder ProviderFinder, payloads PayloadFinder, clk clock.Clock) *RetrievalPeerResolverConnector {
+	return &RetrievalPeerResolverConnector{
+		ctx:      ctx,
+		views:    views,
+		finder:   finder,
+		payloads: payloads,
+		clock:    clk,
+		order:    list.New(),
+		cache:    make(map[cid.Cid]*list.Element),
+	}
 }
 
 // GetPeers gets peers for the piece CID
 func (r *RetrievalPeerResolverConnector) GetPeers(pieceCID []byte) ([]iface.RetrievalPeer, error) {
-	panic("TODO: go-fil-markets integration")
+	_, c, err := cid.CidFromBytes(pieceCID)
+	if err != nil {
+		return nil, xerrors.Wrap(err, "invalid piece cid")
+	}
+
+	if peers, ok := r.cached(c); ok {
+		return peers, nil
+	}
+
+	peers, err := r.findPeers(c)
+	if err != nil {
+		return nil, err
+	}
+	r.cachePeers(c, peers)
+	return peers, nil
+}
+
+// cached returns the unexpired peers cached for a piece.
+func (r *RetrievalPeerResolverConnector) cached(pieceCID cid.Cid) ([]iface.RetrievalPeer, bool) {
+	r.lk.Lock()
+	defer r.lk.Unlock()
+	elem, ok := r.cache[pieceCID]
+	if !ok {
+		return nil, false
+	}
+	entry := elem.Value.(*resolverCacheEntry)
+	if !r.clock.Now().Before(entry.expires) {
+		r.order.Remove(elem)
+		delete(r.cache, pieceCID)
+		return nil, false
+	}
+	r.order.MoveToFront(elem)
+	return entry.peers, true
+}
+
+// cachePeers caches the peers of a piece, evicting the least recently used
+// piece when the cache is full.
+func (r *RetrievalPeerResolverConnector) cachePeers(pieceCID cid.Cid, peers []iface.RetrievalPeer) {
+	r.lk.Lock()
+	defer r.lk.Unlock()
+	entry := &resolverCacheEntry{piece: pieceCID, peers: peers, expires: r.clock.Now().Add(ResolverCacheTTL)}
+	if elem, ok := r.cache[pieceCID]; ok {
+		elem.Value = entry
+		r.order.MoveToFront(elem)
+		return
+	}
+	r.cache[pieceCID] = r.order.PushFront(entry)
+	for r.order.Len() > ResolverCacheSize {
+		oldest := r.order.Back()
+		r.order.Remove(oldest)
+		delete(r.cache, oldest.Value.(*resolverCacheEntry).piece)
+	}
+}
+
+func (r *RetrievalPeerResolverConnector) findPeers(pieceCID cid.Cid) ([]iface.RetrievalPeer, error) {
+	view, err := r.views()
+	if err != nil {
+		return nil, err
+	}
+	miners, err := view.MarketPieceProviders(r.ctx, pieceCID)
+	if err != nil {
+		return nil, xerrors.Wrapf(err, "failed to find deals for piece %s", pieceCID)
+	}
+
+	var peers []iface.RetrievalPeer
+	seen := make(map[peer.ID]struct{})
+	for _, miner := range miners {
+		pid, err := view.MinerPeerID(r.ctx, miner)
+		if err != nil {
+			// The miner may have been removed since the deal was made.
+			log.Warnf("failed to get peer id of miner %s: %s", miner, err)
+			continue
+		}
+		seen[pid] = struct{}{}
+		peers = append(peers, iface.RetrievalPeer{Address: miner, ID: pid})
+	}
+
+	if r.finder == nil || r.payloads == nil {
+		return peers, nil
+	}
+	found, err := r.findPayloadProviders(pieceCID, seen)
+	if err != nil {
+		return nil, err
+	}
+	if len(found) == 0 {
+		return peers, nil
+	}
+	dhtPeers, err := resolveMiners(r.ctx, view, found)
+	if err != nil {
+		return nil, err
+	}
+	return append(peers, dhtPeers...), nil
+}
+
+// findPayloadProviders returns the peers providing the payloads of a piece in
+// the DHT, other than the peers in `seen`.
+func (r *RetrievalPeerResolverConnector) findPayloadProviders(pieceCID cid.Cid, seen map[peer.ID]struct{}) (map[peer.ID]struct{}, error) {
+	payloads, err := r.payloads.PayloadCIDs(r.ctx, pieceCID)
+	if err != nil {
+		return nil, xerrors.Wrapf(err, "failed to find payloads of piece %s", pieceCID)
+	}
+
+	found := make(map[peer.ID]struct{})
+	ctx, cancel := context.WithTimeout(r.ctx, resolverDHTTimeout)
+	defer cancel()
+	for _, payload := range payloads {
+		for info := range r.finder.FindProvidersAsync(ctx, payload, resolverDHTMaxProviders) {
+			if _, ok := seen[info.ID]; !ok {
+				found[info.ID] = struct{}{}
+			}
+		}
+	}
+	return found, nil
+}
+
+// resolveMiners returns the miners operated by the peers in `pids`, in the
+// order of the power table. Peers operating no miner are dropped.
+func resolveMiners(ctx context.Context, view ResolverStateView, pids map[peer.ID]struct{}) ([]iface.RetrievalPeer, error) {
+	miners, err := view.PowerMiners(ctx)
+	if err != nil {
+		return nil, xerrors.Wrap(err, "failed to list miners")
+	}
+	var peers []iface.RetrievalPeer
+	resolved := make(map[peer.ID]struct{})
+	for _, miner := range miners {
+		pid, err := view.MinerPeerID(ctx, miner)
+		if err != nil {
+			log.Warnf("failed to get peer id of miner %s: %s", miner, err)
+			continue
+		}
+		if _, ok := pids[pid]; ok {
+			resolved[pid] = struct{}{}
+			peers = append(peers, iface.RetrievalPeer{Address: miner, ID: pid})
+		}
+	}
+	for pid := range pids {
+		if _, ok := resolved[pid]; !ok {
+			log.Debugf("dropping DHT provider %s operating no miner", pid)
+		}
+	}
+	return peers, nil
+}
+
+// LocalDealLister lists the storage deals made by this node as a client.
+type LocalDealLister interface {
+	ListLocalDeals(ctx context.Context) ([]storagemarket.ClientDeal, error)
+}
+
+// ClientDealPayloads finds the payloads of a piece among the storage deals
+// made by this node as a client.
+type ClientDealPayloads struct {
+	deals func() LocalDealLister
+}
+
+// NewClientDealPayloads creates a payload finder over the deals listed by the
+// lister `deals` returns, which may be nil while the node has no storage client.
+func NewClientDealPayloads(deals func() LocalDealLister) *ClientDealPayloads {
+	return &ClientDealPayloads{deals: deals}
+}
+
+// PayloadCIDs returns the distinct payload roots of the client deals for a piece.
+func (p *ClientDealPayloads) PayloadCIDs(ctx context.Context, pieceCID cid.Cid) ([]cid.Cid, error) {
+	lister := p.deals()
+	if lister == nil {
+		return nil, nil
+	}
+	deals, err := lister.ListLocalDeals(ctx)
+	if err != nil {
+		return nil, err
+	}
+	var payloads []cid.Cid
+	seen := make(map[cid.Cid]struct{})
+	for _, deal := range deals {
+		if deal.DataRef == nil || !deal.Proposal.PieceCID.Equals(pieceCID) {
+			continue
+		}
+		if _, ok := seen[deal.DataRef.Root]; !ok {
+			seen[deal.DataRef.Root] = struct{}{}
+			payloads = append(payloads, deal.DataRef.Root)
+		}
+	}
+	return payloads, nil
 }
@@ -0,0 +1,170 @@
+package retrievalmarketconnector
+
+import (
+	"context"
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/filecoin-project/go-address"
+	spec_test "github.com/filecoin-project/specs-actors/support/testing"
+	"github.com/ipfs/go-cid"
+	"github.com/libp2p/go-libp2p-core/peer"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	th "github.com/filecoin-project/go-filecoin/internal/pkg/testhelpers"
+	tf "github.com/filecoin-project/go-filecoin/internal/pkg/testhelpers/testflags"
+	"github.com/filecoin-project/go-filecoin/internal/pkg/types"
+)
+
+type fakeResolverView struct {
+	providers map[cid.Cid][]address.Address
+	miners    []address.Address
+	peers     map[address.Address]peer.ID
+	calls     int
+}
+
+func (v *fakeResolverView) MarketPieceProviders(_ context.Context, pieceCID cid.Cid) ([]address.Address, error) {
+	v.calls++
+	return v.providers[pieceCID], nil
+}
+
+func (v *fakeResolverView) MinerPeerID(_ context.Context, maddr address.Address) (peer.ID, error) {
+	return v.peers[maddr], nil
+}
+
+func (v *fakeResolverView) PowerMiners(_ context.Context) ([]address.Address, error) {
+	return v.miners, nil
+}
+
+type fakeProviderFinder struct {
+	providers map[cid.Cid][]peer.AddrInfo
+}
+
+func (f *fakeProviderFinder) FindProvidersAsync(_ context.Context, key cid.Cid, _ int) <-chan peer.AddrInfo {
+	out := make(chan peer.AddrInfo, len(f.providers[key]))
+	for _, p := range f.providers[key] {
+		out <- p
+	}
+	close(out)
+	return out
+}
+
+type fakePayloadFinder struct {
+	payloads map[cid.Cid][]cid.Cid
+}
+
+func (f *fakePayloadFinder) PayloadCIDs(_ context.Context, pieceCID cid.Cid) ([]cid.Cid, error) {
+	return f.payloads[pieceCID], nil
+}
+
+func TestRetrievalPeerResolverConnector_GetPeers(t *testing.T) {
+	tf.UnitTest(t)
+	ctx := context.Background()
+
+	pieceCID := types.CidFromString(t, "piece")
+	payloadCID := types.CidFromString(t, "payload")
+	miner1, miner2, miner3 := spec_test.NewIDAddr(t, 101), spec_test.NewIDAddr(t, 102), spec_test.NewIDAddr(t, 103)
+	pid1, pid2, pid3, pid4 := th.RequireIntPeerID(t, 1), th.RequireIntPeerID(t, 2), th.RequireIntPeerID(t, 3), th.RequireIntPeerID(t, 4)
+
+	newView := func() *fakeResolverView {
+		return &fakeResolverView{
+			providers: map[cid.Cid][]address.Address{pieceCID: {miner1, miner2}},
+			miners:    []address.Address{miner1, miner2, miner3},
+			peers:     map[address.Address]peer.ID{miner1: pid1, miner2: pid2, miner3: pid3},
+		}
+	}
+	payloads := &fakePayloadFinder{payloads: map[cid.Cid][]cid.Cid{pieceCID: {payloadCID}}}
+
+	t.Run("finds deal providers", func(t *testing.T) {
+		view := newView()
+		views := func() (ResolverStateView, error) { return view, nil }
+		r := newRetrievalPeerResolverConnector(ctx, views, nil, nil, th.NewFakeClock(time.Unix(1234567890, 0)))
+
+		peers, err := r.GetPeers(pieceCID.Bytes())
+		require.NoError(t, err)
+		require.Len(t, peers, 2)
+		assert.Equal(t, miner1, peers[0].Address)
+		assert.Equal(t, pid1, peers[0].ID)
+		assert.Equal(t, miner2, peers[1].Address)
+		assert.Equal(t, pid2, peers[1].ID)
+
+		peers, err = r.GetPeers(types.CidFromString(t, "other").Bytes())
+		require.NoError(t, err)
+		assert.Empty(t, peers)
+	})
+
+	t.Run("adds the miners of DHT providers of the payload", func(t *testing.T) {
+		view := newView()
+		views := func() (ResolverStateView, error) { return view, nil }
+		finder := &fakeProviderFinder{providers: map[cid.Cid][]peer.AddrInfo{
+			payloadCID: {{ID: pid2}, {ID: pid3}, {ID: pid4}},
+			// Providers of the piece CID itself are not looked up.
+			pieceCID: {{ID: pid4}},
+		}}
+		r := newRetrievalPeerResolverConnector(ctx, views, finder, payloads, th.NewFakeClock(time.Unix(1234567890, 0)))
+
+		peers, err := r.GetPeers(pieceCID.Bytes())
+		require.NoError(t, err)
+		// The peer operating no miner is dropped.
+		require.Len(t, peers, 3)
+		assert.Equal(t, miner3, peers[2].Address)
+		assert.Equal(t, pid3, peers[2].ID)
+	})
+
+	t.Run("skips the DHT without payloads", func(t *testing.T) {
+		view := newView()
+		views := func() (ResolverStateView, error) { return view, nil }
+		finder := &fakeProviderFinder{providers: map[cid.Cid][]peer.AddrInfo{payloadCID: {{ID: pid3}}}}
+		r := newRetrievalPeerResolverConnector(ctx, views, finder, &fakePayloadFinder{}, th.NewFakeClock(time.Unix(1234567890, 0)))
+
+		peers, err := r.GetPeers(pieceCID.Bytes())
+		require.NoError(t, err)
+		assert.Len(t, peers, 2)
+	})
+
+	t.Run("caches results until they expire", func(t *testing.T) {
+		view := newView()
+		views := func() (ResolverStateView, error) { return view, nil }
+		clk := th.NewFakeClock(time.Unix(1234567890, 0))
+		r := newRetrievalPeerResolverConnector(ctx, views, nil, nil, clk)
+
+		_, err := r.GetPeers(pieceCID.Bytes())
+		require.NoError(t, err)
+		_, err = r.GetPeers(pieceCID.Bytes())
+		require.NoError(t, err)
+		assert.Equal(t, 1, view.calls)
+
+		clk.Advance(ResolverCacheTTL)
+		_, err = r.GetPeers(pieceCID.Bytes())
+		require.NoError(t, err)
+		assert.Equal(t, 2, view.calls)
+	})
+
+	t.Run("evicts the least recently used piece", func(t *testing.T) {
+		view := newView()
+		views := func() (ResolverStateView, error) { return view, nil }
+		r := newRetrievalPeerResolverConnector(ctx, views, nil, nil, th.NewFakeClock(time.Unix(1234567890, 0)))
+
+		_, err := r.GetPeers(pieceCID.Bytes())
+		require.NoError(t, err)
+		for i := 0; i < ResolverCacheSize; i++ {
+			_, err := r.GetPeers(types.CidFromString(t, fmt.Sprintf("other %d", i)).Bytes())
+			require.NoError(t, err)
+		}
+		assert.Len(t, r.cache, ResolverCacheSize)
+
+		calls := view.calls
+		_, err = r.GetPeers(pieceCID.Bytes())
+		require.NoError(t, err)
+		assert.Equal(t, calls+1, view.calls)
+	})
+
+	t.Run("rejects invalid cid", func(t *testing.T) {
+		views := func() (ResolverStateView, error) { return newView(), nil }
+		r := newRetrievalPeerResolverConnector(ctx, views, nil, nil, th.NewFakeClock(time.Unix(1234567890, 0)))
+		_, err := r.GetPeers([]byte("not a cid"))
+		assert.Error(t, err)
+	})
+}
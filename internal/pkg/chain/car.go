@@ -10,6 +10,7 @@ import (
 	"github.com/ipfs/go-cid"
 	format "github.com/ipfs/go-ipld-format"
 	logging "github.com/ipfs/go-log"
+	"github.com/pkg/errors"
 
 	"github.com/filecoin-project/go-filecoin/internal/pkg/block"
 	"github.com/filecoin-project/go-filecoin/internal/pkg/encoding"
@@ -39,6 +40,24 @@ type carHeader struct {
 
 // Export will export a chain (all blocks and their messages) to the writer `out`.
 func Export(ctx context.Context, headTS block.TipSet, cr carChainReader, mr carMessageReader, sr carStateReader, out io.Writer) error {
+	return export(ctx, headTS, 0, cr, mr, sr, out)
+}
+
+// ExportRecent exports the headers of a chain back to genesis, but the
+// messages, receipts and state trees of only the `recentStateRoots` most
+// recent tipsets, to the writer `out`. The state trees exported are those
+// the tipsets' blocks were mined on.
+func ExportRecent(ctx context.Context, headTS block.TipSet, recentStateRoots int, cr carChainReader, mr carMessageReader, sr carStateReader, out io.Writer) error {
+	if recentStateRoots < 1 {
+		return errors.New("at least one recent state root must be exported")
+	}
+	return export(ctx, headTS, recentStateRoots, cr, mr, sr, out)
+}
+
+// export writes the chain to `out`. If recent is positive only the most
+// recent tipsets have their messages, receipts and states written, otherwise
+// all messages and receipts and the genesis state are.
+func export(ctx context.Context, headTS block.TipSet, recent int, cr carChainReader, mr carMessageReader, sr carStateReader, out io.Writer) error {
 	// ensure we don't duplicate writes to the car file. // e.g. only write EmptyMessageCID once.
 	filter := make(map[cid.Cid]bool)
 
@@ -64,10 +83,7 @@ func Export(ctx context.Context, headTS block.TipSet, cr carChainReader, mr carM
 
 	iter := IterAncestors(ctx, cr, headTS)
 	// accumulate TipSets in descending order.
-	for ; !iter.Complete(); err = iter.Next() {
-		if err != nil {
-			return err
-		}
+	for depth := 0; !iter.Complete(); depth++ {
 		tip := iter.Value()
 		// write blocks
 		for i := 0; i < tip.Len(); i++ {
@@ -81,6 +97,11 @@ func Export(ctx context.Context, headTS block.TipSet, cr carChainReader, mr carM
 				filter[hdr.Cid()] = true
 			}
 
+			if recent > 0 && depth >= recent {
+				// Only the header of older tipsets is needed.
+				continue
+			}
+
 			meta, err := mr.LoadTxMeta(ctx, hdr.Messages.Cid)
 			if err != nil {
 				return err
@@ -129,19 +150,28 @@ func Export(ctx context.Context, headTS block.TipSet, cr carChainReader, mr carM
 				filter[hdr.MessageReceipts.Cid] = true
 			}
 
-			if hdr.Height == 0 {
+			if (recent > 0 || hdr.Height == 0) && !filter[hdr.StateRoot.Cid] {
 				logCar.Debugf("writing state tree: %s", hdr.StateRoot)
 				stateRoots, err := sr.ChainStateTree(ctx, hdr.StateRoot.Cid)
 				if err != nil {
 					return err
 				}
 				for _, r := range stateRoots {
+					// State trees of consecutive tipsets share most of their nodes.
+					if filter[r.Cid()] {
+						continue
+					}
 					if err := carutil.LdWrite(out, r.Cid().Bytes(), r.RawData()); err != nil {
 						return err
 					}
+					filter[r.Cid()] = true
 				}
+				filter[hdr.StateRoot.Cid] = true
 			}
 		}
+		if err := iter.Next(); err != nil {
+			return err
+		}
 	}
 	return nil
 }
@@ -191,9 +221,10 @@ type carStore interface {
 	Put(blocks.Block) error
 }
 
-// Import imports a chain from `in` to `bs`.
+// Import imports a chain from `in` to `bs`. Blocks whose data does not hash
+// to their CID are rejected; the chain itself is checked by VerifySnapshot.
 func Import(ctx context.Context, cs carStore, in io.Reader) (block.TipSetKey, error) {
-	header, err := car.LoadCar(cs, in)
+	header, err := car.LoadCar(hashCheckingCarStore{cs}, in)
 	if err != nil {
 		return block.UndefTipSet.Key(), err
 	}
@@ -201,6 +232,22 @@ func Import(ctx context.Context, cs carStore, in io.Reader) (block.TipSetKey, er
 	return headKey, nil
 }
 
+// hashCheckingCarStore verifies blocks before putting them in the wrapped store.
+type hashCheckingCarStore struct {
+	carStore
+}
+
+func (cs hashCheckingCarStore) Put(b blocks.Block) error {
+	c, err := b.Cid().Prefix().Sum(b.RawData())
+	if err != nil {
+		return err
+	}
+	if !c.Equals(b.Cid()) {
+		return errors.Errorf("car block data does not match cid %s", b.Cid())
+	}
+	return cs.carStore.Put(b)
+}
+
 // carExportBlockstore allows a structure that would normally put blocks in a block store to output to a car file instead.
 type carExportBlockstore struct {
 	out io.Writer
@@ -27,6 +27,7 @@ func Init(ctx context.Context, r repo.Repo, bs bstore.Blockstore, cst cbor.IpldS
 		return nil, errors.Wrap(err, "failed to generate genesis block")
 	}
 	chainStore := NewStore(r.ChainDatastore(), cst, NewStatusReporter(), genesis.Cid())
+	chainStore.SetMessageIndex(NewMessageIndex(r.ChainDatastore(), NewMessageStore(bs)))
 
 	// Persist the genesis tipset to the repo.
 	genTsas := &TipSetMetadata{
@@ -40,6 +41,9 @@ func Init(ctx context.Context, r repo.Repo, bs bstore.Blockstore, cst cbor.IpldS
 	if err = chainStore.SetHead(ctx, genTipSet); err != nil {
 		return nil, errors.Wrap(err, "failed to persist genesis block in chain store")
 	}
+	if err = chainStore.BuildMessageIndex(ctx); err != nil {
+		return nil, errors.Wrap(err, "failed to index genesis messages")
+	}
 	// Persist the genesis cid to the repo.
 	val, err := json.Marshal(genesis.Cid())
 	if err != nil {
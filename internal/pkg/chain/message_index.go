@@ -0,0 +1,518 @@
+package chain
+
+import (
+	"context"
+	"sync"
+
+	"github.com/filecoin-project/specs-actors/actors/abi"
+	"github.com/ipfs/go-cid"
+	"github.com/ipfs/go-datastore"
+	"github.com/ipfs/go-datastore/query"
+	"github.com/pkg/errors"
+
+	"github.com/filecoin-project/go-filecoin/internal/pkg/block"
+	e "github.com/filecoin-project/go-filecoin/internal/pkg/enccid"
+	"github.com/filecoin-project/go-filecoin/internal/pkg/encoding"
+	"github.com/filecoin-project/go-filecoin/internal/pkg/repo"
+	"github.com/filecoin-project/go-filecoin/internal/pkg/types"
+)
+
+// messageIndexPrefix is the datastore namespace under which message locations are written.
+const messageIndexPrefix = "/chain/msgindex/msg/"
+
+// MessageIndexHeadKey is the key at which the tipset key up to which the message
+// index is current is written in the datastore.
+var MessageIndexHeadKey = datastore.NewKey("/chain/msgindex/head")
+
+// messageIndexBaseKey is the key at which the height above which the index
+// covers the chain is written. An index without a base covers the chain from
+// genesis.
+var messageIndexBaseKey = datastore.NewKey("/chain/msgindex/base")
+
+// ErrNoMessageIndex is returned when a message lookup is requested from a store
+// without a message index.
+var ErrNoMessageIndex = errors.New("chain store has no message index")
+
+// ErrMessageIndexNotReady is returned when a message lookup is requested while
+// the message index is not built or is behind the head of the store.
+var ErrMessageIndexNotReady = errors.New("message index is not up to date with the head")
+
+// MessageLocation records where a message was included on chain.
+type MessageLocation struct {
+	// TipSet is the key of the tipset in which the message was included.
+	TipSet block.TipSetKey
+	// Height is the height of that tipset.
+	Height abi.ChainEpoch
+	// Block is the cid of the first block in the tipset that includes the message.
+	Block e.Cid
+	// ReceiptIndex is the index of the message's receipt among the tipset's receipts.
+	ReceiptIndex uint64
+}
+
+// MessageIndex is a persistent mapping from message cid to the location of the
+// message on the current best chain. It is kept up to date by the Store on
+// every head change, rewinding the entries of tipsets that are reorged out.
+type MessageIndex struct {
+	ds       repo.Datastore
+	messages MessageProvider
+
+	// Serializes updates to the index.
+	mu sync.Mutex
+	// building is set while the index is being built from the chain.
+	building bool
+
+	headLk sync.RWMutex
+	// head is the key of the tipset up to which the index is current. It is
+	// empty while the index is not built.
+	head block.TipSetKey
+}
+
+// NewMessageIndex creates a message index persisted in the given datastore.
+func NewMessageIndex(ds repo.Datastore, messages MessageProvider) *MessageIndex {
+	return &MessageIndex{
+		ds:       ds,
+		messages: messages,
+	}
+}
+
+// Get returns the location of the message with cid c, and whether it is
+// on the indexed chain at all.
+func (mi *MessageIndex) Get(c cid.Cid) (*MessageLocation, bool, error) {
+	bb, err := mi.ds.Get(messageIndexKey(c))
+	if err == datastore.ErrNotFound {
+		return nil, false, nil
+	}
+	if err != nil {
+		return nil, false, errors.Wrapf(err, "failed to read message index entry for %s", c)
+	}
+
+	var loc MessageLocation
+	if err := encoding.Decode(bb, &loc); err != nil {
+		return nil, false, errors.Wrapf(err, "failed to decode message index entry for %s", c)
+	}
+	return &loc, true, nil
+}
+
+// IsCurrent returns true if the index has been built and is up to date with
+// the tipset with key head.
+func (mi *MessageIndex) IsCurrent(head block.TipSetKey) bool {
+	mi.headLk.RLock()
+	defer mi.headLk.RUnlock()
+	return !mi.head.Empty() && mi.head.Equals(head)
+}
+
+// Update moves the index from the head it last recorded to the new head,
+// reverting the tipsets only on the old chain and applying the tipsets only on
+// the new chain. Resuming from the recorded head means a failed or interrupted
+// update is completed by the next one.
+// Update returns ErrMessageIndexNotReady if the index has not been built, see
+// Build and Rebuild. Head changes during a build are indexed once it completes.
+func (mi *MessageIndex) Update(ctx context.Context, tipsets TipSetProvider, newHead block.TipSet) error {
+	mi.mu.Lock()
+	defer mi.mu.Unlock()
+
+	if mi.building {
+		return nil
+	}
+	oldHead, found, err := mi.loadHead(tipsets)
+	if err != nil {
+		return err
+	}
+	if !found {
+		return ErrMessageIndexNotReady
+	}
+	return mi.update(ctx, tipsets, oldHead, newHead)
+}
+
+// Build indexes every tipset from genesis to the head returned by `head` if
+// the index has never been written, then brings the index up to date with
+// that head. Building may take long, and head changes meanwhile are not
+// blocked: they are indexed once the build completes.
+func (mi *MessageIndex) Build(ctx context.Context, tipsets TipSetProvider, head func() (block.TipSet, error)) error {
+	return mi.build(ctx, tipsets, head, false)
+}
+
+// Rebuild discards the index and builds it again like Build. It recovers an
+// index that fails to update. Rebuild does nothing if a build is in progress.
+func (mi *MessageIndex) Rebuild(ctx context.Context, tipsets TipSetProvider, head func() (block.TipSet, error)) error {
+	return mi.build(ctx, tipsets, head, true)
+}
+
+// Seed discards the index and indexes only `tipsets`, which are ordered by
+// decreasing height from the head and are all above height `base`. Tipsets at
+// or below `base` are never indexed afterwards. Seed is used for chains
+// imported without the messages of their older tipsets.
+func (mi *MessageIndex) Seed(ctx context.Context, base abi.ChainEpoch, tipsets []block.TipSet) error {
+	if len(tipsets) == 0 {
+		return errors.New("no tipsets to seed the message index with")
+	}
+
+	mi.mu.Lock()
+	defer mi.mu.Unlock()
+	if mi.building {
+		return errors.New("message index is being built")
+	}
+
+	mi.setHead(block.TipSetKey{})
+	if err := mi.clear(); err != nil {
+		return err
+	}
+	val, err := encoding.Encode(base)
+	if err != nil {
+		return err
+	}
+	if err := mi.ds.Put(messageIndexBaseKey, val); err != nil {
+		return err
+	}
+	for i := len(tipsets) - 1; i >= 0; i-- {
+		if err := mi.apply(ctx, tipsets[i]); err != nil {
+			return err
+		}
+	}
+	return mi.writeHead(tipsets[0].Key())
+}
+
+func (mi *MessageIndex) build(ctx context.Context, tipsets TipSetProvider, head func() (block.TipSet, error), force bool) error {
+	mi.mu.Lock()
+	if mi.building {
+		mi.mu.Unlock()
+		return nil
+	}
+	found := false
+	if !force {
+		var err error
+		if _, found, err = mi.loadHead(tipsets); err != nil {
+			mi.mu.Unlock()
+			return err
+		}
+	}
+	if !found {
+		// Updates do nothing while building, so the entries can be written
+		// without holding the lock.
+		mi.building = true
+		mi.setHead(block.TipSetKey{})
+	}
+	mi.mu.Unlock()
+
+	if !found {
+		built, err := head()
+		if err == nil {
+			err = mi.rebuild(ctx, tipsets, built)
+		}
+
+		mi.mu.Lock()
+		mi.building = false
+		if err == nil {
+			err = mi.writeHead(built.Key())
+		}
+		mi.mu.Unlock()
+		if err != nil {
+			return err
+		}
+	}
+
+	current, err := head()
+	if err != nil {
+		return err
+	}
+	return mi.Update(ctx, tipsets, current)
+}
+
+func (mi *MessageIndex) update(ctx context.Context, tipsets TipSetProvider, oldHead, newHead block.TipSet) error {
+	if oldHead.Equals(newHead) {
+		return nil
+	}
+
+	reverted, applied, err := CollectTipsToCommonAncestor(ctx, tipsets, oldHead, newHead)
+	if err != nil {
+		return errors.Wrap(err, "failed to collect tipsets for message index")
+	}
+	// Reverted tipsets are ordered by decreasing height, which is the order to unwind them.
+	for _, ts := range reverted {
+		if err := mi.revert(ctx, ts); err != nil {
+			return err
+		}
+	}
+	// Applied tipsets are written from the lowest height so that a message
+	// included more than once is located at its first, executed, inclusion.
+	Reverse(applied)
+	for _, ts := range applied {
+		if err := mi.apply(ctx, ts); err != nil {
+			return err
+		}
+	}
+	return mi.writeHead(newHead.Key())
+}
+
+// rebuild discards the index and re-indexes every tipset from the base, or
+// genesis, to head. It does not record the head.
+func (mi *MessageIndex) rebuild(ctx context.Context, tipsets TipSetProvider, head block.TipSet) error {
+	logStore.Infof("rebuilding message index from %s", head.String())
+	if err := mi.clear(); err != nil {
+		return err
+	}
+	base, hasBase, err := mi.loadBase()
+	if err != nil {
+		return err
+	}
+
+	var tips []block.TipSet
+	for iterator := IterAncestors(ctx, tipsets, head); err == nil && !iterator.Complete(); err = iterator.Next() {
+		var h abi.ChainEpoch
+		if h, err = iterator.Value().Height(); err != nil {
+			break
+		}
+		if hasBase && h <= base {
+			break
+		}
+		tips = append(tips, iterator.Value())
+	}
+	if err != nil {
+		return errors.Wrap(err, "failed to traverse chain for message index")
+	}
+
+	Reverse(tips)
+	for _, ts := range tips {
+		if err := mi.apply(ctx, ts); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// clear deletes the recorded head and every location entry.
+func (mi *MessageIndex) clear() error {
+	res, err := mi.ds.Query(query.Query{Prefix: messageIndexPrefix, KeysOnly: true})
+	if err != nil {
+		return err
+	}
+	entries, err := res.Rest()
+	if err != nil {
+		return err
+	}
+
+	batch, err := mi.ds.Batch()
+	if err != nil {
+		return err
+	}
+	for _, entry := range entries {
+		if err := batch.Delete(datastore.NewKey(entry.Key)); err != nil {
+			return err
+		}
+	}
+	if err := batch.Delete(MessageIndexHeadKey); err != nil {
+		return err
+	}
+	return batch.Commit()
+}
+
+// apply writes a location entry for every message in the tipset that is not
+// already located in an earlier tipset.
+func (mi *MessageIndex) apply(ctx context.Context, ts block.TipSet) error {
+	h, err := ts.Height()
+	if err != nil {
+		return err
+	}
+	if covered, err := mi.covers(h); err != nil || !covered {
+		return err
+	}
+	msgs, err := mi.tipSetMessages(ctx, ts)
+	if err != nil {
+		return err
+	}
+
+	batch, err := mi.ds.Batch()
+	if err != nil {
+		return err
+	}
+	for _, m := range msgs {
+		has, err := mi.ds.Has(messageIndexKey(m.cid))
+		if err != nil {
+			return err
+		}
+		if has {
+			continue
+		}
+		val, err := encoding.Encode(MessageLocation{
+			TipSet:       ts.Key(),
+			Height:       h,
+			Block:        e.NewCid(m.block),
+			ReceiptIndex: m.receiptIndex,
+		})
+		if err != nil {
+			return err
+		}
+		if err := batch.Put(messageIndexKey(m.cid), val); err != nil {
+			return err
+		}
+	}
+	return batch.Commit()
+}
+
+// revert removes the location entries pointing at the tipset.
+func (mi *MessageIndex) revert(ctx context.Context, ts block.TipSet) error {
+	h, err := ts.Height()
+	if err != nil {
+		return err
+	}
+	if covered, err := mi.covers(h); err != nil || !covered {
+		return err
+	}
+	msgs, err := mi.tipSetMessages(ctx, ts)
+	if err != nil {
+		return err
+	}
+
+	batch, err := mi.ds.Batch()
+	if err != nil {
+		return err
+	}
+	for _, m := range msgs {
+		loc, found, err := mi.Get(m.cid)
+		if err != nil {
+			return err
+		}
+		// The message may be located at an earlier inclusion.
+		if !found || !loc.TipSet.Equals(ts.Key()) {
+			continue
+		}
+		if err := batch.Delete(messageIndexKey(m.cid)); err != nil {
+			return err
+		}
+	}
+	return batch.Commit()
+}
+
+type indexedMessage struct {
+	cid          cid.Cid
+	block        cid.Cid
+	receiptIndex uint64
+}
+
+// tipSetMessages returns the de-duplicated messages of a tipset along with
+// the block that first includes them and the index of their receipt.
+// The cid of each message is the cid under which it appears on chain: the
+// signed cid for secp messages and the unsigned cid for BLS messages. Receipts
+// are indexed by the unwrapped message, following the order in which the
+// processor applies them.
+func (mi *MessageIndex) tipSetMessages(ctx context.Context, ts block.TipSet) ([]indexedMessage, error) {
+	var out []indexedMessage
+	seen := make(map[cid.Cid]struct{})
+	receiptIndex := uint64(0)
+	for i := 0; i < ts.Len(); i++ {
+		blk := ts.At(i)
+		secpMsgs, blsMsgs, err := mi.messages.LoadMessages(ctx, blk.Messages.Cid)
+		if err != nil {
+			return nil, errors.Wrapf(err, "failed to load messages of block %s", blk.Cid())
+		}
+
+		onChain := make([]cid.Cid, 0, len(blsMsgs)+len(secpMsgs))
+		unwrapped := make([]*types.UnsignedMessage, 0, len(blsMsgs)+len(secpMsgs))
+		for _, msg := range blsMsgs {
+			c, err := msg.Cid()
+			if err != nil {
+				return nil, err
+			}
+			onChain = append(onChain, c)
+			unwrapped = append(unwrapped, msg)
+		}
+		for _, msg := range secpMsgs {
+			c, err := msg.Cid()
+			if err != nil {
+				return nil, err
+			}
+			onChain = append(onChain, c)
+			unwrapped = append(unwrapped, &msg.Message)
+		}
+
+		for j, msg := range unwrapped {
+			c, err := msg.Cid()
+			if err != nil {
+				return nil, err
+			}
+			if _, dup := seen[c]; dup {
+				continue
+			}
+			seen[c] = struct{}{}
+			out = append(out, indexedMessage{
+				cid:          onChain[j],
+				block:        blk.Cid(),
+				receiptIndex: receiptIndex,
+			})
+			receiptIndex++
+		}
+	}
+	return out, nil
+}
+
+// loadHead reads the head up to which the index was last written. It reports
+// not found if no head was recorded or the recorded tipset is unknown.
+func (mi *MessageIndex) loadHead(tipsets TipSetProvider) (block.TipSet, bool, error) {
+	bb, err := mi.ds.Get(MessageIndexHeadKey)
+	if err == datastore.ErrNotFound {
+		return block.UndefTipSet, false, nil
+	}
+	if err != nil {
+		return block.UndefTipSet, false, errors.Wrap(err, "failed to read message index head")
+	}
+
+	var key block.TipSetKey
+	if err := encoding.Decode(bb, &key); err != nil {
+		return block.UndefTipSet, false, errors.Wrap(err, "failed to decode message index head")
+	}
+	ts, err := tipsets.GetTipSet(key)
+	if err != nil {
+		logStore.Warnf("message index head %s not found in chain", key)
+		return block.UndefTipSet, false, nil
+	}
+	mi.setHead(key)
+	return ts, true, nil
+}
+
+// loadBase reads the height above which the index covers the chain, if the
+// index was seeded.
+func (mi *MessageIndex) loadBase() (abi.ChainEpoch, bool, error) {
+	bb, err := mi.ds.Get(messageIndexBaseKey)
+	if err == datastore.ErrNotFound {
+		return 0, false, nil
+	}
+	if err != nil {
+		return 0, false, errors.Wrap(err, "failed to read message index base")
+	}
+	var base abi.ChainEpoch
+	if err := encoding.Decode(bb, &base); err != nil {
+		return 0, false, errors.Wrap(err, "failed to decode message index base")
+	}
+	return base, true, nil
+}
+
+// covers returns true if tipsets at height h are indexed.
+func (mi *MessageIndex) covers(h abi.ChainEpoch) (bool, error) {
+	base, hasBase, err := mi.loadBase()
+	if err != nil {
+		return false, err
+	}
+	return !hasBase || h > base, nil
+}
+
+func (mi *MessageIndex) writeHead(key block.TipSetKey) error {
+	val, err := encoding.Encode(key)
+	if err != nil {
+		return err
+	}
+	if err := mi.ds.Put(MessageIndexHeadKey, val); err != nil {
+		return err
+	}
+	mi.setHead(key)
+	return nil
+}
+
+func (mi *MessageIndex) setHead(key block.TipSetKey) {
+	mi.headLk.Lock()
+	defer mi.headLk.Unlock()
+	mi.head = key
+}
+
+func messageIndexKey(c cid.Cid) datastore.Key {
+	return datastore.NewKey(messageIndexPrefix + c.String())
+}
@@ -0,0 +1,149 @@
+package chain_test
+
+import (
+	"context"
+	"testing"
+
+	"github.com/filecoin-project/go-address"
+	"github.com/ipfs/go-cid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/filecoin-project/go-filecoin/internal/pkg/block"
+	"github.com/filecoin-project/go-filecoin/internal/pkg/chain"
+	"github.com/filecoin-project/go-filecoin/internal/pkg/repo"
+	tf "github.com/filecoin-project/go-filecoin/internal/pkg/testhelpers/testflags"
+	"github.com/filecoin-project/go-filecoin/internal/pkg/types"
+)
+
+func TestMessageIndex(t *testing.T) {
+	tf.UnitTest(t)
+
+	ctx := context.Background()
+	signer, _ := types.NewMockSignersAndKeyInfo(2)
+	newMsg := types.NewSignedMessageForTestGetter(signer)
+
+	builder := chain.NewBuilder(t, address.Undef)
+	gen := builder.NewGenesis()
+
+	m1, m2, m3 := newMsg(), newMsg(), newMsg()
+	withMsgs := func(msgs ...*types.SignedMessage) func(bb *chain.BlockBuilder) {
+		return func(bb *chain.BlockBuilder) {
+			bb.AddMessages(msgs, []*types.UnsignedMessage{})
+		}
+	}
+	link1 := builder.BuildOneOn(gen, withMsgs(m1))
+	link2 := builder.BuildOneOn(link1, withMsgs(m2, m1))
+	fork := builder.BuildOneOn(link1, withMsgs(m3))
+	forkHead := builder.AppendOn(fork, 1)
+
+	requireCid := func(m *types.SignedMessage) cid.Cid {
+		c, err := m.Cid()
+		require.NoError(t, err)
+		return c
+	}
+	c1, c2, c3 := requireCid(m1), requireCid(m2), requireCid(m3)
+	build := func(idx *chain.MessageIndex, head block.TipSet) {
+		require.NoError(t, idx.Build(ctx, builder, func() (block.TipSet, error) { return head, nil }))
+	}
+
+	t.Run("is not ready until built", func(t *testing.T) {
+		idx := chain.NewMessageIndex(repo.NewInMemoryRepo().ChainDatastore(), builder)
+		assert.Equal(t, chain.ErrMessageIndexNotReady, idx.Update(ctx, builder, link2))
+		assert.False(t, idx.IsCurrent(link2.Key()))
+
+		_, found, err := idx.Get(c1)
+		require.NoError(t, err)
+		assert.False(t, found)
+	})
+
+	t.Run("is current only at the indexed head", func(t *testing.T) {
+		idx := chain.NewMessageIndex(repo.NewInMemoryRepo().ChainDatastore(), builder)
+		build(idx, link1)
+		assert.True(t, idx.IsCurrent(link1.Key()))
+		assert.False(t, idx.IsCurrent(link2.Key()))
+
+		require.NoError(t, idx.Update(ctx, builder, link2))
+		assert.True(t, idx.IsCurrent(link2.Key()))
+	})
+
+	t.Run("rebuild discards the index", func(t *testing.T) {
+		ds := repo.NewInMemoryRepo().ChainDatastore()
+		idx := chain.NewMessageIndex(ds, builder)
+		build(idx, link2)
+
+		require.NoError(t, idx.Rebuild(ctx, builder, func() (block.TipSet, error) { return forkHead, nil }))
+		assert.True(t, idx.IsCurrent(forkHead.Key()))
+		_, found, err := idx.Get(c2)
+		require.NoError(t, err)
+		assert.False(t, found)
+		_, found, err = idx.Get(c3)
+		require.NoError(t, err)
+		assert.True(t, found)
+	})
+
+	t.Run("builds from chain when never written", func(t *testing.T) {
+		idx := chain.NewMessageIndex(repo.NewInMemoryRepo().ChainDatastore(), builder)
+		build(idx, link2)
+
+		loc, found, err := idx.Get(c1)
+		require.NoError(t, err)
+		require.True(t, found)
+		assert.Equal(t, link1.Key(), loc.TipSet)
+		assert.Equal(t, link1.At(0).Cid(), loc.Block.Cid)
+		assert.Equal(t, uint64(0), loc.ReceiptIndex)
+
+		loc, found, err = idx.Get(c2)
+		require.NoError(t, err)
+		require.True(t, found)
+		assert.Equal(t, link2.Key(), loc.TipSet)
+		assert.Equal(t, uint64(0), loc.ReceiptIndex)
+
+		_, found, err = idx.Get(c3)
+		require.NoError(t, err)
+		assert.False(t, found)
+	})
+
+	t.Run("reorg rewinds reverted tipsets", func(t *testing.T) {
+		idx := chain.NewMessageIndex(repo.NewInMemoryRepo().ChainDatastore(), builder)
+		build(idx, link2)
+		require.NoError(t, idx.Update(ctx, builder, forkHead))
+
+		_, found, err := idx.Get(c2)
+		require.NoError(t, err)
+		assert.False(t, found)
+
+		loc, found, err := idx.Get(c3)
+		require.NoError(t, err)
+		require.True(t, found)
+		assert.Equal(t, fork.Key(), loc.TipSet)
+
+		// m1 was included in both link1 and link2, so reverting link2 keeps
+		// the entry for link1.
+		loc, found, err = idx.Get(c1)
+		require.NoError(t, err)
+		require.True(t, found)
+		assert.Equal(t, link1.Key(), loc.TipSet)
+	})
+
+	t.Run("resumes from recorded head", func(t *testing.T) {
+		ds := repo.NewInMemoryRepo().ChainDatastore()
+		build(chain.NewMessageIndex(ds, builder), link1)
+
+		idx := chain.NewMessageIndex(ds, builder)
+		require.NoError(t, idx.Update(ctx, builder, forkHead))
+
+		_, found, err := idx.Get(c3)
+		require.NoError(t, err)
+		assert.True(t, found)
+
+		// Building an index already written only brings it up to date.
+		build(idx, link2)
+		_, found, err = idx.Get(c3)
+		require.NoError(t, err)
+		assert.False(t, found)
+		_, found, err = idx.Get(c2)
+		require.NoError(t, err)
+		assert.True(t, found)
+	})
+}
@@ -0,0 +1,81 @@
+package chain
+
+import (
+	"context"
+
+	"github.com/ipfs/go-cid"
+	"github.com/ipfs/go-datastore"
+	blockstore "github.com/ipfs/go-ipfs-blockstore"
+	"github.com/pkg/errors"
+
+	"github.com/filecoin-project/go-filecoin/internal/pkg/block"
+)
+
+// ReplayResult is the outcome of running the state transition of a tipset
+// again.
+type ReplayResult struct {
+	TipSet block.TipSetKey
+	// StateRoot and ReceiptsRoot are the recomputed state and receipts.
+	StateRoot    cid.Cid
+	ReceiptsRoot cid.Cid
+	// StoredStateRoot and StoredReceiptsRoot are the state and receipts
+	// recorded for the tipset in the chain store.
+	StoredStateRoot    cid.Cid
+	StoredReceiptsRoot cid.Cid
+}
+
+// Matches returns true if the recomputed state and receipts are the stored ones.
+func (r *ReplayResult) Matches() bool {
+	return r.StateRoot.Equals(r.StoredStateRoot) && r.ReceiptsRoot.Equals(r.StoredReceiptsRoot)
+}
+
+// replayStore provides the recorded results of state transitions.
+type replayStore interface {
+	GetTipSetStateRoot(block.TipSetKey) (cid.Cid, error)
+	GetTipSetReceiptsRoot(block.TipSetKey) (cid.Cid, error)
+}
+
+// ReplayTipSet runs the state transition of `ts` again with `transitioner`,
+// from the parent state its blocks were mined on, and returns the result
+// along with the state and receipts `store` recorded for `ts`. The receipts
+// root is computed in memory: the replay only writes what `transitioner`
+// persists itself.
+func ReplayTipSet(ctx context.Context, ts block.TipSet, store replayStore, messages *MessageStore, transitioner StateTransitioner) (*ReplayResult, error) {
+	if ts.At(0).Height == 0 {
+		return nil, errors.New("the genesis tipset has no state transition to replay")
+	}
+
+	storedRoot, err := store.GetTipSetStateRoot(ts.Key())
+	if err != nil {
+		return nil, errors.Wrapf(err, "no state recorded for tipset %s", ts.Key())
+	}
+	storedReceipts, err := store.GetTipSetReceiptsRoot(ts.Key())
+	if err != nil {
+		return nil, errors.Wrapf(err, "no receipts recorded for tipset %s", ts.Key())
+	}
+
+	blsMessages, secpMessages, err := loadTipSetMessages(ctx, messages, ts)
+	if err != nil {
+		return nil, errors.Wrapf(err, "failed to load messages of tipset %s", ts.Key())
+	}
+
+	first := ts.At(0)
+	root, receipts, err := transitioner.RunStateTransition(ctx, ts, blsMessages, secpMessages,
+		first.ParentWeight, first.StateRoot.Cid, first.MessageReceipts.Cid)
+	if err != nil {
+		return nil, errors.Wrapf(err, "failed to replay tipset %s", ts.Key())
+	}
+	scratch := NewMessageStore(blockstore.NewBlockstore(datastore.NewMapDatastore()))
+	receiptsRoot, err := scratch.StoreReceipts(ctx, receipts)
+	if err != nil {
+		return nil, err
+	}
+
+	return &ReplayResult{
+		TipSet:             ts.Key(),
+		StateRoot:          root,
+		ReceiptsRoot:       receiptsRoot,
+		StoredStateRoot:    storedRoot,
+		StoredReceiptsRoot: storedReceipts,
+	}, nil
+}
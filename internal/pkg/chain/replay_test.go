@@ -0,0 +1,93 @@
+package chain_test
+
+import (
+	"context"
+	"testing"
+
+	fbig "github.com/filecoin-project/specs-actors/actors/abi/big"
+	"github.com/ipfs/go-cid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/filecoin-project/go-filecoin/internal/pkg/block"
+	"github.com/filecoin-project/go-filecoin/internal/pkg/chain"
+	tf "github.com/filecoin-project/go-filecoin/internal/pkg/testhelpers/testflags"
+	"github.com/filecoin-project/go-filecoin/internal/pkg/types"
+	"github.com/filecoin-project/go-filecoin/internal/pkg/vm"
+	"github.com/filecoin-project/go-filecoin/internal/pkg/vm/gas"
+)
+
+func TestReplayTipSet(t *testing.T) {
+	tf.UnitTest(t)
+
+	ctx, gene, cb, carW, carR, bstore := setupDeps(t)
+	keys := types.MustGenerateKeyInfo(1, 42)
+	mm := vm.NewMessageMaker(t, keys)
+	alice := mm.Addresses()[0]
+
+	parent := cb.AppendOn(gene, 1)
+	ts := cb.BuildOneOn(parent, func(b *chain.BlockBuilder) {
+		b.AddMessages([]*types.SignedMessage{mm.NewSignedMessage(alice, 1)}, []*types.UnsignedMessage{})
+	})
+	mustExportToBuffer(ctx, t, ts, cb, &mockStateReader{}, carW)
+	mustImportFromBuffer(ctx, t, bstore, carR)
+	messages := chain.NewMessageStore(bstore)
+
+	t.Run("matches the recorded state", func(t *testing.T) {
+		store := &fakeReplayStore{root: cb.StateForKey(ts.Key()), receipts: types.EmptyReceiptsCID}
+		result, err := chain.ReplayTipSet(ctx, ts, store, messages, &chain.FakeStateEvaluator{})
+		require.NoError(t, err)
+		assert.Equal(t, ts.Key(), result.TipSet)
+		assert.Equal(t, store.root, result.StateRoot)
+		assert.True(t, result.Matches())
+	})
+
+	t.Run("reports a mismatched state", func(t *testing.T) {
+		store := &fakeReplayStore{root: types.CidFromString(t, "recorded state"), receipts: types.EmptyReceiptsCID}
+		result, err := chain.ReplayTipSet(ctx, ts, store, messages, &chain.FakeStateEvaluator{})
+		require.NoError(t, err)
+		assert.False(t, result.Matches())
+		assert.Equal(t, cb.StateForKey(ts.Key()), result.StateRoot)
+	})
+
+	t.Run("stores no receipts", func(t *testing.T) {
+		store := &fakeReplayStore{root: cb.StateForKey(ts.Key()), receipts: types.EmptyReceiptsCID}
+		result, err := chain.ReplayTipSet(ctx, ts, store, messages, &receiptTransitioner{})
+		require.NoError(t, err)
+		assert.False(t, result.Matches())
+		has, err := bstore.Has(result.ReceiptsRoot)
+		require.NoError(t, err)
+		assert.False(t, has)
+	})
+
+	t.Run("rejects the genesis tipset", func(t *testing.T) {
+		store := &fakeReplayStore{root: cb.StateForKey(gene.Key()), receipts: types.EmptyReceiptsCID}
+		_, err := chain.ReplayTipSet(ctx, gene, store, messages, &chain.FakeStateEvaluator{})
+		assert.Error(t, err)
+	})
+}
+
+type fakeReplayStore struct {
+	root     cid.Cid
+	receipts cid.Cid
+}
+
+func (s *fakeReplayStore) GetTipSetStateRoot(block.TipSetKey) (cid.Cid, error) {
+	return s.root, nil
+}
+
+func (s *fakeReplayStore) GetTipSetReceiptsRoot(block.TipSetKey) (cid.Cid, error) {
+	return s.receipts, nil
+}
+
+// receiptTransitioner is a FakeStateEvaluator whose transitions produce a
+// receipt.
+type receiptTransitioner struct {
+	chain.FakeStateEvaluator
+}
+
+func (e *receiptTransitioner) RunStateTransition(ctx context.Context, ts block.TipSet, blsMessages [][]*types.UnsignedMessage, secpMessages [][]*types.SignedMessage,
+	parentWeight fbig.Int, parentStateRoot cid.Cid, parentReceiptRoot cid.Cid) (cid.Cid, []vm.MessageReceipt, error) {
+	root, receipts, err := e.FakeStateEvaluator.RunStateTransition(ctx, ts, blsMessages, secpMessages, parentWeight, parentStateRoot, parentReceiptRoot)
+	return root, append(receipts, vm.MessageReceipt{GasUsed: gas.NewGas(7)}), err
+}
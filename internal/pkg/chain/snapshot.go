@@ -0,0 +1,330 @@
+package chain
+
+import (
+	"context"
+	"io"
+	"sync"
+
+	"github.com/filecoin-project/specs-actors/actors/abi"
+	fbig "github.com/filecoin-project/specs-actors/actors/abi/big"
+	blocks "github.com/ipfs/go-block-format"
+	"github.com/ipfs/go-cid"
+	blockstore "github.com/ipfs/go-ipfs-blockstore"
+	"github.com/pkg/errors"
+
+	"github.com/filecoin-project/go-filecoin/internal/pkg/block"
+	"github.com/filecoin-project/go-filecoin/internal/pkg/types"
+	"github.com/filecoin-project/go-filecoin/internal/pkg/vm"
+)
+
+var (
+	// ErrSnapshotGenesisMismatch is returned when a snapshot does not descend
+	// from the node's genesis block.
+	ErrSnapshotGenesisMismatch = errors.New("snapshot genesis does not match the node's genesis")
+	// ErrSnapshotHeadState is returned when a snapshot would be imported
+	// without recomputing the state of its head, which the node needs to sync
+	// on top of it.
+	ErrSnapshotHeadState = errors.New("the state of at least the head tipset must be recomputed")
+)
+
+// StateTransitioner runs the state transitions recomputed when verifying a
+// snapshot or replaying a tipset.
+type StateTransitioner interface {
+	RunStateTransition(ctx context.Context, ts block.TipSet, blsMessages [][]*types.UnsignedMessage, secpMessages [][]*types.SignedMessage,
+		parentWeight fbig.Int, parentStateRoot cid.Cid, parentReceiptRoot cid.Cid) (cid.Cid, []vm.MessageReceipt, error)
+}
+
+// snapshotStore records the state of verified snapshot tipsets and indexes
+// their messages.
+type snapshotStore interface {
+	GenesisCid() cid.Cid
+	PutTipSetMetadata(ctx context.Context, tsm *TipSetMetadata) error
+	SeedMessageIndex(ctx context.Context, base abi.ChainEpoch, tipsets []block.TipSet) error
+}
+
+// ImportSnapshot imports a chain from `in` into `bs` and verifies it with
+// VerifySnapshot. The import is staged: if it fails, the blocks it added to
+// `bs` are deleted again and no tipset state is recorded. State written by
+// `transitioner` while recomputing is left to garbage collection.
+func ImportSnapshot(ctx context.Context, bs blockstore.Blockstore, in io.Reader, store snapshotStore,
+	transitioner StateTransitioner, recompute int) (block.TipSetKey, error) {
+	staging := newStagingBlockstore(bs)
+	headKey, err := Import(ctx, staging, in)
+	if err == nil {
+		err = VerifySnapshot(ctx, staging, headKey, store, transitioner, recompute)
+	}
+	if err != nil {
+		if derr := staging.discard(); derr != nil {
+			logCar.Errorf("failed to discard rejected chain import: %s", derr)
+		}
+		return block.UndefTipSet.Key(), err
+	}
+	return headKey, nil
+}
+
+// VerifySnapshot checks a chain imported into `bs` with head `head`: every
+// tipset must be well formed and link to its parents, back to the genesis
+// block of `store`. The headers are checked back to genesis before any state
+// transition is run. The state transitions of the `recompute` most recent
+// tipsets, at least the head, are then run again with `transitioner` and must
+// produce the state and receipts their children commit to. Once the whole
+// chain is verified the state of each tipset that was recomputed, or whose
+// resulting state tree was imported, is recorded in `store` so the node can
+// sync on top of the snapshot. The message index of `store` is seeded with
+// the most recent tipsets whose messages the snapshot holds.
+func VerifySnapshot(ctx context.Context, bs blockstore.Blockstore, head block.TipSetKey, store snapshotStore,
+	transitioner StateTransitioner, recompute int) error {
+	if recompute < 1 {
+		return ErrSnapshotHeadState
+	}
+
+	tipsets, err := loadSnapshotChain(ctx, bs, head, store.GenesisCid())
+	if err != nil {
+		return err
+	}
+
+	messages := NewMessageStore(bs)
+	var child block.TipSet
+	var verified []*TipSetMetadata
+	for depth, ts := range tipsets {
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+
+		var tsm *TipSetMetadata
+		if depth < recompute {
+			tsm, err = recomputeSnapshotTipSet(ctx, messages, transitioner, ts, child)
+			if err != nil {
+				return err
+			}
+		} else {
+			// The child commits to the state of this tipset; record it if the
+			// snapshot included it.
+			has, err := bs.Has(child.At(0).StateRoot.Cid)
+			if err != nil {
+				return err
+			}
+			if has {
+				tsm = &TipSetMetadata{
+					TipSet:          ts,
+					TipSetStateRoot: child.At(0).StateRoot.Cid,
+					TipSetReceipts:  child.At(0).MessageReceipts.Cid,
+				}
+			}
+		}
+		if tsm != nil {
+			verified = append(verified, tsm)
+		}
+		child = ts
+	}
+
+	base, withMessages, err := snapshotMessageBase(bs, tipsets)
+	if err != nil {
+		return err
+	}
+	if len(withMessages) > 0 {
+		if err := store.SeedMessageIndex(ctx, base, withMessages); err != nil {
+			return errors.Wrap(err, "failed to index snapshot messages")
+		}
+	}
+
+	for _, tsm := range verified {
+		if err := store.PutTipSetMetadata(ctx, tsm); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// snapshotMessageBase returns the most recent tipsets, in descending height
+// order, whose messages are all in `bs`, and the height below them. Snapshots
+// of recent state roots hold the messages of only the most recent tipsets.
+func snapshotMessageBase(bs blockstore.Blockstore, tipsets []block.TipSet) (abi.ChainEpoch, []block.TipSet, error) {
+	for i, ts := range tipsets {
+		for j := 0; j < ts.Len(); j++ {
+			has, err := bs.Has(ts.At(j).Messages.Cid)
+			if err != nil {
+				return 0, nil, err
+			}
+			if !has {
+				return ts.At(0).Height, tipsets[:i], nil
+			}
+		}
+	}
+	// Every tipset but genesis, which has no messages, was imported.
+	return 0, tipsets, nil
+}
+
+// loadSnapshotChain loads the tipsets of a snapshot from `head` back to, but
+// excluding, the genesis block, which must be `genesis`. Tipsets are returned
+// in descending height order.
+func loadSnapshotChain(ctx context.Context, bs blockstore.Blockstore, head block.TipSetKey, genesis cid.Cid) ([]block.TipSet, error) {
+	var tipsets []block.TipSet
+	var child block.TipSet
+	key := head
+	for {
+		if err := ctx.Err(); err != nil {
+			return nil, err
+		}
+
+		ts, err := loadSnapshotTipSet(bs, key)
+		if err != nil {
+			return nil, err
+		}
+		if child.Defined() && ts.At(0).Height >= child.At(0).Height {
+			return nil, errors.Errorf("tipset %s is not lower than its child %s", ts.Key(), child.Key())
+		}
+
+		if ts.At(0).Height == 0 {
+			if ts.Len() != 1 || !ts.At(0).Cid().Equals(genesis) {
+				return nil, ErrSnapshotGenesisMismatch
+			}
+			return tipsets, nil
+		}
+		tipsets = append(tipsets, ts)
+
+		key, err = ts.Parents()
+		if err != nil {
+			return nil, err
+		}
+		if key.Len() == 0 {
+			return nil, errors.Errorf("tipset %s at height %d has no parents", ts.Key(), ts.At(0).Height)
+		}
+		child = ts
+	}
+}
+
+// stagingBlockstore records the blocks put into the wrapped blockstore that it
+// did not hold before, so they can be deleted if an import is rejected.
+type stagingBlockstore struct {
+	blockstore.Blockstore
+
+	lk    sync.Mutex
+	added []cid.Cid
+}
+
+func newStagingBlockstore(bs blockstore.Blockstore) *stagingBlockstore {
+	return &stagingBlockstore{Blockstore: bs}
+}
+
+func (bs *stagingBlockstore) Put(b blocks.Block) error {
+	return bs.PutMany([]blocks.Block{b})
+}
+
+func (bs *stagingBlockstore) PutMany(blks []blocks.Block) error {
+	var added []cid.Cid
+	for _, b := range blks {
+		has, err := bs.Blockstore.Has(b.Cid())
+		if err != nil {
+			return err
+		}
+		if !has {
+			added = append(added, b.Cid())
+		}
+	}
+	if err := bs.Blockstore.PutMany(blks); err != nil {
+		return err
+	}
+
+	bs.lk.Lock()
+	defer bs.lk.Unlock()
+	bs.added = append(bs.added, added...)
+	return nil
+}
+
+// discard deletes the blocks added through the staging blockstore.
+func (bs *stagingBlockstore) discard() error {
+	bs.lk.Lock()
+	defer bs.lk.Unlock()
+
+	for _, c := range bs.added {
+		if err := bs.Blockstore.DeleteBlock(c); err != nil {
+			return err
+		}
+	}
+	bs.added = nil
+	return nil
+}
+
+// loadSnapshotTipSet loads the blocks of `key` from the blockstore and checks
+// that they form a tipset with a single parent state.
+func loadSnapshotTipSet(bs blockstore.Blockstore, key block.TipSetKey) (block.TipSet, error) {
+	var blks []*block.Block
+	for _, c := range key.ToSlice() {
+		raw, err := bs.Get(c)
+		if err != nil {
+			return block.UndefTipSet, errors.Wrapf(err, "snapshot is missing block %s", c)
+		}
+		blk, err := block.DecodeBlock(raw.RawData())
+		if err != nil {
+			return block.UndefTipSet, errors.Wrapf(err, "snapshot has invalid block %s", c)
+		}
+		if !blk.Cid().Equals(c) {
+			return block.UndefTipSet, errors.Errorf("snapshot block %s has mismatched cid %s", c, blk.Cid())
+		}
+		if len(blks) > 0 {
+			if !blk.StateRoot.Cid.Equals(blks[0].StateRoot.Cid) || !blk.MessageReceipts.Cid.Equals(blks[0].MessageReceipts.Cid) {
+				return block.UndefTipSet, errors.Errorf("blocks of tipset %s have different parent states", key)
+			}
+		}
+		blks = append(blks, blk)
+	}
+
+	ts, err := block.NewTipSet(blks...)
+	if err != nil {
+		return block.UndefTipSet, errors.Wrapf(err, "snapshot has invalid tipset %s", key)
+	}
+	return ts, nil
+}
+
+// recomputeSnapshotTipSet runs the state transition of `ts` and checks the
+// result against the state and receipts `child` was mined on, if any.
+func recomputeSnapshotTipSet(ctx context.Context, messages *MessageStore, transitioner StateTransitioner,
+	ts, child block.TipSet) (*TipSetMetadata, error) {
+	blsMessages, secpMessages, err := loadTipSetMessages(ctx, messages, ts)
+	if err != nil {
+		return nil, errors.Wrapf(err, "snapshot is missing messages of tipset %s; export more recent state roots", ts.Key())
+	}
+
+	first := ts.At(0)
+	root, receipts, err := transitioner.RunStateTransition(ctx, ts, blsMessages, secpMessages,
+		first.ParentWeight, first.StateRoot.Cid, first.MessageReceipts.Cid)
+	if err != nil {
+		return nil, errors.Wrapf(err, "failed to recompute state of tipset %s", ts.Key())
+	}
+	receiptsRoot, err := messages.StoreReceipts(ctx, receipts)
+	if err != nil {
+		return nil, err
+	}
+
+	if child.Defined() {
+		if !root.Equals(child.At(0).StateRoot.Cid) {
+			return nil, errors.Errorf("recomputed state %s of tipset %s does not match state %s of its child",
+				root, ts.Key(), child.At(0).StateRoot)
+		}
+		if !receiptsRoot.Equals(child.At(0).MessageReceipts.Cid) {
+			return nil, errors.Errorf("recomputed receipts %s of tipset %s do not match receipts %s of its child",
+				receiptsRoot, ts.Key(), child.At(0).MessageReceipts)
+		}
+	}
+	return &TipSetMetadata{
+		TipSet:          ts,
+		TipSetStateRoot: root,
+		TipSetReceipts:  receiptsRoot,
+	}, nil
+}
+
+// loadTipSetMessages loads the messages of each block of `ts`, in block order.
+func loadTipSetMessages(ctx context.Context, messages MessageProvider, ts block.TipSet) ([][]*types.UnsignedMessage, [][]*types.SignedMessage, error) {
+	var blsMessages [][]*types.UnsignedMessage
+	var secpMessages [][]*types.SignedMessage
+	for i := 0; i < ts.Len(); i++ {
+		secpMsgs, blsMsgs, err := messages.LoadMessages(ctx, ts.At(i).Messages.Cid)
+		if err != nil {
+			return nil, nil, err
+		}
+		blsMessages = append(blsMessages, blsMsgs)
+		secpMessages = append(secpMessages, secpMsgs)
+	}
+	return blsMessages, secpMessages, nil
+}
@@ -0,0 +1,180 @@
+package chain_test
+
+import (
+	"context"
+	"testing"
+
+	"github.com/filecoin-project/specs-actors/actors/abi"
+	fbig "github.com/filecoin-project/specs-actors/actors/abi/big"
+	"github.com/ipfs/go-cid"
+	blockstore "github.com/ipfs/go-ipfs-blockstore"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/filecoin-project/go-filecoin/internal/pkg/block"
+	"github.com/filecoin-project/go-filecoin/internal/pkg/chain"
+	tf "github.com/filecoin-project/go-filecoin/internal/pkg/testhelpers/testflags"
+	"github.com/filecoin-project/go-filecoin/internal/pkg/types"
+	"github.com/filecoin-project/go-filecoin/internal/pkg/vm"
+)
+
+func TestExportRecentSkipsOldMessages(t *testing.T) {
+	tf.UnitTest(t)
+
+	ctx, gene, cb, carW, carR, bstore := setupDeps(t)
+
+	keys := types.MustGenerateKeyInfo(1, 42)
+	mm := vm.NewMessageMaker(t, keys)
+	alice := mm.Addresses()[0]
+
+	old := cb.BuildOneOn(gene, func(b *chain.BlockBuilder) {
+		b.AddMessages([]*types.SignedMessage{mm.NewSignedMessage(alice, 1)}, []*types.UnsignedMessage{})
+	})
+	head := cb.AppendManyOn(3, old)
+
+	require.NoError(t, chain.ExportRecent(ctx, head, 1, cb, cb, &mockStateReader{}, carW))
+	require.NoError(t, carW.Flush())
+	importedKey := mustImportFromBuffer(ctx, t, bstore, carR)
+	assert.Equal(t, head.Key(), importedKey)
+
+	// Every header is present, but the messages of the old tipset are not.
+	has, err := bstore.Has(old.At(0).Cid())
+	require.NoError(t, err)
+	assert.True(t, has)
+	has, err = bstore.Has(old.At(0).Messages.Cid)
+	require.NoError(t, err)
+	assert.False(t, has)
+	has, err = bstore.Has(head.At(0).Messages.Cid)
+	require.NoError(t, err)
+	assert.True(t, has)
+
+	assert.Error(t, chain.ExportRecent(ctx, head, 0, cb, cb, &mockStateReader{}, carW))
+}
+
+func TestVerifySnapshotSeedsMessageIndex(t *testing.T) {
+	tf.UnitTest(t)
+
+	ctx, gene, cb, carW, carR, bstore := setupDeps(t)
+
+	keys := types.MustGenerateKeyInfo(1, 42)
+	mm := vm.NewMessageMaker(t, keys)
+	alice := mm.Addresses()[0]
+	withMessage := func(nonce uint64) func(b *chain.BlockBuilder) {
+		return func(b *chain.BlockBuilder) {
+			b.AddMessages([]*types.SignedMessage{mm.NewSignedMessage(alice, nonce)}, []*types.UnsignedMessage{})
+		}
+	}
+	old := cb.BuildOneOn(gene, withMessage(1))
+	mid := cb.BuildOneOn(old, withMessage(2))
+	head := cb.BuildOneOn(mid, withMessage(3))
+	require.NoError(t, chain.ExportRecent(ctx, head, 2, cb, cb, &mockStateReader{}, carW))
+	require.NoError(t, carW.Flush())
+	key := mustImportFromBuffer(ctx, t, bstore, carR)
+
+	store := &fakeSnapshotStore{genesis: gene.At(0).Cid(), bs: bstore}
+	require.NoError(t, chain.VerifySnapshot(ctx, bstore, key, store, &chain.FakeStateEvaluator{}, 1))
+
+	// Only the two most recent tipsets have their messages imported.
+	require.Len(t, store.indexTipSets, 2)
+	assert.Equal(t, head.Key(), store.indexTipSets[0].Key())
+	assert.Equal(t, mid.Key(), store.indexTipSets[1].Key())
+	assert.Equal(t, old.At(0).Height, store.indexBase)
+}
+
+func TestVerifySnapshot(t *testing.T) {
+	tf.UnitTest(t)
+
+	setup := func(t *testing.T) (context.Context, block.TipSet, *fakeSnapshotStore, block.TipSetKey, *chain.FakeStateEvaluator) {
+		ctx, gene, cb, carW, carR, bstore := setupDeps(t)
+		head := cb.AppendManyOn(4, gene)
+		mustExportToBuffer(ctx, t, head, cb, &mockStateReader{}, carW)
+		importedKey := mustImportFromBuffer(ctx, t, bstore, carR)
+		store := &fakeSnapshotStore{genesis: gene.At(0).Cid(), bs: bstore}
+		return ctx, head, store, importedKey, &chain.FakeStateEvaluator{}
+	}
+
+	t.Run("accepts a chain and records recomputed state", func(t *testing.T) {
+		ctx, head, store, key, evaluator := setup(t)
+		require.NoError(t, chain.VerifySnapshot(ctx, store.bs, key, store, evaluator, 2))
+
+		require.Len(t, store.recorded, 2)
+		assert.Equal(t, head.Key(), store.recorded[0].TipSet.Key())
+		assert.Equal(t, types.EmptyReceiptsCID, store.recorded[0].TipSetReceipts)
+	})
+
+	t.Run("rejects a different genesis before recomputing state", func(t *testing.T) {
+		ctx, _, store, key, _ := setup(t)
+		store.genesis = types.CidFromString(t, "other genesis")
+		bad := &badTransitioner{root: types.CidFromString(t, "bad state")}
+		assert.Equal(t, chain.ErrSnapshotGenesisMismatch, chain.VerifySnapshot(ctx, store.bs, key, store, bad, 2))
+		assert.Equal(t, 0, bad.calls)
+		assert.Empty(t, store.recorded)
+	})
+
+	t.Run("requires the head state to be recomputed", func(t *testing.T) {
+		ctx, _, store, key, evaluator := setup(t)
+		assert.Equal(t, chain.ErrSnapshotHeadState, chain.VerifySnapshot(ctx, store.bs, key, store, evaluator, 0))
+		assert.Empty(t, store.recorded)
+	})
+
+	t.Run("rejects a mismatched state transition", func(t *testing.T) {
+		ctx, _, store, key, _ := setup(t)
+		bad := &badTransitioner{root: types.CidFromString(t, "bad state")}
+		err := chain.VerifySnapshot(ctx, store.bs, key, store, bad, 2)
+		assert.Error(t, err)
+		assert.Contains(t, err.Error(), "does not match state")
+		assert.Empty(t, store.recorded)
+	})
+}
+
+func TestImportSnapshotDiscardsRejectedChain(t *testing.T) {
+	tf.UnitTest(t)
+
+	ctx, gene, cb, carW, carR, bstore := setupDeps(t)
+	head := cb.AppendManyOn(4, gene)
+	mustExportToBuffer(ctx, t, head, cb, &mockStateReader{}, carW)
+
+	store := &fakeSnapshotStore{genesis: types.CidFromString(t, "other genesis"), bs: bstore}
+	_, err := chain.ImportSnapshot(ctx, bstore, carR, store, &chain.FakeStateEvaluator{}, 1)
+	assert.Equal(t, chain.ErrSnapshotGenesisMismatch, err)
+
+	has, err := bstore.Has(head.At(0).Cid())
+	require.NoError(t, err)
+	assert.False(t, has)
+	assert.Empty(t, store.recorded)
+}
+
+type fakeSnapshotStore struct {
+	genesis  cid.Cid
+	bs       blockstore.Blockstore
+	recorded []*chain.TipSetMetadata
+
+	indexBase    abi.ChainEpoch
+	indexTipSets []block.TipSet
+}
+
+func (s *fakeSnapshotStore) GenesisCid() cid.Cid {
+	return s.genesis
+}
+
+func (s *fakeSnapshotStore) PutTipSetMetadata(_ context.Context, tsm *chain.TipSetMetadata) error {
+	s.recorded = append(s.recorded, tsm)
+	return nil
+}
+
+func (s *fakeSnapshotStore) SeedMessageIndex(_ context.Context, base abi.ChainEpoch, tipsets []block.TipSet) error {
+	s.indexBase = base
+	s.indexTipSets = tipsets
+	return nil
+}
+
+type badTransitioner struct {
+	root  cid.Cid
+	calls int
+}
+
+func (b *badTransitioner) RunStateTransition(_ context.Context, _ block.TipSet, _ [][]*types.UnsignedMessage, _ [][]*types.SignedMessage,
+	_ fbig.Int, _ cid.Cid, _ cid.Cid) (cid.Cid, []vm.MessageReceipt, error) {
+	b.calls++
+	return b.root, []vm.MessageReceipt{}, nil
+}
@@ -34,6 +34,10 @@ var logStore = logging.Logger("chain.store")
 // HeadKey is the key at which the head tipset cid's are written in the datastore.
 var HeadKey = datastore.NewKey("/chain/heaviestTipSet")
 
+// ErrReorgPastCheckpoint is returned when setting a head that does not
+// include the store's checkpoint tipset.
+var ErrReorgPastCheckpoint = errors.New("new head does not include the checkpoint tipset")
+
 type ipldSource struct {
 	// cst is a store allowing access
 	// (un)marshalling and interop with go-ipld-hamt.
@@ -78,7 +82,10 @@ type Store struct {
 	genesis cid.Cid
 	// head is the tipset at the head of the best known chain.
 	head block.TipSet
-	// Protects head and genesisCid.
+	// checkpoint is the key of a trusted tipset that the head must include
+	// once it is in the store.
+	checkpoint block.TipSetKey
+	// Protects head, checkpoint and genesisCid.
 	mu sync.RWMutex
 
 	// headEvents is a pubsub channel that publishes an event every time the head changes.
@@ -94,6 +101,16 @@ type Store struct {
 
 	// Reporter is used by the store to update the current status of the chain.
 	reporter Reporter
+
+	// msgIndex maps message cids to their location on the head chain. It is
+	// optional and updated on every head change when set.
+	msgIndex *MessageIndex
+
+	// gcLock is held for reading while tipsets are added to the store and for
+	// writing while garbage collection snapshots the store and sweeps the
+	// blockstore, so that collection never sweeps the blocks of a tipset
+	// being added.
+	gcLock sync.RWMutex
 }
 
 // NewStore constructs a new default store.
@@ -273,6 +290,13 @@ func (store *Store) GetGenesisBlock(ctx context.Context) (*block.Block, error) {
 	return store.stateAndBlockSource.GetBlock(ctx, store.GenesisCid())
 }
 
+// HasState returns true if the root of the state tree `root` is in the
+// store, as it is once computed or imported.
+func (store *Store) HasState(ctx context.Context, root cid.Cid) bool {
+	_, err := state.LoadState(ctx, store.stateAndBlockSource.cborStore, root)
+	return err == nil
+}
+
 // GetTipSetStateRoot returns the aggregate state root CID of the tipset identified by `key`.
 func (store *Store) GetTipSetStateRoot(key block.TipSetKey) (cid.Cid, error) {
 	return store.tipIndex.GetTipSetStateRoot(key)
@@ -301,6 +325,27 @@ func (store *Store) HasTipSetAndStatesWithParentsAndHeight(parentKey block.TipSe
 	return store.tipIndex.HasByParentsAndHeight(parentKey, h)
 }
 
+// GetTipSetAndStatesSince returns the tipsets and states of height at least
+// h tracked by the store, including those not on the head chain.
+func (store *Store) GetTipSetAndStatesSince(h abi.ChainEpoch) []*TipSetMetadata {
+	return store.tipIndex.GetSince(h)
+}
+
+// SyncLock excludes garbage collection of the blockstore while new tipsets
+// are fetched and added to the store. The returned function releases it.
+func (store *Store) SyncLock() func() {
+	store.gcLock.RLock()
+	return store.gcLock.RUnlock
+}
+
+// GCLock waits for tipsets being added to the store and excludes new ones
+// until the returned function is called. Garbage collection holds it while
+// it snapshots the store and while it sweeps.
+func (store *Store) GCLock() func() {
+	store.gcLock.Lock()
+	return store.gcLock.Unlock
+}
+
 // HeadEvents returns a pubsub interface the pushes events each time the
 // default store's head is reset.
 func (store *Store) HeadEvents() *pubsub.PubSub {
@@ -317,7 +362,11 @@ func (store *Store) SetHead(ctx context.Context, ts block.TipSet) error {
 		logStore.Error(debug.Stack())
 	}
 
-	noop, err := store.setHeadPersistent(ctx, ts)
+	if err := store.checkCheckpoint(ctx, ts); err != nil {
+		return err
+	}
+
+	_, noop, err := store.setHeadPersistent(ctx, ts)
 	if err != nil {
 		return err
 	}
@@ -326,6 +375,20 @@ func (store *Store) SetHead(ctx context.Context, ts block.TipSet) error {
 		return nil
 	}
 
+	// Index the new head's messages before announcing it so that anyone
+	// reacting to the event can find them. An index that is not built or
+	// fails to update is built in the background, and lookups search the
+	// chain meanwhile.
+	if store.msgIndex != nil {
+		err := store.msgIndex.Update(ctx, store, ts)
+		if err == ErrMessageIndexNotReady {
+			go store.buildMessageIndex(false)
+		} else if err != nil {
+			logStore.Errorf("failed to update message index to %s, rebuilding: %s", ts.String(), err)
+			go store.buildMessageIndex(true)
+		}
+	}
+
 	h, err := ts.Height()
 	if err != nil {
 		return err
@@ -337,28 +400,187 @@ func (store *Store) SetHead(ctx context.Context, ts block.TipSet) error {
 	return nil
 }
 
+// checkCheckpoint errors if setting `ts` as the head would reorg past the
+// checkpoint. Heads are not checked until the checkpoint is in the store.
+func (store *Store) checkCheckpoint(ctx context.Context, ts block.TipSet) error {
+	key := store.GetCheckpoint()
+	if key.Empty() {
+		return nil
+	}
+	cp, err := store.GetTipSet(key)
+	if err != nil {
+		// Not synced yet, the syncer refuses chains that do not include it.
+		return nil
+	}
+	cpHeight, err := cp.Height()
+	if err != nil {
+		return err
+	}
+	height, err := ts.Height()
+	if err != nil {
+		return err
+	}
+
+	store.mu.RLock()
+	head := store.head
+	store.mu.RUnlock()
+	pastCheckpoint := false
+	if head.Defined() {
+		headHeight, err := head.Height()
+		if err != nil {
+			return err
+		}
+		pastCheckpoint = headHeight >= cpHeight
+	}
+	if height < cpHeight {
+		// The head may only be below the checkpoint until it passes it.
+		if pastCheckpoint {
+			return ErrReorgPastCheckpoint
+		}
+		return nil
+	}
+
+	if pastCheckpoint {
+		return store.checkReorg(ctx, head, ts, cpHeight)
+	}
+	included, err := IncludesTipSet(ctx, store, ts, cp)
+	if err != nil {
+		return err
+	}
+	if !included {
+		return ErrReorgPastCheckpoint
+	}
+	return nil
+}
+
+// checkReorg errors if `ts` forks from the chain of `head`, which includes the
+// checkpoint, below the checkpoint's height. Both chains are walked down to
+// their common ancestor, which for most new heads is the current head itself,
+// and never below the checkpoint.
+func (store *Store) checkReorg(ctx context.Context, head, ts block.TipSet, cpHeight abi.ChainEpoch) error {
+	headIter := IterAncestors(ctx, store, head)
+	tsIter := IterAncestors(ctx, store, ts)
+	for !headIter.Complete() && !tsIter.Complete() {
+		headHeight, err := headIter.Value().Height()
+		if err != nil {
+			return err
+		}
+		tsHeight, err := tsIter.Value().Height()
+		if err != nil {
+			return err
+		}
+		if headHeight < cpHeight || tsHeight < cpHeight {
+			return ErrReorgPastCheckpoint
+		}
+		if headIter.Value().Equals(tsIter.Value()) {
+			return nil
+		}
+		if headHeight >= tsHeight {
+			err = headIter.Next()
+		} else {
+			err = tsIter.Next()
+		}
+		if err != nil {
+			return err
+		}
+	}
+	return ErrReorgPastCheckpoint
+}
+
+// SetCheckpoint sets the key of a trusted tipset that the head must include.
+// An empty key removes the checkpoint.
+func (store *Store) SetCheckpoint(key block.TipSetKey) {
+	store.mu.Lock()
+	defer store.mu.Unlock()
+	store.checkpoint = key
+}
+
+// GetCheckpoint returns the key of the store's checkpoint tipset, which is
+// empty if there is none.
+func (store *Store) GetCheckpoint() block.TipSetKey {
+	store.mu.RLock()
+	defer store.mu.RUnlock()
+	return store.checkpoint
+}
+
 // ReadOnlyStateStore provides a read-only IPLD store for access to chain state.
 func (store *Store) ReadOnlyStateStore() cborutil.ReadOnlyIpldStore {
 	return cborutil.ReadOnlyIpldStore{IpldStore: store.stateAndBlockSource.cborStore}
 }
 
-func (store *Store) setHeadPersistent(ctx context.Context, ts block.TipSet) (bool, error) {
+func (store *Store) setHeadPersistent(ctx context.Context, ts block.TipSet) (block.TipSet, bool, error) {
 	// setHeaadPersistent sets the head in memory and on disk if the head is not
-	// already set to ts.  If it is already set to ts it skips this and returns true
+	// already set to ts.  If it is already set to ts it skips this and returns true.
+	// It also returns the head being replaced.
 	store.mu.Lock()
 	defer store.mu.Unlock()
 
 	// Ensure consistency by storing this new head on disk.
 	if errInner := store.writeHead(ctx, ts.Key()); errInner != nil {
-		return false, errors.Wrap(errInner, "failed to write new Head to datastore")
+		return block.UndefTipSet, false, errors.Wrap(errInner, "failed to write new Head to datastore")
 	}
 	if ts.Equals(store.head) {
-		return true, nil
+		return store.head, true, nil
 	}
 
+	prev := store.head
 	store.head = ts
 
-	return false, nil
+	return prev, false, nil
+}
+
+// SetMessageIndex attaches a message index to the store. The index is kept up
+// to date with the head once it has been built by BuildMessageIndex.
+func (store *Store) SetMessageIndex(idx *MessageIndex) {
+	store.msgIndex = idx
+}
+
+// LookupMessage returns the location on the head chain of the message with
+// cid c, as recorded by the store's message index. It returns
+// ErrMessageIndexNotReady if the index is not current with the head.
+func (store *Store) LookupMessage(c cid.Cid) (*MessageLocation, bool, error) {
+	if store.msgIndex == nil {
+		return nil, false, ErrNoMessageIndex
+	}
+	if !store.msgIndex.IsCurrent(store.GetHead()) {
+		return nil, false, ErrMessageIndexNotReady
+	}
+	return store.msgIndex.Get(c)
+}
+
+// SeedMessageIndex indexes only the messages of `tipsets`, which are ordered
+// from the head down to, but excluding, height `base`. See MessageIndex.Seed.
+func (store *Store) SeedMessageIndex(ctx context.Context, base abi.ChainEpoch, tipsets []block.TipSet) error {
+	if store.msgIndex == nil {
+		return nil
+	}
+	return store.msgIndex.Seed(ctx, base, tipsets)
+}
+
+// BuildMessageIndex indexes all messages from genesis to the current head if
+// the message index has never been written, and brings it up to date with the
+// head otherwise. The first build walks the whole chain.
+func (store *Store) BuildMessageIndex(ctx context.Context) error {
+	if store.msgIndex == nil {
+		return ErrNoMessageIndex
+	}
+	return store.msgIndex.Build(ctx, store, store.headTipSet)
+}
+
+// buildMessageIndex builds the message index, discarding it first if rebuild
+// is set.
+func (store *Store) buildMessageIndex(rebuild bool) {
+	build := store.msgIndex.Build
+	if rebuild {
+		build = store.msgIndex.Rebuild
+	}
+	if err := build(context.Background(), store, store.headTipSet); err != nil {
+		logStore.Errorf("failed to build message index: %s", err)
+	}
+}
+
+func (store *Store) headTipSet() (block.TipSet, error) {
+	return store.GetTipSet(store.GetHead())
 }
 
 // writeHead writes the given cid set as head to disk.
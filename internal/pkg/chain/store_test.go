@@ -311,6 +311,41 @@ func TestHead(t *testing.T) {
 	assert.Equal(t, link1.Key(), sr.Status().ValidatedHead)
 }
 
+// Heads that do not include the checkpoint are refused once it is stored.
+func TestHeadCheckpoint(t *testing.T) {
+	tf.UnitTest(t)
+	ctx := context.Background()
+
+	builder := chain.NewBuilder(t, address.Undef)
+	genTS := builder.NewGenesis()
+	r := repo.NewInMemoryRepo()
+	cs := newChainStore(r, genTS.At(0).Cid())
+
+	checkpoint := builder.AppendManyOn(2, genTS)
+	head := builder.AppendManyOn(2, checkpoint)
+	fork := builder.AppendManyOn(4, genTS)
+	requirePutTestChain(ctx, t, cs, head.Key(), builder, 5)
+	requirePutTestChain(ctx, t, cs, fork.Key(), builder, 5)
+	cs.SetCheckpoint(checkpoint.Key())
+	assert.Equal(t, checkpoint.Key(), cs.GetCheckpoint())
+
+	assertSetHead(t, cs, genTS)
+	assertSetHead(t, cs, head)
+
+	// Neither a fork nor a head below the checkpoint may replace it.
+	assert.Equal(t, chain.ErrReorgPastCheckpoint, cs.SetHead(ctx, fork))
+	assert.Equal(t, chain.ErrReorgPastCheckpoint, cs.SetHead(ctx, genTS))
+	assert.Equal(t, head.Key(), cs.GetHead())
+
+	// Reorgs to forks above the checkpoint are allowed.
+	sibling := builder.AppendManyOn(3, checkpoint)
+	requirePutTestChain(ctx, t, cs, sibling.Key(), builder, 3)
+	assertSetHead(t, cs, sibling)
+
+	// Reorgs down to the checkpoint are allowed.
+	assertSetHead(t, cs, checkpoint)
+}
+
 func assertEmptyCh(t *testing.T, ch <-chan interface{}) {
 	select {
 	case <-ch:
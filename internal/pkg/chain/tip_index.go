@@ -156,6 +156,20 @@ func (ti *TipIndex) HasByParentsAndHeight(pKey block.TipSetKey, h abi.ChainEpoch
 	return ok
 }
 
+// GetSince returns all tipsets and states stored in the TipIndex with height
+// at least h.
+func (ti *TipIndex) GetSince(h abi.ChainEpoch) []*TipSetMetadata {
+	ti.mu.Lock()
+	defer ti.mu.Unlock()
+	var ret []*TipSetMetadata
+	for _, tsas := range ti.tsasByID {
+		if tsas.TipSet.At(0).Height >= h {
+			ret = append(ret, tsas)
+		}
+	}
+	return ret
+}
+
 // makeKey returns a unique string for every parent set key and height input
 func makeKey(pKey string, h abi.ChainEpoch) string {
 	return fmt.Sprintf("p-%s h-%d", pKey, h)
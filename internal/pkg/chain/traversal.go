@@ -55,6 +55,28 @@ func (it *TipsetIterator) Next() error {
 	}
 }
 
+// IncludesTipSet returns true if `ts` is `start` or one of its ancestors. The
+// ancestors of `start` are traversed only down to the height of `ts`.
+func IncludesTipSet(ctx context.Context, store TipSetProvider, start, ts block.TipSet) (bool, error) {
+	height, err := ts.Height()
+	if err != nil {
+		return false, err
+	}
+	for it := IterAncestors(ctx, store, start); !it.Complete(); err = it.Next() {
+		if err != nil {
+			return false, err
+		}
+		h, err := it.Value().Height()
+		if err != nil {
+			return false, err
+		}
+		if h <= height {
+			return it.Value().Equals(ts), nil
+		}
+	}
+	return false, err
+}
+
 // BlockProvider provides blocks.
 type BlockProvider interface {
 	GetBlock(ctx context.Context, cid cid.Cid) (*block.Block, error)
@@ -9,6 +9,7 @@ import (
 	"github.com/filecoin-project/go-filecoin/internal/pkg/chainsync/internal/syncer"
 	"github.com/filecoin-project/go-filecoin/internal/pkg/chainsync/status"
 	"github.com/filecoin-project/go-filecoin/internal/pkg/clock"
+	"github.com/filecoin-project/go-filecoin/internal/pkg/repo"
 	"github.com/filecoin-project/go-filecoin/internal/pkg/slashing"
 )
 
@@ -27,9 +28,18 @@ type Manager struct {
 	transitionCh chan bool
 }
 
-// NewManager creates a new chain sync manager.
-func NewManager(fv syncer.FullBlockValidator, hv syncer.HeaderValidator, cs syncer.ChainSelector, s syncer.ChainReaderWriter, m *chain.MessageStore, f syncer.Fetcher, c clock.Clock, detector *slashing.ConsensusFaultDetector) (Manager, error) {
-	syncer, err := syncer.NewSyncer(fv, hv, cs, s, m, f, status.NewReporter(), c, detector)
+// BadTipSet records a tipset that failed validation.
+type BadTipSet = syncer.BadTipSet
+
+// NewManager creates a new chain sync manager. Up to `badTipSetCacheSize`
+// tipsets failing validation are remembered in `ds`, and the peers sending
+// them are reported to `scorer`.
+func NewManager(fv syncer.FullBlockValidator, hv syncer.HeaderValidator, cs syncer.ChainSelector, s syncer.ChainReaderWriter, m *chain.MessageStore, f syncer.Fetcher, c clock.Clock, detector *slashing.ConsensusFaultDetector, ds repo.Datastore, badTipSetCacheSize int, scorer syncer.PeerScorer) (Manager, error) {
+	badTipSets, err := syncer.NewBadTipSetCache(ds, badTipSetCacheSize)
+	if err != nil {
+		return Manager{}, err
+	}
+	syncer, err := syncer.NewSyncer(fv, hv, cs, s, m, f, status.NewReporter(), c, detector, badTipSets, scorer)
 	if err != nil {
 		return Manager{}, err
 	}
@@ -62,3 +72,15 @@ func (m *Manager) TransitionChannel() chan bool {
 func (m *Manager) Status() status.Status {
 	return m.syncer.Status()
 }
+
+// BadTipSets returns the tipsets that failed validation, most recently used first.
+func (m *Manager) BadTipSets() []*BadTipSet {
+	return m.syncer.BadTipSets().List()
+}
+
+// UnmarkBadTipSet forgets that a tipset failed validation, so that it is
+// validated again when next received. It returns false if the tipset was not
+// marked bad.
+func (m *Manager) UnmarkBadTipSet(key block.TipSetKey) (bool, error) {
+	return m.syncer.BadTipSets().Remove(key)
+}
@@ -0,0 +1,242 @@
+package exchange
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"time"
+
+	"github.com/filecoin-project/specs-actors/actors/abi"
+	logging "github.com/ipfs/go-log"
+	"github.com/libp2p/go-libp2p-core/host"
+	net "github.com/libp2p/go-libp2p-core/network"
+	"github.com/libp2p/go-libp2p-core/peer"
+	"github.com/pkg/errors"
+
+	"github.com/filecoin-project/go-filecoin/internal/pkg/block"
+	"github.com/filecoin-project/go-filecoin/internal/pkg/cborutil"
+	"github.com/filecoin-project/go-filecoin/internal/pkg/encoding"
+)
+
+var log = logging.Logger("chainsync.exchange")
+
+// HeaderRangeProtocolID is the libp2p protocol identifier for fetching the
+// block headers of a range of heights of a chain.
+const HeaderRangeProtocolID = "/fil/chain/headers/1.0.0"
+
+// MaxHeaderRangeHeights is the largest number of heights served for a single
+// request.
+const MaxHeaderRangeHeights = 256
+
+// headerRangeTimeout bounds the time spent serving or fetching one range.
+const headerRangeTimeout = 30 * time.Second
+
+// Status codes of a header range response.
+const (
+	// HeaderRangeOK is the status of a response carrying the requested range.
+	HeaderRangeOK = uint64(0)
+	// HeaderRangeBadRequest is the status of a response to a malformed request.
+	HeaderRangeBadRequest = uint64(1)
+	// HeaderRangeNotFound is the status of a response to a request for a
+	// chain or range the peer does not store.
+	HeaderRangeNotFound = uint64(2)
+)
+
+// HeaderRangeRequest asks for the headers of the tipsets of the chain of Head
+// whose heights are between MinHeight and MaxHeight, both included.
+type HeaderRangeRequest struct {
+	_         struct{} `cbor:",toarray"`
+	Head      block.TipSetKey
+	MaxHeight abi.ChainEpoch
+	MinHeight abi.ChainEpoch
+}
+
+// HeaderRangeResponse is the first message of a response. When the status is
+// HeaderRangeOK it is followed by TipSets messages of type HeaderRangeTipSet,
+// highest tipset first.
+type HeaderRangeResponse struct {
+	_       struct{} `cbor:",toarray"`
+	Status  uint64
+	Message string
+	TipSets uint64
+}
+
+// HeaderRangeTipSet carries the raw block headers of one tipset.
+type HeaderRangeTipSet struct {
+	_      struct{} `cbor:",toarray"`
+	Blocks [][]byte
+}
+
+type headerRangeChain interface {
+	GetTipSet(block.TipSetKey) (block.TipSet, error)
+}
+
+// HeaderRangeHandler serves the header range protocol from the tipsets of
+// the chain store.
+type HeaderRangeHandler struct {
+	host  host.Host
+	chain headerRangeChain
+}
+
+// NewHeaderRangeHandler creates a handler serving the header range protocol
+// from `chain`.
+func NewHeaderRangeHandler(h host.Host, chain headerRangeChain) *HeaderRangeHandler {
+	return &HeaderRangeHandler{
+		host:  h,
+		chain: chain,
+	}
+}
+
+// Register registers the handler with the network.
+func (h *HeaderRangeHandler) Register() {
+	h.host.SetStreamHandler(HeaderRangeProtocolID, h.handleNewStream)
+}
+
+func (h *HeaderRangeHandler) handleNewStream(s net.Stream) {
+	defer s.Close() // nolint: errcheck
+	_ = s.SetDeadline(time.Now().Add(headerRangeTimeout))
+
+	var req HeaderRangeRequest
+	if err := cborutil.NewMsgReader(s).ReadMsg(&req); err != nil {
+		log.Debugf("failed to read header range request: %s", err)
+		return
+	}
+	resp, tipsets := h.serve(&req)
+	if err := writeMsg(s, resp); err != nil {
+		log.Debugf("failed to write header range response: %s", err)
+		return
+	}
+	for _, ts := range tipsets {
+		msg := HeaderRangeTipSet{}
+		for i := 0; i < ts.Len(); i++ {
+			msg.Blocks = append(msg.Blocks, ts.At(i).ToNode().RawData())
+		}
+		if err := writeMsg(s, &msg); err != nil {
+			log.Debugf("failed to write header range tipset: %s", err)
+			return
+		}
+	}
+}
+
+// serve looks up the tipsets of the requested range by walking the parents
+// of the requested head.
+func (h *HeaderRangeHandler) serve(req *HeaderRangeRequest) (*HeaderRangeResponse, []block.TipSet) {
+	if req.MinHeight < 0 || req.MaxHeight < req.MinHeight || req.MaxHeight-req.MinHeight >= MaxHeaderRangeHeights {
+		return &HeaderRangeResponse{Status: HeaderRangeBadRequest, Message: "invalid height range"}, nil
+	}
+	var tipsets []block.TipSet
+	key := req.Head
+	for !key.Empty() {
+		ts, err := h.chain.GetTipSet(key)
+		if err != nil {
+			return &HeaderRangeResponse{Status: HeaderRangeNotFound, Message: fmt.Sprintf("tipset %s not found", key)}, nil
+		}
+		height, err := ts.Height()
+		if err != nil {
+			return &HeaderRangeResponse{Status: HeaderRangeNotFound, Message: err.Error()}, nil
+		}
+		if height < req.MinHeight {
+			break
+		}
+		if height <= req.MaxHeight {
+			tipsets = append(tipsets, ts)
+		}
+		if key, err = ts.Parents(); err != nil {
+			return &HeaderRangeResponse{Status: HeaderRangeNotFound, Message: err.Error()}, nil
+		}
+	}
+	return &HeaderRangeResponse{Status: HeaderRangeOK, TipSets: uint64(len(tipsets))}, tipsets
+}
+
+// HeaderRangeClient fetches ranges of headers from peers.
+type HeaderRangeClient struct {
+	host host.Host
+}
+
+// NewHeaderRangeClient creates a client of the header range protocol.
+func NewHeaderRangeClient(h host.Host) *HeaderRangeClient {
+	return &HeaderRangeClient{host: h}
+}
+
+// FetchHeaderRange fetches from peer `p` the tipsets of the chain of `head`
+// with heights between `minHeight` and `maxHeight`, highest first, and the
+// number of bytes received. The blocks are decoded but not validated, and the
+// tipsets are not checked to link to each other.
+func (c *HeaderRangeClient) FetchHeaderRange(ctx context.Context, p peer.ID, head block.TipSetKey, maxHeight, minHeight abi.ChainEpoch) ([]block.TipSet, int, error) {
+	ctx, cancel := context.WithTimeout(ctx, headerRangeTimeout)
+	defer cancel()
+	s, err := c.host.NewStream(ctx, p, HeaderRangeProtocolID)
+	if err != nil {
+		return nil, 0, err
+	}
+	defer s.Close() // nolint: errcheck
+	if deadline, ok := ctx.Deadline(); ok {
+		_ = s.SetDeadline(deadline)
+	}
+
+	if err := writeMsg(s, &HeaderRangeRequest{Head: head, MaxHeight: maxHeight, MinHeight: minHeight}); err != nil {
+		return nil, 0, err
+	}
+	cr := &countingReader{r: s}
+	mr := cborutil.NewMsgReader(cr)
+	var resp HeaderRangeResponse
+	if err := mr.ReadMsg(&resp); err != nil {
+		return nil, cr.n, errors.Wrap(err, "reading header range response")
+	}
+	if resp.Status != HeaderRangeOK {
+		return nil, cr.n, errors.Errorf("peer %s refused header range: %s", p, resp.Message)
+	}
+	if resp.TipSets > MaxHeaderRangeHeights {
+		return nil, cr.n, errors.Errorf("peer %s sent %d tipsets for at most %d heights", p, resp.TipSets, MaxHeaderRangeHeights)
+	}
+
+	var tipsets []block.TipSet
+	for i := uint64(0); i < resp.TipSets; i++ {
+		var msg HeaderRangeTipSet
+		if err := mr.ReadMsg(&msg); err != nil {
+			return nil, cr.n, errors.Wrap(err, "reading header range tipset")
+		}
+		var blks []*block.Block
+		for _, raw := range msg.Blocks {
+			blk, err := block.DecodeBlock(raw)
+			if err != nil {
+				return nil, cr.n, errors.Wrap(err, "decoding header range block")
+			}
+			blks = append(blks, blk)
+		}
+		ts, err := block.NewTipSet(blks...)
+		if err != nil {
+			return nil, cr.n, err
+		}
+		height, err := ts.Height()
+		if err != nil {
+			return nil, cr.n, err
+		}
+		if height > maxHeight || height < minHeight {
+			return nil, cr.n, errors.Errorf("peer %s sent tipset %s at height %d out of range", p, ts.Key(), height)
+		}
+		tipsets = append(tipsets, ts)
+	}
+	return tipsets, cr.n, nil
+}
+
+func writeMsg(w io.Writer, msg interface{}) error {
+	raw, err := encoding.Encode(msg)
+	if err != nil {
+		return err
+	}
+	_, err = w.Write(raw)
+	return err
+}
+
+// countingReader counts the bytes read through it.
+type countingReader struct {
+	r io.Reader
+	n int
+}
+
+func (cr *countingReader) Read(p []byte) (int, error) {
+	n, err := cr.r.Read(p)
+	cr.n += n
+	return n, err
+}
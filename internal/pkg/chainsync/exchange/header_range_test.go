@@ -0,0 +1,77 @@
+package exchange_test
+
+import (
+	"context"
+	"testing"
+
+	"github.com/filecoin-project/go-address"
+	"github.com/filecoin-project/specs-actors/actors/abi"
+	mocknet "github.com/libp2p/go-libp2p/p2p/net/mock"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/filecoin-project/go-filecoin/internal/pkg/block"
+	"github.com/filecoin-project/go-filecoin/internal/pkg/chain"
+	"github.com/filecoin-project/go-filecoin/internal/pkg/chainsync/exchange"
+	tf "github.com/filecoin-project/go-filecoin/internal/pkg/testhelpers/testflags"
+)
+
+func TestHeaderRange(t *testing.T) {
+	tf.UnitTest(t)
+	ctx := context.Background()
+
+	builder := chain.NewBuilder(t, address.Undef)
+	gen := builder.NewGenesis()
+	final := gen
+	for i := 0; i < 10; i++ {
+		final = builder.BuildOn(final, 2, nil)
+	}
+	// Leave a null round at height 11.
+	final = builder.BuildOn(final, 1, func(b *chain.BlockBuilder, i int) { b.IncHeight(1) })
+
+	mn := mocknet.New(ctx)
+	client, err := mn.GenPeer()
+	require.NoError(t, err)
+	server, err := mn.GenPeer()
+	require.NoError(t, err)
+	require.NoError(t, mn.LinkAll())
+	exchange.NewHeaderRangeHandler(server, builder).Register()
+	fetcher := exchange.NewHeaderRangeClient(client)
+
+	t.Run("fetches the tipsets of a range highest first", func(t *testing.T) {
+		tipsets, n, err := fetcher.FetchHeaderRange(ctx, server.ID(), final.Key(), 12, 3)
+		require.NoError(t, err)
+		assert.NotZero(t, n)
+
+		// Heights 12 and 10 to 3, skipping the null round.
+		expected := builder.RequireTipSets(final.Key(), 9)
+		require.Equal(t, len(expected), len(tipsets))
+		for i, ts := range expected {
+			assert.Equal(t, ts.Key(), tipsets[i].Key())
+			assert.Equal(t, ts.Len(), tipsets[i].Len())
+		}
+	})
+
+	t.Run("fetches an empty range", func(t *testing.T) {
+		tipsets, _, err := fetcher.FetchHeaderRange(ctx, server.ID(), final.Key(), 11, 11)
+		require.NoError(t, err)
+		assert.Empty(t, tipsets)
+	})
+
+	t.Run("refuses a range too long", func(t *testing.T) {
+		_, _, err := fetcher.FetchHeaderRange(ctx, server.ID(), final.Key(), exchange.MaxHeaderRangeHeights, 0)
+		assert.Error(t, err)
+	})
+
+	t.Run("refuses an unknown chain", func(t *testing.T) {
+		unknown := chain.NewBuilder(t, address.Undef)
+		other := unknown.BuildOn(unknown.NewGenesis(), 1, func(b *chain.BlockBuilder, i int) { b.IncHeight(5) })
+		_, _, err := fetcher.FetchHeaderRange(ctx, server.ID(), other.Key(), abi.ChainEpoch(6), 0)
+		assert.Error(t, err)
+	})
+
+	t.Run("fails against a peer without the protocol", func(t *testing.T) {
+		_, _, err := exchange.NewHeaderRangeClient(server).FetchHeaderRange(ctx, client.ID(), block.NewTipSetKey(gen.At(0).Cid()), 0, 0)
+		assert.Error(t, err)
+	})
+}
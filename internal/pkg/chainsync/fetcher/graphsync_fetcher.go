@@ -9,6 +9,7 @@ import (
 	"github.com/filecoin-project/go-amt-ipld/v2"
 	"github.com/filecoin-project/go-filecoin/internal/pkg/block"
 	"github.com/filecoin-project/go-filecoin/internal/pkg/chainsync/internal/syncer"
+	"github.com/filecoin-project/specs-actors/actors/abi"
 	blocks "github.com/ipfs/go-block-format"
 	"github.com/ipfs/go-cid"
 	"github.com/ipfs/go-graphsync"
@@ -28,6 +29,7 @@ import (
 	"github.com/filecoin-project/go-filecoin/internal/pkg/consensus"
 	"github.com/filecoin-project/go-filecoin/internal/pkg/encoding"
 	"github.com/filecoin-project/go-filecoin/internal/pkg/types"
+	"github.com/filecoin-project/go-filecoin/internal/pkg/vm"
 )
 
 var logGraphsyncFetcher = logging.Logger("chainsync.fetcher.graphsync")
@@ -66,6 +68,24 @@ type graphsyncFallbackPeerTracker interface {
 	Self() peer.ID
 }
 
+// fetchScorer is told how peers respond to graphsync requests.
+type fetchScorer interface {
+	RecordFailedFetch(peer.ID)
+	RecordSlowFetch(peer.ID)
+	RecordGoodFetch(peer.ID)
+}
+
+// HeaderRangeExchange fetches the headers of the tipsets of a range of
+// heights of a chain from a peer, returning the tipsets highest first and the
+// number of bytes received.
+type HeaderRangeExchange interface {
+	FetchHeaderRange(ctx context.Context, p peer.ID, head block.TipSetKey, maxHeight, minHeight abi.ChainEpoch) ([]block.TipSet, int, error)
+}
+
+// errProgressTimeout is returned for graphsync requests cancelled after
+// making no progress for the progressTimeout.
+var errProgressTimeout = errors.New("graphsync request stopped making progress")
+
 // GraphSyncFetcher is used to fetch data over the network.  It is implemented
 // using a Graphsync exchange to fetch tipsets recursively
 type GraphSyncFetcher struct {
@@ -74,19 +94,26 @@ type GraphSyncFetcher struct {
 	store       bstore.Blockstore
 	ssb         selectorbuilder.SelectorSpecBuilder
 	peerTracker graphsyncFallbackPeerTracker
+	scorer      fetchScorer
+	headerRange HeaderRangeExchange
 	systemClock clock.Clock
 }
 
 // NewGraphSyncFetcher returns a GraphsyncFetcher wired up to the input Graphsync exchange and
-// attached local blockservice for reloading blocks in memory once they are returned
+// attached local blockservice for reloading blocks in memory once they are returned.
+// The responses of peers are recorded in the scorer, unless it is nil. Long
+// chains of headers are fetched by height range through headerRange, unless
+// it is nil.
 func NewGraphSyncFetcher(ctx context.Context, exchange GraphExchange, blockstore bstore.Blockstore,
-	bv consensus.SyntaxValidator, systemClock clock.Clock, pt graphsyncFallbackPeerTracker) *GraphSyncFetcher {
+	bv consensus.SyntaxValidator, systemClock clock.Clock, pt graphsyncFallbackPeerTracker, scorer fetchScorer, headerRange HeaderRangeExchange) *GraphSyncFetcher {
 	gsf := &GraphSyncFetcher{
 		store:       blockstore,
 		validator:   bv,
 		exchange:    exchange,
 		ssb:         selectorbuilder.NewSelectorSpecBuilder(ipldfree.NodeBuilder()),
 		peerTracker: pt,
+		scorer:      scorer,
+		headerRange: headerRange,
 		systemClock: systemClock,
 	}
 	return gsf
@@ -159,6 +186,7 @@ func (gsf *GraphSyncFetcher) fetchFirstTipset(ctx context.Context, tsKey block.T
 		peer := rpf.CurrentPeer()
 		logGraphsyncFetcher.Infof("fetching initial tipset %s from peer %s", tsKey, peer)
 		err := gsf.fetchBlocks(ctx, selGen, blocksToFetch, peer)
+		gsf.recordFetch(ctx, peer, err)
 		if err != nil {
 			// A likely case is the peer doesn't have the tipset. When graphsync provides
 			// this status we should quiet this log.
@@ -202,6 +230,7 @@ func (gsf *GraphSyncFetcher) fetchRemainingTipsets(ctx context.Context, starting
 		peer := rpf.CurrentPeer()
 		logGraphsyncFetcher.Infof("fetching chain from height %d, block %s, peer %s, %d levels", childBlock.Height, childBlock.Cid(), peer, recursionDepth)
 		err := gsf.fetchBlocksRecursively(ctx, recSelGen, childBlock.Cid(), peer, recursionDepth)
+		gsf.recordFetch(ctx, peer, err)
 		if err != nil {
 			// something went wrong in a graphsync request, but we want to keep trying other peers, so
 			// just log error
@@ -292,8 +321,25 @@ func (gsf *GraphSyncFetcher) fetchThroughAMTSelector(recursionDepth uint32) sele
 				gsf.ssb.ExploreIndex(amtNodeValuesFieldIndex, gsf.ssb.ExploreAll(gsf.ssb.Matcher())))))
 }
 
+// recordFetch tells the scorer how a peer responded to a request that returned
+// `err`. Requests cancelled by the caller are not recorded.
+func (gsf *GraphSyncFetcher) recordFetch(ctx context.Context, p peer.ID, err error) {
+	if gsf.scorer == nil || ctx.Err() != nil {
+		return
+	}
+	switch {
+	case err == errProgressTimeout:
+		gsf.scorer.RecordSlowFetch(p)
+	case err != nil:
+		gsf.scorer.RecordFailedFetch(p)
+	default:
+		gsf.scorer.RecordGoodFetch(p)
+	}
+}
+
 func (gsf *GraphSyncFetcher) consumeResponse(requestChan <-chan graphsync.ResponseProgress, errChan <-chan error, cancelFunc func()) error {
 	timer := gsf.systemClock.NewTimer(progressTimeout)
+	timedOut := false
 	var anyError error
 	for errChan != nil || requestChan != nil {
 		select {
@@ -309,9 +355,13 @@ func (gsf *GraphSyncFetcher) consumeResponse(requestChan <-chan graphsync.Respon
 			}
 			timer.Reset(progressTimeout)
 		case <-timer.Chan():
+			timedOut = true
 			cancelFunc()
 		}
 	}
+	if timedOut {
+		return errProgressTimeout
+	}
 	return anyError
 }
 
@@ -389,6 +439,11 @@ func (gsf *GraphSyncFetcher) loadAndVerifyFullBlock(ctx context.Context, key blo
 		return block.UndefTipSet, nil, err
 	}
 
+	// Messages by the root of their collection, to check the gas limit of
+	// each block once all its messages are loaded.
+	secpByRoot := make(map[cid.Cid][]*types.SignedMessage)
+	blsByRoot := make(map[cid.Cid][]*types.UnsignedMessage)
+
 	err = gsf.loadAndVerifySubComponents(ctx, tip, incomplete,
 		func(meta types.TxMeta) cid.Cid {
 			return meta.SecpRoot.Cid
@@ -411,6 +466,7 @@ func (gsf *GraphSyncFetcher) loadAndVerifyFullBlock(ctx context.Context, key blo
 			if err := gsf.validator.ValidateMessagesSyntax(ctx, messages); err != nil {
 				return errors.Wrapf(err, "invalid messages for for message collection (cid %s)", rawBlock.Cid())
 			}
+			secpByRoot[rawBlock.Cid()] = messages
 			return nil
 		})
 	if err != nil {
@@ -439,6 +495,7 @@ func (gsf *GraphSyncFetcher) loadAndVerifyFullBlock(ctx context.Context, key blo
 			if err := gsf.validator.ValidateUnsignedMessagesSyntax(ctx, messages); err != nil {
 				return errors.Wrapf(err, "invalid messages for for message collection (cid %s)", rawBlock.Cid())
 			}
+			blsByRoot[rawBlock.Cid()] = messages
 			return nil
 		})
 	if err != nil {
@@ -453,9 +510,62 @@ func (gsf *GraphSyncFetcher) loadAndVerifyFullBlock(ctx context.Context, key blo
 		return block.UndefTipSet, incompleteArr, nil
 	}
 
+	for i := 0; i < tip.Len(); i++ {
+		blk := tip.At(i)
+		meta, err := gsf.loadTxMeta(blk.Messages.Cid)
+		if err != nil {
+			return block.UndefTipSet, nil, err
+		}
+		if err := gsf.validator.ValidateMessagesGasLimit(ctx, secpByRoot[meta.SecpRoot.Cid], blsByRoot[meta.BLSRoot.Cid]); err != nil {
+			return block.UndefTipSet, nil, errors.Wrapf(err, "invalid messages for block %s", blk.Cid())
+		}
+	}
+
+	if err := gsf.verifyReceipts(ctx, tip); err != nil {
+		return block.UndefTipSet, nil, err
+	}
+
 	return tip, nil, nil
 }
 
+// verifyReceipts validates the syntax of the receipts of each block of the
+// tipset that were fetched along with it. Receipts that were not fetched are
+// not required: they are computed and checked against the block when the
+// tipset is validated.
+func (gsf *GraphSyncFetcher) verifyReceipts(ctx context.Context, tip block.TipSet) error {
+	for i := 0; i < tip.Len(); i++ {
+		root := tip.At(i).MessageReceipts.Cid
+		ok, err := gsf.store.Has(root)
+		if err != nil {
+			return err
+		}
+		if !ok {
+			continue
+		}
+
+		receipts := []vm.MessageReceipt{}
+		err = gsf.loadAndProcessAMTData(ctx, root, func(receiptBlock blocks.Block) error {
+			var receipt vm.MessageReceipt
+			if err := encoding.Decode(receiptBlock.RawData(), &receipt); err != nil {
+				return errors.Wrapf(err, "could not decode receipt (cid %s)", receiptBlock.Cid())
+			}
+			receipts = append(receipts, receipt)
+			return nil
+		})
+		if err == bstore.ErrNotFound {
+			continue
+		}
+		if err != nil {
+			return err
+		}
+
+		if err := gsf.validator.ValidateReceiptsSyntax(ctx, receipts); err != nil {
+			return errors.Wrapf(err, "invalid receipts for receipt collection (cid %s)", root)
+		}
+	}
+	return nil
+}
+
 // loadAndProcessAMTData processes data loaded from an AMT that is stored in the fetcher's datastore.
 func (gsf *GraphSyncFetcher) loadAndProcessAMTData(ctx context.Context, c cid.Cid, processFn func(b blocks.Block) error) error {
 	as := cbor.NewCborStore(gsf.store)
@@ -628,6 +738,8 @@ func (pri *requestPeerFinder) CurrentPeer() peer.ID {
 	return pri.currentPeer
 }
 
+// FindNextPeer moves to the next untried peer, in the order the peer tracker
+// lists them.
 func (pri *requestPeerFinder) FindNextPeer() error {
 	chains := pri.peerTracker.List()
 	for _, chain := range chains {
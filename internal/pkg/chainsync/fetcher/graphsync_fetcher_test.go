@@ -6,10 +6,12 @@ import (
 	"fmt"
 	"io"
 	"reflect"
+	"sync"
 	"testing"
 	"time"
 
 	"github.com/filecoin-project/go-address"
+	"github.com/filecoin-project/specs-actors/actors/abi"
 	"github.com/ipfs/go-datastore"
 	dss "github.com/ipfs/go-datastore/sync"
 	graphsync "github.com/ipfs/go-graphsync/impl"
@@ -31,6 +33,7 @@ import (
 
 	"github.com/filecoin-project/go-filecoin/internal/pkg/block"
 	"github.com/filecoin-project/go-filecoin/internal/pkg/chain"
+	"github.com/filecoin-project/go-filecoin/internal/pkg/chainsync/exchange"
 	"github.com/filecoin-project/go-filecoin/internal/pkg/chainsync/fetcher"
 	"github.com/filecoin-project/go-filecoin/internal/pkg/clock"
 	"github.com/filecoin-project/go-filecoin/internal/pkg/consensus"
@@ -154,7 +157,7 @@ func TestGraphsyncFetcher(t *testing.T) {
 		mgs.stubResponseWithLoader(pid0, layer1Selector, loader, final.Key().ToSlice()...)
 		mgs.stubResponseWithLoader(pid0, recursiveSelector(1), loader, final.At(0).Cid())
 
-		fetcher := fetcher.NewGraphSyncFetcher(ctx, mgs, bs, bv, fc, newFakePeerTracker(chain0))
+		fetcher := fetcher.NewGraphSyncFetcher(ctx, mgs, bs, bv, fc, newFakePeerTracker(chain0), nil, nil)
 		done := doneAt(gen.Key())
 
 		ts, err := fetcher.FetchTipSets(ctx, final.Key(), pid0, done)
@@ -183,7 +186,7 @@ func TestGraphsyncFetcher(t *testing.T) {
 		mgs.expectRequestToRespondWithLoader(pid2, layer1Selector, loader, final.At(2).Cid())
 		mgs.expectRequestToRespondWithLoader(pid2, recursiveSelector(1), loader, final.At(0).Cid())
 
-		fetcher := fetcher.NewGraphSyncFetcher(ctx, mgs, bs, bv, fc, pt)
+		fetcher := fetcher.NewGraphSyncFetcher(ctx, mgs, bs, bv, fc, pt, nil, nil)
 
 		done := doneAt(gen.Key())
 		ts, err := fetcher.FetchTipSets(ctx, final.Key(), pid0, done)
@@ -210,7 +213,7 @@ func TestGraphsyncFetcher(t *testing.T) {
 		mgs.expectRequestToRespondWithLoader(pid1, layer1Selector, errorLoader, final.At(1).Cid(), final.At(2).Cid())
 		mgs.expectRequestToRespondWithLoader(pid2, layer1Selector, errorLoader, final.At(1).Cid(), final.At(2).Cid())
 
-		fetcher := fetcher.NewGraphSyncFetcher(ctx, mgs, bs, bv, fc, pt)
+		fetcher := fetcher.NewGraphSyncFetcher(ctx, mgs, bs, bv, fc, pt, nil, nil)
 
 		done := doneAt(gen.Key())
 
@@ -233,7 +236,7 @@ func TestGraphsyncFetcher(t *testing.T) {
 		errorOnMessagesLoader := errorOnCidsLoader(loader, final2Meta.SecpRoot.Cid)
 		mgs.expectRequestToRespondWithLoader(pid0, layer1Selector, errorOnMessagesLoader, final.Key().ToSlice()...)
 
-		fetcher := fetcher.NewGraphSyncFetcher(ctx, mgs, bs, bv, fc, newFakePeerTracker(chain0))
+		fetcher := fetcher.NewGraphSyncFetcher(ctx, mgs, bs, bv, fc, newFakePeerTracker(chain0), nil, nil)
 
 		done := doneAt(gen.Key())
 		ts, err := fetcher.FetchTipSets(ctx, final.Key(), pid0, done)
@@ -269,7 +272,7 @@ func TestGraphsyncFetcher(t *testing.T) {
 		mgs.expectRequestToRespondWithLoader(pid0, recursiveSelector(4), pid0Loader, blocks[0].Cid())
 		mgs.expectRequestToRespondWithLoader(pid1, recursiveSelector(4), loader, blocks[2].Cid())
 
-		fetcher := fetcher.NewGraphSyncFetcher(ctx, mgs, bs, bv, fc, pt)
+		fetcher := fetcher.NewGraphSyncFetcher(ctx, mgs, bs, bv, fc, pt, nil, nil)
 
 		done := func(ts block.TipSet) (bool, error) {
 			if ts.Key().Equals(gen.Key()) {
@@ -309,7 +312,7 @@ func TestGraphsyncFetcher(t *testing.T) {
 		mgs.expectRequestToRespondWithLoader(pid0, recursiveSelector(1), errorInMultiBlockLoader, final.At(0).Cid())
 		mgs.expectRequestToRespondWithLoader(pid0, recursiveSelector(4), errorInMultiBlockLoader, penultimate.At(0).Cid())
 
-		fetcher := fetcher.NewGraphSyncFetcher(ctx, mgs, bs, bv, fc, newFakePeerTracker(chain0))
+		fetcher := fetcher.NewGraphSyncFetcher(ctx, mgs, bs, bv, fc, newFakePeerTracker(chain0), nil, nil)
 		done := doneAt(gen.Key())
 
 		ts, err := fetcher.FetchTipSets(ctx, final.Key(), pid0, done)
@@ -338,7 +341,7 @@ func TestGraphsyncFetcher(t *testing.T) {
 		mgs.expectRequestToRespondWithLoader(pid0, recursiveSelector(4), errorInMultiBlockLoader, penultimate.At(0).Cid())
 		mgs.expectRequestToRespondWithLoader(pid1, recursiveSelector(4), loader, withMultiParent.At(0).Cid())
 
-		fetcher := fetcher.NewGraphSyncFetcher(ctx, mgs, bs, bv, fc, newFakePeerTracker(chain0, chain1, chain2))
+		fetcher := fetcher.NewGraphSyncFetcher(ctx, mgs, bs, bv, fc, newFakePeerTracker(chain0, chain1, chain2), nil, nil)
 		done := doneAt(gen.Key())
 
 		ts, err := fetcher.FetchTipSets(ctx, final.Key(), pid0, done)
@@ -389,7 +392,7 @@ func TestGraphsyncFetcher(t *testing.T) {
 				receivedRequestCount++
 			}
 
-			fetcher := fetcher.NewGraphSyncFetcher(ctx, mgs, bs, bv, fc, newFakePeerTracker(chain0))
+			fetcher := fetcher.NewGraphSyncFetcher(ctx, mgs, bs, bv, fc, newFakePeerTracker(chain0), nil, nil)
 			done := doneAt(tipset.Key())
 
 			ts, err := fetcher.FetchTipSets(ctx, final.Key(), pid0, done)
@@ -413,7 +416,7 @@ func TestGraphsyncFetcher(t *testing.T) {
 		chain0 := block.NewChainInfo(pid0, pid0, key, 0)
 		notDecodableLoader := simpleLoader([]format.Node{notDecodableBlock})
 		mgs.stubResponseWithLoader(pid0, layer1Selector, notDecodableLoader, notDecodableBlock.Cid())
-		fetcher := fetcher.NewGraphSyncFetcher(ctx, mgs, bs, bv, fc, newFakePeerTracker(chain0))
+		fetcher := fetcher.NewGraphSyncFetcher(ctx, mgs, bs, bv, fc, newFakePeerTracker(chain0), nil, nil)
 
 		done := doneAt(key)
 		ts, err := fetcher.FetchTipSets(ctx, key, pid0, done)
@@ -431,7 +434,7 @@ func TestGraphsyncFetcher(t *testing.T) {
 		chain0 := block.NewChainInfo(pid0, pid0, key, blk.Height)
 		invalidSyntaxLoader := simpleLoader([]format.Node{blk.ToNode()})
 		mgs.stubResponseWithLoader(pid0, layer1Selector, invalidSyntaxLoader, blk.Cid())
-		fetcher := fetcher.NewGraphSyncFetcher(ctx, mgs, bs, bv, fc, newFakePeerTracker(chain0))
+		fetcher := fetcher.NewGraphSyncFetcher(ctx, mgs, bs, bv, fc, newFakePeerTracker(chain0), nil, nil)
 		done := doneAt(key)
 		ts, err := fetcher.FetchTipSets(ctx, key, pid0, done)
 		require.EqualError(t, err, fmt.Sprintf("invalid block %s: block %s has nil miner address", blk.Cid().String(), blk.Cid().String()))
@@ -450,7 +453,7 @@ func TestGraphsyncFetcher(t *testing.T) {
 		require.NoError(t, err)
 		notDecodableLoader := simpleLoader([]format.Node{blk.ToNode(), notDecodableBlock, nd})
 		mgs.stubResponseWithLoader(pid0, layer1Selector, notDecodableLoader, blk.Cid())
-		fetcher := fetcher.NewGraphSyncFetcher(ctx, mgs, bs, bv, fc, newFakePeerTracker(chain0))
+		fetcher := fetcher.NewGraphSyncFetcher(ctx, mgs, bs, bv, fc, newFakePeerTracker(chain0), nil, nil)
 
 		done := doneAt(key)
 		ts, err := fetcher.FetchTipSets(ctx, key, pid0, done)
@@ -470,7 +473,7 @@ func TestGraphsyncFetcher(t *testing.T) {
 		errorMv := mockSyntaxValidator{
 			validateMessagesError: fmt.Errorf("Everything Failed"),
 		}
-		fetcher := fetcher.NewGraphSyncFetcher(ctx, mgs, bs, errorMv, fc, newFakePeerTracker(chain0))
+		fetcher := fetcher.NewGraphSyncFetcher(ctx, mgs, bs, errorMv, fc, newFakePeerTracker(chain0), nil, nil)
 		done := doneAt(gen.Key())
 
 		ts, err := fetcher.FetchTipSets(ctx, final.Key(), pid0, done)
@@ -478,6 +481,32 @@ func TestGraphsyncFetcher(t *testing.T) {
 		require.Error(t, err, "invalid messages for for message collection (cid %s)", final.At(0).Messages.String())
 	})
 
+	t.Run("fetched receipts don't validate", func(t *testing.T) {
+		gen := builder.NewGenesis()
+		final := builder.BuildOn(gen, 1, withMessageEachBuilder)
+		height, err := final.Height()
+		require.NoError(t, err)
+		chain0 := block.NewChainInfo(pid0, pid0, final.Key(), height)
+		mgs := newMockableGraphsync(ctx, bs, fc, t)
+		mgs.stubResponseWithLoader(pid0, layer1Selector, loader, final.Key().ToSlice()...)
+
+		// The receipts are in the store along with the fetched blocks.
+		receipts, err := builder.GetBlockstoreValue(ctx, final.At(0).MessageReceipts.Cid)
+		require.NoError(t, err)
+		require.NoError(t, bs.Put(receipts))
+
+		errorMv := mockSyntaxValidator{
+			validateReceiptsError: fmt.Errorf("Everything Failed"),
+		}
+		fetcher := fetcher.NewGraphSyncFetcher(ctx, mgs, bs, errorMv, fc, newFakePeerTracker(chain0), nil, nil)
+		done := doneAt(gen.Key())
+
+		ts, err := fetcher.FetchTipSets(ctx, final.Key(), pid0, done)
+		require.Nil(t, ts)
+		require.Error(t, err)
+		assert.Contains(t, err.Error(), "invalid receipts")
+	})
+
 	t.Run("hangup occurs during first layer fetch but recovers through fallback", func(t *testing.T) {
 		gen := builder.NewGenesis()
 		final := builder.BuildOn(gen, 3, withMessageEachBuilder)
@@ -496,7 +525,7 @@ func TestGraphsyncFetcher(t *testing.T) {
 		mgs.expectRequestToRespondWithLoader(pid2, layer1Selector, loader, final.At(2).Cid())
 		mgs.expectRequestToRespondWithLoader(pid2, recursiveSelector(1), loader, final.At(0).Cid())
 
-		fetcher := fetcher.NewGraphSyncFetcher(ctx, mgs, bs, bv, fc, pt)
+		fetcher := fetcher.NewGraphSyncFetcher(ctx, mgs, bs, bv, fc, pt, nil, nil)
 		done := doneAt(gen.Key())
 
 		ts, err := fetcher.FetchTipSets(ctx, final.Key(), pid0, done)
@@ -523,7 +552,7 @@ func TestGraphsyncFetcher(t *testing.T) {
 		mgs.expectRequestToRespondWithHangupAfter(pid1, layer1Selector, loader, 0, final.At(1).Cid(), final.At(2).Cid())
 		mgs.expectRequestToRespondWithHangupAfter(pid2, layer1Selector, loader, 0, final.At(1).Cid(), final.At(2).Cid())
 
-		fetcher := fetcher.NewGraphSyncFetcher(ctx, mgs, bs, bv, fc, pt)
+		fetcher := fetcher.NewGraphSyncFetcher(ctx, mgs, bs, bv, fc, pt, nil, nil)
 		done := doneAt(gen.Key())
 		ts, err := fetcher.FetchTipSets(ctx, final.Key(), pid0, done)
 
@@ -558,7 +587,7 @@ func TestGraphsyncFetcher(t *testing.T) {
 		mgs.expectRequestToRespondWithHangupAfter(pid0, recursiveSelector(4), loader, 2*visitsPerBlock, blocks[0].Cid())
 		mgs.expectRequestToRespondWithLoader(pid1, recursiveSelector(4), loader, blocks[2].Cid())
 
-		fetcher := fetcher.NewGraphSyncFetcher(ctx, mgs, bs, bv, fc, pt)
+		fetcher := fetcher.NewGraphSyncFetcher(ctx, mgs, bs, bv, fc, pt, nil, nil)
 
 		done := func(ts block.TipSet) (bool, error) {
 			if ts.Key().Equals(gen.Key()) {
@@ -598,7 +627,7 @@ func TestGraphsyncFetcher(t *testing.T) {
 		mgs.expectRequestToRespondWithLoader(pid0, recursiveSelector(1), loader, final.At(0).Cid())
 		mgs.expectRequestToRespondWithHangupAfter(pid0, recursiveSelector(4), loader, 2*visitsPerBlock, penultimate.At(0).Cid())
 
-		fetcher := fetcher.NewGraphSyncFetcher(ctx, mgs, bs, bv, fc, newFakePeerTracker(chain0))
+		fetcher := fetcher.NewGraphSyncFetcher(ctx, mgs, bs, bv, fc, newFakePeerTracker(chain0), nil, nil)
 		done := doneAt(gen.Key())
 
 		ts, err := fetcher.FetchTipSets(ctx, final.Key(), pid0, done)
@@ -627,7 +656,7 @@ func TestGraphsyncFetcher(t *testing.T) {
 		mgs.expectRequestToRespondWithHangupAfter(pid0, recursiveSelector(4), loader, 2*visitsPerBlock, penultimate.At(0).Cid())
 		mgs.expectRequestToRespondWithLoader(pid1, recursiveSelector(4), loader, withMultiParent.At(0).Cid())
 
-		fetcher := fetcher.NewGraphSyncFetcher(ctx, mgs, bs, bv, fc, newFakePeerTracker(chain0, chain1, chain2))
+		fetcher := fetcher.NewGraphSyncFetcher(ctx, mgs, bs, bv, fc, newFakePeerTracker(chain0, chain1, chain2), nil, nil)
 		done := doneAt(gen.Key())
 
 		ts, err := fetcher.FetchTipSets(ctx, final.Key(), pid0, done)
@@ -720,7 +749,7 @@ func TestHeadersOnlyGraphsyncFetch(t *testing.T) {
 		mgs.stubResponseWithLoader(pid0, layer1Selector, loader, final.Key().ToSlice()...)
 		mgs.stubResponseWithLoader(pid0, recursiveSelector(1), loader, final.At(0).Cid())
 
-		fetcher := fetcher.NewGraphSyncFetcher(ctx, mgs, bs, bv, fc, newFakePeerTracker(chain0))
+		fetcher := fetcher.NewGraphSyncFetcher(ctx, mgs, bs, bv, fc, newFakePeerTracker(chain0), nil, nil)
 		done := doneAt(gen.Key())
 
 		ts, err := fetcher.FetchTipSetHeaders(ctx, final.Key(), pid0, done)
@@ -745,7 +774,7 @@ func TestHeadersOnlyGraphsyncFetch(t *testing.T) {
 		require.NoError(t, err)
 		notDecodableLoader := simpleLoader([]format.Node{blk.ToNode(), notDecodableBlock, nd})
 		mgs.stubResponseWithLoader(pid0, layer1Selector, notDecodableLoader, blk.Cid())
-		fetcher := fetcher.NewGraphSyncFetcher(ctx, mgs, bs, bv, fc, newFakePeerTracker(chain0))
+		fetcher := fetcher.NewGraphSyncFetcher(ctx, mgs, bs, bv, fc, newFakePeerTracker(chain0), nil, nil)
 
 		done := doneAt(key)
 		ts, err := fetcher.FetchTipSetHeaders(ctx, key, pid0, done)
@@ -813,7 +842,7 @@ func TestRealWorldGraphsyncFetchOnlyHeaders(t *testing.T) {
 	bs := bstore.NewBlockstore(dss.MutexWrap(datastore.NewMapDatastore()))
 
 	bv := consensus.NewDefaultBlockValidator(chainClock)
-	pt := discovery.NewPeerTracker(peer.ID(""))
+	pt := discovery.NewPeerTracker(peer.ID(""), nil)
 	pt.Track(block.NewChainInfo(host2.ID(), host2.ID(), block.TipSetKey{}, 0))
 
 	localLoader := gsstoreutil.LoaderForBlockstore(bs)
@@ -821,7 +850,7 @@ func TestRealWorldGraphsyncFetchOnlyHeaders(t *testing.T) {
 
 	localGraphsync := graphsync.New(ctx, gsnet1, bridge1, localLoader, localStorer)
 
-	fetcher := fetcher.NewGraphSyncFetcher(ctx, localGraphsync, bs, bv, fc, pt)
+	fetcher := fetcher.NewGraphSyncFetcher(ctx, localGraphsync, bs, bv, fc, pt, nil, nil)
 
 	remoteLoader := func(lnk ipld.Link, lnkCtx ipld.LinkContext) (io.Reader, error) {
 		cid := lnk.(cidlink.Link).Cid
@@ -907,7 +936,7 @@ func TestRealWorldGraphsyncFetchAcrossNetwork(t *testing.T) {
 	bs := bstore.NewBlockstore(dss.MutexWrap(datastore.NewMapDatastore()))
 	bv := th.NewFakeBlockValidator()
 	fc := th.NewFakeClock(time.Now())
-	pt := discovery.NewPeerTracker(peer.ID(""))
+	pt := discovery.NewPeerTracker(peer.ID(""), nil)
 	pt.Track(block.NewChainInfo(host2.ID(), host2.ID(), block.TipSetKey{}, 0))
 
 	localLoader := gsstoreutil.LoaderForBlockstore(bs)
@@ -915,7 +944,7 @@ func TestRealWorldGraphsyncFetchAcrossNetwork(t *testing.T) {
 
 	localGraphsync := graphsync.New(ctx, gsnet1, bridge1, localLoader, localStorer)
 
-	fetcher := fetcher.NewGraphSyncFetcher(ctx, localGraphsync, bs, bv, fc, pt)
+	fetcher := fetcher.NewGraphSyncFetcher(ctx, localGraphsync, bs, bv, fc, pt, nil, nil)
 
 	remoteLoader := func(lnk ipld.Link, lnkCtx ipld.LinkContext) (io.Reader, error) {
 		cid := lnk.(cidlink.Link).Cid
@@ -955,3 +984,157 @@ func TestRealWorldGraphsyncFetchAcrossNetwork(t *testing.T) {
 		assert.True(t, stored)
 	}
 }
+
+func TestRealWorldGraphsyncFetchHeadersWindowed(t *testing.T) {
+	tf.IntegrationTest(t)
+	ctx := context.Background()
+	// setup a chain of tipsets of two blocks, longer than a window
+	builder := chain.NewBuilder(t, address.Undef)
+	gen := builder.NewGenesis()
+	tipCount := 150
+	final := gen
+	for i := 0; i < tipCount; i++ {
+		final = builder.BuildOn(final, 2, nil)
+	}
+
+	// setup a network of the fetching peer and two peers advertising the head
+	mn := mocknet.New(ctx)
+	host1, err := mn.GenPeer()
+	require.NoError(t, err)
+	host2, err := mn.GenPeer()
+	require.NoError(t, err)
+	host3, err := mn.GenPeer()
+	require.NoError(t, err)
+	require.NoError(t, mn.LinkAll())
+
+	bs := bstore.NewBlockstore(dss.MutexWrap(datastore.NewMapDatastore()))
+	bv := th.NewFakeBlockValidator()
+	fc := th.NewFakeClock(time.Now())
+	height, err := final.Height()
+	require.NoError(t, err)
+	pt := discovery.NewPeerTracker(host1.ID(), nil)
+	pt.Track(block.NewChainInfo(host2.ID(), host2.ID(), final.Key(), height))
+	pt.Track(block.NewChainInfo(host3.ID(), host3.ID(), final.Key(), height))
+
+	localGraphsync := graphsync.New(ctx, gsnet.NewFromLibp2pHost(host1), ipldbridge.NewIPLDBridge(), gsstoreutil.LoaderForBlockstore(bs), gsstoreutil.StorerForBlockstore(bs))
+	scorer := &fetchCountingScorer{good: make(map[peer.ID]int)}
+	fetcher := fetcher.NewGraphSyncFetcher(ctx, localGraphsync, bs, bv, fc, pt, scorer, nil)
+
+	remoteLoader := func(lnk ipld.Link, lnkCtx ipld.LinkContext) (io.Reader, error) {
+		cid := lnk.(cidlink.Link).Cid
+		b, err := builder.GetBlockstoreValue(ctx, cid)
+		if err != nil {
+			return nil, err
+		}
+		return bytes.NewBuffer(b.RawData()), nil
+	}
+	graphsync.New(ctx, gsnet.NewFromLibp2pHost(host2), ipldbridge.NewIPLDBridge(), remoteLoader, nil)
+	graphsync.New(ctx, gsnet.NewFromLibp2pHost(host3), ipldbridge.NewIPLDBridge(), remoteLoader, nil)
+
+	reachesGenesis := func(parents block.TipSetKey) bool { return parents.Equals(gen.Key()) }
+	tipsets, err := fetcher.FetchTipSetHeadersWindowed(ctx, final.Key(), host2.ID(),
+		func(parents block.TipSetKey, _ abi.ChainEpoch) bool { return reachesGenesis(parents) },
+		func(ts block.TipSet) (bool, error) {
+			parents, err := ts.Parents()
+			return reachesGenesis(parents), err
+		})
+	require.NoError(t, err)
+
+	// The whole chain above genesis is returned in traversal order, with all
+	// the blocks of each tipset.
+	expectedTips := builder.RequireTipSets(final.Key(), tipCount)
+	require.Equal(t, len(expectedTips), len(tipsets))
+	for i, ts := range expectedTips {
+		assert.Equal(t, ts.Key(), tipsets[i].Key())
+	}
+
+	// The chain is split by height across both peers.
+	scorer.mu.Lock()
+	defer scorer.mu.Unlock()
+	assert.NotZero(t, scorer.good[host2.ID()])
+	assert.NotZero(t, scorer.good[host3.ID()])
+}
+
+func TestRealWorldFetchHeadersWindowedByHeightRange(t *testing.T) {
+	tf.IntegrationTest(t)
+	ctx := context.Background()
+	// setup a chain of tipsets of two blocks, longer than a window
+	builder := chain.NewBuilder(t, address.Undef)
+	gen := builder.NewGenesis()
+	tipCount := 150
+	final := gen
+	for i := 0; i < tipCount; i++ {
+		final = builder.BuildOn(final, 2, nil)
+	}
+
+	// setup a network of the fetching peer and two peers advertising the head
+	// and serving header ranges
+	mn := mocknet.New(ctx)
+	host1, err := mn.GenPeer()
+	require.NoError(t, err)
+	host2, err := mn.GenPeer()
+	require.NoError(t, err)
+	host3, err := mn.GenPeer()
+	require.NoError(t, err)
+	require.NoError(t, mn.LinkAll())
+	exchange.NewHeaderRangeHandler(host2, builder).Register()
+	exchange.NewHeaderRangeHandler(host3, builder).Register()
+
+	bs := bstore.NewBlockstore(dss.MutexWrap(datastore.NewMapDatastore()))
+	bv := th.NewFakeBlockValidator()
+	fc := th.NewFakeClock(time.Now())
+	height, err := final.Height()
+	require.NoError(t, err)
+	pt := discovery.NewPeerTracker(host1.ID(), nil)
+	pt.Track(block.NewChainInfo(host2.ID(), host2.ID(), final.Key(), height))
+	pt.Track(block.NewChainInfo(host3.ID(), host3.ID(), final.Key(), height))
+
+	// No graphsync responder: the chain is fetched only by height range.
+	localGraphsync := graphsync.New(ctx, gsnet.NewFromLibp2pHost(host1), ipldbridge.NewIPLDBridge(), gsstoreutil.LoaderForBlockstore(bs), gsstoreutil.StorerForBlockstore(bs))
+	scorer := &fetchCountingScorer{good: make(map[peer.ID]int)}
+	fetcher := fetcher.NewGraphSyncFetcher(ctx, localGraphsync, bs, bv, fc, pt, scorer, exchange.NewHeaderRangeClient(host1))
+
+	reachesGenesis := func(parents block.TipSetKey) bool { return parents.Equals(gen.Key()) }
+	tipsets, err := fetcher.FetchTipSetHeadersWindowed(ctx, final.Key(), host2.ID(),
+		func(parents block.TipSetKey, _ abi.ChainEpoch) bool { return reachesGenesis(parents) },
+		func(ts block.TipSet) (bool, error) {
+			parents, err := ts.Parents()
+			return reachesGenesis(parents), err
+		})
+	require.NoError(t, err)
+
+	// The whole chain above genesis is returned in traversal order and its
+	// headers are stored.
+	expectedTips := builder.RequireTipSets(final.Key(), tipCount)
+	require.Equal(t, len(expectedTips), len(tipsets))
+	for i, ts := range expectedTips {
+		assert.Equal(t, ts.Key(), tipsets[i].Key())
+		for j := 0; j < ts.Len(); j++ {
+			stored, err := bs.Has(ts.At(j).Cid())
+			require.NoError(t, err)
+			assert.True(t, stored)
+		}
+	}
+
+	// The windows of heights are split across both peers.
+	scorer.mu.Lock()
+	defer scorer.mu.Unlock()
+	assert.NotZero(t, scorer.good[host2.ID()])
+	assert.NotZero(t, scorer.good[host3.ID()])
+}
+
+// fetchCountingScorer counts the good fetches of each peer.
+type fetchCountingScorer struct {
+	mu   sync.Mutex
+	good map[peer.ID]int
+}
+
+func (s *fetchCountingScorer) RecordFailedFetch(peer.ID) {}
+
+func (s *fetchCountingScorer) RecordSlowFetch(peer.ID) {}
+
+func (s *fetchCountingScorer) RecordGoodFetch(p peer.ID) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.good[p]++
+}
@@ -402,6 +402,10 @@ func (mv mockSyntaxValidator) ValidateUnsignedMessagesSyntax(ctx context.Context
 	return nil
 }
 
+func (mv mockSyntaxValidator) ValidateMessagesGasLimit(ctx context.Context, secpMessages []*types.SignedMessage, blsMessages []*types.UnsignedMessage) error {
+	return nil
+}
+
 func (mv mockSyntaxValidator) ValidateReceiptsSyntax(ctx context.Context, receipts []vm.MessageReceipt) error {
 	return mv.validateReceiptsError
 }
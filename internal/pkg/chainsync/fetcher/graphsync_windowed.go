@@ -0,0 +1,520 @@
+package fetcher
+
+import (
+	"context"
+	"sync"
+
+	"github.com/filecoin-project/specs-actors/actors/abi"
+	"github.com/ipfs/go-cid"
+	"github.com/ipld/go-ipld-prime"
+	ipldselector "github.com/ipld/go-ipld-prime/traversal/selector"
+	"github.com/libp2p/go-libp2p-core/peer"
+	"github.com/pkg/errors"
+	"go.opencensus.io/tag"
+
+	"github.com/filecoin-project/go-filecoin/internal/pkg/block"
+	"github.com/filecoin-project/go-filecoin/internal/pkg/metrics"
+)
+
+// headerWindowSize is the number of heights of headers fetched from a single
+// peer at once when fetching a chain in windows.
+const headerWindowSize = 64
+
+var peerTagKey = tag.MustNewKey("peer")
+
+var fetchHeaderWindowTimer = metrics.NewTimerMs("chainsync/fetch_header_window", "Duration of fetching a window of tipset headers in milliseconds", peerTagKey)
+var fetchedHeadersCt = metrics.NewInt64Counter("chainsync/fetched_headers", "Number of block headers fetched in windows", peerTagKey)
+var fetchedHeaderBytesCt = metrics.NewInt64Counter("chainsync/fetched_header_bytes", "Number of bytes of block headers fetched in windows", peerTagKey)
+
+// FetchTipSetHeadersWindowed behaves as FetchTipSetHeaders but fetches a long
+// chain faster, concurrently from all the peers that advertised `tsKey`.
+//
+// The chain is split by height into windows of headerWindowSize heights below
+// the advertised height of `tsKey`, which are fetched by height range with the
+// header range protocol, the i-th window from the top first from the i-th peer
+// and from the next peer if a peer fails to serve it. At most one window per
+// peer is fetched ahead of the windows already stitched together, after
+// verifying that each tipset links to the next one as its parent, until
+// `stop` returns true for the parents of a tipset.
+//
+// If no header range exchange is configured or fetching by height range
+// fails, the chain of first parent blocks is walked down from `tsKey`
+// instead, learning the keys of the tipsets of the chain a segment at a time,
+// each segment from the next peer, while windows of the keys already learned
+// are fetched concurrently from different peers.
+//
+// Either way the chain is cut at the first tipset for which `done` returns
+// true. It falls back to FetchTipSetHeaders when no peer advertised `tsKey` or
+// the chain originates from this node.
+func (gsf *GraphSyncFetcher) FetchTipSetHeadersWindowed(ctx context.Context, tsKey block.TipSetKey, originatingPeer peer.ID, stop func(parents block.TipSetKey, height abi.ChainEpoch) bool, done func(block.TipSet) (bool, error)) ([]block.TipSet, error) {
+	peers, height := gsf.advertisingPeers(tsKey)
+	if len(peers) == 0 || originatingPeer == gsf.peerTracker.Self() || tsKey.Len() == 0 {
+		return gsf.FetchTipSetHeaders(ctx, tsKey, originatingPeer, done)
+	}
+
+	if gsf.headerRange != nil {
+		out, err := gsf.fetchHeaderRanges(ctx, tsKey, height, peers, stop)
+		if err == nil {
+			return cutChain(tsKey, out, done)
+		}
+		if ctx.Err() != nil {
+			return nil, ctx.Err()
+		}
+		logGraphsyncFetcher.Infof("fetching chain from %s by height range failed, walking it instead: %s", tsKey, err)
+	}
+
+	out, err := gsf.fetchWalkedWindows(ctx, tsKey, peers, stop)
+	if err != nil {
+		return nil, err
+	}
+	return cutChain(tsKey, out, done)
+}
+
+// fetchHeaderRanges fetches the chain down from `tsKey`, at height `top`, in
+// windows of heights from `peers` and stitches them together. The headers are
+// put in the store once they link to the chain above them.
+func (gsf *GraphSyncFetcher) fetchHeaderRanges(ctx context.Context, tsKey block.TipSetKey, top abi.ChainEpoch, peers []peer.ID, stop func(block.TipSetKey, abi.ChainEpoch) bool) ([]block.TipSet, error) {
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	type window struct {
+		index   int
+		tipsets []block.TipSet
+		err     error
+	}
+	// Every window fetched but not stitched yet holds one slot of the
+	// results channel, so fetching goroutines never block.
+	results := make(chan window, len(peers))
+	fetched := make(map[int]window)
+	next, outstanding := 0, 0
+	// The windows are numbered from the top, the last one reaching height 0.
+	last := int(top) / headerWindowSize
+	fetchNext := func() {
+		i := next
+		next++
+		outstanding++
+		maxHeight := top - abi.ChainEpoch(i*headerWindowSize)
+		minHeight := maxHeight - headerWindowSize + 1
+		if minHeight < 0 {
+			minHeight = 0
+		}
+		go func() {
+			tipsets, err := gsf.fetchHeaderRange(ctx, tsKey, maxHeight, minHeight, peers, i)
+			results <- window{index: i, tipsets: tipsets, err: err}
+		}()
+	}
+
+	var out []block.TipSet
+	expected := tsKey
+	for i := 0; i <= last; i++ {
+		for outstanding < len(peers) && next <= last {
+			fetchNext()
+		}
+		w, ok := fetched[i]
+		for !ok {
+			select {
+			case r := <-results:
+				fetched[r.index] = r
+			case <-ctx.Done():
+				return nil, ctx.Err()
+			}
+			w, ok = fetched[i]
+		}
+		delete(fetched, i)
+		outstanding--
+		if w.err != nil {
+			return nil, w.err
+		}
+
+		for _, ts := range w.tipsets {
+			if !ts.Key().Equals(expected) {
+				return nil, errors.Errorf("fetched tipset %s where parent %s was expected", ts.Key(), expected)
+			}
+			for j := 0; j < ts.Len(); j++ {
+				if err := gsf.store.Put(ts.At(j).ToNode()); err != nil {
+					return nil, err
+				}
+			}
+			out = append(out, ts)
+
+			parents, err := ts.Parents()
+			if err != nil {
+				return nil, err
+			}
+			height, err := ts.Height()
+			if err != nil {
+				return nil, err
+			}
+			if parents.Empty() || stop(parents, height) {
+				logGraphsyncFetcher.Infof("fetched %d tipsets from %s in %d windows from %d peers", len(out), tsKey, i+1, len(peers))
+				return out, nil
+			}
+			expected = parents
+		}
+	}
+	return nil, errors.Errorf("fetched chain from %s ended at %s before reaching height 0", tsKey, expected)
+}
+
+// fetchHeaderRange fetches the tipsets of the chain of `tsKey` with heights
+// between `minHeight` and `maxHeight`, trying the peers in turn from the one at
+// index `first`. The tipsets returned are syntactically valid and link to each
+// other, but are not checked to link to the chain above them.
+func (gsf *GraphSyncFetcher) fetchHeaderRange(ctx context.Context, tsKey block.TipSetKey, maxHeight, minHeight abi.ChainEpoch, peers []peer.ID, first int) ([]block.TipSet, error) {
+	for attempt := 0; attempt < len(peers); attempt++ {
+		p := peers[(first+attempt)%len(peers)]
+		tipsets, err := gsf.tryHeaderRange(ctx, tsKey, maxHeight, minHeight, p)
+		if err == nil {
+			return tipsets, nil
+		}
+		if ctx.Err() != nil {
+			return nil, ctx.Err()
+		}
+		logGraphsyncFetcher.Infof("failed to fetch heights %d to %d from peer %s, trying new peer: %s", minHeight, maxHeight, p, err)
+	}
+	return nil, errors.Errorf("fetching tipset window: no peer served heights %d to %d", minHeight, maxHeight)
+}
+
+// tryHeaderRange fetches the tipsets of the chain of `tsKey` with heights
+// between `minHeight` and `maxHeight` from peer `p`.
+func (gsf *GraphSyncFetcher) tryHeaderRange(ctx context.Context, tsKey block.TipSetKey, maxHeight, minHeight abi.ChainEpoch, p peer.ID) ([]block.TipSet, error) {
+	peerCtx, err := tag.New(ctx, tag.Upsert(peerTagKey, p.Pretty()))
+	if err != nil {
+		return nil, err
+	}
+	stopwatch := fetchHeaderWindowTimer.Start(peerCtx)
+
+	tipsets, n, err := gsf.headerRange.FetchHeaderRange(ctx, p, tsKey, maxHeight, minHeight)
+	fetchedHeaderBytesCt.Inc(peerCtx, int64(n))
+	if err == nil {
+		err = gsf.verifyHeaderRange(ctx, tipsets)
+	}
+	gsf.recordFetch(ctx, p, err)
+	if err != nil {
+		return nil, err
+	}
+
+	headers := 0
+	for _, ts := range tipsets {
+		headers += ts.Len()
+	}
+	elapsed := stopwatch.Stop(peerCtx)
+	fetchedHeadersCt.Inc(peerCtx, int64(headers))
+	logGraphsyncFetcher.Debugf("fetched %d tipsets at heights %d to %d from peer %s in %s", len(tipsets), minHeight, maxHeight, p, elapsed)
+	return tipsets, nil
+}
+
+// verifyHeaderRange checks the syntax of the blocks of `tipsets` and that each
+// tipset links to the next one as its parent.
+func (gsf *GraphSyncFetcher) verifyHeaderRange(ctx context.Context, tipsets []block.TipSet) error {
+	for i, ts := range tipsets {
+		for j := 0; j < ts.Len(); j++ {
+			if err := gsf.validator.ValidateSyntax(ctx, ts.At(j)); err != nil {
+				return errors.Wrapf(err, "invalid block %s", ts.At(j).Cid())
+			}
+		}
+		if i+1 < len(tipsets) {
+			if err := requireLink(ts, tipsets[i+1]); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// fetchWalkedWindows fetches the chain down from `tsKey` in windows of the
+// keys learned by walking the chain of first parent blocks with fetchSkeleton.
+func (gsf *GraphSyncFetcher) fetchWalkedWindows(ctx context.Context, tsKey block.TipSetKey, peers []peer.ID, stop func(block.TipSetKey, abi.ChainEpoch) bool) ([]block.TipSet, error) {
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	segments := make(chan []block.TipSetKey)
+	skeletonErr := make(chan error, 1)
+	go func() {
+		defer close(segments)
+		skeletonErr <- gsf.fetchSkeleton(ctx, tsKey, peers, stop, segments)
+	}()
+
+	// Fetch the windows as their keys are learned, at most one per peer at
+	// a time. The i-th window from the top is first asked of the i-th peer.
+	type window struct {
+		tipsets []block.TipSet
+		err     error
+	}
+	var windows []*window
+	sem := make(chan struct{}, len(peers))
+	var wg sync.WaitGroup
+	fetchWindow := func(keys []block.TipSetKey) {
+		w := &window{}
+		i := len(windows)
+		windows = append(windows, w)
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			select {
+			case sem <- struct{}{}:
+			case <-ctx.Done():
+				w.err = ctx.Err()
+				return
+			}
+			defer func() { <-sem }()
+			w.tipsets, w.err = gsf.fetchHeaderWindow(ctx, keys, peers, i)
+			if w.err != nil {
+				cancel()
+			}
+		}()
+	}
+
+	var pending []block.TipSetKey
+	fetched := 0
+	for segment := range segments {
+		pending = append(pending, segment...)
+		for len(pending) >= headerWindowSize {
+			fetchWindow(pending[:headerWindowSize])
+			pending = pending[headerWindowSize:]
+			fetched += headerWindowSize
+		}
+	}
+	if len(pending) > 0 {
+		fetchWindow(pending)
+		fetched += len(pending)
+	}
+	err := <-skeletonErr
+	if err != nil {
+		cancel()
+	}
+	wg.Wait()
+	// A failed window cancels the walk, so report the failure itself.
+	for _, w := range windows {
+		if w.err != nil && errors.Cause(w.err) != context.Canceled {
+			return nil, w.err
+		}
+	}
+	if err != nil {
+		return nil, err
+	}
+	logGraphsyncFetcher.Infof("fetched %d tipsets from %s in %d windows from %d peers", fetched, tsKey, len(windows), len(peers))
+
+	var out []block.TipSet
+	for _, w := range windows {
+		if w.err != nil {
+			return nil, w.err
+		}
+		out = append(out, w.tipsets...)
+	}
+	for i := 0; i+1 < len(out); i++ {
+		if err := requireLink(out[i], out[i+1]); err != nil {
+			return nil, err
+		}
+	}
+	return out, nil
+}
+
+// cutChain cuts the chain `out` fetched from `tsKey` at the first tipset for
+// which `done` returns true.
+func cutChain(tsKey block.TipSetKey, out []block.TipSet, done func(block.TipSet) (bool, error)) ([]block.TipSet, error) {
+	for i, ts := range out {
+		isDone, err := done(ts)
+		if err != nil {
+			return nil, err
+		}
+		if isDone {
+			return out[:i+1], nil
+		}
+	}
+	return nil, errors.Errorf("fetched chain from %s ended before reaching a known tipset", tsKey)
+}
+
+// requireLink returns an error unless `parent` is the parent of `ts`.
+func requireLink(ts, parent block.TipSet) error {
+	parents, err := ts.Parents()
+	if err != nil {
+		return err
+	}
+	if !parents.Equals(parent.Key()) {
+		return errors.Errorf("tipset %s does not link to parent %s", ts.Key(), parent.Key())
+	}
+	return nil
+}
+
+// advertisingPeers returns the peers whose head is `tsKey`, best first, and
+// the lowest height they advertised for it.
+func (gsf *GraphSyncFetcher) advertisingPeers(tsKey block.TipSetKey) ([]peer.ID, abi.ChainEpoch) {
+	var peers []peer.ID
+	var height abi.ChainEpoch
+	for _, ci := range gsf.peerTracker.List() {
+		if ci.Head.Equals(tsKey) && ci.Sender != gsf.peerTracker.Self() {
+			if len(peers) == 0 || ci.Height < height {
+				height = ci.Height
+			}
+			peers = append(peers, ci.Sender)
+		}
+	}
+	return peers, height
+}
+
+// fetchSkeleton walks the chain of first parent blocks down from `tsKey`,
+// sending the keys of the tipsets on the way to `out`, highest first, up to
+// the first tipset for which `stop` returns true for its parents. The blocks
+// not stored are fetched in segments of maxRecursionDepth levels, each segment
+// from the next peer, and the keys walked so far are sent before each fetch.
+func (gsf *GraphSyncFetcher) fetchSkeleton(ctx context.Context, tsKey block.TipSetKey, peers []peer.ID, stop func(block.TipSetKey, abi.ChainEpoch) bool, out chan<- []block.TipSetKey) error {
+	var keys []block.TipSetKey
+	flush := func() error {
+		if len(keys) == 0 {
+			return nil
+		}
+		select {
+		case out <- keys:
+			keys = nil
+			return nil
+		case <-ctx.Done():
+			return ctx.Err()
+		}
+	}
+
+	key := tsKey
+	segment, attempts := 0, 0
+	for {
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+		first := key.ToSlice()[0]
+		blk, err := gsf.loadHeader(ctx, first)
+		if err != nil {
+			return err
+		}
+		if blk == nil {
+			if attempts == len(peers) {
+				return errors.Errorf("fetching chain skeleton: no peer served block %s", first)
+			}
+			if err := flush(); err != nil {
+				return err
+			}
+			p := peers[(segment+attempts)%len(peers)]
+			attempts++
+			logGraphsyncFetcher.Infof("fetching chain skeleton from block %s, peer %s, %d levels", first, p, maxRecursionDepth)
+			err := gsf.fetchBlocksRecursively(ctx, gsf.recFirstParentSel, first, p, maxRecursionDepth)
+			gsf.recordFetch(ctx, p, err)
+			if err != nil {
+				logGraphsyncFetcher.Infof("request failed, trying another peer: %s", err)
+			}
+			continue
+		}
+		if attempts > 0 {
+			segment++
+			attempts = 0
+		}
+
+		keys = append(keys, key)
+		if blk.Parents.Empty() || stop(blk.Parents, blk.Height) {
+			return flush()
+		}
+		key = blk.Parents
+	}
+}
+
+// fetchHeaderWindow fetches the headers of the tipsets with keys `keys`,
+// trying the peers in turn from the one at index `first`.
+func (gsf *GraphSyncFetcher) fetchHeaderWindow(ctx context.Context, keys []block.TipSetKey, peers []peer.ID, first int) ([]block.TipSet, error) {
+	for attempt := 0; attempt < len(peers); attempt++ {
+		p := peers[(first+attempt)%len(peers)]
+		tipsets, err := gsf.tryHeaderWindow(ctx, keys, p)
+		if err != nil {
+			return nil, err
+		}
+		if tipsets != nil {
+			return tipsets, nil
+		}
+		logGraphsyncFetcher.Infof("incomplete fetch for window at tipset %s, trying new peer", keys[0])
+	}
+	return nil, errors.Errorf("fetching tipset window: no peer served tipset %s", keys[0])
+}
+
+// tryHeaderWindow fetches the headers of the tipsets with keys `keys` from
+// peer `p`. It returns nil tipsets if the peer did not serve all of them.
+func (gsf *GraphSyncFetcher) tryHeaderWindow(ctx context.Context, keys []block.TipSetKey, p peer.ID) ([]block.TipSet, error) {
+	peerCtx, err := tag.New(ctx, tag.Upsert(peerTagKey, p.Pretty()))
+	if err != nil {
+		return nil, err
+	}
+	stopwatch := fetchHeaderWindowTimer.Start(peerCtx)
+
+	var missing []cid.Cid
+	for _, key := range keys {
+		for it := key.Iter(); !it.Complete(); it.Next() {
+			has, err := gsf.store.Has(it.Value())
+			if err != nil {
+				return nil, err
+			}
+			if !has {
+				missing = append(missing, it.Value())
+			}
+		}
+	}
+	if len(missing) > 0 {
+		err := gsf.fetchBlocks(ctx, gsf.headerSel, missing, p)
+		gsf.recordFetch(ctx, p, err)
+		if err != nil {
+			logGraphsyncFetcher.Infof("request failed: %s", err)
+		}
+	}
+
+	tipsets := make([]block.TipSet, len(keys))
+	for i, key := range keys {
+		ts, incomplete, err := gsf.loadAndVerifyHeader(ctx, key)
+		if err != nil {
+			return nil, err
+		}
+		if len(incomplete) > 0 {
+			return nil, nil
+		}
+		tipsets[i] = ts
+	}
+
+	bytes := 0
+	for _, c := range missing {
+		size, err := gsf.store.GetSize(c)
+		if err != nil {
+			return nil, err
+		}
+		bytes += size
+	}
+	elapsed := stopwatch.Stop(peerCtx)
+	fetchedHeadersCt.Inc(peerCtx, int64(len(missing)))
+	fetchedHeaderBytesCt.Inc(peerCtx, int64(bytes))
+	logGraphsyncFetcher.Debugf("fetched window of %d tipsets at %s from peer %s in %s", len(tipsets), keys[0], p, elapsed)
+	return tipsets, nil
+}
+
+// loadHeader loads and validates a block header from the store, returning nil
+// if it is not stored.
+func (gsf *GraphSyncFetcher) loadHeader(ctx context.Context, c cid.Cid) (*block.Block, error) {
+	has, err := gsf.store.Has(c)
+	if err != nil || !has {
+		return nil, err
+	}
+	rawBlock, err := gsf.store.Get(c)
+	if err != nil {
+		return nil, err
+	}
+	blk, err := block.DecodeBlock(rawBlock.RawData())
+	if err != nil {
+		return nil, errors.Wrapf(err, "fetched data (cid %s) was not a block", c)
+	}
+	if err := gsf.validator.ValidateSyntax(ctx, blk); err != nil {
+		return nil, errors.Wrapf(err, "invalid block %s", c)
+	}
+	return blk, nil
+}
+
+// recFirstParentSel generates a selector for a chain of block headers
+// following only the first parent of each block.
+func (gsf *GraphSyncFetcher) recFirstParentSel(recursionDepth int) ipld.Node {
+	return gsf.ssb.ExploreRecursive(ipldselector.RecursionLimitDepth(recursionDepth),
+		gsf.ssb.ExploreUnion(
+			gsf.ssb.Matcher(),
+			gsf.ssb.ExploreIndex(block.IndexParentsField,
+				gsf.ssb.ExploreIndex(0, gsf.ssb.ExploreRecursiveEdge()),
+			),
+		)).Node()
+}
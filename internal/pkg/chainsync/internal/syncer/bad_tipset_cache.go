@@ -1,42 +1,214 @@
 package syncer
 
 import (
+	"container/list"
+	"sort"
+	"strings"
 	"sync"
 
+	"github.com/ipfs/go-datastore"
+	"github.com/ipfs/go-datastore/query"
+	"github.com/libp2p/go-libp2p-core/peer"
+	"github.com/pkg/errors"
+
 	"github.com/filecoin-project/go-filecoin/internal/pkg/block"
+	"github.com/filecoin-project/go-filecoin/internal/pkg/encoding"
+	"github.com/filecoin-project/go-filecoin/internal/pkg/repo"
 )
 
+// badTipSetPrefix is the datastore namespace under which bad tipsets are written.
+const badTipSetPrefix = "/chainsync/badtipsets/"
+
+// BadTipSet records a tipset that failed validation.
+type BadTipSet struct {
+	_ struct{} `cbor:",toarray"`
+	// Key is the key of the rejected tipset.
+	Key block.TipSetKey
+	// Reason is the validation error that caused the rejection.
+	Reason string
+	// Sender is the peer that sent the chain including the tipset, empty if
+	// unknown.
+	Sender string
+	// Seq orders the entries by insertion when they are loaded again.
+	Seq uint64
+}
+
 // BadTipSetCache keeps track of bad tipsets that the syncer should not try to
 // download. Readers and writers grab a lock. The purpose of this cache is to
 // prevent a node from having to repeatedly invalidate a block (and its children)
-// in the event that the tipset does not conform to the rules of consensus. Note
-// that the cache is only in-memory, so it is reset whenever the node is restarted.
-// TODO: this needs to be limited.
+// in the event that the tipset does not conform to the rules of consensus.
+//
+// The cache holds a bounded number of tipsets, evicting the least recently
+// used when full. Entries are written to a datastore, when there is one, so
+// that they survive restarts.
 type BadTipSetCache struct {
-	mu  sync.Mutex
-	bad map[string]struct{}
+	mu      sync.Mutex
+	ds      repo.Datastore
+	size    int
+	seq     uint64
+	order   *list.List // of *BadTipSet, most recently used first
+	entries map[string]*list.Element
+}
+
+// NewBadTipSetCache creates a cache of at most `size` tipsets, loading the
+// entries previously written to `ds`. A nil datastore keeps the cache in memory.
+func NewBadTipSetCache(ds repo.Datastore, size int) (*BadTipSetCache, error) {
+	if size <= 0 {
+		return nil, errors.Errorf("bad tipset cache size must be positive, got %d", size)
+	}
+	cache := &BadTipSetCache{
+		ds:      ds,
+		size:    size,
+		order:   list.New(),
+		entries: make(map[string]*list.Element),
+	}
+	if ds == nil {
+		return cache, nil
+	}
+
+	loaded, err := loadBadTipSets(ds)
+	if err != nil {
+		return nil, err
+	}
+	for _, bad := range loaded {
+		cache.seq = bad.Seq
+		cache.entries[bad.Key.String()] = cache.order.PushFront(bad)
+	}
+	// The configured size may have shrunk since the entries were written.
+	for cache.order.Len() > cache.size {
+		if err := cache.evictOldest(); err != nil {
+			return nil, err
+		}
+	}
+	return cache, nil
 }
 
-// AddChain adds the chain of tipsets to the BadTipSetCache.  For now it just
-// does the simplest thing and adds all blocks of the chain to the cache.
-// TODO: might want to cache a random subset once cache size is limited.
-func (cache *BadTipSetCache) AddChain(chain []block.TipSet) {
+// AddChain adds the chain of tipsets to the BadTipSetCache, recording `reason`
+// and `sender` for each of them.  For now it just does the simplest thing and
+// adds all tipsets of the chain to the cache.
+// TODO: might want to cache a random subset now that the cache size is limited.
+func (cache *BadTipSetCache) AddChain(chain []block.TipSet, reason error, sender peer.ID) {
 	for _, ts := range chain {
-		cache.Add(ts.String())
+		cache.Add(ts.Key(), reason.Error(), sender)
 	}
 }
 
-// Add adds a single tipset key to the BadTipSetCache.
-func (cache *BadTipSetCache) Add(tsKey string) {
+// Add adds a single tipset key to the BadTipSetCache. Failing to persist the
+// entry is logged, the entry is still cached in memory.
+func (cache *BadTipSetCache) Add(key block.TipSetKey, reason string, sender peer.ID) {
 	cache.mu.Lock()
 	defer cache.mu.Unlock()
-	cache.bad[tsKey] = struct{}{}
+
+	if elem, ok := cache.entries[key.String()]; ok {
+		cache.order.Remove(elem)
+		delete(cache.entries, key.String())
+	}
+	cache.seq++
+	bad := &BadTipSet{Key: key, Reason: reason, Seq: cache.seq}
+	if sender != "" {
+		bad.Sender = sender.Pretty()
+	}
+	cache.entries[key.String()] = cache.order.PushFront(bad)
+	if err := cache.put(bad); err != nil {
+		logSyncer.Errorf("failed to persist bad tipset %s: %s", key, err)
+	}
+
+	for cache.order.Len() > cache.size {
+		if err := cache.evictOldest(); err != nil {
+			logSyncer.Errorf("failed to evict bad tipset: %s", err)
+		}
+	}
 }
 
 // Has checks for membership in the BadTipSetCache.
-func (cache *BadTipSetCache) Has(tsKey string) bool {
+func (cache *BadTipSetCache) Has(key block.TipSetKey) bool {
 	cache.mu.Lock()
 	defer cache.mu.Unlock()
-	_, ok := cache.bad[tsKey]
+	elem, ok := cache.entries[key.String()]
+	if ok {
+		cache.order.MoveToFront(elem)
+	}
 	return ok
 }
+
+// List returns the cached bad tipsets, most recently used first.
+func (cache *BadTipSetCache) List() []*BadTipSet {
+	cache.mu.Lock()
+	defer cache.mu.Unlock()
+	out := make([]*BadTipSet, 0, cache.order.Len())
+	for elem := cache.order.Front(); elem != nil; elem = elem.Next() {
+		bad := *elem.Value.(*BadTipSet)
+		out = append(out, &bad)
+	}
+	return out
+}
+
+// Remove removes a tipset from the cache, so that it is synced again. It
+// returns false if the tipset was not in the cache.
+func (cache *BadTipSetCache) Remove(key block.TipSetKey) (bool, error) {
+	cache.mu.Lock()
+	defer cache.mu.Unlock()
+	elem, ok := cache.entries[key.String()]
+	if !ok {
+		return false, nil
+	}
+	return true, cache.remove(elem)
+}
+
+func (cache *BadTipSetCache) evictOldest() error {
+	return cache.remove(cache.order.Back())
+}
+
+func (cache *BadTipSetCache) remove(elem *list.Element) error {
+	bad := cache.order.Remove(elem).(*BadTipSet)
+	delete(cache.entries, bad.Key.String())
+	if cache.ds == nil {
+		return nil
+	}
+	if err := cache.ds.Delete(badTipSetKey(bad.Key)); err != nil {
+		return errors.Wrapf(err, "failed to delete bad tipset %s", bad.Key)
+	}
+	return nil
+}
+
+func (cache *BadTipSetCache) put(bad *BadTipSet) error {
+	if cache.ds == nil {
+		return nil
+	}
+	bb, err := encoding.Encode(bad)
+	if err != nil {
+		return err
+	}
+	return cache.ds.Put(badTipSetKey(bad.Key), bb)
+}
+
+// loadBadTipSets reads the bad tipsets written to `ds`, in insertion order.
+func loadBadTipSets(ds repo.Datastore) ([]*BadTipSet, error) {
+	results, err := ds.Query(query.Query{Prefix: badTipSetPrefix})
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to query bad tipsets")
+	}
+	defer func() { _ = results.Close() }()
+
+	var loaded []*BadTipSet
+	for res := range results.Next() {
+		if res.Error != nil {
+			return nil, errors.Wrap(res.Error, "failed to read bad tipsets")
+		}
+		var bad BadTipSet
+		if err := encoding.Decode(res.Value, &bad); err != nil {
+			return nil, errors.Wrapf(err, "failed to decode bad tipset at %s", res.Key)
+		}
+		loaded = append(loaded, &bad)
+	}
+	sort.Slice(loaded, func(i, j int) bool { return loaded[i].Seq < loaded[j].Seq })
+	return loaded, nil
+}
+
+func badTipSetKey(key block.TipSetKey) datastore.Key {
+	cids := make([]string, key.Len())
+	for i, c := range key.ToSlice() {
+		cids[i] = c.String()
+	}
+	return datastore.NewKey(badTipSetPrefix + strings.Join(cids, "-"))
+}
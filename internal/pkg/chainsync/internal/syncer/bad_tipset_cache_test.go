@@ -0,0 +1,92 @@
+package syncer_test
+
+import (
+	"testing"
+
+	"github.com/filecoin-project/go-address"
+	"github.com/libp2p/go-libp2p-core/peer"
+	"github.com/pkg/errors"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/filecoin-project/go-filecoin/internal/pkg/block"
+	"github.com/filecoin-project/go-filecoin/internal/pkg/chain"
+	"github.com/filecoin-project/go-filecoin/internal/pkg/chainsync/internal/syncer"
+	"github.com/filecoin-project/go-filecoin/internal/pkg/repo"
+	tf "github.com/filecoin-project/go-filecoin/internal/pkg/testhelpers/testflags"
+)
+
+func TestBadTipSetCache(t *testing.T) {
+	tf.UnitTest(t)
+
+	builder := chain.NewBuilder(t, address.Undef)
+	genesis := builder.NewGenesis()
+	tipsets := []block.TipSet{genesis}
+	for i := 0; i < 3; i++ {
+		tipsets = append(tipsets, builder.AppendOn(tipsets[len(tipsets)-1], 1))
+	}
+	sender := peer.ID("sender")
+
+	t.Run("evicts the least recently used tipset", func(t *testing.T) {
+		cache, err := syncer.NewBadTipSetCache(nil, 2)
+		require.NoError(t, err)
+
+		cache.Add(tipsets[0].Key(), "first", sender)
+		cache.Add(tipsets[1].Key(), "second", sender)
+		assert.True(t, cache.Has(tipsets[0].Key()))
+		cache.Add(tipsets[2].Key(), "third", sender)
+
+		assert.True(t, cache.Has(tipsets[0].Key()))
+		assert.False(t, cache.Has(tipsets[1].Key()))
+		assert.True(t, cache.Has(tipsets[2].Key()))
+	})
+
+	t.Run("records the reason and sender of a chain", func(t *testing.T) {
+		cache, err := syncer.NewBadTipSetCache(nil, 10)
+		require.NoError(t, err)
+
+		cache.AddChain(tipsets[1:3], errors.New("bad state"), sender)
+		bad := cache.List()
+		require.Len(t, bad, 2)
+		assert.Equal(t, tipsets[2].Key(), bad[0].Key)
+		assert.Equal(t, "bad state", bad[0].Reason)
+		assert.Equal(t, sender.Pretty(), bad[0].Sender)
+	})
+
+	t.Run("entries survive a restart", func(t *testing.T) {
+		ds := repo.NewInMemoryRepo().ChainDatastore()
+		cache, err := syncer.NewBadTipSetCache(ds, 10)
+		require.NoError(t, err)
+		for i, ts := range tipsets {
+			cache.Add(ts.Key(), "bad", peer.ID(""))
+			assert.Equal(t, uint64(i+1), cache.List()[0].Seq)
+		}
+		removed, err := cache.Remove(tipsets[0].Key())
+		require.NoError(t, err)
+		assert.True(t, removed)
+
+		reloaded, err := syncer.NewBadTipSetCache(ds, 10)
+		require.NoError(t, err)
+		assert.Equal(t, cache.List(), reloaded.List())
+		assert.False(t, reloaded.Has(tipsets[0].Key()))
+
+		// a smaller cache keeps the most recent entries
+		shrunk, err := syncer.NewBadTipSetCache(ds, 1)
+		require.NoError(t, err)
+		require.Len(t, shrunk.List(), 1)
+		assert.True(t, shrunk.Has(tipsets[3].Key()))
+	})
+
+	t.Run("removing an unknown tipset is not an error", func(t *testing.T) {
+		cache, err := syncer.NewBadTipSetCache(nil, 10)
+		require.NoError(t, err)
+		removed, err := cache.Remove(tipsets[0].Key())
+		require.NoError(t, err)
+		assert.False(t, removed)
+	})
+
+	t.Run("size must be positive", func(t *testing.T) {
+		_, err := syncer.NewBadTipSetCache(nil, 0)
+		assert.Error(t, err)
+	})
+}
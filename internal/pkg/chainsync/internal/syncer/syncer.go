@@ -17,6 +17,7 @@ import (
 	"github.com/filecoin-project/go-filecoin/internal/pkg/chain"
 	"github.com/filecoin-project/go-filecoin/internal/pkg/chainsync/status"
 	"github.com/filecoin-project/go-filecoin/internal/pkg/clock"
+	"github.com/filecoin-project/go-filecoin/internal/pkg/consensus"
 	"github.com/filecoin-project/go-filecoin/internal/pkg/metrics"
 	"github.com/filecoin-project/go-filecoin/internal/pkg/metrics/tracing"
 	"github.com/filecoin-project/go-filecoin/internal/pkg/types"
@@ -42,6 +43,8 @@ type Syncer struct {
 	fetcher Fetcher
 	// BadTipSetCache is used to filter out collections of invalid blocks.
 	badTipSets *BadTipSetCache
+	// scorer is told about peers sending invalid chains, may be nil.
+	scorer PeerScorer
 
 	// Evaluates tipset messages and stores the resulting states.
 	fullValidator FullBlockValidator
@@ -77,17 +80,29 @@ type Fetcher interface {
 	FetchTipSetHeaders(context.Context, block.TipSetKey, peer.ID, func(block.TipSet) (bool, error)) ([]block.TipSet, error)
 }
 
+// windowedFetcher is a Fetcher that can fetch a long chain of headers
+// concurrently from several peers.
+type windowedFetcher interface {
+	// FetchTipSetHeadersWindowed behaves as FetchTipSetHeaders. It fetches
+	// the chain down until `stop` returns true for the parents and height of
+	// a tipset.
+	FetchTipSetHeadersWindowed(ctx context.Context, tsKey block.TipSetKey, originatingPeer peer.ID, stop func(parents block.TipSetKey, height abi.ChainEpoch) bool, done func(block.TipSet) (bool, error)) ([]block.TipSet, error)
+}
+
 // ChainReaderWriter reads and writes the chain store.
 type ChainReaderWriter interface {
 	GetHead() block.TipSetKey
+	GetCheckpoint() block.TipSetKey
 	GetTipSet(tsKey block.TipSetKey) (block.TipSet, error)
 	GetTipSetStateRoot(tsKey block.TipSetKey) (cid.Cid, error)
 	GetTipSetReceiptsRoot(tsKey block.TipSetKey) (cid.Cid, error)
 	HasTipSetAndState(ctx context.Context, tsKey block.TipSetKey) bool
+	HasState(ctx context.Context, root cid.Cid) bool
 	PutTipSetMetadata(ctx context.Context, tsas *chain.TipSetMetadata) error
 	SetHead(ctx context.Context, ts block.TipSet) error
 	HasTipSetAndStatesWithParentsAndHeight(pTsKey block.TipSetKey, h abi.ChainEpoch) bool
 	GetTipSetAndStatesByParentsAndHeight(pTsKey block.TipSetKey, h abi.ChainEpoch) ([]*chain.TipSetMetadata, error)
+	SyncLock() func()
 }
 
 type messageStore interface {
@@ -125,6 +140,11 @@ type faultDetector interface {
 	CheckBlock(b *block.Block, p block.TipSet) error
 }
 
+// PeerScorer is told about peers that sent chains failing validation.
+type PeerScorer interface {
+	RecordInvalidBlock(p peer.ID)
+}
+
 var reorgCnt *metrics.Int64Counter
 
 func init() {
@@ -136,6 +156,12 @@ var (
 	ErrChainHasBadTipSet = errors.New("input chain contains a cached bad tipset")
 	// ErrNewChainTooLong is returned when processing a fork that split off from the main chain too many blocks ago.
 	ErrNewChainTooLong = errors.New("input chain forked from best chain past finality limit")
+	// ErrForkPastCheckpoint is returned when processing a chain that passes the height of the checkpoint without including it.
+	ErrForkPastCheckpoint = errors.New("input chain forked from the checkpoint tipset")
+	// ErrCheckpointNotSynced is returned when processing a chain that does not include the checkpoint before it is synced.
+	ErrCheckpointNotSynced = errors.New("input chain does not include the checkpoint tipset, which is not synced")
+	// ErrCheckpointStateMissing is returned when processing a chain through the checkpoint before its state is imported.
+	ErrCheckpointStateMissing = errors.New("the state of the checkpoint tipset is not in the store, import a chain snapshot including it")
 	// ErrUnexpectedStoreState indicates that the syncer's chain store is violating expected invariants.
 	ErrUnexpectedStoreState = errors.New("the chain store is in an unexpected state")
 )
@@ -148,14 +174,18 @@ func init() {
 
 var logSyncer = logging.Logger("chainsync.syncer")
 
+// messageWindowSize is the number of tipsets whose messages are fetched at
+// once, while the tipsets below them are validated.
+const messageWindowSize = 16
+
 // NewSyncer constructs a Syncer ready for use.  The chain reader must have a
-// head tipset to initialize the staging field.
-func NewSyncer(fv FullBlockValidator, hv HeaderValidator, cs ChainSelector, s ChainReaderWriter, m messageStore, f Fetcher, sr status.Reporter, c clock.Clock, fd faultDetector) (*Syncer, error) {
+// head tipset to initialize the staging field. Peers sending invalid chains
+// are reported to `scorer` unless it is nil.
+func NewSyncer(fv FullBlockValidator, hv HeaderValidator, cs ChainSelector, s ChainReaderWriter, m messageStore, f Fetcher, sr status.Reporter, c clock.Clock, fd faultDetector, bad *BadTipSetCache, scorer PeerScorer) (*Syncer, error) {
 	return &Syncer{
-		fetcher: f,
-		badTipSets: &BadTipSetCache{
-			bad: make(map[string]struct{}),
-		},
+		fetcher:         f,
+		badTipSets:      bad,
+		scorer:          scorer,
 		fullValidator:   fv,
 		headerValidator: hv,
 		chainSelector:   cs,
@@ -178,14 +208,36 @@ func (syncer *Syncer) InitStaged() error {
 	return nil
 }
 
+// BadTipSets returns the cache of tipsets that failed validation.
+func (syncer *Syncer) BadTipSets() *BadTipSetCache {
+	return syncer.badTipSets
+}
+
+// rejectChain marks the chain of tipsets as bad for `reason` and reports the
+// peer that sent it. Only chains breaking the consensus rules are rejected:
+// a failure to check a chain, such as missing local state or a cancelled
+// context, leaves it to be synced again. A fork past the checkpoint is
+// rejected but not held against the peer, which may be honestly on it.
+func (syncer *Syncer) rejectChain(chain []block.TipSet, reason error, sender peer.ID) {
+	invalid := consensus.IsInvalid(reason)
+	if reason != ErrForkPastCheckpoint && !invalid {
+		return
+	}
+	syncer.badTipSets.AddChain(chain, reason, sender)
+	if invalid && syncer.scorer != nil {
+		syncer.scorer.RecordInvalidBlock(sender)
+	}
+}
+
 // SetStagedHead sets the syncer's internal staged tipset to the chain's head.
 func (syncer *Syncer) SetStagedHead(ctx context.Context) error {
 	return syncer.chainStore.SetHead(ctx, syncer.staged)
 }
 
 // fetchAndValidateHeaders fetches headers and runs semantic block validation
-// on the chain of fetched headers
-func (syncer *Syncer) fetchAndValidateHeaders(ctx context.Context, ci *block.ChainInfo) ([]block.TipSet, error) {
+// on the chain of fetched headers. When catching up the headers are fetched
+// in windows from several peers, if the fetcher supports it.
+func (syncer *Syncer) fetchAndValidateHeaders(ctx context.Context, ci *block.ChainInfo, catchup bool) ([]block.TipSet, error) {
 	head, err := syncer.chainStore.GetTipSet(syncer.chainStore.GetHead())
 	if err != nil {
 		return nil, err
@@ -194,7 +246,7 @@ func (syncer *Syncer) fetchAndValidateHeaders(ctx context.Context, ci *block.Cha
 	if err != nil {
 		return nil, err
 	}
-	headers, err := syncer.fetcher.FetchTipSetHeaders(ctx, ci.Head, ci.Sender, func(t block.TipSet) (bool, error) {
+	done := func(t block.TipSet) (bool, error) {
 		h, err := t.Height()
 		if err != nil {
 			return true, err
@@ -203,12 +255,26 @@ func (syncer *Syncer) fetchAndValidateHeaders(ctx context.Context, ci *block.Cha
 			return true, ErrNewChainTooLong
 		}
 
+		if syncer.badTipSets.Has(t.Key()) {
+			return true, ErrChainHasBadTipSet
+		}
+
 		parents, err := t.Parents()
 		if err != nil {
 			return true, err
 		}
 		return syncer.chainStore.HasTipSetAndState(ctx, parents), nil
-	})
+	}
+
+	var headers []block.TipSet
+	if wf, ok := syncer.fetcher.(windowedFetcher); ok && catchup {
+		stop := func(parents block.TipSetKey, h abi.ChainEpoch) bool {
+			return h+miner.ChainFinalityish < headHeight || syncer.chainStore.HasTipSetAndState(ctx, parents)
+		}
+		headers, err = wf.FetchTipSetHeadersWindowed(ctx, ci.Head, ci.Sender, stop, done)
+	} else {
+		headers, err = syncer.fetcher.FetchTipSetHeaders(ctx, ci.Head, ci.Sender, done)
+	}
 	if err != nil {
 		return nil, err
 	}
@@ -220,9 +286,10 @@ func (syncer *Syncer) fetchAndValidateHeaders(ctx context.Context, ci *block.Cha
 		return nil, err
 	}
 	for i, ts := range headers {
-		for i := 0; i < ts.Len(); i++ {
-			err = syncer.headerValidator.ValidateSemantic(ctx, ts.At(i), parent)
+		for j := 0; j < ts.Len(); j++ {
+			err = syncer.headerValidator.ValidateSemantic(ctx, ts.At(j), parent)
 			if err != nil {
+				syncer.rejectChain(headers[i:], err, ci.Sender)
 				return nil, err
 			}
 		}
@@ -270,10 +337,14 @@ func (syncer *Syncer) syncOne(ctx context.Context, grandParent, parent, next blo
 		nextSecpMessages = append(nextSecpMessages, secpMsgs)
 	}
 
-	// Gather validated parent weight
-	parentWeight, err := syncer.calculateParentWeight(ctx, parent, grandParent)
-	if err != nil {
-		return err
+	// Gather validated parent weight. The weight of a trusted parent is that
+	// recorded by its children.
+	parentWeight := next.At(0).ParentWeight
+	if !syncer.isTrusted(parent) {
+		parentWeight, err = syncer.calculateParentWeight(ctx, parent, grandParent)
+		if err != nil {
+			return err
+		}
 	}
 
 	parentReceiptRoot, err := syncer.chainStore.GetTipSetReceiptsRoot(parent.Key())
@@ -314,6 +385,94 @@ func (syncer *Syncer) syncOne(ctx context.Context, grandParent, parent, next blo
 	return nil
 }
 
+// checkpointIndex returns the index of the checkpoint in the chain of tipsets,
+// given in height order, or -1 if the chain does not include it. A chain not
+// including the checkpoint is refused if it reaches past its height, or if the
+// checkpoint is not synced yet.
+func (syncer *Syncer) checkpointIndex(ctx context.Context, tipsets []block.TipSet) (int, error) {
+	key := syncer.chainStore.GetCheckpoint()
+	if key.Empty() {
+		return -1, nil
+	}
+	for i, ts := range tipsets {
+		if ts.Key().Equals(key) {
+			return i, nil
+		}
+	}
+	cp, err := syncer.chainStore.GetTipSet(key)
+	if err != nil {
+		return -1, ErrCheckpointNotSynced
+	}
+	cpHeight, err := cp.Height()
+	if err != nil {
+		return -1, err
+	}
+
+	// The chain includes the checkpoint iff the parent of its first tipset
+	// above the checkpoint does.
+	for i, ts := range tipsets {
+		h, err := ts.Height()
+		if err != nil {
+			return -1, err
+		}
+		if h < cpHeight {
+			continue
+		}
+		if h == cpHeight || i > 0 {
+			return -1, ErrForkPastCheckpoint
+		}
+		parent, _, err := syncer.ancestorsFromStore(ts)
+		if err != nil {
+			return -1, err
+		}
+		included, err := chain.IncludesTipSet(ctx, syncer.chainStore, parent, cp)
+		if err != nil {
+			return -1, err
+		}
+		if !included {
+			return -1, ErrForkPastCheckpoint
+		}
+		return -1, nil
+	}
+	return -1, nil
+}
+
+// isTrusted returns true if the tipset is at or below the height of the
+// checkpoint. Syncing ensures such tipsets in the store are the checkpoint or
+// its ancestors.
+func (syncer *Syncer) isTrusted(ts block.TipSet) bool {
+	key := syncer.chainStore.GetCheckpoint()
+	if key.Empty() {
+		return false
+	}
+	cp, err := syncer.chainStore.GetTipSet(key)
+	if err != nil {
+		return false
+	}
+	cpHeight, err := cp.Height()
+	if err != nil {
+		return false
+	}
+	h, err := ts.Height()
+	return err == nil && h <= cpHeight
+}
+
+// putTrusted adds a tipset at or below the checkpoint to the store without
+// running its state transition. Its state root and receipts are those
+// recorded by the headers of its child.
+func (syncer *Syncer) putTrusted(ctx context.Context, ts, child block.TipSet) error {
+	err := syncer.chainStore.PutTipSetMetadata(ctx, &chain.TipSetMetadata{
+		TipSet:          ts,
+		TipSetStateRoot: child.At(0).StateRoot.Cid,
+		TipSetReceipts:  child.At(0).MessageReceipts.Cid,
+	})
+	if err != nil {
+		return err
+	}
+	logSyncer.Debugf("Updated store with trusted %s", ts.String())
+	return nil
+}
+
 // TODO #3537 this should be stored the first time it is computed and retrieved
 // from disk just like aggregate state roots.
 func (syncer *Syncer) calculateParentWeight(ctx context.Context, parent, grandParent block.TipSet) (fbig.Int, error) {
@@ -447,7 +606,12 @@ func (syncer *Syncer) widen(ctx context.Context, ts block.TipSet) (block.TipSet,
 // HandleNewTipSet validates and syncs the chain rooted at the provided tipset
 // to a chain store.  Iff catchup is false then the syncer will set the head.
 func (syncer *Syncer) HandleNewTipSet(ctx context.Context, ci *block.ChainInfo, catchup bool) error {
-	err := syncer.handleNewTipSet(ctx, ci)
+	// Fetched blocks are not reachable from the store until they are added,
+	// so hold off garbage collection until then.
+	unlock := syncer.chainStore.SyncLock()
+	defer unlock()
+
+	err := syncer.handleNewTipSet(ctx, ci, catchup)
 	if err != nil {
 		return err
 	}
@@ -457,7 +621,7 @@ func (syncer *Syncer) HandleNewTipSet(ctx context.Context, ci *block.ChainInfo,
 	return syncer.SetStagedHead(ctx)
 }
 
-func (syncer *Syncer) handleNewTipSet(ctx context.Context, ci *block.ChainInfo) (err error) {
+func (syncer *Syncer) handleNewTipSet(ctx context.Context, ci *block.ChainInfo, catchup bool) (err error) {
 	// handleNewTipSet extends the Syncer's chain store with the given tipset if
 	// the chain is a valid extension.  It stages new heaviest tipsets for later
 	// setting the chain head
@@ -470,39 +634,45 @@ func (syncer *Syncer) handleNewTipSet(ctx context.Context, ci *block.ChainInfo)
 	if syncer.chainStore.HasTipSetAndState(ctx, ci.Head) {
 		return nil
 	}
+	if syncer.badTipSets.Has(ci.Head) {
+		return ErrChainHasBadTipSet
+	}
 
 	syncer.reporter.UpdateStatus(status.SyncingStarted(syncer.clock.Now().Unix()), status.SyncHead(ci.Head), status.SyncHeight(ci.Height), status.SyncComplete(false))
 	defer syncer.reporter.UpdateStatus(status.SyncComplete(true))
 	syncer.reporter.UpdateStatus(status.SyncFetchComplete(false))
 
-	tipsets, err := syncer.fetchAndValidateHeaders(ctx, ci)
+	tipsets, err := syncer.fetchAndValidateHeaders(ctx, ci, catchup)
 	if err != nil {
 		return err
 	}
 
-	// Once headers check out, fetch messages
-	_, err = syncer.fetcher.FetchTipSets(ctx, ci.Head, ci.Sender, func(t block.TipSet) (bool, error) {
-		parents, err := t.Parents()
-		if err != nil {
-			return true, err
-		}
-		height, err := t.Height()
-		if err != nil {
-			return false, err
-		}
-
-		// update status with latest fetched head and height
-		syncer.reporter.UpdateStatus(status.FetchHead(t.Key()), status.FetchHeight(height))
-		return syncer.chainStore.HasTipSetAndState(ctx, parents), nil
-	})
-	if err != nil {
-		return err
+	// Tipsets up to the checkpoint are trusted, except the last one which
+	// has no child recording its state.
+	cpIndex, err := syncer.checkpointIndex(ctx, tipsets)
+	if err == ErrForkPastCheckpoint {
+		syncer.rejectChain(tipsets, err, ci.Sender)
 	}
-
-	syncer.reporter.UpdateStatus(status.SyncFetchComplete(true))
 	if err != nil {
 		return err
 	}
+	trusted := cpIndex + 1
+	if trusted > len(tipsets)-1 {
+		trusted = len(tipsets) - 1
+	}
+	// The state of the highest trusted tipset is the parent state of the
+	// first validated one. It is not computed, so it must be imported.
+	if trusted > 0 && !syncer.chainStore.HasState(ctx, tipsets[trusted].At(0).StateRoot.Cid) {
+		return ErrCheckpointStateMissing
+	}
+
+	// Once headers check out, fetch messages from the bottom of the chain up,
+	// validating the tipsets whose messages are fetched while fetching the
+	// messages of the tipsets above them.
+	fetchCtx, cancelFetch := context.WithCancel(ctx)
+	defer cancelFetch()
+	fetches := syncer.fetchMessages(fetchCtx, ci.Sender, tipsets)
+	fetched := 0
 
 	parent, grandParent, err := syncer.ancestorsFromStore(tipsets[0])
 	if err != nil {
@@ -512,6 +682,30 @@ func (syncer *Syncer) handleNewTipSet(ctx context.Context, ci *block.ChainInfo)
 	// Try adding the tipsets of the chain to the store, checking for new
 	// heaviest tipsets.
 	for i, ts := range tipsets {
+		for i >= fetched {
+			fetch, ok := <-fetches
+			if !ok {
+				return fetchCtx.Err()
+			}
+			if fetch.err != nil {
+				return fetch.err
+			}
+			fetched = fetch.fetched
+			if fetched == len(tipsets) {
+				syncer.reporter.UpdateStatus(status.SyncFetchComplete(true))
+			}
+		}
+
+		if i < trusted {
+			err = syncer.putTrusted(ctx, ts, tipsets[i+1])
+			if err != nil {
+				return err
+			}
+			grandParent = parent
+			parent = ts
+			continue
+		}
+
 		// TODO: this "i==0" leaks EC specifics into syncer abstraction
 		// for the sake of efficiency, consider plugging up this leak.
 		var wts block.TipSet
@@ -541,12 +735,7 @@ func (syncer *Syncer) handleNewTipSet(ctx context.Context, ci *block.ChainInfo)
 		if !wts.Defined() || len(tipsets) > 1 {
 			err = syncer.syncOne(ctx, grandParent, parent, ts)
 			if err != nil {
-				// While `syncOne` can indeed fail for reasons other than consensus,
-				// adding to the badTipSets at this point is the simplest, since we
-				// have access to the chain. If syncOne fails for non-consensus reasons,
-				// there is no assumption that the running node's data is valid at all,
-				// so we don't really lose anything with this simplification.
-				syncer.badTipSets.AddChain(tipsets[i:])
+				syncer.rejectChain(tipsets[i:], err, ci.Sender)
 				return err
 			}
 		}
@@ -560,6 +749,51 @@ func (syncer *Syncer) handleNewTipSet(ctx context.Context, ci *block.ChainInfo)
 	return syncer.stageIfHeaviest(ctx, parent)
 }
 
+// messageFetch is the progress of fetching the messages of a chain.
+type messageFetch struct {
+	// fetched is the number of tipsets from the bottom of the chain whose
+	// messages are fetched.
+	fetched int
+	// err is the error that stopped fetching.
+	err error
+}
+
+// fetchMessages fetches the messages of the chain of tipsets, given in height
+// order, in windows of messageWindowSize tipsets from the bottom up. The
+// progress is sent on the returned channel after each window, until all
+// messages are fetched or fetching fails.
+func (syncer *Syncer) fetchMessages(ctx context.Context, sender peer.ID, tipsets []block.TipSet) <-chan messageFetch {
+	out := make(chan messageFetch, 1)
+	go func() {
+		defer close(out)
+		for start := 0; start < len(tipsets); start += messageWindowSize {
+			end := start + messageWindowSize
+			if end > len(tipsets) {
+				end = len(tipsets)
+			}
+			bottom, top := tipsets[start], tipsets[end-1]
+			_, err := syncer.fetcher.FetchTipSets(ctx, top.Key(), sender, func(t block.TipSet) (bool, error) {
+				return t.Key().Equals(bottom.Key()), nil
+			})
+			if err == nil {
+				// update status with latest fetched head and height
+				height, _ := top.Height()
+				syncer.reporter.UpdateStatus(status.FetchHead(top.Key()), status.FetchHeight(height))
+			}
+
+			select {
+			case out <- messageFetch{fetched: end, err: err}:
+			case <-ctx.Done():
+				return
+			}
+			if err != nil {
+				return
+			}
+		}
+	}()
+	return out
+}
+
 func (syncer *Syncer) stageIfHeaviest(ctx context.Context, candidate block.TipSet) error {
 	// stageIfHeaviest sets the provided candidates to the staging head of the chain if they
 	// are heavier. Precondtion: candidates are validated and added to the store.
@@ -21,6 +21,7 @@ import (
 	"github.com/filecoin-project/go-filecoin/internal/pkg/chain"
 	"github.com/filecoin-project/go-filecoin/internal/pkg/chainsync/internal/syncer"
 	"github.com/filecoin-project/go-filecoin/internal/pkg/chainsync/status"
+	"github.com/filecoin-project/go-filecoin/internal/pkg/consensus"
 	"github.com/filecoin-project/go-filecoin/internal/pkg/repo"
 	th "github.com/filecoin-project/go-filecoin/internal/pkg/testhelpers"
 	tf "github.com/filecoin-project/go-filecoin/internal/pkg/testhelpers/testflags"
@@ -125,6 +126,23 @@ func TestChainIncremental(t *testing.T) {
 	verifyHead(t, store, t4)
 }
 
+func TestLongChainFetchedInWindows(t *testing.T) {
+	tf.UnitTest(t)
+	ctx := context.Background()
+	builder, store, syncer := setup(ctx, t)
+	genesis := builder.RequireTipSet(store.GetHead())
+
+	// Messages are fetched in several windows while the chain is validated.
+	head := builder.AppendManyOn(40, genesis)
+	assert.NoError(t, syncer.HandleNewTipSet(ctx, block.NewChainInfo(peer.ID(""), "", head.Key(), heightFromTip(t, head)), false))
+	for _, ts := range builder.RequireTipSets(head.Key(), 40) {
+		verifyTip(t, store, ts, builder.StateForKey(ts.Key()))
+	}
+	require.NoError(t, syncer.SetStagedHead(ctx))
+	verifyHead(t, store, head)
+	assert.True(t, syncer.Status().SyncingFetchComplete)
+}
+
 func TestChainJump(t *testing.T) {
 	tf.UnitTest(t)
 	ctx := context.Background()
@@ -237,7 +255,7 @@ func TestNoUncessesaryFetch(t *testing.T) {
 	// A new syncer unable to fetch blocks from the network can handle a tipset that's already
 	// in the store and linked to genesis.
 	emptyFetcher := chain.NewBuilder(t, address.Undef)
-	newSyncer, err := syncer.NewSyncer(&chain.FakeStateEvaluator{}, &chain.FakeStateEvaluator{}, &chain.FakeChainSelector{}, store, builder, emptyFetcher, status.NewReporter(), th.NewFakeClock(time.Unix(1234567890, 0)), &noopFaultDetector{})
+	newSyncer, err := syncer.NewSyncer(&chain.FakeStateEvaluator{}, &chain.FakeStateEvaluator{}, &chain.FakeChainSelector{}, store, builder, emptyFetcher, status.NewReporter(), th.NewFakeClock(time.Unix(1234567890, 0)), &noopFaultDetector{}, newBadTipSetCache(t), nil)
 	require.NoError(t, err)
 	require.NoError(t, newSyncer.InitStaged())
 	assert.NoError(t, newSyncer.HandleNewTipSet(ctx, block.NewChainInfo(peer.ID(""), "", head.Key(), heightFromTip(t, head)), false))
@@ -421,14 +439,14 @@ func (pv *poisonValidator) RunStateTransition(_ context.Context, ts block.TipSet
 	_ fbig.Int, _ cid.Cid, _ cid.Cid) (cid.Cid, []vm.MessageReceipt, error) {
 	stamp := ts.At(0).Timestamp
 	if pv.fullFailureTS == stamp {
-		return cid.Undef, nil, errors.New("run state transition fails on poison timestamp")
+		return cid.Undef, nil, consensus.Invalid(errors.New("run state transition fails on poison timestamp"))
 	}
 	return cid.Undef, nil, nil
 }
 
 func (pv *poisonValidator) ValidateSemantic(_ context.Context, header *block.Block, _ block.TipSet) error {
 	if pv.headerFailureTS == header.Timestamp {
-		return errors.New("val semantic fails on poison timestamp")
+		return consensus.Invalid(errors.New("val semantic fails on poison timestamp"))
 	}
 	return nil
 }
@@ -437,7 +455,7 @@ func TestSemanticallyBadTipSetFails(t *testing.T) {
 	tf.UnitTest(t)
 	ctx := context.Background()
 	eval := newPoisonValidator(t, 98, 99)
-	builder, store, syncer := setupWithValidator(ctx, t, eval, eval)
+	builder, store, s := setupWithValidator(ctx, t, eval, eval)
 	genesis := builder.RequireTipSet(store.GetHead())
 
 	// Build a chain with messages that will fail semantic header validation
@@ -457,11 +475,130 @@ func TestSemanticallyBadTipSetFails(t *testing.T) {
 	})
 
 	// Set up a fresh builder without any of this data
-	err := syncer.HandleNewTipSet(ctx, block.NewChainInfo(peer.ID(""), "", link1.Key(), heightFromTip(t, link1)), false)
+	ci := block.NewChainInfo(peer.ID(""), "", link1.Key(), heightFromTip(t, link1))
+	err := s.HandleNewTipSet(ctx, ci, false)
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "val semantic fails")
+
+	// The rejected tipset is remembered along with the reason
+	bad := s.BadTipSets().List()
+	require.Len(t, bad, 1)
+	assert.Equal(t, link1.Key(), bad[0].Key)
+	assert.Contains(t, bad[0].Reason, "val semantic fails")
+	assert.Equal(t, syncer.ErrChainHasBadTipSet, s.HandleNewTipSet(ctx, ci, false))
+
+	// Unmarking the tipset validates it again
+	removed, err := s.BadTipSets().Remove(link1.Key())
+	require.NoError(t, err)
+	assert.True(t, removed)
+	err = s.HandleNewTipSet(ctx, ci, false)
 	require.Error(t, err)
 	assert.Contains(t, err.Error(), "val semantic fails")
 }
 
+// checkpointValidator fails the state transitions of tipsets at or below a height.
+type checkpointValidator struct {
+	chain.FakeStateEvaluator
+	failAtOrBelow abi.ChainEpoch
+}
+
+func (cv *checkpointValidator) RunStateTransition(ctx context.Context, ts block.TipSet, blsMessages [][]*types.UnsignedMessage, secpMessages [][]*types.SignedMessage,
+	parentWeight fbig.Int, stateID cid.Cid, receiptCid cid.Cid) (cid.Cid, []vm.MessageReceipt, error) {
+	h, err := ts.Height()
+	if err != nil {
+		return cid.Undef, nil, err
+	}
+	if h <= cv.failAtOrBelow {
+		return cid.Undef, nil, errors.New("state transition run below checkpoint")
+	}
+	return cv.FakeStateEvaluator.RunStateTransition(ctx, ts, blsMessages, secpMessages, parentWeight, stateID, receiptCid)
+}
+
+func TestFailedCheckIsNotBad(t *testing.T) {
+	tf.UnitTest(t)
+	ctx := context.Background()
+	eval := &checkpointValidator{failAtOrBelow: 2}
+	builder, store, s := setupWithValidator(ctx, t, eval, eval)
+	genesis := builder.RequireTipSet(store.GetHead())
+
+	// A state transition failing without breaking the consensus rules leaves
+	// the chain to be synced again.
+	head := builder.AppendManyOn(3, genesis)
+	ci := block.NewChainInfo(peer.ID(""), "", head.Key(), heightFromTip(t, head))
+	require.Error(t, s.HandleNewTipSet(ctx, ci, false))
+	assert.Len(t, s.BadTipSets().List(), 0)
+
+	eval.failAtOrBelow = 0
+	require.NoError(t, s.HandleNewTipSet(ctx, ci, false))
+	verifyHead(t, store, head)
+}
+
+func TestCheckpointTrustsChainBelow(t *testing.T) {
+	tf.UnitTest(t)
+	ctx := context.Background()
+	eval := &checkpointValidator{failAtOrBelow: 3}
+	builder, store, s := setupWithValidator(ctx, t, eval, eval)
+	genesis := builder.RequireTipSet(store.GetHead())
+
+	checkpoint := builder.AppendManyOn(3, genesis)
+	head := builder.AppendManyOn(2, checkpoint)
+	store.SetCheckpoint(checkpoint.Key())
+
+	require.NoError(t, s.HandleNewTipSet(ctx, block.NewChainInfo(peer.ID(""), "", head.Key(), heightFromTip(t, head)), false))
+	for _, ts := range builder.RequireTipSets(head.Key(), 5) {
+		verifyTip(t, store, ts, builder.StateForKey(ts.Key()))
+	}
+	verifyHead(t, store, head)
+}
+
+func TestCheckpointRequiresImportedState(t *testing.T) {
+	tf.UnitTest(t)
+	ctx := context.Background()
+	eval := &checkpointValidator{failAtOrBelow: 3}
+	missing := make(map[cid.Cid]struct{})
+	builder, store, s := setupWithMissingStates(ctx, t, eval, eval, missing)
+	genesis := builder.RequireTipSet(store.GetHead())
+
+	checkpoint := builder.AppendManyOn(3, genesis)
+	head := builder.AppendManyOn(2, checkpoint)
+	store.SetCheckpoint(checkpoint.Key())
+
+	// The state of the checkpoint is not computed but must be imported.
+	missing[builder.StateForKey(checkpoint.Key())] = struct{}{}
+	ci := block.NewChainInfo(peer.ID(""), "", head.Key(), heightFromTip(t, head))
+	assert.Equal(t, syncer.ErrCheckpointStateMissing, s.HandleNewTipSet(ctx, ci, false))
+	assert.Len(t, s.BadTipSets().List(), 0)
+	verifyHead(t, store, genesis)
+
+	delete(missing, builder.StateForKey(checkpoint.Key()))
+	require.NoError(t, s.HandleNewTipSet(ctx, ci, false))
+	verifyHead(t, store, head)
+}
+
+func TestCheckpointRefusesFork(t *testing.T) {
+	tf.UnitTest(t)
+	ctx := context.Background()
+	builder, store, s := setup(ctx, t)
+	genesis := builder.RequireTipSet(store.GetHead())
+
+	checkpoint := builder.AppendManyOn(2, genesis)
+	fork := builder.AppendManyOn(4, genesis)
+	store.SetCheckpoint(checkpoint.Key())
+
+	// Chains are refused until the checkpoint is synced.
+	forkCi := block.NewChainInfo(peer.ID(""), "", fork.Key(), heightFromTip(t, fork))
+	assert.Equal(t, syncer.ErrCheckpointNotSynced, s.HandleNewTipSet(ctx, forkCi, false))
+	assert.Len(t, s.BadTipSets().List(), 0)
+
+	require.NoError(t, s.HandleNewTipSet(ctx, block.NewChainInfo(peer.ID(""), "", checkpoint.Key(), heightFromTip(t, checkpoint)), false))
+	verifyHead(t, store, checkpoint)
+
+	// Afterwards forks past its height are bad.
+	assert.Equal(t, syncer.ErrForkPastCheckpoint, s.HandleNewTipSet(ctx, forkCi, false))
+	assert.Len(t, s.BadTipSets().List(), 4)
+	verifyHead(t, store, checkpoint)
+}
+
 func TestSyncerStatus(t *testing.T) {
 	tf.UnitTest(t)
 	ctx := context.Background()
@@ -547,6 +684,13 @@ func setup(ctx context.Context, t *testing.T) (*chain.Builder, *chain.Store, *sy
 }
 
 func setupWithValidator(ctx context.Context, t *testing.T, fullVal syncer.FullBlockValidator, headerVal syncer.HeaderValidator) (*chain.Builder, *chain.Store, *syncer.Syncer) {
+	return setupWithMissingStates(ctx, t, fullVal, headerVal, map[cid.Cid]struct{}{})
+}
+
+// setupWithMissingStates behaves as setupWithValidator, but the states with
+// roots in `missing` are not in the store. Other states are, although the
+// chain builder computes fake state roots.
+func setupWithMissingStates(ctx context.Context, t *testing.T, fullVal syncer.FullBlockValidator, headerVal syncer.HeaderValidator, missing map[cid.Cid]struct{}) (*chain.Builder, *chain.Store, *syncer.Syncer) {
 	builder := chain.NewBuilder(t, address.Undef)
 	genesis := builder.NewGenesis()
 	genStateRoot, err := builder.GetTipSetStateRoot(genesis.Key())
@@ -563,13 +707,31 @@ func setupWithValidator(ctx context.Context, t *testing.T, fullVal syncer.FullBl
 	// Note: the chain builder is passed as the fetcher, from which blocks may be requested, but
 	// *not* as the store, to which the syncer must ensure to put blocks.
 	sel := &chain.FakeChainSelector{}
-	syncer, err := syncer.NewSyncer(fullVal, headerVal, sel, store, builder, builder, status.NewReporter(), th.NewFakeClock(time.Unix(1234567890, 0)), &noopFaultDetector{})
+	states := &fakeStateStore{Store: store, missing: missing}
+	syncer, err := syncer.NewSyncer(fullVal, headerVal, sel, states, builder, builder, status.NewReporter(), th.NewFakeClock(time.Unix(1234567890, 0)), &noopFaultDetector{}, newBadTipSetCache(t), nil)
 	require.NoError(t, err)
 	require.NoError(t, syncer.InitStaged())
 
 	return builder, store, syncer
 }
 
+// fakeStateStore is a chain store holding all states but the missing ones.
+type fakeStateStore struct {
+	*chain.Store
+	missing map[cid.Cid]struct{}
+}
+
+func (s *fakeStateStore) HasState(_ context.Context, root cid.Cid) bool {
+	_, missing := s.missing[root]
+	return !missing
+}
+
+func newBadTipSetCache(t *testing.T) *syncer.BadTipSetCache {
+	cache, err := syncer.NewBadTipSetCache(nil, 1000)
+	require.NoError(t, err)
+	return cache
+}
+
 ///// Verification helpers /////
 
 // Sub-interface of the store used for verification.
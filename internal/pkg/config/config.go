@@ -4,12 +4,16 @@ import (
 	"encoding/json"
 	"fmt"
 	"io/ioutil"
+	"net"
+	"net/url"
 	"os"
 	"reflect"
 	"regexp"
 	"strings"
+	"time"
 
 	"github.com/filecoin-project/go-address"
+	"github.com/ipfs/go-cid"
 	"github.com/pkg/errors"
 
 	"github.com/filecoin-project/go-filecoin/internal/pkg/types"
@@ -19,12 +23,16 @@ import (
 type Config struct {
 	API           *APIConfig           `json:"api"`
 	Bootstrap     *BootstrapConfig     `json:"bootstrap"`
+	Chain         *ChainConfig         `json:"chain"`
 	Datastore     *DatastoreConfig     `json:"datastore"`
+	GC            *GCConfig            `json:"gc"`
 	Heartbeat     *HeartbeatConfig     `json:"heartbeat"`
+	Message       *MessageConfig       `json:"message"`
 	Mining        *MiningConfig        `json:"mining"`
 	Mpool         *MessagePoolConfig   `json:"mpool"`
 	Observability *ObservabilityConfig `json:"observability"`
 	SectorBase    *SectorBaseConfig    `json:"sectorbase"`
+	Slashing      *SlashingConfig      `json:"slashing"`
 	Swarm         *SwarmConfig         `json:"swarm"`
 	Wallet        *WalletConfig        `json:"wallet"`
 }
@@ -63,7 +71,13 @@ type DatastoreConfig struct {
 // the given key and value are valid. Validators will only be run if a property
 // being set matches the name given in this map.
 var Validators = map[string]func(string, string) error{
-	"heartbeat.nickname": validateLettersOnly,
+	"gc.period":                  validatePositiveDuration,
+	"heartbeat.nickname":         validateLettersOnly,
+	"message.gasPricePercentile": validatePercentile,
+	"mining.messageSelection":    validateMessageSelection,
+	"swarm.banDuration":          validatePositiveDuration,
+	"swarm.banThreshold":         validateNegativeInteger,
+	"wallet.remoteSigner":        validateRemoteSigner,
 }
 
 func newDefaultDatastoreConfig() *DatastoreConfig {
@@ -73,15 +87,61 @@ func newDefaultDatastoreConfig() *DatastoreConfig {
 	}
 }
 
+// ChainConfig holds all configuration options related to the chain and its
+// validation.
+type ChainConfig struct {
+	// BadTipSetCacheSize is the number of tipsets that failed validation
+	// remembered so that they are not synced again.
+	BadTipSetCacheSize int `json:"badTipSetCacheSize"`
+	// Checkpoint is the key of a tipset trusted without validating its
+	// state. Chains that do not include it are refused.
+	Checkpoint []cid.Cid `json:"checkpoint,omitempty"`
+}
+
+func newDefaultChainConfig() *ChainConfig {
+	return &ChainConfig{
+		BadTipSetCacheSize: 1024,
+	}
+}
+
+// GCConfig holds all configuration options related to garbage collection of
+// the blockstore.
+type GCConfig struct {
+	// Enabled runs garbage collection periodically while the daemon runs.
+	Enabled bool `json:"enabled"`
+	// Period is the time between background collections.
+	// Golang duration units are accepted.
+	Period string `json:"period"`
+	// RetainedEpochs is the number of final epochs whose state and messages
+	// are kept, in addition to those of epochs not yet final.
+	RetainedEpochs uint64 `json:"retainedEpochs"`
+}
+
+func newDefaultGCConfig() *GCConfig {
+	return &GCConfig{
+		Enabled:        false,
+		Period:         "1h",
+		RetainedEpochs: 2880,
+	}
+}
+
 // SwarmConfig holds all configuration options related to the swarm.
 type SwarmConfig struct {
 	Address            string `json:"address"`
 	PublicRelayAddress string `json:"public_relay_address,omitempty"`
+	// BanThreshold is the score at which a misbehaving peer is banned. It
+	// must be negative, peers start at a score of zero.
+	BanThreshold int `json:"banThreshold"`
+	// BanDuration is how long a banned peer stays banned.
+	// Golang duration units are accepted.
+	BanDuration string `json:"banDuration"`
 }
 
 func newDefaultSwarmConfig() *SwarmConfig {
 	return &SwarmConfig{
-		Address: "/ip4/0.0.0.0/tcp/6000",
+		Address:      "/ip4/0.0.0.0/tcp/6000",
+		BanThreshold: -100,
+		BanDuration:  "30m",
 	}
 }
 
@@ -101,24 +161,65 @@ func newDefaultBootstrapConfig() *BootstrapConfig {
 	}
 }
 
+// MessageConfig holds all configuration options related to estimating the gas
+// of the messages sent by the node.
+type MessageConfig struct {
+	// GasLimitMarginPercent is the percentage added to the gas a message uses
+	// when run against the head state to make its estimated gas limit.
+	GasLimitMarginPercent uint `json:"gasLimitMarginPercent"`
+	// GasPricePercentile is the percentile of the gas prices of the messages
+	// included in recent tipsets suggested as gas price.
+	GasPricePercentile uint `json:"gasPricePercentile"`
+	// GasPriceLookback is the number of tipsets, back from the head, whose
+	// messages the gas price is suggested from.
+	GasPriceLookback uint `json:"gasPriceLookback"`
+	// MinGasPrice is the least gas price suggested, in attoFIL, and the one
+	// suggested when recent tipsets include no messages.
+	MinGasPrice types.AttoFIL `json:"minGasPrice"`
+}
+
+func newDefaultMessageConfig() *MessageConfig {
+	return &MessageConfig{
+		GasLimitMarginPercent: 25,
+		GasPricePercentile:    50,
+		GasPriceLookback:      10,
+		MinGasPrice:           types.NewGasPrice(1),
+	}
+}
+
 // MiningConfig holds all configuration options related to mining.
 type MiningConfig struct {
-	MinerAddress            address.Address `json:"minerAddress"`
-	AutoSealIntervalSeconds uint            `json:"autoSealIntervalSeconds"`
-	StoragePrice            types.AttoFIL   `json:"storagePrice"`
+	MinerAddress address.Address `json:"minerAddress"`
+	// MinerAddresses are further miner actors the node mines blocks for,
+	// running an election for each alongside MinerAddress. The wallet must
+	// hold the keys of their workers. Storage and retrieval deals are only
+	// served for MinerAddress.
+	MinerAddresses          []address.Address `json:"minerAddresses"`
+	AutoSealIntervalSeconds uint              `json:"autoSealIntervalSeconds"`
+	StoragePrice            types.AttoFIL     `json:"storagePrice"`
+	// MessageSelection is the strategy choosing the messages of mined
+	// blocks: "greedy", by decreasing gas price, or "pool", in the order of
+	// the message pool.
+	MessageSelection string `json:"messageSelection"`
 }
 
 func newDefaultMiningConfig() *MiningConfig {
 	return &MiningConfig{
 		MinerAddress:            address.Undef,
+		MinerAddresses:          []address.Address{},
 		AutoSealIntervalSeconds: 120,
 		StoragePrice:            types.ZeroAttoFIL,
+		MessageSelection:        "greedy",
 	}
 }
 
 // WalletConfig holds all configuration options related to the wallet.
 type WalletConfig struct {
 	DefaultAddress address.Address `json:"defaultAddress,omitempty"`
+	// RemoteSigner is the address of an external signer process holding
+	// wallet keys, either unix:///path/to/socket or http://127.0.0.1:port.
+	// Addresses of the signer are used alongside those of the local wallet.
+	RemoteSigner string `json:"remoteSigner,omitempty"`
 }
 
 func newDefaultWalletConfig() *WalletConfig {
@@ -206,12 +307,19 @@ type MessagePoolConfig struct {
 	MaxPoolSize uint `json:"maxPoolSize"`
 	// MaxNonceGap is the maximum nonce of a message past the last received on chain
 	MaxNonceGap uint64 `json:"maxNonceGap"`
+	// MaxSenderMessages is the maximum number of pending messages from a single sender
+	MaxSenderMessages uint `json:"maxSenderMessages"`
+	// ReplaceByFeeBumpPercent is the minimum percentage by which the gas price of a message must
+	// exceed that of a pending message with the same sender and nonce in order to replace it
+	ReplaceByFeeBumpPercent uint `json:"replaceByFeeBumpPercent"`
 }
 
 func newDefaultMessagePoolConfig() *MessagePoolConfig {
 	return &MessagePoolConfig{
-		MaxPoolSize: 10000,
-		MaxNonceGap: 100,
+		MaxPoolSize:             10000,
+		MaxNonceGap:             100,
+		MaxSenderMessages:       100,
+		ReplaceByFeeBumpPercent: 25,
 	}
 }
 
@@ -229,19 +337,37 @@ func newDefaultSectorbaseConfig() *SectorBaseConfig {
 	}
 }
 
+// SlashingConfig holds all configuration options related to reporting
+// consensus faults.
+type SlashingConfig struct {
+	// ReporterAddress is the address consensus fault reports are sent from.
+	// Detected faults are not reported while it is empty.
+	ReporterAddress address.Address `json:"reporterAddress"`
+}
+
+func newDefaultSlashingConfig() *SlashingConfig {
+	return &SlashingConfig{
+		ReporterAddress: address.Undef,
+	}
+}
+
 // NewDefaultConfig returns a config object with all the fields filled out to
 // their default values
 func NewDefaultConfig() *Config {
 	return &Config{
 		API:           newDefaultAPIConfig(),
 		Bootstrap:     newDefaultBootstrapConfig(),
+		Chain:         newDefaultChainConfig(),
 		Datastore:     newDefaultDatastoreConfig(),
+		GC:            newDefaultGCConfig(),
 		Swarm:         newDefaultSwarmConfig(),
+		Message:       newDefaultMessageConfig(),
 		Mining:        newDefaultMiningConfig(),
 		Wallet:        newDefaultWalletConfig(),
 		Heartbeat:     newDefaultHeartbeatConfig(),
 		Mpool:         newDefaultMessagePoolConfig(),
 		SectorBase:    newDefaultSectorbaseConfig(),
+		Slashing:      newDefaultSlashingConfig(),
 		Observability: newDefaultObservabilityConfig(),
 	}
 }
@@ -375,3 +501,89 @@ func validateLettersOnly(key string, value string) error {
 	}
 	return nil
 }
+
+// validateRemoteSigner validates that a given value is empty, a unix socket
+// URL or an HTTP URL on a loopback address.
+func validateRemoteSigner(key string, value string) error {
+	var target string
+	if err := json.Unmarshal([]byte(value), &target); err != nil {
+		return errors.Errorf(`"%s" must be a string`, key)
+	}
+	if target == "" {
+		return nil
+	}
+
+	u, err := url.Parse(target)
+	if err != nil {
+		return errors.Wrapf(err, `"%s" is not a valid URL`, key)
+	}
+	switch u.Scheme {
+	case "unix":
+		if u.Path == "" {
+			return errors.Errorf(`"%s" must include a socket path`, key)
+		}
+	case "http":
+		if !IsLoopbackHost(u.Hostname()) {
+			return errors.Errorf(`"%s" must be on a loopback address`, key)
+		}
+	default:
+		return errors.Errorf(`"%s" must be a unix:// or http:// URL`, key)
+	}
+	return nil
+}
+
+// IsLoopbackHost returns true if host is localhost or a loopback IP. A remote
+// signer must only be reachable on such a host.
+func IsLoopbackHost(host string) bool {
+	if host == "localhost" {
+		return true
+	}
+	ip := net.ParseIP(host)
+	return ip != nil && ip.IsLoopback()
+}
+
+// validateMessageSelection validates that a given value names a message
+// selection strategy.
+func validateMessageSelection(key string, value string) error {
+	var s string
+	if err := json.Unmarshal([]byte(value), &s); err != nil {
+		return errors.Errorf(`"%s" must be a string`, key)
+	}
+	if s != "greedy" && s != "pool" {
+		return errors.Errorf(`"%s" must be "greedy" or "pool"`, key)
+	}
+	return nil
+}
+
+// validatePercentile validates that a given value is an integer between 0 and
+// 100.
+func validatePercentile(key string, value string) error {
+	var p uint
+	if err := json.Unmarshal([]byte(value), &p); err != nil || p > 100 {
+		return errors.Errorf(`"%s" must be an integer between 0 and 100`, key)
+	}
+	return nil
+}
+
+// validateNegativeInteger validates that a given value is a negative integer.
+func validateNegativeInteger(key string, value string) error {
+	var n int
+	if err := json.Unmarshal([]byte(value), &n); err != nil || n >= 0 {
+		return errors.Errorf(`"%s" must be a negative integer`, key)
+	}
+	return nil
+}
+
+// validatePositiveDuration validates that a given value is a positive Golang
+// duration.
+func validatePositiveDuration(key string, value string) error {
+	var s string
+	if err := json.Unmarshal([]byte(value), &s); err != nil {
+		return errors.Errorf(`"%s" must be a string`, key)
+	}
+	d, err := time.ParseDuration(s)
+	if err != nil || d <= 0 {
+		return errors.Errorf(`"%s" must be a positive duration`, key)
+	}
+	return nil
+}
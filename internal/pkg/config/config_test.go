@@ -62,24 +62,42 @@ func TestWriteFile(t *testing.T) {
 		"minPeerThreshold": 0,
 		"period": "1m"
 	},
+	"chain": {
+		"badTipSetCacheSize": 1024
+	},
 	"datastore": {
 		"type": "badgerds",
 		"path": "badger"
 	},
+	"gc": {
+		"enabled": false,
+		"period": "1h",
+		"retainedEpochs": 2880
+	},
 	"heartbeat": {
 		"beatTarget": "",
 		"beatPeriod": "3s",
 		"reconnectPeriod": "10s",
 		"nickname": ""
 	},
+	"message": {
+		"gasLimitMarginPercent": 25,
+		"gasPricePercentile": 50,
+		"gasPriceLookback": 10,
+		"minGasPrice": "1"
+	},
 	"mining": {
 		"minerAddress": "\u003cempty\u003e",
+		"minerAddresses": [],
 		"autoSealIntervalSeconds": 120,
-		"storagePrice": "0"
+		"storagePrice": "0",
+		"messageSelection": "greedy"
 	},
 	"mpool": {
 		"maxPoolSize": 10000,
-		"maxNonceGap": 100
+		"maxNonceGap": 100,
+		"maxSenderMessages": 100,
+		"replaceByFeeBumpPercent": 25
 	},
 	"observability": {
 		"metrics": {
@@ -96,8 +114,13 @@ func TestWriteFile(t *testing.T) {
 	"sectorbase": {
 		"rootdir": ""
 	},
+	"slashing": {
+		"reporterAddress": "\u003cempty\u003e"
+	},
 	"swarm": {
-		"address": "/ip4/0.0.0.0/tcp/6000"
+		"address": "/ip4/0.0.0.0/tcp/6000",
+		"banThreshold": -100,
+		"banDuration": "30m"
 	},
 	"wallet": {
 		"defaultAddress": "\u003cempty\u003e"
@@ -123,6 +146,62 @@ func TestSetRejectsInvalidNicks(t *testing.T) {
 	assert.Error(t, err)
 }
 
+func TestSetRejectsInvalidRemoteSigner(t *testing.T) {
+	tf.UnitTest(t)
+
+	cfg := NewDefaultConfig()
+
+	assert.NoError(t, cfg.Set("wallet.remoteSigner", `"unix:///var/run/signer.sock"`))
+	assert.NoError(t, cfg.Set("wallet.remoteSigner", `"http://127.0.0.1:5050"`))
+	assert.NoError(t, cfg.Set("wallet.remoteSigner", `""`))
+	assert.Error(t, cfg.Set("wallet.remoteSigner", `"http://10.0.0.1:5050"`))
+	assert.Error(t, cfg.Set("wallet.remoteSigner", `"https://127.0.0.1:5050"`))
+	assert.Error(t, cfg.Set("wallet.remoteSigner", `"unix://"`))
+}
+
+func TestSetRejectsInvalidGCPeriod(t *testing.T) {
+	tf.UnitTest(t)
+
+	cfg := NewDefaultConfig()
+
+	assert.NoError(t, cfg.Set("gc.period", `"30m"`))
+	assert.Error(t, cfg.Set("gc.period", `"0s"`))
+	assert.Error(t, cfg.Set("gc.period", `"often"`))
+}
+
+func TestSetRejectsInvalidBans(t *testing.T) {
+	tf.UnitTest(t)
+
+	cfg := NewDefaultConfig()
+
+	assert.NoError(t, cfg.Set("swarm.banThreshold", `-50`))
+	assert.Error(t, cfg.Set("swarm.banThreshold", `0`))
+	assert.Error(t, cfg.Set("swarm.banThreshold", `"low"`))
+	assert.NoError(t, cfg.Set("swarm.banDuration", `"1h"`))
+	assert.Error(t, cfg.Set("swarm.banDuration", `"0s"`))
+}
+
+func TestSetRejectsInvalidMessageSelection(t *testing.T) {
+	tf.UnitTest(t)
+
+	cfg := NewDefaultConfig()
+
+	assert.NoError(t, cfg.Set("mining.messageSelection", `"pool"`))
+	assert.NoError(t, cfg.Set("mining.messageSelection", `"greedy"`))
+	assert.Error(t, cfg.Set("mining.messageSelection", `"random"`))
+}
+
+func TestSetRejectsInvalidGasPricePercentile(t *testing.T) {
+	tf.UnitTest(t)
+
+	cfg := NewDefaultConfig()
+
+	assert.NoError(t, cfg.Set("message.gasPricePercentile", "90"))
+	assert.Equal(t, uint(90), cfg.Message.GasPricePercentile)
+	assert.Error(t, cfg.Set("message.gasPricePercentile", "101"))
+	assert.Error(t, cfg.Set("message.gasPricePercentile", "-1"))
+}
+
 func TestConfigRoundtrip(t *testing.T) {
 	tf.UnitTest(t)
 
@@ -4,10 +4,13 @@ import (
 	"context"
 	"fmt"
 
+	"github.com/pkg/errors"
+
 	"github.com/filecoin-project/go-filecoin/internal/pkg/block"
 	"github.com/filecoin-project/go-filecoin/internal/pkg/clock"
 	"github.com/filecoin-project/go-filecoin/internal/pkg/types"
 	"github.com/filecoin-project/go-filecoin/internal/pkg/vm"
+	"github.com/filecoin-project/go-filecoin/internal/pkg/vm/gas"
 )
 
 // BlockValidator defines an interface used to validate a blocks syntax and
@@ -41,10 +44,45 @@ type BlockSyntaxValidator interface {
 type MessageSyntaxValidator interface {
 	ValidateMessagesSyntax(ctx context.Context, messages []*types.SignedMessage) error
 	ValidateUnsignedMessagesSyntax(ctx context.Context, messages []*types.UnsignedMessage) error
+	ValidateMessagesGasLimit(ctx context.Context, secpMessages []*types.SignedMessage, blsMessages []*types.UnsignedMessage) error
 	// TODO: Remove receipt validation when they're no longer fetched, #3489
 	ValidateReceiptsSyntax(ctx context.Context, receipts []vm.MessageReceipt) error
 }
 
+// invalidError marks an error as a block or tipset breaking the consensus
+// rules, as opposed to a failure to check it, such as missing local data.
+type invalidError struct {
+	error
+}
+
+func (e *invalidError) Cause() error {
+	return e.error
+}
+
+// Invalid marks err as a violation of the consensus rules. Chains failing with
+// an invalid error are bad for good, other failures may pass on a retry.
+func Invalid(err error) error {
+	if err == nil {
+		return nil
+	}
+	return &invalidError{err}
+}
+
+// IsInvalid returns true if err, or an error it wraps, was marked by Invalid.
+func IsInvalid(err error) bool {
+	for err != nil {
+		if _, ok := err.(*invalidError); ok {
+			return true
+		}
+		causer, ok := err.(interface{ Cause() error })
+		if !ok {
+			return false
+		}
+		err = causer.Cause()
+	}
+	return false
+}
+
 // DefaultBlockValidator implements the BlockValidator interface.
 type DefaultBlockValidator struct {
 	clock.ChainEpochClock
@@ -95,7 +133,7 @@ func (dv *DefaultBlockValidator) ValidateSemantic(ctx context.Context, child *bl
 	}
 
 	if child.Height <= ph {
-		return fmt.Errorf("block %s has invalid height %d", child.Cid().String(), child.Height)
+		return Invalid(fmt.Errorf("block %s has invalid height %d", child.Cid().String(), child.Height))
 	}
 
 	return nil
@@ -104,48 +142,106 @@ func (dv *DefaultBlockValidator) ValidateSemantic(ctx context.Context, child *bl
 // ValidateSyntax validates a single block is correctly formed.
 // TODO this is an incomplete implementation #3277
 func (dv *DefaultBlockValidator) ValidateSyntax(ctx context.Context, blk *block.Block) error {
-	// TODO special handling for genesis block #3121
 	if blk.Height == 0 {
-		return nil
+		return dv.validateGenesisSyntax(blk)
 	}
+	// A future block may only be ahead of a skewed local clock.
 	err := dv.NotFutureBlock(blk)
 	if err != nil {
 		return err
 	}
 	err = dv.TimeMatchesEpoch(blk)
 	if err != nil {
-		return err
+		return Invalid(err)
 	}
 	if !blk.StateRoot.Defined() {
-		return fmt.Errorf("block %s has nil StateRoot", blk.Cid().String())
+		return Invalid(errors.Wrapf(ErrNilStateRoot, "block %s", blk.Cid()))
 	}
 	if blk.Miner.Empty() {
-		return fmt.Errorf("block %s has nil miner address", blk.Cid().String())
+		return Invalid(fmt.Errorf("block %s has nil miner address", blk.Cid().String()))
 	}
 	if len(blk.Ticket.VRFProof) == 0 {
-		return fmt.Errorf("block %s has nil ticket", blk.Cid().String())
+		return Invalid(fmt.Errorf("block %s has nil ticket", blk.Cid().String()))
 	}
 
 	return nil
 }
 
+// validateGenesisSyntax validates a block at height zero. The genesis block is
+// not mined, so it has no parents, ticket or timing constraints, but it must
+// still commit to a state, messages and receipts.
+func (dv *DefaultBlockValidator) validateGenesisSyntax(blk *block.Block) error {
+	if !blk.Parents.Empty() {
+		return Invalid(errors.Wrapf(ErrGenesisHasParents, "block %s", blk.Cid()))
+	}
+	if !blk.StateRoot.Defined() {
+		return Invalid(errors.Wrapf(ErrNilStateRoot, "genesis block %s", blk.Cid()))
+	}
+	if !blk.Messages.Defined() {
+		return Invalid(errors.Wrapf(ErrNilMessages, "genesis block %s", blk.Cid()))
+	}
+	if !blk.MessageReceipts.Defined() {
+		return Invalid(errors.Wrapf(ErrNilMessageReceipts, "genesis block %s", blk.Cid()))
+	}
+	return nil
+}
+
 // ValidateMessagesSyntax validates a set of messages are correctly formed.
-// TODO: Create a real implementation
-// See: https://github.com/filecoin-project/go-filecoin/issues/3312
 func (dv *DefaultBlockValidator) ValidateMessagesSyntax(ctx context.Context, messages []*types.SignedMessage) error {
+	for i, smsg := range messages {
+		if err := validateSignatureSyntax(smsg); err != nil {
+			return wrapMessageSyntaxError(err, i, smsg)
+		}
+		if err := validateMessageSyntax(&smsg.Message); err != nil {
+			return wrapMessageSyntaxError(err, i, smsg)
+		}
+	}
 	return nil
 }
 
 // ValidateUnsignedMessagesSyntax validates a set of messages are correctly formed.
-// TODO: Create a real implementation
-// See: https://github.com/filecoin-project/go-filecoin/issues/3312
 func (dv *DefaultBlockValidator) ValidateUnsignedMessagesSyntax(ctx context.Context, messages []*types.UnsignedMessage) error {
+	for i, msg := range messages {
+		if err := validateMessageSyntax(msg); err != nil {
+			return wrapMessageSyntaxError(err, i, msg)
+		}
+	}
+	return nil
+}
+
+// ValidateMessagesGasLimit validates that the gas limits of all the messages
+// of a block, secp and BLS, sum to at most the block gas limit.
+func (dv *DefaultBlockValidator) ValidateMessagesGasLimit(ctx context.Context, secpMessages []*types.SignedMessage, blsMessages []*types.UnsignedMessage) error {
+	totalGas := types.GasUnits(0)
+	for i, msg := range blsMessages {
+		totalGas += msg.GasLimit
+		if totalGas > types.BlockGasLimit {
+			return wrapMessageSyntaxError(ErrTotalGasAboveBlockLimit, i, msg)
+		}
+	}
+	for i, smsg := range secpMessages {
+		totalGas += smsg.Message.GasLimit
+		if totalGas > types.BlockGasLimit {
+			return wrapMessageSyntaxError(ErrTotalGasAboveBlockLimit, len(blsMessages)+i, smsg)
+		}
+	}
 	return nil
 }
 
 // ValidateReceiptsSyntax validates a set of receipts are correctly formed.
-// TODO: Create a real implementation
-// See: https://github.com/filecoin-project/go-filecoin/issues/3312
 func (dv *DefaultBlockValidator) ValidateReceiptsSyntax(ctx context.Context, receipts []vm.MessageReceipt) error {
+	totalGas := gas.Unit(0)
+	for i, r := range receipts {
+		if r.GasUsed < 0 {
+			return Invalid(errors.Wrapf(ErrReceiptNegativeGas, "receipt %d", i))
+		}
+		if len(r.ReturnValue) > MaxReceiptReturnSize {
+			return Invalid(errors.Wrapf(ErrReceiptReturnTooLarge, "receipt %d", i))
+		}
+		totalGas += r.GasUsed
+		if totalGas > gas.Unit(types.BlockGasLimit) {
+			return Invalid(errors.Wrapf(ErrReceiptGasAboveBlockLimit, "receipt %d", i))
+		}
+	}
 	return nil
 }
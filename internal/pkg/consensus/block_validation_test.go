@@ -2,22 +2,27 @@ package consensus_test
 
 import (
 	"context"
+	"math/big"
 	"testing"
 	"time"
 
 	"github.com/filecoin-project/go-address"
 	"github.com/ipfs/go-cid"
+	"github.com/pkg/errors"
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
 
 	"github.com/filecoin-project/go-filecoin/internal/pkg/block"
 	"github.com/filecoin-project/go-filecoin/internal/pkg/clock"
 	"github.com/filecoin-project/go-filecoin/internal/pkg/consensus"
+	"github.com/filecoin-project/go-filecoin/internal/pkg/crypto"
 	e "github.com/filecoin-project/go-filecoin/internal/pkg/enccid"
 	th "github.com/filecoin-project/go-filecoin/internal/pkg/testhelpers"
 	tf "github.com/filecoin-project/go-filecoin/internal/pkg/testhelpers/testflags"
 	"github.com/filecoin-project/go-filecoin/internal/pkg/types"
+	"github.com/filecoin-project/go-filecoin/internal/pkg/vm"
 	vmaddr "github.com/filecoin-project/go-filecoin/internal/pkg/vm/address"
+	"github.com/filecoin-project/go-filecoin/internal/pkg/vm/gas"
 )
 
 func TestBlockValidSemantic(t *testing.T) {
@@ -121,26 +126,157 @@ func TestBlockValidSyntax(t *testing.T) {
 
 	// invalidate timestamp
 	blk.Timestamp = uint64(ts.Add(time.Duration(3) * blockTime).Unix())
-	require.Error(t, validator.ValidateSyntax(ctx, blk))
+	err := validator.ValidateSyntax(ctx, blk)
+	require.Error(t, err)
+	assert.True(t, consensus.IsInvalid(err))
+	blk.Timestamp = validTs
+	require.NoError(t, validator.ValidateSyntax(ctx, blk))
+
+	// a block from a future epoch may only be ahead of the local clock
+	blk.Height = 2
+	blk.Timestamp = uint64(ts.Add(2 * blockTime).Unix())
+	err = validator.ValidateSyntax(ctx, blk)
+	require.Error(t, err)
+	assert.False(t, consensus.IsInvalid(err))
+	blk.Height = 1
 	blk.Timestamp = validTs
 	require.NoError(t, validator.ValidateSyntax(ctx, blk))
 
 	// invalidate stateroot
 	blk.StateRoot = e.NewCid(cid.Undef)
-	require.Error(t, validator.ValidateSyntax(ctx, blk))
+	err = validator.ValidateSyntax(ctx, blk)
+	require.Error(t, err)
+	assert.True(t, consensus.IsInvalid(err))
 	blk.StateRoot = validSt
 	require.NoError(t, validator.ValidateSyntax(ctx, blk))
 
 	// invalidate miner address
 	blk.Miner = address.Undef
-	require.Error(t, validator.ValidateSyntax(ctx, blk))
+	err = validator.ValidateSyntax(ctx, blk)
+	require.Error(t, err)
+	assert.True(t, consensus.IsInvalid(err))
 	blk.Miner = validAd
 	require.NoError(t, validator.ValidateSyntax(ctx, blk))
 
 	// invalidate ticket
 	blk.Ticket = block.Ticket{}
-	require.Error(t, validator.ValidateSyntax(ctx, blk))
+	err = validator.ValidateSyntax(ctx, blk)
+	require.Error(t, err)
+	assert.True(t, consensus.IsInvalid(err))
 	blk.Ticket = validTi
 	require.NoError(t, validator.ValidateSyntax(ctx, blk))
 
 }
+
+func TestBlockValidMessageSyntax(t *testing.T) {
+	tf.UnitTest(t)
+
+	ctx := context.Background()
+	validator := consensus.NewDefaultBlockValidator(clock.NewChainClock(1234567890, clock.DefaultEpochDuration))
+	keys := types.MustGenerateKeyInfo(1, 42)
+	signer := types.NewMockSigner(keys)
+	from := signer.Addresses[0]
+	to := vmaddr.NewForTestGetter()()
+
+	newMsg := func() *types.UnsignedMessage {
+		return types.NewMeteredMessage(from, to, 0, types.NewAttoFILFromFIL(1), 0, []byte{}, types.NewGasPrice(1), types.GasUnits(100))
+	}
+	sign := func(msg *types.UnsignedMessage) *types.SignedMessage {
+		smsg, err := types.NewSignedMessage(*msg, &signer)
+		require.NoError(t, err)
+		return smsg
+	}
+
+	require.NoError(t, validator.ValidateMessagesSyntax(ctx, []*types.SignedMessage{sign(newMsg())}))
+	require.NoError(t, validator.ValidateUnsignedMessagesSyntax(ctx, []*types.UnsignedMessage{newMsg()}))
+
+	cases := []struct {
+		name     string
+		mutate   func(msg *types.UnsignedMessage)
+		expected error
+	}{
+		{"undefined from", func(m *types.UnsignedMessage) { m.From = address.Undef }, consensus.ErrInvalidFromAddress},
+		{"undefined to", func(m *types.UnsignedMessage) { m.To = address.Undef }, consensus.ErrInvalidToAddress},
+		{"negative gas price", func(m *types.UnsignedMessage) { m.GasPrice = types.NewGasPrice(-1) }, consensus.ErrNegativeGasPrice},
+		{"negative gas limit", func(m *types.UnsignedMessage) { m.GasLimit = -1 }, consensus.ErrNegativeGasLimit},
+		{"gas limit above block limit", func(m *types.UnsignedMessage) { m.GasLimit = types.BlockGasLimit + 1 }, consensus.ErrGasLimitAboveBlockLimit},
+		{"negative method", func(m *types.UnsignedMessage) { m.Method = -1 }, consensus.ErrInvalidMethodNum},
+		{"method too large", func(m *types.UnsignedMessage) { m.Method = consensus.MaxMethodNum + 1 }, consensus.ErrInvalidMethodNum},
+		{"params too large", func(m *types.UnsignedMessage) { m.Params = make([]byte, consensus.MaxMessageParamsSize+1) }, consensus.ErrParamsTooLarge},
+	}
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			msg := newMsg()
+			tc.mutate(msg)
+			err := validator.ValidateUnsignedMessagesSyntax(ctx, []*types.UnsignedMessage{msg})
+			require.Error(t, err)
+			assert.Equal(t, tc.expected, errors.Cause(err))
+		})
+	}
+
+	t.Run("negative value", func(t *testing.T) {
+		msg := newMsg()
+		msg.Value = types.NewAttoFIL(big.NewInt(-1))
+		err := validator.ValidateUnsignedMessagesSyntax(ctx, []*types.UnsignedMessage{msg})
+		assert.Equal(t, consensus.ErrNegativeMessageValue, errors.Cause(err))
+	})
+
+	t.Run("total gas above block limit", func(t *testing.T) {
+		m1, m2 := newMsg(), newMsg()
+		m1.GasLimit = types.BlockGasLimit / 2
+		m2.GasLimit = types.BlockGasLimit / 2
+		m2.CallSeqNum = 1
+		require.NoError(t, validator.ValidateMessagesGasLimit(ctx, []*types.SignedMessage{sign(m1)}, []*types.UnsignedMessage{m2}))
+
+		// Secp and BLS messages count against the same limit.
+		m3 := newMsg()
+		m3.GasLimit = 1
+		m3.CallSeqNum = 2
+		err := validator.ValidateMessagesGasLimit(ctx, []*types.SignedMessage{sign(m1), sign(m3)}, []*types.UnsignedMessage{m2})
+		assert.Equal(t, consensus.ErrTotalGasAboveBlockLimit, errors.Cause(err))
+	})
+
+	t.Run("secp message with bls signature", func(t *testing.T) {
+		smsg := sign(newMsg())
+		smsg.Signature.Type = crypto.SigTypeBLS
+		err := validator.ValidateMessagesSyntax(ctx, []*types.SignedMessage{smsg})
+		assert.Equal(t, consensus.ErrInvalidSignatureType, errors.Cause(err))
+	})
+
+	t.Run("receipts", func(t *testing.T) {
+		require.NoError(t, validator.ValidateReceiptsSyntax(ctx, []vm.MessageReceipt{{GasUsed: 10}}))
+
+		err := validator.ValidateReceiptsSyntax(ctx, []vm.MessageReceipt{{GasUsed: -1}})
+		assert.Equal(t, consensus.ErrReceiptNegativeGas, errors.Cause(err))
+
+		err = validator.ValidateReceiptsSyntax(ctx, []vm.MessageReceipt{{ReturnValue: make([]byte, consensus.MaxReceiptReturnSize+1)}})
+		assert.Equal(t, consensus.ErrReceiptReturnTooLarge, errors.Cause(err))
+
+		err = validator.ValidateReceiptsSyntax(ctx, []vm.MessageReceipt{{GasUsed: gas.Unit(types.BlockGasLimit)}, {GasUsed: 1}})
+		assert.Equal(t, consensus.ErrReceiptGasAboveBlockLimit, errors.Cause(err))
+	})
+}
+
+func TestGenesisBlockSyntax(t *testing.T) {
+	tf.UnitTest(t)
+
+	ctx := context.Background()
+	validator := consensus.NewDefaultBlockValidator(clock.NewChainClock(1234567890, clock.DefaultEpochDuration))
+	c := e.NewCid(types.NewCidForTestGetter()())
+	gen := &block.Block{StateRoot: c, Messages: c, MessageReceipts: c}
+	require.NoError(t, validator.ValidateSyntax(ctx, gen))
+
+	gen.Parents = block.NewTipSetKey(c.Cid)
+	err := validator.ValidateSyntax(ctx, gen)
+	assert.Equal(t, consensus.ErrGenesisHasParents, errors.Cause(err))
+	gen.Parents = block.NewTipSetKey()
+
+	gen.StateRoot = e.NewCid(cid.Undef)
+	err = validator.ValidateSyntax(ctx, gen)
+	assert.Equal(t, consensus.ErrNilStateRoot, errors.Cause(err))
+	gen.StateRoot = c
+
+	gen.MessageReceipts = e.NewCid(cid.Undef)
+	err = validator.ValidateSyntax(ctx, gen)
+	assert.Equal(t, consensus.ErrNilMessageReceipts, errors.Cause(err))
+}
@@ -9,7 +9,9 @@ import (
 	"github.com/filecoin-project/specs-actors/actors/abi"
 	fbig "github.com/filecoin-project/specs-actors/actors/abi/big"
 	"github.com/filecoin-project/specs-actors/actors/builtin/miner"
+	blocks "github.com/ipfs/go-block-format"
 	cid "github.com/ipfs/go-cid"
+	"github.com/ipfs/go-datastore"
 	blockstore "github.com/ipfs/go-ipfs-blockstore"
 	cbor "github.com/ipfs/go-ipld-cbor"
 	logging "github.com/ipfs/go-log"
@@ -17,6 +19,7 @@ import (
 	"go.opencensus.io/trace"
 
 	"github.com/filecoin-project/go-filecoin/internal/pkg/block"
+	"github.com/filecoin-project/go-filecoin/internal/pkg/cborutil"
 	"github.com/filecoin-project/go-filecoin/internal/pkg/crypto"
 	"github.com/filecoin-project/go-filecoin/internal/pkg/metrics/tracing"
 	appstate "github.com/filecoin-project/go-filecoin/internal/pkg/state"
@@ -128,6 +131,20 @@ func (c *Expected) BlockTime() time.Duration {
 	return c.blockTime
 }
 
+// ReadOnly returns a copy of the protocol whose state transitions read the
+// stores of `c` but keep the state they compute in memory, leaving the stores
+// unchanged.
+func (c *Expected) ReadOnly() Protocol {
+	bs := &memOverlayBlockstore{
+		Blockstore: blockstore.NewBlockstore(datastore.NewMapDatastore()),
+		base:       c.bstore,
+	}
+	ro := *c
+	ro.bstore = bs
+	ro.cstore = cborutil.NewIpldStore(bs)
+	return &ro
+}
+
 // RunStateTransition applies the messages in a tipset to a state, and persists that new state.
 // It errors if the tipset was not mined according to the EC rules, or if any of the messages
 // in the tipset results in an error.
@@ -182,16 +199,16 @@ func (c *Expected) validateMining(ctx context.Context,
 
 		// confirm block state root matches parent state root
 		if !parentStateRoot.Equals(blk.StateRoot.Cid) {
-			return ErrStateRootMismatch
+			return Invalid(ErrStateRootMismatch)
 		}
 
 		// confirm block receipts match parent receipts
 		if !parentReceiptRoot.Equals(blk.MessageReceipts.Cid) {
-			return ErrReceiptRootMismatch
+			return Invalid(ErrReceiptRootMismatch)
 		}
 
 		if !parentWeight.Equals(blk.ParentWeight) {
-			return errors.Errorf("block %s has invalid parent weight %d", blk.Cid().String(), parentWeight)
+			return Invalid(errors.Errorf("block %s has invalid parent weight %d", blk.Cid().String(), parentWeight))
 		}
 		workerAddr, err := powerTable.WorkerAddr(ctx, blk.Miner)
 		if err != nil {
@@ -203,18 +220,18 @@ func (c *Expected) validateMining(ctx context.Context,
 		}
 		// Validate block signature
 		if err := crypto.ValidateSignature(blk.SignatureData(), workerSignerAddr, blk.BlockSig); err != nil {
-			return errors.Wrap(err, "block signature invalid")
+			return Invalid(errors.Wrap(err, "block signature invalid"))
 		}
 
 		// Verify that the BLS signature aggregate is correct
 		if err := sigValidator.ValidateBLSMessageAggregate(ctx, blsMsgs[i], blk.BLSAggregateSig); err != nil {
-			return errors.Wrapf(err, "bls message verification failed for block %s", blk.Cid())
+			return Invalid(errors.Wrapf(err, "bls message verification failed for block %s", blk.Cid()))
 		}
 
 		// Verify that all secp message signatures are correct
 		for i, msg := range secpMsgs[i] {
 			if err := sigValidator.ValidateMessageSignature(ctx, msg); err != nil {
-				return errors.Wrapf(err, "invalid signature for secp message %d in block %s", i, blk.Cid())
+				return Invalid(errors.Wrapf(err, "invalid signature for secp message %d in block %s", i, blk.Cid()))
 			}
 		}
 
@@ -223,7 +240,7 @@ func (c *Expected) validateMining(ctx context.Context,
 
 		// Verify EPoSt VRF proof ("PoSt randomness")
 		if err := c.VerifyEPoStVrfProof(ctx, blk.Parents, sampleEpoch, blk.Miner, workerSignerAddr, blk.EPoStInfo.VRFProof); err != nil {
-			return errors.Wrapf(err, "failed to verify EPoSt VRF proof (PoSt randomness) in block %s", blk.Cid())
+			return Invalid(errors.Wrapf(err, "failed to verify EPoSt VRF proof (PoSt randomness) in block %s", blk.Cid()))
 		}
 
 		// Verify no duplicate challenge indexes
@@ -231,7 +248,7 @@ func (c *Expected) validateMining(ctx context.Context,
 		for _, winner := range blk.EPoStInfo.Winners {
 			index := winner.SectorChallengeIndex
 			if _, dup := challengeIndexes[index]; dup {
-				return errors.Errorf("Duplicate partial ticket submitted, challenge idx: %d", index)
+				return Invalid(errors.Errorf("Duplicate partial ticket submitted, challenge idx: %d", index))
 			}
 			challengeIndexes[index] = struct{}{}
 		}
@@ -254,7 +271,7 @@ func (c *Expected) validateMining(ctx context.Context,
 			hasher.Bytes(candidate.PartialTicket)
 			// Dragons: must pass fault count value here, not zero.
 			if !c.ElectionValidator.CandidateWins(hasher.Hash(), sectorNum, 0, networkPower.Uint64(), uint64(sectorSize)) {
-				return errors.Errorf("partial ticket %d lost election", i)
+				return Invalid(errors.Errorf("partial ticket %d lost election", i))
 			}
 		}
 
@@ -270,12 +287,12 @@ func (c *Expected) validateMining(ctx context.Context,
 			return errors.Wrapf(err, "error checking PoSt")
 		}
 		if !valid {
-			return errors.Errorf("invalid PoSt")
+			return Invalid(errors.Errorf("invalid PoSt"))
 		}
 
 		// Ticket was correctly generated by miner
 		if err := c.IsValidTicket(ctx, blk.Parents, sampleEpoch, blk.Miner, workerSignerAddr, blk.Ticket); err != nil {
-			return errors.Wrapf(err, "invalid ticket: %s in block %s", blk.Ticket.String(), blk.Cid())
+			return Invalid(errors.Wrapf(err, "invalid ticket: %s in block %s", blk.Ticket.String(), blk.Cid()))
 		}
 	}
 	return nil
@@ -332,3 +349,34 @@ func AsPowerStateViewer(v *appstate.Viewer) PowerStateViewer {
 func (p *PowerStateViewer) StateView(root cid.Cid) PowerStateView {
 	return p.Viewer.StateView(root)
 }
+
+// memOverlayBlockstore keeps the blocks put into it in memory, and reads the
+// blocks it does not hold from a base blockstore.
+type memOverlayBlockstore struct {
+	blockstore.Blockstore
+	base blockstore.Blockstore
+}
+
+func (bs *memOverlayBlockstore) Has(c cid.Cid) (bool, error) {
+	has, err := bs.Blockstore.Has(c)
+	if err != nil || has {
+		return has, err
+	}
+	return bs.base.Has(c)
+}
+
+func (bs *memOverlayBlockstore) Get(c cid.Cid) (blocks.Block, error) {
+	blk, err := bs.Blockstore.Get(c)
+	if err == blockstore.ErrNotFound {
+		return bs.base.Get(c)
+	}
+	return blk, err
+}
+
+func (bs *memOverlayBlockstore) GetSize(c cid.Cid) (int, error) {
+	size, err := bs.Blockstore.GetSize(c)
+	if err == blockstore.ErrNotFound {
+		return bs.base.GetSize(c)
+	}
+	return size, err
+}
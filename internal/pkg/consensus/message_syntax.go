@@ -0,0 +1,119 @@
+package consensus
+
+import (
+	"github.com/filecoin-project/go-address"
+	"github.com/filecoin-project/specs-actors/actors/abi"
+	specsbig "github.com/filecoin-project/specs-actors/actors/abi/big"
+	"github.com/ipfs/go-cid"
+	"github.com/pkg/errors"
+
+	"github.com/filecoin-project/go-filecoin/internal/pkg/crypto"
+	"github.com/filecoin-project/go-filecoin/internal/pkg/types"
+)
+
+// MaxMessageParamsSize is the largest encoded params a message in a block may carry.
+const MaxMessageParamsSize = 64 << 10
+
+// MaxMethodNum is the largest method number a message in a block may call,
+// well above the method numbers of the builtin actors.
+const MaxMethodNum = abi.MethodNum(1 << 10)
+
+// MaxReceiptReturnSize is the largest return value a receipt in a block may carry.
+const MaxReceiptReturnSize = 64 << 10
+
+// Errors returned by block syntax validation. Validation wraps them with the
+// position of the offending message or receipt; use errors.Cause to recover them.
+var (
+	// ErrGenesisHasParents indicates a block at height zero links to parents.
+	ErrGenesisHasParents = errors.New("genesis block has parents")
+	// ErrNilStateRoot indicates a block that does not commit to a state root.
+	ErrNilStateRoot = errors.New("block has nil state root")
+	// ErrNilMessages indicates a block that does not commit to its messages.
+	ErrNilMessages = errors.New("block has nil messages")
+	// ErrNilMessageReceipts indicates a block that does not commit to message receipts.
+	ErrNilMessageReceipts = errors.New("block has nil message receipts")
+	// ErrInvalidFromAddress indicates a message sender that is undefined or not an ID or public key address.
+	ErrInvalidFromAddress = errors.New("message has invalid from address")
+	// ErrInvalidToAddress indicates a message recipient that is undefined or of unknown protocol.
+	ErrInvalidToAddress = errors.New("message has invalid to address")
+	// ErrNegativeMessageValue indicates a message transferring a negative value.
+	ErrNegativeMessageValue = errors.New("message has negative value")
+	// ErrNegativeGasPrice indicates a message with a negative gas price.
+	ErrNegativeGasPrice = errors.New("message has negative gas price")
+	// ErrNegativeGasLimit indicates a message with a negative gas limit.
+	ErrNegativeGasLimit = errors.New("message has negative gas limit")
+	// ErrGasLimitAboveBlockLimit indicates a message whose gas limit alone exceeds the block gas limit.
+	ErrGasLimitAboveBlockLimit = errors.New("message gas limit above block gas limit")
+	// ErrTotalGasAboveBlockLimit indicates a block whose secp and BLS message gas limits sum above the block gas limit.
+	ErrTotalGasAboveBlockLimit = errors.New("messages gas limit above block gas limit")
+	// ErrInvalidMethodNum indicates a message with a negative method number or one above MaxMethodNum.
+	ErrInvalidMethodNum = errors.New("message has invalid method number")
+	// ErrParamsTooLarge indicates a message with params larger than MaxMessageParamsSize.
+	ErrParamsTooLarge = errors.New("message params too large")
+	// ErrInvalidSignatureType indicates a signed message whose signature is not a secp256k1 signature.
+	ErrInvalidSignatureType = errors.New("message signature has invalid type")
+	// ErrEmptySignature indicates a signed message with no signature data.
+	ErrEmptySignature = errors.New("message signature is empty")
+	// ErrReceiptNegativeGas indicates a receipt with negative gas used.
+	ErrReceiptNegativeGas = errors.New("receipt has negative gas used")
+	// ErrReceiptGasAboveBlockLimit indicates receipts whose gas used sums above the block gas limit.
+	ErrReceiptGasAboveBlockLimit = errors.New("receipts gas used above block gas limit")
+	// ErrReceiptReturnTooLarge indicates a receipt with a return value larger than MaxReceiptReturnSize.
+	ErrReceiptReturnTooLarge = errors.New("receipt return value too large")
+)
+
+// validateMessageSyntax checks the fields of a message that can be validated without state.
+func validateMessageSyntax(msg *types.UnsignedMessage) error {
+	switch msg.From.Protocol() {
+	case address.ID, address.SECP256K1, address.BLS:
+	default:
+		return ErrInvalidFromAddress
+	}
+	switch msg.To.Protocol() {
+	case address.ID, address.SECP256K1, address.Actor, address.BLS:
+	default:
+		return ErrInvalidToAddress
+	}
+	if msg.Value.LessThan(specsbig.Zero()) {
+		return ErrNegativeMessageValue
+	}
+	if msg.GasPrice.LessThan(specsbig.Zero()) {
+		return ErrNegativeGasPrice
+	}
+	if msg.GasLimit < 0 {
+		return ErrNegativeGasLimit
+	}
+	if msg.GasLimit > types.BlockGasLimit {
+		return ErrGasLimitAboveBlockLimit
+	}
+	if msg.Method < 0 || msg.Method > MaxMethodNum {
+		return ErrInvalidMethodNum
+	}
+	if len(msg.Params) > MaxMessageParamsSize {
+		return ErrParamsTooLarge
+	}
+	return nil
+}
+
+// validateSignatureSyntax checks that a message included as secp signed carries a secp signature.
+func validateSignatureSyntax(smsg *types.SignedMessage) error {
+	if smsg.Signature.Type != crypto.SigTypeSecp256k1 {
+		return ErrInvalidSignatureType
+	}
+	if len(smsg.Signature.Data) == 0 {
+		return ErrEmptySignature
+	}
+	return nil
+}
+
+type cidable interface {
+	Cid() (cid.Cid, error)
+}
+
+func wrapMessageSyntaxError(err error, index int, msg cidable) error {
+	c, cidErr := msg.Cid()
+	if cidErr != nil {
+		return Invalid(errors.Wrapf(err, "message %d", index))
+	}
+	return Invalid(errors.Wrapf(err, "message %d (cid %s)", index, c))
+}
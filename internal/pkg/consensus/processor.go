@@ -3,13 +3,16 @@ package consensus
 import (
 	"context"
 
+	"github.com/filecoin-project/go-address"
 	"github.com/filecoin-project/specs-actors/actors/abi"
 	"github.com/filecoin-project/specs-actors/actors/crypto"
 	"go.opencensus.io/trace"
 
 	"github.com/filecoin-project/go-filecoin/internal/pkg/block"
 	"github.com/filecoin-project/go-filecoin/internal/pkg/metrics/tracing"
+	"github.com/filecoin-project/go-filecoin/internal/pkg/types"
 	"github.com/filecoin-project/go-filecoin/internal/pkg/vm"
+	"github.com/filecoin-project/go-filecoin/internal/pkg/vm/actor"
 	"github.com/filecoin-project/go-filecoin/internal/pkg/vm/state"
 )
 
@@ -34,24 +37,29 @@ type ChainRandomness interface {
 
 // DefaultProcessor handles all block processing.
 type DefaultProcessor struct {
-	actors   vm.ActorCodeLoader
-	syscalls vm.SyscallsImpl
-	rnd      ChainRandomness
+	actors     vm.ActorCodeLoader
+	syscalls   vm.SyscallsImpl
+	rnd        ChainRandomness
+	pricelists *vm.PricelistSchedule
 }
 
 var _ Processor = (*DefaultProcessor)(nil)
 
-// NewDefaultProcessor creates a default processor from the given state tree and vms.
-func NewDefaultProcessor(syscalls vm.SyscallsImpl, rnd ChainRandomness) *DefaultProcessor {
-	return NewConfiguredProcessor(vm.DefaultActors, syscalls, rnd)
+// NewDefaultProcessor creates a default processor from the given state tree and vms,
+// charging gas from the pricelists of `pricelists`.
+func NewDefaultProcessor(syscalls vm.SyscallsImpl, rnd ChainRandomness, pricelists *vm.PricelistSchedule) *DefaultProcessor {
+	p := NewConfiguredProcessor(vm.DefaultActors, syscalls, rnd)
+	p.pricelists = pricelists
+	return p
 }
 
 // NewConfiguredProcessor creates a default processor with custom validation and rewards.
 func NewConfiguredProcessor(actors vm.ActorCodeLoader, syscalls vm.SyscallsImpl, rnd ChainRandomness) *DefaultProcessor {
 	return &DefaultProcessor{
-		actors:   actors,
-		syscalls: syscalls,
-		rnd:      rnd,
+		actors:     actors,
+		syscalls:   syscalls,
+		rnd:        rnd,
+		pricelists: vm.DefaultPricelistSchedule(),
 	}
 }
 
@@ -61,23 +69,109 @@ func (p *DefaultProcessor) ProcessTipSet(ctx context.Context, st state.Tree, vms
 	span.AddAttributes(trace.StringAttribute("tipset", ts.String()))
 	defer tracing.AddErrorEndSpan(ctx, span, &err)
 
-	epoch, err := ts.Height()
+	epoch, rnd, err := p.tipSetContext(ts)
 	if err != nil {
 		return nil, err
 	}
+	v := vm.NewVM(st, &vms, p.syscalls, p.pricelists)
 
-	parent, err := ts.Parents()
+	return v.ApplyTipSetMessages(msgs, epoch, rnd)
+}
+
+// TraceTipSet computes the same state transition as ProcessTipSet, also
+// returning the execution trace of each message in the order of the receipts.
+func (p *DefaultProcessor) TraceTipSet(ctx context.Context, st state.Tree, vms vm.Storage, ts block.TipSet, msgs []vm.BlockMessagesInfo) (results []vm.MessageReceipt, traces []*vm.ExecutionTrace, err error) {
+	ctx, span := trace.StartSpan(ctx, "DefaultProcessor.TraceTipSet")
+	span.AddAttributes(trace.StringAttribute("tipset", ts.String()))
+	defer tracing.AddErrorEndSpan(ctx, span, &err)
+
+	epoch, rnd, err := p.tipSetContext(ts)
 	if err != nil {
-		return nil, err
+		return nil, nil, err
 	}
+	v := vm.NewTracingVM(st, &vms, p.syscalls, p.pricelists)
 
+	results, err = v.ApplyTipSetMessages(msgs, epoch, rnd)
+	if err != nil {
+		return nil, nil, err
+	}
+	return results, v.Traces(), nil
+}
+
+// PreviewMessage applies a message on `st`, the state of `head`, as if it
+// were included in the next tipset, and returns its receipt and execution
+// trace. The resulting state is not committed.
+func (p *DefaultProcessor) PreviewMessage(ctx context.Context, st state.Tree, vms vm.Storage, head block.TipSet, msg *types.UnsignedMessage) (*vm.MessageReceipt, *vm.ExecutionTrace, error) {
+	height, err := head.Height()
+	if err != nil {
+		return nil, nil, err
+	}
 	rnd := headRandomness{
 		chain: p.rnd,
-		head:  parent,
+		head:  head.Key(),
+	}
+	v := vm.NewTracingVM(st, &vms, p.syscalls, p.pricelists)
+
+	receipt, msgTrace := v.ApplyMessage(msg, msg.OnChainLen(), height+1, &rnd)
+	return &receipt, msgTrace, nil
+}
+
+// MessageSimulation applies messages one after another on the state of a
+// tipset, each on the state left by those before it, as if they were included
+// in the next tipset. The resulting state is not committed.
+type MessageSimulation struct {
+	vm    vm.TracingInterpreter
+	epoch abi.ChainEpoch
+	rnd   *headRandomness
+}
+
+// NewMessageSimulation starts a simulation of messages on `st`, the state of
+// `head`.
+func (p *DefaultProcessor) NewMessageSimulation(st state.Tree, vms vm.Storage, head block.TipSet) (*MessageSimulation, error) {
+	height, err := head.Height()
+	if err != nil {
+		return nil, err
 	}
-	v := vm.NewVM(st, &vms, p.syscalls)
+	return &MessageSimulation{
+		vm:    vm.NewTracingVM(st, &vms, p.syscalls, p.pricelists),
+		epoch: height + 1,
+		rnd: &headRandomness{
+			chain: p.rnd,
+			head:  head.Key(),
+		},
+	}, nil
+}
 
-	return v.ApplyTipSetMessages(msgs, epoch, &rnd)
+// Actor returns the actor at `addr` in the simulated state, and whether there
+// is one.
+func (s *MessageSimulation) Actor(addr address.Address) (*actor.Actor, bool, error) {
+	return s.vm.ResolveActor(addr)
+}
+
+// Apply applies `msg` on the simulated state and returns its receipt.
+func (s *MessageSimulation) Apply(msg *types.UnsignedMessage, onChainMsgSize int) vm.MessageReceipt {
+	// The vm normalizes the addresses of the message it applies.
+	applied := *msg
+	receipt, _ := s.vm.ApplyMessage(&applied, onChainMsgSize, s.epoch, s.rnd)
+	return receipt
+}
+
+// tipSetContext returns the epoch and randomness the messages of `ts` are applied with.
+func (p *DefaultProcessor) tipSetContext(ts block.TipSet) (abi.ChainEpoch, *headRandomness, error) {
+	epoch, err := ts.Height()
+	if err != nil {
+		return 0, nil, err
+	}
+
+	parent, err := ts.Parents()
+	if err != nil {
+		return 0, nil, err
+	}
+
+	return epoch, &headRandomness{
+		chain: p.rnd,
+		head:  parent,
+	}, nil
 }
 
 // A chain randomness source with a fixed head tipset key.
@@ -33,4 +33,8 @@ type Protocol interface {
 
 	// BlockTime returns the block time used by the consensus protocol.
 	BlockTime() time.Duration
+
+	// ReadOnly returns the protocol running state transitions without
+	// persisting the states they compute.
+	ReadOnly() Protocol
 }
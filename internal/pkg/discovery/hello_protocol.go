@@ -65,6 +65,10 @@ type HelloProtocolHandler struct {
 	getHeaviestTipSet getTipSetFunc
 
 	networkName string
+
+	// scorer is told about peers with a different genesis block, and bans
+	// peers. It may be nil.
+	scorer *PeerScorer
 }
 
 type peerDiscoveredCallback func(ci *block.ChainInfo)
@@ -72,12 +76,13 @@ type peerDiscoveredCallback func(ci *block.ChainInfo)
 type getTipSetFunc func() (block.TipSet, error)
 
 // NewHelloProtocolHandler creates a new instance of the hello protocol `Handler` and registers it to
-// the given `host.Host`.
-func NewHelloProtocolHandler(h host.Host, gen cid.Cid, networkName string) *HelloProtocolHandler {
+// the given `host.Host`. Peers are scored by `scorer` unless it is nil.
+func NewHelloProtocolHandler(h host.Host, gen cid.Cid, networkName string, scorer *PeerScorer) *HelloProtocolHandler {
 	return &HelloProtocolHandler{
 		host:        h,
 		genesis:     gen,
 		networkName: networkName,
+		scorer:      scorer,
 	}
 }
 
@@ -110,6 +115,11 @@ func (h *HelloProtocolHandler) handleNewStream(s net.Stream) {
 
 	// process the hello message
 	from := s.Conn().RemotePeer()
+	if h.scorer != nil && h.scorer.IsBanned(from) {
+		log.Debugf("disconnecting from banned peer: %s", from)
+		_ = s.Conn().Close()
+		return
+	}
 	ci, err := h.processHelloMessage(from, hello)
 	switch {
 	// no error
@@ -120,6 +130,9 @@ func (h *HelloProtocolHandler) handleNewStream(s net.Stream) {
 	case err == ErrBadGenesis:
 		log.Debugf("genesis cid: %s does not match: %s, disconnecting from peer: %s", &hello.GenesisHash, h.genesis, from)
 		genesisErrCt.Inc(context.Background(), 1)
+		if h.scorer != nil {
+			h.scorer.RecordBadGenesis(from)
+		}
 		_ = s.Conn().Close()
 		return
 	default:
@@ -0,0 +1,257 @@
+package discovery
+
+import (
+	"sort"
+	"sync"
+	"time"
+
+	logging "github.com/ipfs/go-log"
+	"github.com/libp2p/go-libp2p-core/network"
+	"github.com/libp2p/go-libp2p-core/peer"
+
+	"github.com/filecoin-project/go-filecoin/internal/pkg/clock"
+)
+
+var logPeerScorer = logging.Logger("peer-scorer")
+
+const (
+	// maxScore bounds the credit a peer can build up with good behaviour, so
+	// that a long lived peer that turns bad is banned quickly.
+	maxScore = 20
+
+	invalidBlockPenalty  = 50
+	badGenesisPenalty    = 100
+	gossipFailurePenalty = 10
+	failedFetchPenalty   = 10
+	slowFetchPenalty     = 5
+	goodFetchReward      = 1
+
+	// maxScoredPeers bounds the number of peers scored. When it is reached
+	// the least telling score is forgotten to score a new peer.
+	maxScoredPeers = 4096
+)
+
+// PeerScore is the score of a peer.
+type PeerScore struct {
+	Peer  peer.ID
+	Score int
+	// InvalidBlocks, FailedFetches, SlowFetches, BadGenesis and GossipFailures
+	// count the offenses of the peer since its score was last reset.
+	InvalidBlocks  int
+	FailedFetches  int
+	SlowFetches    int
+	BadGenesis     int
+	GossipFailures int
+	// BannedUntil is the end of the peer's ban, zero if it is not banned.
+	BannedUntil time.Time
+}
+
+// PeerScorer scores peers by their behaviour when syncing the chain, and bans
+// peers whose score falls to a threshold. Its methods are thread safe.
+//
+// Scores start at zero. Serving invalid blocks, failing or stalling graphsync
+// requests, saying hello with a different genesis block and gossiping invalid
+// blocks lower the score; good graphsync responses raise it. A banned peer is
+// disconnected, refused new connections once RegisterConnect is called, and
+// its score is reset when the ban ends.
+type PeerScorer struct {
+	mu sync.Mutex
+
+	self        peer.ID
+	clock       clock.Clock
+	threshold   int
+	banDuration time.Duration
+	disconnect  func(peer.ID)
+
+	scores map[peer.ID]*PeerScore
+}
+
+// NewPeerScorer creates a peer scorer banning peers whose score falls to
+// `threshold` for `banDuration`. `disconnect` is called to disconnect a peer
+// when it is banned. The scorer never scores `self`.
+func NewPeerScorer(self peer.ID, clk clock.Clock, threshold int, banDuration time.Duration, disconnect func(peer.ID)) *PeerScorer {
+	return &PeerScorer{
+		self:        self,
+		clock:       clk,
+		threshold:   threshold,
+		banDuration: banDuration,
+		disconnect:  disconnect,
+		scores:      make(map[peer.ID]*PeerScore),
+	}
+}
+
+// RecordInvalidBlock records that a peer sent a chain that failed validation.
+func (s *PeerScorer) RecordInvalidBlock(p peer.ID) {
+	s.record(p, -invalidBlockPenalty, func(ps *PeerScore) { ps.InvalidBlocks++ })
+}
+
+// RecordBadGenesis records that a peer said hello with a different genesis block.
+func (s *PeerScorer) RecordBadGenesis(p peer.ID) {
+	s.record(p, -badGenesisPenalty, func(ps *PeerScore) { ps.BadGenesis++ })
+}
+
+// RecordGossipFailure records that a peer relayed a block failing validation
+// on pubsub.
+func (s *PeerScorer) RecordGossipFailure(p peer.ID) {
+	s.record(p, -gossipFailurePenalty, func(ps *PeerScore) { ps.GossipFailures++ })
+}
+
+// RecordFailedFetch records that a graphsync request to a peer failed.
+func (s *PeerScorer) RecordFailedFetch(p peer.ID) {
+	s.record(p, -failedFetchPenalty, func(ps *PeerScore) { ps.FailedFetches++ })
+}
+
+// RecordSlowFetch records that a graphsync request to a peer stopped making
+// progress.
+func (s *PeerScorer) RecordSlowFetch(p peer.ID) {
+	s.record(p, -slowFetchPenalty, func(ps *PeerScore) { ps.SlowFetches++ })
+}
+
+// RecordGoodFetch records that a graphsync request to a peer succeeded.
+func (s *PeerScorer) RecordGoodFetch(p peer.ID) {
+	s.record(p, goodFetchReward, func(*PeerScore) {})
+}
+
+// Score returns the score of a peer, zero for unknown peers.
+func (s *PeerScorer) Score(p peer.ID) int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	ps, ok := s.scores[p]
+	if !ok || s.expireBan(ps) {
+		return 0
+	}
+	return ps.Score
+}
+
+// IsBanned returns true if a peer is banned.
+func (s *PeerScorer) IsBanned(p peer.ID) bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	ps, ok := s.scores[p]
+	if !ok || s.expireBan(ps) {
+		return false
+	}
+	return !ps.BannedUntil.IsZero()
+}
+
+// Scores returns the scores of all scored peers, ordered from lowest score.
+func (s *PeerScorer) Scores() []PeerScore {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	out := make([]PeerScore, 0, len(s.scores))
+	for _, ps := range s.scores {
+		s.expireBan(ps)
+		out = append(out, *ps)
+	}
+	sort.Slice(out, func(i, j int) bool {
+		if out[i].Score != out[j].Score {
+			return out[i].Score < out[j].Score
+		}
+		return out[i].Peer < out[j].Peer
+	})
+	return out
+}
+
+func (s *PeerScorer) record(p peer.ID, delta int, count func(*PeerScore)) {
+	if p == s.self || p == "" {
+		return
+	}
+
+	s.mu.Lock()
+	ps, ok := s.scores[p]
+	if !ok {
+		if len(s.scores) >= maxScoredPeers {
+			s.evict()
+		}
+		ps = &PeerScore{Peer: p}
+		s.scores[p] = ps
+	}
+	s.expireBan(ps)
+	if !ps.BannedUntil.IsZero() {
+		// Already banned, nothing it does counts until the ban ends.
+		s.mu.Unlock()
+		return
+	}
+	count(ps)
+	ps.Score += delta
+	if ps.Score > maxScore {
+		ps.Score = maxScore
+	}
+	banned := ps.Score <= s.threshold
+	if banned {
+		ps.BannedUntil = s.clock.Now().Add(s.banDuration)
+	}
+	until := ps.BannedUntil
+	s.mu.Unlock()
+
+	if banned {
+		logPeerScorer.Warnw("Banning peer", "peer", p.Pretty(), "until", until)
+		if s.disconnect != nil {
+			s.disconnect(p)
+		}
+	}
+}
+
+// RegisterConnect registers the closing of connections from banned peers as a
+// libp2p "Connected" network event callback, so that banned peers cannot
+// reach any protocol of the node until their ban ends.
+func (s *PeerScorer) RegisterConnect(ntwk network.Network) {
+	notifee := &network.NotifyBundle{}
+	notifee.ConnectedF = func(_ network.Network, conn network.Conn) {
+		p := conn.RemotePeer()
+		if !s.IsBanned(p) {
+			return
+		}
+		logPeerScorer.Infow("Refusing connection from banned peer", "peer", p.Pretty())
+		go func() {
+			if err := conn.Close(); err != nil {
+				logPeerScorer.Debugf("failed to close connection from banned peer %s: %s", p, err)
+			}
+		}()
+	}
+	ntwk.Notify(notifee)
+}
+
+// evict forgets the score telling the least about its peer: the unbanned
+// score closest to zero or, if every peer is banned, the ban ending first.
+// The caller holds the lock.
+func (s *PeerScorer) evict() {
+	var victim *PeerScore
+	for _, ps := range s.scores {
+		s.expireBan(ps)
+		switch {
+		case victim == nil:
+			victim = ps
+		case ps.BannedUntil.IsZero() != victim.BannedUntil.IsZero():
+			if ps.BannedUntil.IsZero() {
+				victim = ps
+			}
+		case ps.BannedUntil.IsZero():
+			if abs(ps.Score) < abs(victim.Score) {
+				victim = ps
+			}
+		case ps.BannedUntil.Before(victim.BannedUntil):
+			victim = ps
+		}
+	}
+	if victim != nil {
+		delete(s.scores, victim.Peer)
+	}
+}
+
+func abs(n int) int {
+	if n < 0 {
+		return -n
+	}
+	return n
+}
+
+// expireBan resets the score of a peer whose ban is over, returning true if it
+// did. The caller holds the lock.
+func (s *PeerScorer) expireBan(ps *PeerScore) bool {
+	if ps.BannedUntil.IsZero() || s.clock.Now().Before(ps.BannedUntil) {
+		return false
+	}
+	*ps = PeerScore{Peer: ps.Peer}
+	return true
+}
@@ -0,0 +1,142 @@
+package discovery_test
+
+import (
+	"testing"
+	"time"
+
+	"github.com/libp2p/go-libp2p-core/peer"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/filecoin-project/go-filecoin/internal/pkg/block"
+	"github.com/filecoin-project/go-filecoin/internal/pkg/discovery"
+	th "github.com/filecoin-project/go-filecoin/internal/pkg/testhelpers"
+	tf "github.com/filecoin-project/go-filecoin/internal/pkg/testhelpers/testflags"
+	"github.com/filecoin-project/go-filecoin/internal/pkg/types"
+)
+
+func TestPeerScorer(t *testing.T) {
+	tf.UnitTest(t)
+
+	self := th.RequireIntPeerID(t, 0)
+	pid1 := th.RequireIntPeerID(t, 1)
+	pid2 := th.RequireIntPeerID(t, 2)
+
+	newScorer := func() (*discovery.PeerScorer, th.FakeClock, *[]peer.ID) {
+		clk := th.NewFakeClock(time.Unix(1234567890, 0))
+		var disconnected []peer.ID
+		scorer := discovery.NewPeerScorer(self, clk, -100, time.Hour, func(p peer.ID) {
+			disconnected = append(disconnected, p)
+		})
+		return scorer, clk, &disconnected
+	}
+
+	t.Run("offenses lower the score", func(t *testing.T) {
+		scorer, _, _ := newScorer()
+		scorer.RecordFailedFetch(pid1)
+		scorer.RecordSlowFetch(pid1)
+		scorer.RecordGossipFailure(pid1)
+		scorer.RecordGoodFetch(pid2)
+
+		assert.Equal(t, -25, scorer.Score(pid1))
+		assert.Equal(t, 1, scorer.Score(pid2))
+		scores := scorer.Scores()
+		require.Len(t, scores, 2)
+		assert.Equal(t, pid1, scores[0].Peer)
+		assert.Equal(t, 1, scores[0].FailedFetches)
+		assert.Equal(t, 1, scores[0].SlowFetches)
+		assert.Equal(t, 1, scores[0].GossipFailures)
+	})
+
+	t.Run("good behaviour is bounded", func(t *testing.T) {
+		scorer, _, _ := newScorer()
+		for i := 0; i < 100; i++ {
+			scorer.RecordGoodFetch(pid1)
+		}
+		assert.Equal(t, 20, scorer.Score(pid1))
+	})
+
+	t.Run("self is not scored", func(t *testing.T) {
+		scorer, _, _ := newScorer()
+		scorer.RecordInvalidBlock(self)
+		assert.Equal(t, 0, scorer.Score(self))
+		assert.Empty(t, scorer.Scores())
+	})
+
+	t.Run("bans and disconnects peers at the threshold until the ban ends", func(t *testing.T) {
+		scorer, clk, disconnected := newScorer()
+		scorer.RecordInvalidBlock(pid1)
+		assert.False(t, scorer.IsBanned(pid1))
+		scorer.RecordInvalidBlock(pid1)
+		assert.True(t, scorer.IsBanned(pid1))
+		assert.Equal(t, []peer.ID{pid1}, *disconnected)
+
+		// Offenses of banned peers are ignored.
+		scorer.RecordBadGenesis(pid1)
+		assert.Equal(t, []peer.ID{pid1}, *disconnected)
+
+		clk.Advance(time.Hour)
+		assert.False(t, scorer.IsBanned(pid1))
+		assert.Equal(t, 0, scorer.Score(pid1))
+	})
+
+	t.Run("a bad genesis bans immediately", func(t *testing.T) {
+		scorer, _, _ := newScorer()
+		scorer.RecordBadGenesis(pid1)
+		assert.True(t, scorer.IsBanned(pid1))
+	})
+
+	t.Run("the least telling score is forgotten when too many peers are scored", func(t *testing.T) {
+		scorer, _, _ := newScorer()
+		scorer.RecordBadGenesis(pid1)
+		scorer.RecordGoodFetch(pid2)
+		for i := int64(3); i < 4096+1; i++ {
+			scorer.RecordFailedFetch(th.RequireIntPeerID(t, i))
+		}
+		require.Len(t, scorer.Scores(), 4096)
+
+		newPeer := th.RequireIntPeerID(t, 4096+1)
+		scorer.RecordFailedFetch(newPeer)
+		assert.Len(t, scorer.Scores(), 4096)
+		assert.Equal(t, -10, scorer.Score(newPeer))
+		assert.True(t, scorer.IsBanned(pid1))
+		for _, ps := range scorer.Scores() {
+			assert.NotEqual(t, pid2, ps.Peer)
+		}
+	})
+}
+
+func TestPeerTrackerUsesScores(t *testing.T) {
+	tf.UnitTest(t)
+
+	pid0 := th.RequireIntPeerID(t, 0)
+	pid1 := th.RequireIntPeerID(t, 1)
+	pid2 := th.RequireIntPeerID(t, 2)
+	pid3 := th.RequireIntPeerID(t, 3)
+
+	scorer := discovery.NewPeerScorer(pid0, th.NewFakeClock(time.Unix(1234567890, 0)), -100, time.Hour, nil)
+	tracker := discovery.NewPeerTracker(pid0, scorer, pid1, pid2, pid3)
+
+	ci1 := block.NewChainInfo(pid1, pid1, block.NewTipSetKey(types.CidFromString(t, "somecid1")), 10)
+	ci2 := block.NewChainInfo(pid2, pid2, block.NewTipSetKey(types.CidFromString(t, "somecid2")), 10)
+	ci3 := block.NewChainInfo(pid3, pid3, block.NewTipSetKey(types.CidFromString(t, "somecid3")), 12)
+	tracker.Track(ci1)
+	tracker.Track(ci2)
+	tracker.Track(ci3)
+
+	scorer.RecordFailedFetch(pid1)
+	scorer.RecordGoodFetch(pid2)
+	scorer.RecordBadGenesis(pid3)
+
+	// Banned peers are left out, the best scored peer is listed first.
+	assert.Equal(t, []*block.ChainInfo{ci2, ci1}, tracker.List())
+
+	// The best scored of the highest heads is selected.
+	head, err := tracker.SelectHead()
+	require.NoError(t, err)
+	assert.Equal(t, ci2.Head, head.Head)
+
+	// Banned peers are not tracked again.
+	tracker.Track(ci3)
+	assert.Len(t, tracker.List(), 2)
+}
@@ -26,10 +26,14 @@ type PeerTracker struct {
 	// peers maps peer.IDs to info about their chains
 	peers   map[peer.ID]*block.ChainInfo
 	trusted map[peer.ID]struct{}
+
+	// scorer scores the peers, nil if peers are not scored
+	scorer *PeerScorer
 }
 
-// NewPeerTracker creates a peer tracker.
-func NewPeerTracker(self peer.ID, trust ...peer.ID) *PeerTracker {
+// NewPeerTracker creates a peer tracker. Peers are ranked by their score in
+// `scorer`, and banned peers ignored, unless `scorer` is nil.
+func NewPeerTracker(self peer.ID, scorer *PeerScorer, trust ...peer.ID) *PeerTracker {
 	trustedSet := make(map[peer.ID]struct{}, len(trust))
 	for _, t := range trust {
 		trustedSet[t] = struct{}{}
@@ -38,22 +42,34 @@ func NewPeerTracker(self peer.ID, trust ...peer.ID) *PeerTracker {
 		peers:   make(map[peer.ID]*block.ChainInfo),
 		trusted: trustedSet,
 		self:    self,
+		scorer:  scorer,
 	}
 }
 
-// SelectHead returns the chain info from trusted peers with the greatest height.
+// SelectHead returns the chain info from trusted peers with the greatest height,
+// preferring the peer with the best score among peers at the same height.
 // An error is returned if no peers are in the tracker.
 func (tracker *PeerTracker) SelectHead() (*block.ChainInfo, error) {
 	heads := tracker.listTrusted()
 	if len(heads) == 0 {
 		return nil, errors.New("no peers tracked")
 	}
-	sort.Slice(heads, func(i, j int) bool { return heads[i].Height > heads[j].Height })
+	sort.SliceStable(heads, func(i, j int) bool {
+		if heads[i].Height != heads[j].Height {
+			return heads[i].Height > heads[j].Height
+		}
+		return tracker.score(heads[i].Sender) > tracker.score(heads[j].Sender)
+	})
 	return heads[0], nil
 }
 
-// Track adds information about a given peer.ID
+// Track adds information about a given peer.ID. Banned peers are not tracked.
 func (tracker *PeerTracker) Track(ci *block.ChainInfo) {
+	if tracker.banned(ci.Sender) {
+		logPeerTracker.Infow("Ignoring banned peer", "peer", ci.Sender.Pretty())
+		return
+	}
+
 	tracker.mu.Lock()
 	defer tracker.mu.Unlock()
 
@@ -68,20 +84,26 @@ func (tracker *PeerTracker) Self() peer.ID {
 	return tracker.self
 }
 
-// List returns the chain info of the currently tracked peers (both trusted and untrusted).
+// List returns the chain info of the currently tracked peers (both trusted and untrusted),
+// best scored first, leaving out peers banned since they were tracked.
 // The info tracked by the tracker can change arbitrarily after this is called -- there is no
 // guarantee that the peers returned will be tracked when they are used by the caller and no
 // guarantee that the chain info is up to date.
 func (tracker *PeerTracker) List() []*block.ChainInfo {
 	tracker.mu.Lock()
-	defer tracker.mu.Unlock()
-
 	var tracked []*block.ChainInfo
 	for _, ci := range tracker.peers {
 		tracked = append(tracked, ci)
 	}
-	out := make([]*block.ChainInfo, len(tracked))
-	copy(out, tracked)
+	tracker.mu.Unlock()
+
+	out := make([]*block.ChainInfo, 0, len(tracked))
+	for _, ci := range tracked {
+		if !tracker.banned(ci.Sender) {
+			out = append(out, ci)
+		}
+	}
+	sort.SliceStable(out, func(i, j int) bool { return tracker.score(out[i].Sender) > tracker.score(out[j].Sender) })
 	return out
 }
 
@@ -122,7 +144,20 @@ func (tracker *PeerTracker) trustedPeers() []peer.ID {
 	return peers
 }
 
-// listTrusted returns the chain info of the trusted tracked peers. The info tracked by the tracker can
+// score returns the score of a peer, zero if peers are not scored.
+func (tracker *PeerTracker) score(pid peer.ID) int {
+	if tracker.scorer == nil {
+		return 0
+	}
+	return tracker.scorer.Score(pid)
+}
+
+// banned returns true if a peer is banned.
+func (tracker *PeerTracker) banned(pid peer.ID) bool {
+	return tracker.scorer != nil && tracker.scorer.IsBanned(pid)
+}
+
+// listTrusted returns the chain info of the trusted tracked peers, leaving out banned peers. The info tracked by the tracker can
 // change arbitrarily after this is called -- there is no guarantee that the peers returned will be
 // tracked when they are used by the caller and no guarantee that the chain info is up to date.
 func (tracker *PeerTracker) listTrusted() []*block.ChainInfo {
@@ -131,7 +166,7 @@ func (tracker *PeerTracker) listTrusted() []*block.ChainInfo {
 
 	var tracked []*block.ChainInfo
 	for p, ci := range tracker.peers {
-		if _, trusted := tracker.trusted[p]; trusted {
+		if _, trusted := tracker.trusted[p]; trusted && !tracker.banned(p) {
 			tracked = append(tracked, ci)
 		}
 	}
@@ -20,7 +20,7 @@ import (
 func TestPeerTrackerTracks(t *testing.T) {
 	tf.UnitTest(t)
 
-	tracker := discovery.NewPeerTracker(peer.ID(""))
+	tracker := discovery.NewPeerTracker(peer.ID(""), nil)
 	pid0 := th.RequireIntPeerID(t, 0)
 	pid1 := th.RequireIntPeerID(t, 1)
 	pid3 := th.RequireIntPeerID(t, 3)
@@ -58,7 +58,7 @@ func TestPeerTrackerSelectHead(t *testing.T) {
 	ci3 := block.NewChainInfo(pid3, pid3, block.NewTipSetKey(types.CidFromString(t, "somecid3")), 9)
 
 	// trusting pid2 and pid3
-	tracker := discovery.NewPeerTracker(pid2, pid3)
+	tracker := discovery.NewPeerTracker(pid2, nil, pid3)
 	tracker.Track(ci0)
 	tracker.Track(ci1)
 	tracker.Track(ci2)
@@ -73,7 +73,7 @@ func TestPeerTrackerSelectHead(t *testing.T) {
 func TestPeerTrackerRemove(t *testing.T) {
 	tf.UnitTest(t)
 
-	tracker := discovery.NewPeerTracker(peer.ID(""))
+	tracker := discovery.NewPeerTracker(peer.ID(""), nil)
 	pid0 := th.RequireIntPeerID(t, 0)
 	pid1 := th.RequireIntPeerID(t, 1)
 	pid3 := th.RequireIntPeerID(t, 3)
@@ -123,7 +123,7 @@ func TestPeerTrackerNetworkDisconnect(t *testing.T) {
 	// self is the tracking node
 	// self tracks peers a and b
 	// self does not track peer c
-	tracker := discovery.NewPeerTracker(peer.ID(""))
+	tracker := discovery.NewPeerTracker(peer.ID(""), nil)
 	tracker.Track(aCI)
 	tracker.Track(bCI)
 
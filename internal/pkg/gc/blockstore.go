@@ -0,0 +1,105 @@
+package gc
+
+import (
+	"sync"
+
+	blocks "github.com/ipfs/go-block-format"
+	"github.com/ipfs/go-cid"
+	blockstore "github.com/ipfs/go-ipfs-blockstore"
+	"github.com/pkg/errors"
+)
+
+// ErrInProgress is returned when a collection is started while another is
+// running.
+var ErrInProgress = errors.New("garbage collection is already in progress")
+
+// Blockstore wraps a blockstore so that it can be garbage collected while in
+// use. Blocks written while a collection runs are recorded and kept by its
+// sweep, whether or not they were marked.
+type Blockstore struct {
+	blockstore.Blockstore
+
+	lk sync.Mutex
+	// written holds the blocks written during the current collection, by
+	// multihash, and is nil when no collection is running.
+	written map[string]cid.Cid
+}
+
+// NewBlockstore wraps `bs` for garbage collection.
+func NewBlockstore(bs blockstore.Blockstore) *Blockstore {
+	return &Blockstore{Blockstore: bs}
+}
+
+// Put stores a block.
+func (bs *Blockstore) Put(b blocks.Block) error {
+	bs.record(b.Cid())
+	return bs.Blockstore.Put(b)
+}
+
+// PutMany stores several blocks.
+func (bs *Blockstore) PutMany(bls []blocks.Block) error {
+	for _, b := range bls {
+		bs.record(b.Cid())
+	}
+	return bs.Blockstore.PutMany(bls)
+}
+
+func (bs *Blockstore) record(c cid.Cid) {
+	bs.lk.Lock()
+	defer bs.lk.Unlock()
+	if bs.written != nil {
+		bs.written[string(c.Hash())] = c
+	}
+}
+
+// startCollection begins recording written blocks.
+func (bs *Blockstore) startCollection() error {
+	bs.lk.Lock()
+	defer bs.lk.Unlock()
+	if bs.written != nil {
+		return ErrInProgress
+	}
+	bs.written = make(map[string]cid.Cid)
+	return nil
+}
+
+// writtenBlocks returns the blocks written since the collection started.
+func (bs *Blockstore) writtenBlocks() []cid.Cid {
+	bs.lk.Lock()
+	defer bs.lk.Unlock()
+	written := make([]cid.Cid, 0, len(bs.written))
+	for _, c := range bs.written {
+		written = append(written, c)
+	}
+	return written
+}
+
+// endCollection stops recording written blocks.
+func (bs *Blockstore) endCollection() {
+	bs.lk.Lock()
+	defer bs.lk.Unlock()
+	bs.written = nil
+}
+
+// sweep deletes the block `c` unless it was written during the collection,
+// and returns its size if it was deleted. The check and the delete are atomic
+// with respect to writes.
+func (bs *Blockstore) sweep(c cid.Cid) (int, bool, error) {
+	bs.lk.Lock()
+	defer bs.lk.Unlock()
+	if _, ok := bs.written[string(c.Hash())]; ok {
+		return 0, false, nil
+	}
+
+	size, err := bs.Blockstore.GetSize(c)
+	if err == blockstore.ErrNotFound {
+		return 0, false, nil
+	}
+	if err != nil {
+		return 0, false, err
+	}
+	if err := bs.Blockstore.DeleteBlock(c); err != nil {
+		return 0, false, err
+	}
+	return size, true, nil
+}
@@ -0,0 +1,383 @@
+package gc
+
+import (
+	"context"
+
+	"github.com/filecoin-project/go-address"
+	"github.com/filecoin-project/specs-actors/actors/abi"
+	"github.com/filecoin-project/specs-actors/actors/builtin/miner"
+	"github.com/ipfs/go-cid"
+	blockstore "github.com/ipfs/go-ipfs-blockstore"
+	format "github.com/ipfs/go-ipld-format"
+	logging "github.com/ipfs/go-log"
+	_ "github.com/ipfs/go-merkledag" // registers the block decoders used to find links
+	"github.com/pkg/errors"
+
+	"github.com/filecoin-project/go-filecoin/internal/pkg/block"
+	"github.com/filecoin-project/go-filecoin/internal/pkg/chain"
+	"github.com/filecoin-project/go-filecoin/internal/pkg/types"
+)
+
+var log = logging.Logger("gc")
+
+// chainStore is the view of the chain store needed to find live blocks.
+type chainStore interface {
+	GetHead() block.TipSetKey
+	GetTipSet(block.TipSetKey) (block.TipSet, error)
+	GetTipSetStateRoot(block.TipSetKey) (cid.Cid, error)
+	GetTipSetReceiptsRoot(block.TipSetKey) (cid.Cid, error)
+	GetTipSetAndStatesSince(abi.ChainEpoch) []*chain.TipSetMetadata
+	GCLock() func()
+}
+
+type messageLoader interface {
+	LoadMessages(context.Context, cid.Cid) ([]*types.SignedMessage, []*types.UnsignedMessage, error)
+}
+
+type walletAddresses interface {
+	Addresses() []address.Address
+}
+
+// Result summarises a garbage collection.
+type Result struct {
+	BlocksRemoved uint64 `json:"blocksRemoved"`
+	BytesFreed    uint64 `json:"bytesFreed"`
+}
+
+// Collector garbage collects a blockstore with mark and sweep. It keeps the
+// headers of the head chain back to genesis, and the state, messages and
+// receipts of every tipset, on the head chain or not, that is not yet final
+// or within `retained` epochs of finality. The genesis state, the DAGs of
+// pinned roots, and the message collections and receipts of head chain blocks
+// with messages sent from or to wallet addresses are kept too, so that those
+// messages can still be looked up. Every other block is deleted.
+//
+// Only the chain blockstore may be collected. Client and piece data, such as
+// the payloads and pieces of storage and retrieval deals, are kept in the
+// piece blockstore, which is never collected.
+type Collector struct {
+	bs       *Blockstore
+	chain    chainStore
+	messages messageLoader
+	wallet   walletAddresses
+	pins     *Pins
+	retained abi.ChainEpoch
+}
+
+// NewCollector constructs a collector of `bs`.
+func NewCollector(bs *Blockstore, chain chainStore, messages messageLoader, wallet walletAddresses, pins *Pins, retained abi.ChainEpoch) *Collector {
+	return &Collector{
+		bs:       bs,
+		chain:    chain,
+		messages: messages,
+		wallet:   wallet,
+		pins:     pins,
+		retained: retained,
+	}
+}
+
+// chainSnapshot is the view of the chain store a collection marks from.
+type chainSnapshot struct {
+	head     block.TipSet
+	boundary abi.ChainEpoch
+	recent   []*chain.TipSetMetadata
+}
+
+// Collect deletes the blocks that are not live. Live blocks are marked from a
+// snapshot of the chain store while tipsets continue to be added. Tipsets
+// being added are then waited for and new ones held off only while the blocks
+// written since the collection started are marked and the dead blocks are
+// deleted.
+func (c *Collector) Collect(ctx context.Context) (*Result, error) {
+	if err := c.bs.startCollection(); err != nil {
+		return nil, err
+	}
+	defer c.bs.endCollection()
+
+	snap, err := c.snapshot()
+	if err != nil {
+		return nil, err
+	}
+	m := &marker{bs: c.bs.Blockstore, live: make(map[string]struct{})}
+	if err := c.mark(ctx, m, snap); err != nil {
+		return nil, errors.Wrap(err, "failed to mark live blocks")
+	}
+	dead, err := c.unmarked(ctx, m)
+	if err != nil {
+		return nil, err
+	}
+
+	unlock := c.chain.GCLock()
+	defer unlock()
+
+	// Blocks written since the collection started are kept by the sweep, but
+	// may link to blocks the snapshot did not reach, such as the unchanged
+	// state a new tipset shares with an old one.
+	for _, w := range c.bs.writtenBlocks() {
+		if err := m.markDAG(ctx, w); err != nil {
+			return nil, errors.Wrap(err, "failed to mark written blocks")
+		}
+	}
+	if err := c.markPins(ctx, m); err != nil {
+		return nil, errors.Wrap(err, "failed to mark pinned blocks")
+	}
+	return c.sweep(m, dead)
+}
+
+// snapshot reads the head and the recent tipsets of the chain store, waiting
+// for tipsets being added.
+func (c *Collector) snapshot() (*chainSnapshot, error) {
+	unlock := c.chain.GCLock()
+	defer unlock()
+
+	head, err := c.chain.GetTipSet(c.chain.GetHead())
+	if err != nil {
+		return nil, err
+	}
+	boundary := head.At(0).Height - miner.ChainFinalityish - c.retained
+	if boundary < 0 {
+		boundary = 0
+	}
+	return &chainSnapshot{
+		head:     head,
+		boundary: boundary,
+		recent:   c.chain.GetTipSetAndStatesSince(boundary),
+	}, nil
+}
+
+func (c *Collector) mark(ctx context.Context, m *marker, snap *chainSnapshot) error {
+	// Tipsets above the boundary keep their state, wherever they are.
+	for _, tsm := range snap.recent {
+		if err := c.markTipSet(ctx, m, tsm.TipSet, tsm.TipSetStateRoot, tsm.TipSetReceipts); err != nil {
+			return err
+		}
+	}
+
+	// The head chain keeps its headers back to genesis.
+	wallet := make(map[address.Address]struct{})
+	for _, addr := range c.wallet.Addresses() {
+		wallet[addr] = struct{}{}
+	}
+	ts := snap.head
+	var child block.TipSet
+	for {
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+		for i := 0; i < ts.Len(); i++ {
+			m.markOne(ts.At(i).Cid())
+		}
+		height := ts.At(0).Height
+		if height == 0 {
+			// Genesis keeps its state.
+			stateRoot, err := c.chain.GetTipSetStateRoot(ts.Key())
+			if err != nil {
+				return err
+			}
+			receipts, err := c.chain.GetTipSetReceiptsRoot(ts.Key())
+			if err != nil {
+				return err
+			}
+			if err := c.markTipSet(ctx, m, ts, stateRoot, receipts); err != nil {
+				return err
+			}
+			break
+		}
+		if height < snap.boundary {
+			if err := c.markWalletMessages(ctx, m, ts, child, wallet); err != nil {
+				return err
+			}
+		}
+
+		parents, err := ts.Parents()
+		if err != nil {
+			return err
+		}
+		child = ts
+		if ts, err = c.chain.GetTipSet(parents); err != nil {
+			return err
+		}
+	}
+
+	return c.markPins(ctx, m)
+}
+
+// markPins marks the DAGs of the pinned roots.
+func (c *Collector) markPins(ctx context.Context, m *marker) error {
+	pins, err := c.pins.List()
+	if err != nil {
+		return err
+	}
+	for _, p := range pins {
+		if err := m.markDAG(ctx, p); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// markTipSet marks the headers, messages, state and receipts of a tipset,
+// along with the state and receipts it was mined on.
+func (c *Collector) markTipSet(ctx context.Context, m *marker, ts block.TipSet, stateRoot, receipts cid.Cid) error {
+	for i := 0; i < ts.Len(); i++ {
+		blk := ts.At(i)
+		m.markOne(blk.Cid())
+		for _, root := range []cid.Cid{blk.Messages.Cid, blk.StateRoot.Cid, blk.MessageReceipts.Cid} {
+			if err := m.markDAG(ctx, root); err != nil {
+				return err
+			}
+		}
+	}
+	for _, root := range []cid.Cid{stateRoot, receipts} {
+		if err := m.markDAG(ctx, root); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// markWalletMessages marks the message collections of the blocks of a tipset
+// that include messages sent from or to wallet addresses, and the receipts of
+// the tipset, which its child commits to. Blocks whose messages were collected
+// already are skipped.
+func (c *Collector) markWalletMessages(ctx context.Context, m *marker, ts, child block.TipSet, wallet map[address.Address]struct{}) error {
+	if len(wallet) == 0 {
+		return nil
+	}
+	isWallet := func(addr address.Address) bool {
+		_, ok := wallet[addr]
+		return ok
+	}
+
+	found := false
+	for i := 0; i < ts.Len(); i++ {
+		metaCid := ts.At(i).Messages.Cid
+		has, err := m.bs.Has(metaCid)
+		if err != nil {
+			return err
+		}
+		if !has {
+			continue
+		}
+		secpMsgs, blsMsgs, err := c.messages.LoadMessages(ctx, metaCid)
+		if err != nil {
+			return errors.Wrapf(err, "failed to load messages of block %s", ts.At(i).Cid())
+		}
+
+		include := false
+		for _, msg := range secpMsgs {
+			include = include || isWallet(msg.Message.From) || isWallet(msg.Message.To)
+		}
+		for _, msg := range blsMsgs {
+			include = include || isWallet(msg.From) || isWallet(msg.To)
+		}
+		if include {
+			found = true
+			if err := m.markDAG(ctx, metaCid); err != nil {
+				return err
+			}
+		}
+	}
+	if found {
+		return m.markDAG(ctx, child.At(0).MessageReceipts.Cid)
+	}
+	return nil
+}
+
+// unmarked lists the blocks of the blockstore that are not marked live.
+func (c *Collector) unmarked(ctx context.Context, m *marker) ([]cid.Cid, error) {
+	keys, err := c.bs.AllKeysChan(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	// Collect the keys first: deleting while iterating is not supported by
+	// every datastore.
+	var dead []cid.Cid
+	for k := range keys {
+		if !m.isLive(k) {
+			dead = append(dead, k)
+		}
+	}
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+	return dead, nil
+}
+
+// sweep deletes the blocks of `dead` that are still not marked live.
+func (c *Collector) sweep(m *marker, dead []cid.Cid) (*Result, error) {
+	result := &Result{}
+	for _, k := range dead {
+		if m.isLive(k) {
+			continue
+		}
+		size, deleted, err := c.bs.sweep(k)
+		if err != nil {
+			return result, errors.Wrapf(err, "failed to delete block %s", k)
+		}
+		if deleted {
+			result.BlocksRemoved++
+			result.BytesFreed += uint64(size)
+		}
+	}
+	log.Infof("garbage collection removed %d blocks, freeing %d bytes", result.BlocksRemoved, result.BytesFreed)
+	return result, nil
+}
+
+// marker records the blocks reachable from a set of roots.
+type marker struct {
+	bs   blockstore.Blockstore
+	live map[string]struct{}
+}
+
+// markOne marks a single block without following its links.
+func (m *marker) markOne(c cid.Cid) {
+	m.live[string(c.Hash())] = struct{}{}
+}
+
+// isLive returns true if the block `c` is marked.
+func (m *marker) isLive(c cid.Cid) bool {
+	_, ok := m.live[string(c.Hash())]
+	return ok
+}
+
+// markDAG marks every block reachable from `root`. Blocks missing from the
+// blockstore, such as the state of a tipset imported from a snapshot, are
+// skipped.
+func (m *marker) markDAG(ctx context.Context, root cid.Cid) error {
+	if !root.Defined() {
+		return nil
+	}
+	stack := []cid.Cid{root}
+	for len(stack) > 0 {
+		c := stack[len(stack)-1]
+		stack = stack[:len(stack)-1]
+		if m.isLive(c) {
+			continue
+		}
+		m.markOne(c)
+		if c.Prefix().Codec == cid.Raw {
+			continue
+		}
+
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+		blk, err := m.bs.Get(c)
+		if err == blockstore.ErrNotFound {
+			continue
+		}
+		if err != nil {
+			return err
+		}
+		nd, err := format.Decode(blk)
+		if err != nil {
+			return errors.Wrapf(err, "failed to decode block %s", c)
+		}
+		for _, l := range nd.Links() {
+			stack = append(stack, l.Cid)
+		}
+	}
+	return nil
+}
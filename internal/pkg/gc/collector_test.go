@@ -0,0 +1,319 @@
+package gc
+
+import (
+	"context"
+	"fmt"
+	"testing"
+
+	"github.com/filecoin-project/go-address"
+	"github.com/filecoin-project/specs-actors/actors/abi"
+	"github.com/filecoin-project/specs-actors/actors/builtin/miner"
+	"github.com/ipfs/go-cid"
+	"github.com/ipfs/go-datastore"
+	blockstore "github.com/ipfs/go-ipfs-blockstore"
+	cbor "github.com/ipfs/go-ipld-cbor"
+	"github.com/pkg/errors"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/filecoin-project/go-filecoin/internal/pkg/block"
+	"github.com/filecoin-project/go-filecoin/internal/pkg/chain"
+	"github.com/filecoin-project/go-filecoin/internal/pkg/constants"
+	e "github.com/filecoin-project/go-filecoin/internal/pkg/enccid"
+	tf "github.com/filecoin-project/go-filecoin/internal/pkg/testhelpers/testflags"
+	"github.com/filecoin-project/go-filecoin/internal/pkg/types"
+)
+
+func TestCollect(t *testing.T) {
+	tf.UnitTest(t)
+	ctx := context.Background()
+
+	ds := datastore.NewMapDatastore()
+	bs := NewBlockstore(blockstore.NewBlockstore(ds))
+	f := &fakeChain{bs: bs, t: t, tsms: make(map[string]*chain.TipSetMetadata)}
+
+	signer, _ := types.NewMockSignersAndKeyInfo(1)
+	walletMsg := types.NewSignedMessageForTestGetter(signer)()
+	other, err := address.NewSecp256k1Address([]byte("other"))
+	require.NoError(t, err)
+	otherMsg := &types.SignedMessage{Message: *types.NewMeteredMessage(other, other, 0,
+		types.ZeroAttoFIL, 0, nil, types.ZeroAttoFIL, 0)}
+
+	// genesis <- old <- quiet <- recent <- head, with an orphaned fork on
+	// old and a recent fork on old. Only old, quiet and the orphan are past
+	// the boundary.
+	final := miner.ChainFinalityish
+	gen := f.tipSet(0, block.TipSetKey{})
+	old := f.tipSet(1, gen.Key())
+	orphan := f.tipSet(2, old.Key())
+	quiet := f.tipSet(3, old.Key())
+	recent := f.tipSet(2*final+1, quiet.Key())
+	recentFork := f.tipSet(2*final+2, old.Key())
+	head := f.tipSet(3*final, recent.Key())
+	f.head = head
+	f.messages = map[cid.Cid][]*types.SignedMessage{
+		old.At(0).Messages.Cid:   {walletMsg, otherMsg},
+		quiet.At(0).Messages.Cid: {otherMsg},
+	}
+
+	pinned := f.dag("pinned")
+	pins := NewPins(ds)
+	require.NoError(t, pins.Add(pinned))
+	garbage := f.dag("garbage")
+
+	collector := NewCollector(bs, f, f, &fakeWallet{addrs: signer.Addresses}, pins, 0)
+	result, err := collector.Collect(ctx)
+	require.NoError(t, err)
+	assert.True(t, result.BlocksRemoved > 0)
+	assert.True(t, result.BytesFreed > 0)
+
+	has := func(c cid.Cid) bool {
+		ok, err := bs.Has(c)
+		require.NoError(t, err)
+		return ok
+	}
+
+	t.Log("headers of the head chain are kept")
+	for _, ts := range []block.TipSet{gen, old, quiet, recent, head} {
+		assert.True(t, has(ts.At(0).Cid()))
+	}
+	t.Log("state of recent tipsets, on the head chain or not, and genesis is kept")
+	for _, ts := range []block.TipSet{gen, recent, recentFork, head} {
+		assert.True(t, has(f.stateRoots[ts.String()]))
+		assert.True(t, has(f.stateChildren[ts.String()]))
+		assert.True(t, has(ts.At(0).Messages.Cid))
+	}
+	t.Log("state and messages of old tipsets are deleted")
+	for _, ts := range []block.TipSet{old, quiet} {
+		assert.False(t, has(f.stateRoots[ts.String()]))
+		assert.False(t, has(f.stateChildren[ts.String()]))
+	}
+	assert.False(t, has(quiet.At(0).Messages.Cid))
+	assert.False(t, has(recent.At(0).MessageReceipts.Cid))
+	t.Log("orphaned blocks are deleted")
+	assert.False(t, has(orphan.At(0).Cid()))
+	assert.False(t, has(f.stateRoots[orphan.String()]))
+
+	t.Log("messages and receipts of old tipsets with wallet messages are kept, without pins")
+	assert.True(t, has(old.At(0).Messages.Cid))
+	assert.True(t, has(quiet.At(0).MessageReceipts.Cid))
+	pinList, err := pins.List()
+	require.NoError(t, err)
+	assert.Equal(t, []cid.Cid{pinned}, pinList)
+
+	t.Log("pinned DAGs are kept and others deleted")
+	assert.True(t, has(pinned))
+	assert.False(t, has(garbage))
+
+	t.Log("a second collection removes nothing")
+	result, err = collector.Collect(ctx)
+	require.NoError(t, err)
+	assert.Equal(t, uint64(0), result.BlocksRemoved)
+	assert.True(t, has(old.At(0).Messages.Cid))
+
+	t.Log("failures to load messages abort the collection")
+	f.loadErr = errors.New("failed to load")
+	_, err = collector.Collect(ctx)
+	assert.Error(t, err)
+	assert.True(t, has(old.At(0).Messages.Cid))
+}
+
+func TestBlockstoreKeepsBlocksWrittenDuringCollection(t *testing.T) {
+	tf.UnitTest(t)
+
+	bs := NewBlockstore(blockstore.NewBlockstore(datastore.NewMapDatastore()))
+	before := mustNode(t, "before")
+	require.NoError(t, bs.Put(before))
+
+	require.NoError(t, bs.startCollection())
+	assert.Equal(t, ErrInProgress, bs.startCollection())
+
+	during := mustNode(t, "during")
+	require.NoError(t, bs.Put(during))
+
+	_, deleted, err := bs.sweep(during.Cid())
+	require.NoError(t, err)
+	assert.False(t, deleted)
+
+	size, deleted, err := bs.sweep(before.Cid())
+	require.NoError(t, err)
+	assert.True(t, deleted)
+	assert.Equal(t, len(before.RawData()), size)
+
+	bs.endCollection()
+	require.NoError(t, bs.startCollection())
+}
+
+func TestCollectKeepsBlocksLinkedFromWritesDuringMark(t *testing.T) {
+	tf.UnitTest(t)
+	ctx := context.Background()
+
+	bs := NewBlockstore(blockstore.NewBlockstore(datastore.NewMapDatastore()))
+	f := &fakeChain{bs: bs, t: t, tsms: make(map[string]*chain.TipSetMetadata)}
+
+	final := miner.ChainFinalityish
+	gen := f.tipSet(0, block.TipSetKey{})
+	old := f.tipSet(1, gen.Key())
+	f.head = f.tipSet(2*final, old.Key())
+
+	// After the snapshot is taken, a new block is written that links to the
+	// state of the old tipset, which the snapshot does not keep.
+	shared := f.stateChildren[old.String()]
+	var written cid.Cid
+	f.onLock = func(n int) {
+		if n == 2 {
+			nd := mustNode(t, map[string]interface{}{"shared": shared})
+			require.NoError(t, bs.Put(nd))
+			written = nd.Cid()
+		}
+	}
+
+	collector := NewCollector(bs, f, f, &fakeWallet{}, NewPins(datastore.NewMapDatastore()), 0)
+	_, err := collector.Collect(ctx)
+	require.NoError(t, err)
+	assert.Equal(t, 2, f.locks)
+	assert.False(t, f.locked)
+
+	has := func(c cid.Cid) bool {
+		ok, err := bs.Has(c)
+		require.NoError(t, err)
+		return ok
+	}
+	assert.True(t, has(written))
+	assert.True(t, has(shared))
+	assert.False(t, has(f.stateRoots[old.String()]))
+}
+
+type fakeChain struct {
+	t             *testing.T
+	bs            *Blockstore
+	head          block.TipSet
+	tsms          map[string]*chain.TipSetMetadata
+	messages      map[cid.Cid][]*types.SignedMessage
+	loadErr       error
+	stateRoots    map[string]cid.Cid
+	stateChildren map[string]cid.Cid
+
+	// locked is true while the GC lock is held.
+	locked bool
+	// onLock, if set, is called with the number of times the GC lock has
+	// been taken each time it is taken.
+	onLock func(int)
+	locks  int
+}
+
+// tipSet stores a single block tipset with a state, messages and receipts of
+// its own.
+func (f *fakeChain) tipSet(h abi.ChainEpoch, parents block.TipSetKey) block.TipSet {
+	if f.stateRoots == nil {
+		f.stateRoots = make(map[string]cid.Cid)
+		f.stateChildren = make(map[string]cid.Cid)
+	}
+	name := func(s string) string { return fmt.Sprintf("%s %d %s", s, h, parents) }
+
+	child := mustNode(f.t, name("state child"))
+	require.NoError(f.t, f.bs.Put(child))
+	root := mustNode(f.t, map[string]interface{}{"child": child.Cid()})
+	require.NoError(f.t, f.bs.Put(root))
+
+	blk := &block.Block{
+		Height:          h,
+		Parents:         parents,
+		StateRoot:       e.NewCid(f.dag(name("parent state"))),
+		Messages:        e.NewCid(f.dag(name("messages"))),
+		MessageReceipts: e.NewCid(f.dag(name("parent receipts"))),
+	}
+	require.NoError(f.t, f.bs.Put(blk.ToNode()))
+	ts, err := block.NewTipSet(blk)
+	require.NoError(f.t, err)
+
+	f.tsms[ts.Key().String()] = &chain.TipSetMetadata{
+		TipSet:          ts,
+		TipSetStateRoot: root.Cid(),
+		TipSetReceipts:  f.dag(name("receipts")),
+	}
+	f.stateRoots[ts.String()] = root.Cid()
+	f.stateChildren[ts.String()] = child.Cid()
+	return ts
+}
+
+// dag stores a block linking to another and returns the root.
+func (f *fakeChain) dag(name string) cid.Cid {
+	leaf := mustNode(f.t, name+" leaf")
+	require.NoError(f.t, f.bs.Put(leaf))
+	root := mustNode(f.t, map[string]interface{}{"leaf": leaf.Cid()})
+	require.NoError(f.t, f.bs.Put(root))
+	return root.Cid()
+}
+
+func (f *fakeChain) GetHead() block.TipSetKey {
+	return f.head.Key()
+}
+
+func (f *fakeChain) GetTipSet(key block.TipSetKey) (block.TipSet, error) {
+	tsm, ok := f.tsms[key.String()]
+	if !ok {
+		return block.UndefTipSet, chain.ErrNotFound
+	}
+	return tsm.TipSet, nil
+}
+
+func (f *fakeChain) GetTipSetStateRoot(key block.TipSetKey) (cid.Cid, error) {
+	tsm, ok := f.tsms[key.String()]
+	if !ok {
+		return cid.Undef, chain.ErrNotFound
+	}
+	return tsm.TipSetStateRoot, nil
+}
+
+func (f *fakeChain) GetTipSetReceiptsRoot(key block.TipSetKey) (cid.Cid, error) {
+	tsm, ok := f.tsms[key.String()]
+	if !ok {
+		return cid.Undef, chain.ErrNotFound
+	}
+	return tsm.TipSetReceipts, nil
+}
+
+func (f *fakeChain) GetTipSetAndStatesSince(h abi.ChainEpoch) []*chain.TipSetMetadata {
+	var ret []*chain.TipSetMetadata
+	for _, tsm := range f.tsms {
+		if tsm.TipSet.At(0).Height >= h {
+			ret = append(ret, tsm)
+		}
+	}
+	return ret
+}
+
+func (f *fakeChain) GCLock() func() {
+	f.locks++
+	f.locked = true
+	if f.onLock != nil {
+		f.onLock(f.locks)
+	}
+	return func() { f.locked = false }
+}
+
+func (f *fakeChain) LoadMessages(ctx context.Context, c cid.Cid) ([]*types.SignedMessage, []*types.UnsignedMessage, error) {
+	assert.False(f.t, f.locked, "marking must not hold the GC lock")
+	if f.loadErr != nil {
+		return nil, nil, f.loadErr
+	}
+	if has, err := f.bs.Has(c); err != nil || !has {
+		return nil, nil, blockstore.ErrNotFound
+	}
+	return f.messages[c], nil, nil
+}
+
+type fakeWallet struct {
+	addrs []address.Address
+}
+
+func (w *fakeWallet) Addresses() []address.Address {
+	return w.addrs
+}
+
+func mustNode(t *testing.T, obj interface{}) *cbor.Node {
+	nd, err := cbor.WrapObject(obj, constants.DefaultHashFunction, -1)
+	require.NoError(t, err)
+	return nd
+}
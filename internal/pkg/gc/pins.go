@@ -0,0 +1,66 @@
+package gc
+
+import (
+	"strings"
+
+	"github.com/ipfs/go-cid"
+	"github.com/ipfs/go-datastore"
+	"github.com/ipfs/go-datastore/namespace"
+	dsq "github.com/ipfs/go-datastore/query"
+	"github.com/pkg/errors"
+)
+
+// pinsPrefix is the datastore namespace of the pinned roots.
+var pinsPrefix = datastore.NewKey("/gc/pins")
+
+// Pins is a persistent set of roots whose DAGs are kept by garbage
+// collection, such as data imported for deals.
+type Pins struct {
+	ds datastore.Datastore
+}
+
+// NewPins returns the pins recorded in `ds`.
+func NewPins(ds datastore.Batching) *Pins {
+	return &Pins{ds: namespace.Wrap(ds, pinsPrefix)}
+}
+
+// Add pins the DAG rooted at `c`.
+func (p *Pins) Add(c cid.Cid) error {
+	return p.ds.Put(pinKey(c), []byte{})
+}
+
+// Remove unpins the DAG rooted at `c`.
+func (p *Pins) Remove(c cid.Cid) error {
+	return p.ds.Delete(pinKey(c))
+}
+
+// Has returns true if `c` is pinned.
+func (p *Pins) Has(c cid.Cid) (bool, error) {
+	return p.ds.Has(pinKey(c))
+}
+
+// List returns all pinned roots.
+func (p *Pins) List() ([]cid.Cid, error) {
+	res, err := p.ds.Query(dsq.Query{KeysOnly: true})
+	if err != nil {
+		return nil, err
+	}
+	entries, err := res.Rest()
+	if err != nil {
+		return nil, err
+	}
+
+	var pins []cid.Cid
+	for _, e := range entries {
+		c, err := cid.Decode(strings.TrimPrefix(e.Key, "/"))
+		if err != nil {
+			return nil, errors.Wrapf(err, "invalid pin %s", e.Key)
+		}
+		pins = append(pins, c)
+	}
+	return pins, nil
+}
+
+func pinKey(c cid.Cid) datastore.Key {
+	return datastore.NewKey(c.String())
+}
@@ -0,0 +1,600 @@
+package jsonrpc
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"mime"
+	"net/http"
+	"reflect"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+
+	"github.com/gorilla/websocket"
+	logging "github.com/ipfs/go-log"
+)
+
+var log = logging.Logger("jsonrpc")
+
+// Version is the protocol version of requests and responses.
+const Version = "2.0"
+
+// Error codes defined by the JSON-RPC 2.0 specification.
+const (
+	CodeParseError     = -32700
+	CodeInvalidRequest = -32600
+	CodeMethodNotFound = -32601
+	CodeInvalidParams  = -32602
+	CodeInternalError  = -32603
+	// CodeServerError is the code of errors returned by method implementations.
+	CodeServerError = -32000
+)
+
+// Names of the methods built into every server.
+const (
+	DiscoverMethod     = "rpc.discover"
+	UnsubscribeMethod  = "rpc.unsubscribe"
+	NotificationMethod = "rpc.subscription"
+)
+
+// maxRequestSize bounds the body of an HTTP request or a websocket message.
+const maxRequestSize = 16 << 20
+
+// writeTimeout bounds the time to write a websocket message. A client that
+// does not read within it is disconnected, ending its subscriptions.
+const writeTimeout = 10 * time.Second
+
+var errNoWebsocket = &Error{Code: CodeInvalidRequest, Message: "subscriptions require a websocket connection"}
+
+var (
+	contextType = reflect.TypeOf((*context.Context)(nil)).Elem()
+	errorType   = reflect.TypeOf((*error)(nil)).Elem()
+)
+
+// Error is a JSON-RPC error object.
+type Error struct {
+	Code    int         `json:"code"`
+	Message string      `json:"message"`
+	Data    interface{} `json:"data,omitempty"`
+}
+
+// Error implements the error interface.
+func (e *Error) Error() string {
+	return fmt.Sprintf("jsonrpc error %d: %s", e.Code, e.Message)
+}
+
+// Request is a JSON-RPC request. A request without an id is a notification
+// and gets no response.
+type Request struct {
+	JSONRPC string          `json:"jsonrpc"`
+	ID      json.RawMessage `json:"id,omitempty"`
+	Method  string          `json:"method"`
+	Params  json.RawMessage `json:"params,omitempty"`
+}
+
+// Response is a JSON-RPC response, carrying either a result or an error.
+type Response struct {
+	JSONRPC string          `json:"jsonrpc"`
+	ID      json.RawMessage `json:"id"`
+	Result  json.RawMessage `json:"result,omitempty"`
+	Error   *Error          `json:"error,omitempty"`
+}
+
+// Notification is a request without an id, sent by the server to deliver
+// the values of a subscription.
+type Notification struct {
+	JSONRPC string             `json:"jsonrpc"`
+	Method  string             `json:"method"`
+	Params  NotificationParams `json:"params"`
+}
+
+// NotificationParams carries one value of a subscription.
+type NotificationParams struct {
+	Subscription string          `json:"subscription"`
+	Result       json.RawMessage `json:"result"`
+}
+
+// MethodInfo describes a method in the listing returned by rpc.discover.
+type MethodInfo struct {
+	Name         string      `json:"name"`
+	Description  string      `json:"description"`
+	Params       []ParamInfo `json:"params"`
+	Result       string      `json:"result,omitempty"`
+	Subscription bool        `json:"subscription"`
+}
+
+// ParamInfo describes a parameter of a method. Parameters may be passed by
+// name or by position, in the listed order.
+type ParamInfo struct {
+	Name string `json:"name"`
+	Type string `json:"type"`
+}
+
+// method is a registered method implementation.
+type method struct {
+	info   MethodInfo
+	fn     reflect.Value
+	params reflect.Type // params struct type, nil if the method takes none
+	fields []int        // indices of the params struct fields, in positional order
+	result bool         // whether fn returns a value before its error
+}
+
+// Server serves registered methods over JSON-RPC 2.0, both on HTTP POST
+// requests and on websocket connections. Subscriptions, methods returning a
+// channel, are only available on websocket connections, where each value
+// received from the channel is sent to the client as a notification.
+type Server struct {
+	methods     map[string]*method
+	checkOrigin func(*http.Request) bool
+	upgrader    websocket.Upgrader
+}
+
+// NewServer returns a server without methods. POST requests and websocket
+// connections are accepted from origins for which checkOrigin returns true.
+func NewServer(checkOrigin func(*http.Request) bool) *Server {
+	s := &Server{
+		methods:     make(map[string]*method),
+		checkOrigin: checkOrigin,
+		upgrader: websocket.Upgrader{
+			CheckOrigin: checkOrigin,
+		},
+	}
+	s.Register(DiscoverMethod, "Lists the methods served, with their parameters and results", func(context.Context) ([]MethodInfo, error) {
+		return s.Methods(), nil
+	})
+	return s
+}
+
+// Register adds a method named `name`. `fn` must be a function taking a
+// context.Context, optionally followed by a pointer to a struct holding the
+// parameters, and returning an error, optionally preceded by a result. If the
+// result is a receive-only channel the method is a subscription, which
+// streams the values received until the channel is closed or the client
+// unsubscribes. Register panics if `fn` does not have such a signature or if
+// `name` is already registered.
+func (s *Server) Register(name, description string, fn interface{}) {
+	if _, ok := s.methods[name]; ok {
+		panic(fmt.Sprintf("jsonrpc: method %s registered twice", name))
+	}
+	m, err := newMethod(name, description, reflect.ValueOf(fn))
+	if err != nil {
+		panic(fmt.Sprintf("jsonrpc: method %s: %s", name, err))
+	}
+	s.methods[name] = m
+}
+
+func newMethod(name, description string, fn reflect.Value) (*method, error) {
+	t := fn.Type()
+	if t.Kind() != reflect.Func {
+		return nil, fmt.Errorf("%s is not a function", t)
+	}
+	if t.NumIn() < 1 || t.NumIn() > 2 || t.In(0) != contextType {
+		return nil, fmt.Errorf("must take a context and an optional params pointer")
+	}
+	if t.NumOut() < 1 || t.NumOut() > 2 || t.Out(t.NumOut()-1) != errorType {
+		return nil, fmt.Errorf("must return an optional result and an error")
+	}
+
+	m := &method{
+		info: MethodInfo{
+			Name:        name,
+			Description: description,
+			Params:      []ParamInfo{},
+		},
+		fn:     fn,
+		result: t.NumOut() == 2,
+	}
+	if t.NumIn() == 2 {
+		if t.In(1).Kind() != reflect.Ptr || t.In(1).Elem().Kind() != reflect.Struct {
+			return nil, fmt.Errorf("params must be a pointer to a struct")
+		}
+		m.params = t.In(1).Elem()
+		for i := 0; i < m.params.NumField(); i++ {
+			field := m.params.Field(i)
+			paramName := jsonName(field)
+			if paramName == "" {
+				continue
+			}
+			m.fields = append(m.fields, i)
+			m.info.Params = append(m.info.Params, ParamInfo{Name: paramName, Type: field.Type.String()})
+		}
+	}
+	if m.result {
+		result := t.Out(0)
+		if result.Kind() == reflect.Chan {
+			if result.ChanDir() != reflect.RecvDir {
+				return nil, fmt.Errorf("subscriptions must return a receive-only channel")
+			}
+			m.info.Subscription = true
+			result = result.Elem()
+		}
+		m.info.Result = result.String()
+	}
+	return m, nil
+}
+
+// jsonName returns the name under which a struct field is encoded in JSON,
+// or the empty string if it is not.
+func jsonName(field reflect.StructField) string {
+	if field.PkgPath != "" {
+		return ""
+	}
+	tag := field.Tag.Get("json")
+	if tag == "-" {
+		return ""
+	}
+	if name := strings.Split(tag, ",")[0]; name != "" {
+		return name
+	}
+	return field.Name
+}
+
+// Methods returns descriptions of the registered methods, sorted by name.
+func (s *Server) Methods() []MethodInfo {
+	infos := make([]MethodInfo, 0, len(s.methods))
+	for _, m := range s.methods {
+		infos = append(infos, m.info)
+	}
+	sort.Slice(infos, func(i, j int) bool { return infos[i].Name < infos[j].Name })
+	return infos
+}
+
+// ServeHTTP handles a websocket upgrade, a POST request carrying a single or
+// a batch request, or a GET request for the method listing. POST requests
+// must come from an allowed origin and have a JSON content type, which a
+// cross-site form cannot send without a CORS preflight.
+func (s *Server) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if websocket.IsWebSocketUpgrade(r) {
+		s.serveWebsocket(w, r)
+		return
+	}
+	switch r.Method {
+	case http.MethodGet:
+		writeJSON(w, s.Methods())
+	case http.MethodPost:
+		if !s.checkOrigin(r) {
+			http.Error(w, "origin not allowed", http.StatusForbidden)
+			return
+		}
+		if mediaType, _, err := mime.ParseMediaType(r.Header.Get("Content-Type")); err != nil || mediaType != "application/json" {
+			http.Error(w, "content type must be application/json", http.StatusUnsupportedMediaType)
+			return
+		}
+		body, err := ioutil.ReadAll(http.MaxBytesReader(w, r.Body, maxRequestSize))
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		out, ok := s.handleMessage(r.Context(), body, nil)
+		if !ok {
+			w.WriteHeader(http.StatusNoContent)
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		w.Write(out) // nolint: errcheck
+	default:
+		w.Header().Set("Allow", "GET, POST")
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+	}
+}
+
+// handleMessage handles a single or a batch request and returns the encoded
+// response, if there is any to send.
+func (s *Server) handleMessage(ctx context.Context, msg []byte, sess *session) ([]byte, bool) {
+	msg = bytes.TrimSpace(msg)
+	if len(msg) > 0 && msg[0] == '[' {
+		var batch []json.RawMessage
+		if err := json.Unmarshal(msg, &batch); err != nil {
+			return encode(errorResponse(nil, &Error{Code: CodeParseError, Message: err.Error()}))
+		}
+		if len(batch) == 0 {
+			return encode(errorResponse(nil, &Error{Code: CodeInvalidRequest, Message: "empty batch"}))
+		}
+		responses := make([]*Response, len(batch))
+		var wg sync.WaitGroup
+		for i, raw := range batch {
+			wg.Add(1)
+			go func(i int, raw json.RawMessage) {
+				defer wg.Done()
+				responses[i] = s.handleRequest(ctx, raw, sess)
+			}(i, raw)
+		}
+		wg.Wait()
+		var out []*Response
+		for _, resp := range responses {
+			if resp != nil {
+				out = append(out, resp)
+			}
+		}
+		if len(out) == 0 {
+			return nil, false
+		}
+		return encode(out)
+	}
+
+	resp := s.handleRequest(ctx, msg, sess)
+	if resp == nil {
+		return nil, false
+	}
+	return encode(resp)
+}
+
+// handleRequest handles a single request and returns its response, nil if
+// the request is a notification.
+func (s *Server) handleRequest(ctx context.Context, raw json.RawMessage, sess *session) *Response {
+	var req Request
+	if err := json.Unmarshal(raw, &req); err != nil {
+		return errorResponse(nil, &Error{Code: CodeParseError, Message: err.Error()})
+	}
+	if req.JSONRPC != Version || req.Method == "" {
+		return errorResponse(req.ID, &Error{Code: CodeInvalidRequest, Message: "not a JSON-RPC 2.0 request"})
+	}
+
+	result, rpcErr := s.call(ctx, &req, sess)
+	if req.ID == nil {
+		return nil
+	}
+	if rpcErr != nil {
+		return errorResponse(req.ID, rpcErr)
+	}
+	out, err := json.Marshal(result)
+	if err != nil {
+		return errorResponse(req.ID, &Error{Code: CodeInternalError, Message: err.Error()})
+	}
+	return &Response{JSONRPC: Version, ID: req.ID, Result: out}
+}
+
+// call invokes the requested method and returns its result.
+func (s *Server) call(ctx context.Context, req *Request, sess *session) (interface{}, *Error) {
+	if req.Method == UnsubscribeMethod {
+		if sess == nil {
+			return nil, errNoWebsocket
+		}
+		var params struct {
+			Subscription string `json:"subscription"`
+		}
+		if err := decodeParams(req.Params, &params, []int{0}); err != nil {
+			return nil, &Error{Code: CodeInvalidParams, Message: err.Error()}
+		}
+		return sess.conn.unsubscribe(params.Subscription), nil
+	}
+
+	m, ok := s.methods[req.Method]
+	if !ok {
+		return nil, &Error{Code: CodeMethodNotFound, Message: fmt.Sprintf("method %s not found", req.Method)}
+	}
+	onSuccess := func(result reflect.Value) interface{} {
+		return result.Interface()
+	}
+
+	// A subscription outlives its request, until the client unsubscribes or
+	// the connection closes.
+	if m.info.Subscription {
+		if sess == nil {
+			return nil, errNoWebsocket
+		}
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithCancel(sess.conn.ctx)
+		subscribed := false
+		defer func() {
+			if !subscribed {
+				cancel()
+			}
+		}()
+		onSuccess = func(ch reflect.Value) interface{} {
+			subscribed = true
+			return sess.conn.subscribe(ctx, cancel, ch, sess.responded)
+		}
+	}
+
+	args := []reflect.Value{reflect.ValueOf(ctx)}
+	if m.params != nil {
+		params := reflect.New(m.params)
+		if err := decodeParams(req.Params, params.Interface(), m.fields); err != nil {
+			return nil, &Error{Code: CodeInvalidParams, Message: err.Error()}
+		}
+		args = append(args, params)
+	}
+
+	outs := m.fn.Call(args)
+	if err, _ := outs[len(outs)-1].Interface().(error); err != nil {
+		if rpcErr, ok := err.(*Error); ok {
+			return nil, rpcErr
+		}
+		return nil, &Error{Code: CodeServerError, Message: err.Error()}
+	}
+	if !m.result {
+		return nil, nil
+	}
+	return onSuccess(outs[0]), nil
+}
+
+// decodeParams decodes params given by name as a JSON object, or by position
+// as a JSON array mapped onto the struct fields at `fields`.
+func decodeParams(raw json.RawMessage, params interface{}, fields []int) error {
+	raw = bytes.TrimSpace(raw)
+	if len(raw) == 0 || bytes.Equal(raw, []byte("null")) {
+		return nil
+	}
+	if raw[0] != '[' {
+		return json.Unmarshal(raw, params)
+	}
+
+	var positional []json.RawMessage
+	if err := json.Unmarshal(raw, &positional); err != nil {
+		return err
+	}
+	if len(positional) > len(fields) {
+		return fmt.Errorf("expected at most %d params, got %d", len(fields), len(positional))
+	}
+	v := reflect.ValueOf(params).Elem()
+	for i, p := range positional {
+		if err := json.Unmarshal(p, v.Field(fields[i]).Addr().Interface()); err != nil {
+			return fmt.Errorf("param %d: %s", i, err)
+		}
+	}
+	return nil
+}
+
+func errorResponse(id json.RawMessage, err *Error) *Response {
+	if id == nil {
+		id = json.RawMessage("null")
+	}
+	return &Response{JSONRPC: Version, ID: id, Error: err}
+}
+
+func encode(v interface{}) ([]byte, bool) {
+	out, err := json.Marshal(v)
+	if err != nil {
+		log.Errorf("failed encoding response: %s", err)
+		return nil, false
+	}
+	return out, true
+}
+
+func writeJSON(w http.ResponseWriter, v interface{}) {
+	out, ok := encode(v)
+	if !ok {
+		http.Error(w, "failed encoding response", http.StatusInternalServerError)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	w.Write(out) // nolint: errcheck
+}
+
+// serveWebsocket upgrades the connection and serves requests read from it
+// until it is closed. Requests are handled concurrently, so that a long call
+// does not hold up the others.
+func (s *Server) serveWebsocket(w http.ResponseWriter, r *http.Request) {
+	ws, err := s.upgrader.Upgrade(w, r, nil)
+	if err != nil {
+		log.Debugf("websocket upgrade failed: %s", err)
+		return
+	}
+	ws.SetReadLimit(maxRequestSize)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	conn := &wsConn{
+		ctx:           ctx,
+		ws:            ws,
+		subscriptions: make(map[string]context.CancelFunc),
+	}
+	defer func() {
+		cancel()
+		ws.Close() // nolint: errcheck
+	}()
+
+	for {
+		_, msg, err := ws.ReadMessage()
+		if err != nil {
+			if !websocket.IsCloseError(err, websocket.CloseNormalClosure, websocket.CloseGoingAway) {
+				log.Debugf("websocket read failed: %s", err)
+			}
+			return
+		}
+		go func() {
+			responded := make(chan struct{})
+			defer close(responded)
+			if out, ok := s.handleMessage(ctx, msg, &session{conn: conn, responded: responded}); ok {
+				conn.write(out) // nolint: errcheck
+			}
+		}()
+	}
+}
+
+// session is the websocket context of a request.
+type session struct {
+	conn *wsConn
+	// responded is closed once the response to the request is sent, so
+	// that notifications of the subscriptions it makes come after their id.
+	responded <-chan struct{}
+}
+
+// wsConn is a websocket connection and the subscriptions made on it.
+type wsConn struct {
+	ctx     context.Context
+	writeMu sync.Mutex
+	ws      *websocket.Conn
+
+	mu            sync.Mutex
+	nextID        uint64
+	subscriptions map[string]context.CancelFunc
+}
+
+// write sends a message, closing the connection if it fails, since a write
+// past its deadline leaves the connection unusable.
+func (c *wsConn) write(msg []byte) error {
+	c.writeMu.Lock()
+	defer c.writeMu.Unlock()
+	err := c.ws.SetWriteDeadline(time.Now().Add(writeTimeout))
+	if err == nil {
+		err = c.ws.WriteMessage(websocket.TextMessage, msg)
+	}
+	if err != nil {
+		log.Debugf("websocket write failed: %s", err)
+		c.ws.Close() // nolint: errcheck
+	}
+	return err
+}
+
+// subscribe forwards the values received from `ch` as notifications, once
+// `responded` is closed and until ctx is done, `ch` is closed or a write
+// fails. It returns the id of the subscription.
+func (c *wsConn) subscribe(ctx context.Context, cancel context.CancelFunc, ch reflect.Value, responded <-chan struct{}) string {
+	c.mu.Lock()
+	c.nextID++
+	id := strconv.FormatUint(c.nextID, 10)
+	c.subscriptions[id] = cancel
+	c.mu.Unlock()
+
+	go func() {
+		defer c.unsubscribe(id)
+		select {
+		case <-responded:
+		case <-ctx.Done():
+			return
+		}
+
+		cases := []reflect.SelectCase{
+			{Dir: reflect.SelectRecv, Chan: reflect.ValueOf(ctx.Done())},
+			{Dir: reflect.SelectRecv, Chan: ch},
+		}
+		for {
+			chosen, value, ok := reflect.Select(cases)
+			if chosen == 0 || !ok {
+				return
+			}
+			result, err := json.Marshal(value.Interface())
+			if err != nil {
+				log.Errorf("failed encoding value of subscription %s: %s", id, err)
+				return
+			}
+			if out, ok := encode(Notification{
+				JSONRPC: Version,
+				Method:  NotificationMethod,
+				Params:  NotificationParams{Subscription: id, Result: result},
+			}); ok {
+				if err := c.write(out); err != nil {
+					return
+				}
+			}
+		}
+	}()
+	return id
+}
+
+// unsubscribe cancels a subscription and reports whether it existed.
+func (c *wsConn) unsubscribe(id string) bool {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	cancel, ok := c.subscriptions[id]
+	if ok {
+		cancel()
+		delete(c.subscriptions, id)
+	}
+	return ok
+}
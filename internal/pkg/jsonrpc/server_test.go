@@ -0,0 +1,264 @@
+package jsonrpc_test
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gorilla/websocket"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/filecoin-project/go-filecoin/internal/pkg/jsonrpc"
+	tf "github.com/filecoin-project/go-filecoin/internal/pkg/testhelpers/testflags"
+)
+
+type addParams struct {
+	A int `json:"a"`
+	B int `json:"b"`
+}
+
+type countParams struct {
+	To int `json:"to"`
+}
+
+func newTestServer() *httptest.Server {
+	s := jsonrpc.NewServer(func(*http.Request) bool { return true })
+	s.Register("test.add", "Adds two numbers", func(_ context.Context, p *addParams) (int, error) {
+		return p.A + p.B, nil
+	})
+	s.Register("test.fail", "Always fails", func(context.Context) error {
+		return errors.New("boom")
+	})
+	s.Register("test.count", "Counts from one", func(ctx context.Context, p *countParams) (<-chan int, error) {
+		out := make(chan int)
+		go func() {
+			defer close(out)
+			for i := 1; p.To == 0 || i <= p.To; i++ {
+				select {
+				case out <- i:
+				case <-ctx.Done():
+					return
+				}
+			}
+		}()
+		return out, nil
+	})
+	return httptest.NewServer(s)
+}
+
+func post(t *testing.T, server *httptest.Server, body string) (int, []byte) {
+	resp, err := http.Post(server.URL, "application/json", strings.NewReader(body))
+	require.NoError(t, err)
+	defer resp.Body.Close() // nolint: errcheck
+	out, err := ioutil.ReadAll(resp.Body)
+	require.NoError(t, err)
+	return resp.StatusCode, out
+}
+
+func postRequest(t *testing.T, server *httptest.Server, body string) jsonrpc.Response {
+	status, out := post(t, server, body)
+	require.Equal(t, http.StatusOK, status)
+	var resp jsonrpc.Response
+	require.NoError(t, json.Unmarshal(out, &resp))
+	return resp
+}
+
+func TestHTTPCall(t *testing.T) {
+	tf.UnitTest(t)
+	server := newTestServer()
+	defer server.Close()
+
+	t.Run("params by name", func(t *testing.T) {
+		resp := postRequest(t, server, `{"jsonrpc":"2.0","id":1,"method":"test.add","params":{"a":1,"b":2}}`)
+		assert.Nil(t, resp.Error)
+		assert.Equal(t, json.RawMessage(`1`), resp.ID)
+		assert.Equal(t, json.RawMessage(`3`), resp.Result)
+	})
+
+	t.Run("params by position", func(t *testing.T) {
+		resp := postRequest(t, server, `{"jsonrpc":"2.0","id":"x","method":"test.add","params":[4,5]}`)
+		assert.Nil(t, resp.Error)
+		assert.Equal(t, json.RawMessage(`"x"`), resp.ID)
+		assert.Equal(t, json.RawMessage(`9`), resp.Result)
+	})
+
+	t.Run("notification", func(t *testing.T) {
+		status, out := post(t, server, `{"jsonrpc":"2.0","method":"test.add","params":[4,5]}`)
+		assert.Equal(t, http.StatusNoContent, status)
+		assert.Empty(t, out)
+	})
+
+	t.Run("batch", func(t *testing.T) {
+		status, out := post(t, server, `[
+			{"jsonrpc":"2.0","id":1,"method":"test.add","params":[1,1]},
+			{"jsonrpc":"2.0","method":"test.add","params":[1,1]},
+			{"jsonrpc":"2.0","id":2,"method":"test.add","params":[2,2]}
+		]`)
+		require.Equal(t, http.StatusOK, status)
+		var resps []jsonrpc.Response
+		require.NoError(t, json.Unmarshal(out, &resps))
+		require.Len(t, resps, 2)
+		assert.Equal(t, json.RawMessage(`2`), resps[0].Result)
+		assert.Equal(t, json.RawMessage(`4`), resps[1].Result)
+	})
+}
+
+func TestHTTPErrors(t *testing.T) {
+	tf.UnitTest(t)
+	server := newTestServer()
+	defer server.Close()
+
+	for name, tc := range map[string]struct {
+		body string
+		code int
+	}{
+		"parse error":     {`{"jsonrpc":`, jsonrpc.CodeParseError},
+		"invalid request": {`{"jsonrpc":"1.0","id":1,"method":"test.add"}`, jsonrpc.CodeInvalidRequest},
+		"unknown method":  {`{"jsonrpc":"2.0","id":1,"method":"test.missing"}`, jsonrpc.CodeMethodNotFound},
+		"invalid params":  {`{"jsonrpc":"2.0","id":1,"method":"test.add","params":[1,2,3]}`, jsonrpc.CodeInvalidParams},
+		"method error":    {`{"jsonrpc":"2.0","id":1,"method":"test.fail"}`, jsonrpc.CodeServerError},
+		"subscription":    {`{"jsonrpc":"2.0","id":1,"method":"test.count"}`, jsonrpc.CodeInvalidRequest},
+	} {
+		t.Run(name, func(t *testing.T) {
+			resp := postRequest(t, server, tc.body)
+			require.NotNil(t, resp.Error)
+			assert.Equal(t, tc.code, resp.Error.Code)
+			assert.Nil(t, resp.Result)
+		})
+	}
+}
+
+func TestHTTPRejectsCrossSiteRequests(t *testing.T) {
+	tf.UnitTest(t)
+	s := jsonrpc.NewServer(func(r *http.Request) bool {
+		origin := r.Header.Get("Origin")
+		return origin == "" || origin == "http://allowed.example"
+	})
+	server := httptest.NewServer(s)
+	defer server.Close()
+
+	for name, tc := range map[string]struct {
+		origin      string
+		contentType string
+		status      int
+	}{
+		"allowed":              {"http://allowed.example", "application/json", http.StatusOK},
+		"charset":              {"", "application/json; charset=utf-8", http.StatusOK},
+		"disallowed origin":    {"http://evil.example", "application/json", http.StatusForbidden},
+		"form content type":    {"", "application/x-www-form-urlencoded", http.StatusUnsupportedMediaType},
+		"missing content type": {"", "", http.StatusUnsupportedMediaType},
+	} {
+		t.Run(name, func(t *testing.T) {
+			req, err := http.NewRequest(http.MethodPost, server.URL, strings.NewReader(`{"jsonrpc":"2.0","id":1,"method":"rpc.discover"}`))
+			require.NoError(t, err)
+			if tc.origin != "" {
+				req.Header.Set("Origin", tc.origin)
+			}
+			if tc.contentType != "" {
+				req.Header.Set("Content-Type", tc.contentType)
+			}
+			resp, err := http.DefaultClient.Do(req)
+			require.NoError(t, err)
+			defer resp.Body.Close() // nolint: errcheck
+			assert.Equal(t, tc.status, resp.StatusCode)
+		})
+	}
+}
+
+func TestDiscover(t *testing.T) {
+	tf.UnitTest(t)
+	server := newTestServer()
+	defer server.Close()
+
+	resp := postRequest(t, server, `{"jsonrpc":"2.0","id":1,"method":"rpc.discover"}`)
+	require.Nil(t, resp.Error)
+	var methods []jsonrpc.MethodInfo
+	require.NoError(t, json.Unmarshal(resp.Result, &methods))
+
+	var names []string
+	for _, m := range methods {
+		names = append(names, m.Name)
+	}
+	assert.Equal(t, []string{"rpc.discover", "test.add", "test.count", "test.fail"}, names)
+	assert.Equal(t, jsonrpc.MethodInfo{
+		Name:        "test.add",
+		Description: "Adds two numbers",
+		Params:      []jsonrpc.ParamInfo{{Name: "a", Type: "int"}, {Name: "b", Type: "int"}},
+		Result:      "int",
+	}, methods[1])
+	assert.True(t, methods[2].Subscription)
+}
+
+func TestWebsocket(t *testing.T) {
+	tf.UnitTest(t)
+	server := newTestServer()
+	defer server.Close()
+	ws, _, err := websocket.DefaultDialer.Dial("ws"+strings.TrimPrefix(server.URL, "http"), nil)
+	require.NoError(t, err)
+	defer ws.Close() // nolint: errcheck
+	require.NoError(t, ws.SetReadDeadline(time.Now().Add(5*time.Second)))
+
+	t.Run("call", func(t *testing.T) {
+		require.NoError(t, ws.WriteMessage(websocket.TextMessage, []byte(`{"jsonrpc":"2.0","id":1,"method":"test.add","params":[1,2]}`)))
+		var resp jsonrpc.Response
+		require.NoError(t, ws.ReadJSON(&resp))
+		assert.Equal(t, json.RawMessage(`3`), resp.Result)
+	})
+
+	t.Run("subscription", func(t *testing.T) {
+		require.NoError(t, ws.WriteMessage(websocket.TextMessage, []byte(`{"jsonrpc":"2.0","id":2,"method":"test.count","params":{"to":3}}`)))
+		var resp jsonrpc.Response
+		require.NoError(t, ws.ReadJSON(&resp))
+		require.Nil(t, resp.Error)
+		var id string
+		require.NoError(t, json.Unmarshal(resp.Result, &id))
+
+		for i := 1; i <= 3; i++ {
+			var note jsonrpc.Notification
+			require.NoError(t, ws.ReadJSON(&note))
+			assert.Equal(t, jsonrpc.NotificationMethod, note.Method)
+			assert.Equal(t, id, note.Params.Subscription)
+			assert.Equal(t, json.RawMessage(strconv.Itoa(i)), note.Params.Result)
+		}
+	})
+
+	t.Run("unsubscribe", func(t *testing.T) {
+		require.NoError(t, ws.WriteMessage(websocket.TextMessage, []byte(`{"jsonrpc":"2.0","id":3,"method":"test.count"}`)))
+		var resp jsonrpc.Response
+		require.NoError(t, ws.ReadJSON(&resp))
+		require.Nil(t, resp.Error)
+		var id string
+		require.NoError(t, json.Unmarshal(resp.Result, &id))
+
+		unsubscribe, err := json.Marshal(jsonrpc.Request{
+			JSONRPC: jsonrpc.Version,
+			ID:      json.RawMessage(`4`),
+			Method:  jsonrpc.UnsubscribeMethod,
+			Params:  json.RawMessage(`["` + id + `"]`),
+		})
+		require.NoError(t, err)
+		require.NoError(t, ws.WriteMessage(websocket.TextMessage, unsubscribe))
+
+		// Notifications sent before the unsubscription arrive first.
+		for {
+			var msg struct {
+				ID     json.RawMessage `json:"id"`
+				Result json.RawMessage `json:"result"`
+			}
+			require.NoError(t, ws.ReadJSON(&msg))
+			if msg.ID != nil {
+				assert.Equal(t, json.RawMessage(`4`), msg.ID)
+				assert.Equal(t, json.RawMessage(`true`), msg.Result)
+				break
+			}
+		}
+	})
+}
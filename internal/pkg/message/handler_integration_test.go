@@ -5,6 +5,7 @@ import (
 	"testing"
 	"time"
 
+	"github.com/filecoin-project/go-address"
 	"github.com/filecoin-project/specs-actors/actors/builtin"
 	"github.com/filecoin-project/specs-actors/actors/util/adt"
 
@@ -40,7 +41,7 @@ func TestNewHeadHandlerIntegration(t *testing.T) {
 	gasPrice := types.NewGasPrice(1)
 	gasUnits := types.GasUnits(1000)
 
-	makeHandler := func(provider *message.FakeProvider, root block.TipSet) *message.HeadHandler {
+	makeHandlerWithSigner := func(provider *message.FakeProvider, root block.TipSet, signer types.Signer) *message.HeadHandler {
 		mpool := message.NewPool(config.NewDefaultConfig().Mpool, th.NewMockMessagePoolValidator())
 		inbox := message.NewInbox(mpool, maxAge, provider, provider)
 		queue := message.NewQueue()
@@ -51,6 +52,9 @@ func TestNewHeadHandlerIntegration(t *testing.T) {
 
 		return message.NewHeadHandler(inbox, outbox, provider, root)
 	}
+	makeHandler := func(provider *message.FakeProvider, root block.TipSet) *message.HeadHandler {
+		return makeHandlerWithSigner(provider, root, signer)
+	}
 
 	t.Run("test send after reverted message", func(t *testing.T) {
 		provider := message.NewFakeProvider(t)
@@ -115,6 +119,61 @@ func TestNewHeadHandlerIntegration(t *testing.T) {
 		assert.True(t, msg2.Equals(restoredQueue[1].Msg))
 	})
 
+	t.Run("test send from BLS sender after reverted message", func(t *testing.T) {
+		blsSigner := types.NewMockSigner(types.MustGenerateMixedKeyInfo(1, 1))
+		blsSender := blsSigner.Addresses[0]
+		require.Equal(t, address.BLS, blsSender.Protocol())
+
+		provider := message.NewFakeProvider(t)
+		root := provider.NewGenesis()
+		actr := actor.NewActor(builtin.AccountActorCodeID, abi.NewTokenAmount(0))
+		provider.SetHeadAndActor(t, root.Key(), blsSender, actr)
+
+		handler := makeHandlerWithSigner(provider, root, &blsSigner)
+		outbox := handler.Outbox
+		inbox := handler.Inbox
+
+		// The CID of a BLS message is that of the unsigned message, as in blocks.
+		mid1, donePub1, err := outbox.Send(ctx, blsSender, dest, types.ZeroAttoFIL, gasPrice, gasUnits, true, abi.MethodNum(9000001), &adt.EmptyValue{})
+		require.NoError(t, err)
+		require.NoError(t, <-donePub1)
+		msg1, found := inbox.Pool().Get(mid1)
+		require.True(t, found)
+		unsignedCid, err := msg1.Message.Cid()
+		require.NoError(t, err)
+		assert.Equal(t, unsignedCid, mid1)
+
+		// Receive the message, unsigned, in a block.
+		left := provider.BuildOneOn(root, func(b *chain.BlockBuilder) {
+			b.AddMessages([]*types.SignedMessage{}, []*types.UnsignedMessage{&msg1.Message})
+		})
+		require.NoError(t, handler.HandleNewHead(ctx, left))
+		assert.Empty(t, outbox.Queue().List(blsSender)) // Gone from queue.
+		_, found = inbox.Pool().Get(mid1)
+		assert.False(t, found) // Gone from pool.
+
+		// Re-org the chain to un-mine that message: it returns signed to the queue and pool.
+		right := provider.BuildOneOn(root, func(b *chain.BlockBuilder) {
+			// No messages.
+		})
+		require.NoError(t, handler.HandleNewHead(ctx, right))
+		restoredQueue := outbox.Queue().List(blsSender)
+		require.Equal(t, 1, len(restoredQueue))
+		assert.True(t, msg1.Equals(restoredQueue[0].Msg))
+		restored, found := inbox.Pool().Get(mid1)
+		require.True(t, found)
+		assert.True(t, msg1.Equals(restored))
+
+		// The next message takes the next nonce.
+		provider.SetHeadAndActor(t, right.Key(), blsSender, actr)
+		_, donePub2, err := outbox.Send(ctx, blsSender, dest, types.ZeroAttoFIL, gasPrice, gasUnits, true, abi.MethodNum(9000002), &adt.EmptyValue{})
+		require.NoError(t, err)
+		require.NoError(t, <-donePub2)
+		restoredQueue = outbox.Queue().List(blsSender)
+		require.Equal(t, 2, len(restoredQueue))
+		assert.Equal(t, uint64(1), restoredQueue[1].Msg.Message.CallSeqNum)
+	})
+
 	t.Run("ignores empty tipset", func(t *testing.T) {
 		provider := message.NewFakeProvider(t)
 		root := provider.NewGenesis()
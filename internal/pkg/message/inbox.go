@@ -26,6 +26,8 @@ type Inbox struct {
 	// Provides tipsets for chain traversal.
 	chain           chainProvider
 	messageProvider messageProvider
+	// Signatures of the BLS messages removed from the pool when mined.
+	signatures *blsSignatures
 }
 
 // messageProvider provides message collections given their cid.
@@ -40,6 +42,7 @@ func NewInbox(pool *Pool, maxAgeRounds uint, chain chainProvider, messages messa
 		maxAgeTipsets:   maxAgeRounds,
 		chain:           chain,
 		messageProvider: messages,
+		signatures:      newBLSSignatures(maxAgeRounds),
 	}
 }
 
@@ -67,6 +70,8 @@ func (ib *Inbox) Pool() *Pool {
 // HandleNewHead updates the message pool in response to a new head tipset.
 // This removes messages from the pool that are found in the newly adopted chain and adds back
 // those from the removed chain (if any) that do not appear in the new chain.
+// BLS messages are added back only if they were in the pool when mined, as blocks do not carry
+// their signatures.
 // The `oldChain` and `newChain` lists are expected in descending height order, and each may be empty.
 func (ib *Inbox) HandleNewHead(ctx context.Context, oldChain, newChain []block.TipSet) error {
 	chainHeight, err := reorgHeight(oldChain, newChain)
@@ -76,19 +81,21 @@ func (ib *Inbox) HandleNewHead(ctx context.Context, oldChain, newChain []block.T
 
 	// Add all message from the old tipsets to the message pool, so they can be mined again.
 	for _, tipset := range oldChain {
-		for i := 0; i < tipset.Len(); i++ {
-			block := tipset.At(i)
-			secpMsgs, _, err := ib.messageProvider.LoadMessages(ctx, block.Messages.Cid)
-			if err != nil {
-				return err
+		minedMsgs, err := tipSetMessages(ctx, ib.messageProvider, tipset)
+		if err != nil {
+			return err
+		}
+		for _, mined := range minedMsgs {
+			msg, ok := ib.signatures.signed(mined)
+			if !ok {
+				log.Debugf("Not restoring BLS message from %s with nonce %d, its signature is unknown", mined.msg.From, mined.msg.CallSeqNum)
+				continue
 			}
-			for _, msg := range secpMsgs {
-				_, err = ib.pool.Add(ctx, msg, chainHeight)
-				if err != nil {
-					// Messages from the removed chain are frequently invalidated, e.g. because that
-					// same message is already mined on the new chain.
-					log.Debug(err)
-				}
+			_, err = ib.pool.Add(ctx, msg, chainHeight)
+			if err != nil {
+				// Messages from the removed chain are frequently invalidated, e.g. because that
+				// same message is already mined on the new chain.
+				log.Debug(err)
 			}
 		}
 	}
@@ -97,23 +104,29 @@ func (ib *Inbox) HandleNewHead(ctx context.Context, oldChain, newChain []block.T
 	// Cid() can error, so collect all the CIDs up front.
 	var removeCids []cid.Cid
 	for _, tipset := range newChain {
-		for i := 0; i < tipset.Len(); i++ {
-			secpMsgs, _, err := ib.messageProvider.LoadMessages(ctx, tipset.At(i).Messages.Cid)
+		height, err := tipset.Height()
+		if err != nil {
+			return err
+		}
+		minedMsgs, err := tipSetMessages(ctx, ib.messageProvider, tipset)
+		if err != nil {
+			return err
+		}
+		for _, mined := range minedMsgs {
+			c, err := mined.cid()
 			if err != nil {
 				return err
 			}
-			for _, msg := range secpMsgs {
-				cid, err := msg.Cid()
-				if err != nil {
-					return err
-				}
-				removeCids = append(removeCids, cid)
+			if pooled, ok := ib.pool.Get(c); ok {
+				ib.signatures.add(pooled, height)
 			}
+			removeCids = append(removeCids, c)
 		}
 	}
 	for _, c := range removeCids {
 		ib.pool.Remove(c)
 	}
+	ib.signatures.prune(chainHeight)
 
 	// prune all messages that have been in the pool too long
 	if len(newChain) > 0 {
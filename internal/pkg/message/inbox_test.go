@@ -6,6 +6,7 @@ import (
 	"strconv"
 	"testing"
 
+	"github.com/filecoin-project/go-address"
 	"github.com/filecoin-project/go-filecoin/internal/pkg/block"
 	"github.com/filecoin-project/specs-actors/actors/abi"
 	"github.com/stretchr/testify/assert"
@@ -17,6 +18,7 @@ import (
 	th "github.com/filecoin-project/go-filecoin/internal/pkg/testhelpers"
 	tf "github.com/filecoin-project/go-filecoin/internal/pkg/testhelpers/testflags"
 	"github.com/filecoin-project/go-filecoin/internal/pkg/types"
+	"github.com/filecoin-project/go-filecoin/internal/pkg/vm"
 )
 
 func TestUpdateMessagePool(t *testing.T) {
@@ -386,6 +388,39 @@ func TestUpdateMessagePool(t *testing.T) {
 		assert.NoError(t, ib.HandleNewHead(ctx, nil, []block.TipSet{next}))
 		assertPoolEquals(t, p, m[1:]...)
 	})
+
+	t.Run("BLS and secp messages are removed when mined and restored on re-org", func(t *testing.T) {
+		// Msg pool: [bls0, bls1, secp0], Chain: b[]
+		// to
+		// Msg pool: [bls1],              Chain: b[bls0, secp0]
+		// to
+		// Msg pool: [bls0, bls1, secp0], Chain: b[]
+		chainProvider, parent := newProviderWithGenesis(t)
+		p := message.NewPool(config.NewDefaultConfig().Mpool, th.NewMockMessagePoolValidator())
+		ib := message.NewInbox(p, 10, chainProvider, chainProvider)
+
+		mm := vm.NewMessageMaker(t, types.MustGenerateMixedKeyInfo(1, 1))
+		blsAddr, secpAddr := mm.Addresses()[0], mm.Addresses()[1]
+		require.Equal(t, address.BLS, blsAddr.Protocol())
+		bls0, bls1 := mm.NewSignedMessage(blsAddr, 0), mm.NewSignedMessage(blsAddr, 1)
+		secp0 := mm.NewSignedMessage(secpAddr, 0)
+		requireAdd(t, ib, bls0, bls1, secp0)
+
+		mined := chainProvider.BuildOneOn(parent, func(b *chain.BlockBuilder) {
+			b.AddMessages([]*types.SignedMessage{secp0}, []*types.UnsignedMessage{&bls0.Message})
+		})
+		assert.NoError(t, ib.HandleNewHead(ctx, nil, []block.TipSet{mined}))
+		assertPoolEquals(t, p, bls1)
+
+		fork := chainProvider.BuildOneOn(parent, nil)
+		assert.NoError(t, ib.HandleNewHead(ctx, []block.TipSet{mined}, []block.TipSet{fork}))
+		assertPoolEquals(t, p, bls0, bls1, secp0)
+		c, err := bls0.Cid()
+		require.NoError(t, err)
+		restored, ok := p.Get(c)
+		require.True(t, ok)
+		assert.True(t, bls0.Equals(restored))
+	})
 }
 
 func newProviderWithGenesis(t *testing.T) (*message.FakeProvider, block.TipSet) {
@@ -0,0 +1,202 @@
+package message
+
+import (
+	"context"
+	"sync"
+
+	"github.com/filecoin-project/specs-actors/actors/abi"
+	"github.com/ipfs/go-cid"
+	"github.com/ipfs/go-datastore"
+	"github.com/ipfs/go-datastore/namespace"
+	"github.com/ipfs/go-datastore/query"
+	"github.com/pkg/errors"
+
+	"github.com/filecoin-project/go-filecoin/internal/pkg/block"
+	"github.com/filecoin-project/go-filecoin/internal/pkg/crypto"
+	"github.com/filecoin-project/go-filecoin/internal/pkg/encoding"
+	"github.com/filecoin-project/go-filecoin/internal/pkg/types"
+)
+
+// minedMessage is a message as included in a block. Blocks keep secp messages signed, but only
+// the aggregate of the signatures of their BLS messages, so `signed` is nil for those.
+type minedMessage struct {
+	msg    *types.UnsignedMessage
+	signed *types.SignedMessage
+}
+
+// equals tests whether a mined message is `other`, ignoring the signature of a BLS message.
+func (m minedMessage) equals(other *types.SignedMessage) bool {
+	if m.signed != nil {
+		return m.signed.Equals(other)
+	}
+	return m.msg.Equals(&other.Message)
+}
+
+// cid returns the CID of the message, which is the CID of the unsigned message for BLS messages.
+func (m minedMessage) cid() (cid.Cid, error) {
+	if m.signed != nil {
+		return m.signed.Cid()
+	}
+	return m.msg.Cid()
+}
+
+// tipSetMessages loads the messages of a tipset in the order they are applied: block by block,
+// with the BLS messages of a block before its secp messages. A message included by several
+// blocks appears once, as it is applied once.
+func tipSetMessages(ctx context.Context, provider messageProvider, ts block.TipSet) ([]minedMessage, error) {
+	var mined []minedMessage
+	seen := make(map[cid.Cid]struct{})
+	add := func(m minedMessage) error {
+		c, err := m.cid()
+		if err != nil {
+			return err
+		}
+		if _, ok := seen[c]; !ok {
+			seen[c] = struct{}{}
+			mined = append(mined, m)
+		}
+		return nil
+	}
+
+	for i := 0; i < ts.Len(); i++ {
+		secpMsgs, blsMsgs, err := provider.LoadMessages(ctx, ts.At(i).Messages.Cid)
+		if err != nil {
+			return nil, err
+		}
+		for _, msg := range blsMsgs {
+			if err := add(minedMessage{msg: msg}); err != nil {
+				return nil, err
+			}
+		}
+		for _, msg := range secpMsgs {
+			if err := add(minedMessage{msg: &msg.Message, signed: msg}); err != nil {
+				return nil, err
+			}
+		}
+	}
+	return mined, nil
+}
+
+// blsSignatures remembers the signatures of BLS messages seen mined, which blocks do not carry,
+// so that the messages can be restored in signed form when a re-org reverts their blocks.
+// Signatures are forgotten after `maxAge` rounds, re-orgs deeper than that being unexpected.
+// They are written to a datastore, when there is one, so that they survive restarts.
+type blsSignatures struct {
+	lk     sync.Mutex
+	maxAge abi.ChainEpoch
+	sigs   map[cid.Cid]minedSignature
+	// Holds a copy of the signatures, nil if they are in memory only.
+	ds datastore.Datastore
+}
+
+type minedSignature struct {
+	_      struct{} `cbor:",toarray"`
+	Sig    crypto.Signature
+	Height abi.ChainEpoch
+}
+
+// blsSignaturesPrefix is the datastore namespace of the signatures of mined BLS messages.
+var blsSignaturesPrefix = datastore.NewKey("/outbox/blssignatures")
+
+func newBLSSignatures(maxAge uint) *blsSignatures {
+	return &blsSignatures{
+		maxAge: abi.ChainEpoch(maxAge),
+		sigs:   make(map[cid.Cid]minedSignature),
+	}
+}
+
+// newPersistentBLSSignatures creates a set of signatures holding those previously written to
+// `ds`, and which writes the signatures added from now on to it.
+func newPersistentBLSSignatures(maxAge uint, ds datastore.Datastore) (*blsSignatures, error) {
+	s := newBLSSignatures(maxAge)
+	s.ds = namespace.Wrap(ds, blsSignaturesPrefix)
+
+	results, err := s.ds.Query(query.Query{})
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to query mined message signatures")
+	}
+	defer func() { _ = results.Close() }()
+
+	for res := range results.Next() {
+		if res.Error != nil {
+			return nil, errors.Wrap(res.Error, "failed to read mined message signatures")
+		}
+		c, err := cid.Decode(datastore.RawKey(res.Key).BaseNamespace())
+		if err != nil {
+			return nil, errors.Wrapf(err, "invalid mined message signature key %s", res.Key)
+		}
+		var known minedSignature
+		if err := encoding.Decode(res.Value, &known); err != nil {
+			return nil, errors.Wrapf(err, "failed to decode mined message signature at %s", res.Key)
+		}
+		s.sigs[c] = known
+	}
+	return s, nil
+}
+
+// add remembers the signature of `msg`, mined at `height`, if it is BLS-signed.
+func (s *blsSignatures) add(msg *types.SignedMessage, height abi.ChainEpoch) {
+	if msg.Signature.Type != crypto.SigTypeBLS {
+		return
+	}
+	c, err := msg.Cid()
+	if err != nil {
+		log.Warnf("failed to compute cid of mined message: %s", err)
+		return
+	}
+
+	s.lk.Lock()
+	defer s.lk.Unlock()
+	known := minedSignature{Sig: msg.Signature, Height: height}
+	s.sigs[c] = known
+	if s.ds == nil {
+		return
+	}
+	// A signature that fails to be written is still known until the node restarts.
+	raw, err := encoding.Encode(known)
+	if err == nil {
+		err = s.ds.Put(datastore.NewKey(c.String()), raw)
+	}
+	if err != nil {
+		log.Errorf("failed to persist signature of mined message %s: %s", c, err)
+	}
+}
+
+// signed returns a mined message in signed form. Returns false for a BLS message whose signature
+// is not known, as it was not sent or received by this node, or is too old.
+func (s *blsSignatures) signed(mined minedMessage) (*types.SignedMessage, bool) {
+	if mined.signed != nil {
+		return mined.signed, true
+	}
+	c, err := mined.msg.Cid()
+	if err != nil {
+		log.Warnf("failed to compute cid of mined message: %s", err)
+		return nil, false
+	}
+
+	s.lk.Lock()
+	defer s.lk.Unlock()
+	known, ok := s.sigs[c]
+	if !ok {
+		return nil, false
+	}
+	return &types.SignedMessage{Message: *mined.msg, Signature: known.Sig}, true
+}
+
+// prune forgets the signatures of messages mined more than `maxAge` rounds before `height`.
+func (s *blsSignatures) prune(height abi.ChainEpoch) {
+	s.lk.Lock()
+	defer s.lk.Unlock()
+	for c, known := range s.sigs {
+		if known.Height+s.maxAge < height {
+			delete(s.sigs, c)
+			if s.ds == nil {
+				continue
+			}
+			// A signature left behind is deleted again at the next prune after a restart.
+			if err := s.ds.Delete(datastore.NewKey(c.String())); err != nil {
+				log.Errorf("failed to delete signature of mined message %s: %s", c, err)
+			}
+		}
+	}
+}
@@ -10,11 +10,13 @@ import (
 	"github.com/pkg/errors"
 
 	"github.com/filecoin-project/go-filecoin/internal/pkg/block"
+	"github.com/filecoin-project/go-filecoin/internal/pkg/chain"
 	"github.com/filecoin-project/go-filecoin/internal/pkg/encoding"
 	"github.com/filecoin-project/go-filecoin/internal/pkg/journal"
 	"github.com/filecoin-project/go-filecoin/internal/pkg/metrics"
 	"github.com/filecoin-project/go-filecoin/internal/pkg/types"
 	"github.com/filecoin-project/go-filecoin/internal/pkg/vm/actor"
+	"github.com/filecoin-project/go-filecoin/internal/pkg/wallet"
 )
 
 // Outbox validates and marshals messages for sending and maintains the outbound message queue.
@@ -118,6 +120,10 @@ func (ob *Outbox) Send(ctx context.Context, from, to address.Address, value type
 	rawMsg := types.NewMeteredMessage(from, to, nonce, value, method, encodedParams, gasPrice, gasLimit)
 	signed, err := types.NewSignedMessage(*rawMsg, ob.signer)
 
+	if wallet.IsLocked(err) {
+		// Keep the cause so callers can tell the wallet must be unlocked.
+		return cid.Undef, nil, errors.Wrapf(err, "cannot sign message from %s", from)
+	}
 	if err != nil {
 		return cid.Undef, nil, errors.Wrap(err, "failed to sign message")
 	}
@@ -165,7 +171,7 @@ func sendSignedMsg(ctx context.Context, ob *Outbox, signed *types.SignedMessage,
 	pubErrCh := make(chan error)
 
 	go func() {
-		err = ob.publisher.Publish(ctx, signed, height, bcast)
+		err = ob.publish(ctx, signed, height, bcast)
 		if err != nil {
 			log.Errorf("error: %s publishing message %s", err, c.String())
 		}
@@ -176,6 +182,66 @@ func sendSignedMsg(ctx context.Context, ob *Outbox, signed *types.SignedMessage,
 	return c, pubErrCh, nil
 }
 
+// publish publishes a queued message, counting the attempt in the queue.
+func (ob *Outbox) publish(ctx context.Context, signed *types.SignedMessage, height abi.ChainEpoch, bcast bool) error {
+	if _, err := ob.queue.RecordAttempt(signed.Message.From, signed.Message.CallSeqNum); err != nil {
+		log.Warnf("failed to record publication of message from %s with nonce %d: %s", signed.Message.From, signed.Message.CallSeqNum, err)
+	}
+	return ob.publisher.Publish(ctx, signed, height, bcast)
+}
+
+// Resend publishes and broadcasts again the messages queued for `sender`, for example when they
+// seem stuck because they were lost by the network. Returns the number of messages published.
+func (ob *Outbox) Resend(ctx context.Context, sender address.Address) (int, error) {
+	height, err := tipsetHeight(ob.chains, ob.chains.GetHead())
+	if err != nil {
+		return 0, errors.Wrap(err, "failed to get block height")
+	}
+
+	sent := 0
+	for _, qm := range ob.queue.List(sender) {
+		if err := ob.publish(ctx, qm.Msg, height, true); err != nil {
+			return sent, errors.Wrapf(err, "failed to publish message from %s with nonce %d", sender, qm.Msg.Message.CallSeqNum)
+		}
+		sent++
+	}
+	return sent, nil
+}
+
+// Restore brings a queue loaded from a previous run up to date with the current head and
+// publishes its messages again. The tipsets mined since the oldest message was queued are
+// handed to the queue policy, which removes the messages they include and expires those too old.
+// As the node may not have synced yet, the policy then also removes the messages whose nonces
+// its senders have already used at the head.
+func (ob *Outbox) Restore(ctx context.Context) error {
+	if ob.queue.Size() == 0 {
+		return nil
+	}
+
+	head, err := ob.chains.GetTipSet(ob.chains.GetHead())
+	if err != nil {
+		return errors.Wrap(err, "failed to get head tipset")
+	}
+	newTips, err := chain.CollectTipSetsOfHeightAtLeast(ctx, chain.IterAncestors(ctx, ob.chains, head), abi.ChainEpoch(ob.queue.Oldest()+1))
+	if err != nil {
+		return errors.Wrap(err, "failed to collect tipsets mined since messages were queued")
+	}
+	if err := ob.policy.HandleNewHead(ctx, ob.queue, nil, newTips); err != nil {
+		return errors.Wrap(err, "failed to reconcile outbound queue with the chain")
+	}
+	if err := ob.policy.HandleRestore(ctx, ob.queue, head.Key(), ob.actors); err != nil {
+		return errors.Wrap(err, "failed to reconcile outbound queue with the actors at the head")
+	}
+
+	for _, sender := range ob.queue.Queues() {
+		if _, err := ob.Resend(ctx, sender); err != nil {
+			// Other senders' messages may still go through.
+			log.Errorf("failed to republish messages from %s: %s", sender, err)
+		}
+	}
+	return nil
+}
+
 // HandleNewHead maintains the message queue in response to a new head tipset.
 func (ob *Outbox) HandleNewHead(ctx context.Context, oldTips, newTips []block.TipSet) error {
 	return ob.policy.HandleNewHead(ctx, ob.queue, oldTips, newTips)
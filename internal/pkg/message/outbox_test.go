@@ -6,10 +6,12 @@ import (
 	"testing"
 	"time"
 
+	"github.com/filecoin-project/go-address"
 	"github.com/filecoin-project/specs-actors/actors/builtin"
 	"github.com/filecoin-project/specs-actors/actors/util/adt"
 
 	"github.com/filecoin-project/specs-actors/actors/abi"
+	"github.com/ipfs/go-datastore"
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
 
@@ -22,7 +24,9 @@ import (
 	th "github.com/filecoin-project/go-filecoin/internal/pkg/testhelpers"
 	tf "github.com/filecoin-project/go-filecoin/internal/pkg/testhelpers/testflags"
 	"github.com/filecoin-project/go-filecoin/internal/pkg/types"
+	"github.com/filecoin-project/go-filecoin/internal/pkg/vm"
 	vmaddr "github.com/filecoin-project/go-filecoin/internal/pkg/vm/address"
+	"github.com/filecoin-project/go-filecoin/internal/pkg/wallet"
 )
 
 func newOutboxTestJournal(t *testing.T) journal.Writer {
@@ -48,6 +52,30 @@ func TestOutbox(t *testing.T) {
 		assert.False(t, cid.Defined())
 	})
 
+	t.Run("locked wallet reported", func(t *testing.T) {
+		ds := datastore.NewMapDatastore()
+		plain, err := wallet.NewDSBackend(ds)
+		require.NoError(t, err)
+		sender, err := plain.NewAddress(address.SECP256K1)
+		require.NoError(t, err)
+		require.NoError(t, wallet.EncryptDatastore(ds, "passphrase"))
+		backend, err := wallet.NewEncryptedBackend(ds, th.NewFakeClock(time.Unix(1234567890, 0)))
+		require.NoError(t, err)
+
+		provider := message.NewFakeProvider(t)
+		head := provider.BuildOneOn(block.UndefTipSet, func(b *chain.BlockBuilder) {
+			b.IncHeight(1)
+		})
+		provider.SetHeadAndActor(t, head.Key(), sender, actor.NewActor(builtin.AccountActorCodeID, abi.NewTokenAmount(0)))
+
+		ob := message.NewOutbox(wallet.New(backend), message.FakeValidator{}, message.NewQueue(), &message.MockPublisher{},
+			message.NullPolicy{}, provider, provider, newOutboxTestJournal(t))
+
+		_, _, err = ob.Send(context.Background(), sender, sender, types.ZeroAttoFIL, types.NewGasPrice(0), types.GasUnits(0), true, builtin.MethodSend, &adt.EmptyValue{})
+		require.Error(t, err)
+		assert.True(t, wallet.IsLocked(err))
+	})
+
 	t.Run("send message enqueues and calls Publish, but respects bcast flag for broadcasting", func(t *testing.T) {
 		w, _ := types.NewMockSignersAndKeyInfo(1)
 		sender := w.Addresses[0]
@@ -162,4 +190,77 @@ func TestOutbox(t *testing.T) {
 		assert.Error(t, err)
 		assert.Contains(t, err.Error(), "account or empty")
 	})
+
+	t.Run("restore removes mined messages and publishes the rest", func(t *testing.T) {
+		ctx := context.Background()
+		mm := vm.NewMessageMaker(t, types.MustGenerateKeyInfo(2, 42))
+		alice, bob := mm.Addresses()[0], mm.Addresses()[1]
+		fromAlice := []*types.SignedMessage{mm.NewSignedMessage(alice, 0), mm.NewSignedMessage(alice, 1)}
+		fromBob := mm.NewSignedMessage(bob, 0)
+
+		provider := message.NewFakeProvider(t)
+		root := provider.NewGenesis()
+		queue, err := message.NewPersistentQueue(datastore.NewMapDatastore())
+		require.NoError(t, err)
+		require.NoError(t, queue.Enqueue(ctx, fromAlice[0], 0))
+		require.NoError(t, queue.Enqueue(ctx, fromAlice[1], 0))
+		require.NoError(t, queue.Enqueue(ctx, fromBob, 0))
+
+		// The first message of alice was mined while the node was down.
+		head := provider.BuildOneOn(root, func(b *chain.BlockBuilder) {
+			b.AddMessages([]*types.SignedMessage{fromAlice[0]}, []*types.UnsignedMessage{})
+		})
+		provider.SetHead(head.Key())
+
+		publisher := &message.MockPublisher{}
+		policy := message.NewMessageQueuePolicy(provider, message.OutboxMaxAgeRounds)
+		ob := message.NewOutbox(mm.Signer(), message.FakeValidator{}, queue, publisher, policy, provider, provider, newOutboxTestJournal(t))
+		require.NoError(t, ob.Restore(ctx))
+
+		remaining := queue.List(alice)
+		require.Len(t, remaining, 1)
+		assert.Equal(t, fromAlice[1], remaining[0].Msg)
+		assert.Equal(t, uint64(1), remaining[0].Attempts)
+		require.Len(t, queue.List(bob), 1)
+		assert.Equal(t, uint64(1), queue.List(bob)[0].Attempts)
+		assert.Equal(t, abi.ChainEpoch(1), publisher.Height)
+		assert.True(t, publisher.Bcast)
+
+		sent, err := ob.Resend(ctx, alice)
+		require.NoError(t, err)
+		assert.Equal(t, 1, sent)
+		assert.Equal(t, fromAlice[1], publisher.Message)
+		assert.Equal(t, uint64(2), queue.List(alice)[0].Attempts)
+	})
+
+	t.Run("restore removes messages with nonces used at the head", func(t *testing.T) {
+		ctx := context.Background()
+		mm := vm.NewMessageMaker(t, types.MustGenerateKeyInfo(1, 42))
+		alice := mm.Addresses()[0]
+		fromAlice := []*types.SignedMessage{mm.NewSignedMessage(alice, 0), mm.NewSignedMessage(alice, 1), mm.NewSignedMessage(alice, 2)}
+
+		provider := message.NewFakeProvider(t)
+		root := provider.NewGenesis()
+		queue, err := message.NewPersistentQueue(datastore.NewMapDatastore())
+		require.NoError(t, err)
+		for _, msg := range fromAlice {
+			require.NoError(t, queue.Enqueue(ctx, msg, 5))
+		}
+
+		// The node has not synced the tipsets including the first two messages, but the
+		// nonce of alice at its head already accounts for them.
+		aliceActor := actor.NewActor(builtin.AccountActorCodeID, abi.NewTokenAmount(0))
+		aliceActor.CallSeqNum = 2
+		provider.SetHeadAndActor(t, root.Key(), alice, aliceActor)
+
+		publisher := &message.MockPublisher{}
+		policy := message.NewMessageQueuePolicy(provider, message.OutboxMaxAgeRounds)
+		ob := message.NewOutbox(mm.Signer(), message.FakeValidator{}, queue, publisher, policy, provider, provider, newOutboxTestJournal(t))
+		require.NoError(t, ob.Restore(ctx))
+
+		remaining := queue.List(alice)
+		require.Len(t, remaining, 1)
+		assert.Equal(t, fromAlice[2], remaining[0].Msg)
+		assert.Equal(t, fromAlice[2], publisher.Message)
+	})
 }
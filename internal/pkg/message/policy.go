@@ -6,6 +6,7 @@ import (
 	"github.com/filecoin-project/go-address"
 	"github.com/filecoin-project/go-filecoin/internal/pkg/block"
 	"github.com/filecoin-project/specs-actors/actors/abi"
+	"github.com/ipfs/go-datastore"
 	logging "github.com/ipfs/go-log"
 
 	"github.com/filecoin-project/go-filecoin/internal/pkg/chain"
@@ -27,6 +28,10 @@ type QueuePolicy interface {
 	// - `newTips` is a list of tipsets that now form the head of the main chain.
 	// Both lists are in descending height order, down to but not including the common ancestor tipset.
 	HandleNewHead(ctx context.Context, target PolicyTarget, oldTips, newTips []block.TipSet) error
+
+	// HandleRestore updates a message queue loaded from a previous run, whose messages may have
+	// been mined in tipsets never handed to HandleNewHead, against the actors at `head`.
+	HandleRestore(ctx context.Context, target PolicyTarget, head block.TipSetKey, actors actorProvider) error
 }
 
 // PolicyTarget is outbound queue object on which the policy acts.
@@ -34,6 +39,8 @@ type PolicyTarget interface {
 	RemoveNext(ctx context.Context, sender address.Address, expectedNonce uint64) (msg *types.SignedMessage, found bool, err error)
 	Requeue(ctx context.Context, msg *types.SignedMessage, stamp uint64) error
 	ExpireBefore(ctx context.Context, stamp uint64) map[address.Address][]*types.SignedMessage
+	Queues() []address.Address
+	List(sender address.Address) []*Queued
 }
 
 // DefaultQueuePolicy manages a target message queue state in response to changes on the blockchain.
@@ -42,17 +49,32 @@ type PolicyTarget interface {
 // even if the block ends up as an abandoned fork.
 // There is no special handling for re-orgs and messages do not revert to the queue if the block
 // ends up childless (in contrast to the message pool).
+// BLS and secp messages are handled alike. As blocks do not carry the signatures of BLS messages,
+// the policy remembers those of the messages it removes, to requeue them signed after a re-org.
 type DefaultQueuePolicy struct {
 	// Provides messages collections from cids.
 	messageProvider messageProvider
 	// Maximum difference in message stamp from current block height before expiring an address's queue
 	maxAgeRounds uint64
+	// Signatures of the BLS messages removed from the queue.
+	signatures *blsSignatures
 }
 
 // NewMessageQueuePolicy returns a new policy which removes mined messages from the queue and expires
 // messages older than `maxAgeTipsets` rounds.
 func NewMessageQueuePolicy(messages messageProvider, maxAge uint) *DefaultQueuePolicy {
-	return &DefaultQueuePolicy{messages, uint64(maxAge)}
+	return &DefaultQueuePolicy{messages, uint64(maxAge), newBLSSignatures(maxAge)}
+}
+
+// NewPersistentMessageQueuePolicy returns a policy like NewMessageQueuePolicy, which keeps the
+// signatures of the BLS messages it removes in `ds` so that they can be requeued after a re-org
+// following a restart.
+func NewPersistentMessageQueuePolicy(messages messageProvider, maxAge uint, ds datastore.Datastore) (*DefaultQueuePolicy, error) {
+	signatures, err := newPersistentBLSSignatures(maxAge, ds)
+	if err != nil {
+		return nil, err
+	}
+	return &DefaultQueuePolicy{messages, uint64(maxAge), signatures}, nil
 }
 
 // HandleNewHead removes from the queue all messages that have now been mined in new blocks.
@@ -62,49 +84,60 @@ func (p *DefaultQueuePolicy) HandleNewHead(ctx context.Context, target PolicyTar
 		return err
 	}
 
-	// Remove all messages in the new chain from the queue since they have been mined into blocks.
-	// Rearrange the tipsets into ascending height order so messages are discovered in nonce order.
-	chain.Reverse(newTips)
-	for _, tipset := range newTips {
-		for i := 0; i < tipset.Len(); i++ {
-			secpMsgs, _, err := p.messageProvider.LoadMessages(ctx, tipset.At(i).Messages.Cid)
-			if err != nil {
-				return err
-			}
-			for _, minedMsg := range secpMsgs {
-				removed, found, err := target.RemoveNext(ctx, minedMsg.Message.From, minedMsg.Message.CallSeqNum)
-				if err != nil {
-					return err
-				}
-				if found && !minedMsg.Equals(removed) {
-					log.Warnf("Queued message %v differs from mined message %v with same sender & nonce", removed, minedMsg)
-				}
-				// Else if not found, the message was not sent by this node, or has already been removed
-				// from the queue (e.g. a blockchain re-org).
-			}
-		}
-	}
-
-	// Return messages from the old chain back to the queue. This is necessary so that the next nonce
+	// Return messages from the old chain back to the queue, before removing those of the new chain,
+	// which may include some of them again. This is necessary so that the next nonce
 	// implied by the queue+state matches that of the message pool (which will also have the un-mined
 	// message re-instated).
 	// Note that this will include messages that were never sent by this node since the queue doesn't
 	// keep track of "allowed" senders. However, messages from other addresses will expire
-	// harmlessly.
+	// harmlessly. BLS messages whose signatures are unknown, as they were not removed from this
+	// queue, cannot be requeued and are skipped.
 	// See discussion in https://github.com/filecoin-project/go-filecoin/issues/3052
-	// Traverse these in descending height order.
+	// Traverse these in descending height order, and the messages of each tipset in reverse, so
+	// each message is requeued before the previous message from the same sender.
 	for _, tipset := range oldTips {
-		for i := 0; i < tipset.Len(); i++ {
-			secpMsgs, _, err := p.messageProvider.LoadMessages(ctx, tipset.At(i).Messages.Cid)
+		minedMsgs, err := tipSetMessages(ctx, p.messageProvider, tipset)
+		if err != nil {
+			return err
+		}
+		for i := len(minedMsgs) - 1; i >= 0; i-- {
+			restoredMsg, ok := p.signatures.signed(minedMsgs[i])
+			if !ok {
+				log.Debugf("Not requeueing BLS message from %s with nonce %d, its signature is unknown", minedMsgs[i].msg.From, minedMsgs[i].msg.CallSeqNum)
+				continue
+			}
+			err := target.Requeue(ctx, restoredMsg, uint64(chainHeight))
 			if err != nil {
 				return err
 			}
-			for _, restoredMsg := range secpMsgs {
-				err := target.Requeue(ctx, restoredMsg, uint64(chainHeight))
-				if err != nil {
-					return err
+		}
+	}
+
+	// Remove all messages in the new chain from the queue since they have been mined into blocks.
+	// Rearrange the tipsets into ascending height order so messages are discovered in nonce order.
+	chain.Reverse(newTips)
+	for _, tipset := range newTips {
+		height, err := tipset.Height()
+		if err != nil {
+			return err
+		}
+		minedMsgs, err := tipSetMessages(ctx, p.messageProvider, tipset)
+		if err != nil {
+			return err
+		}
+		for _, minedMsg := range minedMsgs {
+			removed, found, err := target.RemoveNext(ctx, minedMsg.msg.From, minedMsg.msg.CallSeqNum)
+			if err != nil {
+				return err
+			}
+			if found {
+				if !minedMsg.equals(removed) {
+					log.Warnf("Queued message %v differs from mined message %v with same sender & nonce", removed, minedMsg.msg)
 				}
+				p.signatures.add(removed, height)
 			}
+			// Else if not found, the message was not sent by this node, or has already been removed
+			// from the queue (e.g. a blockchain re-org).
 		}
 	}
 
@@ -115,6 +148,31 @@ func (p *DefaultQueuePolicy) HandleNewHead(ctx context.Context, target PolicyTar
 			log.Warnf("Outbound message %v expired un-mined after %d rounds", msg, p.maxAgeRounds)
 		}
 	}
+	p.signatures.prune(chainHeight)
+	return nil
+}
+
+// HandleRestore removes from the queue the messages whose nonces are below the nonce of their
+// sender at `head`, which must have been mined already.
+func (p *DefaultQueuePolicy) HandleRestore(ctx context.Context, target PolicyTarget, head block.TipSetKey, actors actorProvider) error {
+	for _, sender := range target.Queues() {
+		act, err := actors.GetActorAt(ctx, head, sender)
+		if err != nil {
+			// The sender may not exist yet at a head behind the network.
+			log.Debugf("Not checking queued messages from %s against the head: %s", sender, err)
+			continue
+		}
+		for _, queued := range target.List(sender) {
+			nonce := queued.Msg.Message.CallSeqNum
+			if nonce >= act.CallSeqNum {
+				break
+			}
+			if _, _, err := target.RemoveNext(ctx, sender, nonce); err != nil {
+				return err
+			}
+			log.Infof("Removed outbound message from %s with nonce %d, mined before the queue was restored", sender, nonce)
+		}
+	}
 	return nil
 }
 
@@ -4,7 +4,9 @@ import (
 	"context"
 	"testing"
 
+	"github.com/filecoin-project/go-address"
 	"github.com/filecoin-project/go-filecoin/internal/pkg/block"
+	"github.com/ipfs/go-datastore"
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
 
@@ -199,6 +201,116 @@ func TestMessageQueuePolicy(t *testing.T) {
 		require.Error(t, err)
 		assert.Contains(t, err.Error(), "nonce 1, expected 2")
 	})
+
+	t.Run("handles mixed protocol senders across re-orgs", func(t *testing.T) {
+		mixed := vm.NewMessageMaker(t, types.MustGenerateMixedKeyInfo(1, 1))
+		blsSender, secpSender := mixed.Addresses()[0], mixed.Addresses()[1]
+		require.Equal(t, address.BLS, blsSender.Protocol())
+
+		blocks := chain.NewBuilder(t, alice)
+		q := message.NewQueue()
+		policy := message.NewMessageQueuePolicy(blocks, 10)
+
+		fromBLS := []*types.SignedMessage{
+			requireEnqueue(q, mixed.NewSignedMessage(blsSender, 0), 100),
+			requireEnqueue(q, mixed.NewSignedMessage(blsSender, 1), 100),
+			requireEnqueue(q, mixed.NewSignedMessage(blsSender, 2), 100),
+		}
+		fromSecp := []*types.SignedMessage{
+			requireEnqueue(q, mixed.NewSignedMessage(secpSender, 0), 100),
+			requireEnqueue(q, mixed.NewSignedMessage(secpSender, 1), 100),
+		}
+
+		root := blocks.BuildOneOn(block.UndefTipSet, func(b *chain.BlockBuilder) {
+			b.IncHeight(100)
+		})
+		// Blocks carry BLS messages unsigned.
+		b1 := blocks.BuildOneOn(root, func(b *chain.BlockBuilder) {
+			b.AddMessages(
+				[]*types.SignedMessage{fromSecp[0]},
+				[]*types.UnsignedMessage{&fromBLS[0].Message, &fromBLS[1].Message},
+			)
+		})
+		b2 := blocks.BuildOneOn(b1, func(b *chain.BlockBuilder) {
+			b.AddMessages(
+				[]*types.SignedMessage{fromSecp[1]},
+				[]*types.UnsignedMessage{&fromBLS[2].Message},
+			)
+		})
+		require.NoError(t, policy.HandleNewHead(ctx, q, nil, []block.TipSet{b2, b1}))
+		assert.Empty(t, q.List(blsSender))
+		assert.Empty(t, q.List(secpSender))
+
+		// A re-org to a fork including only the first BLS message returns the others to the
+		// queue, signed, in nonce order.
+		fork := blocks.BuildOneOn(root, func(b *chain.BlockBuilder) {
+			b.AddMessages([]*types.SignedMessage{}, []*types.UnsignedMessage{&fromBLS[0].Message})
+		})
+		require.NoError(t, policy.HandleNewHead(ctx, q, []block.TipSet{b2, b1}, []block.TipSet{fork}))
+		assert.Equal(t, []*message.Queued{qm(fromBLS[1], 101), qm(fromBLS[2], 101)}, q.List(blsSender))
+		assert.Equal(t, []*message.Queued{qm(fromSecp[0], 101), qm(fromSecp[1], 101)}, q.List(secpSender))
+		largest, found := q.LargestNonce(blsSender)
+		assert.True(t, found)
+		assert.Equal(t, uint64(2), largest)
+
+		// Messages of other BLS senders, whose signatures are unknown, are not requeued.
+		stranger := mm.NewSignedMessage(bob, 0)
+		other := vm.NewMessageMaker(t, types.MustGenerateMixedKeyInfo(1, 1))
+		foreign := other.NewSignedMessage(other.Addresses()[0], 0)
+		b3 := blocks.BuildOneOn(fork, func(b *chain.BlockBuilder) {
+			b.AddMessages([]*types.SignedMessage{stranger}, []*types.UnsignedMessage{&foreign.Message})
+		})
+		fork2 := blocks.BuildOneOn(fork, nil)
+		require.NoError(t, policy.HandleNewHead(ctx, q, nil, []block.TipSet{b3}))
+		require.NoError(t, policy.HandleNewHead(ctx, q, []block.TipSet{b3}, []block.TipSet{fork2}))
+		assert.Empty(t, q.List(other.Addresses()[0]))
+		assert.Equal(t, []*message.Queued{qm(stranger, 102)}, q.List(bob))
+	})
+
+	t.Run("requeues BLS messages after a restart", func(t *testing.T) {
+		mixed := vm.NewMessageMaker(t, types.MustGenerateMixedKeyInfo(1, 1))
+		blsSender := mixed.Addresses()[0]
+		ds := datastore.NewMapDatastore()
+
+		blocks := chain.NewBuilder(t, alice)
+		q, err := message.NewPersistentQueue(ds)
+		require.NoError(t, err)
+		policy, err := message.NewPersistentMessageQueuePolicy(blocks, 10, ds)
+		require.NoError(t, err)
+
+		sent := requireEnqueue(q, mixed.NewSignedMessage(blsSender, 0), 100)
+		root := blocks.BuildOneOn(block.UndefTipSet, func(b *chain.BlockBuilder) {
+			b.IncHeight(100)
+		})
+		b1 := blocks.BuildOneOn(root, func(b *chain.BlockBuilder) {
+			b.AddMessages([]*types.SignedMessage{}, []*types.UnsignedMessage{&sent.Message})
+		})
+		require.NoError(t, policy.HandleNewHead(ctx, q, nil, []block.TipSet{b1}))
+		assert.Empty(t, q.List(blsSender))
+
+		// After a restart, a re-org reverting the block requeues the message signed.
+		q, err = message.NewPersistentQueue(ds)
+		require.NoError(t, err)
+		policy, err = message.NewPersistentMessageQueuePolicy(blocks, 10, ds)
+		require.NoError(t, err)
+		fork := blocks.BuildOneOn(root, nil)
+		require.NoError(t, policy.HandleNewHead(ctx, q, []block.TipSet{b1}, []block.TipSet{fork}))
+		assert.Equal(t, []*message.Queued{qm(sent, 101)}, q.List(blsSender))
+
+		// The signature is forgotten once older than the maximum age, after a restart too.
+		late := fork
+		for i := 0; i < 12; i++ {
+			late = blocks.BuildOneOn(late, nil)
+		}
+		require.NoError(t, policy.HandleNewHead(ctx, q, nil, []block.TipSet{late}))
+		policy, err = message.NewPersistentMessageQueuePolicy(blocks, 10, ds)
+		require.NoError(t, err)
+		b2 := blocks.BuildOneOn(late, func(b *chain.BlockBuilder) {
+			b.AddMessages([]*types.SignedMessage{}, []*types.UnsignedMessage{&sent.Message})
+		})
+		require.NoError(t, policy.HandleNewHead(ctx, q, []block.TipSet{b2}, []block.TipSet{late}))
+		assert.Empty(t, q.List(blsSender))
+	})
 }
 
 func qm(msg *types.SignedMessage, stamp uint64) *message.Queued {
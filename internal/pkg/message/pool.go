@@ -2,10 +2,12 @@ package message
 
 import (
 	"context"
+	"sort"
 	"sync"
 
 	"github.com/filecoin-project/go-address"
 	"github.com/filecoin-project/specs-actors/actors/abi"
+	specsbig "github.com/filecoin-project/specs-actors/actors/abi/big"
 	"github.com/ipfs/go-cid"
 	"github.com/pkg/errors"
 
@@ -15,18 +17,26 @@ import (
 )
 
 var mpSize = metrics.NewInt64Gauge("message_pool_size", "The size of the message pool")
+var mpEvicted = metrics.NewInt64Counter("message_pool_evicted", "The number of messages evicted from a full message pool")
+var mpReplaced = metrics.NewInt64Counter("message_pool_replaced", "The number of messages replaced by fee in the message pool")
 
 // PoolValidator defines a validator that ensures a message can go through the pool.
 type PoolValidator interface {
 	Validate(ctx context.Context, msg *types.SignedMessage) error
 }
 
-// Pool keeps an unordered, de-duplicated set of Messages and supports removal by CID.
+// Pool keeps a de-duplicated set of Messages, ranked by gas price, and supports
+// removal by CID.
 // By 'de-duplicated' we mean that insertion of a message by cid that already
 // exists is a nop. We use a Pool to store all messages received by this node
 // via network or directly created via user command that have yet to be included
 // in a block. Messages are removed as they are processed.
 //
+// A message with the same sender and nonce as a pending message replaces it
+// if its gas price is higher by at least the configured bump. The number of
+// pending messages per sender is capped, and when the pool is full a new
+// message evicts the cheapest evictable message if it pays more.
+//
 // Pool is safe for concurrent access.
 type Pool struct {
 	lk sync.RWMutex
@@ -34,12 +44,15 @@ type Pool struct {
 	cfg           *config.MessagePoolConfig
 	validator     PoolValidator
 	pending       map[cid.Cid]*timedmessage // all pending messages
-	addressNonces map[addressNonce]bool     // set of address nonce pairs used to efficiently validate duplicate nonces
+	addressNonces map[addressNonce]cid.Cid  // address nonce pairs to the cid of the pending message using them
+	senderCounts  map[address.Address]uint  // number of pending messages per sender
+	seq           uint64                    // arrival counter, breaking gas price ties in favour of older messages
 }
 
 type timedmessage struct {
 	message *types.SignedMessage
 	addedAt abi.ChainEpoch
+	seq     uint64
 }
 
 type addressNonce struct {
@@ -57,7 +70,8 @@ func NewPool(cfg *config.MessagePoolConfig, validator PoolValidator) *Pool {
 		cfg:           cfg,
 		validator:     validator,
 		pending:       make(map[cid.Cid]*timedmessage),
-		addressNonces: make(map[addressNonce]bool),
+		addressNonces: make(map[addressNonce]cid.Cid),
+		senderCounts:  make(map[address.Address]uint),
 	}
 }
 
@@ -78,26 +92,46 @@ func (pool *Pool) Add(ctx context.Context, msg *types.SignedMessage, height abi.
 		return c, nil
 	}
 
-	if err = pool.validateMessage(ctx, msg); err != nil {
+	replaced, evicted, err := pool.validateMessage(ctx, msg)
+	if err != nil {
 		return cid.Undef, errors.Wrap(err, "validation error adding message to pool")
 	}
+	if replaced.Defined() {
+		pool.remove(replaced)
+		mpReplaced.Inc(ctx, 1)
+	}
+	if evicted.Defined() {
+		pool.remove(evicted)
+		mpEvicted.Inc(ctx, 1)
+	}
 
-	pool.pending[c] = &timedmessage{message: msg, addedAt: height}
-	pool.addressNonces[newAddressNonce(msg)] = true
+	pool.seq++
+	pool.pending[c] = &timedmessage{message: msg, addedAt: height, seq: pool.seq}
+	pool.addressNonces[newAddressNonce(msg)] = c
+	pool.senderCounts[msg.Message.From]++
 	mpSize.Set(ctx, int64(len(pool.pending)))
 	return c, nil
 }
 
-// Pending returns all pending messages.
+// Pending returns all pending messages, ranked by decreasing gas price. Messages
+// with equal gas price are ranked in the order they were added.
+// The ranking does not account for nonce order between messages from the same sender.
 func (pool *Pool) Pending() []*types.SignedMessage {
 	pool.lk.Lock()
 	defer pool.lk.Unlock()
 
-	out := make([]*types.SignedMessage, 0, len(pool.pending))
+	ranked := make([]*timedmessage, 0, len(pool.pending))
 	for _, msg := range pool.pending {
-		out = append(out, msg.message)
+		ranked = append(ranked, msg)
 	}
+	sort.Slice(ranked, func(i, j int) bool {
+		return outranks(ranked[i], ranked[j])
+	})
 
+	out := make([]*types.SignedMessage, len(ranked))
+	for i, msg := range ranked {
+		out[i] = msg.message
+	}
 	return out
 }
 
@@ -118,15 +152,25 @@ func (pool *Pool) Get(c cid.Cid) (*types.SignedMessage, bool) {
 func (pool *Pool) Remove(c cid.Cid) {
 	pool.lk.Lock()
 	defer pool.lk.Unlock()
-	msg, ok := pool.pending[c]
-	if ok {
-		delete(pool.addressNonces, newAddressNonce(msg.message))
-		delete(pool.pending, c)
-	}
+	pool.remove(c)
 
 	mpSize.Set(context.TODO(), int64(len(pool.pending)))
 }
 
+func (pool *Pool) remove(c cid.Cid) {
+	msg, ok := pool.pending[c]
+	if !ok {
+		return
+	}
+	from := msg.message.Message.From
+	delete(pool.addressNonces, newAddressNonce(msg.message))
+	delete(pool.pending, c)
+	pool.senderCounts[from]--
+	if pool.senderCounts[from] == 0 {
+		delete(pool.senderCounts, from)
+	}
+}
+
 // LargestNonce returns the largest nonce used by a message from address in the pool.
 // If no messages from address are found, found will be false.
 func (pool *Pool) LargestNonce(address address.Address) (largest uint64, found bool) {
@@ -156,18 +200,80 @@ func (pool *Pool) PendingBefore(minimumHeight abi.ChainEpoch) []cid.Cid {
 }
 
 // validateMessage validates that too many messages aren't added to the pool and the ones that are
-// have a high probability of making it through processing.
-func (pool *Pool) validateMessage(ctx context.Context, message *types.SignedMessage) error {
-	if uint(len(pool.pending)) >= pool.cfg.MaxPoolSize {
-		return errors.Errorf("message pool is full (%d messages)", pool.cfg.MaxPoolSize)
-	}
+// have a high probability of making it through processing. It returns the cid of a pending message
+// the new one replaces by fee, or else of a message that must be evicted to make room for it.
+func (pool *Pool) validateMessage(ctx context.Context, message *types.SignedMessage) (replaced cid.Cid, evicted cid.Cid, err error) {
+	if existing, found := pool.addressNonces[newAddressNonce(message)]; found {
+		// a message with this nonce already exists and may only be replaced by fee
+		old := pool.pending[existing].message
+		if !pool.isReplacement(old, message) {
+			return cid.Undef, cid.Undef, errors.Errorf("message pool contains message with same actor and nonce but different cid, "+
+				"replacement requires gas price at least %d%% above %s", pool.cfg.ReplaceByFeeBumpPercent, old.Message.GasPrice)
+		}
+		replaced = existing
+	} else {
+		if pool.senderCounts[message.Message.From] >= pool.cfg.MaxSenderMessages {
+			return cid.Undef, cid.Undef, errors.Errorf("message pool contains too many messages from %s (%d messages)",
+				message.Message.From, pool.cfg.MaxSenderMessages)
+		}
 
-	// check that message with this nonce does not already exist
-	_, found := pool.addressNonces[newAddressNonce(message)]
-	if found {
-		return errors.Errorf("message pool contains message with same actor and nonce but different cid")
+		if uint(len(pool.pending)) >= pool.cfg.MaxPoolSize {
+			cheapest, found := pool.cheapestEvictable(message.Message.From)
+			if !found || !cheapest.message.Message.GasPrice.LessThan(message.Message.GasPrice) {
+				return cid.Undef, cid.Undef, errors.Errorf("message pool is full (%d messages)", pool.cfg.MaxPoolSize)
+			}
+			evicted, err = cheapest.message.Cid()
+			if err != nil {
+				return cid.Undef, cid.Undef, err
+			}
+		}
 	}
 
 	// check that the message is likely to succeed in processing
-	return pool.validator.Validate(ctx, message)
+	if err := pool.validator.Validate(ctx, message); err != nil {
+		return cid.Undef, cid.Undef, err
+	}
+	return replaced, evicted, nil
+}
+
+// isReplacement tests whether the candidate's gas price is high enough to replace the existing message:
+// strictly higher, and by at least the configured bump.
+func (pool *Pool) isReplacement(existing, candidate *types.SignedMessage) bool {
+	bump := specsbig.NewInt(int64(100 + pool.cfg.ReplaceByFeeBumpPercent))
+	minPrice := specsbig.Div(specsbig.Mul(existing.Message.GasPrice, bump), specsbig.NewInt(100))
+	return candidate.Message.GasPrice.GreaterThan(existing.Message.GasPrice) &&
+		candidate.Message.GasPrice.GreaterThanEqual(minPrice)
+}
+
+// cheapestEvictable returns the lowest ranked message among those with the highest nonce
+// from their sender, so that eviction never leaves a gap in a sender's nonces.
+// The tail of `incoming`'s sender is never evicted, as the incoming message
+// follows it and evicting it would leave a nonce gap.
+func (pool *Pool) cheapestEvictable(incoming address.Address) (*timedmessage, bool) {
+	tails := make(map[address.Address]*timedmessage)
+	for _, msg := range pool.pending {
+		from := msg.message.Message.From
+		if from == incoming {
+			continue
+		}
+		if tail, ok := tails[from]; !ok || msg.message.Message.CallSeqNum > tail.message.Message.CallSeqNum {
+			tails[from] = msg
+		}
+	}
+
+	var cheapest *timedmessage
+	for _, tail := range tails {
+		if cheapest == nil || outranks(cheapest, tail) {
+			cheapest = tail
+		}
+	}
+	return cheapest, cheapest != nil
+}
+
+// outranks tests whether a ranks ahead of b: a has the higher gas price, or was added first.
+func outranks(a, b *timedmessage) bool {
+	if !a.message.Message.GasPrice.Equals(b.message.Message.GasPrice) {
+		return a.message.Message.GasPrice.GreaterThan(b.message.Message.GasPrice)
+	}
+	return a.seq < b.seq
 }
@@ -67,6 +67,8 @@ func TestMessagePoolValidate(t *testing.T) {
 		// pull the default size from the default config value
 		mpoolCfg := config.NewDefaultConfig().Mpool
 		maxMessagePoolSize := mpoolCfg.MaxPoolSize
+		// all messages come from one sender
+		mpoolCfg.MaxSenderMessages = maxMessagePoolSize
 		ctx := context.Background()
 		pool := message.NewPool(mpoolCfg, th.NewMockMessagePoolValidator())
 
@@ -131,6 +133,116 @@ func TestMessagePoolDedup(t *testing.T) {
 	assert.Len(t, pool.Pending(), 1)
 }
 
+func TestMessagePoolReplaceByFee(t *testing.T) {
+	tf.UnitTest(t)
+
+	ctx := context.Background()
+	withPrice := func(price int64) func(*types.UnsignedMessage) {
+		return func(m *types.UnsignedMessage) { m.GasPrice = types.NewGasPrice(price) }
+	}
+
+	t.Run("replaces with sufficient bump", func(t *testing.T) {
+		pool := message.NewPool(config.NewDefaultConfig().Mpool, th.NewMockMessagePoolValidator())
+		smsg1 := mustResignMessage(mockSigner, newSignedMessage(), withPrice(100))
+		reqAdd(t, pool, 0, smsg1)
+
+		smsg2 := mustResignMessage(mockSigner, smsg1, withPrice(125))
+		c2, err := pool.Add(ctx, smsg2, 0)
+		require.NoError(t, err)
+
+		c1, err := smsg1.Cid()
+		require.NoError(t, err)
+		_, found := pool.Get(c1)
+		assert.False(t, found)
+		_, found = pool.Get(c2)
+		assert.True(t, found)
+		assert.Len(t, pool.Pending(), 1)
+	})
+
+	t.Run("rejects insufficient bump", func(t *testing.T) {
+		pool := message.NewPool(config.NewDefaultConfig().Mpool, th.NewMockMessagePoolValidator())
+		smsg1 := mustResignMessage(mockSigner, newSignedMessage(), withPrice(100))
+		reqAdd(t, pool, 0, smsg1)
+
+		smsg2 := mustResignMessage(mockSigner, smsg1, withPrice(124))
+		_, err := pool.Add(ctx, smsg2, 0)
+		require.Error(t, err)
+		assert.Contains(t, err.Error(), "replacement requires gas price")
+		assert.Equal(t, []*types.SignedMessage{smsg1}, pool.Pending())
+	})
+}
+
+func TestMessagePoolLimits(t *testing.T) {
+	tf.UnitTest(t)
+
+	ctx := context.Background()
+	signer, _ := types.NewMockSignersAndKeyInfo(3)
+	newMsg := func(from int, nonce uint64, price int64) *types.SignedMessage {
+		msg := types.NewMeteredMessage(signer.Addresses[from], signer.Addresses[2], nonce, types.ZeroAttoFIL,
+			0, []byte{}, types.NewGasPrice(price), types.GasUnits(0))
+		smsg, err := types.NewSignedMessage(*msg, signer)
+		require.NoError(t, err)
+		return smsg
+	}
+
+	t.Run("caps pending messages per sender", func(t *testing.T) {
+		cfg := config.NewDefaultConfig().Mpool
+		cfg.MaxSenderMessages = 2
+		pool := message.NewPool(cfg, th.NewMockMessagePoolValidator())
+		reqAdd(t, pool, 0, newMsg(0, 0, 1), newMsg(0, 1, 1))
+
+		_, err := pool.Add(ctx, newMsg(0, 2, 1), 0)
+		require.Error(t, err)
+		assert.Contains(t, err.Error(), "too many messages")
+
+		reqAdd(t, pool, 0, newMsg(1, 0, 1))
+	})
+
+	t.Run("evicts cheapest message when full", func(t *testing.T) {
+		cfg := config.NewDefaultConfig().Mpool
+		cfg.MaxPoolSize = 3
+		pool := message.NewPool(cfg, th.NewMockMessagePoolValidator())
+		cheap, dear := newMsg(0, 1, 1), newMsg(0, 0, 5)
+		reqAdd(t, pool, 0, dear, cheap, newMsg(1, 0, 3))
+
+		// Too cheap to evict anything.
+		_, err := pool.Add(ctx, newMsg(1, 1, 1), 0)
+		require.Error(t, err)
+		assert.Contains(t, err.Error(), "message pool is full")
+
+		reqAdd(t, pool, 0, newMsg(1, 1, 2))
+		assert.Len(t, pool.Pending(), 3)
+		cheapCid, err := cheap.Cid()
+		require.NoError(t, err)
+		_, found := pool.Get(cheapCid)
+		assert.False(t, found)
+	})
+
+	t.Run("does not evict the tail of the incoming message's sender", func(t *testing.T) {
+		cfg := config.NewDefaultConfig().Mpool
+		cfg.MaxPoolSize = 3
+		pool := message.NewPool(cfg, th.NewMockMessagePoolValidator())
+		tail := newMsg(0, 1, 1)
+		reqAdd(t, pool, 0, newMsg(0, 0, 5), tail, newMsg(1, 0, 2))
+
+		// Evicts the cheapest tail of another sender instead.
+		reqAdd(t, pool, 0, newMsg(0, 2, 3))
+		tailCid, err := tail.Cid()
+		require.NoError(t, err)
+		_, found := pool.Get(tailCid)
+		assert.True(t, found)
+		assert.Len(t, pool.Pending(), 3)
+	})
+
+	t.Run("ranks pending by gas price", func(t *testing.T) {
+		pool := message.NewPool(config.NewDefaultConfig().Mpool, th.NewMockMessagePoolValidator())
+		m1, m2, m3 := newMsg(0, 0, 2), newMsg(1, 0, 3), newMsg(0, 1, 2)
+		reqAdd(t, pool, 0, m1, m2, m3)
+
+		assert.Equal(t, []*types.SignedMessage{m2, m1, m3}, pool.Pending())
+	})
+}
+
 func TestMessagePoolAsync(t *testing.T) {
 	tf.UnitTest(t)
 
@@ -139,6 +251,7 @@ func TestMessagePoolAsync(t *testing.T) {
 	count := uint(400)
 	mpoolCfg := config.NewDefaultConfig().Mpool
 	mpoolCfg.MaxPoolSize = count
+	mpoolCfg.MaxSenderMessages = count
 	msgs := types.NewSignedMsgs(count, mockSigner)
 
 	pool := message.NewPool(mpoolCfg, th.NewMockMessagePoolValidator())
@@ -2,12 +2,18 @@ package message
 
 import (
 	"context"
+	"fmt"
+	"sort"
 	"sync"
 
+	"github.com/ipfs/go-datastore"
+	"github.com/ipfs/go-datastore/namespace"
+	"github.com/ipfs/go-datastore/query"
 	"github.com/pkg/errors"
 
 	"github.com/filecoin-project/go-address"
 
+	"github.com/filecoin-project/go-filecoin/internal/pkg/encoding"
 	"github.com/filecoin-project/go-filecoin/internal/pkg/metrics"
 	"github.com/filecoin-project/go-filecoin/internal/pkg/types"
 )
@@ -29,12 +35,20 @@ type Queue struct {
 	lk sync.RWMutex
 	// Message queues keyed by sending actor address, in nonce order
 	queues map[address.Address][]*Queued
+	// Holds a copy of the queued messages so they survive restarts, nil if the queue is in memory only.
+	ds datastore.Datastore
 }
 
+// outboxPrefix is the datastore namespace of the queued messages.
+var outboxPrefix = datastore.NewKey("/outbox/queue")
+
 // Queued is a message an the stamp it was enqueued with.
 type Queued struct {
+	_     struct{} `cbor:",toarray"`
 	Msg   *types.SignedMessage
 	Stamp uint64
+	// Attempts counts the times the message has been published.
+	Attempts uint64
 }
 
 // NewQueue constructs a new, empty queue.
@@ -44,6 +58,50 @@ func NewQueue() *Queue {
 	}
 }
 
+// NewPersistentQueue constructs a queue holding the messages previously written to `ds`, and
+// which writes the messages enqueued from now on to it.
+// A sender's messages following a gap in its nonces are dropped, as they could never be mined.
+func NewPersistentQueue(ds datastore.Datastore) (*Queue, error) {
+	mq := &Queue{
+		queues: make(map[address.Address][]*Queued),
+		ds:     namespace.Wrap(ds, outboxPrefix),
+	}
+
+	results, err := mq.ds.Query(query.Query{})
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to query outbound messages")
+	}
+	defer func() { _ = results.Close() }()
+
+	for res := range results.Next() {
+		if res.Error != nil {
+			return nil, errors.Wrap(res.Error, "failed to read outbound messages")
+		}
+		var qm Queued
+		if err := encoding.Decode(res.Value, &qm); err != nil {
+			return nil, errors.Wrapf(err, "failed to decode outbound message at %s", res.Key)
+		}
+		from := qm.Msg.Message.From
+		mq.queues[from] = append(mq.queues[from], &qm)
+	}
+
+	for sender, q := range mq.queues {
+		sort.Slice(q, func(i, j int) bool { return q[i].Msg.Message.CallSeqNum < q[j].Msg.Message.CallSeqNum })
+		for i := 1; i < len(q); i++ {
+			if q[i].Msg.Message.CallSeqNum != q[i-1].Msg.Message.CallSeqNum+1 {
+				log.Warnf("Dropping %d outbound messages from %s after nonce gap at %d", len(q)-i, sender, q[i-1].Msg.Message.CallSeqNum)
+				for _, dropped := range q[i:] {
+					mq.delete(dropped.Msg)
+				}
+				q = q[:i]
+				break
+			}
+		}
+		mq.queues[sender] = q
+	}
+	return mq, nil
+}
+
 // Enqueue appends a new message for an address. If the queue already contains any messages for
 // from same address, the new message's nonce must be exactly one greater than the largest nonce
 // present.
@@ -63,7 +121,11 @@ func (mq *Queue) Enqueue(ctx context.Context, msg *types.SignedMessage, stamp ui
 			return errors.Errorf("Invalid nonce in %d in enqueue, expected %d", msg.Message.CallSeqNum, nextNonce)
 		}
 	}
-	mq.queues[msg.Message.From] = append(q, &Queued{msg, stamp})
+	qm := &Queued{Msg: msg, Stamp: stamp}
+	if err := mq.put(qm); err != nil {
+		return err
+	}
+	mq.queues[msg.Message.From] = append(q, qm)
 	return nil
 }
 
@@ -85,7 +147,11 @@ func (mq *Queue) Requeue(ctx context.Context, msg *types.SignedMessage, stamp ui
 			return errors.Errorf("Invalid nonce %d in requeue, expected %d", msg.Message.CallSeqNum, prevNonce)
 		}
 	}
-	mq.queues[msg.Message.From] = append([]*Queued{{msg, stamp}}, q...)
+	qm := &Queued{Msg: msg, Stamp: stamp}
+	if err := mq.put(qm); err != nil {
+		return err
+	}
+	mq.queues[msg.Message.From] = append([]*Queued{qm}, q...)
 	return nil
 }
 
@@ -108,6 +174,7 @@ func (mq *Queue) RemoveNext(ctx context.Context, sender address.Address, expecte
 		head := q[0]
 		if expectedNonce == head.Msg.Message.CallSeqNum {
 			mq.queues[sender] = q[1:] // pop the head
+			mq.delete(head.Msg)
 			msg = head.Msg
 			found = true
 		} else if expectedNonce > head.Msg.Message.CallSeqNum {
@@ -131,6 +198,9 @@ func (mq *Queue) Clear(ctx context.Context, sender address.Address) bool {
 
 	q := mq.queues[sender]
 	delete(mq.queues, sender)
+	for _, qm := range q {
+		mq.delete(qm.Msg)
+	}
 	return len(q) > 0
 }
 
@@ -154,6 +224,7 @@ func (mq *Queue) ExpireBefore(ctx context.Context, stamp uint64) map[address.Add
 			mqExpireCt.Inc(ctx, int64(len(q)))
 			for _, m := range q {
 				expired[sender] = append(expired[sender], m.Msg)
+				mq.delete(m.Msg)
 			}
 
 			mq.queues[sender] = []*Queued{}
@@ -236,3 +307,48 @@ func (mq *Queue) List(sender address.Address) []*Queued {
 	}
 	return out
 }
+
+// RecordAttempt counts a publication of the message from `sender` with nonce `nonce`.
+// Returns false if the message is no longer queued.
+func (mq *Queue) RecordAttempt(sender address.Address, nonce uint64) (bool, error) {
+	mq.lk.Lock()
+	defer mq.lk.Unlock()
+
+	for _, qm := range mq.queues[sender] {
+		if qm.Msg.Message.CallSeqNum == nonce {
+			qm.Attempts++
+			return true, mq.put(qm)
+		}
+	}
+	return false, nil
+}
+
+// put writes a queued message to the datastore, if any.
+func (mq *Queue) put(qm *Queued) error {
+	if mq.ds == nil {
+		return nil
+	}
+	raw, err := encoding.Encode(qm)
+	if err != nil {
+		return err
+	}
+	if err := mq.ds.Put(queuedKey(qm.Msg), raw); err != nil {
+		return errors.Wrapf(err, "failed to persist outbound message from %s with nonce %d", qm.Msg.Message.From, qm.Msg.Message.CallSeqNum)
+	}
+	return nil
+}
+
+// delete removes a message from the datastore, if any. Failures are only logged: a message left
+// behind is removed again when the queue is next reconciled with the chain.
+func (mq *Queue) delete(msg *types.SignedMessage) {
+	if mq.ds == nil {
+		return
+	}
+	if err := mq.ds.Delete(queuedKey(msg)); err != nil {
+		log.Errorf("failed to delete outbound message from %s with nonce %d: %s", msg.Message.From, msg.Message.CallSeqNum, err)
+	}
+}
+
+func queuedKey(msg *types.SignedMessage) datastore.Key {
+	return datastore.NewKey(fmt.Sprintf("/%s/%020d", msg.Message.From, msg.Message.CallSeqNum))
+}
@@ -6,6 +6,7 @@ import (
 	"testing"
 
 	"github.com/filecoin-project/go-address"
+	"github.com/ipfs/go-datastore"
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
 
@@ -352,4 +353,47 @@ func TestMessageQueue(t *testing.T) {
 		assert.Equal(t, uint64(1), q.Oldest())
 
 	})
+
+	t.Run("persistent queue survives reload", func(t *testing.T) {
+		fromAlice := []*types.SignedMessage{
+			mm.NewSignedMessage(alice, 0),
+			mm.NewSignedMessage(alice, 1),
+			mm.NewSignedMessage(alice, 2),
+		}
+		fromBob := mm.NewSignedMessage(bob, 7)
+		ds := datastore.NewMapDatastore()
+		q, err := message.NewPersistentQueue(ds)
+		require.NoError(t, err)
+
+		requireEnqueue(q, fromAlice[0], 100)
+		requireEnqueue(q, fromAlice[1], 101)
+		requireEnqueue(q, fromAlice[2], 102)
+		requireEnqueue(q, fromBob, 200)
+		assert.Equal(t, fromAlice[0], requireRemoveNext(q, alice, 0))
+		recorded, err := q.RecordAttempt(alice, 1)
+		require.NoError(t, err)
+		assert.True(t, recorded)
+		recorded, err = q.RecordAttempt(alice, 0)
+		require.NoError(t, err)
+		assert.False(t, recorded)
+
+		reloaded, err := message.NewPersistentQueue(ds)
+		require.NoError(t, err)
+		assert.Equal(t, int64(3), reloaded.Size())
+		listed := reloaded.List(alice)
+		require.Len(t, listed, 2)
+		assert.True(t, fromAlice[1].Equals(listed[0].Msg))
+		assert.Equal(t, uint64(101), listed[0].Stamp)
+		assert.Equal(t, uint64(1), listed[0].Attempts)
+		assert.True(t, fromAlice[2].Equals(listed[1].Msg))
+		assert.Equal(t, uint64(0), listed[1].Attempts)
+		assertLargestNonce(reloaded, bob, 7)
+
+		// Removals are persisted too.
+		assert.True(t, reloaded.Clear(ctx, bob))
+		assert.Len(t, reloaded.ExpireBefore(ctx, 102), 1)
+		reloaded, err = message.NewPersistentQueue(ds)
+		require.NoError(t, err)
+		assert.Equal(t, int64(0), reloaded.Size())
+	})
 }
@@ -103,6 +103,11 @@ func (NullPolicy) HandleNewHead(ctx context.Context, target PolicyTarget, oldCha
 	return nil
 }
 
+// HandleRestore does nothing.
+func (NullPolicy) HandleRestore(ctx context.Context, target PolicyTarget, head block.TipSetKey, actors actorProvider) error {
+	return nil
+}
+
 // MockNetworkPublisher records the last message published.
 type MockNetworkPublisher struct {
 	Data []byte
@@ -58,13 +58,13 @@ func (w *DefaultWorker) Generate(
 
 	blockHeight := baseHeight + nullBlockCount + 1
 
-	// Construct list of message candidates for inclusion.
-	// These messages will be processed, and those that fail excluded from the block.
-	pending := w.messageSource.Pending()
-	mq := NewMessageQueue(pending)
-	candidateMsgs := orderMessageCandidates(mq.Drain())
-
-	// Dragons: ask something to select and order messages to include
+	// Select the messages to include among the pending ones, excluding those
+	// which would fail against the base state or exceed the block limits.
+	selected, err := w.messageSelector.SelectMessages(ctx, baseTipSet, w.messageSource.Pending())
+	if err != nil {
+		return nil, errors.Wrap(err, "select messages")
+	}
+	candidateMsgs := orderMessageCandidates(selected)
 
 	var blsAccepted []*types.SignedMessage
 	var secpAccepted []*types.SignedMessage
@@ -0,0 +1,209 @@
+package mining
+
+import (
+	"context"
+	"sort"
+
+	"github.com/filecoin-project/go-address"
+	"github.com/filecoin-project/specs-actors/actors/abi"
+	"github.com/pkg/errors"
+
+	"github.com/filecoin-project/go-filecoin/internal/pkg/block"
+	"github.com/filecoin-project/go-filecoin/internal/pkg/consensus"
+	"github.com/filecoin-project/go-filecoin/internal/pkg/types"
+	"github.com/filecoin-project/go-filecoin/internal/pkg/vm"
+	"github.com/filecoin-project/go-filecoin/internal/pkg/vm/actor"
+)
+
+// Names of the message selection strategies.
+const (
+	// GreedySelection packs messages by decreasing gas price.
+	GreedySelection = "greedy"
+	// PoolSelection packs messages in the order of the message pool.
+	PoolSelection = "pool"
+)
+
+const (
+	// MaxBlockMessages is the maximum number of messages packed into a block.
+	MaxBlockMessages = 4000
+	// MaxBlockMessageBytes is the maximum size of the messages packed into a
+	// block, in bytes of their on-chain encoding.
+	MaxBlockMessageBytes = 1 << 20
+)
+
+// MessageSelector chooses which of the candidate messages to include in a
+// block mined on a base tipset, and their order.
+type MessageSelector interface {
+	SelectMessages(ctx context.Context, base block.TipSet, candidates []*types.SignedMessage) ([]*types.SignedMessage, error)
+}
+
+// MessageSimulator starts simulations of messages on the states of tipsets.
+type MessageSimulator interface {
+	SimulateMessages(ctx context.Context, base block.TipSet) (MessageSimulation, error)
+}
+
+// MessageSimulation applies messages one after another on the state of a
+// tipset, as if they were included in the next tipset.
+type MessageSimulation interface {
+	// Actor returns the actor at `addr` in the simulated state, and whether
+	// there is one.
+	Actor(addr address.Address) (*actor.Actor, bool, error)
+	// Apply applies `msg` on the simulated state and returns its receipt.
+	Apply(msg *types.UnsignedMessage, onChainMsgSize int) vm.MessageReceipt
+}
+
+// NewMessageSelector returns the message selector of strategy `name`.
+func NewMessageSelector(name string, simulator MessageSimulator) (MessageSelector, error) {
+	switch name {
+	case GreedySelection, "":
+		return NewGreedySelector(simulator), nil
+	case PoolSelection:
+		return NewPoolSelector(simulator), nil
+	default:
+		return nil, errors.Errorf("unknown message selection strategy %s", name)
+	}
+}
+
+// PackingSelector packs the messages of an ordering into a block. It
+// simulates each message in the vm on the base state, as updated by the
+// messages packed before it, and drops those which could not be applied, for
+// a wrong nonce or a balance not covering their value and gas, and those
+// failing in the vm. Messages following a dropped message from the same
+// sender are dropped too, as their nonces can no longer be reached, except
+// when it was dropped for a nonce already used. The messages packed must fit
+// the block gas limit and the message count and size caps.
+type PackingSelector struct {
+	simulator MessageSimulator
+	validator *consensus.DefaultMessageValidator
+	order     func([]*types.SignedMessage) []*types.SignedMessage
+
+	// GasLimit bounds the sum of the gas limits of the messages packed.
+	GasLimit types.GasUnits
+	// MaxMessages bounds the number of messages packed.
+	MaxMessages int
+	// MaxBytes bounds the sum of the encoded sizes of the messages packed.
+	MaxBytes int
+}
+
+// NewGreedySelector returns a selector packing messages by decreasing gas
+// price, subject to the nonce order of each sender. As the gas price is the
+// reward per unit of gas, this approximates the block paying the miner most.
+func NewGreedySelector(simulator MessageSimulator) *PackingSelector {
+	return newPackingSelector(simulator, func(msgs []*types.SignedMessage) []*types.SignedMessage {
+		mq := NewMessageQueue(msgs)
+		return mq.Drain()
+	})
+}
+
+// NewPoolSelector returns a selector packing messages in the order in which
+// the message source ranks them, with those of each sender in nonce order.
+func NewPoolSelector(simulator MessageSimulator) *PackingSelector {
+	return newPackingSelector(simulator, func(msgs []*types.SignedMessage) []*types.SignedMessage {
+		out := make([]*types.SignedMessage, len(msgs))
+		copy(out, msgs)
+		rank := make(map[address.Address]int)
+		for i, m := range out {
+			if _, ok := rank[m.Message.From]; !ok {
+				rank[m.Message.From] = i
+			}
+		}
+		sort.SliceStable(out, func(i, j int) bool {
+			ri, rj := rank[out[i].Message.From], rank[out[j].Message.From]
+			if ri != rj {
+				return ri < rj
+			}
+			return out[i].Message.CallSeqNum < out[j].Message.CallSeqNum
+		})
+		return out
+	})
+}
+
+func newPackingSelector(simulator MessageSimulator, order func([]*types.SignedMessage) []*types.SignedMessage) *PackingSelector {
+	return &PackingSelector{
+		simulator:   simulator,
+		validator:   consensus.NewDefaultMessageValidator(),
+		order:       order,
+		GasLimit:    types.BlockGasLimit,
+		MaxMessages: MaxBlockMessages,
+		MaxBytes:    MaxBlockMessageBytes,
+	}
+}
+
+// SelectMessages implements MessageSelector.
+func (s *PackingSelector) SelectMessages(ctx context.Context, base block.TipSet, candidates []*types.SignedMessage) ([]*types.SignedMessage, error) {
+	if len(candidates) == 0 {
+		return nil, nil
+	}
+	simulation, err := s.simulator.SimulateMessages(ctx, base)
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to start simulation on base state")
+	}
+
+	// Senders whose remaining messages cannot be packed.
+	blocked := make(map[address.Address]struct{})
+
+	var selected []*types.SignedMessage
+	gasUsed := types.GasUnits(0)
+	size := 0
+	for _, smsg := range s.order(candidates) {
+		if len(selected) >= s.MaxMessages {
+			break
+		}
+		if err := ctx.Err(); err != nil {
+			return nil, err
+		}
+		msg := &smsg.Message
+		if _, ok := blocked[msg.From]; ok {
+			continue
+		}
+
+		sender, err := loadSender(simulation, msg.From)
+		if err != nil {
+			return nil, err
+		}
+		if msg.CallSeqNum < sender.CallSeqNum {
+			// Already applied, the sender's next messages may still be packed.
+			continue
+		}
+		if err := s.validator.Validate(ctx, msg, sender); err != nil {
+			log.Debugf("not packing message from %s with nonce %d: %s", msg.From, msg.CallSeqNum, err)
+			blocked[msg.From] = struct{}{}
+			continue
+		}
+
+		msgSize := smsg.OnChainLen()
+		if gasUsed+msg.GasLimit > s.GasLimit || size+msgSize > s.MaxBytes {
+			// Smaller messages of other senders may still fit.
+			blocked[msg.From] = struct{}{}
+			continue
+		}
+
+		// A message failing in the vm still uses its nonce and pays for its
+		// gas, which the simulated state keeps. As the sender is blocked, this
+		// only affects the messages of other senders through its balance.
+		receipt := simulation.Apply(msg, msgSize)
+		if !receipt.ExitCode.IsSuccess() {
+			log.Debugf("not packing message from %s with nonce %d: exit code %d", msg.From, msg.CallSeqNum, receipt.ExitCode)
+			blocked[msg.From] = struct{}{}
+			continue
+		}
+
+		selected = append(selected, smsg)
+		gasUsed += msg.GasLimit
+		size += msgSize
+	}
+	return selected, nil
+}
+
+// loadSender returns the actor at `addr` in the simulated state, or an empty
+// actor if there is none, which cannot pay for any message.
+func loadSender(simulation MessageSimulation, addr address.Address) (*actor.Actor, error) {
+	sender, found, err := simulation.Actor(addr)
+	if err != nil {
+		return nil, errors.Wrapf(err, "failed to load sender %s", addr)
+	}
+	if !found {
+		return &actor.Actor{Balance: abi.NewTokenAmount(0)}, nil
+	}
+	return sender, nil
+}
@@ -0,0 +1,225 @@
+package mining
+
+import (
+	"context"
+	"testing"
+
+	"github.com/filecoin-project/go-address"
+	"github.com/filecoin-project/specs-actors/actors/abi"
+	specsbig "github.com/filecoin-project/specs-actors/actors/abi/big"
+	"github.com/filecoin-project/specs-actors/actors/builtin"
+	"github.com/filecoin-project/specs-actors/actors/runtime/exitcode"
+	"github.com/pkg/errors"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/filecoin-project/go-filecoin/internal/pkg/block"
+	tf "github.com/filecoin-project/go-filecoin/internal/pkg/testhelpers/testflags"
+	"github.com/filecoin-project/go-filecoin/internal/pkg/types"
+	"github.com/filecoin-project/go-filecoin/internal/pkg/vm"
+	"github.com/filecoin-project/go-filecoin/internal/pkg/vm/actor"
+)
+
+// fakeActorProvider simulates messages on a set of actors, transferring
+// nothing but charging senders for the full gas limit of their messages.
+// Messages calling a method other than send fail.
+type fakeActorProvider map[address.Address]*actor.Actor
+
+func (f fakeActorProvider) SimulateMessages(_ context.Context, _ block.TipSet) (MessageSimulation, error) {
+	actors := make(fakeActorProvider)
+	for addr, act := range f {
+		copied := *act
+		actors[addr] = &copied
+	}
+	return &fakeSimulation{actors: actors}, nil
+}
+
+type fakeSimulation struct {
+	actors fakeActorProvider
+}
+
+func (f *fakeSimulation) Actor(addr address.Address) (*actor.Actor, bool, error) {
+	act, ok := f.actors[addr]
+	return act, ok, nil
+}
+
+func (f *fakeSimulation) Apply(msg *types.UnsignedMessage, _ int) vm.MessageReceipt {
+	sender := f.actors[msg.From]
+	sender.IncrementSeqNum()
+	gasCost := specsbig.Mul(msg.GasPrice, specsbig.NewInt(int64(msg.GasLimit)))
+	sender.Balance = specsbig.Sub(sender.Balance, gasCost)
+	if msg.Method != builtin.MethodSend {
+		return vm.MessageReceipt{ExitCode: exitcode.SysErrInvalidMethod}
+	}
+	return vm.MessageReceipt{ExitCode: exitcode.Ok}
+}
+
+type failingActorProvider struct{}
+
+func (failingActorProvider) SimulateMessages(_ context.Context, _ block.TipSet) (MessageSimulation, error) {
+	return failingSimulation{}, nil
+}
+
+type failingSimulation struct{}
+
+func (failingSimulation) Actor(addr address.Address) (*actor.Actor, bool, error) {
+	return nil, false, errors.New("boom")
+}
+
+func (failingSimulation) Apply(_ *types.UnsignedMessage, _ int) vm.MessageReceipt {
+	panic("not applied")
+}
+
+func TestGreedySelector(t *testing.T) {
+	tf.UnitTest(t)
+	ctx := context.Background()
+
+	ki := types.MustGenerateKeyInfo(5, 42)
+	mockSigner := types.NewMockSigner(ki)
+	a0, a1, a2, a3 := mockSigner.Addresses[0], mockSigner.Addresses[1], mockSigner.Addresses[2], mockSigner.Addresses[3]
+	to := mockSigner.Addresses[4]
+
+	sign := func(from address.Address, nonce uint64, units uint64, price int64) *types.SignedMessage {
+		msg := types.NewMeteredMessage(from, to, nonce, types.ZeroAttoFIL, builtin.MethodSend, []byte{}, types.NewGasPrice(price), types.GasUnits(units))
+		s, err := types.NewSignedMessage(*msg, &mockSigner)
+		require.NoError(t, err)
+		return s
+	}
+	account := func(nonce uint64, balance int64) *actor.Actor {
+		act := actor.NewActor(builtin.AccountActorCodeID, types.NewAttoFILFromFIL(uint64(balance)))
+		act.CallSeqNum = nonce
+		return act
+	}
+	rich := fakeActorProvider{
+		a0: account(0, 1000),
+		a1: account(0, 1000),
+		a2: account(0, 1000),
+	}
+
+	t.Run("orders by gas price within nonce order", func(t *testing.T) {
+		m00, m01 := sign(a0, 0, 100, 1), sign(a0, 1, 100, 4)
+		m10 := sign(a1, 0, 100, 3)
+		m20 := sign(a2, 0, 100, 2)
+
+		selected, err := NewGreedySelector(rich).SelectMessages(ctx, block.UndefTipSet, []*types.SignedMessage{m00, m01, m10, m20})
+		require.NoError(t, err)
+		assert.Equal(t, []*types.SignedMessage{m10, m20, m00, m01}, selected)
+	})
+
+	t.Run("drops messages with used or unreachable nonces", func(t *testing.T) {
+		actors := fakeActorProvider{
+			a0: account(1, 1000),
+			a1: account(0, 1000),
+		}
+		m00, m01, m02 := sign(a0, 0, 100, 1), sign(a0, 1, 100, 1), sign(a0, 2, 100, 1)
+		// A gap in the nonces of a1.
+		m10, m12, m13 := sign(a1, 0, 100, 1), sign(a1, 2, 100, 1), sign(a1, 3, 100, 1)
+
+		selected, err := NewGreedySelector(actors).SelectMessages(ctx, block.UndefTipSet, []*types.SignedMessage{m00, m01, m02, m10, m12, m13})
+		require.NoError(t, err)
+		assert.Equal(t, []*types.SignedMessage{m01, m02, m10}, selected)
+	})
+
+	t.Run("drops messages the sender cannot pay for", func(t *testing.T) {
+		actors := fakeActorProvider{
+			// Enough for two messages of 100 gas at 1 FIL per unit.
+			a0: account(0, 250),
+		}
+		price := types.NewAttoFILFromFIL(1)
+		signPriced := func(nonce uint64) *types.SignedMessage {
+			msg := types.NewMeteredMessage(a0, to, nonce, types.ZeroAttoFIL, builtin.MethodSend, []byte{}, price, types.GasUnits(100))
+			s, err := types.NewSignedMessage(*msg, &mockSigner)
+			require.NoError(t, err)
+			return s
+		}
+		m0, m1, m2 := signPriced(0), signPriced(1), signPriced(2)
+
+		selected, err := NewGreedySelector(actors).SelectMessages(ctx, block.UndefTipSet, []*types.SignedMessage{m0, m1, m2})
+		require.NoError(t, err)
+		assert.Equal(t, []*types.SignedMessage{m0, m1}, selected)
+	})
+
+	t.Run("drops messages from unknown senders", func(t *testing.T) {
+		m0 := sign(a0, 0, 100, 1)
+		m3 := sign(a3, 0, 100, 5)
+
+		selected, err := NewGreedySelector(rich).SelectMessages(ctx, block.UndefTipSet, []*types.SignedMessage{m0, m3})
+		require.NoError(t, err)
+		assert.Equal(t, []*types.SignedMessage{m0}, selected)
+	})
+
+	t.Run("drops messages failing in the vm", func(t *testing.T) {
+		m00 := sign(a0, 0, 100, 3)
+		call := types.NewMeteredMessage(a0, to, 1, types.ZeroAttoFIL, abi.MethodNum(42), []byte{}, types.NewGasPrice(3), types.GasUnits(100))
+		m01, err := types.NewSignedMessage(*call, &mockSigner)
+		require.NoError(t, err)
+		m02 := sign(a0, 2, 100, 3)
+		m10 := sign(a1, 0, 100, 1)
+
+		selected, err := NewGreedySelector(rich).SelectMessages(ctx, block.UndefTipSet, []*types.SignedMessage{m00, m01, m02, m10})
+		require.NoError(t, err)
+		assert.Equal(t, []*types.SignedMessage{m00, m10}, selected)
+	})
+
+	t.Run("fails when the senders cannot be loaded", func(t *testing.T) {
+		_, err := NewGreedySelector(failingActorProvider{}).SelectMessages(ctx, block.UndefTipSet, []*types.SignedMessage{sign(a0, 0, 100, 1)})
+		assert.Error(t, err)
+	})
+
+	t.Run("respects the gas limit", func(t *testing.T) {
+		selector := NewGreedySelector(rich)
+		selector.GasLimit = 1000
+		m00, m01 := sign(a0, 0, 600, 3), sign(a0, 1, 100, 3)
+		m10 := sign(a1, 0, 600, 2)
+		m20 := sign(a2, 0, 300, 1)
+
+		// Once a1's message does not fit, the smaller one of a2 still does.
+		selected, err := selector.SelectMessages(ctx, block.UndefTipSet, []*types.SignedMessage{m00, m01, m10, m20})
+		require.NoError(t, err)
+		assert.Equal(t, []*types.SignedMessage{m00, m01, m20}, selected)
+	})
+
+	t.Run("respects the message count and size caps", func(t *testing.T) {
+		m00, m01 := sign(a0, 0, 100, 3), sign(a0, 1, 100, 3)
+		m10 := sign(a1, 0, 100, 2)
+
+		selector := NewGreedySelector(rich)
+		selector.MaxMessages = 2
+		selected, err := selector.SelectMessages(ctx, block.UndefTipSet, []*types.SignedMessage{m00, m01, m10})
+		require.NoError(t, err)
+		assert.Equal(t, []*types.SignedMessage{m00, m01}, selected)
+
+		selector = NewGreedySelector(rich)
+		selector.MaxBytes = m00.OnChainLen() + m10.OnChainLen()
+		selected, err = selector.SelectMessages(ctx, block.UndefTipSet, []*types.SignedMessage{m00, m01, m10})
+		require.NoError(t, err)
+		assert.Len(t, selected, 2)
+		assert.Equal(t, m00, selected[0])
+	})
+}
+
+func TestPoolSelector(t *testing.T) {
+	tf.UnitTest(t)
+	ctx := context.Background()
+
+	ki := types.MustGenerateKeyInfo(3, 42)
+	mockSigner := types.NewMockSigner(ki)
+	a0, a1, to := mockSigner.Addresses[0], mockSigner.Addresses[1], mockSigner.Addresses[2]
+
+	sign := func(from address.Address, nonce uint64, price int64) *types.SignedMessage {
+		msg := types.NewMeteredMessage(from, to, nonce, types.ZeroAttoFIL, builtin.MethodSend, []byte{}, types.NewGasPrice(price), types.GasUnits(100))
+		s, err := types.NewSignedMessage(*msg, &mockSigner)
+		require.NoError(t, err)
+		return s
+	}
+	actors := fakeActorProvider{
+		a0: actor.NewActor(builtin.AccountActorCodeID, types.NewAttoFILFromFIL(1000)),
+		a1: actor.NewActor(builtin.AccountActorCodeID, types.NewAttoFILFromFIL(1000)),
+	}
+
+	m01, m00 := sign(a0, 1, 1), sign(a0, 0, 1)
+	m10 := sign(a1, 0, 5)
+	selected, err := NewPoolSelector(actors).SelectMessages(ctx, block.UndefTipSet, []*types.SignedMessage{m01, m10, m00})
+	require.NoError(t, err)
+	assert.Equal(t, []*types.SignedMessage{m00, m01, m10}, selected)
+}
@@ -1,7 +1,6 @@
 package mining
 
 import (
-	"bytes"
 	"container/heap"
 	"sort"
 
@@ -13,7 +12,9 @@ import (
 
 // MessageQueue is a priority queue of messages from different actors. Messages are ordered
 // by decreasing gas price, subject to the constraint that messages from a single actor are
-// always in increasing nonce order.
+// always in increasing nonce order. Between actors whose next messages have equal gas price,
+// the queue preserves the order in which the actors first appear in its input, so that it
+// follows the ranking of a message source such as the message pool.
 // All messages for a queue are inserted at construction, after which messages may only
 // be popped.
 // Potential improvements include:
@@ -27,19 +28,23 @@ type MessageQueue struct {
 
 // NewMessageQueue allocates and initializes a message queue.
 func NewMessageQueue(msgs []*types.SignedMessage) MessageQueue {
-	// Group messages by sender.
-	bySender := make(map[address.Address]nonceQueue)
+	// Group messages by sender, ranking senders by first appearance.
+	bySender := make(map[address.Address]*nonceQueue)
 	for _, m := range msgs {
-		bySender[m.Message.From] = append(bySender[m.Message.From], m)
+		nq, ok := bySender[m.Message.From]
+		if !ok {
+			nq = &nonceQueue{rank: len(bySender)}
+			bySender[m.Message.From] = nq
+		}
+		nq.msgs = append(nq.msgs, m)
 	}
 
 	// Order each sender queue by nonce and initialize heap structure.
 	addrHeap := make(queueHeap, len(bySender))
-	heapIdx := 0
 	for _, nq := range bySender {
-		sort.Slice(nq, func(i, j int) bool { return nq[i].Message.CallSeqNum < nq[j].Message.CallSeqNum })
-		addrHeap[heapIdx] = nq
-		heapIdx++
+		queued := nq.msgs
+		sort.Slice(queued, func(i, j int) bool { return queued[i].Message.CallSeqNum < queued[j].Message.CallSeqNum })
+		addrHeap[nq.rank] = *nq
 	}
 	heap.Init(&addrHeap)
 
@@ -60,14 +65,14 @@ func (mq *MessageQueue) Pop() (*types.SignedMessage, bool) {
 	bestQueue := &mq.senderQueues[0]
 
 	// Pop first message off that actor's queue
-	msg := (*bestQueue)[0]
-	if len(*bestQueue) == 1 {
+	msg := bestQueue.msgs[0]
+	if len(bestQueue.msgs) == 1 {
 		// If the actor's queue will become empty, remove it from the heap.
 		heap.Pop(&mq.senderQueues)
 	} else {
 		// If the actor's queue still has elements, remove the first and relocate the queue in the heap
 		// according to the gas price of its next message.
-		*bestQueue = (*bestQueue)[1:]
+		bestQueue.msgs = bestQueue.msgs[1:]
 		heap.Fix(&mq.senderQueues, 0)
 	}
 	return msg, true
@@ -82,8 +87,11 @@ func (mq *MessageQueue) Drain() []*types.SignedMessage {
 	return out
 }
 
-// A slice of messages ordered by CallSeqNum (for a single sender).
-type nonceQueue []*types.SignedMessage
+// A slice of messages ordered by CallSeqNum (for a single sender), with the sender's rank in the input.
+type nonceQueue struct {
+	msgs []*types.SignedMessage
+	rank int
+}
 
 // Implements heap.Interface to hold a priority queue of nonce-ordered queues, one per sender.
 // Heap priority is given by the gas price of the first message for each queue.
@@ -93,15 +101,15 @@ type queueHeap []nonceQueue
 
 func (pq queueHeap) Len() int { return len(pq) }
 
-// Less implements Heap.Interface.Less to compare items on gas price and sender address.
+// Less implements Heap.Interface.Less to compare items on gas price and sender rank.
 func (pq queueHeap) Less(i, j int) bool {
-	delta := specsbig.Sub(pq[i][0].Message.GasPrice, pq[j][0].Message.GasPrice)
+	delta := specsbig.Sub(pq[i].msgs[0].Message.GasPrice, pq[j].msgs[0].Message.GasPrice)
 	if !delta.IsZero() {
 		// We want Pop to give us the highest gas price, so use GreaterThan.
 		return delta.GreaterThan(types.ZeroAttoFIL)
 	}
-	// Secondarily order by address to give a stable ordering.
-	return bytes.Compare(pq[i][0].Message.From.Bytes(), pq[j][0].Message.From.Bytes()) < 0
+	// Secondarily order by the input ranking to give a stable ordering.
+	return pq[i].rank < pq[j].rank
 }
 
 func (pq queueHeap) Swap(i, j int) {
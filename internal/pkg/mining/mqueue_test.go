@@ -88,6 +88,16 @@ func TestMessageQueueOrder(t *testing.T) {
 		assert.True(t, q.Empty())
 	})
 
+	t.Run("preserves input ranking between equal gas prices", func(t *testing.T) {
+		msgs := []*types.SignedMessage{
+			sign(a2, to, 0, 0, 1),
+			sign(a0, to, 0, 0, 1),
+			sign(a1, to, 0, 0, 1),
+		}
+		q := NewMessageQueue(msgs)
+		assert.Equal(t, msgs, q.Drain())
+	})
+
 	t.Run("nonce overrides gas price", func(t *testing.T) {
 		msgs := []*types.SignedMessage{
 			sign(a0, to, 0, 0, 1),
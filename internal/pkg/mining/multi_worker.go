@@ -0,0 +1,133 @@
+package mining
+
+// The MultiWorker mines for several miner actors of the same node, running
+// the election of each of them on every base the Scheduler provides.
+
+import (
+	"context"
+	"sync"
+
+	address "github.com/filecoin-project/go-address"
+	"github.com/filecoin-project/specs-actors/actors/abi"
+	cid "github.com/ipfs/go-cid"
+
+	"github.com/filecoin-project/go-filecoin/internal/pkg/block"
+)
+
+// MinerWorker is a Worker mining for a single miner actor.
+type MinerWorker interface {
+	Worker
+	MinerAddress() address.Address
+}
+
+// MinerResult sums up the elections run by a miner.
+type MinerResult struct {
+	Miner address.Address `json:"miner"`
+	// Elections is the number of elections run, Wins the number of those won.
+	Elections uint64 `json:"elections"`
+	Wins      uint64 `json:"wins"`
+	// LastEpoch is the epoch of the last election run.
+	LastEpoch abi.ChainEpoch `json:"lastEpoch"`
+	// LastBlock is the last block mined, undefined if none was.
+	LastBlock cid.Cid `json:"lastBlock"`
+	// LastError is the error of the last election, if it failed.
+	LastError string `json:"lastError,omitempty"`
+}
+
+// MultiWorker runs the elections of several miners concurrently, each with
+// its own worker, and outputs the blocks of all the winners.
+type MultiWorker struct {
+	workers []MinerWorker
+
+	lk      sync.Mutex
+	results map[address.Address]*MinerResult
+}
+
+// NewMultiWorker returns a worker running the elections of `workers`.
+func NewMultiWorker(workers ...MinerWorker) *MultiWorker {
+	results := make(map[address.Address]*MinerResult, len(workers))
+	for _, w := range workers {
+		results[w.MinerAddress()] = &MinerResult{Miner: w.MinerAddress()}
+	}
+	return &MultiWorker{
+		workers: workers,
+		results: results,
+	}
+}
+
+// Mine runs the election of every miner on `base`. The returned bool
+// indicates if any of them created a new block.
+func (m *MultiWorker) Mine(ctx context.Context, base block.TipSet, nullBlkCount uint64, outCh chan<- Output) bool {
+	var epoch abi.ChainEpoch
+	if base.Defined() {
+		if h, err := base.Height(); err == nil {
+			epoch = h + abi.ChainEpoch(nullBlkCount) + 1
+		}
+	}
+
+	var wg sync.WaitGroup
+	wins := make(chan bool, len(m.workers))
+	for _, w := range m.workers {
+		wg.Add(1)
+		go func(w MinerWorker) {
+			defer wg.Done()
+			// Each worker outputs at most once per run.
+			workerOut := make(chan Output, 1)
+			won := w.Mine(ctx, base, nullBlkCount, workerOut)
+
+			var out *Output
+			select {
+			case o := <-workerOut:
+				out = &o
+			default:
+			}
+			m.record(w.MinerAddress(), epoch, out)
+			wins <- won
+
+			if out != nil {
+				select {
+				case outCh <- *out:
+				case <-ctx.Done():
+				}
+			}
+		}(w)
+	}
+	wg.Wait()
+	close(wins)
+
+	anyWon := false
+	for won := range wins {
+		anyWon = anyWon || won
+	}
+	return anyWon
+}
+
+// Results returns the results of the elections of each miner, in the order
+// of the workers.
+func (m *MultiWorker) Results() []MinerResult {
+	m.lk.Lock()
+	defer m.lk.Unlock()
+	out := make([]MinerResult, len(m.workers))
+	for i, w := range m.workers {
+		out[i] = *m.results[w.MinerAddress()]
+	}
+	return out
+}
+
+func (m *MultiWorker) record(miner address.Address, epoch abi.ChainEpoch, out *Output) {
+	m.lk.Lock()
+	defer m.lk.Unlock()
+	result := m.results[miner]
+	result.Elections++
+	result.LastEpoch = epoch
+	result.LastError = ""
+	if out == nil {
+		return
+	}
+	if out.Err != nil {
+		result.LastError = out.Err.Error()
+		return
+	}
+	result.Wins++
+	result.LastBlock = out.NewBlock.Cid()
+}
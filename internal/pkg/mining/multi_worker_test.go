@@ -0,0 +1,105 @@
+package mining_test
+
+import (
+	"context"
+	"testing"
+
+	"github.com/filecoin-project/go-address"
+	"github.com/filecoin-project/specs-actors/actors/abi"
+	"github.com/pkg/errors"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/filecoin-project/go-filecoin/internal/pkg/block"
+	e "github.com/filecoin-project/go-filecoin/internal/pkg/enccid"
+	. "github.com/filecoin-project/go-filecoin/internal/pkg/mining"
+	tf "github.com/filecoin-project/go-filecoin/internal/pkg/testhelpers/testflags"
+	"github.com/filecoin-project/go-filecoin/internal/pkg/types"
+	vmaddr "github.com/filecoin-project/go-filecoin/internal/pkg/vm/address"
+)
+
+// fakeMinerWorker wins with a block mined by its miner when `wins` is set, or
+// fails with `err`.
+type fakeMinerWorker struct {
+	miner address.Address
+	wins  bool
+	err   error
+}
+
+func (w *fakeMinerWorker) MinerAddress() address.Address {
+	return w.miner
+}
+
+func (w *fakeMinerWorker) Mine(_ context.Context, base block.TipSet, nullBlkCount uint64, outCh chan<- Output) bool {
+	if w.err != nil {
+		outCh <- NewOutput(nil, w.err)
+		return false
+	}
+	if !w.wins {
+		return false
+	}
+	h, _ := base.Height()
+	outCh <- NewOutput(&block.Block{Miner: w.miner, Height: h + abi.ChainEpoch(nullBlkCount) + 1, Parents: base.Key()}, nil)
+	return true
+}
+
+func TestMultiWorker(t *testing.T) {
+	tf.UnitTest(t)
+	ctx := context.Background()
+
+	m1, m2, m3 := vmaddr.RequireIDAddress(t, 101), vmaddr.RequireIDAddress(t, 102), vmaddr.RequireIDAddress(t, 103)
+	base, err := block.NewTipSet(&block.Block{Height: 4, StateRoot: e.NewCid(types.CidFromString(t, "state"))})
+	require.NoError(t, err)
+
+	t.Run("outputs the blocks of all winners", func(t *testing.T) {
+		w1 := &fakeMinerWorker{miner: m1, wins: true}
+		w2 := &fakeMinerWorker{miner: m2}
+		w3 := &fakeMinerWorker{miner: m3, wins: true}
+		worker := NewMultiWorker(w1, w2, w3)
+
+		outCh := make(chan Output, 3)
+		assert.True(t, worker.Mine(ctx, base, 1, outCh))
+		close(outCh)
+
+		miners := make(map[address.Address]abi.ChainEpoch)
+		for out := range outCh {
+			require.NoError(t, out.Err)
+			miners[out.NewBlock.Miner] = out.NewBlock.Height
+		}
+		assert.Equal(t, map[address.Address]abi.ChainEpoch{m1: 6, m3: 6}, miners)
+	})
+
+	t.Run("no winner", func(t *testing.T) {
+		worker := NewMultiWorker(&fakeMinerWorker{miner: m1}, &fakeMinerWorker{miner: m2})
+		outCh := make(chan Output, 2)
+		assert.False(t, worker.Mine(ctx, base, 0, outCh))
+		assert.Empty(t, outCh)
+	})
+
+	t.Run("reports the results of each miner", func(t *testing.T) {
+		w1 := &fakeMinerWorker{miner: m1, wins: true}
+		w2 := &fakeMinerWorker{miner: m2, err: errors.New("no worker key")}
+		w3 := &fakeMinerWorker{miner: m3}
+		worker := NewMultiWorker(w1, w2, w3)
+
+		outCh := make(chan Output, 3)
+		worker.Mine(ctx, base, 0, outCh)
+		w1.wins = false
+		worker.Mine(ctx, base, 1, outCh)
+		close(outCh)
+
+		var mined *block.Block
+		for out := range outCh {
+			if out.Err == nil {
+				mined = out.NewBlock
+			}
+		}
+		require.NotNil(t, mined)
+
+		results := worker.Results()
+		require.Len(t, results, 3)
+		assert.Equal(t, MinerResult{Miner: m1, Elections: 2, Wins: 1, LastEpoch: 6, LastBlock: mined.Cid()}, results[0])
+		assert.Equal(t, MinerResult{Miner: m2, Elections: 2, LastEpoch: 6, LastError: "no worker key"}, results[1])
+		assert.Equal(t, MinerResult{Miner: m3, Elections: 2, LastEpoch: 6}, results[2])
+	})
+}
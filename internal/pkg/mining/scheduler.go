@@ -142,18 +142,29 @@ func NewScheduler(w Worker, f func() (block.TipSet, error), c clock.ChainEpochCl
 
 // MineOnce mines on a given base until it finds a winner.
 func MineOnce(ctx context.Context, w DefaultWorker, ts block.TipSet, c clock.ChainEpochClock) (Output, error) {
-	var winner *block.Block
-	var nullCount uint64
-	for winner == nil {
-		var err error
-		winner, err = MineOneEpoch(ctx, w, ts, nullCount, c)
-		if err != nil {
+	return MineOnceWithAny(ctx, []*DefaultWorker{&w}, ts, c)
+}
+
+// MineOnceWithAny mines on a given base until one of the workers finds a
+// winner, running the election of each of them at every epoch.
+func MineOnceWithAny(ctx context.Context, workers []*DefaultWorker, ts block.TipSet, c clock.ChainEpochClock) (Output, error) {
+	if len(workers) == 0 {
+		return Output{}, errors.New("no mining workers")
+	}
+	for nullCount := uint64(0); ; nullCount++ {
+		for _, w := range workers {
+			winner, err := MineOneEpoch(ctx, *w, ts, nullCount, c)
+			if err != nil {
+				return Output{}, err
+			}
+			if winner != nil {
+				return Output{NewBlock: winner}, nil
+			}
+		}
+		if err := ctx.Err(); err != nil {
 			return Output{}, err
 		}
-		nullCount++
 	}
-
-	return Output{NewBlock: winner}, nil
 }
 
 // MineOneEpoch attempts to mine a block in an epoch and returns the mined block
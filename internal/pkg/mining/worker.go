@@ -25,6 +25,7 @@ import (
 	"github.com/filecoin-project/go-filecoin/internal/pkg/postgenerator"
 	"github.com/filecoin-project/go-filecoin/internal/pkg/types"
 	"github.com/filecoin-project/go-filecoin/internal/pkg/util/hasher"
+	"github.com/filecoin-project/go-filecoin/internal/pkg/vm"
 	"github.com/filecoin-project/go-filecoin/internal/pkg/vm/state"
 )
 
@@ -65,9 +66,10 @@ type MessageSource interface {
 	Remove(message cid.Cid)
 }
 
-// A MessageApplier processes all the messages in a message pool.
+// A MessageApplier simulates the application of messages on the state of a
+// tipset.
 type MessageApplier interface {
-	// Dragons: add something back or remove
+	NewMessageSimulation(st state.Tree, vms vm.Storage, head block.TipSet) (*consensus.MessageSimulation, error)
 }
 
 type workerPorcelainAPI interface {
@@ -101,17 +103,18 @@ type DefaultWorker struct {
 	minerOwnerAddr address.Address
 	workerSigner   types.Signer
 
-	tsMetadata    tipSetMetadata
-	getStateTree  GetStateTree
-	getWeight     GetWeight
-	election      electionUtil
-	ticketGen     ticketGenerator
-	messageSource MessageSource
-	processor     MessageApplier
-	messageStore  chain.MessageWriter // nolint: structcheck
-	blockstore    blockstore.Blockstore
-	clock         clock.Clock
-	poster        postgenerator.PoStGenerator
+	tsMetadata      tipSetMetadata
+	getStateTree    GetStateTree
+	getWeight       GetWeight
+	election        electionUtil
+	ticketGen       ticketGenerator
+	messageSource   MessageSource
+	messageSelector MessageSelector
+	processor       MessageApplier
+	messageStore    chain.MessageWriter // nolint: structcheck
+	blockstore      blockstore.Blockstore
+	clock           clock.Clock
+	poster          postgenerator.PoStGenerator
 }
 
 // WorkerParameters use for NewDefaultWorker parameters
@@ -131,32 +134,76 @@ type WorkerParameters struct {
 
 	// core filecoin things
 	MessageSource MessageSource
-	Processor     MessageApplier
-	MessageStore  chain.MessageWriter
-	Blockstore    blockstore.Blockstore
-	Clock         clock.Clock
-	Poster        postgenerator.PoStGenerator
+	// MessageSelector defaults to a greedy selector simulating messages
+	// with Processor on the state trees of GetStateTree.
+	MessageSelector MessageSelector
+	Processor       MessageApplier
+	MessageStore    chain.MessageWriter
+	Blockstore      blockstore.Blockstore
+	Clock           clock.Clock
+	Poster          postgenerator.PoStGenerator
 }
 
 // NewDefaultWorker instantiates a new Worker.
 func NewDefaultWorker(parameters WorkerParameters) *DefaultWorker {
+	messageSelector := parameters.MessageSelector
+	if messageSelector == nil {
+		messageSelector = NewGreedySelector(NewStateSimulator(parameters.GetStateTree, parameters.Processor, parameters.Blockstore))
+	}
 	return &DefaultWorker{
-		api:            parameters.API,
-		getStateTree:   parameters.GetStateTree,
-		getWeight:      parameters.GetWeight,
-		messageSource:  parameters.MessageSource,
-		messageStore:   parameters.MessageStore,
-		processor:      parameters.Processor,
-		blockstore:     parameters.Blockstore,
-		minerAddr:      parameters.MinerAddr,
-		minerOwnerAddr: parameters.MinerOwnerAddr,
-		workerSigner:   parameters.WorkerSigner,
-		election:       parameters.Election,
-		ticketGen:      parameters.TicketGen,
-		tsMetadata:     parameters.TipSetMetadata,
-		clock:          parameters.Clock,
-		poster:         parameters.Poster,
+		api:             parameters.API,
+		getStateTree:    parameters.GetStateTree,
+		getWeight:       parameters.GetWeight,
+		messageSource:   parameters.MessageSource,
+		messageSelector: messageSelector,
+		messageStore:    parameters.MessageStore,
+		processor:       parameters.Processor,
+		blockstore:      parameters.Blockstore,
+		minerAddr:       parameters.MinerAddr,
+		minerOwnerAddr:  parameters.MinerOwnerAddr,
+		workerSigner:    parameters.WorkerSigner,
+		election:        parameters.Election,
+		ticketGen:       parameters.TicketGen,
+		tsMetadata:      parameters.TipSetMetadata,
+		clock:           parameters.Clock,
+		poster:          parameters.Poster,
+	}
+}
+
+// StateSimulator simulates messages on the state trees of tipsets.
+type StateSimulator struct {
+	getStateTree GetStateTree
+	processor    MessageApplier
+	bs           blockstore.Blockstore
+}
+
+// NewStateSimulator creates a simulator applying messages with `processor`
+// on the state trees of `getStateTree`, reading and writing the vm storage in
+// `bs`.
+func NewStateSimulator(getStateTree GetStateTree, processor MessageApplier, bs blockstore.Blockstore) *StateSimulator {
+	return &StateSimulator{
+		getStateTree: getStateTree,
+		processor:    processor,
+		bs:           bs,
+	}
+}
+
+// SimulateMessages implements MessageSimulator.
+func (s *StateSimulator) SimulateMessages(ctx context.Context, base block.TipSet) (MessageSimulation, error) {
+	st, err := s.getStateTree(ctx, base.Key())
+	if err != nil {
+		return nil, err
 	}
+	simulation, err := s.processor.NewMessageSimulation(st, vm.NewStorage(s.bs), base)
+	if err != nil {
+		return nil, err
+	}
+	return simulation, nil
+}
+
+// MinerAddress returns the address of the miner actor the worker mines for.
+func (w *DefaultWorker) MinerAddress() address.Address {
+	return w.minerAddr
 }
 
 // Mine implements the DefaultWorkers main mining function..
@@ -548,7 +548,7 @@ func TestGeneratePoolBlockResults(t *testing.T) {
 		TicketGen:      &consensus.FakeTicketMachine{},
 
 		MessageSource: pool,
-		Processor:     consensus.NewDefaultProcessor(syscalls, rnd),
+		Processor:     consensus.NewDefaultProcessor(syscalls, rnd, vm.DefaultPricelistSchedule()),
 		Blockstore:    bs,
 		MessageStore:  messages,
 		Clock:         th.NewFakeClock(time.Unix(1234567890, 0)),
@@ -654,7 +654,7 @@ func TestGenerateSetsBasicFields(t *testing.T) {
 		TicketGen:      &consensus.FakeTicketMachine{},
 
 		MessageSource: pool,
-		Processor:     consensus.NewDefaultProcessor(syscalls, rnd),
+		Processor:     consensus.NewDefaultProcessor(syscalls, rnd, vm.DefaultPricelistSchedule()),
 		Blockstore:    bs,
 		MessageStore:  messages,
 		Clock:         th.NewFakeClock(time.Unix(1234567890, 0)),
@@ -715,7 +715,7 @@ func TestGenerateWithoutMessages(t *testing.T) {
 		TicketGen:      &consensus.FakeTicketMachine{},
 
 		MessageSource: pool,
-		Processor:     consensus.NewDefaultProcessor(syscalls, rnd),
+		Processor:     consensus.NewDefaultProcessor(syscalls, rnd, vm.DefaultPricelistSchedule()),
 		Blockstore:    bs,
 		MessageStore:  messages,
 		Clock:         th.NewFakeClock(time.Unix(1234567890, 0)),
@@ -770,7 +770,7 @@ func TestGenerateError(t *testing.T) {
 		TicketGen:      &consensus.FakeTicketMachine{},
 
 		MessageSource: pool,
-		Processor:     consensus.NewDefaultProcessor(syscalls, rnd),
+		Processor:     consensus.NewDefaultProcessor(syscalls, rnd, vm.DefaultPricelistSchedule()),
 		Blockstore:    bs,
 		MessageStore:  messages,
 		Clock:         th.NewFakeClock(time.Unix(1234567890, 0)),
@@ -21,6 +21,8 @@ type SwarmConnInfo struct {
 	Latency string
 	Muxer   string
 	Streams []SwarmStreamInfo
+	// Score is the sync behaviour score of the peer, when requested.
+	Score *int `json:",omitempty"`
 }
 
 // SwarmStreamInfo represents details about a single swarm stream.
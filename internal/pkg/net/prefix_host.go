@@ -0,0 +1,90 @@
+package net
+
+import (
+	"context"
+	"strings"
+
+	"github.com/libp2p/go-libp2p-core/host"
+	"github.com/libp2p/go-libp2p-core/network"
+	"github.com/libp2p/go-libp2p-core/peer"
+	"github.com/libp2p/go-libp2p-core/protocol"
+)
+
+// PieceProtocolPrefix prefixes the protocols exchanging client and piece
+// data, so they run apart from the ones exchanging chain data.
+const PieceProtocolPrefix = "/fil/piece"
+
+// prefixHost is a host whose stream protocols are all prefixed. It lets a
+// second instance of a protocol such as bitswap run on the same host.
+type prefixHost struct {
+	host.Host
+	prefix string
+}
+
+var _ host.Host = (*prefixHost)(nil)
+
+// NewPrefixHost wraps `h` so that the protocols of the streams opened and
+// handled through it are prefixed with `prefix`. Streams report their
+// protocol without the prefix.
+func NewPrefixHost(h host.Host, prefix string) host.Host {
+	return &prefixHost{Host: h, prefix: prefix}
+}
+
+// SetStreamHandler sets the handler of the prefixed protocol.
+func (h *prefixHost) SetStreamHandler(pid protocol.ID, handler network.StreamHandler) {
+	h.Host.SetStreamHandler(h.prefixed(pid), h.wrapHandler(handler))
+}
+
+// SetStreamHandlerMatch sets the handler of the prefixed protocol, matching
+// protocols with the prefix removed.
+func (h *prefixHost) SetStreamHandlerMatch(pid protocol.ID, match func(string) bool, handler network.StreamHandler) {
+	prefixedMatch := func(s string) bool {
+		return strings.HasPrefix(s, h.prefix) && match(strings.TrimPrefix(s, h.prefix))
+	}
+	h.Host.SetStreamHandlerMatch(h.prefixed(pid), prefixedMatch, h.wrapHandler(handler))
+}
+
+// RemoveStreamHandler removes the handler of the prefixed protocol.
+func (h *prefixHost) RemoveStreamHandler(pid protocol.ID) {
+	h.Host.RemoveStreamHandler(h.prefixed(pid))
+}
+
+// NewStream opens a stream to `p` speaking the first supported of the
+// prefixed protocols.
+func (h *prefixHost) NewStream(ctx context.Context, p peer.ID, pids ...protocol.ID) (network.Stream, error) {
+	prefixed := make([]protocol.ID, len(pids))
+	for i, pid := range pids {
+		prefixed[i] = h.prefixed(pid)
+	}
+	s, err := h.Host.NewStream(ctx, p, prefixed...)
+	if err != nil {
+		return nil, err
+	}
+	return &prefixStream{Stream: s, prefix: h.prefix}, nil
+}
+
+func (h *prefixHost) prefixed(pid protocol.ID) protocol.ID {
+	return protocol.ID(h.prefix + string(pid))
+}
+
+func (h *prefixHost) wrapHandler(handler network.StreamHandler) network.StreamHandler {
+	return func(s network.Stream) {
+		handler(&prefixStream{Stream: s, prefix: h.prefix})
+	}
+}
+
+// prefixStream is a stream whose protocol is reported without its prefix.
+type prefixStream struct {
+	network.Stream
+	prefix string
+}
+
+// Protocol returns the protocol of the stream without the prefix.
+func (s *prefixStream) Protocol() protocol.ID {
+	return protocol.ID(strings.TrimPrefix(string(s.Stream.Protocol()), s.prefix))
+}
+
+// SetProtocol sets the prefixed protocol of the stream.
+func (s *prefixStream) SetProtocol(pid protocol.ID) {
+	s.Stream.SetProtocol(protocol.ID(s.prefix + string(pid)))
+}
@@ -0,0 +1,58 @@
+package net_test
+
+import (
+	"context"
+	"io/ioutil"
+	"testing"
+
+	"github.com/libp2p/go-libp2p-core/host"
+	"github.com/libp2p/go-libp2p-core/network"
+	"github.com/libp2p/go-libp2p-core/protocol"
+	mocknet "github.com/libp2p/go-libp2p/p2p/net/mock"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/filecoin-project/go-filecoin/internal/pkg/net"
+	tf "github.com/filecoin-project/go-filecoin/internal/pkg/testhelpers/testflags"
+)
+
+func TestPrefixHost(t *testing.T) {
+	tf.UnitTest(t)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	mn, err := mocknet.FullMeshConnected(ctx, 2)
+	require.NoError(t, err)
+	a, b := mn.Hosts()[0], mn.Hosts()[1]
+	prefixedA := net.NewPrefixHost(a, net.PieceProtocolPrefix)
+	prefixedB := net.NewPrefixHost(b, net.PieceProtocolPrefix)
+
+	const pid = protocol.ID("/test/1.0.0")
+	received := make(chan string, 2)
+	handler := func(name string) network.StreamHandler {
+		return func(s network.Stream) {
+			defer func() { _ = s.Close() }()
+			assert.Equal(t, pid, s.Protocol())
+			msg, err := ioutil.ReadAll(s)
+			assert.NoError(t, err)
+			received <- name + ": " + string(msg)
+		}
+	}
+	b.SetStreamHandler(pid, handler("plain"))
+	prefixedB.SetStreamHandler(pid, handler("prefixed"))
+
+	send := func(from host.Host, msg string) {
+		s, err := from.NewStream(ctx, b.ID(), pid)
+		require.NoError(t, err)
+		assert.Equal(t, pid, s.Protocol())
+		_, err = s.Write([]byte(msg))
+		require.NoError(t, err)
+		require.NoError(t, s.Close())
+	}
+
+	send(prefixedA, "piece")
+	assert.Equal(t, "prefixed: piece", <-received)
+	send(a, "chain")
+	assert.Equal(t, "plain: chain", <-received)
+}
@@ -20,6 +20,11 @@ var mInvalidBlk = metrics.NewInt64Counter("net/pubsub_invalid_block", "Number of
 var mDecodeMsgFail = metrics.NewInt64Counter("net/pubsub_message_decode_failure", "Number of messages that fail to decode seen on MessageTopic pubsub channel")
 var mInvalidMsg = metrics.NewInt64Counter("net/pubsub_invalid_message", "Number of messages that fail syntax validation seen on MessageTopic pubsub channel")
 
+// gossipScorer is told about peers relaying invalid blocks.
+type gossipScorer interface {
+	RecordGossipFailure(p peer.ID)
+}
+
 // BlockTopicValidator may be registered on go-libp2p-pubsub to validate pubsub messages on the
 // BlockTopic.
 type BlockTopicValidator struct {
@@ -27,8 +32,10 @@ type BlockTopicValidator struct {
 	opts      []pubsub.ValidatorOpt
 }
 
-// NewBlockTopicValidator retruns a BlockTopicValidator using `bv` for message validation
-func NewBlockTopicValidator(bv consensus.BlockSyntaxValidator, opts ...pubsub.ValidatorOpt) *BlockTopicValidator {
+// NewBlockTopicValidator retruns a BlockTopicValidator using `bv` for message validation.
+// Peers relaying blocks that fail to decode or break the consensus rules are
+// reported to `scorer` unless it is nil.
+func NewBlockTopicValidator(bv consensus.BlockSyntaxValidator, scorer gossipScorer, opts ...pubsub.ValidatorOpt) *BlockTopicValidator {
 	return &BlockTopicValidator{
 		opts: opts,
 		validator: func(ctx context.Context, p peer.ID, msg *pubsub.Message) bool {
@@ -36,11 +43,19 @@ func NewBlockTopicValidator(bv consensus.BlockSyntaxValidator, opts ...pubsub.Va
 			if err != nil {
 				blockTopicLogger.Debugf("block from peer: %s failed to decode: %s", p.String(), err.Error())
 				mDecodeBlkFail.Inc(ctx, 1)
+				if scorer != nil {
+					scorer.RecordGossipFailure(p)
+				}
 				return false
 			}
 			if err := bv.ValidateSyntax(ctx, blk); err != nil {
 				blockTopicLogger.Debugf("block: %s from peer: %s failed to validate: %s", blk.Cid().String(), p.String(), err.Error())
 				mInvalidBlk.Inc(ctx, 1)
+				// Blocks failing for other reasons, such as a skewed clock,
+				// are not held against the peer.
+				if scorer != nil && consensus.IsInvalid(err) {
+					scorer.RecordGossipFailure(p)
+				}
 				return false
 			}
 			return true
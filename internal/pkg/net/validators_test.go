@@ -8,6 +8,7 @@ import (
 
 	"github.com/filecoin-project/go-address"
 	"github.com/filecoin-project/specs-actors/actors/abi"
+	"github.com/libp2p/go-libp2p-core/peer"
 	pubsub "github.com/libp2p/go-libp2p-pubsub"
 	pubsub_pb "github.com/libp2p/go-libp2p-pubsub/pb"
 	mocknet "github.com/libp2p/go-libp2p/p2p/net/mock"
@@ -32,7 +33,8 @@ func TestBlockTopicValidator(t *testing.T) {
 
 	ctx := context.Background()
 	mbv := th.NewStubBlockValidator()
-	tv := net.NewBlockTopicValidator(mbv, nil)
+	scorer := &fakeGossipScorer{}
+	tv := net.NewBlockTopicValidator(mbv, scorer)
 	builder := chain.NewBuilder(t, address.Undef)
 	pid1 := th.RequireIntPeerID(t, 1)
 
@@ -40,8 +42,12 @@ func TestBlockTopicValidator(t *testing.T) {
 	badBlk := builder.BuildOnBlock(nil, func(b *chain.BlockBuilder) {
 		b.IncHeight(1)
 	})
+	futureBlk := builder.BuildOnBlock(nil, func(b *chain.BlockBuilder) {
+		b.IncHeight(2)
+	})
 
-	mbv.StubSyntaxValidationForBlock(badBlk, fmt.Errorf("invalid block"))
+	mbv.StubSyntaxValidationForBlock(badBlk, consensus.Invalid(fmt.Errorf("invalid block")))
+	mbv.StubSyntaxValidationForBlock(futureBlk, fmt.Errorf("future block"))
 
 	validator := tv.Validator()
 
@@ -50,6 +56,20 @@ func TestBlockTopicValidator(t *testing.T) {
 	assert.True(t, validator(ctx, pid1, blkToPubSub(goodBlk)))
 	assert.False(t, validator(ctx, pid1, blkToPubSub(badBlk)))
 	assert.False(t, validator(ctx, pid1, nonBlkPubSubMsg()))
+	assert.Equal(t, []peer.ID{pid1, pid1}, scorer.failures)
+
+	// Blocks failing without breaking the consensus rules are not held
+	// against the peer.
+	assert.False(t, validator(ctx, pid1, blkToPubSub(futureBlk)))
+	assert.Equal(t, []peer.ID{pid1, pid1}, scorer.failures)
+}
+
+type fakeGossipScorer struct {
+	failures []peer.ID
+}
+
+func (s *fakeGossipScorer) RecordGossipFailure(p peer.ID) {
+	s.failures = append(s.failures, p)
 }
 
 func TestBlockPubSubValidation(t *testing.T) {
@@ -70,7 +90,7 @@ func TestBlockPubSubValidation(t *testing.T) {
 	// setup a block validator and a topic validator
 	chainClock := clock.NewChainClockFromClock(uint64(now.Unix()), blocktime, mclock)
 	bv := consensus.NewDefaultBlockValidator(chainClock)
-	btv := net.NewBlockTopicValidator(bv)
+	btv := net.NewBlockTopicValidator(bv, nil)
 
 	// setup a floodsub instance on the host and register the topic validator
 	network := "gfctest"
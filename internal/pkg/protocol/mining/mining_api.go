@@ -19,37 +19,43 @@ type miningChainReader interface {
 // API provides an interface to the block mining protocol.
 type API struct {
 	minerAddress    func() (address.Address, error)
+	minerAddresses  func() ([]address.Address, error)
 	addNewBlockFunc func(context.Context, *block.Block) (err error)
 	chainReader     miningChainReader
 	isMiningFunc    func() bool
 	setupMiningFunc func(context.Context) error
 	startMiningFunc func(context.Context) error
 	stopMiningFunc  func(context.Context)
-	getWorkerFunc   func(ctx context.Context) (*mining.DefaultWorker, error)
+	getWorkersFunc  func(ctx context.Context) ([]*mining.DefaultWorker, error)
+	minerResults    func() []mining.MinerResult
 	chainClock      clock.ChainEpochClock
 }
 
 // New creates a new API instance with the provided deps
 func New(
 	minerAddr func() (address.Address, error),
+	minerAddrs func() ([]address.Address, error),
 	addNewBlockFunc func(context.Context, *block.Block) (err error),
 	chainReader miningChainReader,
 	isMiningFunc func() bool,
 	setupMiningFunc func(ctx context.Context) error,
 	startMiningFunc func(context.Context) error,
 	stopMiningfunc func(context.Context),
-	getWorkerFunc func(ctx context.Context) (*mining.DefaultWorker, error),
+	getWorkersFunc func(ctx context.Context) ([]*mining.DefaultWorker, error),
+	minerResults func() []mining.MinerResult,
 	chainClock clock.ChainEpochClock,
 ) API {
 	return API{
 		minerAddress:    minerAddr,
+		minerAddresses:  minerAddrs,
 		addNewBlockFunc: addNewBlockFunc,
 		chainReader:     chainReader,
 		isMiningFunc:    isMiningFunc,
 		setupMiningFunc: setupMiningFunc,
 		startMiningFunc: startMiningFunc,
 		stopMiningFunc:  stopMiningfunc,
-		getWorkerFunc:   getWorkerFunc,
+		getWorkersFunc:  getWorkersFunc,
+		minerResults:    minerResults,
 		chainClock:      chainClock,
 	}
 }
@@ -60,12 +66,24 @@ func (a *API) MinerAddress() (address.Address, error) {
 	return a.minerAddress()
 }
 
+// MinerAddresses returns the addresses of all the miners the API mines for,
+// an error is returned if the mining address is not set.
+func (a *API) MinerAddresses() ([]address.Address, error) {
+	return a.minerAddresses()
+}
+
+// MinerResults returns the results of the elections run by each miner.
+func (a *API) MinerResults() []mining.MinerResult {
+	return a.minerResults()
+}
+
 // MiningIsActive calls the node's IsMining function
 func (a *API) MiningIsActive() bool {
 	return a.isMiningFunc()
 }
 
-// MiningOnce mines a single block in the given context, and returns the new block.
+// MiningOnce mines a single block in the given context, and returns the new
+// block. The block is mined by the first of the miners to win an election.
 func (a *API) MiningOnce(ctx context.Context) (*block.Block, error) {
 	if a.isMiningFunc() {
 		return nil, errors.New("Node is already mining")
@@ -76,12 +94,12 @@ func (a *API) MiningOnce(ctx context.Context) (*block.Block, error) {
 		return nil, err
 	}
 
-	miningWorker, err := a.getWorkerFunc(ctx)
+	miningWorkers, err := a.getWorkersFunc(ctx)
 	if err != nil {
 		return nil, err
 	}
 
-	res, err := mining.MineOnce(ctx, *miningWorker, ts, a.chainClock)
+	res, err := mining.MineOnceWithAny(ctx, miningWorkers, ts, a.chainClock)
 	if err != nil {
 		return nil, err
 	}
@@ -4,12 +4,14 @@ import (
 	"context"
 	"testing"
 
+	"github.com/filecoin-project/go-address"
 	bapi "github.com/filecoin-project/go-filecoin/internal/pkg/protocol/mining"
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
 
 	"github.com/filecoin-project/go-filecoin/internal/app/go-filecoin/node"
 	"github.com/filecoin-project/go-filecoin/internal/app/go-filecoin/node/test"
+	"github.com/filecoin-project/go-filecoin/internal/pkg/mining"
 	tf "github.com/filecoin-project/go-filecoin/internal/pkg/testhelpers/testflags"
 )
 
@@ -20,6 +22,21 @@ func TestTrivialNew(t *testing.T) {
 	require.NotNil(t, api)
 }
 
+func TestMiningAPI_MinerResults(t *testing.T) {
+	tf.UnitTest(t)
+
+	api, nd := newAPI(t)
+	minerAddr, err := nd.MiningAddress()
+	require.NoError(t, err)
+
+	addrs, err := api.MinerAddresses()
+	require.NoError(t, err)
+	assert.Equal(t, []address.Address{minerAddr}, addrs)
+
+	// No election has been run yet.
+	assert.Equal(t, []mining.MinerResult{{Miner: minerAddr}}, api.MinerResults())
+}
+
 func TestAPI_MineOnce(t *testing.T) {
 	tf.UnitTest(t)
 	t.Skip("Dragons: fake proofs")
@@ -144,13 +161,15 @@ func newAPI(t *testing.T) (bapi.API, *node.Node) {
 	seed.GiveMiner(t, nd, 0) // TODO: go-fil-markets integration
 	return bapi.New(
 		nd.MiningAddress,
+		nd.MiningAddresses,
 		nd.AddNewBlock,
 		nd.Chain().ChainReader,
 		nd.IsMining,
 		nd.SetupMining,
 		nd.StartMining,
 		nd.StopMining,
-		nd.CreateMiningWorker,
+		nd.CreateMiningWorkers,
+		nd.MiningResults,
 		nd.ChainClock,
 	), nd
 }
@@ -32,6 +32,7 @@ const (
 	walletDatastorePrefix  = "wallet"
 	chainDatastorePrefix   = "chain"
 	dealsDatastorePrefix   = "deals"
+	pieceDatastorePrefix   = "piece"
 	snapshotStorePrefix    = "snapshots"
 	snapshotFilenamePrefix = "snapshot"
 )
@@ -53,6 +54,7 @@ type FSRepo struct {
 	walletDs Datastore
 	chainDs  Datastore
 	dealsDs  Datastore
+	pieceDs  Datastore
 
 	// lockfile is the file system lock to prevent others from opening the same repo.
 	lockfile io.Closer
@@ -228,6 +230,10 @@ func (r *FSRepo) loadFromDisk() error {
 	if err := r.openDealsDatastore(); err != nil {
 		return errors.Wrap(err, "failed to open deals datastore")
 	}
+
+	if err := r.openPieceDatastore(); err != nil {
+		return errors.Wrap(err, "failed to open piece datastore")
+	}
 	return nil
 }
 
@@ -294,6 +300,30 @@ func (r *FSRepo) DealsDatastore() Datastore {
 	return r.dealsDs
 }
 
+// PieceDatastore returns the piece datastore.
+func (r *FSRepo) PieceDatastore() Datastore {
+	return r.pieceDs
+}
+
+// ChainStoreSize returns the size on disk of the datastores holding chain
+// blocks and chain metadata.
+func (r *FSRepo) ChainStoreSize() (uint64, error) {
+	blocksSize, err := dirSize(filepath.Join(r.path, r.Config().Datastore.Path))
+	if err != nil {
+		return 0, err
+	}
+	metadataSize, err := dirSize(filepath.Join(r.path, chainDatastorePrefix))
+	if err != nil {
+		return 0, err
+	}
+	return blocksSize + metadataSize, nil
+}
+
+// PieceStoreSize returns the size on disk of the piece datastore.
+func (r *FSRepo) PieceStoreSize() (uint64, error) {
+	return dirSize(filepath.Join(r.path, pieceDatastorePrefix))
+}
+
 // Version returns the version of the repo
 func (r *FSRepo) Version() uint {
 	return r.version
@@ -322,6 +352,10 @@ func (r *FSRepo) Close() error {
 		return errors.Wrap(err, "failed to close miner deals datastore")
 	}
 
+	if err := r.pieceDs.Close(); err != nil {
+		return errors.Wrap(err, "failed to close piece datastore")
+	}
+
 	if err := r.removeAPIFile(); err != nil {
 		return errors.Wrap(err, "error removing API file")
 	}
@@ -445,6 +479,17 @@ func (r *FSRepo) openDealsDatastore() error {
 	return nil
 }
 
+func (r *FSRepo) openPieceDatastore() error {
+	ds, err := badgerds.NewDatastore(filepath.Join(r.path, pieceDatastorePrefix), badgerOptions())
+	if err != nil {
+		return err
+	}
+
+	r.pieceDs = ds
+
+	return nil
+}
+
 // WriteVersion writes the given version to the repo version file.
 func WriteVersion(p string, version uint) error {
 	return ioutil.WriteFile(filepath.Join(p, versionFilename), []byte(strconv.Itoa(int(version))), 0644)
@@ -507,6 +552,24 @@ func ensureWritableDirectory(path string) error {
 	return nil
 }
 
+// dirSize returns the total size of the files under path.
+func dirSize(path string) (uint64, error) {
+	var size uint64
+	err := filepath.Walk(path, func(_ string, info os.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+		if !info.IsDir() {
+			size += uint64(info.Size())
+		}
+		return nil
+	})
+	if err != nil {
+		return 0, errors.Wrapf(err, "failed to size %s", path)
+	}
+	return size, nil
+}
+
 // Tests whether the directory at path is empty
 func isEmptyDir(path string) (bool, error) {
 	infos, err := ioutil.ReadDir(path)
@@ -40,24 +40,42 @@ const (
 		"minPeerThreshold": 0,
 		"period": "1m"
 	},
+	"chain": {
+		"badTipSetCacheSize": 1024
+	},
 	"datastore": {
 		"type": "badgerds",
 		"path": "badger"
 	},
+	"gc": {
+		"enabled": false,
+		"period": "1h",
+		"retainedEpochs": 2880
+	},
 	"heartbeat": {
 		"beatTarget": "",
 		"beatPeriod": "3s",
 		"reconnectPeriod": "10s",
 		"nickname": ""
 	},
+	"message": {
+		"gasLimitMarginPercent": 25,
+		"gasPricePercentile": 50,
+		"gasPriceLookback": 10,
+		"minGasPrice": "1"
+	},
 	"mining": {
 		"minerAddress": "\u003cempty\u003e",
+		"minerAddresses": [],
 		"autoSealIntervalSeconds": 120,
-		"storagePrice": "0"
+		"storagePrice": "0",
+		"messageSelection": "greedy"
 	},
 	"mpool": {
 		"maxPoolSize": 10000,
-		"maxNonceGap": 100
+		"maxNonceGap": 100,
+		"maxSenderMessages": 100,
+		"replaceByFeeBumpPercent": 25
 	},
 	"observability": {
 		"metrics": {
@@ -74,6 +92,9 @@ const (
 	"sectorbase": {
 		"rootdir": ""
 	},
+	"slashing": {
+		"reporterAddress": "\u003cempty\u003e"
+	},
 	"swarm": {
 		"address": "/ip4/0.0.0.0/tcp/6000"
 	},
@@ -212,6 +233,7 @@ func TestFSRepoRoundtrip(t *testing.T) {
 
 	assert.Equal(t, cfg, r.Config())
 	assert.NoError(t, r.Datastore().Put(ds.NewKey("beep"), []byte("boop")))
+	assert.NoError(t, r.PieceDatastore().Put(ds.NewKey("piece"), []byte("data")))
 	assert.NoError(t, r.Close())
 
 	r2, err := OpenFSRepo(repoPath, 42)
@@ -221,6 +243,17 @@ func TestFSRepoRoundtrip(t *testing.T) {
 	assert.NoError(t, err)
 	assert.Equal(t, []byte("boop"), val)
 
+	val, err = r2.PieceDatastore().Get(ds.NewKey("piece"))
+	assert.NoError(t, err)
+	assert.Equal(t, []byte("data"), val)
+	has, err := r2.Datastore().Has(ds.NewKey("piece"))
+	assert.NoError(t, err)
+	assert.False(t, has)
+
+	pieceSize, err := r2.PieceStoreSize()
+	assert.NoError(t, err)
+	assert.True(t, pieceSize > 0)
+
 	assert.NoError(t, r2.Close())
 }
 
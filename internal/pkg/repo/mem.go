@@ -21,6 +21,7 @@ type MemRepo struct {
 	W          Datastore
 	Chain      Datastore
 	DealsDs    Datastore
+	PieceDs    Datastore
 	version    uint
 	apiAddress string
 }
@@ -36,6 +37,7 @@ func NewInMemoryRepo() *MemRepo {
 		W:       dss.MutexWrap(datastore.NewMapDatastore()),
 		Chain:   dss.MutexWrap(datastore.NewMapDatastore()),
 		DealsDs: dss.MutexWrap(datastore.NewMapDatastore()),
+		PieceDs: dss.MutexWrap(datastore.NewMapDatastore()),
 		version: Version,
 	}
 }
@@ -83,6 +85,11 @@ func (mr *MemRepo) DealsDatastore() Datastore {
 	return mr.DealsDs
 }
 
+// PieceDatastore returns the piece datastore.
+func (mr *MemRepo) PieceDatastore() Datastore {
+	return mr.PieceDs
+}
+
 // Version returns the version of the repo.
 func (mr *MemRepo) Version() uint {
 	return mr.version
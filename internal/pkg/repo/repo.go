@@ -9,7 +9,7 @@ import (
 )
 
 // Version is the version of repo schema that this code understands.
-const Version uint = 2
+const Version uint = 5
 
 // Datastore is the datastore interface provided by the repo
 type Datastore interface {
@@ -38,6 +38,9 @@ type Repo interface {
 	// DealsDatastore holds deals data.
 	DealsDatastore() Datastore
 
+	// PieceDatastore holds the blocks of client and piece DAGs, apart from chain data.
+	PieceDatastore() Datastore
+
 	// SetAPIAddr sets the address of the running API.
 	SetAPIAddr(string) error
 
@@ -3,31 +3,85 @@ package slashing
 import (
 	"github.com/filecoin-project/go-address"
 	"github.com/filecoin-project/specs-actors/actors/abi"
+	"github.com/filecoin-project/specs-actors/actors/builtin/miner"
 
 	"github.com/filecoin-project/go-filecoin/internal/pkg/block"
 )
 
+// FaultType is the kind of a consensus fault.
+type FaultType uint64
+
+const (
+	// DoubleForkMining is two blocks mined by a miner at the same epoch.
+	DoubleForkMining FaultType = iota + 1
+	// TimeOffsetMining is two blocks mined by a miner on the same parents at
+	// different epochs.
+	TimeOffsetMining
+	// ParentGrinding is a block mined by a miner on a tipset that omits the
+	// miner's own block of the same epoch and parents as one of the tipset's
+	// blocks.
+	ParentGrinding
+)
+
+func (t FaultType) String() string {
+	switch t {
+	case DoubleForkMining:
+		return "double-fork mining"
+	case TimeOffsetMining:
+		return "time-offset mining"
+	case ParentGrinding:
+		return "parent grinding"
+	default:
+		return "unknown"
+	}
+}
+
 // ConsensusFaultDetector detects consensus faults -- misbehavior conditions where a single
 // party produces multiple blocks at the same time.
+//
+// Only blocks within miner.ChainFinalityish epochs of the highest block
+// checked are tracked: older blocks are pruned from the indexes and blocks
+// arriving that late are not checked.
 type ConsensusFaultDetector struct {
 	// minerIndex tracks witnessed blocks by miner address and epoch
 	minerIndex map[address.Address]map[abi.ChainEpoch]*block.Block
+	// parentIndex tracks witnessed blocks and their parents by miner address
+	// and block epoch
+	parentIndex map[address.Address]map[abi.ChainEpoch][]witnessedBlock
+	// childIndex tracks the same blocks as parentIndex by miner address and
+	// parent epoch
+	childIndex map[address.Address]map[abi.ChainEpoch][]witnessedBlock
+	// highest is the highest epoch of the blocks checked
+	highest abi.ChainEpoch
 	// sender sends messages on behalf of the slasher
 	faultCh chan ConsensusFault
 }
 
+type witnessedBlock struct {
+	blk    *block.Block
+	parent block.TipSet
+}
+
 // ConsensusFault is the information needed to submit a consensus fault
 type ConsensusFault struct {
 	// Block1 and Block2 are two distinct blocks from an overlapping interval
-	// signed by the same miner
+	// signed by the same miner. For parent grinding Block1 is the omitted
+	// block and Block2 the block omitting it.
 	Block1, Block2 *block.Block
+	// Extra is the witness of a parent grinding fault: the block of Block2's
+	// parents that shares parents and epoch with Block1.
+	Extra *block.Block
+	// Type is the kind of the fault.
+	Type FaultType
 }
 
 // NewConsensusFaultDetector returns a fault detector given a fault channel
 func NewConsensusFaultDetector(faultCh chan ConsensusFault) *ConsensusFaultDetector {
 	return &ConsensusFaultDetector{
-		minerIndex: make(map[address.Address]map[abi.ChainEpoch]*block.Block),
-		faultCh:    faultCh,
+		minerIndex:  make(map[address.Address]map[abi.ChainEpoch]*block.Block),
+		parentIndex: make(map[address.Address]map[abi.ChainEpoch][]witnessedBlock),
+		childIndex:  make(map[address.Address]map[abi.ChainEpoch][]witnessedBlock),
+		faultCh:     faultCh,
 	}
 
 }
@@ -42,6 +96,17 @@ func (detector *ConsensusFaultDetector) CheckBlock(b *block.Block, p block.TipSe
 	}
 	earliest := parentHeight + 1
 
+	if latest > detector.highest {
+		detector.highest = latest
+		detector.prune(latest - miner.ChainFinalityish)
+	}
+	if latest < detector.highest-miner.ChainFinalityish {
+		return nil
+	}
+	if earliest < detector.highest-miner.ChainFinalityish {
+		earliest = detector.highest - miner.ChainFinalityish
+	}
+
 	// Find per-miner index
 	blockByEpoch, tracked := detector.minerIndex[b.Miner]
 	if !tracked {
@@ -58,10 +123,98 @@ func (detector *ConsensusFaultDetector) CheckBlock(b *block.Block, p block.TipSe
 				continue
 			}
 			// Emit all faults, any special handling of duplicates belongs downstream
-			detector.faultCh <- ConsensusFault{b, collision}
+			if collision.Height == b.Height {
+				detector.faultCh <- ConsensusFault{Block1: b, Block2: collision, Type: DoubleForkMining}
+			} else if collision.Parents.Equals(b.Parents) {
+				detector.faultCh <- ConsensusFault{Block1: b, Block2: collision, Type: TimeOffsetMining}
+			}
 		}
 		// In case of collision overwrite with most recent
 		blockByEpoch[e] = b
 	}
+
+	detector.checkParentGrinding(b, p, parentHeight)
+	return nil
+}
+
+// prune forgets the blocks of epochs before `floor`.
+func (detector *ConsensusFaultDetector) prune(floor abi.ChainEpoch) {
+	for minerAddr, blockByEpoch := range detector.minerIndex {
+		for e := range blockByEpoch {
+			if e < floor {
+				delete(blockByEpoch, e)
+			}
+		}
+		if len(blockByEpoch) == 0 {
+			delete(detector.minerIndex, minerAddr)
+		}
+	}
+	pruneWitnessed(detector.parentIndex, floor)
+	pruneWitnessed(detector.childIndex, floor)
+}
+
+func pruneWitnessed(index map[address.Address]map[abi.ChainEpoch][]witnessedBlock, floor abi.ChainEpoch) {
+	for minerAddr, byEpoch := range index {
+		for e := range byEpoch {
+			if e < floor {
+				delete(byEpoch, e)
+			}
+		}
+		if len(byEpoch) == 0 {
+			delete(index, minerAddr)
+		}
+	}
+}
+
+// checkParentGrinding checks `b` against the miner's other blocks both as
+// the block omitted from the parents of another, and as the block omitting
+// another from its parents. Only blocks of the epoch of `b`'s parents can be
+// omitted from them, and `b` can only be omitted from parents of its epoch.
+func (detector *ConsensusFaultDetector) checkParentGrinding(b *block.Block, p block.TipSet, parentHeight abi.ChainEpoch) {
+	byEpoch, tracked := detector.parentIndex[b.Miner]
+	if !tracked {
+		byEpoch = make(map[abi.ChainEpoch][]witnessedBlock)
+		detector.parentIndex[b.Miner] = byEpoch
+	}
+	byParentEpoch, tracked := detector.childIndex[b.Miner]
+	if !tracked {
+		byParentEpoch = make(map[abi.ChainEpoch][]witnessedBlock)
+		detector.childIndex[b.Miner] = byParentEpoch
+	}
+
+	for _, w := range byEpoch[b.Height] {
+		if w.blk.Cid().Equals(b.Cid()) {
+			return
+		}
+	}
+
+	for _, w := range byEpoch[parentHeight] {
+		if witness := grindingWitness(w.blk, p); witness != nil {
+			detector.faultCh <- ConsensusFault{Block1: w.blk, Block2: b, Extra: witness, Type: ParentGrinding}
+		}
+	}
+	for _, w := range byParentEpoch[b.Height] {
+		if witness := grindingWitness(b, w.parent); witness != nil {
+			detector.faultCh <- ConsensusFault{Block1: b, Block2: w.blk, Extra: witness, Type: ParentGrinding}
+		}
+	}
+
+	witnessed := witnessedBlock{blk: b, parent: p}
+	byEpoch[b.Height] = append(byEpoch[b.Height], witnessed)
+	byParentEpoch[parentHeight] = append(byParentEpoch[parentHeight], witnessed)
+}
+
+// grindingWitness returns the block of `parent` that shares epoch and parents
+// with `omitted`, if `parent` does not include `omitted`.
+func grindingWitness(omitted *block.Block, parent block.TipSet) *block.Block {
+	if parent.Key().Has(omitted.Cid()) {
+		return nil
+	}
+	for i := 0; i < parent.Len(); i++ {
+		sibling := parent.At(i)
+		if sibling.Height == omitted.Height && sibling.Parents.Equals(omitted.Parents) {
+			return sibling
+		}
+	}
 	return nil
 }
@@ -3,6 +3,7 @@ package slashing_test
 import (
 	"testing"
 
+	"github.com/filecoin-project/specs-actors/actors/builtin/miner"
 	"github.com/stretchr/testify/assert"
 
 	"github.com/filecoin-project/go-filecoin/internal/pkg/block"
@@ -136,3 +137,119 @@ func TestFaultNullBlocks(t *testing.T) {
 	})
 
 }
+
+func TestFaultTypes(t *testing.T) {
+	addrGetter := vmaddr.NewForTestGetter()
+	minerAddr1 := addrGetter()
+	minerAddr2 := addrGetter()
+
+	grandparent := th.RequireNewTipSet(t, &block.Block{Height: 41})
+	parentBlock := &block.Block{Height: 42, Parents: grandparent.Key()}
+	parentTipSet := th.RequireNewTipSet(t, parentBlock)
+
+	t.Run("same epoch is double-fork mining", func(t *testing.T) {
+		block1 := &block.Block{Miner: minerAddr1, Height: 43, Parents: parentTipSet.Key(), StateRoot: e.NewCid(types.CidFromString(t, "some-state"))}
+		block2 := &block.Block{Miner: minerAddr1, Height: 43, Parents: parentTipSet.Key(), StateRoot: e.NewCid(types.CidFromString(t, "some-other-state"))}
+
+		faultCh := make(chan ConsensusFault, 1)
+		cfd := NewConsensusFaultDetector(faultCh)
+		assert.NoError(t, cfd.CheckBlock(block1, parentTipSet))
+		assert.NoError(t, cfd.CheckBlock(block2, parentTipSet))
+		fault := <-faultCh
+		assert.Equal(t, DoubleForkMining, fault.Type)
+		assertEmptyCh(t, faultCh)
+	})
+
+	t.Run("same parents at different epochs is time-offset mining", func(t *testing.T) {
+		block1 := &block.Block{Miner: minerAddr1, Height: 43, Parents: parentTipSet.Key()}
+		block2 := &block.Block{Miner: minerAddr1, Height: 44, Parents: parentTipSet.Key()}
+
+		faultCh := make(chan ConsensusFault, 2)
+		cfd := NewConsensusFaultDetector(faultCh)
+		assert.NoError(t, cfd.CheckBlock(block1, parentTipSet))
+		assert.NoError(t, cfd.CheckBlock(block2, parentTipSet))
+		fault := <-faultCh
+		assert.Equal(t, TimeOffsetMining, fault.Type)
+		assert.Equal(t, block2, fault.Block1)
+		assert.Equal(t, block1, fault.Block2)
+		assertEmptyCh(t, faultCh)
+	})
+
+	// The miner mines `omitted` at 43 alongside `sibling` from another miner,
+	// then mines `grinding` at 44 on a tipset of `sibling` alone.
+	omitted := &block.Block{Miner: minerAddr1, Height: 43, Parents: parentTipSet.Key()}
+	sibling := &block.Block{Miner: minerAddr2, Height: 43, Parents: parentTipSet.Key()}
+	siblingTipSet := th.RequireNewTipSet(t, sibling)
+	grinding := &block.Block{Miner: minerAddr1, Height: 44, Parents: siblingTipSet.Key()}
+
+	t.Run("omitting an own block from the parents is parent grinding", func(t *testing.T) {
+		faultCh := make(chan ConsensusFault, 1)
+		cfd := NewConsensusFaultDetector(faultCh)
+		assert.NoError(t, cfd.CheckBlock(omitted, parentTipSet))
+		assert.NoError(t, cfd.CheckBlock(sibling, parentTipSet))
+		assert.NoError(t, cfd.CheckBlock(grinding, siblingTipSet))
+		fault := <-faultCh
+		assert.Equal(t, ParentGrinding, fault.Type)
+		assert.Equal(t, omitted, fault.Block1)
+		assert.Equal(t, grinding, fault.Block2)
+		assert.Equal(t, sibling, fault.Extra)
+		assertEmptyCh(t, faultCh)
+	})
+
+	t.Run("parent grinding is detected when the omitted block arrives last", func(t *testing.T) {
+		faultCh := make(chan ConsensusFault, 1)
+		cfd := NewConsensusFaultDetector(faultCh)
+		assert.NoError(t, cfd.CheckBlock(grinding, siblingTipSet))
+		assert.NoError(t, cfd.CheckBlock(omitted, parentTipSet))
+		fault := <-faultCh
+		assert.Equal(t, ParentGrinding, fault.Type)
+		assert.Equal(t, omitted, fault.Block1)
+		assert.Equal(t, grinding, fault.Block2)
+		assert.Equal(t, sibling, fault.Extra)
+		assertEmptyCh(t, faultCh)
+	})
+
+	t.Run("including an own block in the parents is not parent grinding", func(t *testing.T) {
+		bothTipSet := th.RequireNewTipSet(t, omitted, sibling)
+		honest := &block.Block{Miner: minerAddr1, Height: 44, Parents: bothTipSet.Key()}
+
+		faultCh := make(chan ConsensusFault, 1)
+		cfd := NewConsensusFaultDetector(faultCh)
+		assert.NoError(t, cfd.CheckBlock(omitted, parentTipSet))
+		assert.NoError(t, cfd.CheckBlock(honest, bothTipSet))
+		assertEmptyCh(t, faultCh)
+	})
+}
+
+func TestFaultsBeyondFinality(t *testing.T) {
+	addrGetter := vmaddr.NewForTestGetter()
+	minerAddr1 := addrGetter()
+	minerAddr2 := addrGetter()
+
+	parentTipSet := th.RequireNewTipSet(t, &block.Block{Height: 42})
+	block1 := &block.Block{Miner: minerAddr1, Height: 43, StateRoot: e.NewCid(types.CidFromString(t, "some-state"))}
+	block2 := &block.Block{Miner: minerAddr1, Height: 43, StateRoot: e.NewCid(types.CidFromString(t, "some-other-state"))}
+
+	laterHeight := 43 + miner.ChainFinalityish + 1
+	laterParent := th.RequireNewTipSet(t, &block.Block{Height: laterHeight - 1})
+	later := &block.Block{Miner: minerAddr2, Height: laterHeight}
+
+	t.Run("blocks older than finality are forgotten", func(t *testing.T) {
+		faultCh := make(chan ConsensusFault, 1)
+		cfd := NewConsensusFaultDetector(faultCh)
+		assert.NoError(t, cfd.CheckBlock(block1, parentTipSet))
+		assert.NoError(t, cfd.CheckBlock(later, laterParent))
+		assert.NoError(t, cfd.CheckBlock(block2, parentTipSet))
+		assertEmptyCh(t, faultCh)
+	})
+
+	t.Run("blocks within finality are kept", func(t *testing.T) {
+		faultCh := make(chan ConsensusFault, 1)
+		cfd := NewConsensusFaultDetector(faultCh)
+		assert.NoError(t, cfd.CheckBlock(block1, parentTipSet))
+		assert.NoError(t, cfd.CheckBlock(&block.Block{Miner: minerAddr2, Height: laterHeight - 1}, parentTipSet))
+		assert.NoError(t, cfd.CheckBlock(block2, parentTipSet))
+		fault := <-faultCh
+		assert.Equal(t, DoubleForkMining, fault.Type)
+	})
+}
@@ -0,0 +1,405 @@
+package slashing
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"github.com/filecoin-project/go-address"
+	"github.com/filecoin-project/specs-actors/actors/abi"
+	"github.com/filecoin-project/specs-actors/actors/builtin"
+	"github.com/filecoin-project/specs-actors/actors/builtin/miner"
+	"github.com/ipfs/go-cid"
+	"github.com/ipfs/go-datastore"
+	"github.com/ipfs/go-datastore/namespace"
+	dsq "github.com/ipfs/go-datastore/query"
+	logging "github.com/ipfs/go-log"
+	"github.com/pkg/errors"
+
+	"github.com/filecoin-project/go-filecoin/internal/app/go-filecoin/plumbing/msg"
+	"github.com/filecoin-project/go-filecoin/internal/pkg/block"
+	"github.com/filecoin-project/go-filecoin/internal/pkg/constants"
+	e "github.com/filecoin-project/go-filecoin/internal/pkg/enccid"
+	"github.com/filecoin-project/go-filecoin/internal/pkg/encoding"
+	"github.com/filecoin-project/go-filecoin/internal/pkg/message"
+	"github.com/filecoin-project/go-filecoin/internal/pkg/types"
+)
+
+var log = logging.Logger("slashing")
+
+// reportsPrefix is the datastore namespace of the consensus fault reports.
+var reportsPrefix = datastore.NewKey("/slashing/reports")
+
+// RetryInterval is how often the reports not yet on chain are checked, and
+// sent again if their message left the outbox.
+const RetryInterval = time.Minute
+
+type reportSender interface {
+	Send(ctx context.Context, from, to address.Address, value types.AttoFIL,
+		gasPrice types.AttoFIL, gasLimit types.GasUnits, bcast bool, method abi.MethodNum, params interface{}) (cid.Cid, chan error, error)
+	Queue() *message.Queue
+}
+
+type reportEstimator interface {
+	Estimate(ctx context.Context, from, to address.Address, value types.AttoFIL, method abi.MethodNum, params ...interface{}) (*msg.GasEstimate, error)
+}
+
+type reportChain interface {
+	GetHead() block.TipSetKey
+	GetTipSet(block.TipSetKey) (block.TipSet, error)
+}
+
+type reportFinder interface {
+	Lookup(ctx context.Context, msgCid cid.Cid) (*msg.ChainMessage, bool, error)
+}
+
+// Report is a consensus fault reported, or to be reported, to the miner
+// actor of the offending miner.
+type Report struct {
+	// control field for encoding struct as an array
+	_ struct{} `cbor:",toarray"`
+
+	Miner   address.Address
+	Type    FaultType
+	Header1 []byte
+	Header2 []byte
+	Extra   []byte
+	// Message is the last report message sent, undefined until one is sent.
+	Message e.Cid
+	// Height is the height of the block including the report message, zero
+	// while the message is not on chain.
+	Height abi.ChainEpoch
+}
+
+// Pending returns true if the report message is not on chain yet.
+func (r *Report) Pending() bool {
+	return r.Height == 0
+}
+
+// Slasher turns consensus faults into ReportConsensusFault messages to the
+// offending miner actors, sent from a reporter address. Each fault, that is
+// each pair of offending blocks, is reported once. The gas of a report is
+// estimated by running it against the head state. Reports are persisted and
+// stay pending until their message is on chain: reports that could not be
+// sent, or whose message left the outbox without being included, are sent
+// again, also after a restart. Reports are deleted once their message is
+// final.
+type Slasher struct {
+	reporter  address.Address
+	outbox    reportSender
+	estimator reportEstimator
+	chain     reportChain
+	finder    reportFinder
+	reports   datastore.Datastore
+
+	// Serializes report updates. It is not held while sending: reports
+	// being sent are tracked in sending instead.
+	mu      sync.Mutex
+	sending map[datastore.Key]struct{}
+}
+
+// NewSlasher returns a slasher sending reports from `reporter` through
+// `outbox`, with the gas suggested by `estimator`, looking their messages up
+// in the chain of `chain` with `finder`, and persisting them in `ds`.
+func NewSlasher(reporter address.Address, outbox reportSender, estimator reportEstimator, chain reportChain, finder reportFinder, ds datastore.Batching) *Slasher {
+	return &Slasher{
+		reporter:  reporter,
+		outbox:    outbox,
+		estimator: estimator,
+		chain:     chain,
+		finder:    finder,
+		reports:   namespace.Wrap(ds, reportsPrefix),
+		sending:   make(map[datastore.Key]struct{}),
+	}
+}
+
+// Run reports the faults received on `faultCh` until the context is done.
+// Pending reports are retried on start and every RetryInterval.
+func (s *Slasher) Run(ctx context.Context, faultCh <-chan ConsensusFault) {
+	if err := s.RetryPending(ctx); err != nil {
+		log.Warnf("failed to retry pending consensus fault reports: %s", err)
+	}
+
+	ticker := time.NewTicker(RetryInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case fault := <-faultCh:
+			if err := s.Report(ctx, fault); err != nil {
+				log.Warnf("failed to report %s consensus fault of %s: %s", fault.Type, fault.Block1.Miner, err)
+			}
+		case <-ticker.C:
+			if err := s.RetryPending(ctx); err != nil {
+				log.Warnf("failed to retry pending consensus fault reports: %s", err)
+			}
+		}
+	}
+}
+
+// Report records a report of `fault` and sends it, unless the fault has been
+// reported already. A report that fails to send is kept pending.
+func (s *Slasher) Report(ctx context.Context, fault ConsensusFault) error {
+	report, err := newReport(fault)
+	if err != nil {
+		return err
+	}
+	key, err := report.key()
+	if err != nil {
+		return err
+	}
+
+	s.mu.Lock()
+	reported, err := s.reports.Has(key)
+	if err != nil || reported {
+		s.mu.Unlock()
+		return err
+	}
+	log.Infof("detected %s consensus fault of %s", fault.Type, report.Miner)
+	if err := s.put(key, report); err != nil {
+		s.mu.Unlock()
+		return err
+	}
+	s.sending[key] = struct{}{}
+	s.mu.Unlock()
+
+	defer s.release(key)
+	return s.send(ctx, key, report)
+}
+
+// RetryPending checks the pending reports not being sent already. Reports
+// whose message is on chain are recorded as such, and those never sent or
+// whose message left the outbox without being included are sent again.
+// Reports whose message was included more than ChainFinalityish epochs
+// before the head are deleted.
+func (s *Slasher) RetryPending(ctx context.Context) error {
+	head, err := s.chain.GetTipSet(s.chain.GetHead())
+	if err != nil {
+		return errors.Wrap(err, "failed to load head")
+	}
+	headHeight, err := head.Height()
+	if err != nil {
+		return err
+	}
+
+	s.mu.Lock()
+	reports, err := s.list()
+	if err != nil {
+		s.mu.Unlock()
+		return err
+	}
+	pending := make(map[datastore.Key]*Report)
+	for key, r := range reports {
+		if _, ok := s.sending[key]; ok {
+			continue
+		}
+		if !r.Pending() {
+			if r.Height+miner.ChainFinalityish < headHeight {
+				if err := s.reports.Delete(key); err != nil {
+					s.mu.Unlock()
+					return err
+				}
+			}
+			continue
+		}
+		s.sending[key] = struct{}{}
+		pending[key] = r
+	}
+	s.mu.Unlock()
+
+	var firstErr error
+	for key, r := range pending {
+		if err := s.retry(ctx, key, r); err != nil && firstErr == nil {
+			firstErr = err
+		}
+	}
+	return firstErr
+}
+
+// Reports returns all recorded reports.
+func (s *Slasher) Reports() ([]*Report, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	reports, err := s.list()
+	if err != nil {
+		return nil, err
+	}
+	var out []*Report
+	for _, r := range reports {
+		out = append(out, r)
+	}
+	return out, nil
+}
+
+// retry checks a pending report claimed in s.sending, and releases it.
+func (s *Slasher) retry(ctx context.Context, key datastore.Key, r *Report) error {
+	defer s.release(key)
+
+	if r.Message.Defined() {
+		queued, err := s.queued(r.Message.Cid)
+		if err != nil || queued {
+			return err
+		}
+		chainMsg, found, err := s.finder.Lookup(ctx, r.Message.Cid)
+		if err != nil {
+			return errors.Wrapf(err, "failed to look up consensus fault report of %s", r.Miner)
+		}
+		if found {
+			r.Height = chainMsg.Block.Height
+			log.Infof("consensus fault report of %s in message %s is on chain at height %d", r.Miner, r.Message.Cid, r.Height)
+			s.mu.Lock()
+			defer s.mu.Unlock()
+			return s.put(key, r)
+		}
+		log.Infof("consensus fault report of %s in message %s left the outbox without being included, sending it again", r.Miner, r.Message.Cid)
+	}
+	return s.send(ctx, key, r)
+}
+
+// queued returns true if message `msgCid` is still in the outbox.
+func (s *Slasher) queued(msgCid cid.Cid) (bool, error) {
+	for _, qm := range s.outbox.Queue().List(s.reporter) {
+		c, err := qm.Msg.Cid()
+		if err != nil {
+			return false, err
+		}
+		if c.Equals(msgCid) {
+			return true, nil
+		}
+	}
+	return false, nil
+}
+
+// send sends a report claimed in s.sending.
+func (s *Slasher) send(ctx context.Context, key datastore.Key, r *Report) error {
+	params := &miner.ReportConsensusFaultParams{
+		BlockHeader1:     r.Header1,
+		BlockHeader2:     r.Header2,
+		BlockHeaderExtra: r.Extra,
+	}
+	gas, err := s.estimator.Estimate(ctx, s.reporter, r.Miner, types.ZeroAttoFIL, builtin.MethodsMiner.ReportConsensusFault, params)
+	if err != nil {
+		return errors.Wrapf(err, "failed to estimate gas of consensus fault report of %s", r.Miner)
+	}
+	msgCid, pubErrCh, err := s.outbox.Send(
+		ctx,
+		s.reporter,
+		r.Miner,
+		types.ZeroAttoFIL,
+		gas.GasPrice,
+		gas.GasLimit,
+		true,
+		builtin.MethodsMiner.ReportConsensusFault,
+		params,
+	)
+	if err != nil {
+		return errors.Wrapf(err, "failed to send consensus fault report of %s", r.Miner)
+	}
+	go logPublishError(r.Miner, msgCid, pubErrCh)
+
+	r.Message = e.NewCid(msgCid)
+	log.Infof("reported consensus fault of %s in message %s", r.Miner, msgCid)
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.put(key, r)
+}
+
+// release releases a report claimed in s.sending.
+func (s *Slasher) release(key datastore.Key) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	delete(s.sending, key)
+}
+
+// logPublishError waits for a report message to be published. A message
+// that fails to publish stays queued in the outbox, which publishes it again,
+// so the report is not sent again.
+func logPublishError(minerAddr address.Address, msgCid cid.Cid, pubErrCh chan error) {
+	if pubErrCh == nil {
+		return
+	}
+	if err := <-pubErrCh; err != nil {
+		log.Warnf("failed to publish consensus fault report of %s in message %s: %s", minerAddr, msgCid, err)
+	}
+}
+
+func (s *Slasher) put(key datastore.Key, r *Report) error {
+	b, err := encoding.Encode(r)
+	if err != nil {
+		return err
+	}
+	return s.reports.Put(key, b)
+}
+
+func (s *Slasher) list() (map[datastore.Key]*Report, error) {
+	res, err := s.reports.Query(dsq.Query{})
+	if err != nil {
+		return nil, err
+	}
+	entries, err := res.Rest()
+	if err != nil {
+		return nil, err
+	}
+
+	reports := make(map[datastore.Key]*Report)
+	for _, entry := range entries {
+		var r Report
+		if err := encoding.Decode(entry.Value, &r); err != nil {
+			return nil, errors.Wrapf(err, "invalid consensus fault report %s", entry.Key)
+		}
+		reports[datastore.NewKey(entry.Key)] = &r
+	}
+	return reports, nil
+}
+
+func newReport(fault ConsensusFault) (*Report, error) {
+	header1, err := encoding.Encode(fault.Block1)
+	if err != nil {
+		return nil, err
+	}
+	header2, err := encoding.Encode(fault.Block2)
+	if err != nil {
+		return nil, err
+	}
+	var extra []byte
+	if fault.Extra != nil {
+		if extra, err = encoding.Encode(fault.Extra); err != nil {
+			return nil, err
+		}
+	}
+	return &Report{
+		Miner:   fault.Block1.Miner,
+		Type:    fault.Type,
+		Header1: header1,
+		Header2: header2,
+		Extra:   extra,
+		Message: e.Undef,
+	}, nil
+}
+
+// key returns the datastore key of the report, from the miner and the pair
+// of offending blocks, in either order.
+func (r *Report) key() (datastore.Key, error) {
+	h1, err := headerKey(r.Header1)
+	if err != nil {
+		return datastore.Key{}, err
+	}
+	h2, err := headerKey(r.Header2)
+	if err != nil {
+		return datastore.Key{}, err
+	}
+	if h2 < h1 {
+		h1, h2 = h2, h1
+	}
+	return datastore.KeyWithNamespaces([]string{r.Miner.String(), h1, h2}), nil
+}
+
+// headerKey returns the cid of an encoded block header, as a string.
+func headerKey(header []byte) (string, error) {
+	c, err := constants.DefaultCidBuilder.Sum(header)
+	if err != nil {
+		return "", errors.Wrap(err, "failed to compute cid of block header")
+	}
+	return c.String(), nil
+}
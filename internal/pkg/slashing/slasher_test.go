@@ -0,0 +1,266 @@
+package slashing_test
+
+import (
+	"context"
+	"testing"
+
+	"github.com/filecoin-project/go-address"
+	"github.com/filecoin-project/specs-actors/actors/abi"
+	"github.com/filecoin-project/specs-actors/actors/builtin"
+	"github.com/filecoin-project/specs-actors/actors/builtin/miner"
+	"github.com/ipfs/go-cid"
+	"github.com/ipfs/go-datastore"
+	"github.com/pkg/errors"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/filecoin-project/go-filecoin/internal/app/go-filecoin/plumbing/msg"
+	"github.com/filecoin-project/go-filecoin/internal/pkg/block"
+	e "github.com/filecoin-project/go-filecoin/internal/pkg/enccid"
+	"github.com/filecoin-project/go-filecoin/internal/pkg/encoding"
+	"github.com/filecoin-project/go-filecoin/internal/pkg/message"
+	. "github.com/filecoin-project/go-filecoin/internal/pkg/slashing"
+	tf "github.com/filecoin-project/go-filecoin/internal/pkg/testhelpers/testflags"
+	"github.com/filecoin-project/go-filecoin/internal/pkg/types"
+	vmaddr "github.com/filecoin-project/go-filecoin/internal/pkg/vm/address"
+)
+
+func TestSlasher(t *testing.T) {
+	tf.UnitTest(t)
+	ctx := context.Background()
+
+	addrGetter := vmaddr.NewForTestGetter()
+	reporter := addrGetter()
+	minerAddr := addrGetter()
+
+	block1 := &block.Block{Miner: minerAddr, Height: 43, StateRoot: e.NewCid(types.CidFromString(t, "some-state"))}
+	block2 := &block.Block{Miner: minerAddr, Height: 43, StateRoot: e.NewCid(types.CidFromString(t, "some-other-state"))}
+	block3 := &block.Block{Miner: minerAddr, Height: 43, StateRoot: e.NewCid(types.CidFromString(t, "yet-another-state"))}
+	fault := ConsensusFault{Block1: block1, Block2: block2, Type: DoubleForkMining}
+	estimator := &fakeEstimator{gasPrice: types.NewGasPrice(3), gasLimit: types.GasUnits(1234)}
+	chain := newFakeChain(t, 60)
+	finder := fakeFinder{}
+
+	t.Run("sends an encoded report to the miner actor", func(t *testing.T) {
+		outbox := newFakeOutbox(nil)
+		slasher := NewSlasher(reporter, outbox, estimator, chain, finder, datastore.NewMapDatastore())
+		require.NoError(t, slasher.Report(ctx, fault))
+
+		require.Len(t, outbox.sent, 1)
+		sent := outbox.sent[0]
+		assert.Equal(t, reporter, sent.from)
+		assert.Equal(t, minerAddr, sent.to)
+		assert.Equal(t, builtin.MethodsMiner.ReportConsensusFault, sent.method)
+		assert.Equal(t, estimator.gasPrice, sent.gasPrice)
+		assert.Equal(t, estimator.gasLimit, sent.gasLimit)
+
+		params := sent.params.(*miner.ReportConsensusFaultParams)
+		header1, err := encoding.Encode(block1)
+		require.NoError(t, err)
+		header2, err := encoding.Encode(block2)
+		require.NoError(t, err)
+		assert.Equal(t, header1, params.BlockHeader1)
+		assert.Equal(t, header2, params.BlockHeader2)
+		assert.Empty(t, params.BlockHeaderExtra)
+	})
+
+	t.Run("reports a fault once", func(t *testing.T) {
+		outbox := newFakeOutbox(nil)
+		slasher := NewSlasher(reporter, outbox, estimator, chain, finder, datastore.NewMapDatastore())
+		require.NoError(t, slasher.Report(ctx, fault))
+		require.NoError(t, slasher.Report(ctx, fault))
+		require.NoError(t, slasher.Report(ctx, ConsensusFault{Block1: block2, Block2: block1, Type: DoubleForkMining}))
+		assert.Len(t, outbox.sent, 1)
+
+		t.Log("another fault of the same miner is reported")
+		require.NoError(t, slasher.Report(ctx, ConsensusFault{Block1: block1, Block2: block3, Type: DoubleForkMining}))
+		assert.Len(t, outbox.sent, 2)
+	})
+
+	t.Run("a report whose gas cannot be estimated is kept pending", func(t *testing.T) {
+		outbox := newFakeOutbox(nil)
+		failing := &fakeEstimator{err: errors.New("message failed with exit code 16")}
+		slasher := NewSlasher(reporter, outbox, failing, chain, finder, datastore.NewMapDatastore())
+		assert.Error(t, slasher.Report(ctx, fault))
+		assert.Empty(t, outbox.sent)
+
+		reports, err := slasher.Reports()
+		require.NoError(t, err)
+		require.Len(t, reports, 1)
+		assert.True(t, reports[0].Pending())
+	})
+
+	t.Run("pending reports are sent after a restart", func(t *testing.T) {
+		ds := datastore.NewMapDatastore()
+		failing := newFakeOutbox(errors.New("no funds"))
+		slasher := NewSlasher(reporter, failing, estimator, chain, finder, ds)
+		assert.Error(t, slasher.Report(ctx, fault))
+
+		reports, err := slasher.Reports()
+		require.NoError(t, err)
+		require.Len(t, reports, 1)
+		assert.True(t, reports[0].Pending())
+
+		outbox := newFakeOutbox(nil)
+		restarted := NewSlasher(reporter, outbox, estimator, chain, finder, ds)
+		require.NoError(t, restarted.RetryPending(ctx))
+		require.Len(t, outbox.sent, 1)
+		assert.Equal(t, minerAddr, outbox.sent[0].to)
+
+		reports, err = restarted.Reports()
+		require.NoError(t, err)
+		require.Len(t, reports, 1)
+		assert.True(t, reports[0].Message.Defined())
+		assert.True(t, reports[0].Pending())
+		assert.Equal(t, DoubleForkMining, reports[0].Type)
+
+		t.Log("reports whose message is in the outbox are not sent again")
+		require.NoError(t, restarted.RetryPending(ctx))
+		require.NoError(t, restarted.Report(ctx, fault))
+		assert.Len(t, outbox.sent, 1)
+	})
+
+	t.Run("a report stays pending until its message is on chain", func(t *testing.T) {
+		outbox := newFakeOutbox(nil)
+		finder := fakeFinder{}
+		slasher := NewSlasher(reporter, outbox, estimator, chain, finder, datastore.NewMapDatastore())
+		require.NoError(t, slasher.Report(ctx, fault))
+
+		t.Log("a report whose message left the outbox without being included is sent again")
+		outbox.queue.Clear(ctx, reporter)
+		require.NoError(t, slasher.RetryPending(ctx))
+		require.Len(t, outbox.sent, 2)
+
+		reports, err := slasher.Reports()
+		require.NoError(t, err)
+		require.Len(t, reports, 1)
+		assert.True(t, reports[0].Pending())
+
+		t.Log("a report whose message is on chain is no longer pending")
+		outbox.queue.Clear(ctx, reporter)
+		finder[reports[0].Message.Cid] = abi.ChainEpoch(50)
+		require.NoError(t, slasher.RetryPending(ctx))
+		assert.Len(t, outbox.sent, 2)
+
+		reports, err = slasher.Reports()
+		require.NoError(t, err)
+		require.Len(t, reports, 1)
+		assert.False(t, reports[0].Pending())
+		assert.Equal(t, abi.ChainEpoch(50), reports[0].Height)
+	})
+
+	t.Run("reports are deleted once their message is final", func(t *testing.T) {
+		outbox := newFakeOutbox(nil)
+		finder := fakeFinder{}
+		ds := datastore.NewMapDatastore()
+		slasher := NewSlasher(reporter, outbox, estimator, chain, finder, ds)
+		require.NoError(t, slasher.Report(ctx, fault))
+		outbox.queue.Clear(ctx, reporter)
+		reports, err := slasher.Reports()
+		require.NoError(t, err)
+		require.Len(t, reports, 1)
+		finder[reports[0].Message.Cid] = abi.ChainEpoch(50)
+		require.NoError(t, slasher.RetryPending(ctx))
+
+		t.Log("a report is kept until finality")
+		final := NewSlasher(reporter, outbox, estimator, newFakeChain(t, 50+miner.ChainFinalityish), finder, ds)
+		require.NoError(t, final.RetryPending(ctx))
+		reports, err = final.Reports()
+		require.NoError(t, err)
+		assert.Len(t, reports, 1)
+
+		final = NewSlasher(reporter, outbox, estimator, newFakeChain(t, 51+miner.ChainFinalityish), finder, ds)
+		require.NoError(t, final.RetryPending(ctx))
+		reports, err = final.Reports()
+		require.NoError(t, err)
+		assert.Empty(t, reports)
+	})
+}
+
+type sentMessage struct {
+	from, to address.Address
+	gasPrice types.AttoFIL
+	gasLimit types.GasUnits
+	method   abi.MethodNum
+	params   interface{}
+}
+
+type fakeOutbox struct {
+	sent  []sentMessage
+	queue *message.Queue
+	err   error
+}
+
+func newFakeOutbox(err error) *fakeOutbox {
+	return &fakeOutbox{queue: message.NewQueue(), err: err}
+}
+
+func (o *fakeOutbox) Send(ctx context.Context, from, to address.Address, value types.AttoFIL,
+	gasPrice types.AttoFIL, gasLimit types.GasUnits, bcast bool, method abi.MethodNum, params interface{}) (cid.Cid, chan error, error) {
+	if o.err != nil {
+		return cid.Undef, nil, o.err
+	}
+	nonce := uint64(len(o.sent))
+	o.sent = append(o.sent, sentMessage{from: from, to: to, gasPrice: gasPrice, gasLimit: gasLimit, method: method, params: params})
+	signed := &types.SignedMessage{Message: *types.NewMeteredMessage(from, to, nonce, value, method, nil, gasPrice, gasLimit)}
+	if err := o.queue.Enqueue(ctx, signed, 0); err != nil {
+		return cid.Undef, nil, err
+	}
+	msgCid, err := signed.Cid()
+	if err != nil {
+		return cid.Undef, nil, err
+	}
+	pubErrCh := make(chan error, 1)
+	pubErrCh <- nil
+	return msgCid, pubErrCh, nil
+}
+
+func (o *fakeOutbox) Queue() *message.Queue {
+	return o.queue
+}
+
+type fakeChain struct {
+	head block.TipSet
+}
+
+func newFakeChain(t *testing.T, height abi.ChainEpoch) *fakeChain {
+	head, err := block.NewTipSet(&block.Block{Height: height})
+	require.NoError(t, err)
+	return &fakeChain{head: head}
+}
+
+func (c *fakeChain) GetHead() block.TipSetKey {
+	return c.head.Key()
+}
+
+func (c *fakeChain) GetTipSet(key block.TipSetKey) (block.TipSet, error) {
+	if !key.Equals(c.head.Key()) {
+		return block.UndefTipSet, errors.Errorf("no tipset %s", key)
+	}
+	return c.head, nil
+}
+
+// fakeFinder maps the cids of the messages on chain to the height of the
+// block including them.
+type fakeFinder map[cid.Cid]abi.ChainEpoch
+
+func (f fakeFinder) Lookup(ctx context.Context, msgCid cid.Cid) (*msg.ChainMessage, bool, error) {
+	height, ok := f[msgCid]
+	if !ok {
+		return nil, false, nil
+	}
+	return &msg.ChainMessage{Block: &block.Block{Height: height}}, true, nil
+}
+
+type fakeEstimator struct {
+	gasPrice types.AttoFIL
+	gasLimit types.GasUnits
+	err      error
+}
+
+func (e *fakeEstimator) Estimate(ctx context.Context, from, to address.Address, value types.AttoFIL, method abi.MethodNum, params ...interface{}) (*msg.GasEstimate, error) {
+	if e.err != nil {
+		return nil, e.err
+	}
+	return &msg.GasEstimate{GasUsed: e.gasLimit, GasLimit: e.gasLimit, GasPrice: e.gasPrice}, nil
+}
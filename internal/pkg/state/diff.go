@@ -0,0 +1,235 @@
+package state
+
+import (
+	"bytes"
+	"context"
+	"encoding/hex"
+	"fmt"
+	"reflect"
+	"sort"
+	"strconv"
+
+	addr "github.com/filecoin-project/go-address"
+	amt "github.com/filecoin-project/go-amt-ipld/v2"
+	"github.com/filecoin-project/specs-actors/actors/builtin"
+	"github.com/filecoin-project/specs-actors/actors/builtin/account"
+	notinit "github.com/filecoin-project/specs-actors/actors/builtin/init"
+	"github.com/filecoin-project/specs-actors/actors/builtin/market"
+	"github.com/filecoin-project/specs-actors/actors/builtin/miner"
+	"github.com/filecoin-project/specs-actors/actors/builtin/paych"
+	"github.com/filecoin-project/specs-actors/actors/builtin/power"
+	"github.com/ipfs/go-cid"
+	hamt "github.com/ipfs/go-hamt-ipld"
+	cbor "github.com/ipfs/go-ipld-cbor"
+	mh "github.com/multiformats/go-multihash"
+	"github.com/pkg/errors"
+	typegen "github.com/whyrusleeping/cbor-gen"
+
+	"github.com/filecoin-project/go-filecoin/internal/pkg/vm/actor"
+	vmstate "github.com/filecoin-project/go-filecoin/internal/pkg/vm/state"
+)
+
+// ActorStateDiff is the difference of an actor between two state roots.
+// Before is nil for an added actor, After is nil for a removed one.
+type ActorStateDiff struct {
+	Address addr.Address
+	Before  *actor.Actor
+	After   *actor.Actor
+	// Fields lists the changed fields of the decoded head state, for builtin
+	// actors whose code is the same in both states.
+	Fields []FieldDiff
+}
+
+// FieldDiff is a changed field of an actor's head state. A field linking to a
+// HAMT or AMT collection is diffed entry by entry: Field is then the name of
+// the field followed by the key of the entry in brackets, and Before and After
+// are the JSON of the decoded entry values, nil for an added or removed entry.
+type FieldDiff struct {
+	Field  string
+	Before interface{}
+	After  interface{}
+}
+
+// DiffStates returns the actors that were added, removed or changed from the
+// state with root `rootA` to the state with root `rootB`, ordered by address.
+func DiffStates(ctx context.Context, store cbor.IpldStore, rootA, rootB cid.Cid) ([]*ActorStateDiff, error) {
+	treeA, err := vmstate.LoadState(ctx, store, rootA)
+	if err != nil {
+		return nil, errors.Wrapf(err, "failed to load state %s", rootA)
+	}
+	treeB, err := vmstate.LoadState(ctx, store, rootB)
+	if err != nil {
+		return nil, errors.Wrapf(err, "failed to load state %s", rootB)
+	}
+	actorDiffs, err := vmstate.Diff(ctx, treeA, treeB)
+	if err != nil {
+		return nil, err
+	}
+
+	diffs := make([]*ActorStateDiff, len(actorDiffs))
+	for i, d := range actorDiffs {
+		diffs[i] = &ActorStateDiff{Address: d.Address, Before: d.Before, After: d.After}
+		if d.Added() || d.Removed() || d.Before.Head.Equals(d.After.Head.Cid) || !d.Before.Code.Equals(d.After.Code.Cid) {
+			continue
+		}
+		diffs[i].Fields, err = diffHeads(ctx, store, d.Before.Code.Cid, d.Before.Head.Cid, d.After.Head.Cid)
+		if err != nil {
+			return nil, errors.Wrapf(err, "failed to diff state of actor %s", d.Address)
+		}
+	}
+	return diffs, nil
+}
+
+// diffHeads decodes the head states `before` and `after` of an actor with
+// code `code` and returns their changed fields. It returns no fields for
+// actors that are not builtin.
+func diffHeads(ctx context.Context, store cbor.IpldStore, code, before, after cid.Cid) ([]FieldDiff, error) {
+	beforeState := newHeadState(code)
+	afterState := newHeadState(code)
+	if beforeState == nil {
+		return nil, nil
+	}
+	if err := store.Get(ctx, before, beforeState); err != nil {
+		return nil, err
+	}
+	if err := store.Get(ctx, after, afterState); err != nil {
+		return nil, err
+	}
+
+	beforeValue := reflect.ValueOf(beforeState).Elem()
+	afterValue := reflect.ValueOf(afterState).Elem()
+	var fields []FieldDiff
+	for i := 0; i < beforeValue.NumField(); i++ {
+		field := beforeValue.Type().Field(i)
+		if field.PkgPath != "" {
+			continue // unexported
+		}
+		b, a := beforeValue.Field(i).Interface(), afterValue.Field(i).Interface()
+		if reflect.DeepEqual(b, a) {
+			continue
+		}
+		if bRoot, ok := b.(cid.Cid); ok {
+			entries, isCollection := diffCollections(ctx, store, field.Name, bRoot, a.(cid.Cid))
+			if isCollection {
+				fields = append(fields, entries...)
+				continue
+			}
+		}
+		fields = append(fields, FieldDiff{Field: field.Name, Before: b, After: a})
+	}
+	return fields, nil
+}
+
+// diffCollections returns the entries added, removed or changed from the HAMT
+// or AMT with root `before` to the one with root `after`, or false if either
+// is not a collection that can be loaded from `store`.
+func diffCollections(ctx context.Context, store cbor.IpldStore, field string, before, after cid.Cid) ([]FieldDiff, bool) {
+	beforeEntries, ok := collectionEntries(ctx, store, before)
+	if !ok {
+		return nil, false
+	}
+	afterEntries, ok := collectionEntries(ctx, store, after)
+	if !ok {
+		return nil, false
+	}
+
+	keys := make(map[string]struct{})
+	for k := range beforeEntries {
+		keys[k] = struct{}{}
+	}
+	for k := range afterEntries {
+		keys[k] = struct{}{}
+	}
+	sorted := make([]string, 0, len(keys))
+	for k := range keys {
+		sorted = append(sorted, k)
+	}
+	sort.Strings(sorted)
+
+	var fields []FieldDiff
+	for _, k := range sorted {
+		b, inBefore := beforeEntries[k]
+		a, inAfter := afterEntries[k]
+		if inBefore && inAfter && bytes.Equal(b, a) {
+			continue
+		}
+		d := FieldDiff{Field: fmt.Sprintf("%s[%s]", field, k)}
+		if inBefore {
+			d.Before = entryJSON(b)
+		}
+		if inAfter {
+			d.After = entryJSON(a)
+		}
+		fields = append(fields, d)
+	}
+	return fields, true
+}
+
+// collectionEntries returns the raw values of the AMT or HAMT with root
+// `root` by printable key, or false if `root` is neither.
+func collectionEntries(ctx context.Context, store cbor.IpldStore, root cid.Cid) (map[string][]byte, bool) {
+	entries := make(map[string][]byte)
+	if array, err := amt.LoadAMT(ctx, store, root); err == nil {
+		err := array.ForEach(ctx, func(i uint64, v *typegen.Deferred) error {
+			entries[strconv.FormatUint(i, 10)] = v.Raw
+			return nil
+		})
+		return entries, err == nil
+	}
+
+	node, err := hamt.LoadNode(ctx, store, root)
+	if err != nil {
+		return nil, false
+	}
+	err = node.ForEach(ctx, func(k string, v interface{}) error {
+		deferred, ok := v.(*typegen.Deferred)
+		if !ok {
+			return errors.Errorf("unexpected HAMT value %T", v)
+		}
+		entries[hamtKey(k)] = deferred.Raw
+		return nil
+	})
+	return entries, err == nil
+}
+
+// hamtKey returns a printable HAMT key: the address it encodes, if any, and
+// its hex encoding otherwise.
+func hamtKey(k string) string {
+	if a, err := addr.NewFromBytes([]byte(k)); err == nil {
+		return a.String()
+	}
+	return "0x" + hex.EncodeToString([]byte(k))
+}
+
+// entryJSON returns the JSON of a collection entry value, or its hex encoding
+// if it cannot be decoded.
+func entryJSON(raw []byte) string {
+	nd, err := cbor.Decode(raw, mh.SHA2_256, -1)
+	if err == nil {
+		if b, err := nd.MarshalJSON(); err == nil {
+			return string(b)
+		}
+	}
+	return "0x" + hex.EncodeToString(raw)
+}
+
+// newHeadState returns a pointer to an empty head state of the builtin actor
+// with code `code`, or nil for other actors.
+func newHeadState(code cid.Cid) interface{} {
+	switch {
+	case code.Equals(builtin.AccountActorCodeID):
+		return &account.State{}
+	case code.Equals(builtin.InitActorCodeID):
+		return &notinit.State{}
+	case code.Equals(builtin.StorageMarketActorCodeID):
+		return &market.State{}
+	case code.Equals(builtin.StorageMinerActorCodeID):
+		return &miner.State{}
+	case code.Equals(builtin.StoragePowerActorCodeID):
+		return &power.State{}
+	case code.Equals(builtin.PaymentChannelActorCodeID):
+		return &paych.State{}
+	default:
+		return nil
+	}
+}
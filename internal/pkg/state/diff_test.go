@@ -0,0 +1,81 @@
+package state
+
+import (
+	"context"
+	"testing"
+
+	"github.com/filecoin-project/specs-actors/actors/abi"
+	"github.com/filecoin-project/specs-actors/actors/builtin"
+	notinit "github.com/filecoin-project/specs-actors/actors/builtin/init"
+	"github.com/ipfs/go-cid"
+	"github.com/ipfs/go-datastore"
+	hamt "github.com/ipfs/go-hamt-ipld"
+	bstore "github.com/ipfs/go-ipfs-blockstore"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/filecoin-project/go-filecoin/internal/pkg/cborutil"
+	"github.com/filecoin-project/go-filecoin/internal/pkg/encoding"
+	tf "github.com/filecoin-project/go-filecoin/internal/pkg/testhelpers/testflags"
+	vmaddr "github.com/filecoin-project/go-filecoin/internal/pkg/vm/address"
+)
+
+func TestDiffHeads(t *testing.T) {
+	tf.UnitTest(t)
+	ctx := context.Background()
+	store := cborutil.NewIpldStore(bstore.NewBlockstore(datastore.NewMapDatastore()))
+
+	addrGetter := vmaddr.NewForTestGetter()
+	kept, changed, removed, added := addrGetter(), addrGetter(), addrGetter(), addrGetter()
+
+	putAddressMap := func(ids map[string]uint64) cid.Cid {
+		node := hamt.NewNode(store)
+		for k, id := range ids {
+			raw, err := encoding.Encode(id)
+			require.NoError(t, err)
+			require.NoError(t, node.SetRaw(ctx, k, raw))
+		}
+		require.NoError(t, node.Flush(ctx))
+		root, err := store.Put(ctx, node)
+		require.NoError(t, err)
+		return root
+	}
+	putState := func(st *notinit.State) cid.Cid {
+		c, err := store.Put(ctx, st)
+		require.NoError(t, err)
+		return c
+	}
+
+	before := putState(&notinit.State{
+		AddressMap: putAddressMap(map[string]uint64{
+			string(kept.Bytes()):    100,
+			string(changed.Bytes()): 101,
+			string(removed.Bytes()): 102,
+		}),
+		NextID:      103,
+		NetworkName: "net",
+	})
+	after := putState(&notinit.State{
+		AddressMap: putAddressMap(map[string]uint64{
+			string(kept.Bytes()):    100,
+			string(changed.Bytes()): 111,
+			string(added.Bytes()):   103,
+		}),
+		NextID:      104,
+		NetworkName: "net",
+	})
+
+	fields, err := diffHeads(ctx, store, builtin.InitActorCodeID, before, after)
+	require.NoError(t, err)
+
+	byField := make(map[string]FieldDiff)
+	for _, f := range fields {
+		byField[f.Field] = f
+	}
+	require.Len(t, byField, 4)
+
+	assert.Equal(t, FieldDiff{Field: "NextID", Before: abi.ActorID(103), After: abi.ActorID(104)}, byField["NextID"])
+	assert.Equal(t, FieldDiff{Field: "AddressMap[" + changed.String() + "]", Before: "101", After: "111"}, byField["AddressMap["+changed.String()+"]"])
+	assert.Equal(t, FieldDiff{Field: "AddressMap[" + removed.String() + "]", Before: "102"}, byField["AddressMap["+removed.String()+"]"])
+	assert.Equal(t, FieldDiff{Field: "AddressMap[" + added.String() + "]", After: "103"}, byField["AddressMap["+added.String()+"]"])
+}
@@ -9,6 +9,7 @@ import (
 	"github.com/filecoin-project/specs-actors/actors/builtin"
 	"github.com/filecoin-project/specs-actors/actors/builtin/account"
 	notinit "github.com/filecoin-project/specs-actors/actors/builtin/init"
+	"github.com/filecoin-project/specs-actors/actors/builtin/market"
 	"github.com/filecoin-project/specs-actors/actors/builtin/miner"
 	"github.com/filecoin-project/specs-actors/actors/builtin/power"
 	"github.com/filecoin-project/specs-actors/actors/util/adt"
@@ -191,6 +192,28 @@ func (v *View) MinerClaimedPower(ctx context.Context, miner addr.Address) (abi.S
 	return claim.Power, nil
 }
 
+// PowerMiners returns the addresses of the miners with a claim in the power actor.
+func (v *View) PowerMiners(ctx context.Context) ([]addr.Address, error) {
+	powerState, err := v.loadPowerActor(ctx)
+	if err != nil {
+		return nil, err
+	}
+	var miners []addr.Address
+	var claim power.Claim
+	err = v.asMap(ctx, powerState.Claims).ForEach(&claim, func(key string) error {
+		miner, err := addr.NewFromBytes([]byte(key))
+		if err != nil {
+			return err
+		}
+		miners = append(miners, miner)
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return miners, nil
+}
+
 func (v *View) loadPowerClaim(ctx context.Context, powerState *power.State, miner addr.Address) (*power.Claim, error) {
 	var claim power.Claim
 	found, err := v.asMap(ctx, powerState.Claims).Get(adt.AddrKey(miner), &claim)
@@ -222,6 +245,32 @@ func (v *View) MinerPledgeCollateral(ctx context.Context, miner addr.Address) (l
 	return
 }
 
+// MarketPieceProviders returns the distinct providers of the storage deals for a piece.
+func (v *View) MarketPieceProviders(ctx context.Context, pieceCID cid.Cid) ([]addr.Address, error) {
+	marketState, err := v.loadMarketActor(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	var providers []addr.Address
+	seen := make(map[addr.Address]struct{})
+	var proposal market.DealProposal
+	err = v.asArray(ctx, marketState.Proposals).ForEach(&proposal, func(i int64) error {
+		if !proposal.PieceCID.Equals(pieceCID) {
+			return nil
+		}
+		if _, ok := seen[proposal.Provider]; !ok {
+			seen[proposal.Provider] = struct{}{}
+			providers = append(providers, proposal.Provider)
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return providers, nil
+}
+
 func (v *View) loadInitActor(ctx context.Context) (*notinit.State, error) {
 	actr, err := v.loadActor(ctx, builtin.InitActorAddr)
 	if err != nil {
@@ -242,6 +291,16 @@ func (v *View) loadMinerActor(ctx context.Context, address addr.Address) (*miner
 	return &state, err
 }
 
+func (v *View) loadMarketActor(ctx context.Context) (*market.State, error) {
+	actr, err := v.loadActor(ctx, builtin.StorageMarketActorAddr)
+	if err != nil {
+		return nil, err
+	}
+	var state market.State
+	err = v.ipldStore.Get(ctx, actr.Head.Cid, &state)
+	return &state, err
+}
+
 func (v *View) loadPowerActor(ctx context.Context) (*power.State, error) {
 	actr, err := v.loadActor(ctx, builtin.StoragePowerActorAddr)
 	if err != nil {
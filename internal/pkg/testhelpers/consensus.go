@@ -80,6 +80,11 @@ func (fbv *FakeBlockValidator) ValidateUnsignedMessagesSyntax(ctx context.Contex
 	return nil
 }
 
+// ValidateMessagesGasLimit does nothing
+func (fbv *FakeBlockValidator) ValidateMessagesGasLimit(ctx context.Context, secpMessages []*types.SignedMessage, blsMessages []*types.UnsignedMessage) error {
+	return nil
+}
+
 // ValidateReceiptsSyntax does nothing
 func (fbv *FakeBlockValidator) ValidateReceiptsSyntax(ctx context.Context, receipts []vm.MessageReceipt) error {
 	return nil
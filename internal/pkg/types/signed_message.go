@@ -58,8 +58,23 @@ func (smsg *SignedMessage) Marshal() ([]byte, error) {
 	return encoding.Encode(smsg)
 }
 
+// WrapBLSMessage wraps a BLS message as included in a block, where only the aggregate of the
+// BLS signatures is kept, into a SignedMessage with an empty BLS signature.
+func WrapBLSMessage(msg *UnsignedMessage) *SignedMessage {
+	return &SignedMessage{
+		Message:   *msg,
+		Signature: crypto.Signature{Type: crypto.SigTypeBLS},
+	}
+}
+
 // Cid returns the canonical CID for the SignedMessage.
+// BLS signatures are not kept on chain, so a BLS-signed message is identified by the CID of the
+// unsigned message, as it is in blocks.
 func (smsg *SignedMessage) Cid() (cid.Cid, error) {
+	if smsg.Signature.Type == crypto.SigTypeBLS {
+		return smsg.Message.Cid()
+	}
+
 	obj, err := smsg.ToNode()
 	if err != nil {
 		return cid.Undef, errors.Wrap(err, "failed to marshal to cbor")
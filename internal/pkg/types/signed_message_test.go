@@ -9,6 +9,7 @@ import (
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
 
+	"github.com/filecoin-project/go-filecoin/internal/pkg/crypto"
 	tf "github.com/filecoin-project/go-filecoin/internal/pkg/testhelpers/testflags"
 )
 
@@ -74,6 +75,24 @@ func TestSignedMessageCidToNode(t *testing.T) {
 
 }
 
+func TestSignedMessageCidBLS(t *testing.T) {
+	tf.UnitTest(t)
+
+	blsSigner := NewMockSigner([]crypto.KeyInfo{crypto.NewBLSKeyRandom()})
+	smsg := makeMessage(t, blsSigner, 41)
+	require.Equal(t, crypto.SigTypeBLS, smsg.Signature.Type)
+
+	c, err := smsg.Cid()
+	require.NoError(t, err)
+	unsigned, err := smsg.Message.Cid()
+	require.NoError(t, err)
+	assert.Equal(t, unsigned, c)
+
+	wrapped, err := WrapBLSMessage(&smsg.Message).Cid()
+	require.NoError(t, err)
+	assert.Equal(t, c, wrapped)
+}
+
 func makeMessage(t *testing.T, signer MockSigner, nonce uint64) *SignedMessage {
 	newAddr, err := address.NewSecp256k1Address([]byte("receiver"))
 	require.NoError(t, err)
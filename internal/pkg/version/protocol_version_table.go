@@ -41,6 +41,21 @@ func (pvt *ProtocolVersionTable) VersionAt(height abi.ChainEpoch) (uint64, error
 	return pvt.versions[idx-1].Version, nil
 }
 
+// ProtocolUpgrade is a protocol version and the height it goes into effect at.
+type ProtocolUpgrade struct {
+	Version     uint64
+	EffectiveAt abi.ChainEpoch
+}
+
+// Upgrades returns the protocol versions of this PVT's network in the order they go into effect.
+func (pvt *ProtocolVersionTable) Upgrades() []ProtocolUpgrade {
+	upgrades := make([]ProtocolUpgrade, len(pvt.versions))
+	for i, v := range pvt.versions {
+		upgrades[i] = ProtocolUpgrade{Version: v.Version, EffectiveAt: v.EffectiveAt}
+	}
+	return upgrades
+}
+
 // ProtocolVersionTableBuilder constructs a protocol version table
 type ProtocolVersionTableBuilder struct {
 	network  string
@@ -52,6 +52,19 @@ func TestUpgradeTable(t *testing.T) {
 		}
 	})
 
+	t.Run("lists upgrades in order", func(t *testing.T) {
+		put, err := NewProtocolVersionTableBuilder(network).
+			Add(network, 1, abi.ChainEpoch(10)).
+			Add(network, 0, abi.ChainEpoch(0)).
+			Build()
+		require.NoError(t, err)
+
+		assert.Equal(t, []ProtocolUpgrade{
+			{Version: 0, EffectiveAt: abi.ChainEpoch(0)},
+			{Version: 1, EffectiveAt: abi.ChainEpoch(10)},
+		}, put.Upgrades())
+	})
+
 	t.Run("constructing a table with no versions is an error", func(t *testing.T) {
 		_, err := NewProtocolVersionTableBuilder(network).Build()
 		require.Error(t, err)
@@ -0,0 +1,59 @@
+// gen embeds the gas parameters files of the parameters directory into a Go
+// source file, keyed by network name.
+//
+// Usage: gen <parameters directory> <output file>
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"go/format"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+func main() {
+	if len(os.Args) != 3 {
+		fmt.Fprintln(os.Stderr, "usage: gen <parameters directory> <output file>")
+		os.Exit(2)
+	}
+	if err := run(os.Args[1], os.Args[2]); err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(1)
+	}
+}
+
+func run(dir, out string) error {
+	paths, err := filepath.Glob(filepath.Join(dir, "*.json"))
+	if err != nil {
+		return err
+	}
+	sort.Strings(paths)
+
+	var buf bytes.Buffer
+	fmt.Fprintln(&buf, "// Code generated by gen from the files of the parameters directory. DO NOT EDIT.")
+	fmt.Fprintln(&buf)
+	fmt.Fprintln(&buf, "package gascost")
+	fmt.Fprintln(&buf)
+	fmt.Fprintln(&buf, "// networkParameters are the gas parameters files embedded for known networks.")
+	fmt.Fprintln(&buf, "var networkParameters = map[string]string{")
+	for _, path := range paths {
+		content, err := ioutil.ReadFile(path)
+		if err != nil {
+			return err
+		}
+		network := strings.TrimSuffix(filepath.Base(path), ".json")
+		fmt.Fprintf(&buf, "%s: %s,\n", strconv.Quote(network), strconv.Quote(string(content)))
+	}
+	fmt.Fprintln(&buf, "}")
+
+	src, err := format.Source(buf.Bytes())
+	if err != nil {
+		return err
+	}
+	return ioutil.WriteFile(out, src, 0644)
+}
@@ -0,0 +1,61 @@
+package gascost
+
+import (
+	"encoding/json"
+	"io"
+	"strings"
+
+	"github.com/pkg/errors"
+)
+
+//go:generate go run ./gen parameters parameters_gen.go
+
+// ParametersVersion is the version of the gas parameters format understood
+// by LoadParameters.
+const ParametersVersion = 1
+
+// Parameters is the content of a gas parameters file, the prices charged
+// under each protocol version of a network.
+type Parameters struct {
+	Version    uint64            `json:"version"`
+	Pricelists []VersionedPrices `json:"pricelists"`
+}
+
+// VersionedPrices are the prices charged while a protocol version is active.
+type VersionedPrices struct {
+	ProtocolVersion uint64 `json:"protocolVersion"`
+	Prices          Prices `json:"prices"`
+}
+
+// LoadParameters reads gas parameters in JSON from `r`.
+func LoadParameters(r io.Reader) (*Parameters, error) {
+	var params Parameters
+	decoder := json.NewDecoder(r)
+	decoder.DisallowUnknownFields()
+	if err := decoder.Decode(&params); err != nil {
+		return nil, errors.Wrap(err, "failed to decode gas parameters")
+	}
+	if params.Version != ParametersVersion {
+		return nil, errors.Errorf("unsupported gas parameters version %d, expected %d", params.Version, ParametersVersion)
+	}
+	seen := make(map[uint64]bool)
+	for _, pl := range params.Pricelists {
+		if seen[pl.ProtocolVersion] {
+			return nil, errors.Errorf("duplicate prices for protocol version %d", pl.ProtocolVersion)
+		}
+		seen[pl.ProtocolVersion] = true
+	}
+	return &params, nil
+}
+
+// ParametersForNetwork returns the gas parameters embedded for `network`,
+// from the file of the parameters directory named after it. As with protocol
+// versions, anything following a dash in the network name is ignored.
+func ParametersForNetwork(network string) (*Parameters, error) {
+	networkPrefix := strings.Split(network, "-")[0]
+	params, ok := networkParameters[networkPrefix]
+	if !ok {
+		return nil, errors.Errorf("no gas parameters for network %s", network)
+	}
+	return LoadParameters(strings.NewReader(params))
+}
@@ -0,0 +1,89 @@
+package gascost
+
+import (
+	"github.com/filecoin-project/go-filecoin/internal/pkg/vm/gas"
+)
+
+// LinearCost is a cost that grows linearly with the size of its input.
+type LinearCost struct {
+	Base    gas.Unit `json:"base"`
+	PerUnit gas.Unit `json:"perUnit"`
+}
+
+// Cost returns the cost for an input of size `x`.
+func (c LinearCost) Cost(x int) gas.Unit {
+	return c.Base + c.PerUnit*gas.Unit(x)
+}
+
+// Prices is the persistable table of gas costs a pricelist charges.
+type Prices struct {
+	///////////////////////////////////////////////////////////////////////////
+	// System operations
+	///////////////////////////////////////////////////////////////////////////
+
+	// Gas cost charged to the originator of an on-chain message (regardless of
+	// whether it succeeds or fails in application) is given by:
+	//   ChainMessage.Base + len(serialized message)*ChainMessage.PerUnit
+	// Together, these account for the cost of message propagation and validation,
+	// up to but excluding any actual processing by the VM.
+	// This is the cost a block producer burns when including an invalid message.
+	ChainMessage LinearCost `json:"chainMessage"`
+
+	// Gas cost charged to the originator of a non-nil return value produced
+	// by an on-chain message is given by:
+	//   len(return value)*ChainReturnValuePerByte
+	ChainReturnValuePerByte gas.Unit `json:"chainReturnValuePerByte"`
+
+	// Gas cost for any message send execution(including the top-level one
+	// initiated by an on-chain message).
+	// This accounts for the cost of loading sender and receiver actors and
+	// (for top-level messages) incrementing the sender's sequence number.
+	// Load and store of actor sub-state is charged separately.
+	SendBase gas.Unit `json:"sendBase"`
+
+	// Gas cost charged, in addition to SendBase, if a message send
+	// is accompanied by any nonzero currency amount.
+	// Accounts for writing receiver's new balance (the sender's state is
+	// already accounted for).
+	SendTransferFunds gas.Unit `json:"sendTransferFunds"`
+
+	// Gas cost charged, in addition to SendBase, if a message invokes
+	// a method on the receiver.
+	// Accounts for the cost of loading receiver code and method dispatch.
+	SendInvokeMethod gas.Unit `json:"sendInvokeMethod"`
+
+	// Gas cost (Base + len*PerUnit) for any Get operation to the IPLD store
+	// in the runtime VM context.
+	IpldGet LinearCost `json:"ipldGet"`
+
+	// Gas cost (Base + len*PerUnit) for any Put operation to the IPLD store
+	// in the runtime VM context.
+	//
+	// Note: these costs should be significantly higher than the costs for Get
+	// operations, since they reflect not only serialization/deserialization
+	// but also persistent storage of chain data.
+	IpldPut LinearCost `json:"ipldPut"`
+
+	// Gas cost for creating a new actor (via InitActor's Exec method).
+	//
+	// Note: this costs assume that the extra will be partially or totally refunded while
+	// the base is covering for the put.
+	CreateActorBase  gas.Unit `json:"createActorBase"`
+	CreateActorExtra gas.Unit `json:"createActorExtra"`
+
+	// Gas cost for deleting an actor.
+	//
+	// Note: this partially refunds the create cost to incentivise the deletion of the actors.
+	DeleteActor gas.Unit `json:"deleteActor"`
+
+	// Gas costs for verifying a signature over a plain text of a given size.
+	VerifyBLSSignature       LinearCost `json:"verifyBLSSignature"`
+	VerifySecp256k1Signature LinearCost `json:"verifySecp256k1Signature"`
+
+	Hashing LinearCost `json:"hashing"`
+
+	ComputeUnsealedSectorCidBase gas.Unit `json:"computeUnsealedSectorCidBase"`
+	VerifySealBase               gas.Unit `json:"verifySealBase"`
+	VerifyPostBase               gas.Unit `json:"verifyPostBase"`
+	VerifyConsensusFault         gas.Unit `json:"verifyConsensusFault"`
+}
@@ -0,0 +1,75 @@
+package gascost
+
+import (
+	"fmt"
+
+	"github.com/filecoin-project/specs-actors/actors/abi"
+	"github.com/pkg/errors"
+
+	"github.com/filecoin-project/go-filecoin/internal/pkg/version"
+)
+
+// Schedule selects the pricelist in effect at an epoch from the protocol
+// version active at that epoch.
+type Schedule struct {
+	versions *version.ProtocolVersionTable
+	prices   map[uint64]Prices
+}
+
+// NewSchedule builds a schedule charging the prices of `params` under the
+// protocol versions of `versions`. Every protocol version of the table must
+// have prices.
+func NewSchedule(versions *version.ProtocolVersionTable, params *Parameters) (*Schedule, error) {
+	prices := make(map[uint64]Prices, len(params.Pricelists))
+	for _, pl := range params.Pricelists {
+		prices[pl.ProtocolVersion] = pl.Prices
+	}
+	for _, upgrade := range versions.Upgrades() {
+		if _, ok := prices[upgrade.Version]; !ok {
+			return nil, errors.Errorf("no gas prices for protocol version %d", upgrade.Version)
+		}
+	}
+	return &Schedule{versions: versions, prices: prices}, nil
+}
+
+// PricesAt returns the protocol version active at `epoch` and its prices.
+func (s *Schedule) PricesAt(epoch abi.ChainEpoch) (uint64, Prices, error) {
+	v, err := s.versions.VersionAt(epoch)
+	if err != nil {
+		return 0, Prices{}, err
+	}
+	return v, s.prices[v], nil
+}
+
+// PricelistAt returns the pricelist in effect at `epoch`.
+func (s *Schedule) PricelistAt(epoch abi.ChainEpoch) Pricelist {
+	_, prices, err := s.PricesAt(epoch)
+	if err != nil {
+		panic(fmt.Sprintf("bad setup: no gas prices available for epoch %d: %s", epoch, err))
+	}
+	return &pricelistV0{prices}
+}
+
+// DefaultSchedule returns the schedule of the test network, used where no
+// network is configured.
+func DefaultSchedule() *Schedule {
+	return defaultSchedule
+}
+
+var defaultSchedule = mustNetworkSchedule(version.TEST)
+
+func mustNetworkSchedule(network string) *Schedule {
+	versions, err := version.ConfigureProtocolVersions(network)
+	if err != nil {
+		panic(err)
+	}
+	params, err := ParametersForNetwork(network)
+	if err != nil {
+		panic(err)
+	}
+	s, err := NewSchedule(versions, params)
+	if err != nil {
+		panic(err)
+	}
+	return s
+}
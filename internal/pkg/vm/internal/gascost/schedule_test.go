@@ -0,0 +1,114 @@
+package gascost
+
+import (
+	"io/ioutil"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/filecoin-project/specs-actors/actors/abi"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/filecoin-project/go-filecoin/internal/pkg/crypto"
+	tf "github.com/filecoin-project/go-filecoin/internal/pkg/testhelpers/testflags"
+	"github.com/filecoin-project/go-filecoin/internal/pkg/version"
+	"github.com/filecoin-project/go-filecoin/internal/pkg/vm/gas"
+)
+
+func TestLoadParameters(t *testing.T) {
+	tf.UnitTest(t)
+
+	t.Run("embedded parameters cover the protocol versions of known networks", func(t *testing.T) {
+		for _, network := range []string{version.USER, version.DEVNET4, version.LOCALNET, version.TEST, "localnet-270a8688"} {
+			versions, err := version.ConfigureProtocolVersions(network)
+			require.NoError(t, err)
+			params, err := ParametersForNetwork(network)
+			require.NoError(t, err)
+			_, err = NewSchedule(versions, params)
+			assert.NoError(t, err, network)
+		}
+	})
+
+	t.Run("embedded parameters are those of the parameters directory", func(t *testing.T) {
+		paths, err := filepath.Glob(filepath.Join("parameters", "*.json"))
+		require.NoError(t, err)
+		require.Equal(t, len(paths), len(networkParameters), "run go generate")
+		for _, path := range paths {
+			content, err := ioutil.ReadFile(path)
+			require.NoError(t, err)
+			network := strings.TrimSuffix(filepath.Base(path), ".json")
+			assert.Equal(t, string(content), networkParameters[network], "run go generate")
+		}
+	})
+
+	t.Run("refuses an unknown network", func(t *testing.T) {
+		_, err := ParametersForNetwork("othernetwork")
+		assert.Error(t, err)
+	})
+
+	t.Run("embedded prices are the v0 prices", func(t *testing.T) {
+		pl := PricelistByEpoch(0)
+		assert.Equal(t, gas.NewGas(20), pl.OnChainMessage(10))
+		assert.Equal(t, gas.NewGas(540), pl.OnCreateActor())
+		assert.Equal(t, gas.NewGas(32), pl.OnVerifySignature(crypto.SigTypeBLS, 10))
+		assert.Equal(t, gas.NewGas(32), pl.OnVerifySignature(crypto.SigTypeSecp256k1, 10))
+	})
+
+	t.Run("rejects an unknown format version", func(t *testing.T) {
+		_, err := LoadParameters(strings.NewReader(`{"version": 2, "pricelists": []}`))
+		require.Error(t, err)
+		assert.Contains(t, err.Error(), "unsupported gas parameters version 2")
+	})
+
+	t.Run("rejects unknown prices", func(t *testing.T) {
+		_, err := LoadParameters(strings.NewReader(`{"version": 1, "pricelists": [{"protocolVersion": 0, "prices": {"teleport": 1}}]}`))
+		assert.Error(t, err)
+	})
+
+	t.Run("rejects duplicate protocol versions", func(t *testing.T) {
+		_, err := LoadParameters(strings.NewReader(`{"version": 1, "pricelists": [{"protocolVersion": 0}, {"protocolVersion": 0}]}`))
+		require.Error(t, err)
+		assert.Contains(t, err.Error(), "duplicate prices for protocol version 0")
+	})
+}
+
+func TestSchedule(t *testing.T) {
+	tf.UnitTest(t)
+
+	versions, err := version.NewProtocolVersionTableBuilder("testnetwork").
+		Add("testnetwork", 0, abi.ChainEpoch(0)).
+		Add("testnetwork", 1, abi.ChainEpoch(10)).
+		Build()
+	require.NoError(t, err)
+
+	t.Run("requires prices for every protocol version", func(t *testing.T) {
+		_, err := NewSchedule(versions, &Parameters{
+			Version:    ParametersVersion,
+			Pricelists: []VersionedPrices{{ProtocolVersion: 0}},
+		})
+		require.Error(t, err)
+		assert.Contains(t, err.Error(), "no gas prices for protocol version 1")
+	})
+
+	t.Run("selects the prices of the active protocol version", func(t *testing.T) {
+		s, err := NewSchedule(versions, &Parameters{
+			Version: ParametersVersion,
+			Pricelists: []VersionedPrices{
+				{ProtocolVersion: 0, Prices: Prices{ChainMessage: LinearCost{Base: 1}}},
+				{ProtocolVersion: 1, Prices: Prices{ChainMessage: LinearCost{Base: 2}}},
+			},
+		})
+		require.NoError(t, err)
+
+		v, prices, err := s.PricesAt(9)
+		require.NoError(t, err)
+		assert.Equal(t, uint64(0), v)
+		assert.Equal(t, gas.NewGas(1), prices.ChainMessage.Base)
+
+		v, prices, err = s.PricesAt(10)
+		require.NoError(t, err)
+		assert.Equal(t, uint64(1), v)
+		assert.Equal(t, gas.NewGas(2), prices.ChainMessage.Base)
+	})
+}
@@ -18,6 +18,8 @@ type actorStateHandle struct {
 	//
 	// any subsequent calls needs to be using the same variable.
 	usedObj interface{}
+	// tracer records the state reads and writes, nil when not tracing.
+	tracer *tracer
 }
 
 // validateFn returns True if it's valid.
@@ -69,6 +71,7 @@ func (h *actorStateHandle) Create(obj specsruntime.CBORMarshaler) {
 
 	// store state
 	h.head = h.ctx.Store().Put(obj)
+	h.tracer.stateAccess(true, h.head)
 
 	// update internal ref to state
 	h.usedObj = obj
@@ -92,6 +95,7 @@ func (h *actorStateHandle) Readonly(obj specsruntime.CBORUnmarshaler) {
 	}
 
 	// load it to obj
+	h.tracer.stateAccess(false, readonlyHead)
 	h.ctx.Store().Get(readonlyHead, obj)
 }
 
@@ -112,6 +116,7 @@ func (h *actorStateHandle) Transaction(obj specsruntime.CBORer, f func() interfa
 
 	// load state only if it already exists
 	if h.head != cid.Undef {
+		h.tracer.stateAccess(false, oldcid)
 		h.ctx.Store().Get(oldcid, obj)
 	}
 
@@ -125,6 +130,7 @@ func (h *actorStateHandle) Transaction(obj specsruntime.CBORer, f func() interfa
 
 	// update head
 	h.head = newcid
+	h.tracer.stateAccess(true, newcid)
 
 	return out
 }
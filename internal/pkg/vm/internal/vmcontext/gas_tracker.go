@@ -10,6 +10,8 @@ import (
 type GasTracker struct {
 	gasLimit    gas.Unit
 	gasConsumed gas.Unit
+	// tracer records the charges made, nil when not tracing
+	tracer *tracer
 }
 
 // NewGasTracker initializes a new empty gas tracker
@@ -37,10 +39,12 @@ func (t *GasTracker) TryCharge(amount gas.Unit) bool {
 	aux := t.gasConsumed + amount
 	if aux > t.gasLimit {
 		t.gasConsumed = t.gasLimit
+		t.tracer.settle(amount, false)
 		return false
 	}
 
 	t.gasConsumed = aux
+	t.tracer.settle(amount, true)
 	return true
 }
 
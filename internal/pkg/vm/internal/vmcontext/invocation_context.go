@@ -32,6 +32,8 @@ type invocationContext struct {
 	allowSideEffects  bool
 	toActor           *actor.Actor
 	stateHandle       internalActorStateHandle
+	// trace is nil unless the vm is tracing
+	trace *ExecutionTrace
 }
 
 type internalActorStateHandle interface {
@@ -65,6 +67,12 @@ func (ctx *stateHandleContext) Store() specsruntime.Store {
 
 // runtime aborts are trapped by invoke, it will always return an exit code.
 func (ctx *invocationContext) invoke() (ret returnWrapper, errcode exitcode.ExitCode) {
+	// record the outcome in the trace, after any abort has been trapped below
+	defer ctx.rt.tracer.enter(ctx.trace)()
+	defer func() {
+		ctx.trace.finish(ret, errcode)
+	}()
+
 	defer func() {
 		if r := recover(); r != nil {
 			// rollback any pending changes
@@ -129,6 +137,7 @@ func (ctx *invocationContext) invoke() (ret returnWrapper, errcode exitcode.Exit
 
 	// 6. create target state handle
 	stateHandle := newActorStateHandle((*stateHandleContext)(ctx), ctx.toActor.Head.Cid)
+	stateHandle.tracer = ctx.rt.tracer
 	ctx.stateHandle = &stateHandle
 
 	// dispatch
@@ -260,6 +269,7 @@ func (ctx *invocationContext) resolveTarget(target address.Address) (*actor.Acto
 		}
 
 		newCtx := newInvocationContext(ctx.rt, newMsg, nil, ctx.gasTank, ctx.randSource)
+		newCtx.trace = ctx.trace.subcall(newMsg)
 		_, code := newCtx.invoke()
 		if code.IsError() {
 			// we failed to construct an account actor..
@@ -379,6 +389,7 @@ func (ctx *invocationContext) Send(toAddr address.Address, methodNum abi.MethodN
 
 	// 1. build new context
 	newCtx := newInvocationContext(ctx.rt, newMsg, fromActor, ctx.gasTank, ctx.randSource)
+	newCtx.trace = ctx.trace.subcall(newMsg)
 
 	// 2. invoke
 	return newCtx.invoke()
@@ -391,6 +402,7 @@ func (ctx *invocationContext) Balance() abi.TokenAmount {
 
 // Charge implements runtime.InvocationContext.
 func (ctx *invocationContext) Charge(cost gas.Unit) error {
+	ctx.rt.tracer.charge("Charge", cost)
 	ctx.gasTank.Charge(cost)
 	return nil
 }
@@ -0,0 +1,58 @@
+package vmcontext
+
+import (
+	"testing"
+
+	"github.com/filecoin-project/specs-actors/actors/abi"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	tf "github.com/filecoin-project/go-filecoin/internal/pkg/testhelpers/testflags"
+	"github.com/filecoin-project/go-filecoin/internal/pkg/version"
+	"github.com/filecoin-project/go-filecoin/internal/pkg/vm/gas"
+	"github.com/filecoin-project/go-filecoin/internal/pkg/vm/internal/gascost"
+)
+
+// The vm must charge the prices of a protocol version from exactly the epoch
+// that version goes into effect.
+func TestPricelistUpgrades(t *testing.T) {
+	tf.UnitTest(t)
+
+	versions, err := version.NewProtocolVersionTableBuilder("testnetwork").
+		Add("testnetwork", 0, abi.ChainEpoch(0)).
+		Add("testnetwork", 1, abi.ChainEpoch(10)).
+		Add("testnetwork", 2, abi.ChainEpoch(25)).
+		Build()
+	require.NoError(t, err)
+
+	// each version charges a per byte message cost equal to its version + 1
+	params := &gascost.Parameters{Version: gascost.ParametersVersion}
+	for _, upgrade := range versions.Upgrades() {
+		params.Pricelists = append(params.Pricelists, gascost.VersionedPrices{
+			ProtocolVersion: upgrade.Version,
+			Prices:          gascost.Prices{ChainMessage: gascost.LinearCost{PerUnit: gas.Unit(upgrade.Version + 1)}},
+		})
+	}
+	schedule, err := gascost.NewSchedule(versions, params)
+	require.NoError(t, err)
+
+	vm := NewVM(nil, nil, nil, nil, schedule)
+	expectations := map[abi.ChainEpoch]gas.Unit{
+		0:  gas.NewGas(1),
+		9:  gas.NewGas(1),
+		10: gas.NewGas(2),
+		24: gas.NewGas(2),
+		25: gas.NewGas(3),
+		99: gas.NewGas(3),
+	}
+	for epoch, perByte := range expectations {
+		vm.setPricelist(epoch)
+		assert.Equal(t, perByte, vm.pricelist.OnChainMessage(1), "epoch %d", epoch)
+	}
+
+	t.Run("tracing charges the same prices", func(t *testing.T) {
+		vm.EnableTracing()
+		vm.setPricelist(10)
+		assert.Equal(t, gas.NewGas(2), vm.pricelist.OnChainMessage(1))
+	})
+}
@@ -106,7 +106,7 @@ func NewState() *ValidationVMWrapper {
 	bs := blockstore.NewBlockstore(datastore.NewMapDatastore())
 	cst := cborutil.NewIpldStore(bs)
 	vmstrg := storage.NewStorage(bs)
-	vm := NewVM(gfbuiltin.DefaultActors, &vmstrg, state.NewState(cst), &vmsupport.FakeSyscalls{})
+	vm := NewVM(gfbuiltin.DefaultActors, &vmstrg, state.NewState(cst), &vmsupport.FakeSyscalls{}, gascost.DefaultSchedule())
 	return &ValidationVMWrapper{
 		vm: &vm,
 	}
@@ -267,7 +267,7 @@ func (a *ValidationApplier) ApplyMessage(context *vtypes.ExecutionContext, state
 	// set epoch
 	// Note: this would have normally happened during `ApplyTipset()`
 	st.vm.currentEpoch = context.Epoch
-	st.vm.pricelist = gascost.PricelistByEpoch(context.Epoch)
+	st.vm.setPricelist(context.Epoch)
 
 	// map message
 	// Dragons: fix after cleaning up our msg
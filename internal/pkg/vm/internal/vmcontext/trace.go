@@ -0,0 +1,229 @@
+package vmcontext
+
+import (
+	"bytes"
+
+	"github.com/filecoin-project/go-address"
+	"github.com/filecoin-project/specs-actors/actors/abi"
+	specsruntime "github.com/filecoin-project/specs-actors/actors/runtime"
+	"github.com/filecoin-project/specs-actors/actors/runtime/exitcode"
+	"github.com/ipfs/go-cid"
+
+	"github.com/filecoin-project/go-filecoin/internal/pkg/crypto"
+	"github.com/filecoin-project/go-filecoin/internal/pkg/encoding"
+	"github.com/filecoin-project/go-filecoin/internal/pkg/vm/gas"
+	"github.com/filecoin-project/go-filecoin/internal/pkg/vm/internal/gascost"
+	"github.com/filecoin-project/go-filecoin/internal/pkg/vm/internal/message"
+)
+
+// ExecutionTrace is the record of the execution of a message, including the
+// internal sends it made.
+type ExecutionTrace struct {
+	From     address.Address   `json:"from"`
+	To       address.Address   `json:"to"`
+	Value    abi.TokenAmount   `json:"value"`
+	Method   abi.MethodNum     `json:"method"`
+	Params   []byte            `json:"params"`
+	Return   []byte            `json:"return"`
+	ExitCode exitcode.ExitCode `json:"exitCode"`
+	// GasCharges are the charges made while this message was executing, not
+	// including the charges of its subcalls.
+	GasCharges []GasCharge `json:"gasCharges"`
+	// StateAccesses are the reads and writes of the receiver's state handle.
+	StateAccesses []StateAccess `json:"stateAccesses"`
+	// Subcalls are the traces of the internal sends, in order.
+	Subcalls []*ExecutionTrace `json:"subcalls"`
+}
+
+// GasCharge is an amount of gas charged by a pricelist operation.
+type GasCharge struct {
+	Name   string   `json:"name"`
+	Amount gas.Unit `json:"amount"`
+}
+
+// StateAccess is a read or a write of an actor state through its state handle.
+type StateAccess struct {
+	Write bool    `json:"write"`
+	Head  cid.Cid `json:"head"`
+}
+
+// GasUsed returns the gas charged by the message and all of its subcalls.
+func (t *ExecutionTrace) GasUsed() gas.Unit {
+	total := gas.Zero
+	for _, c := range t.GasCharges {
+		total += c.Amount
+	}
+	for _, sub := range t.Subcalls {
+		total += sub.GasUsed()
+	}
+	return total
+}
+
+// subcall returns the trace of an internal send made by this message, nil if
+// this message is not being traced.
+func (t *ExecutionTrace) subcall(msg internalMessage) *ExecutionTrace {
+	if t == nil {
+		return nil
+	}
+	sub := newExecutionTrace(msg)
+	t.Subcalls = append(t.Subcalls, sub)
+	return sub
+}
+
+func (t *ExecutionTrace) finish(ret returnWrapper, code exitcode.ExitCode) {
+	if t == nil {
+		return
+	}
+	t.ExitCode = code
+	t.Return, _ = ret.ToCbor()
+}
+
+func newExecutionTrace(msg internalMessage) *ExecutionTrace {
+	return &ExecutionTrace{
+		From:   msg.from,
+		To:     msg.to,
+		Value:  msg.value,
+		Method: msg.method,
+		Params: encodeTracedParams(msg.params),
+	}
+}
+
+// encodeTracedParams encodes message params for a trace, dropping params that
+// fail to encode: the actor will fail on them anyway.
+func encodeTracedParams(params interface{}) []byte {
+	switch p := params.(type) {
+	case nil:
+		return nil
+	case []byte:
+		return p
+	case specsruntime.CBORMarshaler:
+		b := bytes.Buffer{}
+		if err := p.MarshalCBOR(&b); err != nil {
+			return nil
+		}
+		return b.Bytes()
+	default:
+		raw, err := encoding.Encode(p)
+		if err != nil {
+			return nil
+		}
+		return raw
+	}
+}
+
+// tracer tracks the trace of the message executing, to which gas charges and
+// state accesses are recorded. A nil tracer records nothing.
+type tracer struct {
+	current *ExecutionTrace
+	// priced is the last price handed out by the pricelist, recorded as a
+	// charge once the gas tracker has charged it.
+	priced *GasCharge
+}
+
+// enter makes `trace` the current trace, and returns a function restoring
+// the previous one.
+func (t *tracer) enter(trace *ExecutionTrace) func() {
+	if t == nil {
+		return func() {}
+	}
+	prev := t.current
+	t.current = trace
+	return func() { t.current = prev }
+}
+
+// price notes a price handed out by the pricelist, to be recorded as a charge
+// by settle.
+func (t *tracer) price(name string, amount gas.Unit) {
+	if t == nil {
+		return
+	}
+	t.priced = &GasCharge{Name: name, Amount: amount}
+}
+
+// settle records the last price as a charge of the current trace if the gas
+// tracker charged it, and forgets it either way.
+func (t *tracer) settle(amount gas.Unit, charged bool) {
+	if t == nil {
+		return
+	}
+	priced := t.priced
+	t.priced = nil
+	if !charged || priced == nil || priced.Amount != amount || t.current == nil {
+		return
+	}
+	t.current.GasCharges = append(t.current.GasCharges, *priced)
+}
+
+func (t *tracer) stateAccess(write bool, head cid.Cid) {
+	if t == nil || t.current == nil {
+		return
+	}
+	t.current.StateAccesses = append(t.current.StateAccesses, StateAccess{Write: write, Head: head})
+}
+
+// tracingPricelist notes every price it hands out to the tracer. Prices are
+// charged right away, and the gas tracker has the tracer record those it
+// could charge.
+type tracingPricelist struct {
+	inner  gascost.Pricelist
+	tracer *tracer
+}
+
+var _ gascost.Pricelist = (*tracingPricelist)(nil)
+
+func (pl *tracingPricelist) record(name string, amount gas.Unit) gas.Unit {
+	pl.tracer.price(name, amount)
+	return amount
+}
+
+func (pl *tracingPricelist) OnChainMessage(msgSize int) gas.Unit {
+	return pl.record("OnChainMessage", pl.inner.OnChainMessage(msgSize))
+}
+
+func (pl *tracingPricelist) OnChainReturnValue(receipt *message.Receipt) gas.Unit {
+	return pl.record("OnChainReturnValue", pl.inner.OnChainReturnValue(receipt))
+}
+
+func (pl *tracingPricelist) OnMethodInvocation(value abi.TokenAmount, methodNum abi.MethodNum) gas.Unit {
+	return pl.record("OnMethodInvocation", pl.inner.OnMethodInvocation(value, methodNum))
+}
+
+func (pl *tracingPricelist) OnIpldGet(dataSize int) gas.Unit {
+	return pl.record("OnIpldGet", pl.inner.OnIpldGet(dataSize))
+}
+
+func (pl *tracingPricelist) OnIpldPut(dataSize int) gas.Unit {
+	return pl.record("OnIpldPut", pl.inner.OnIpldPut(dataSize))
+}
+
+func (pl *tracingPricelist) OnCreateActor() gas.Unit {
+	return pl.record("OnCreateActor", pl.inner.OnCreateActor())
+}
+
+func (pl *tracingPricelist) OnDeleteActor() gas.Unit {
+	return pl.record("OnDeleteActor", pl.inner.OnDeleteActor())
+}
+
+func (pl *tracingPricelist) OnVerifySignature(sigType crypto.SigType, planTextSize int) gas.Unit {
+	return pl.record("OnVerifySignature", pl.inner.OnVerifySignature(sigType, planTextSize))
+}
+
+func (pl *tracingPricelist) OnHashing(dataSize int) gas.Unit {
+	return pl.record("OnHashing", pl.inner.OnHashing(dataSize))
+}
+
+func (pl *tracingPricelist) OnComputeUnsealedSectorCid(proofType abi.RegisteredProof, pieces *[]abi.PieceInfo) gas.Unit {
+	return pl.record("OnComputeUnsealedSectorCid", pl.inner.OnComputeUnsealedSectorCid(proofType, pieces))
+}
+
+func (pl *tracingPricelist) OnVerifySeal(info abi.SealVerifyInfo) gas.Unit {
+	return pl.record("OnVerifySeal", pl.inner.OnVerifySeal(info))
+}
+
+func (pl *tracingPricelist) OnVerifyPost(info abi.PoStVerifyInfo) gas.Unit {
+	return pl.record("OnVerifyPost", pl.inner.OnVerifyPost(info))
+}
+
+func (pl *tracingPricelist) OnVerifyConsensusFault() gas.Unit {
+	return pl.record("OnVerifyConsensusFault", pl.inner.OnVerifyConsensusFault())
+}
@@ -0,0 +1,114 @@
+package vmcontext
+
+import (
+	"testing"
+
+	"github.com/filecoin-project/specs-actors/actors/abi"
+	"github.com/filecoin-project/specs-actors/actors/abi/big"
+	"github.com/filecoin-project/specs-actors/actors/runtime/exitcode"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/filecoin-project/go-filecoin/internal/pkg/encoding"
+	tf "github.com/filecoin-project/go-filecoin/internal/pkg/testhelpers/testflags"
+	"github.com/filecoin-project/go-filecoin/internal/pkg/types"
+	vmaddr "github.com/filecoin-project/go-filecoin/internal/pkg/vm/address"
+	"github.com/filecoin-project/go-filecoin/internal/pkg/vm/gas"
+	"github.com/filecoin-project/go-filecoin/internal/pkg/vm/internal/gascost"
+	"github.com/filecoin-project/go-filecoin/internal/pkg/vm/internal/message"
+)
+
+func TestExecutionTrace(t *testing.T) {
+	tf.UnitTest(t)
+
+	addrGetter := vmaddr.NewForTestGetter()
+	msg := internalMessage{
+		from:   addrGetter(),
+		to:     addrGetter(),
+		value:  big.Zero(),
+		method: abi.MethodNum(2),
+		params: []byte{1, 2, 3},
+	}
+	inner := gascost.PricelistByEpoch(0)
+	receipt := &message.Receipt{ReturnValue: []byte{4, 5}}
+
+	t.Run("charges are recorded on the current trace", func(t *testing.T) {
+		tr := &tracer{}
+		pricelist := &tracingPricelist{inner: inner, tracer: tr}
+		gasTank := NewGasTracker(gas.NewGas(1000000))
+		gasTank.tracer = tr
+
+		root := newExecutionTrace(msg)
+		restoreRoot := tr.enter(root)
+		assert.Equal(t, inner.OnChainMessage(10), pricelist.OnChainMessage(10))
+		gasTank.Charge(inner.OnChainMessage(10))
+
+		sub := root.subcall(msg)
+		restoreSub := tr.enter(sub)
+		gasTank.Charge(pricelist.OnMethodInvocation(big.Zero(), msg.method))
+		gasTank.Charge(pricelist.OnIpldGet(5))
+		tr.stateAccess(false, types.CidFromString(t, "head"))
+		restoreSub()
+
+		gasTank.Charge(pricelist.OnChainReturnValue(receipt))
+		restoreRoot()
+		gasTank.Charge(pricelist.OnCreateActor())
+
+		require.Len(t, root.GasCharges, 2)
+		assert.Equal(t, "OnChainMessage", root.GasCharges[0].Name)
+		assert.Equal(t, "OnChainReturnValue", root.GasCharges[1].Name)
+		require.Len(t, root.Subcalls, 1)
+		require.Len(t, sub.GasCharges, 2)
+		assert.Equal(t, "OnMethodInvocation", sub.GasCharges[0].Name)
+		assert.Equal(t, inner.OnIpldGet(5), sub.GasCharges[1].Amount)
+		require.Len(t, sub.StateAccesses, 1)
+		assert.False(t, sub.StateAccesses[0].Write)
+
+		expected := inner.OnChainMessage(10) + inner.OnChainReturnValue(receipt) +
+			inner.OnMethodInvocation(big.Zero(), msg.method) + inner.OnIpldGet(5)
+		assert.Equal(t, expected, root.GasUsed())
+	})
+
+	t.Run("charges that fail are not recorded", func(t *testing.T) {
+		tr := &tracer{}
+		pricelist := &tracingPricelist{inner: inner, tracer: tr}
+		gasTank := NewGasTracker(inner.OnChainMessage(10))
+		gasTank.tracer = tr
+
+		root := newExecutionTrace(msg)
+		defer tr.enter(root)()
+		assert.True(t, gasTank.TryCharge(pricelist.OnChainMessage(10)))
+		assert.False(t, gasTank.TryCharge(pricelist.OnChainReturnValue(receipt)))
+
+		t.Log("prices that are not charged are not recorded")
+		pricelist.OnCreateActor()
+		assert.True(t, gasTank.TryCharge(gas.Zero))
+
+		require.Len(t, root.GasCharges, 1)
+		assert.Equal(t, "OnChainMessage", root.GasCharges[0].Name)
+		assert.Equal(t, inner.OnChainMessage(10), root.GasUsed())
+	})
+
+	t.Run("nil tracer and trace record nothing", func(t *testing.T) {
+		var tr *tracer
+		tr.enter(nil)()
+		tr.price("OnHashing", gas.NewGas(1))
+		tr.settle(gas.NewGas(1), true)
+		tr.stateAccess(true, types.CidFromString(t, "head"))
+
+		var trace *ExecutionTrace
+		assert.Nil(t, trace.subcall(msg))
+		trace.finish(returnWrapper{}, exitcode.Ok)
+	})
+
+	t.Run("params are encoded", func(t *testing.T) {
+		trace := newExecutionTrace(msg)
+		assert.Equal(t, []byte{1, 2, 3}, trace.Params)
+
+		withAddr := msg
+		withAddr.params = &msg.from
+		expected, err := encoding.Encode(msg.from)
+		require.NoError(t, err)
+		assert.Equal(t, expected, newExecutionTrace(withAddr).Params)
+	})
+}
@@ -21,6 +21,7 @@ import (
 	"github.com/filecoin-project/go-filecoin/internal/pkg/crypto"
 	"github.com/filecoin-project/go-filecoin/internal/pkg/encoding"
 	"github.com/filecoin-project/go-filecoin/internal/pkg/types"
+	"github.com/filecoin-project/go-filecoin/internal/pkg/vm/actor"
 	"github.com/filecoin-project/go-filecoin/internal/pkg/vm/gas"
 	"github.com/filecoin-project/go-filecoin/internal/pkg/vm/internal/dispatch"
 	"github.com/filecoin-project/go-filecoin/internal/pkg/vm/internal/gascost"
@@ -41,7 +42,12 @@ type VM struct {
 	state        state.Tree
 	syscalls     SyscallsImpl
 	currentEpoch abi.ChainEpoch
+	pricelists   *gascost.Schedule
 	pricelist    gascost.Pricelist
+	// tracer is nil unless tracing is enabled
+	tracer *tracer
+	// traces of the messages applied, in receipt order
+	traces []*ExecutionTrace
 }
 
 // ActorImplLookup provides access to upgradeable actor code.
@@ -62,25 +68,63 @@ type internalMessage struct {
 	callSeqNumber uint64
 }
 
-// NewVM creates a new runtime for executing messages.
+// NewVM creates a new runtime for executing messages, charging gas from the
+// pricelist `pricelists` has in effect at the epoch of the messages.
 // Dragons: change to take a root and the store, build the tree internally
-func NewVM(actorImpls ActorImplLookup, store *storage.VMStorage, st state.Tree, syscalls SyscallsImpl) VM {
+func NewVM(actorImpls ActorImplLookup, store *storage.VMStorage, st state.Tree, syscalls SyscallsImpl, pricelists *gascost.Schedule) VM {
 	return VM{
 		context:    context.Background(),
 		actorImpls: actorImpls,
 		store:      store,
 		state:      st,
 		syscalls:   syscalls,
+		pricelists: pricelists,
 		// loaded during execution
 		// currentEpoch: ..,
 	}
 }
 
+// EnableTracing makes the vm record the execution trace of the messages it applies.
+func (vm *VM) EnableTracing() {
+	vm.tracer = &tracer{}
+}
+
+// Traces returns the execution traces of the messages applied by the last
+// call to ApplyTipSetMessages, in the order of their receipts.
+//
+// Traces are only recorded after EnableTracing has been called.
+func (vm *VM) Traces() []*ExecutionTrace {
+	return vm.traces
+}
+
+// ApplyMessage applies a single message at the given epoch and returns its
+// receipt and execution trace, the trace being nil unless tracing is enabled.
+//
+// The state is not committed, this method is intended for previewing messages.
+func (vm *VM) ApplyMessage(msg *types.UnsignedMessage, onChainMsgSize int, epoch abi.ChainEpoch, rnd crypto.RandomnessSource) (message.Receipt, *ExecutionTrace) {
+	vm.currentEpoch = epoch
+	vm.setPricelist(epoch)
+	vm.traces = nil
+
+	receipt, _, _ := vm.applyMessage(msg, onChainMsgSize, rnd)
+	if vm.tracer == nil {
+		return receipt, nil
+	}
+	return receipt, vm.traces[0]
+}
+
+func (vm *VM) setPricelist(epoch abi.ChainEpoch) {
+	vm.pricelist = vm.pricelists.PricelistAt(epoch)
+	if vm.tracer != nil {
+		vm.pricelist = &tracingPricelist{inner: vm.pricelist, tracer: vm.tracer}
+	}
+}
+
 // ApplyGenesisMessage forces the execution of a message in the vm actor.
 //
 // This method is intended to be used in the generation of the genesis block only.
 func (vm *VM) ApplyGenesisMessage(from address.Address, to address.Address, method abi.MethodNum, value abi.TokenAmount, params interface{}, rnd crypto.RandomnessSource) (interface{}, error) {
-	vm.pricelist = gascost.PricelistByEpoch(vm.currentEpoch)
+	vm.setPricelist(vm.currentEpoch)
 
 	// normalize from addr
 	var ok bool
@@ -149,32 +193,52 @@ func (vm *VM) ContextStore() adt.Store {
 }
 
 func (vm *VM) normalizeAddress(addr address.Address) (address.Address, bool) {
+	idAddr, found, err := vm.resolveAddress(addr)
+	if err != nil {
+		panic(err)
+	}
+	return idAddr, found
+}
+
+// ResolveActor returns the actor at `addr`, which need not be an ID address,
+// in the current state, and whether there is one.
+func (vm *VM) ResolveActor(addr address.Address) (*actor.Actor, bool, error) {
+	idAddr, found, err := vm.resolveAddress(addr)
+	if err != nil || !found {
+		return nil, false, err
+	}
+	return vm.state.GetActor(vm.context, idAddr)
+}
+
+// resolveAddress returns the ID address of `addr` and whether it resolves,
+// or an error if the init actor state could not be loaded.
+func (vm *VM) resolveAddress(addr address.Address) (address.Address, bool, error) {
 	// short-circuit if the address is already an ID address
 	if addr.Protocol() == address.ID {
-		return addr, true
+		return addr, true, nil
 	}
 
 	// resolve the target address via the InitActor, and attempt to load state.
 	initActorEntry, found, err := vm.state.GetActor(vm.context, builtin.InitActorAddr)
 	if err != nil {
-		panic(errors.Wrapf(err, "failed to load init actor"))
+		return address.Undef, false, errors.Wrapf(err, "failed to load init actor")
 	}
 	if !found {
-		panic(errors.Wrapf(err, "no init actor"))
+		return address.Undef, false, errors.New("no init actor")
 	}
 
 	// get a view into the actor state
 	var state notinit.State
 	if _, err := vm.store.Get(vm.context, initActorEntry.Head.Cid, &state); err != nil {
-		panic(err)
+		return address.Undef, false, err
 	}
 
 	idAddr, err := state.ResolveAddress(vm.ContextStore(), addr)
 	if err != nil {
-		return address.Undef, false
+		return address.Undef, false, nil
 	}
 
-	return idAddr, true
+	return idAddr, true, nil
 }
 
 func (vm *VM) resolveSignerAddress(accountAddr address.Address) (address.Address, error) {
@@ -207,7 +271,8 @@ func (vm *VM) ApplyTipSetMessages(blocks []interpreter.BlockMessagesInfo, epoch
 
 	// update current epoch
 	vm.currentEpoch = epoch
-	vm.pricelist = gascost.PricelistByEpoch(epoch)
+	vm.setPricelist(epoch)
+	vm.traces = nil
 
 	// create message tracker
 	// Note: the same message could have been included by more than one miner
@@ -300,6 +365,7 @@ func (vm *VM) ApplyTipSetMessages(blocks []interpreter.BlockMessagesInfo, epoch
 func (vm *VM) applyImplicitMessage(imsg internalMessage, rnd crypto.RandomnessSource) (specsruntime.CBORMarshaler, error) {
 	// implicit messages gas is tracked separatly and not paid by the miner
 	gasTank := NewGasTracker(gas.SystemGasLimit)
+	gasTank.tracer = vm.tracer
 
 	// the execution of the implicit messages is simpler than full external/actor-actor messages
 	// execution:
@@ -337,16 +403,35 @@ func (vm *VM) applyImplicitMessage(imsg internalMessage, rnd crypto.RandomnessSo
 }
 
 // applyMessage applies the message to the current state.
-func (vm *VM) applyMessage(msg *types.UnsignedMessage, onChainMsgSize int, rnd crypto.RandomnessSource) (message.Receipt, minerPenaltyFIL, gasRewardFIL) {
+func (vm *VM) applyMessage(msg *types.UnsignedMessage, onChainMsgSize int, rnd crypto.RandomnessSource) (receipt message.Receipt, penalty minerPenaltyFIL, reward gasRewardFIL) {
 	// Dragons: temp until we remove legacy types
 	var msgGasLimit gas.Unit = gas.Unit(msg.GasLimit)
 
+	// start the message trace, the charges made before the invocation are recorded on it
+	var trace *ExecutionTrace
+	if vm.tracer != nil {
+		trace = newExecutionTrace(internalMessage{
+			from:   msg.From,
+			to:     msg.To,
+			value:  msg.Value,
+			method: msg.Method,
+			params: msg.Params,
+		})
+		vm.traces = append(vm.traces, trace)
+		defer vm.tracer.enter(trace)()
+		defer func() {
+			trace.ExitCode = receipt.ExitCode
+			trace.Return = receipt.ReturnValue
+		}()
+	}
+
 	// This method does not actually execute the message itself,
 	// but rather deals with the pre/post processing of a message.
 	// (see: `invocationContext.invoke()` for the dispatch and execution)
 
 	// initiate gas tracking
 	gasTank := NewGasTracker(msgGasLimit)
+	gasTank.tracer = vm.tracer
 
 	// pre-send
 	// 1. charge for message existence
@@ -432,12 +517,13 @@ func (vm *VM) applyMessage(msg *types.UnsignedMessage, onChainMsgSize int, rnd c
 
 	// 2. build invocation context
 	ctx := newInvocationContext(vm, imsg, fromActor, &gasTank, rnd)
+	ctx.trace = trace
 
 	// 3. invoke
 	ret, code := ctx.invoke()
 
 	// build receipt
-	receipt := message.Receipt{
+	receipt = message.Receipt{
 		ExitCode: code,
 	}
 	// encode value
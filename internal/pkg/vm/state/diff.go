@@ -0,0 +1,102 @@
+package state
+
+import (
+	"bytes"
+	"context"
+	"sort"
+
+	"github.com/filecoin-project/go-address"
+
+	"github.com/filecoin-project/go-filecoin/internal/pkg/vm/actor"
+)
+
+// ActorDiff is the difference of an actor between two state trees.
+// Before is nil for an added actor, After is nil for a removed one.
+type ActorDiff struct {
+	Address address.Address
+	Before  *actor.Actor
+	After   *actor.Actor
+}
+
+// Added returns true if the actor is only in the second tree.
+func (d *ActorDiff) Added() bool {
+	return d.Before == nil
+}
+
+// Removed returns true if the actor is only in the first tree.
+func (d *ActorDiff) Removed() bool {
+	return d.After == nil
+}
+
+// Diff walks the trees `a` and `b` and returns the actors that were added,
+// removed or changed from `a` to `b`, ordered by address.
+func Diff(ctx context.Context, a, b Tree) ([]*ActorDiff, error) {
+	rootA, dirtyA := a.Root()
+	rootB, dirtyB := b.Root()
+	if !dirtyA && !dirtyB && rootA.Defined() && rootA.Equals(rootB) {
+		return []*ActorDiff{}, nil
+	}
+
+	before, err := allActors(ctx, a)
+	if err != nil {
+		return nil, err
+	}
+	after, err := allActors(ctx, b)
+	if err != nil {
+		return nil, err
+	}
+
+	diffs := []*ActorDiff{}
+	for key, beforeActor := range before {
+		afterActor, found := after[key]
+		if !found {
+			diffs = append(diffs, &ActorDiff{Address: beforeActor.addr, Before: beforeActor.actor})
+			continue
+		}
+		if !actorsEqual(beforeActor.actor, afterActor.actor) {
+			diffs = append(diffs, &ActorDiff{Address: beforeActor.addr, Before: beforeActor.actor, After: afterActor.actor})
+		}
+	}
+	for key, afterActor := range after {
+		if _, found := before[key]; !found {
+			diffs = append(diffs, &ActorDiff{Address: afterActor.addr, After: afterActor.actor})
+		}
+	}
+
+	sort.Slice(diffs, func(i, j int) bool {
+		return bytes.Compare(diffs[i].Address.Bytes(), diffs[j].Address.Bytes()) < 0
+	})
+	return diffs, nil
+}
+
+type addressedActor struct {
+	addr  address.Address
+	actor *actor.Actor
+}
+
+// allActors returns the actors of `tree` by address. On error, the walk of
+// the tree is cancelled and its channel drained, so that it does not block.
+func allActors(ctx context.Context, tree Tree) (map[string]addressedActor, error) {
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	results := tree.GetAllActors(ctx)
+	actors := make(map[string]addressedActor)
+	for res := range results {
+		if res.Error != nil {
+			cancel()
+			for range results {
+			}
+			return nil, res.Error
+		}
+		actors[string(res.Key.Bytes())] = addressedActor{addr: res.Key, actor: res.Actor}
+	}
+	return actors, nil
+}
+
+func actorsEqual(a, b *actor.Actor) bool {
+	return a.Code.Equals(b.Code.Cid) &&
+		a.Head.Equals(b.Head.Cid) &&
+		a.CallSeqNum == b.CallSeqNum &&
+		a.Balance.Equals(b.Balance)
+}
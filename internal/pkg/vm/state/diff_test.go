@@ -0,0 +1,61 @@
+package state
+
+import (
+	"context"
+	"testing"
+
+	"github.com/filecoin-project/specs-actors/actors/abi"
+	"github.com/filecoin-project/specs-actors/actors/builtin"
+	bstore "github.com/ipfs/go-ipfs-blockstore"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/filecoin-project/go-filecoin/internal/pkg/cborutil"
+	"github.com/filecoin-project/go-filecoin/internal/pkg/repo"
+	tf "github.com/filecoin-project/go-filecoin/internal/pkg/testhelpers/testflags"
+	"github.com/filecoin-project/go-filecoin/internal/pkg/vm/actor"
+	vmaddr "github.com/filecoin-project/go-filecoin/internal/pkg/vm/address"
+)
+
+func TestDiff(t *testing.T) {
+	tf.UnitTest(t)
+
+	ctx := context.Background()
+	bs := bstore.NewBlockstore(repo.NewInMemoryRepo().Datastore())
+	cst := cborutil.NewIpldStore(bs)
+
+	addrGetter := vmaddr.NewForTestGetter()
+	kept, changed, removed, added := addrGetter(), addrGetter(), addrGetter(), addrGetter()
+
+	before := NewState(cst)
+	require.NoError(t, before.SetActor(ctx, kept, actor.NewActor(builtin.AccountActorCodeID, abi.NewTokenAmount(1))))
+	require.NoError(t, before.SetActor(ctx, changed, actor.NewActor(builtin.AccountActorCodeID, abi.NewTokenAmount(2))))
+	require.NoError(t, before.SetActor(ctx, removed, actor.NewActor(builtin.AccountActorCodeID, abi.NewTokenAmount(3))))
+	rootBefore, err := before.Commit(ctx)
+	require.NoError(t, err)
+
+	after, err := LoadState(ctx, cst, rootBefore)
+	require.NoError(t, err)
+	require.NoError(t, after.SetActor(ctx, changed, actor.NewActor(builtin.AccountActorCodeID, abi.NewTokenAmount(20))))
+	require.NoError(t, after.DeleteActor(ctx, removed))
+	require.NoError(t, after.SetActor(ctx, added, actor.NewActor(builtin.AccountActorCodeID, abi.NewTokenAmount(4))))
+	_, err = after.Commit(ctx)
+	require.NoError(t, err)
+
+	diffs, err := Diff(ctx, before, after)
+	require.NoError(t, err)
+	require.Len(t, diffs, 3)
+
+	byAddr := make(map[string]*ActorDiff)
+	for _, d := range diffs {
+		byAddr[d.Address.String()] = d
+	}
+	assert.True(t, byAddr[added.String()].Added())
+	assert.True(t, byAddr[removed.String()].Removed())
+	assert.Equal(t, abi.NewTokenAmount(2), byAddr[changed.String()].Before.Balance)
+	assert.Equal(t, abi.NewTokenAmount(20), byAddr[changed.String()].After.Balance)
+
+	same, err := Diff(ctx, before, before)
+	require.NoError(t, err)
+	assert.Empty(t, same)
+}
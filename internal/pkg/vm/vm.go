@@ -1,10 +1,17 @@
 package vm
 
 import (
+	"github.com/filecoin-project/go-address"
+	"github.com/filecoin-project/specs-actors/actors/abi"
 	blockstore "github.com/ipfs/go-ipfs-blockstore"
 
+	"github.com/filecoin-project/go-filecoin/internal/pkg/crypto"
+	"github.com/filecoin-project/go-filecoin/internal/pkg/types"
+	"github.com/filecoin-project/go-filecoin/internal/pkg/version"
+	"github.com/filecoin-project/go-filecoin/internal/pkg/vm/actor"
 	"github.com/filecoin-project/go-filecoin/internal/pkg/vm/actor/builtin"
 	"github.com/filecoin-project/go-filecoin/internal/pkg/vm/internal/dispatch"
+	"github.com/filecoin-project/go-filecoin/internal/pkg/vm/internal/gascost"
 	"github.com/filecoin-project/go-filecoin/internal/pkg/vm/internal/interpreter"
 	"github.com/filecoin-project/go-filecoin/internal/pkg/vm/internal/message"
 	"github.com/filecoin-project/go-filecoin/internal/pkg/vm/internal/storage"
@@ -28,9 +35,60 @@ type BlockMessagesInfo = interpreter.BlockMessagesInfo
 // MessageReceipt is what is returned by executing a message on the vm.
 type MessageReceipt = message.Receipt
 
+// ExecutionTrace is the record of the execution of a message.
+type ExecutionTrace = vmcontext.ExecutionTrace
+
+// GasCharge is an amount of gas charged during the execution of a message.
+type GasCharge = vmcontext.GasCharge
+
+// StateAccess is a read or write of an actor state during the execution of a message.
+type StateAccess = vmcontext.StateAccess
+
+// TracingInterpreter is a VM recording the execution trace of the messages it applies.
+type TracingInterpreter interface {
+	Interpreter
+	// Traces returns the traces of the messages applied by the last call to
+	// ApplyTipSetMessages, in the order of their receipts.
+	Traces() []*ExecutionTrace
+	// ApplyMessage applies a single message without committing the state.
+	ApplyMessage(msg *types.UnsignedMessage, onChainMsgSize int, epoch abi.ChainEpoch, rnd crypto.RandomnessSource) (MessageReceipt, *ExecutionTrace)
+	// ResolveActor returns the actor at an address, which need not be an ID
+	// address, in the current state, and whether there is one.
+	ResolveActor(addr address.Address) (*actor.Actor, bool, error)
+}
+
+// PricelistSchedule selects the gas pricelist in effect at an epoch.
+type PricelistSchedule = gascost.Schedule
+
+// GasPrices is the table of gas costs of a pricelist.
+type GasPrices = gascost.Prices
+
+// NewPricelistSchedule builds the gas pricelist schedule of `network` from
+// the gas parameters embedded for it and its protocol versions.
+func NewPricelistSchedule(versions *version.ProtocolVersionTable, network string) (*PricelistSchedule, error) {
+	params, err := gascost.ParametersForNetwork(network)
+	if err != nil {
+		return nil, err
+	}
+	return gascost.NewSchedule(versions, params)
+}
+
+// DefaultPricelistSchedule returns the gas pricelist schedule used where no
+// network is configured.
+func DefaultPricelistSchedule() *PricelistSchedule {
+	return gascost.DefaultSchedule()
+}
+
 // NewVM creates a new VM interpreter.
-func NewVM(st state.Tree, store *storage.VMStorage, syscalls SyscallsImpl) Interpreter {
-	vm := vmcontext.NewVM(builtin.DefaultActors, store, st, syscalls)
+func NewVM(st state.Tree, store *storage.VMStorage, syscalls SyscallsImpl, pricelists *PricelistSchedule) Interpreter {
+	vm := vmcontext.NewVM(builtin.DefaultActors, store, st, syscalls, pricelists)
+	return &vm
+}
+
+// NewTracingVM creates a new VM interpreter recording execution traces.
+func NewTracingVM(st state.Tree, store *storage.VMStorage, syscalls SyscallsImpl, pricelists *PricelistSchedule) TracingInterpreter {
+	vm := vmcontext.NewVM(builtin.DefaultActors, store, st, syscalls, pricelists)
+	vm.EnableTracing()
 	return &vm
 }
 
@@ -0,0 +1,545 @@
+package wallet
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"encoding/json"
+	"fmt"
+	"io"
+	"reflect"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/filecoin-project/go-address"
+	ds "github.com/ipfs/go-datastore"
+	dsq "github.com/ipfs/go-datastore/query"
+	"github.com/pkg/errors"
+	"golang.org/x/crypto/scrypt"
+
+	"github.com/filecoin-project/go-filecoin/internal/pkg/clock"
+	"github.com/filecoin-project/go-filecoin/internal/pkg/crypto"
+	"github.com/filecoin-project/go-filecoin/internal/pkg/repo"
+)
+
+// EncryptedBackendType is the reflect type of the EncryptedBackend.
+var EncryptedBackendType = reflect.TypeOf(&EncryptedBackend{})
+
+var (
+	// ErrBadPassphrase is returned when a passphrase does not open the wallet.
+	ErrBadPassphrase = errors.New("incorrect wallet passphrase")
+	// ErrNotEncrypted is returned when a wallet datastore is expected to be
+	// encrypted but is not.
+	ErrNotEncrypted = errors.New("wallet is not encrypted")
+	// ErrAlreadyEncrypted is returned when encrypting an encrypted wallet datastore.
+	ErrAlreadyEncrypted = errors.New("wallet is already encrypted")
+)
+
+// encryptionParamsKey holds the key derivation parameters of an encrypted
+// wallet datastore. Its presence marks the datastore as encrypted.
+var encryptionParamsKey = ds.NewKey("/encryption")
+
+// checkPlaintext is sealed into the encryption params so a passphrase can be
+// verified without any keys in the wallet.
+var checkPlaintext = []byte("filecoin wallet")
+
+const (
+	scryptN      = 1 << 15
+	scryptR      = 8
+	scryptP      = 1
+	sealKeyBytes = 32
+	saltBytes    = 32
+)
+
+// LockedError is returned when a private key is needed from an encrypted
+// wallet that is locked.
+type LockedError struct {
+	Addr address.Address
+}
+
+func (e *LockedError) Error() string {
+	if e.Addr == address.Undef {
+		return "wallet is locked: run 'go-filecoin wallet unlock' first"
+	}
+	return fmt.Sprintf("wallet is locked: run 'go-filecoin wallet unlock' to use address %s", e.Addr)
+}
+
+// IsLocked returns true if the cause of err is a locked wallet.
+func IsLocked(err error) bool {
+	_, ok := errors.Cause(err).(*LockedError)
+	return ok
+}
+
+// encryptionParams are the scrypt parameters used to derive the sealing key
+// from the passphrase.
+type encryptionParams struct {
+	Salt  []byte
+	N     int
+	R     int
+	P     int
+	Check []byte
+}
+
+// EncryptedBackend is a wallet backend storing addresses in a datastore with
+// their keys sealed by a key derived from a passphrase. The backend starts
+// locked and private keys are only available while it is unlocked.
+type EncryptedBackend struct {
+	lk sync.RWMutex
+
+	ds     repo.Datastore
+	params encryptionParams
+	clock  clock.Clock
+
+	cache map[address.Address]struct{}
+
+	// key is the sealing key, nil while locked.
+	key []byte
+	// expires is when the backend locks itself, zero for never.
+	expires   time.Time
+	lockTimer clock.Timer
+}
+
+var _ Backend = (*EncryptedBackend)(nil)
+var _ Importer = (*EncryptedBackend)(nil)
+
+// IsEncrypted returns true if the wallet datastore has been encrypted.
+func IsEncrypted(d repo.Datastore) (bool, error) {
+	return d.Has(encryptionParamsKey)
+}
+
+// NewEncryptedBackend opens an encrypted wallet datastore. The backend is
+// locked until Unlock is called.
+func NewEncryptedBackend(d repo.Datastore, clk clock.Clock) (*EncryptedBackend, error) {
+	params, err := loadEncryptionParams(d)
+	if err != nil {
+		return nil, err
+	}
+
+	addrs, err := storedAddresses(d)
+	if err != nil {
+		return nil, err
+	}
+	cache := make(map[address.Address]struct{})
+	for _, a := range addrs {
+		cache[a] = struct{}{}
+	}
+
+	return &EncryptedBackend{
+		ds:     d,
+		params: params,
+		clock:  clk,
+		cache:  cache,
+	}, nil
+}
+
+// EncryptDatastore seals all plaintext keys of a wallet datastore with a key
+// derived from passphrase. The datastore must be opened with
+// NewEncryptedBackend afterwards.
+func EncryptDatastore(d repo.Datastore, passphrase string) error {
+	encrypted, err := IsEncrypted(d)
+	if err != nil {
+		return err
+	}
+	if encrypted {
+		return ErrAlreadyEncrypted
+	}
+	if passphrase == "" {
+		return errors.New("wallet passphrase must not be empty")
+	}
+
+	params, key, err := newEncryptionParams(passphrase)
+	if err != nil {
+		return err
+	}
+
+	addrs, err := storedAddresses(d)
+	if err != nil {
+		return err
+	}
+
+	batch, err := d.Batch()
+	if err != nil {
+		return err
+	}
+	for _, a := range addrs {
+		kib, err := d.Get(ds.NewKey(a.String()))
+		if err != nil {
+			return errors.Wrapf(err, "failed to read key of %s", a)
+		}
+		sealed, err := seal(key, kib, a.Bytes())
+		if err != nil {
+			return err
+		}
+		if err := batch.Put(ds.NewKey(a.String()), sealed); err != nil {
+			return err
+		}
+	}
+	paramsBytes, err := json.Marshal(params)
+	if err != nil {
+		return err
+	}
+	if err := batch.Put(encryptionParamsKey, paramsBytes); err != nil {
+		return err
+	}
+	return errors.Wrap(batch.Commit(), "failed to write encrypted wallet")
+}
+
+// Unlock derives the sealing key from passphrase and keeps it until Lock is
+// called or timeout elapses. A zero timeout keeps the wallet unlocked until
+// Lock is called.
+func (backend *EncryptedBackend) Unlock(passphrase string, timeout time.Duration) error {
+	key, err := backend.params.deriveKey(passphrase)
+	if err != nil {
+		return err
+	}
+
+	backend.lk.Lock()
+	defer backend.lk.Unlock()
+
+	backend.resetLocked()
+	backend.key = key
+	if timeout > 0 {
+		backend.expires = backend.clock.Now().Add(timeout)
+		backend.lockTimer = backend.clock.AfterFunc(timeout, func() {
+			// Drop the key from memory; access checks expires regardless.
+			backend.lk.Lock()
+			defer backend.lk.Unlock()
+			if !backend.expires.IsZero() && !backend.clock.Now().Before(backend.expires) {
+				backend.resetLocked()
+			}
+		})
+	}
+	return nil
+}
+
+// Lock forgets the sealing key.
+func (backend *EncryptedBackend) Lock() {
+	backend.lk.Lock()
+	defer backend.lk.Unlock()
+	backend.resetLocked()
+}
+
+// IsLocked returns true if private keys are not available.
+func (backend *EncryptedBackend) IsLocked() bool {
+	backend.lk.RLock()
+	defer backend.lk.RUnlock()
+	return backend.sealKey() == nil
+}
+
+// ChangePassphrase reseals all keys with a key derived from newPassphrase.
+// The backend is left locked.
+func (backend *EncryptedBackend) ChangePassphrase(oldPassphrase, newPassphrase string) error {
+	if newPassphrase == "" {
+		return errors.New("wallet passphrase must not be empty")
+	}
+	oldKey, err := backend.params.deriveKey(oldPassphrase)
+	if err != nil {
+		return err
+	}
+	params, newKey, err := newEncryptionParams(newPassphrase)
+	if err != nil {
+		return err
+	}
+
+	backend.lk.Lock()
+	defer backend.lk.Unlock()
+
+	batch, err := backend.ds.Batch()
+	if err != nil {
+		return err
+	}
+	for a := range backend.cache {
+		kib, err := backend.getSealed(oldKey, a)
+		if err != nil {
+			return err
+		}
+		sealed, err := seal(newKey, kib, a.Bytes())
+		if err != nil {
+			return err
+		}
+		if err := batch.Put(ds.NewKey(a.String()), sealed); err != nil {
+			return err
+		}
+	}
+	paramsBytes, err := json.Marshal(params)
+	if err != nil {
+		return err
+	}
+	if err := batch.Put(encryptionParamsKey, paramsBytes); err != nil {
+		return err
+	}
+	if err := batch.Commit(); err != nil {
+		return errors.Wrap(err, "failed to write resealed wallet")
+	}
+
+	backend.params = params
+	backend.resetLocked()
+	return nil
+}
+
+// ImportKey seals and stores the KeyInfo `ki`. The backend must be unlocked.
+func (backend *EncryptedBackend) ImportKey(ki *crypto.KeyInfo) error {
+	return backend.putKeyInfo(ki)
+}
+
+// Addresses returns a list of all addresses that are stored in this backend.
+func (backend *EncryptedBackend) Addresses() []address.Address {
+	backend.lk.RLock()
+	defer backend.lk.RUnlock()
+
+	var cpy []address.Address
+	for addr := range backend.cache {
+		cpy = append(cpy, addr)
+	}
+	return cpy
+}
+
+// HasAddress checks if the passed in address is stored in this backend.
+// Safe for concurrent access.
+func (backend *EncryptedBackend) HasAddress(addr address.Address) bool {
+	backend.lk.RLock()
+	defer backend.lk.RUnlock()
+
+	_, ok := backend.cache[addr]
+	return ok
+}
+
+// NewAddress creates a new address and stores it. The backend must be unlocked.
+// Safe for concurrent access.
+func (backend *EncryptedBackend) NewAddress(protocol address.Protocol) (address.Address, error) {
+	var ki crypto.KeyInfo
+	switch protocol {
+	case address.BLS:
+		ki = crypto.NewBLSKeyRandom()
+	case address.SECP256K1:
+		var err error
+		ki, err = crypto.NewSecpKeyFromSeed(rand.Reader)
+		if err != nil {
+			return address.Undef, err
+		}
+	default:
+		return address.Undef, errors.Errorf("Unknown address protocol %d", protocol)
+	}
+
+	if err := backend.putKeyInfo(&ki); err != nil {
+		return address.Undef, err
+	}
+	return ki.Address()
+}
+
+// SignBytes cryptographically signs `data` using the private key of `addr`.
+// It returns a *LockedError if the backend is locked.
+func (backend *EncryptedBackend) SignBytes(data []byte, addr address.Address) (crypto.Signature, error) {
+	ki, err := backend.GetKeyInfo(addr)
+	if err != nil {
+		return crypto.Signature{}, err
+	}
+	return crypto.Sign(data, ki.PrivateKey, ki.SigType)
+}
+
+// GetKeyInfo will return the private & public keys associated with address `addr`
+// iff backend contains the addr. It returns a *LockedError if the backend is locked.
+func (backend *EncryptedBackend) GetKeyInfo(addr address.Address) (*crypto.KeyInfo, error) {
+	backend.lk.RLock()
+	defer backend.lk.RUnlock()
+
+	if _, ok := backend.cache[addr]; !ok {
+		return nil, errors.New("backend does not contain address")
+	}
+	key := backend.sealKey()
+	if key == nil {
+		return nil, &LockedError{Addr: addr}
+	}
+
+	kib, err := backend.getSealed(key, addr)
+	if err != nil {
+		return nil, err
+	}
+	ki := &crypto.KeyInfo{}
+	if err := ki.Unmarshal(kib); err != nil {
+		return nil, errors.Wrap(err, "failed to unmarshal keyinfo from backend")
+	}
+	return ki, nil
+}
+
+func (backend *EncryptedBackend) putKeyInfo(ki *crypto.KeyInfo) error {
+	a, err := ki.Address()
+	if err != nil {
+		return err
+	}
+
+	backend.lk.Lock()
+	defer backend.lk.Unlock()
+
+	key := backend.sealKey()
+	if key == nil {
+		return &LockedError{Addr: a}
+	}
+
+	kib, err := ki.Marshal()
+	if err != nil {
+		return err
+	}
+	sealed, err := seal(key, kib, a.Bytes())
+	if err != nil {
+		return err
+	}
+	if err := backend.ds.Put(ds.NewKey(a.String()), sealed); err != nil {
+		return errors.Wrap(err, "failed to store new address")
+	}
+
+	backend.cache[a] = struct{}{}
+	return nil
+}
+
+// getSealed reads and opens the stored key of addr.
+func (backend *EncryptedBackend) getSealed(key []byte, addr address.Address) ([]byte, error) {
+	sealed, err := backend.ds.Get(ds.NewKey(addr.String()))
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to fetch private key from backend")
+	}
+	kib, err := open(key, sealed, addr.Bytes())
+	if err != nil {
+		return nil, errors.Wrapf(err, "failed to decrypt key of %s", addr)
+	}
+	return kib, nil
+}
+
+// sealKey returns the sealing key, or nil if the backend is locked.
+// Callers must hold the lock.
+func (backend *EncryptedBackend) sealKey() []byte {
+	if backend.key == nil {
+		return nil
+	}
+	if !backend.expires.IsZero() && !backend.clock.Now().Before(backend.expires) {
+		return nil
+	}
+	return backend.key
+}
+
+// resetLocked forgets the sealing key and stops the lock timer.
+// Callers must hold the lock.
+func (backend *EncryptedBackend) resetLocked() {
+	for i := range backend.key {
+		backend.key[i] = 0
+	}
+	backend.key = nil
+	backend.expires = time.Time{}
+	if backend.lockTimer != nil {
+		backend.lockTimer.Stop()
+		backend.lockTimer = nil
+	}
+}
+
+func newEncryptionParams(passphrase string) (encryptionParams, []byte, error) {
+	params := encryptionParams{
+		Salt: make([]byte, saltBytes),
+		N:    scryptN,
+		R:    scryptR,
+		P:    scryptP,
+	}
+	if _, err := io.ReadFull(rand.Reader, params.Salt); err != nil {
+		return encryptionParams{}, nil, err
+	}
+	key, err := scrypt.Key([]byte(passphrase), params.Salt, params.N, params.R, params.P, sealKeyBytes)
+	if err != nil {
+		return encryptionParams{}, nil, err
+	}
+	params.Check, err = seal(key, checkPlaintext, nil)
+	if err != nil {
+		return encryptionParams{}, nil, err
+	}
+	return params, key, nil
+}
+
+// deriveKey derives the sealing key from passphrase and verifies it against
+// the check value.
+func (p encryptionParams) deriveKey(passphrase string) ([]byte, error) {
+	key, err := scrypt.Key([]byte(passphrase), p.Salt, p.N, p.R, p.P, sealKeyBytes)
+	if err != nil {
+		return nil, err
+	}
+	if _, err := open(key, p.Check, nil); err != nil {
+		return nil, ErrBadPassphrase
+	}
+	return key, nil
+}
+
+func loadEncryptionParams(d repo.Datastore) (encryptionParams, error) {
+	raw, err := d.Get(encryptionParamsKey)
+	if err == ds.ErrNotFound {
+		return encryptionParams{}, ErrNotEncrypted
+	}
+	if err != nil {
+		return encryptionParams{}, errors.Wrap(err, "failed to read wallet encryption params")
+	}
+	var params encryptionParams
+	if err := json.Unmarshal(raw, &params); err != nil {
+		return encryptionParams{}, errors.Wrap(err, "failed to decode wallet encryption params")
+	}
+	return params, nil
+}
+
+// storedAddresses lists the addresses with keys in a wallet datastore.
+func storedAddresses(d repo.Datastore) ([]address.Address, error) {
+	result, err := d.Query(dsq.Query{
+		KeysOnly: true,
+	})
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to query datastore")
+	}
+
+	list, err := result.Rest()
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to read query results")
+	}
+
+	var addrs []address.Address
+	for _, el := range list {
+		if el.Key == encryptionParamsKey.String() {
+			continue
+		}
+		parsedAddr, err := address.NewFromString(strings.Trim(el.Key, "/"))
+		if err != nil {
+			return nil, errors.Wrapf(err, "trying to restore invalid address: %s", el.Key)
+		}
+		addrs = append(addrs, parsedAddr)
+	}
+	return addrs, nil
+}
+
+// seal encrypts plaintext with AES-256-GCM, binding it to additional data ad.
+// The output is the nonce followed by the ciphertext.
+func seal(key, plaintext, ad []byte) ([]byte, error) {
+	aead, err := newAEAD(key)
+	if err != nil {
+		return nil, err
+	}
+	nonce := make([]byte, aead.NonceSize())
+	if _, err := io.ReadFull(rand.Reader, nonce); err != nil {
+		return nil, err
+	}
+	return aead.Seal(nonce, nonce, plaintext, ad), nil
+}
+
+// open reverses seal.
+func open(key, sealed, ad []byte) ([]byte, error) {
+	aead, err := newAEAD(key)
+	if err != nil {
+		return nil, err
+	}
+	if len(sealed) < aead.NonceSize() {
+		return nil, errors.New("sealed data too short")
+	}
+	nonce, ciphertext := sealed[:aead.NonceSize()], sealed[aead.NonceSize():]
+	return aead.Open(nil, nonce, ciphertext, ad)
+}
+
+func newAEAD(key []byte) (cipher.AEAD, error) {
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, err
+	}
+	return cipher.NewGCM(block)
+}
@@ -0,0 +1,174 @@
+package wallet
+
+import (
+	"testing"
+	"time"
+
+	"github.com/filecoin-project/go-address"
+	"github.com/ipfs/go-datastore"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	th "github.com/filecoin-project/go-filecoin/internal/pkg/testhelpers"
+	tf "github.com/filecoin-project/go-filecoin/internal/pkg/testhelpers/testflags"
+)
+
+func TestEncryptedBackendEncryptDatastore(t *testing.T) {
+	tf.UnitTest(t)
+
+	ds := datastore.NewMapDatastore()
+	plain, err := NewDSBackend(ds)
+	require.NoError(t, err)
+	addr, err := plain.NewAddress(address.SECP256K1)
+	require.NoError(t, err)
+	ki, err := plain.GetKeyInfo(addr)
+	require.NoError(t, err)
+
+	encrypted, err := IsEncrypted(ds)
+	require.NoError(t, err)
+	assert.False(t, encrypted)
+	_, err = NewEncryptedBackend(ds, th.NewFakeClock(time.Unix(1234567890, 0)))
+	assert.Equal(t, ErrNotEncrypted, err)
+
+	require.NoError(t, EncryptDatastore(ds, "passphrase"))
+	assert.Equal(t, ErrAlreadyEncrypted, EncryptDatastore(ds, "passphrase"))
+	encrypted, err = IsEncrypted(ds)
+	require.NoError(t, err)
+	assert.True(t, encrypted)
+
+	t.Log("the key is no longer stored in plaintext")
+	stored, err := ds.Get(datastore.NewKey(addr.String()))
+	require.NoError(t, err)
+	kib, err := ki.Marshal()
+	require.NoError(t, err)
+	assert.NotEqual(t, kib, stored)
+
+	t.Log("the key is available once unlocked")
+	backend, err := NewEncryptedBackend(ds, th.NewFakeClock(time.Unix(1234567890, 0)))
+	require.NoError(t, err)
+	assert.True(t, backend.HasAddress(addr))
+	require.NoError(t, backend.Unlock("passphrase", 0))
+	got, err := backend.GetKeyInfo(addr)
+	require.NoError(t, err)
+	assert.True(t, ki.Equals(got))
+}
+
+func TestEncryptedBackendLocking(t *testing.T) {
+	tf.UnitTest(t)
+
+	ds := datastore.NewMapDatastore()
+	require.NoError(t, EncryptDatastore(ds, "passphrase"))
+	clk := th.NewFakeClock(time.Unix(1234567890, 0))
+	backend, err := NewEncryptedBackend(ds, clk)
+	require.NoError(t, err)
+
+	t.Log("a new backend is locked")
+	assert.True(t, backend.IsLocked())
+	_, err = backend.NewAddress(address.SECP256K1)
+	assert.True(t, IsLocked(err))
+
+	t.Log("a wrong passphrase does not unlock")
+	assert.Equal(t, ErrBadPassphrase, backend.Unlock("wrong", 0))
+	assert.True(t, backend.IsLocked())
+
+	require.NoError(t, backend.Unlock("passphrase", time.Minute))
+	addr, err := backend.NewAddress(address.BLS)
+	require.NoError(t, err)
+	_, err = backend.SignBytes([]byte("data"), addr)
+	require.NoError(t, err)
+
+	t.Log("signing fails with a typed error after the timeout")
+	clk.Advance(time.Minute)
+	assert.True(t, backend.IsLocked())
+	_, err = backend.SignBytes([]byte("data"), addr)
+	require.Error(t, err)
+	assert.True(t, IsLocked(err))
+	assert.Equal(t, addr, err.(*LockedError).Addr)
+
+	t.Log("signing through the wallet reports the locked error")
+	w := New(backend)
+	_, err = w.SignBytes([]byte("data"), addr)
+	assert.True(t, IsLocked(err))
+
+	t.Log("lock ends an unlock without timeout")
+	require.NoError(t, w.Unlock("passphrase", 0))
+	clk.Advance(time.Hour)
+	assert.False(t, backend.IsLocked())
+	require.NoError(t, w.Lock())
+	assert.True(t, backend.IsLocked())
+}
+
+func TestEncryptedBackendChangePassphrase(t *testing.T) {
+	tf.UnitTest(t)
+
+	ds := datastore.NewMapDatastore()
+	require.NoError(t, EncryptDatastore(ds, "old"))
+	clk := th.NewFakeClock(time.Unix(1234567890, 0))
+	backend, err := NewEncryptedBackend(ds, clk)
+	require.NoError(t, err)
+	require.NoError(t, backend.Unlock("old", 0))
+	addr, err := backend.NewAddress(address.SECP256K1)
+	require.NoError(t, err)
+	ki, err := backend.GetKeyInfo(addr)
+	require.NoError(t, err)
+
+	assert.Equal(t, ErrBadPassphrase, backend.ChangePassphrase("wrong", "new"))
+	require.NoError(t, backend.ChangePassphrase("old", "new"))
+	assert.True(t, backend.IsLocked())
+
+	reopened, err := NewEncryptedBackend(ds, clk)
+	require.NoError(t, err)
+	assert.Equal(t, ErrBadPassphrase, reopened.Unlock("old", 0))
+	require.NoError(t, reopened.Unlock("new", 0))
+	got, err := reopened.GetKeyInfo(addr)
+	require.NoError(t, err)
+	assert.True(t, ki.Equals(got))
+}
+
+func TestWalletWithoutEncryption(t *testing.T) {
+	tf.UnitTest(t)
+
+	backend, err := NewDSBackend(datastore.NewMapDatastore())
+	require.NoError(t, err)
+	w := New(backend)
+
+	assert.Equal(t, ErrNotEncrypted, w.Unlock("passphrase", 0))
+	assert.Equal(t, ErrNotEncrypted, w.Lock())
+}
+
+func TestWalletEncrypt(t *testing.T) {
+	tf.UnitTest(t)
+
+	ds := datastore.NewMapDatastore()
+	plain, err := NewDSBackend(ds)
+	require.NoError(t, err)
+	w := New(plain)
+	addr, err := NewAddress(w, address.SECP256K1)
+	require.NoError(t, err)
+	ki, err := w.Export([]address.Address{addr})
+	require.NoError(t, err)
+
+	clk := th.NewFakeClock(time.Unix(1234567890, 0))
+	assert.Error(t, w.Encrypt("", clk))
+	assert.Len(t, w.Backends(DSBackendType), 1)
+
+	require.NoError(t, w.Encrypt("passphrase", clk))
+	assert.Equal(t, ErrAlreadyEncrypted, w.Encrypt("passphrase", clk))
+
+	t.Log("the datastore backend is replaced by an encrypted one")
+	assert.Len(t, w.Backends(DSBackendType), 0)
+	assert.Len(t, w.Backends(EncryptedBackendType), 1)
+	encrypted, err := IsEncrypted(ds)
+	require.NoError(t, err)
+	assert.True(t, encrypted)
+
+	t.Log("the wallet is locked until unlocked with the passphrase")
+	assert.True(t, w.HasAddress(addr))
+	_, err = w.SignBytes([]byte("data"), addr)
+	assert.True(t, IsLocked(err))
+	assert.Equal(t, ErrBadPassphrase, w.Unlock("wrong", 0))
+	require.NoError(t, w.Unlock("passphrase", 0))
+	got, err := w.Export([]address.Address{addr})
+	require.NoError(t, err)
+	assert.True(t, ki[0].Equals(got[0]))
+}
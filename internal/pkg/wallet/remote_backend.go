@@ -0,0 +1,311 @@
+package wallet
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"io"
+	"io/ioutil"
+	"net"
+	"net/http"
+	"net/url"
+	"reflect"
+	"sync"
+	"time"
+
+	"github.com/filecoin-project/go-address"
+	logging "github.com/ipfs/go-log"
+	"github.com/pkg/errors"
+
+	"github.com/filecoin-project/go-filecoin/internal/pkg/config"
+	"github.com/filecoin-project/go-filecoin/internal/pkg/crypto"
+)
+
+// The remote signer protocol is JSON over HTTP, served on a unix socket or a
+// loopback TCP address:
+//
+//   GET /addresses
+//     200 {"addresses": ["<address>", ...]}
+//
+//   POST /sign {"address": "<address>", "data": "<base64 bytes>"}
+//     200 {"type": "secp256k1" | "bls", "data": "<base64 signature>"}
+//
+//   POST /publickey {"address": "<address>"}
+//     200 {"data": "<base64 public key>"}
+//
+// Failed requests respond with a non-2xx status and {"error": "<message>"}.
+// POST requests carry "Content-Type: application/json". Requests carry no
+// Origin header and are for a loopback host, or "unix" on a unix socket.
+
+var log = logging.Logger("wallet")
+
+// RemoteBackendType is the reflect type of the RemoteBackend.
+var RemoteBackendType = reflect.TypeOf(&RemoteBackend{})
+
+// ErrRemoteKey is returned when the private key of an address held by a
+// remote signer is requested.
+var ErrRemoteKey = errors.New("private key is held by the remote signer")
+
+const (
+	// RemoteSigTypeSecp256k1 names secp256k1 signatures in the remote signer protocol.
+	RemoteSigTypeSecp256k1 = "secp256k1"
+	// RemoteSigTypeBLS names BLS signatures in the remote signer protocol.
+	RemoteSigTypeBLS = "bls"
+)
+
+// remoteRefreshInterval limits how often an unknown address causes the
+// address list to be fetched again.
+const remoteRefreshInterval = 10 * time.Second
+
+// remoteRequestTimeout bounds each request to the remote signer.
+const remoteRequestTimeout = 30 * time.Second
+
+// RemoteAddressesResponse is the response to GET /addresses.
+type RemoteAddressesResponse struct {
+	Addresses []string `json:"addresses"`
+}
+
+// RemoteSignRequest is the request of POST /sign.
+type RemoteSignRequest struct {
+	Address string `json:"address"`
+	Data    []byte `json:"data"`
+}
+
+// RemoteSignResponse is the response to POST /sign.
+type RemoteSignResponse struct {
+	Type string `json:"type"`
+	Data []byte `json:"data"`
+}
+
+// RemotePublicKeyRequest is the request of POST /publickey.
+type RemotePublicKeyRequest struct {
+	Address string `json:"address"`
+}
+
+// RemotePublicKeyResponse is the response to POST /publickey.
+type RemotePublicKeyResponse struct {
+	Data []byte `json:"data"`
+}
+
+// RemoteErrorResponse is the body of a failed request.
+type RemoteErrorResponse struct {
+	Error string `json:"error"`
+}
+
+// RemoteBackend is a wallet backend forwarding signing to an external signer
+// process, so that no private keys are held by the node.
+type RemoteBackend struct {
+	client  *http.Client
+	baseURL string
+
+	lk        sync.RWMutex
+	cache     map[address.Address]struct{}
+	refreshed time.Time
+}
+
+var _ Backend = (*RemoteBackend)(nil)
+
+// NewRemoteBackend constructs a backend for the signer at target, either
+// unix:///path/to/socket or http://127.0.0.1:port. The signer need not be
+// running yet; addresses are fetched again when an unknown one is used.
+func NewRemoteBackend(target string) (*RemoteBackend, error) {
+	client, baseURL, err := remoteSignerClient(target)
+	if err != nil {
+		return nil, err
+	}
+	backend := &RemoteBackend{
+		client:  client,
+		baseURL: baseURL,
+		cache:   make(map[address.Address]struct{}),
+	}
+	if err := backend.refresh(); err != nil {
+		log.Warnf("failed to list addresses of remote signer %s: %s", target, err)
+	}
+	return backend, nil
+}
+
+// Addresses returns the addresses held by the remote signer.
+func (backend *RemoteBackend) Addresses() []address.Address {
+	if err := backend.refresh(); err != nil {
+		log.Warnf("failed to list addresses of remote signer: %s", err)
+	}
+
+	backend.lk.RLock()
+	defer backend.lk.RUnlock()
+
+	var cpy []address.Address
+	for addr := range backend.cache {
+		cpy = append(cpy, addr)
+	}
+	return cpy
+}
+
+// HasAddress checks if the remote signer holds the address.
+// Safe for concurrent access.
+func (backend *RemoteBackend) HasAddress(addr address.Address) bool {
+	backend.lk.RLock()
+	_, ok := backend.cache[addr]
+	stale := time.Since(backend.refreshed) >= remoteRefreshInterval
+	backend.lk.RUnlock()
+	if ok || !stale {
+		return ok
+	}
+
+	if err := backend.refresh(); err != nil {
+		log.Warnf("failed to list addresses of remote signer: %s", err)
+		return false
+	}
+	backend.lk.RLock()
+	defer backend.lk.RUnlock()
+	_, ok = backend.cache[addr]
+	return ok
+}
+
+// SignBytes asks the remote signer to sign `data` with the key of `addr`.
+// The signature is verified before it is returned.
+func (backend *RemoteBackend) SignBytes(data []byte, addr address.Address) (crypto.Signature, error) {
+	var resp RemoteSignResponse
+	req := RemoteSignRequest{Address: addr.String(), Data: data}
+	if err := backend.do(http.MethodPost, "/sign", &req, &resp); err != nil {
+		return crypto.Signature{}, errors.Wrapf(err, "remote signer failed to sign for %s", addr)
+	}
+
+	sig := crypto.Signature{Data: resp.Data}
+	switch resp.Type {
+	case RemoteSigTypeSecp256k1:
+		sig.Type = crypto.SigTypeSecp256k1
+	case RemoteSigTypeBLS:
+		sig.Type = crypto.SigTypeBLS
+	default:
+		return crypto.Signature{}, errors.Errorf("remote signer returned unknown signature type %q", resp.Type)
+	}
+	if err := crypto.ValidateSignature(data, addr, sig); err != nil {
+		return crypto.Signature{}, errors.Wrap(err, "remote signer returned invalid signature")
+	}
+	return sig, nil
+}
+
+// GetKeyInfo always fails: the keys never leave the remote signer.
+func (backend *RemoteBackend) GetKeyInfo(addr address.Address) (*crypto.KeyInfo, error) {
+	return nil, ErrRemoteKey
+}
+
+// PublicKey asks the remote signer for the public key of `addr`. The key is
+// checked against the address before it is returned.
+func (backend *RemoteBackend) PublicKey(addr address.Address) ([]byte, error) {
+	var resp RemotePublicKeyResponse
+	req := RemotePublicKeyRequest{Address: addr.String()}
+	if err := backend.do(http.MethodPost, "/publickey", &req, &resp); err != nil {
+		return nil, errors.Wrapf(err, "remote signer failed to return public key of %s", addr)
+	}
+
+	var derived address.Address
+	var err error
+	switch addr.Protocol() {
+	case address.SECP256K1:
+		derived, err = address.NewSecp256k1Address(resp.Data)
+	case address.BLS:
+		derived, err = address.NewBLSAddress(resp.Data)
+	default:
+		return nil, errors.Errorf("address %s has no public key", addr)
+	}
+	if err != nil || derived != addr {
+		return nil, errors.Errorf("remote signer returned invalid public key for %s", addr)
+	}
+	return resp.Data, nil
+}
+
+func (backend *RemoteBackend) refresh() error {
+	var resp RemoteAddressesResponse
+	err := backend.do(http.MethodGet, "/addresses", nil, &resp)
+
+	backend.lk.Lock()
+	defer backend.lk.Unlock()
+	backend.refreshed = time.Now()
+	if err != nil {
+		return err
+	}
+
+	cache := make(map[address.Address]struct{})
+	for _, s := range resp.Addresses {
+		addr, err := address.NewFromString(s)
+		if err != nil {
+			return errors.Wrapf(err, "remote signer returned invalid address %s", s)
+		}
+		cache[addr] = struct{}{}
+	}
+	backend.cache = cache
+	return nil
+}
+
+func (backend *RemoteBackend) do(method, path string, in, out interface{}) error {
+	var body io.Reader
+	if in != nil {
+		b, err := json.Marshal(in)
+		if err != nil {
+			return err
+		}
+		body = bytes.NewReader(b)
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), remoteRequestTimeout)
+	defer cancel()
+	req, err := http.NewRequest(method, backend.baseURL+path, body)
+	if err != nil {
+		return err
+	}
+	req = req.WithContext(ctx)
+	req.Header.Set("Content-Type", "application/json")
+
+	res, err := backend.client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer res.Body.Close() // nolint: errcheck
+
+	raw, err := ioutil.ReadAll(res.Body)
+	if err != nil {
+		return err
+	}
+	if res.StatusCode < 200 || res.StatusCode >= 300 {
+		var e RemoteErrorResponse
+		if json.Unmarshal(raw, &e) == nil && e.Error != "" {
+			return errors.New(e.Error)
+		}
+		return errors.Errorf("remote signer responded %s", res.Status)
+	}
+	return json.Unmarshal(raw, out)
+}
+
+// remoteSignerUnixHost is the host of requests to remote signers served on a
+// unix socket.
+const remoteSignerUnixHost = "unix"
+
+// remoteSignerClient returns an HTTP client and base URL for a signer target.
+func remoteSignerClient(target string) (*http.Client, string, error) {
+	u, err := url.Parse(target)
+	if err != nil {
+		return nil, "", errors.Wrapf(err, "invalid remote signer %s", target)
+	}
+
+	switch u.Scheme {
+	case "unix":
+		if u.Path == "" {
+			return nil, "", errors.Errorf("remote signer %s has no socket path", target)
+		}
+		socket := u.Path
+		transport := &http.Transport{
+			DialContext: func(ctx context.Context, _, _ string) (net.Conn, error) {
+				return (&net.Dialer{}).DialContext(ctx, "unix", socket)
+			},
+		}
+		return &http.Client{Transport: transport}, "http://" + remoteSignerUnixHost, nil
+	case "http":
+		if !config.IsLoopbackHost(u.Hostname()) {
+			return nil, "", errors.Errorf("remote signer %s is not on a loopback address", target)
+		}
+		return &http.Client{}, "http://" + u.Host, nil
+	default:
+		return nil, "", errors.Errorf("remote signer %s must be a unix:// or http:// address", target)
+	}
+}
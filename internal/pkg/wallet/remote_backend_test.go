@@ -0,0 +1,134 @@
+package wallet
+
+import (
+	"io/ioutil"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/filecoin-project/go-address"
+	"github.com/ipfs/go-datastore"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/filecoin-project/go-filecoin/internal/pkg/crypto"
+	tf "github.com/filecoin-project/go-filecoin/internal/pkg/testhelpers/testflags"
+)
+
+func newRemoteSignerKeys(t *testing.T) (*DSBackend, address.Address, address.Address) {
+	keys, err := NewDSBackend(datastore.NewMapDatastore())
+	require.NoError(t, err)
+	secp, err := keys.NewAddress(address.SECP256K1)
+	require.NoError(t, err)
+	bls, err := keys.NewAddress(address.BLS)
+	require.NoError(t, err)
+	return keys, secp, bls
+}
+
+func TestRemoteBackendHTTP(t *testing.T) {
+	tf.UnitTest(t)
+
+	keys, secp, bls := newRemoteSignerKeys(t)
+	server := httptest.NewServer(NewRemoteSignerHandler(keys))
+	defer server.Close()
+
+	backend, err := NewRemoteBackend(server.URL)
+	require.NoError(t, err)
+	assert.ElementsMatch(t, []address.Address{secp, bls}, backend.Addresses())
+
+	t.Log("the wallet routes signing to the remote signer")
+	w := New(backend)
+	for _, addr := range []address.Address{secp, bls} {
+		sig, err := w.SignBytes([]byte("data"), addr)
+		require.NoError(t, err)
+		assert.NoError(t, crypto.ValidateSignature([]byte("data"), addr, sig))
+	}
+
+	t.Log("private keys are not available")
+	_, err = backend.GetKeyInfo(secp)
+	assert.Equal(t, ErrRemoteKey, err)
+
+	t.Log("public keys are available")
+	for _, addr := range []address.Address{secp, bls} {
+		ki, err := keys.GetKeyInfo(addr)
+		require.NoError(t, err)
+		pk, err := w.GetPubKeyForAddress(addr)
+		require.NoError(t, err)
+		assert.Equal(t, ki.PublicKey(), pk)
+	}
+
+	t.Log("unknown addresses are rejected")
+	other, err := NewDSBackend(datastore.NewMapDatastore())
+	require.NoError(t, err)
+	unknown, err := other.NewAddress(address.SECP256K1)
+	require.NoError(t, err)
+	assert.False(t, backend.HasAddress(unknown))
+	_, err = backend.SignBytes([]byte("data"), unknown)
+	assert.Error(t, err)
+}
+
+func TestRemoteSignerRejectsCrossSiteRequests(t *testing.T) {
+	tf.UnitTest(t)
+
+	keys, secp, _ := newRemoteSignerKeys(t)
+	handler := NewRemoteSignerHandler(keys)
+	sign := func(host, origin, contentType string) int {
+		body := strings.NewReader(`{"address":"` + secp.String() + `","data":"ZGF0YQ=="}`)
+		req := httptest.NewRequest(http.MethodPost, "http://"+host+"/sign", body)
+		if origin != "" {
+			req.Header.Set("Origin", origin)
+		}
+		if contentType != "" {
+			req.Header.Set("Content-Type", contentType)
+		}
+		rec := httptest.NewRecorder()
+		handler.ServeHTTP(rec, req)
+		return rec.Code
+	}
+
+	assert.Equal(t, http.StatusOK, sign("127.0.0.1:1234", "", "application/json"))
+	assert.Equal(t, http.StatusOK, sign("unix", "", "application/json"))
+	assert.Equal(t, http.StatusForbidden, sign("127.0.0.1:1234", "http://evil.example", "application/json"))
+	assert.Equal(t, http.StatusForbidden, sign("evil.example:1234", "", "application/json"))
+	assert.Equal(t, http.StatusUnsupportedMediaType, sign("127.0.0.1:1234", "", "text/plain"))
+	assert.Equal(t, http.StatusUnsupportedMediaType, sign("127.0.0.1:1234", "", ""))
+}
+
+func TestRemoteBackendUnixSocket(t *testing.T) {
+	tf.UnitTest(t)
+
+	dir, err := ioutil.TempDir("", "remote-signer")
+	require.NoError(t, err)
+	defer func() {
+		require.NoError(t, os.RemoveAll(dir))
+	}()
+	socket := filepath.Join(dir, "signer.sock")
+	listener, err := net.Listen("unix", socket)
+	require.NoError(t, err)
+
+	keys, secp, _ := newRemoteSignerKeys(t)
+	server := &http.Server{Handler: NewRemoteSignerHandler(keys)}
+	go server.Serve(listener) // nolint: errcheck
+	defer server.Close()      // nolint: errcheck
+
+	backend, err := NewRemoteBackend("unix://" + socket)
+	require.NoError(t, err)
+	assert.True(t, backend.HasAddress(secp))
+
+	sig, err := backend.SignBytes([]byte("data"), secp)
+	require.NoError(t, err)
+	assert.NoError(t, crypto.ValidateSignature([]byte("data"), secp, sig))
+}
+
+func TestRemoteBackendRejectsNonLocalSigners(t *testing.T) {
+	tf.UnitTest(t)
+
+	_, err := NewRemoteBackend("http://10.0.0.1:5050")
+	assert.Error(t, err)
+	_, err = NewRemoteBackend("tcp://127.0.0.1:5050")
+	assert.Error(t, err)
+}
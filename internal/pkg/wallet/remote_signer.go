@@ -0,0 +1,136 @@
+package wallet
+
+import (
+	"encoding/json"
+	"mime"
+	"net"
+	"net/http"
+
+	"github.com/filecoin-project/go-address"
+
+	"github.com/filecoin-project/go-filecoin/internal/pkg/config"
+	"github.com/filecoin-project/go-filecoin/internal/pkg/crypto"
+)
+
+// NewRemoteSignerHandler serves the remote signer protocol for the keys in
+// backend. It is the server side of RemoteBackend. Requests a browser could
+// send on behalf of a web page are rejected: requests carrying an Origin, or
+// for a host other than a loopback address, and POST requests without a JSON
+// content type, which a cross-site form cannot send without a CORS preflight.
+func NewRemoteSignerHandler(backend Backend) http.Handler {
+	mux := http.NewServeMux()
+	mux.HandleFunc("/addresses", func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodGet {
+			writeRemoteError(w, http.StatusMethodNotAllowed, "method not allowed")
+			return
+		}
+		resp := RemoteAddressesResponse{Addresses: []string{}}
+		for _, addr := range backend.Addresses() {
+			resp.Addresses = append(resp.Addresses, addr.String())
+		}
+		writeRemoteJSON(w, http.StatusOK, &resp)
+	})
+	mux.HandleFunc("/sign", func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodPost {
+			writeRemoteError(w, http.StatusMethodNotAllowed, "method not allowed")
+			return
+		}
+		var req RemoteSignRequest
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			writeRemoteError(w, http.StatusBadRequest, "invalid request: "+err.Error())
+			return
+		}
+		addr, err := address.NewFromString(req.Address)
+		if err != nil {
+			writeRemoteError(w, http.StatusBadRequest, "invalid address: "+err.Error())
+			return
+		}
+		if !backend.HasAddress(addr) {
+			writeRemoteError(w, http.StatusNotFound, ErrUnknownAddress.Error())
+			return
+		}
+
+		sig, err := backend.SignBytes(req.Data, addr)
+		if err != nil {
+			writeRemoteError(w, http.StatusInternalServerError, err.Error())
+			return
+		}
+		resp := RemoteSignResponse{Data: sig.Data}
+		switch sig.Type {
+		case crypto.SigTypeSecp256k1:
+			resp.Type = RemoteSigTypeSecp256k1
+		case crypto.SigTypeBLS:
+			resp.Type = RemoteSigTypeBLS
+		default:
+			writeRemoteError(w, http.StatusInternalServerError, "unknown signature type")
+			return
+		}
+		writeRemoteJSON(w, http.StatusOK, &resp)
+	})
+	mux.HandleFunc("/publickey", func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodPost {
+			writeRemoteError(w, http.StatusMethodNotAllowed, "method not allowed")
+			return
+		}
+		var req RemotePublicKeyRequest
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			writeRemoteError(w, http.StatusBadRequest, "invalid request: "+err.Error())
+			return
+		}
+		addr, err := address.NewFromString(req.Address)
+		if err != nil {
+			writeRemoteError(w, http.StatusBadRequest, "invalid address: "+err.Error())
+			return
+		}
+		if !backend.HasAddress(addr) {
+			writeRemoteError(w, http.StatusNotFound, ErrUnknownAddress.Error())
+			return
+		}
+
+		ki, err := backend.GetKeyInfo(addr)
+		if err != nil {
+			writeRemoteError(w, http.StatusInternalServerError, err.Error())
+			return
+		}
+		writeRemoteJSON(w, http.StatusOK, &RemotePublicKeyResponse{Data: ki.PublicKey()})
+	})
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("Origin") != "" {
+			writeRemoteError(w, http.StatusForbidden, "origin not allowed")
+			return
+		}
+		if !isLocalHost(r.Host) {
+			writeRemoteError(w, http.StatusForbidden, "host not allowed")
+			return
+		}
+		if r.Method == http.MethodPost {
+			if mediaType, _, err := mime.ParseMediaType(r.Header.Get("Content-Type")); err != nil || mediaType != "application/json" {
+				writeRemoteError(w, http.StatusUnsupportedMediaType, "content type must be application/json")
+				return
+			}
+		}
+		mux.ServeHTTP(w, r)
+	})
+}
+
+// isLocalHost returns true if the host of a request, which may include a
+// port, is a loopback address or the host used on unix sockets.
+func isLocalHost(hostport string) bool {
+	host, _, err := net.SplitHostPort(hostport)
+	if err != nil {
+		host = hostport
+	}
+	return host == remoteSignerUnixHost || config.IsLoopbackHost(host)
+}
+
+func writeRemoteJSON(w http.ResponseWriter, status int, v interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	if err := json.NewEncoder(w).Encode(v); err != nil {
+		log.Warnf("failed to write remote signer response: %s", err)
+	}
+}
+
+func writeRemoteError(w http.ResponseWriter, status int, msg string) {
+	writeRemoteJSON(w, status, &RemoteErrorResponse{Error: msg})
+}
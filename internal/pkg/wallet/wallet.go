@@ -6,10 +6,12 @@ import (
 	"reflect"
 	"sort"
 	"sync"
+	"time"
 
 	"github.com/filecoin-project/go-address"
 	"github.com/pkg/errors"
 
+	"github.com/filecoin-project/go-filecoin/internal/pkg/clock"
 	"github.com/filecoin-project/go-filecoin/internal/pkg/crypto"
 )
 
@@ -108,23 +110,120 @@ func (w *Wallet) SignBytes(data []byte, addr address.Address) (crypto.Signature,
 
 // NewAddress creates a new account address on the default wallet backend.
 func NewAddress(w *Wallet, p address.Protocol) (address.Address, error) {
-	backends := w.Backends(DSBackendType)
+	backend, err := w.defaultBackend()
+	if err != nil {
+		return address.Undef, err
+	}
+	return backend.NewAddress(p)
+}
+
+// defaultBackend is a backend that can create and import keys.
+type defaultBackend interface {
+	Importer
+	NewAddress(p address.Protocol) (address.Address, error)
+}
+
+// defaultBackend returns the datastore backend, plaintext or encrypted, that
+// new and imported keys are stored in.
+func (w *Wallet) defaultBackend() (defaultBackend, error) {
+	dsb := append(w.Backends(DSBackendType), w.Backends(EncryptedBackendType)...)
+	if len(dsb) != 1 {
+		return nil, fmt.Errorf("expected exactly one datastore wallet backend")
+	}
+	return dsb[0].(defaultBackend), nil
+}
+
+// encryptedBackend returns the encrypted backend of the wallet.
+func (w *Wallet) encryptedBackend() (*EncryptedBackend, error) {
+	backends := w.Backends(EncryptedBackendType)
 	if len(backends) == 0 {
-		return address.Undef, fmt.Errorf("missing default ds backend")
+		return nil, ErrNotEncrypted
 	}
+	return backends[0].(*EncryptedBackend), nil
+}
 
-	backend := (backends[0]).(*DSBackend)
-	return backend.NewAddress(p)
+// Encrypt seals the keys of a plaintext wallet with a key derived from
+// passphrase, and replaces its datastore backend with an encrypted one. The
+// wallet is left locked.
+func (w *Wallet) Encrypt(passphrase string, clk clock.Clock) error {
+	w.lk.Lock()
+	defer w.lk.Unlock()
+
+	if len(w.backends[EncryptedBackendType]) > 0 {
+		return ErrAlreadyEncrypted
+	}
+	if len(w.backends[DSBackendType]) != 1 {
+		return fmt.Errorf("expected exactly one datastore wallet backend")
+	}
+	plain := w.backends[DSBackendType][0].(*DSBackend)
+
+	// Hold the plaintext backend so no key is written to it while sealing.
+	plain.lk.Lock()
+	defer plain.lk.Unlock()
+
+	if err := EncryptDatastore(plain.ds, passphrase); err != nil {
+		return err
+	}
+	encrypted, err := NewEncryptedBackend(plain.ds, clk)
+	if err != nil {
+		return err
+	}
+	delete(w.backends, DSBackendType)
+	w.backends[EncryptedBackendType] = []Backend{encrypted}
+	return nil
+}
+
+// Unlock makes the keys of an encrypted wallet available for timeout, or
+// until Lock is called if timeout is zero.
+func (w *Wallet) Unlock(passphrase string, timeout time.Duration) error {
+	backend, err := w.encryptedBackend()
+	if err != nil {
+		return err
+	}
+	return backend.Unlock(passphrase, timeout)
+}
+
+// Lock makes the keys of an encrypted wallet unavailable.
+func (w *Wallet) Lock() error {
+	backend, err := w.encryptedBackend()
+	if err != nil {
+		return err
+	}
+	backend.Lock()
+	return nil
+}
+
+// ChangePassphrase reseals the keys of an encrypted wallet with a new
+// passphrase. The wallet is left locked.
+func (w *Wallet) ChangePassphrase(oldPassphrase, newPassphrase string) error {
+	backend, err := w.encryptedBackend()
+	if err != nil {
+		return err
+	}
+	return backend.ChangePassphrase(oldPassphrase, newPassphrase)
+}
+
+// publicKeyer is a backend that provides public keys without exposing the
+// private keys, such as a remote signer.
+type publicKeyer interface {
+	PublicKey(addr address.Address) ([]byte, error)
 }
 
 // GetPubKeyForAddress returns the public key in the keystore associated with
 // the given address.
 func (w *Wallet) GetPubKeyForAddress(addr address.Address) ([]byte, error) {
-	info, err := w.keyInfoForAddr(addr)
+	backend, err := w.Find(addr)
 	if err != nil {
 		return nil, err
 	}
+	if pk, ok := backend.(publicKeyer); ok {
+		return pk.PublicKey(addr)
+	}
 
+	info, err := backend.GetKeyInfo(addr)
+	if err != nil {
+		return nil, err
+	}
 	return info.PublicKey(), nil
 }
 
@@ -153,14 +252,9 @@ func (w *Wallet) keyInfoForAddr(addr address.Address) (*crypto.KeyInfo, error) {
 
 // Import adds the given keyinfos to the wallet
 func (w *Wallet) Import(kinfos ...*crypto.KeyInfo) ([]address.Address, error) {
-	dsb := w.Backends(DSBackendType)
-	if len(dsb) != 1 {
-		return nil, fmt.Errorf("expected exactly one datastore wallet backend")
-	}
-
-	imp, ok := dsb[0].(Importer)
-	if !ok {
-		return nil, fmt.Errorf("datastore backend wallets should implement importer")
+	imp, err := w.defaultBackend()
+	if err != nil {
+		return nil, err
 	}
 
 	var out []address.Address
@@ -12,6 +12,9 @@ package internal
 
 import (
 	migration12 "github.com/filecoin-project/go-filecoin/tools/migration/migrations/repo-1-2"
+	migration23 "github.com/filecoin-project/go-filecoin/tools/migration/migrations/repo-2-3"
+	migration34 "github.com/filecoin-project/go-filecoin/tools/migration/migrations/repo-3-4"
+	migration45 "github.com/filecoin-project/go-filecoin/tools/migration/migrations/repo-4-5"
 )
 
 // DefaultMigrationsProvider is the migrations provider dependency used in production.
@@ -19,5 +22,8 @@ import (
 func DefaultMigrationsProvider() []Migration {
 	return []Migration{
 		&migration12.MetadataFormatJSONtoCBOR{},
+		&migration23.WalletEncryptionMoved{},
+		&migration34.PieceBlockstoreSplit{},
+		&migration45.WalletPlaintextToEncrypted{},
 	}
 }
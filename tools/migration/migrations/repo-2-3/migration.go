@@ -0,0 +1,47 @@
+package migration23
+
+import (
+	"github.com/filecoin-project/go-filecoin/internal/pkg/repo"
+)
+
+// WalletEncryptionMoved is the migration from version 2 to 3.
+//
+// Version 3 originally encrypted the wallet. That step moved to the 4 to 5
+// migration so that it runs after the piece blockstore split, and this
+// migration is kept so that version 2 repos keep a migration path.
+type WalletEncryptionMoved struct{}
+
+// Describe describes the steps this migration will take.
+func (m *WalletEncryptionMoved) Describe() string {
+	return `WalletEncryptionMoved migrates the storage repo from version 2 to 3.
+
+    This migration changes no repo data. Wallet encryption is done by the
+    migration from version 4 to 5.
+`
+}
+
+// Migrate performs the migration steps
+func (m *WalletEncryptionMoved) Migrate(newRepoPath string) error {
+	oldVer, _ := m.Versions()
+	fsrepo, err := repo.OpenFSRepo(newRepoPath, oldVer)
+	if err != nil {
+		return err
+	}
+	return fsrepo.Close()
+}
+
+// Versions returns the old and new versions that are valid for this migration
+func (m *WalletEncryptionMoved) Versions() (from, to uint) {
+	return 2, 3
+}
+
+// Validate checks that the new repo opens at the old version.
+func (m *WalletEncryptionMoved) Validate(oldRepoPath, newRepoPath string) error {
+	oldVer, _ := m.Versions()
+	// Version hasn't been updated yet.
+	fsrepo, err := repo.OpenFSRepo(newRepoPath, oldVer)
+	if err != nil {
+		return err
+	}
+	return fsrepo.Close()
+}
@@ -0,0 +1,264 @@
+package migration34
+
+import (
+	"context"
+	"strings"
+
+	"github.com/ipfs/go-cid"
+	"github.com/ipfs/go-datastore"
+	dsq "github.com/ipfs/go-datastore/query"
+	bstore "github.com/ipfs/go-ipfs-blockstore"
+	cbor "github.com/ipfs/go-ipld-cbor"
+	format "github.com/ipfs/go-ipld-format"
+	_ "github.com/ipfs/go-merkledag" // registers the block decoders used to find links
+	mh "github.com/multiformats/go-multihash"
+	"github.com/pkg/errors"
+
+	"github.com/filecoin-project/go-filecoin/internal/pkg/encoding"
+	"github.com/filecoin-project/go-filecoin/internal/pkg/repo"
+	"github.com/filecoin-project/go-filecoin/internal/pkg/types"
+)
+
+// The namespaces below are duplicated here from the gc package and the
+// storage and retrieval markets to protect against future changes.
+
+// pinsPrefix holds the garbage collection pins, which include the roots of
+// data imported for deals.
+var pinsPrefix = datastore.NewKey("/gc/pins")
+
+// dealRecordPrefixes hold the deal and piece records of the storage and
+// retrieval markets, which link to the payload roots of deals.
+var dealRecordPrefixes = []datastore.Key{
+	datastore.NewKey("/deals/provider"),
+	datastore.NewKey("/deals/client"),
+	datastore.NewKey("/retrievals/provider"),
+	datastore.NewKey("/retrievals/client"),
+	datastore.NewKey("/pieces"),
+	datastore.NewKey("/cid-infos"),
+}
+
+// PieceBlockstoreSplit is the migration from version 3 to 4.
+type PieceBlockstoreSplit struct{}
+
+// Describe describes the steps this migration will take.
+func (m *PieceBlockstoreSplit) Describe() string {
+	return `PieceBlockstoreSplit migrates the storage repo from version 3 to 4.
+
+    This migration moves client and piece data out of the blockstore it shared
+    with chain data, into the new piece datastore. The data moved is every block
+    reachable from the roots of data imported for deals, which are pinned, and
+    from the payload roots recorded by the storage and retrieval markets. Chain
+    messages linked from these records stay in place. Pins are kept. No other
+    repo data is changed.
+`
+}
+
+// Migrate performs the migration steps
+func (m *PieceBlockstoreSplit) Migrate(newRepoPath string) error {
+	oldVer, _ := m.Versions()
+	fsrepo, err := repo.OpenFSRepo(newRepoPath, oldVer)
+	if err != nil {
+		return err
+	}
+	defer mustCloseRepo(fsrepo)
+
+	chainBs := bstore.NewBlockstore(fsrepo.Datastore())
+	pieceBs := bstore.NewBlockstore(fsrepo.PieceDatastore())
+
+	pieceCids, err := pieceBlocks(fsrepo.Datastore(), chainBs)
+	if err != nil {
+		return err
+	}
+	for _, c := range pieceCids {
+		blk, err := chainBs.Get(c)
+		if err != nil {
+			return errors.Wrapf(err, "failed to read block %s", c)
+		}
+		if err := pieceBs.Put(blk); err != nil {
+			return errors.Wrapf(err, "failed to write block %s", c)
+		}
+		if err := chainBs.DeleteBlock(c); err != nil {
+			return errors.Wrapf(err, "failed to delete block %s", c)
+		}
+	}
+	return nil
+}
+
+// Versions returns the old and new versions that are valid for this migration
+func (m *PieceBlockstoreSplit) Versions() (from, to uint) {
+	return 3, 4
+}
+
+// Validate checks that every piece block of the old blockstore is in the new
+// piece blockstore and no longer in the new chain blockstore, and that every
+// other block was left in place.
+func (m *PieceBlockstoreSplit) Validate(oldRepoPath, newRepoPath string) error {
+	oldVer, _ := m.Versions()
+	oldFsRepo, err := repo.OpenFSRepo(oldRepoPath, oldVer)
+	if err != nil {
+		return err
+	}
+	defer mustCloseRepo(oldFsRepo)
+
+	// Version hasn't been updated yet.
+	newFsRepo, err := repo.OpenFSRepo(newRepoPath, oldVer)
+	if err != nil {
+		return err
+	}
+	defer mustCloseRepo(newFsRepo)
+
+	oldBs := bstore.NewBlockstore(oldFsRepo.Datastore())
+	newChainBs := bstore.NewBlockstore(newFsRepo.Datastore())
+	newPieceBs := bstore.NewBlockstore(newFsRepo.PieceDatastore())
+
+	pieceCids, err := pieceBlocks(oldFsRepo.Datastore(), oldBs)
+	if err != nil {
+		return err
+	}
+	isPiece := make(map[cid.Cid]struct{}, len(pieceCids))
+	for _, c := range pieceCids {
+		isPiece[c] = struct{}{}
+	}
+
+	keys, err := oldBs.AllKeysChan(context.Background())
+	if err != nil {
+		return err
+	}
+	for c := range keys {
+		inChain, err := newChainBs.Has(c)
+		if err != nil {
+			return err
+		}
+		inPiece, err := newPieceBs.Has(c)
+		if err != nil {
+			return err
+		}
+		if _, ok := isPiece[c]; ok {
+			if inChain || !inPiece {
+				return errors.Errorf("piece block %s was not moved", c)
+			}
+		} else if !inChain {
+			return errors.Errorf("chain block %s is missing", c)
+		}
+	}
+	return nil
+}
+
+// pieceBlocks returns the cids of the blocks of bs reachable from the piece
+// roots recorded in ds. The blocks are collected before any is moved:
+// deleting while iterating is not supported by every datastore.
+func pieceBlocks(ds datastore.Datastore, bs bstore.Blockstore) ([]cid.Cid, error) {
+	roots, err := pieceRoots(ds)
+	if err != nil {
+		return nil, err
+	}
+
+	seen := make(map[cid.Cid]struct{})
+	var blocks []cid.Cid
+	stack := roots
+	for len(stack) > 0 {
+		c := stack[len(stack)-1]
+		stack = stack[:len(stack)-1]
+		if _, ok := seen[c]; ok {
+			continue
+		}
+		seen[c] = struct{}{}
+
+		blk, err := bs.Get(c)
+		if err == bstore.ErrNotFound {
+			// Piece commitments and deal proposals are not stored as blocks.
+			continue
+		}
+		if err != nil {
+			return nil, err
+		}
+		if isChainMessage(blk.RawData()) {
+			continue
+		}
+		blocks = append(blocks, c)
+
+		if c.Prefix().Codec == cid.Raw {
+			continue
+		}
+		nd, err := format.Decode(blk)
+		if err != nil {
+			return nil, errors.Wrapf(err, "failed to decode piece block %s", c)
+		}
+		for _, l := range nd.Links() {
+			stack = append(stack, l.Cid)
+		}
+	}
+	return blocks, nil
+}
+
+// pieceRoots returns the pinned roots and the cids linked from the deal and
+// piece records of ds.
+func pieceRoots(ds datastore.Datastore) ([]cid.Cid, error) {
+	pins, err := queryKeys(ds, pinsPrefix)
+	if err != nil {
+		return nil, err
+	}
+	var roots []cid.Cid
+	for _, k := range pins {
+		c, err := cid.Decode(strings.TrimPrefix(k, "/"))
+		if err != nil {
+			return nil, errors.Wrapf(err, "invalid pin %s", k)
+		}
+		roots = append(roots, c)
+	}
+
+	for _, prefix := range dealRecordPrefixes {
+		res, err := ds.Query(dsq.Query{Prefix: prefix.String()})
+		if err != nil {
+			return nil, err
+		}
+		entries, err := res.Rest()
+		if err != nil {
+			return nil, err
+		}
+		for _, e := range entries {
+			nd, err := cbor.Decode(e.Value, mh.SHA2_256, -1)
+			if err != nil {
+				return nil, errors.Wrapf(err, "failed to decode deal record %s", e.Key)
+			}
+			for _, l := range nd.Links() {
+				roots = append(roots, l.Cid)
+			}
+		}
+	}
+	return roots, nil
+}
+
+// isChainMessage returns true if raw is a signed or unsigned chain message,
+// such as a pinned wallet message or the publish message of a deal.
+func isChainMessage(raw []byte) bool {
+	var signed types.SignedMessage
+	if err := encoding.Decode(raw, &signed); err == nil {
+		return true
+	}
+	var unsigned types.UnsignedMessage
+	return encoding.Decode(raw, &unsigned) == nil
+}
+
+func queryKeys(ds datastore.Datastore, prefix datastore.Key) ([]string, error) {
+	res, err := ds.Query(dsq.Query{Prefix: prefix.String(), KeysOnly: true})
+	if err != nil {
+		return nil, err
+	}
+	entries, err := res.Rest()
+	if err != nil {
+		return nil, err
+	}
+	var keys []string
+	for _, e := range entries {
+		keys = append(keys, strings.TrimPrefix(e.Key, prefix.String()))
+	}
+	return keys, nil
+}
+
+func mustCloseRepo(fsRepo *repo.FSRepo) {
+	err := fsRepo.Close()
+	if err != nil {
+		panic(err)
+	}
+}
@@ -0,0 +1,134 @@
+package migration45
+
+import (
+	"os"
+
+	"github.com/pkg/errors"
+
+	"github.com/filecoin-project/go-filecoin/internal/pkg/clock"
+	"github.com/filecoin-project/go-filecoin/internal/pkg/repo"
+	"github.com/filecoin-project/go-filecoin/internal/pkg/wallet"
+)
+
+// PassphraseEnv is the environment variable holding the passphrase the wallet
+// is encrypted with.
+const PassphraseEnv = "FIL_WALLET_PASSPHRASE"
+
+// WalletPlaintextToEncrypted is the migration from version 4 to 5.
+type WalletPlaintextToEncrypted struct{}
+
+// Describe describes the steps this migration will take.
+func (m *WalletPlaintextToEncrypted) Describe() string {
+	return `WalletPlaintextToEncrypted migrates the storage repo from version 4 to 5.
+
+    If the ` + PassphraseEnv + ` environment variable is set, this migration
+    encrypts the wallet datastore with a key derived from its value. The wallet
+    must then be unlocked with the passphrase after the node starts. If the
+    variable is not set, the wallet is left unencrypted and can be encrypted later
+    with 'go-filecoin wallet encrypt'. Wallets that are already encrypted are left
+    unchanged. No other repo data is changed.
+`
+}
+
+// Migrate performs the migration steps
+func (m *WalletPlaintextToEncrypted) Migrate(newRepoPath string) error {
+	passphrase := os.Getenv(PassphraseEnv)
+	if passphrase == "" {
+		// Encryption is opt-in.
+		return nil
+	}
+
+	oldVer, _ := m.Versions()
+	fsrepo, err := repo.OpenFSRepo(newRepoPath, oldVer)
+	if err != nil {
+		return err
+	}
+	defer mustCloseRepo(fsrepo)
+
+	err = wallet.EncryptDatastore(fsrepo.WalletDatastore(), passphrase)
+	if err == wallet.ErrAlreadyEncrypted {
+		return nil
+	}
+	return err
+}
+
+// Versions returns the old and new versions that are valid for this migration
+func (m *WalletPlaintextToEncrypted) Versions() (from, to uint) {
+	return 4, 5
+}
+
+// Validate checks that every key of the old wallet is in the new wallet. If the
+// new wallet was encrypted by the migration, the keys must open with the
+// passphrase to the old keys.
+func (m *WalletPlaintextToEncrypted) Validate(oldRepoPath, newRepoPath string) error {
+	oldVer, _ := m.Versions()
+	oldFsRepo, err := repo.OpenFSRepo(oldRepoPath, oldVer)
+	if err != nil {
+		return err
+	}
+	defer mustCloseRepo(oldFsRepo)
+
+	// Version hasn't been updated yet.
+	newFsRepo, err := repo.OpenFSRepo(newRepoPath, oldVer)
+	if err != nil {
+		return err
+	}
+	defer mustCloseRepo(newFsRepo)
+
+	oldDs, newDs := oldFsRepo.WalletDatastore(), newFsRepo.WalletDatastore()
+	oldEncrypted, err := wallet.IsEncrypted(oldDs)
+	if err != nil || oldEncrypted {
+		// Nothing was migrated.
+		return err
+	}
+	newEncrypted, err := wallet.IsEncrypted(newDs)
+	if err != nil {
+		return err
+	}
+	passphrase := os.Getenv(PassphraseEnv)
+	if !newEncrypted {
+		if passphrase != "" {
+			return errors.New("migrated wallet is not encrypted")
+		}
+		return nil
+	}
+
+	oldBackend, err := wallet.NewDSBackend(oldDs)
+	if err != nil {
+		return err
+	}
+	newBackend, err := wallet.NewEncryptedBackend(newDs, clock.NewSystemClock())
+	if err != nil {
+		return err
+	}
+	if err := newBackend.Unlock(passphrase, 0); err != nil {
+		return errors.Wrap(err, "migrated wallet does not open with the passphrase")
+	}
+	defer newBackend.Lock()
+
+	oldAddrs, newAddrs := oldBackend.Addresses(), newBackend.Addresses()
+	if len(oldAddrs) != len(newAddrs) {
+		return errors.Errorf("migrated wallet has %d addresses, expected %d", len(newAddrs), len(oldAddrs))
+	}
+	for _, a := range oldAddrs {
+		oldKi, err := oldBackend.GetKeyInfo(a)
+		if err != nil {
+			return err
+		}
+		newKi, err := newBackend.GetKeyInfo(a)
+		if err != nil {
+			return errors.Wrapf(err, "failed to open migrated key of %s", a)
+		}
+		if !oldKi.Equals(newKi) {
+			return errors.Errorf("migrated key of %s does not match", a)
+		}
+	}
+	return nil
+}
+
+func mustCloseRepo(fsRepo *repo.FSRepo) {
+	err := fsRepo.Close()
+	if err != nil {
+		panic(err)
+	}
+}
@@ -0,0 +1,83 @@
+package migration45_test
+
+import (
+	"os"
+	"testing"
+
+	"github.com/filecoin-project/go-address"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/filecoin-project/go-filecoin/internal/pkg/clock"
+	"github.com/filecoin-project/go-filecoin/internal/pkg/repo"
+	tf "github.com/filecoin-project/go-filecoin/internal/pkg/testhelpers/testflags"
+	"github.com/filecoin-project/go-filecoin/internal/pkg/wallet"
+	"github.com/filecoin-project/go-filecoin/tools/migration/internal"
+	migration45 "github.com/filecoin-project/go-filecoin/tools/migration/migrations/repo-4-5"
+)
+
+func TestWalletPlaintextToEncrypted(t *testing.T) {
+	tf.UnitTest(t)
+
+	setup := func(t *testing.T) (container, oldRepoPath, newRepoPath string, addr address.Address) {
+		container, repoLink := internal.RequireInitRepo(t, 4)
+		oldRepoPath = repo.RequireReadLink(t, repoLink)
+
+		fsrepo, err := repo.OpenFSRepo(oldRepoPath, 4)
+		require.NoError(t, err)
+		backend, err := wallet.NewDSBackend(fsrepo.WalletDatastore())
+		require.NoError(t, err)
+		addr, err = backend.NewAddress(address.SECP256K1)
+		require.NoError(t, err)
+		require.NoError(t, fsrepo.Close())
+
+		newRepoPath, err = internal.CloneRepo(repoLink, 5)
+		require.NoError(t, err)
+		return container, oldRepoPath, newRepoPath, addr
+	}
+
+	t.Run("encrypts the wallet when the passphrase is set", func(t *testing.T) {
+		require.NoError(t, os.Setenv(migration45.PassphraseEnv, "correct horse"))
+		defer func() { require.NoError(t, os.Unsetenv(migration45.PassphraseEnv)) }()
+
+		container, oldRepoPath, newRepoPath, addr := setup(t)
+		defer repo.RequireRemoveAll(t, container)
+		mig := migration45.WalletPlaintextToEncrypted{}
+		require.NoError(t, mig.Migrate(newRepoPath))
+		require.NoError(t, mig.Validate(oldRepoPath, newRepoPath))
+
+		fsrepo, err := repo.OpenFSRepo(newRepoPath, 4)
+		require.NoError(t, err)
+		defer func() { require.NoError(t, fsrepo.Close()) }()
+		encrypted, err := wallet.IsEncrypted(fsrepo.WalletDatastore())
+		require.NoError(t, err)
+		assert.True(t, encrypted)
+
+		backend, err := wallet.NewEncryptedBackend(fsrepo.WalletDatastore(), clock.NewSystemClock())
+		require.NoError(t, err)
+		assert.True(t, backend.HasAddress(addr))
+		assert.Error(t, backend.Unlock("wrong", 0))
+		require.NoError(t, backend.Unlock("correct horse", 0))
+		_, err = backend.GetKeyInfo(addr)
+		assert.NoError(t, err)
+	})
+
+	t.Run("leaves the wallet unencrypted without a passphrase", func(t *testing.T) {
+		container, oldRepoPath, newRepoPath, addr := setup(t)
+		defer repo.RequireRemoveAll(t, container)
+		mig := migration45.WalletPlaintextToEncrypted{}
+		require.NoError(t, mig.Migrate(newRepoPath))
+		require.NoError(t, mig.Validate(oldRepoPath, newRepoPath))
+
+		fsrepo, err := repo.OpenFSRepo(newRepoPath, 4)
+		require.NoError(t, err)
+		defer func() { require.NoError(t, fsrepo.Close()) }()
+		encrypted, err := wallet.IsEncrypted(fsrepo.WalletDatastore())
+		require.NoError(t, err)
+		assert.False(t, encrypted)
+
+		backend, err := wallet.NewDSBackend(fsrepo.WalletDatastore())
+		require.NoError(t, err)
+		assert.True(t, backend.HasAddress(addr))
+	})
+}
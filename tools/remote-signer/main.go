@@ -0,0 +1,98 @@
+// remote-signer is a reference implementation of the remote signer protocol
+// used by the wallet.remoteSigner config option. It holds keys in memory and
+// serves signing requests on a unix socket or a loopback TCP address.
+package main
+
+import (
+	"encoding/json"
+	"flag"
+	"fmt"
+	"net"
+	"net/http"
+	"os"
+	"strings"
+
+	"github.com/ipfs/go-datastore"
+
+	"github.com/filecoin-project/go-filecoin/internal/pkg/config"
+	"github.com/filecoin-project/go-filecoin/internal/pkg/crypto"
+	"github.com/filecoin-project/go-filecoin/internal/pkg/wallet"
+)
+
+// keyFile is the format written by `go-filecoin wallet export --enc=json`.
+type keyFile struct {
+	KeyInfo []*crypto.KeyInfo
+}
+
+func main() {
+	listen := flag.String("listen", "", "unix:///path/to/socket or a loopback host:port to serve on")
+	keyFilePath := flag.String("keyfile", "", "path of a file containing keys exported with 'go-filecoin wallet export --enc=json'")
+	flag.Parse()
+
+	if *listen == "" || *keyFilePath == "" {
+		fmt.Println("Please specify --listen and --keyfile")
+		os.Exit(1)
+	}
+
+	keys, err := loadKeys(*keyFilePath)
+	if err != nil {
+		fmt.Println(err)
+		os.Exit(1)
+	}
+
+	listener, err := listenLocal(*listen)
+	if err != nil {
+		fmt.Println(err)
+		os.Exit(1)
+	}
+
+	fmt.Printf("Serving %d addresses on %s\n", len(keys.Addresses()), *listen)
+	// Serve only returns on failure.
+	err = http.Serve(listener, wallet.NewRemoteSignerHandler(keys))
+	fmt.Printf("failed to serve: %s\n", err)
+	os.Exit(1)
+}
+
+// loadKeys reads keys into an in-memory wallet backend.
+func loadKeys(path string) (*wallet.DSBackend, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close() // nolint: errcheck
+
+	var kf keyFile
+	if err := json.NewDecoder(f).Decode(&kf); err != nil {
+		return nil, fmt.Errorf("failed to read key file: %s", err)
+	}
+	if len(kf.KeyInfo) == 0 {
+		return nil, fmt.Errorf("no keys in key file")
+	}
+
+	keys, err := wallet.NewDSBackend(datastore.NewMapDatastore())
+	if err != nil {
+		return nil, err
+	}
+	for _, ki := range kf.KeyInfo {
+		if err := keys.ImportKey(ki); err != nil {
+			return nil, err
+		}
+	}
+	return keys, nil
+}
+
+// listenLocal listens on a unix socket or a loopback TCP address.
+func listenLocal(addr string) (net.Listener, error) {
+	if strings.HasPrefix(addr, "unix://") {
+		return net.Listen("unix", strings.TrimPrefix(addr, "unix://"))
+	}
+
+	host, _, err := net.SplitHostPort(addr)
+	if err != nil {
+		return nil, err
+	}
+	if !config.IsLoopbackHost(host) {
+		return nil, fmt.Errorf("refusing to listen on non-loopback address %s", addr)
+	}
+	return net.Listen("tcp", addr)
+}